@@ -47,8 +47,9 @@ Create Access Token
 Subcommand 'create-token' generates a new access token with the given name.
 Flag -net means to create a network token,
 otherwise it will create a client token.
+Flag -expires makes the token expire after the given duration.
 
-    corectl create-token [-net] [name]
+    corectl create-token [-net] [-expires duration] [name]
 
 Reset
 
@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"chain/core/accesstoken"
@@ -20,6 +22,7 @@ import (
 	chainjson "chain/encoding/json"
 	"chain/env"
 	"chain/log"
+	"chain/protocol/bc"
 )
 
 // config vars
@@ -69,8 +72,32 @@ func main() {
 	cmd.f(db, os.Args[2:])
 }
 
+// supplyCaps implements flag.Value, collecting
+// the supply caps given by repeated flags.
+type supplyCaps []bc.SupplyCap
+
+func (c *supplyCaps) String() string { return fmt.Sprint(*c) }
+
+func (c *supplyCaps) Set(s string) error {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return errors.New("want asset:amount")
+	}
+	var sc bc.SupplyCap
+	err := sc.AssetID.UnmarshalText([]byte(s[:i]))
+	if err != nil {
+		return err
+	}
+	sc.Amount, err = strconv.ParseUint(s[i+1:], 10, 63)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, sc)
+	return nil
+}
+
 func configGenerator(db *sql.DB, args []string) {
-	const usage = "usage: corectl config-generator [-s] [-w duration] [quorum] [pubkey url]..."
+	const usage = "usage: corectl config-generator [-s] [-w duration] [-cap asset:amount]... [quorum] [pubkey url]..."
 	var (
 		quorum  int
 		signers []config.BlockSigner
@@ -80,6 +107,8 @@ func configGenerator(db *sql.DB, args []string) {
 	var flags flag.FlagSet
 	maxIssuanceWindow := flags.Duration("w", 24*time.Hour, "the maximum issuance window `duration` for this generator")
 	isSigner := flags.Bool("s", false, "whether this core is a signer")
+	var caps supplyCaps
+	flags.Var(&caps, "cap", "an `asset:amount` supply cap to commit in the initial block (repeatable)")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
@@ -126,6 +155,7 @@ func configGenerator(db *sql.DB, args []string) {
 		MaxIssuanceWindow: chainjson.Duration{
 			Duration: *maxIssuanceWindow,
 		},
+		SupplyCaps: caps,
 	}
 
 	ctx := context.Background()
@@ -153,9 +183,13 @@ func createBlockKeyPair(db *sql.DB, args []string) {
 }
 
 func createToken(db *sql.DB, args []string) {
-	const usage = "usage: corectl create-token [-net] [name]"
+	const usage = "usage: corectl create-token [-net|-explorer|-admin] [-expires duration] [-namespace ns] [name]"
 	var flags flag.FlagSet
 	flagNet := flags.Bool("net", false, "create a network token instead of client")
+	flagExplorer := flags.Bool("explorer", false, "create a read-only block explorer token instead of client")
+	flagAdmin := flags.Bool("admin", false, "create an admin token instead of client")
+	flagExpires := flags.Duration("expires", 0, "expire the token after `duration` (default never)")
+	flagNamespace := flags.String("namespace", "", "confine a client token to the asset aliases in `ns`")
 	flags.Usage = func() {
 		fmt.Println(usage)
 		flags.PrintDefaults()
@@ -169,7 +203,17 @@ func createToken(db *sql.DB, args []string) {
 
 	accessTokens := &accesstoken.CredentialStore{DB: db}
 	typ := map[bool]string{true: "network", false: "client"}[*flagNet]
-	tok, err := accessTokens.Create(context.Background(), args[0], typ)
+	if *flagExplorer {
+		typ = "explorer"
+	}
+	if *flagAdmin {
+		typ = "admin"
+	}
+	var expiresAt time.Time
+	if *flagExpires > 0 {
+		expiresAt = time.Now().Add(*flagExpires)
+	}
+	tok, err := accessTokens.Create(context.Background(), args[0], typ, *flagNamespace, expiresAt)
 	if err != nil {
 		fatalln("error:", err)
 	}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/coreos/etcd/client"
+
+	"chain/core/failover"
+	"chain/core/leader"
+	"chain/database/sql"
+	"chain/env"
+	chainlog "chain/log"
+	"chain/net/etcdname"
+)
+
+var (
+	standbyDBURL  = env.String("STANDBY_DATABASE_URL", "") // empty disables failover
+	failoverAfter = env.Duration("FAILOVER_AFTER", 30*time.Second)
+	failoverKey   = env.String("FAILOVER_ETCD_KEY", "/chain/core")
+)
+
+// setUpFailover points dbURL at the active database, if a
+// standby database is configured, and starts monitoring the
+// primary. The process exits when the core fails over, so it
+// can be restarted on the new database.
+// It returns the fence for leadership, or nil if there is
+// no standby.
+func setUpFailover(ctx context.Context) leader.Fence {
+	if *standbyDBURL == "" {
+		return nil
+	}
+	etcd, err := etcdname.Client()
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err, "at", "configuring failover")
+	}
+	coord := &failover.Coordinator{
+		Keys:   client.NewKeysAPI(etcd),
+		Prefix: *failoverKey,
+		After:  *failoverAfter,
+	}
+	coord.Database, err = coord.Active(ctx)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+
+	primaryURL := *dbURL
+	if coord.Database == failover.Standby {
+		chainlog.Messagef(ctx, "Using the standby database")
+		*dbURL = *standbyDBURL
+	}
+
+	// The checks get their own connections, so
+	// they don't wait behind the core's queries.
+	primary, err := sql.Open("hapg", primaryURL)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	primary.SetMaxOpenConns(1)
+	standby, err := sql.Open("hapg", *standbyDBURL)
+	if err != nil {
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}
+	standby.SetMaxOpenConns(1)
+	go func() {
+		err := coord.Monitor(ctx, *listenAddr, failover.Writable(primary), failover.Writable(standby))
+		chainlog.Fatal(ctx, chainlog.KeyError, err)
+	}()
+	return coord
+}
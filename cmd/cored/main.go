@@ -8,34 +8,49 @@ import (
 	"expvar"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/exec"
 	"runtime"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/kr/secureheader"
 
 	"chain/core"
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
+	"chain/core/balancehook"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/counterparty"
+	"chain/core/dupdetect"
+	"chain/core/feedsink"
 	"chain/core/fetch"
 	"chain/core/generator"
 	"chain/core/leader"
 	"chain/core/migrate"
 	"chain/core/mockhsm"
+	"chain/core/msgrelay"
 	"chain/core/pin"
+	"chain/core/progtemplate"
 	"chain/core/query"
+	"chain/core/quota"
 	"chain/core/rpc"
+	"chain/core/swap"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/watch"
 	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
 	"chain/database/sql"
 	"chain/env"
 	"chain/errors"
@@ -68,17 +83,41 @@ var (
 	rpsToken      = env.Int("RATELIMIT_TOKEN", 0)       // reqs/sec
 	rpsRemoteAddr = env.Int("RATELIMIT_REMOTE_ADDR", 0) // reqs/sec
 	indexTxs      = env.Bool("INDEX_TRANSACTIONS", true)
+	hedgeDelay    = env.Duration("RPC_HEDGE_DELAY", 0)     // 0 disables hedging
+	submitWindow  = env.Duration("SUBMIT_BATCH_WINDOW", 0) // 0 disables batching
+	submitBatch   = env.Int("SUBMIT_BATCH_SIZE", 100)
+	replayWindow  = env.Duration("RPC_REPLAY_WINDOW", 0) // 0 disables replay protection
+	tokenWindow   = env.Duration("SUBMIT_CLIENT_TOKEN_WINDOW", core.DefaultClientTokenWindow)
+	approvals     = env.Bool("ISSUANCE_APPROVALS", false)
+	buildQuotas   = env.Bool("BUILD_QUOTAS", false)
+	quotaTimeZone = env.String("BUILD_QUOTA_TIME_ZONE", "UTC")  // defines the business day
+	dupWindow     = env.Duration("DUPLICATE_PAYMENT_WINDOW", 0) // 0 disables duplicate detection
+	dupWebhook    = env.String("DUPLICATE_PAYMENT_WEBHOOK_URL", "")
+	resWebhook    = env.String("RESERVATION_CONFLICT_WEBHOOK_URL", "")
+	ratesURL      = env.String("RATES_URL", "")
+	spentBy       = env.Bool("ANNOTATE_SPENT_BY", false)
+	defSchemaFile = env.String("ASSET_DEFINITION_SCHEMA", "") // path to a JSON Schema file
+	maxBlockCost  = env.Int("MAX_BLOCK_COST", 0)              // total VM run cost; 0 disables
+	rcvrExpiry    = env.Duration("RECEIVER_EXPIRY", account.DefaultReceiverExpiry)
+	queryReplica  = env.Bool("QUERY_REPLICA", false)
+	maxStaleness  = env.Duration("REPLICA_MAX_STALENESS", 0) // 0 disables the limit
 
 	// build vars; initialized by the linker
 	buildTag    = "dev"
 	buildCommit = "?"
 	buildDate   = "?"
+	buildFlags  = ""
+	vendorHash  = "?"
 
 	race          []interface{} // initialized in race.go
 	httpsRedirect = true        // initialized in insecure.go
 
 	blockPeriod              = time.Second
 	expireReservationsPeriod = time.Second
+	expireReceiversPeriod    = time.Hour
+
+	// fraction of generator RPCs that may be hedged
+	hedgeBudget = 0.1
 )
 
 func init() {
@@ -98,6 +137,8 @@ func init() {
 	expvar.NewString("buildtag").Set(buildTag)
 	expvar.NewString("builddate").Set(buildDate)
 	expvar.NewString("buildcommit").Set(buildCommit)
+	expvar.NewString("buildflags").Set(buildFlags)
+	expvar.NewString("vendorhash").Set(vendorHash)
 	expvar.NewString("runtime.GOOS").Set(runtime.GOOS)
 	expvar.NewString("runtime.GOARCH").Set(runtime.GOARCH)
 	expvar.NewString("runtime.Version").Set(runtime.Version())
@@ -105,6 +146,8 @@ func init() {
 	config.Version = version
 	config.BuildCommit = buildCommit
 	config.BuildDate = buildDate
+	config.BuildFlags = buildFlags
+	config.VendorHash = vendorHash
 }
 
 func main() {
@@ -114,6 +157,7 @@ func main() {
 	env.Parse()
 
 	sql.EnableQueryLogging(*logQueries)
+	fence := setUpFailover(ctx)
 	db, err := sql.Open("hapg", *dbURL)
 	if err != nil {
 		chainlog.Fatal(ctx, chainlog.KeyError, err)
@@ -148,9 +192,18 @@ func main() {
 	chainlog.SetPrefix(append([]interface{}{"app", "cored", "buildtag", buildTag, "processID", processID}, race...)...)
 	chainlog.SetOutput(logWriter())
 
+	config.BinaryHash, err = binaryHash()
+	if err != nil {
+		chainlog.Error(ctx, err, "hashing cored binary")
+	}
+
+	// Record API usage by access token, whether or not
+	// this core is configured.
+	go core.FlushTokenUsage(ctx, db)
+
 	var h http.Handler
 	if conf != nil {
-		h = launchConfiguredCore(ctx, db, conf, processID)
+		h = launchConfiguredCore(ctx, db, conf, processID, fence)
 	} else {
 		chainlog.Messagef(ctx, "Launching as unconfigured Core.")
 		h = &core.Handler{
@@ -202,7 +255,7 @@ func main() {
 	}
 }
 
-func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config, processID string) http.Handler {
+func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config, processID string, fence leader.Fence) http.Handler {
 	// Initialize the protocol.Chain.
 	heights, err := txdb.ListenBlocks(ctx, *dbURL)
 	if err != nil {
@@ -238,6 +291,7 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 			generatorSigners = append(generatorSigners, signer)
 		}
 		c.MaxIssuanceWindow = conf.MaxIssuanceWindow.Duration
+		c.MaxBlockCost = int64(*maxBlockCost)
 	}
 
 	var submitter txbuilder.Submitter
@@ -252,7 +306,17 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 			BuildTag:     buildTag,
 			BlockchainID: conf.BlockchainID.String(),
 		}
-		submitter = &txbuilder.RemoteGenerator{Peer: remoteGenerator}
+		if *hedgeDelay > 0 {
+			remoteGenerator.Hedge = &rpc.HedgePolicy{
+				Delay:  *hedgeDelay,
+				Budget: hedgeBudget,
+			}
+		}
+		submitter = &txbuilder.RemoteGenerator{
+			Peer:        remoteGenerator,
+			BatchWindow: *submitWindow,
+			MaxBatch:    *submitBatch,
+		}
 	} else {
 		gen = generator.New(c, generatorSigners, db)
 		submitter = gen
@@ -270,36 +334,97 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 
 	// Setup the transaction query indexer to index every transaction.
 	indexer := query.NewIndexer(db, c, pinStore)
+	if *ratesURL != "" {
+		indexer.Rates = &query.HTTPRates{URL: *ratesURL}
+	}
+	indexer.AnnotateSpentBy = *spentBy
 
 	assets := asset.NewRegistry(db, c, pinStore)
+	if *defSchemaFile != "" {
+		b, err := ioutil.ReadFile(*defSchemaFile)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, errors.Wrap(err, "reading asset definition schema"))
+		}
+		schema, err := asset.ParseSchema(b)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
+		assets.RequireDefinitionSchema(schema)
+	}
 	accounts := account.NewManager(db, c, pinStore)
+	if *resWebhook != "" {
+		accounts.NotifyConflicts(*resWebhook)
+	}
+	accounts.SetReceiverExpiry(*rcvrExpiry)
+	counterparties := &counterparty.Registry{DB: db}
+	templates := &progtemplate.Registry{DB: db}
 	if *indexTxs {
 		go pinStore.Listen(ctx, query.TxPinName, *dbURL)
+		go pinStore.Listen(ctx, balancehook.PinName, *dbURL)
+		go pinStore.Listen(ctx, feedsink.PinName, *dbURL)
 		indexer.RegisterAnnotator(assets.AnnotateTxs)
 		indexer.RegisterAnnotator(accounts.AnnotateTxs)
+		indexer.RegisterAnnotator(counterparties.AnnotateTxs)
+		indexer.RegisterAnnotator(templates.AnnotateTxs)
 		assets.IndexAssets(indexer)
 		accounts.IndexAccounts(indexer)
 	}
 
 	// GC old submitted txs periodically.
 	go core.CleanupSubmittedTxs(ctx, db)
+	go core.CleanupClientTokens(ctx, db, *tokenWindow)
 
 	h := &core.Handler{
-		Chain:        c,
-		Store:        store,
-		PinStore:     pinStore,
-		Assets:       assets,
-		Accounts:     accounts,
-		HSM:          hsm,
-		Submitter:    submitter,
-		TxFeeds:      &txfeed.Tracker{DB: db},
-		Indexer:      indexer,
-		AccessTokens: &accesstoken.CredentialStore{DB: db},
-		Config:       conf,
-		DB:           db,
-		Addr:         *listenAddr,
-		Signer:       signBlockHandler,
-		AltAuth:      authLoopbackInDev,
+		Chain:          c,
+		Store:          store,
+		PinStore:       pinStore,
+		Assets:         assets,
+		Accounts:       accounts,
+		HSM:            hsm,
+		Submitter:      submitter,
+		TxFeeds:        &txfeed.Tracker{DB: db},
+		Indexer:        indexer,
+		AccessTokens:   &accesstoken.CredentialStore{DB: db},
+		Counterparties: counterparties,
+		Templates:      templates,
+		Swaps:          &swap.Book{DB: db, Chain: c, PinStore: pinStore},
+		Messages:       &msgrelay.Relay{DB: db},
+		Config:         conf,
+		DB:             db,
+		Addr:           *listenAddr,
+		Signer:         signBlockHandler,
+		AltAuth:        authLoopbackInDev,
+
+		RPCReplayWindow:   *replayWindow,
+		ClientTokenWindow: *tokenWindow,
+		Replica:           *queryReplica,
+		MaxStaleness:      *maxStaleness,
+	}
+	if *indexTxs && *dupWindow > 0 {
+		h.Duplicates = &dupdetect.Detector{
+			DB:         db,
+			Chain:      c,
+			PinStore:   pinStore,
+			Window:     *dupWindow,
+			WebhookURL: *dupWebhook,
+		}
+	}
+	if *indexTxs {
+		h.Watches = &watch.Registry{DB: db, Chain: c, PinStore: pinStore}
+		h.BalanceHooks = &balancehook.Notifier{DB: db, Chain: c, PinStore: pinStore}
+		// The SQS client relies on AWS_REGION, AWS_ACCESS_KEY_ID
+		// and AWS_SECRET_ACCESS_KEY being set.
+		h.FeedSinks = &feedsink.Pusher{DB: db, Chain: c, PinStore: pinStore, SQS: sqs.New(aws.DefaultConfig)}
+	}
+	if *approvals {
+		h.Approvals = &approval.Manager{DB: db}
+	}
+	if *buildQuotas {
+		loc, err := time.LoadLocation(*quotaTimeZone)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, errors.Wrap(err, "loading build quota time zone"))
+		}
+		h.Quotas = &quota.Manager{DB: db, Location: loc}
 	}
 	if *rpsToken > 0 {
 		h.RequestLimits = append(h.RequestLimits, core.RequestLimit{
@@ -339,13 +464,59 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		if err != nil {
 			chainlog.Fatal(ctx, chainlog.KeyError, err)
 		}
+		err = pinStore.CreatePin(ctx, swap.PinName, height)
+		if err != nil {
+			chainlog.Fatal(ctx, chainlog.KeyError, err)
+		}
+		if h.Duplicates != nil {
+			err = pinStore.CreatePin(ctx, dupdetect.PinName, height)
+			if err != nil {
+				chainlog.Fatal(ctx, chainlog.KeyError, err)
+			}
+		}
+		if h.Watches != nil {
+			err = pinStore.CreatePin(ctx, watch.PinName, height)
+			if err != nil {
+				chainlog.Fatal(ctx, chainlog.KeyError, err)
+			}
+		}
+		if h.BalanceHooks != nil {
+			err = pinStore.CreatePin(ctx, balancehook.PinName, height)
+			if err != nil {
+				chainlog.Fatal(ctx, chainlog.KeyError, err)
+			}
+		}
+		if h.FeedSinks != nil {
+			err = pinStore.CreatePin(ctx, feedsink.PinName, height)
+			if err != nil {
+				chainlog.Fatal(ctx, chainlog.KeyError, err)
+			}
+		}
 	}()
 
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(rpc.HeaderBlockchainID, conf.BlockchainID.String())
+		h.ServeHTTP(w, req)
+	})
+
+	// Query replicas serve reads from the database the leader
+	// maintains, so they never run for leader.
+	if *queryReplica {
+		chainlog.Messagef(ctx, "Launching as query replica.")
+		return handler
+	}
+
 	// Note, it's important for any services that will install blockchain
-	// callbacks to be initialized before leader.Run() and the http server,
+	// callbacks to be initialized before leader.RunFenced() and the http server,
 	// otherwise there's a data race within protocol.Chain.
-	go leader.Run(db, *listenAddr, func(ctx context.Context) {
+	go leader.RunFenced(db, *listenAddr, fence, func(ctx context.Context) {
+		// Other processes may have modified assets and accounts
+		// while we were following.
+		h.Assets.ClearCache()
+		h.Accounts.ClearCache()
 		go h.Accounts.ExpireReservations(ctx, expireReservationsPeriod)
+		go h.Accounts.ExpireReceivers(ctx, expireReceiversPeriod)
+		go h.ResumeSubmissions(ctx)
 		if conf.IsGenerator {
 			go gen.Generate(ctx, blockPeriod, genhealth)
 		} else {
@@ -353,15 +524,25 @@ func launchConfiguredCore(ctx context.Context, db *sql.DB, conf *config.Config,
 		}
 		go h.Accounts.ProcessBlocks(ctx)
 		go h.Assets.ProcessBlocks(ctx)
+		go h.Swaps.ProcessBlocks(ctx)
 		if *indexTxs {
 			go h.Indexer.ProcessBlocks(ctx)
 		}
+		if h.Duplicates != nil {
+			go h.Duplicates.ProcessBlocks(ctx)
+		}
+		if h.Watches != nil {
+			go h.Watches.ProcessBlocks(ctx)
+		}
+		if h.BalanceHooks != nil {
+			go h.BalanceHooks.ProcessBlocks(ctx)
+		}
+		if h.FeedSinks != nil {
+			go h.FeedSinks.ProcessBlocks(ctx)
+		}
 	})
 
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set(rpc.HeaderBlockchainID, conf.BlockchainID.String())
-		h.ServeHTTP(w, req)
-	})
+	return handler
 }
 
 // remoteSigner defines the address and public key of another Core
@@ -398,7 +579,7 @@ func (s *remoteSigner) SignBlock(ctx context.Context, b *bc.Block) (signature []
 	// times in multiple calls to different remoteSigners.
 	// Maybe optimize that if it makes a difference.
 	err = s.Client.Call(ctx, "/rpc/signer/sign-block", b, &signature)
-	return
+	return signature, core.SignBlockError(err)
 }
 
 func (s *remoteSigner) String() string {
@@ -421,6 +602,29 @@ func logWriter() io.Writer {
 	return os.Stdout
 }
 
+// binaryHash returns the hex-encoded SHA3-256
+// hash of the running executable.
+func binaryHash() (string, error) {
+	path, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	defer f.Close()
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	var sum [32]byte
+	h.Read(sum[:])
+	return hex.EncodeToString(sum[:]), nil
+}
+
 type errlog struct {
 	w io.Writer
 	t time.Time // protected by chain/log mutex
@@ -0,0 +1,83 @@
+/*
+
+Command errcatalog writes the catalog of Chain Core API
+error codes, as returned by the /errors endpoint, to dir
+as Go source (errcodes.go) and as JSON (errcodes.json).
+
+Usage:
+
+	errcatalog dir
+
+SDKs and other clients can consult the catalog to decide
+which errors are safe to retry.
+
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"chain/core"
+)
+
+func main() {
+	log.SetPrefix("errcatalog: ")
+	log.SetFlags(0)
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: errcatalog dir")
+		os.Exit(1)
+	}
+	dir := os.Args[1]
+	codes := core.ErrorCatalog()
+
+	js, err := json.MarshalIndent(codes, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	js = append(js, '\n')
+	err = ioutil.WriteFile(filepath.Join(dir, "errcodes.json"), js, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "// Code generated by cmd/errcatalog; DO NOT EDIT.")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Package errcodes lists the error codes of the Chain Core API.")
+	fmt.Fprintln(&buf, "package errcodes")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// A Code describes one API error code.")
+	fmt.Fprintln(&buf, "type Code struct {")
+	fmt.Fprintln(&buf, "\tCode       string")
+	fmt.Fprintln(&buf, "\tHTTPStatus int")
+	fmt.Fprintln(&buf, "\tMessage    string")
+	fmt.Fprintln(&buf, "\tRetriable  bool")
+	fmt.Fprintln(&buf, "\tDataFields []string")
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintln(&buf)
+	fmt.Fprintln(&buf, "// Codes maps each error code to its description.")
+	fmt.Fprintln(&buf, "var Codes = map[string]Code{")
+	for _, c := range codes {
+		fields := "nil"
+		if len(c.DataFields) > 0 {
+			fields = fmt.Sprintf("%#v", c.DataFields)
+		}
+		fmt.Fprintf(&buf, "%q: {%q, %d, %q, %t, %s},\n", c.Code, c.Code, c.HTTPStatus, c.Message, c.Retriable, fields)
+	}
+	fmt.Fprintln(&buf, "}")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "errcodes.go"), src, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
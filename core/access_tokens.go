@@ -2,16 +2,77 @@ package core
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
+	"time"
 
 	"chain/core/accesstoken"
+	chainjson "chain/encoding/json"
 	"chain/net/http/httpjson"
 )
 
-var errCurrentToken = errors.New("token cannot delete itself")
+var (
+	errCurrentToken = errors.New("token cannot delete itself")
+	errNotAdmin     = errors.New("admin access token required")
 
-func (h *Handler) createAccessToken(ctx context.Context, x struct{ ID, Type string }) (*accesstoken.Token, error) {
-	return h.AccessTokens.Create(ctx, x.ID, x.Type)
+	// errNamespacedToken keeps a token bound to a namespace
+	// from making tokens outside it or removing others.
+	errNamespacedToken = errors.New("namespaced token cannot manage access tokens")
+)
+
+// defaultRotationGrace is how long the old secret of a rotated
+// access token stays valid if the request doesn't say.
+const defaultRotationGrace = 24 * time.Hour
+
+func (h *Handler) createAccessToken(ctx context.Context, x struct {
+	ID        string
+	Type      string
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// Namespace, if set, confines a client token
+	// to the asset aliases in that namespace.
+	Namespace string `json:"namespace"`
+}) (*accesstoken.Token, error) {
+	if aliasNamespace(ctx) != "" {
+		return nil, errNamespacedToken
+	}
+	// Only admin tokens can make more of themselves.
+	if x.Type == "admin" {
+		err := h.checkAdmin(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h.AccessTokens.Create(ctx, x.ID, x.Type, x.Namespace, x.ExpiresAt)
+}
+
+// rotateAccessToken issues a new secret for an access token.
+// The old secret stays valid for grace_period, which
+// defaults to defaultRotationGrace. A token can rotate
+// itself; rotating any other token takes an admin token.
+//
+// POST /rotate-access-token
+func (h *Handler) rotateAccessToken(ctx context.Context, x struct {
+	ID          string
+	GracePeriod *chainjson.Duration `json:"grace_period"`
+}) (*accesstoken.Token, error) {
+	currentID, _, _ := httpjson.Request(ctx).BasicAuth()
+	if currentID != x.ID {
+		err := h.checkAdmin(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	grace := defaultRotationGrace
+	if x.GracePeriod != nil {
+		grace = x.GracePeriod.Duration
+	}
+	tok, err := h.AccessTokens.Rotate(ctx, x.ID, grace)
+	if err != nil {
+		return nil, err
+	}
+	h.forgetToken(x.ID)
+	return tok, nil
 }
 
 func (h *Handler) listAccessTokens(ctx context.Context, x requestQuery) (*page, error) {
@@ -20,6 +81,13 @@ func (h *Handler) listAccessTokens(ctx context.Context, x requestQuery) (*page,
 		limit = defGenericPageSize
 	}
 
+	// Save recent requests first, so
+	// the last use times are current.
+	err := flushUsage(ctx, h.DB)
+	if err != nil {
+		return nil, err
+	}
+
 	tokens, next, err := h.AccessTokens.List(ctx, x.Type, x.After, limit)
 	if err != nil {
 		return nil, err
@@ -40,5 +108,58 @@ func (h *Handler) deleteAccessToken(ctx context.Context, x struct{ ID string })
 	if currentID == x.ID {
 		return errCurrentToken
 	}
-	return h.AccessTokens.Delete(ctx, x.ID)
+	if aliasNamespace(ctx) != "" {
+		return errNamespacedToken
+	}
+	typ, err := h.AccessTokens.Type(ctx, x.ID)
+	if err != nil {
+		return err
+	}
+	if typ == "admin" {
+		err = h.checkAdmin(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	err = h.AccessTokens.Delete(ctx, x.ID)
+	if err != nil {
+		return err
+	}
+	h.forgetToken(x.ID)
+	return nil
+}
+
+// forgetToken drops the cached checks of the access
+// token with the given ID, if h is serving requests.
+// Other processes keep theirs until they expire.
+func (h *Handler) forgetToken(id string) {
+	if h.authn != nil {
+		h.authn.forget(id)
+	}
+}
+
+// checkAdmin returns errNotAdmin unless the request was
+// authenticated with an admin access token. Requests admitted
+// without credentials by AltAuth, as in development, pass.
+func (h *Handler) checkAdmin(ctx context.Context) error {
+	req := httpjson.Request(ctx)
+	id, secret, ok := req.BasicAuth()
+	if !ok {
+		if h.AltAuth != nil && h.AltAuth(req) {
+			return nil
+		}
+		return errNotAdmin
+	}
+	secretBytes, err := hex.DecodeString(secret)
+	if err != nil {
+		return errNotAdmin
+	}
+	admin, err := h.AccessTokens.Check(ctx, id, "admin", secretBytes)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return errNotAdmin
+	}
+	return nil
 }
@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"chain/core/accesstoken"
+	"chain/database/pg/pgtest"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// tokenContext returns a request context
+// authenticated with the given token.
+func tokenContext(t *testing.T, tok *accesstoken.Token) context.Context {
+	req, err := http.NewRequest("POST", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(tok.Token, ":", 2)
+	req.SetBasicAuth(parts[0], parts[1])
+	return httpjson.WithRequest(context.Background(), req)
+}
+
+func TestAdminTokenManagement(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{AccessTokens: &accesstoken.CredentialStore{DB: pgtest.NewTx(t)}}
+
+	admin, err := h.AccessTokens.Create(ctx, "admin", "admin", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := h.AccessTokens.Create(ctx, "client", "client", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminCtx, clientCtx := tokenContext(t, admin), tokenContext(t, client)
+
+	type tokenReq struct {
+		ID        string
+		Type      string
+		ExpiresAt time.Time `json:"expires_at"`
+		Namespace string    `json:"namespace"`
+	}
+	_, err = h.createAccessToken(clientCtx, tokenReq{ID: "admin2", Type: "admin"})
+	if errors.Root(err) != errNotAdmin {
+		t.Errorf("client creating admin token: error = %v want %v", err, errNotAdmin)
+	}
+	_, err = h.createAccessToken(clientCtx, tokenReq{ID: "client2", Type: "client"})
+	if err != nil {
+		t.Errorf("client creating client token: %v", err)
+	}
+	_, err = h.createAccessToken(adminCtx, tokenReq{ID: "admin2", Type: "admin"})
+	if err != nil {
+		t.Fatalf("admin creating admin token: %v", err)
+	}
+
+	err = h.deleteAccessToken(clientCtx, struct{ ID string }{"admin2"})
+	if errors.Root(err) != errNotAdmin {
+		t.Errorf("client deleting admin token: error = %v want %v", err, errNotAdmin)
+	}
+	err = h.deleteAccessToken(adminCtx, struct{ ID string }{"admin2"})
+	if err != nil {
+		t.Errorf("admin deleting admin token: %v", err)
+	}
+}
+
+func TestRotateAccessToken(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{AccessTokens: &accesstoken.CredentialStore{DB: pgtest.NewTx(t)}}
+
+	admin, err := h.AccessTokens.Create(ctx, "admin", "admin", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := h.AccessTokens.Create(ctx, "client", "client", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminCtx, clientCtx := tokenContext(t, admin), tokenContext(t, client)
+
+	type rotateReq struct {
+		ID          string
+		GracePeriod *chainjson.Duration `json:"grace_period"`
+	}
+	_, err = h.rotateAccessToken(clientCtx, rotateReq{ID: "admin"})
+	if errors.Root(err) != errNotAdmin {
+		t.Errorf("client rotating admin token: error = %v want %v", err, errNotAdmin)
+	}
+	_, err = h.rotateAccessToken(clientCtx, rotateReq{ID: "client"})
+	if err != nil {
+		t.Errorf("client rotating itself: %v", err)
+	}
+	_, err = h.rotateAccessToken(adminCtx, rotateReq{ID: "client"})
+	if err != nil {
+		t.Errorf("admin rotating client token: %v", err)
+	}
+}
+
+func TestDeletedTokenUncached(t *testing.T) {
+	ctx := context.Background()
+	tokens := &accesstoken.CredentialStore{DB: pgtest.NewTx(t)}
+	authn := &apiAuthn{tokens: tokens, tokenMap: make(map[string]tokenResult)}
+	h := &Handler{AccessTokens: tokens, authn: authn}
+
+	tok, err := tokens.Create(ctx, "x", "client", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(tok.Token, ":", 2)
+	_, err = authn.cachedAuthCheck(ctx, "client", parts[0], parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = h.deleteAccessToken(tokenContext(t, &accesstoken.Token{Token: "other:00"}), struct{ ID string }{"x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = authn.cachedAuthCheck(ctx, "client", parts[0], parts[1])
+	if err != errNotAuthenticated {
+		t.Errorf("deleted token check error = %v want %v", err, errNotAuthenticated)
+	}
+}
+
+func TestNamespacedTokenManagement(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{AccessTokens: &accesstoken.CredentialStore{DB: pgtest.NewTx(t)}}
+
+	scoped, err := h.AccessTokens.Create(ctx, "scoped", "client", "treasury", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = h.AccessTokens.Create(ctx, "other", "client", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scopedCtx := context.WithValue(tokenContext(t, scoped), namespaceKey{}, "treasury")
+
+	type tokenReq struct {
+		ID        string
+		Type      string
+		ExpiresAt time.Time `json:"expires_at"`
+		Namespace string    `json:"namespace"`
+	}
+	_, err = h.createAccessToken(scopedCtx, tokenReq{ID: "unscoped", Type: "client"})
+	if errors.Root(err) != errNamespacedToken {
+		t.Errorf("namespaced token creating token: error = %v want %v", err, errNamespacedToken)
+	}
+	err = h.deleteAccessToken(scopedCtx, struct{ ID string }{"other"})
+	if errors.Root(err) != errNamespacedToken {
+		t.Errorf("namespaced token deleting token: error = %v want %v", err, errNamespacedToken)
+	}
+}
@@ -5,6 +5,7 @@ package accesstoken
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"fmt"
 	"regexp"
 	"time"
@@ -22,7 +23,14 @@ var (
 	// ErrDuplicateID is returned when Create is called on an existing ID.
 	ErrDuplicateID = errors.New("duplicate access token ID")
 	// ErrBadType is returned when Create is called with a bad type.
-	ErrBadType = errors.New("type must be client or network")
+	ErrBadType = errors.New("type must be client, network, explorer or admin")
+	// ErrBadExpiry is returned when Create is called with an
+	// expiration time in the past, or Rotate with a negative
+	// grace period.
+	ErrBadExpiry = errors.New("invalid access token expiration")
+	// ErrBadNamespace is returned when Create is called with an
+	// invalid namespace, or a namespace for a non-client token.
+	ErrBadNamespace = errors.New("invalid access token namespace")
 
 	defaultLimit = 100
 
@@ -36,7 +44,25 @@ type Token struct {
 	Token   string    `json:"token,omitempty"`
 	Type    string    `json:"type"`
 	Created time.Time `json:"created_at"`
-	sortID  string
+
+	// Namespace, if set, confines a client token to the asset
+	// aliases in that namespace. See asset.QualifyAlias.
+	Namespace string `json:"namespace,omitempty"`
+
+	// ExpiresAt is when the token stops being valid.
+	// Tokens without it don't expire.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// LastUsedAt is when the token last authenticated a request.
+	// It's updated once a minute, and is unset if the token
+	// hasn't been used.
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+
+	// PreviousSecretExpiresAt is when the secret replaced
+	// by the latest rotation stops being valid.
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+
+	sortID string
 }
 
 type CredentialStore struct {
@@ -44,33 +70,50 @@ type CredentialStore struct {
 }
 
 // Create generates a new access token with the given ID.
-func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token, error) {
+// If expiresAt is not the zero time, the token is valid
+// only until then. A client token may be bound to an
+// alias namespace.
+func (cs *CredentialStore) Create(ctx context.Context, id, typ, namespace string, expiresAt time.Time) (*Token, error) {
 	if !validIDRegexp.MatchString(id) {
 		return nil, errors.WithDetailf(ErrBadID, "invalid id %q", id)
 	}
 
-	if typ != "client" && typ != "network" {
+	if typ != "client" && typ != "network" && typ != "explorer" && typ != "admin" {
 		return nil, errors.WithDetailf(ErrBadType, "unknown type %q", typ)
 	}
 
-	var secret [tokenSize]byte
-	_, err := rand.Read(secret[:])
+	if namespace != "" {
+		if typ != "client" {
+			return nil, errors.WithDetail(ErrBadNamespace, "only client tokens can have a namespace")
+		}
+		if !validIDRegexp.MatchString(namespace) {
+			return nil, errors.WithDetailf(ErrBadNamespace, "invalid namespace %q", namespace)
+		}
+	}
+
+	var expiry *time.Time
+	if !expiresAt.IsZero() {
+		if !expiresAt.After(time.Now()) {
+			return nil, errors.WithDetail(ErrBadExpiry, "expiration time must be in the future")
+		}
+		expiry = &expiresAt
+	}
+
+	secret, hashedSecret, err := newSecret()
 	if err != nil {
 		return nil, err
 	}
-	var hashedSecret [32]byte
-	sha3pool.Sum256(hashedSecret[:], secret[:])
 
 	const q = `
-		INSERT INTO access_tokens (id, type, hashed_secret)
-		VALUES($1, $2, $3)
+		INSERT INTO access_tokens (id, type, hashed_secret, expires_at, namespace)
+		VALUES($1, $2, $3, $4, NULLIF($5, ''))
 		RETURNING created, sort_id
 	`
 	var (
 		created time.Time
 		sortID  string
 	)
-	err = cs.DB.QueryRow(ctx, q, id, typ, hashedSecret[:]).Scan(&created, &sortID)
+	err = cs.DB.QueryRow(ctx, q, id, typ, hashedSecret[:], expiry, namespace).Scan(&created, &sortID)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetailf(ErrDuplicateID, "id %q already in use", id)
 	}
@@ -79,16 +122,82 @@ func (cs *CredentialStore) Create(ctx context.Context, id, typ string) (*Token,
 	}
 
 	return &Token{
-		ID:      id,
-		Token:   fmt.Sprintf("%s:%x", id, secret),
-		Type:    typ,
-		Created: created,
-		sortID:  sortID,
+		ID:        id,
+		Token:     fmt.Sprintf("%s:%x", id, secret),
+		Type:      typ,
+		Created:   created,
+		Namespace: namespace,
+		ExpiresAt: expiry,
+		sortID:    sortID,
 	}, nil
 }
 
+// Rotate replaces the secret of the access token with the given
+// ID and returns the token with its new secret. The old secret
+// stays valid for the grace period, so clients can switch to the
+// new one without interruption. The token's ID, type and
+// expiration are unchanged.
+func (cs *CredentialStore) Rotate(ctx context.Context, id string, grace time.Duration) (*Token, error) {
+	if grace < 0 {
+		return nil, errors.WithDetail(ErrBadExpiry, "grace period must not be negative")
+	}
+
+	secret, hashedSecret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		UPDATE access_tokens SET
+			previous_hashed_secret = hashed_secret,
+			previous_expires_at = $3,
+			hashed_secret = $2
+		WHERE id=$1
+		RETURNING type, created, COALESCE(namespace, ''), sort_id, expires_at, last_used_at, previous_expires_at
+	`
+	tok := &Token{ID: id}
+	err = cs.DB.QueryRow(ctx, q, id, hashedSecret[:], time.Now().Add(grace)).Scan(
+		&tok.Type,
+		&tok.Created,
+		&tok.Namespace,
+		&tok.sortID,
+		&tok.ExpiresAt,
+		&tok.LastUsedAt,
+		&tok.PreviousSecretExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "access token id %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	tok.Token = fmt.Sprintf("%s:%x", id, secret)
+	return tok, nil
+}
+
+func newSecret() (secret [tokenSize]byte, hashed [32]byte, err error) {
+	_, err = rand.Read(secret[:])
+	if err != nil {
+		return secret, hashed, errors.Wrap(err, "generating access token secret")
+	}
+	sha3pool.Sum256(hashed[:], secret[:])
+	return secret, hashed, nil
+}
+
 // Check returns whether or not an id-secret pair is a valid access token.
+// Expired tokens are not valid. A secret replaced by Rotate is valid
+// until its grace period ends.
 func (cs *CredentialStore) Check(ctx context.Context, id, typ string, secret []byte) (bool, error) {
+	valid, _, err := cs.CheckUntil(ctx, id, typ, secret)
+	return valid, err
+}
+
+// CheckUntil is like Check, but also returns when a valid
+// id-secret pair stops being valid, because the token expires
+// or the secret's grace period ends. It returns the zero time
+// for a pair that stays valid until the token is rotated or
+// deleted.
+func (cs *CredentialStore) CheckUntil(ctx context.Context, id, typ string, secret []byte) (bool, time.Time, error) {
 	var (
 		toHash [tokenSize]byte
 		hashed [32]byte
@@ -96,14 +205,49 @@ func (cs *CredentialStore) Check(ctx context.Context, id, typ string, secret []b
 	copy(toHash[:], secret)
 	sha3pool.Sum256(hashed[:], toHash[:])
 
-	const q = `SELECT EXISTS(SELECT 1 FROM access_tokens WHERE id=$1 AND type=$2 AND hashed_secret=$3)`
-	var valid bool
-	err := cs.DB.QueryRow(ctx, q, id, typ, hashed[:]).Scan(&valid)
+	const q = `
+		SELECT LEAST(expires_at, CASE WHEN hashed_secret=$3 THEN NULL ELSE previous_expires_at END)
+		FROM access_tokens
+		WHERE id=$1 AND type=$2
+		AND (expires_at IS NULL OR expires_at > now())
+		AND (hashed_secret=$3 OR (previous_hashed_secret=$3 AND previous_expires_at > now()))
+	`
+	var until *time.Time
+	err := cs.DB.QueryRow(ctx, q, id, typ, hashed[:]).Scan(&until)
+	if err == sql.ErrNoRows {
+		return false, time.Time{}, nil
+	}
 	if err != nil {
-		return false, err
+		return false, time.Time{}, err
 	}
+	if until == nil {
+		return true, time.Time{}, nil
+	}
+	return true, *until, nil
+}
 
-	return valid, nil
+// Namespace returns the alias namespace of the access
+// token with the given ID, or "" if it has none.
+func (cs *CredentialStore) Namespace(ctx context.Context, id string) (string, error) {
+	const q = `SELECT COALESCE(namespace, '') FROM access_tokens WHERE id=$1`
+	var namespace string
+	err := cs.DB.QueryRow(ctx, q, id).Scan(&namespace)
+	if err == sql.ErrNoRows {
+		return "", errors.WithDetailf(pg.ErrUserInputNotFound, "access token id %s", id)
+	}
+	return namespace, errors.Wrap(err)
+}
+
+// Type returns the type of the access
+// token with the given ID.
+func (cs *CredentialStore) Type(ctx context.Context, id string) (string, error) {
+	const q = `SELECT type FROM access_tokens WHERE id=$1`
+	var typ string
+	err := cs.DB.QueryRow(ctx, q, id).Scan(&typ)
+	if err == sql.ErrNoRows {
+		return "", errors.WithDetailf(pg.ErrUserInputNotFound, "access token id %s", id)
+	}
+	return typ, errors.Wrap(err)
 }
 
 // List lists all access tokens.
@@ -112,18 +256,23 @@ func (cs *CredentialStore) List(ctx context.Context, typ, after string, limit in
 		limit = defaultLimit
 	}
 	const q = `
-		SELECT id, type, sort_id, created FROM access_tokens
+		SELECT id, type, COALESCE(namespace, ''), sort_id, created, expires_at, last_used_at, previous_expires_at
+		FROM access_tokens
 		WHERE ($1='' OR type=$1::access_token_type) AND ($2='' OR sort_id<$2)
 		ORDER BY sort_id DESC
 		LIMIT $3
 	`
 	var tokens []*Token
-	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id, typ, sortID string, created time.Time) {
+	err := pg.ForQueryRows(ctx, cs.DB, q, typ, after, limit, func(id, typ, namespace, sortID string, created time.Time, expiresAt, lastUsedAt, previousExpiresAt *time.Time) {
 		tokens = append(tokens, &Token{
-			ID:      id,
-			Type:    typ,
-			Created: created,
-			sortID:  sortID,
+			ID:                      id,
+			Type:                    typ,
+			Created:                 created,
+			Namespace:               namespace,
+			ExpiresAt:               expiresAt,
+			LastUsedAt:              lastUsedAt,
+			PreviousSecretExpiresAt: previousExpiresAt,
+			sortID:                  sortID,
 		})
 	})
 	if err != nil {
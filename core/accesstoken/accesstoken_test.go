@@ -6,9 +6,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
 	"chain/errors"
 )
@@ -23,6 +25,8 @@ func TestCreate(t *testing.T) {
 	}{
 		{"a", "client", nil},
 		{"b", "network", nil},
+		{"d", "explorer", nil},
+		{"e", "admin", nil},
 		{"", "client", ErrBadID},
 		{"bad:id", "client", ErrBadID},
 		{"c", "badtype", ErrBadType},
@@ -30,13 +34,41 @@ func TestCreate(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		_, err := cs.Create(ctx, c.id, c.net)
+		_, err := cs.Create(ctx, c.id, c.net, "", time.Time{})
 		if errors.Root(err) != c.want {
 			t.Errorf("Create(%s, %s) error = %s want %s", c.id, c.net, err, c.want)
 		}
 	}
 }
 
+func TestCreateNamespace(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	cases := []struct {
+		id, typ, namespace string
+		want               error
+	}{
+		{"a", "client", "treasury", nil},
+		{"b", "network", "treasury", ErrBadNamespace},
+		{"c", "client", "bad/namespace", ErrBadNamespace},
+	}
+	for _, c := range cases {
+		_, err := cs.Create(ctx, c.id, c.typ, c.namespace, time.Time{})
+		if errors.Root(err) != c.want {
+			t.Errorf("Create(%s, %s, %s) error = %s want %s", c.id, c.typ, c.namespace, err, c.want)
+		}
+	}
+
+	got, err := cs.Namespace(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "treasury" {
+		t.Errorf("Namespace(a) = %q want %q", got, "treasury")
+	}
+}
+
 func TestList(t *testing.T) {
 	ctx := context.Background()
 	cs := &CredentialStore{DB: pgtest.NewTx(t)}
@@ -132,21 +164,144 @@ func TestCheck(t *testing.T) {
 	}
 }
 
+func TestCheckExpired(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	token, err := cs.Create(ctx, "x", "client", "", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := mustDecodeSecret(t, token)
+
+	valid, until, err := cs.CheckUntil(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Fatal("expected unexpired token to be valid")
+	}
+	// The database keeps microseconds.
+	if d := until.Sub(*token.ExpiresAt); d > time.Millisecond || d < -time.Millisecond {
+		t.Errorf("valid until %s want %s", until, *token.ExpiresAt)
+	}
+
+	_, err = cs.DB.Exec(ctx, `UPDATE access_tokens SET expires_at = now() - interval '1 second'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err = cs.Check(ctx, "x", "client", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected expired token to not be valid")
+	}
+
+	_, err = cs.Create(ctx, "y", "client", "", time.Now().Add(-time.Hour))
+	if errors.Root(err) != ErrBadExpiry {
+		t.Errorf("Create with past expiration error = %v want %v", err, ErrBadExpiry)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	ctx := context.Background()
+	cs := &CredentialStore{DB: pgtest.NewTx(t)}
+
+	old := mustCreateToken(t, ctx, cs, "x", "client")
+	oldSecret := mustDecodeSecret(t, old)
+
+	rotated, err := cs.Rotate(ctx, "x", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Token == old.Token {
+		t.Fatal("expected rotation to change the secret")
+	}
+	if rotated.PreviousSecretExpiresAt == nil {
+		t.Fatal("expected previous secret expiration to be set")
+	}
+	newSecret := mustDecodeSecret(t, rotated)
+
+	for _, secret := range [][]byte{oldSecret, newSecret} {
+		valid, err := cs.Check(ctx, "x", "client", secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid {
+			t.Fatal("expected old and new secrets to be valid during the grace period")
+		}
+	}
+	_, until, err := cs.CheckUntil(ctx, "x", "client", oldSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !until.Equal(*rotated.PreviousSecretExpiresAt) {
+		t.Errorf("old secret valid until %s want %s", until, *rotated.PreviousSecretExpiresAt)
+	}
+	_, until, err = cs.CheckUntil(ctx, "x", "client", newSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !until.IsZero() {
+		t.Errorf("new secret valid until %s want no limit", until)
+	}
+
+	_, err = cs.Rotate(ctx, "x", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := cs.Check(ctx, "x", "client", newSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected replaced secret to not be valid with no grace period")
+	}
+	valid, err = cs.Check(ctx, "x", "client", oldSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected secret from two rotations ago to not be valid")
+	}
+
+	_, err = cs.Rotate(ctx, "nonexistent", time.Hour)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Rotate(nonexistent) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	ctx := context.Background()
 	cs := &CredentialStore{DB: pgtest.NewTx(t)}
 
 	token := mustCreateToken(t, ctx, cs, "x", "client")
-	err := cs.Delete(ctx, token.ID)
+	typ, err := cs.Type(ctx, token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "client" {
+		t.Errorf("Type(x) = %q want client", typ)
+	}
+	err = cs.Delete(ctx, token.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
 func mustCreateToken(t *testing.T, ctx context.Context, cs *CredentialStore, id, typ string) *Token {
-	token, err := cs.Create(ctx, id, typ)
+	token, err := cs.Create(ctx, id, typ, "", time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	return token
 }
+
+func mustDecodeSecret(t *testing.T, token *Token) []byte {
+	secret, err := hex.DecodeString(strings.Split(token.Token, ":")[1])
+	if err != nil {
+		t.Fatal("bad token secret")
+	}
+	return secret
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	stdsql "database/sql"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
@@ -19,7 +20,9 @@ import (
 	"chain/database/sql"
 	"chain/errors"
 	"chain/log"
+	"chain/net/http/webhook"
 	"chain/protocol"
+	"chain/protocol/bc"
 	"chain/protocol/vmutil"
 )
 
@@ -35,6 +38,7 @@ func NewManager(db *sql.DB, chain *protocol.Chain, pinStore *pin.Store) *Manager
 		pinStore:    pinStore,
 		cache:       lru.New(maxAccountCache),
 		aliasCache:  lru.New(maxAccountCache),
+		xpubCache:   lru.New(maxAccountCache),
 		delayedACPs: make(map[*txbuilder.TemplateBuilder][]*controlProgram),
 	}
 }
@@ -50,6 +54,7 @@ type Manager struct {
 	cacheMu    sync.Mutex
 	cache      *lru.Cache
 	aliasCache *lru.Cache
+	xpubCache  *lru.Cache
 
 	delayedACPsMu sync.Mutex
 	delayedACPs   map[*txbuilder.TemplateBuilder][]*controlProgram
@@ -57,6 +62,11 @@ type Manager struct {
 	acpMu        sync.Mutex
 	acpIndexNext uint64 // next acp index in our block
 	acpIndexCap  uint64 // points to end of block
+
+	spendMu sync.Mutex // serializes RecordSpends
+
+	conflictWebhook *webhook.Sender
+	receiverExpiry  time.Duration
 }
 
 func (m *Manager) IndexAccounts(indexer Saver) {
@@ -81,19 +91,117 @@ func (m *Manager) ExpireReservations(ctx context.Context, period time.Duration)
 	}
 }
 
+// ExtendReservations keeps the outputs of this core's accounts
+// spent by tx reserved until exp, or until tx expires, if that's
+// sooner. It returns the number of outputs it extended and
+// the time their reservations now expire, or ErrNotReserved
+// if any of them is no longer reserved for tx.
+// Outputs tx spends from elsewhere, as in a transaction built
+// with other parties, are left alone.
+func (m *Manager) ExtendReservations(ctx context.Context, tx *bc.TxData, exp time.Time) (int, time.Time, error) {
+	if tx.MaxTime > 0 && bc.Millis(exp) > tx.MaxTime {
+		exp = time.Unix(0, int64(tx.MaxTime)*int64(time.Millisecond))
+	}
+	if !exp.After(time.Now()) {
+		return 0, exp, errors.WithDetail(ErrNotReserved, "transaction has expired")
+	}
+
+	outs, err := m.accountSpends(ctx, tx)
+	if err != nil {
+		return 0, exp, err
+	}
+	if len(outs) == 0 {
+		return 0, exp, nil
+	}
+	err = m.utxoDB.Extend(outs, exp)
+	if err != nil {
+		return 0, exp, err
+	}
+	return len(outs), exp, nil
+}
+
+// ReleaseReservations cancels the reservations of the outputs
+// of this core's accounts spent by tx, so that they can be
+// spent again at once, as when a signing flow is abandoned.
+// It returns the number of outputs it released. Outputs that
+// are no longer reserved are skipped. Like ExtendReservations,
+// it returns ErrNotReserved if a reservation also holds
+// outputs tx doesn't spend.
+func (m *Manager) ReleaseReservations(ctx context.Context, tx *bc.TxData) (int, error) {
+	outs, err := m.accountSpends(ctx, tx)
+	if err != nil || len(outs) == 0 {
+		return 0, err
+	}
+	return m.utxoDB.Release(ctx, outs)
+}
+
+// accountSpends returns the outputs of
+// this core's accounts spent by tx.
+func (m *Manager) accountSpends(ctx context.Context, tx *bc.TxData) ([]bc.Outpoint, error) {
+	txHashes, indexes := prevoutDBKeys(bc.NewTx(*tx))
+	const q = `
+		SELECT tx_hash, index FROM account_utxos
+		WHERE (tx_hash, index) IN (SELECT unnest($1::bytea[]), unnest($2::integer[]))
+	`
+	var outs []bc.Outpoint
+	err := pg.ForQueryRows(ctx, m.db, q, txHashes, indexes, func(h bc.Hash, index uint32) {
+		outs = append(outs, bc.Outpoint{Hash: h, Index: index})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account utxos")
+	}
+	return outs, nil
+}
+
 type Account struct {
 	*signers.Signer
 	Alias string
 	Tags  map[string]interface{}
+
+	// Archived is set for accounts that can no longer
+	// send or receive funds. See Archive.
+	Archived bool
+
+	// EpochPeriod, if set, is the length of the account's
+	// derivation epochs. The keys of each control program are
+	// derived through the epoch it's created in, so control
+	// programs of different epochs come from different branches
+	// of the account's keys, and integrations holding one
+	// branch's keys stop matching new control programs once its
+	// epoch ends. Control programs are stored with their epoch,
+	// so payments to every past epoch are still recognized.
+	EpochPeriod time.Duration
+
+	// ParentID is the ID of the account's parent,
+	// if it is a sub-account. See CreateChild.
+	ParentID string
 }
 
-// Create creates a new Account.
-func (m *Manager) Create(ctx context.Context, xpubs []chainkd.XPub, quorum int, alias string, tags map[string]interface{}, clientToken string) (*Account, error) {
+// Create creates a new Account. If epochPeriod is not zero, it
+// must be a whole number of seconds; see Account.EpochPeriod.
+func (m *Manager) Create(ctx context.Context, xpubs []chainkd.XPub, quorum int, alias string, tags map[string]interface{}, epochPeriod time.Duration, clientToken string) (*Account, error) {
+	if !validEpochPeriod(epochPeriod) {
+		return nil, errors.WithDetailf(ErrBadEpochPeriod, "epoch period %s", epochPeriod)
+	}
 	signer, err := signers.Create(ctx, m.db, "account", xpubs, quorum, clientToken)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
+	account, err := m.insertAccount(ctx, m.db, signer, alias, tags, epochPeriod, "")
+	if err != nil {
+		return nil, err
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+	return account, nil
+}
 
+// insertAccount stores the account of a new signer in db
+// and returns the account. The caller indexes it with
+// indexAnnotatedAccount, once it's committed.
+func (m *Manager) insertAccount(ctx context.Context, db pg.DB, signer *signers.Signer, alias string, tags map[string]interface{}, epochPeriod time.Duration, parentID string) (*Account, error) {
 	tagsParam, err := tagsToNullString(tags)
 	if err != nil {
 		return nil, err
@@ -103,29 +211,91 @@ func (m *Manager) Create(ctx context.Context, xpubs []chainkd.XPub, quorum int,
 		String: alias,
 		Valid:  alias != "",
 	}
+	parentSQL := stdsql.NullString{
+		String: parentID,
+		Valid:  parentID != "",
+	}
 
 	const q = `
-		INSERT INTO accounts (account_id, alias, tags) VALUES ($1, $2, $3)
+		INSERT INTO accounts (account_id, alias, tags, epoch_period, parent_id) VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (account_id) DO UPDATE SET alias = $2, tags = $3
+		RETURNING archived_at IS NOT NULL, epoch_period
 	`
-	_, err = m.db.Exec(ctx, q, signer.ID, aliasSQL, tagsParam)
+	var (
+		archived  bool
+		epochSecs int64
+	)
+	err = db.QueryRow(ctx, q, signer.ID, aliasSQL, tagsParam, int64(epochPeriod/time.Second), parentSQL).Scan(&archived, &epochSecs)
 	if pg.IsUniqueViolation(err) {
 		return nil, errors.WithDetail(ErrDuplicateAlias, "an account with the provided alias already exists")
 	} else if err != nil {
 		return nil, errors.Wrap(err)
 	}
 
+	// A retried Create (with the same client token) may have
+	// changed the alias of an account we've already cached.
+	m.invalidate(signer.ID, alias)
+
 	account := &Account{
-		Signer: signer,
-		Alias:  alias,
-		Tags:   tags,
+		Signer:      signer,
+		Alias:       alias,
+		Tags:        tags,
+		Archived:    archived,
+		EpochPeriod: time.Duration(epochSecs) * time.Second,
+		ParentID:    parentID,
 	}
+	return account, nil
+}
 
+// UpdateTags replaces the tags of the account with the given
+// ID. Transactions and outputs already indexed are annotated
+// again with the new tags, so queries on account tags find
+// the account's history.
+func (m *Manager) UpdateTags(ctx context.Context, id string, tags map[string]interface{}) (*Account, error) {
+	signer, err := m.findByID(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account")
+	}
+	tagsParam, err := tagsToNullString(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		alias     stdsql.NullString
+		archived  bool
+		epochSecs int64
+		parentID  stdsql.NullString
+	)
+	const q = `
+		UPDATE accounts SET tags = $2 WHERE account_id = $1
+		RETURNING alias, archived_at IS NOT NULL, epoch_period, parent_id
+	`
+	err = m.db.QueryRow(ctx, q, id, tagsParam).Scan(&alias, &archived, &epochSecs, &parentID)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "account id: %s", id)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "updating account tags")
+	}
+
+	account := &Account{
+		Signer:      signer,
+		Alias:       alias.String,
+		Tags:        tags,
+		Archived:    archived,
+		EpochPeriod: time.Duration(epochSecs) * time.Second,
+		ParentID:    parentID.String,
+	}
 	err = m.indexAnnotatedAccount(ctx, account)
 	if err != nil {
 		return nil, errors.Wrap(err, "indexing annotated account")
 	}
-
+	if m.indexer != nil {
+		err = m.indexer.UpdateAccountAnnotations(ctx, id, tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "annotating account history")
+		}
+	}
 	return account, nil
 }
 
@@ -154,6 +324,47 @@ func (m *Manager) FindByAlias(ctx context.Context, alias string) (*signers.Signe
 	return m.findByID(ctx, accountID)
 }
 
+// ResolveAliases looks up the accounts with the given aliases
+// and IDs in a single query. It returns the ID of each alias and
+// the alias of each ID. Aliases and IDs that don't belong to an
+// account, and accounts without an alias, are left out.
+func (m *Manager) ResolveAliases(ctx context.Context, aliases, ids []string) (idsByAlias, aliasesByID map[string]string, err error) {
+	idsByAlias = make(map[string]string)
+	aliasesByID = make(map[string]string)
+	if len(aliases) == 0 && len(ids) == 0 {
+		return idsByAlias, aliasesByID, nil
+	}
+
+	wantAlias := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		wantAlias[alias] = true
+	}
+	wantID := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wantID[id] = true
+	}
+
+	const q = `
+		SELECT account_id, alias FROM accounts
+		WHERE alias IS NOT NULL AND (alias = ANY($1) OR account_id = ANY($2))
+	`
+	err = pg.ForQueryRows(ctx, m.db, q, pq.StringArray(aliases), pq.StringArray(ids), func(id, alias string) {
+		if wantAlias[alias] {
+			idsByAlias[alias] = id
+		}
+		if wantID[id] {
+			aliasesByID[id] = alias
+		}
+		m.cacheMu.Lock()
+		m.aliasCache.Add(alias, id)
+		m.cacheMu.Unlock()
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "resolving account aliases")
+	}
+	return idsByAlias, aliasesByID, nil
+}
+
 // findByID returns an account's Signer record by its ID.
 func (m *Manager) findByID(ctx context.Context, id string) (*signers.Signer, error) {
 	m.cacheMu.Lock()
@@ -172,14 +383,46 @@ func (m *Manager) findByID(ctx context.Context, id string) (*signers.Signer, err
 	return account, nil
 }
 
+// ClearCache removes all accounts from the lookup caches. It
+// should be called whenever this process may have missed
+// updates made by other processes, such as when it becomes
+// the core leader.
+func (m *Manager) ClearCache() {
+	m.cacheMu.Lock()
+	m.cache = lru.New(maxAccountCache)
+	m.aliasCache = lru.New(maxAccountCache)
+	m.xpubCache = lru.New(maxAccountCache)
+	m.cacheMu.Unlock()
+}
+
+// invalidate removes an account and alias from the lookup caches.
+// Cached xpubs belong to a key generation, which never changes,
+// so they stay.
+func (m *Manager) invalidate(id, alias string) {
+	m.cacheMu.Lock()
+	m.cache.Remove(id)
+	if alias != "" {
+		m.aliasCache.Remove(alias)
+	}
+	m.cacheMu.Unlock()
+}
+
 type controlProgram struct {
 	accountID      string
 	keyIndex       uint64
+	epoch          *uint64 // nil if the account doesn't use epochs
+	keyGeneration  uint64
 	controlProgram []byte
 	change         bool
 }
 
 func (m *Manager) createControlProgram(ctx context.Context, accountID string, change bool) (*controlProgram, error) {
+	// Control programs of an upgraded account
+	// belong to its successor.
+	accountID, err := m.latestSuccessor(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
 	account, err := m.findByID(ctx, accountID)
 	if err != nil {
 		return nil, err
@@ -189,25 +432,85 @@ func (m *Manager) createControlProgram(ctx context.Context, accountID string, ch
 	if err != nil {
 		return nil, err
 	}
+	period, err := m.epochPeriod(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var ep *uint64
+	if period > 0 {
+		e := epoch(period, time.Now())
+		ep = &e
+	}
+
+	keys, err := m.keyGeneration(ctx, account, nil)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := m.quorumTiers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
 
-	path := signers.Path(account, signers.AccountKeySpace, idx)
-	derivedXPubs := chainkd.DeriveXPubs(account.XPubs, path)
+	// The path begins with the account's own path, so
+	// start from the cached account xpubs.
+	path := controlProgramPath(account, ep, idx)
+	accountPath := signers.Path(account, signers.AccountKeySpace)
+	derivedXPubs := chainkd.DeriveXPubs(m.accountXPubs(account, keys), path[len(accountPath):])
 	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	control, err := vmutil.P2SPMultiSigProgram(derivedPKs, account.Quorum)
+	var control []byte
+	if len(tiers) > 0 {
+		control, err = vmutil.TieredMultiSigProgram(derivedPKs, keys.Quorum, vmTiers(tiers))
+	} else {
+		control, err = vmutil.P2SPMultiSigProgram(derivedPKs, keys.Quorum)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &controlProgram{
 		accountID:      account.ID,
 		keyIndex:       idx,
+		epoch:          ep,
+		keyGeneration:  keys.Generation,
 		controlProgram: control,
 		change:         change,
 	}, nil
 }
 
+// accountXPubs returns the root xpubs of an account's key
+// generation derived along the account's signer path. Every
+// control program key of that generation is a child of one
+// of these keys.
+func (m *Manager) accountXPubs(account *signers.Signer, keys *KeyGeneration) []chainkd.XPub {
+	cacheKey := fmt.Sprintf("%s/%d", account.ID, keys.Generation)
+	m.cacheMu.Lock()
+	cached, ok := m.xpubCache.Get(cacheKey)
+	m.cacheMu.Unlock()
+	if ok {
+		return cached.([]chainkd.XPub)
+	}
+
+	path := signers.Path(account, signers.AccountKeySpace)
+	xpubs := chainkd.DeriveXPubs(keys.XPubs, path)
+	m.cacheMu.Lock()
+	m.xpubCache.Add(cacheKey, xpubs)
+	m.cacheMu.Unlock()
+	return xpubs
+}
+
 // CreateControlProgram creates a control program
 // that is tied to the Account and stores it in the database.
+// It returns ErrFrozen if the account is frozen,
+// and ErrArchived if it's archived.
 func (m *Manager) CreateControlProgram(ctx context.Context, accountID string, change bool) ([]byte, error) {
+	err := m.checkFrozen(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkArchived(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
 	cp, err := m.createControlProgram(ctx, accountID, change)
 	if err != nil {
 		return nil, err
@@ -222,23 +525,32 @@ func (m *Manager) CreateControlProgram(ctx context.Context, accountID string, ch
 
 func (m *Manager) insertAccountControlProgram(ctx context.Context, progs ...*controlProgram) error {
 	const q = `
-		INSERT INTO account_control_programs (signer_id, key_index, control_program, change)
-		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), unnest($4::boolean[])
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change, epoch, key_generation)
+		SELECT unnest($1::text[]), unnest($2::bigint[]), unnest($3::bytea[]), unnest($4::boolean[]),
+			NULLIF(unnest($5::bigint[]), -1), unnest($6::integer[])
 	`
 	var (
 		accountIDs   pq.StringArray
 		keyIndexes   pq.Int64Array
 		controlProgs pq.ByteaArray
 		change       pq.BoolArray
+		epochs       pq.Int64Array // -1 for no epoch
+		generations  pq.Int64Array
 	)
 	for _, p := range progs {
 		accountIDs = append(accountIDs, p.accountID)
 		keyIndexes = append(keyIndexes, int64(p.keyIndex))
 		controlProgs = append(controlProgs, p.controlProgram)
 		change = append(change, p.change)
+		if p.epoch != nil {
+			epochs = append(epochs, int64(*p.epoch))
+		} else {
+			epochs = append(epochs, -1)
+		}
+		generations = append(generations, int64(p.keyGeneration))
 	}
 
-	_, err := m.db.Exec(ctx, q, accountIDs, keyIndexes, controlProgs, change)
+	_, err := m.db.Exec(ctx, q, accountIDs, keyIndexes, controlProgs, change, epochs, generations)
 	return errors.Wrap(err)
 }
 
@@ -3,9 +3,11 @@ package account
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
 
+	"chain/core/signers"
 	"chain/crypto/ed25519/chainkd"
 	"chain/database/pg/pgtest"
 	"chain/errors"
@@ -19,7 +21,7 @@ func TestCreateAccount(t *testing.T) {
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 
-	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, "")
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -42,11 +44,11 @@ func TestCreateAccountIdempotency(t *testing.T) {
 	ctx := context.Background()
 	var clientToken = "a-unique-client-token"
 
-	account1, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "satoshi", nil, clientToken)
+	account1, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "satoshi", nil, 0, clientToken)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	account2, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "satoshi", nil, clientToken)
+	account2, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "satoshi", nil, 0, clientToken)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -61,19 +63,75 @@ func TestCreateAccountReusedAlias(t *testing.T) {
 	ctx := context.Background()
 	m.createTestAccount(ctx, t, "some-account", nil)
 
-	_, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "some-account", nil, "")
+	_, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "some-account", nil, 0, "")
 	if errors.Root(err) != ErrDuplicateAlias {
 		t.Errorf("Expected %s when reusing an alias, got %v", ErrDuplicateAlias, err)
 	}
 }
 
+func TestUpdateTags(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	account := m.createTestAccount(ctx, t, "alice", map[string]interface{}{"branch": "east"})
+	tags := map[string]interface{}{"branch": "west"}
+	updated, err := m.UpdateTags(ctx, account.ID, tags)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if updated.Alias != "alice" || !reflect.DeepEqual(updated.Tags, tags) {
+		t.Errorf("updated account = %+v, want alias alice and tags %v", updated, tags)
+	}
+
+	var got []byte
+	err = m.db.QueryRow(ctx, `SELECT tags FROM accounts WHERE account_id = $1`, account.ID).Scan(&got)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var stored map[string]interface{}
+	err = json.Unmarshal(got, &stored)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(stored, tags) {
+		t.Errorf("stored tags = %v want %v", stored, tags)
+	}
+
+	_, err = m.UpdateTags(ctx, "nonexistent", tags)
+	if err == nil {
+		t.Error("expected error updating nonexistent account")
+	}
+}
+
+func TestAccountXPubs(t *testing.T) {
+	m := NewManager(nil, prottest.NewChain(t), nil)
+	account := &signers.Signer{
+		ID:       "acc1",
+		XPubs:    []chainkd.XPub{testutil.TestXPub},
+		Quorum:   1,
+		KeyIndex: 3,
+	}
+
+	keys := &KeyGeneration{AccountID: account.ID, XPubs: account.XPubs, Quorum: account.Quorum}
+
+	path := signers.Path(account, signers.AccountKeySpace, 7)
+	want := chainkd.DeriveXPubs(account.XPubs, path)
+	for i := 0; i < 2; i++ { // once uncached, once cached
+		got := chainkd.DeriveXPubs(m.accountXPubs(account, keys), path[1:])
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("derived xpubs = %v want %v", got, want)
+		}
+	}
+}
+
 func TestCreateControlProgram(t *testing.T) {
 	// use pgtest.NewDB for deterministic postgres sequences
 	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
 	m := NewManager(db, prottest.NewChain(t), nil)
 	ctx := context.Background()
 
-	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, "")
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -94,7 +152,7 @@ func TestCreateControlProgram(t *testing.T) {
 }
 
 func (m *Manager) createTestAccount(ctx context.Context, t testing.TB, alias string, tags map[string]interface{}) *Account {
-	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, alias, tags, "")
+	account, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, alias, tags, 0, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -146,3 +204,26 @@ func TestFindByAlias(t *testing.T) {
 		t.Errorf("expected found account to be %v, instead found %v", account, found)
 	}
 }
+
+func TestResolveAliases(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	alice := m.createTestAccount(ctx, t, "alice", nil)
+	bob := m.createTestAccount(ctx, t, "bob", nil)
+	anon := m.createTestAccount(ctx, t, "", nil)
+
+	idsByAlias, aliasesByID, err := m.ResolveAliases(ctx, []string{"alice", "carol"}, []string{bob.ID, anon.ID, "nonexistent"})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	wantIDs := map[string]string{"alice": alice.ID}
+	if !reflect.DeepEqual(idsByAlias, wantIDs) {
+		t.Errorf("idsByAlias = %v want %v", idsByAlias, wantIDs)
+	}
+	wantAliases := map[string]string{bob.ID: "bob"}
+	if !reflect.DeepEqual(aliasesByID, wantAliases) {
+		t.Errorf("aliasesByID = %v want %v", aliasesByID, wantAliases)
+	}
+}
@@ -14,11 +14,12 @@ import (
 	"chain/log"
 )
 
-// AnnotateTxs adds account data to transactions
+// AnnotateTxs adds account data to transactions. The accounts for
+// every control program appearing in txs are loaded in a single query.
 func (m *Manager) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
 	controlMaps := make(map[string][]map[string]interface{})
 	outputMaps := make(map[string][]map[string]interface{})
-	var controlPrograms [][]byte
+	var controlPrograms pq.ByteaArray
 
 	update := func(s interface{}, maps ...map[string][]map[string]interface{}) {
 		asSlice, ok := s.([]interface{})
@@ -46,7 +47,9 @@ func (m *Manager) AnnotateTxs(ctx context.Context, txs []map[string]interface{})
 				log.Error(ctx, errors.Wrap(err, "could not decode control program"))
 				continue
 			}
-			controlPrograms = append(controlPrograms, controlProgram)
+			if _, ok := controlMaps[string(controlProgram)]; !ok {
+				controlPrograms = append(controlPrograms, controlProgram)
+			}
 			for _, m := range maps {
 				m[string(controlProgram)] = append(m[string(controlProgram)], asMap)
 			}
@@ -57,57 +60,67 @@ func (m *Manager) AnnotateTxs(ctx context.Context, txs []map[string]interface{})
 		update(tx["outputs"], controlMaps, outputMaps)
 		update(tx["inputs"], controlMaps)
 	}
+	if len(controlMaps) == 0 {
+		return nil
+	}
 
+	// Look up every distinct control program in the block at once.
+	// Control programs belonging to the same account share its tags.
 	const q = `
-		SELECT signer_id, control_program, change, alias, tags
+		SELECT signer_id, control_program, change, key_generation, alias, tags
 		FROM account_control_programs
-		LEFT JOIN signers ON signers.id=account_control_programs.signer_id
-		LEFT JOIN accounts ON accounts.account_id=signers.id
-		WHERE control_program=ANY($1::bytea[])
+		LEFT JOIN accounts ON accounts.account_id=account_control_programs.signer_id
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
 	`
-	var (
-		ids         []string
-		programs    [][]byte
-		changeFlags []bool
-		aliases     []sql.NullString
-		tags        []*json.RawMessage
-	)
-	err := pg.ForQueryRows(ctx, m.db, q, pq.ByteaArray(controlPrograms), func(accountID string, program []byte, change bool, alias sql.NullString, accountTags []byte) {
-		ids = append(ids, accountID)
-		programs = append(programs, program)
-		changeFlags = append(changeFlags, change)
-		aliases = append(aliases, alias)
-		if len(accountTags) > 0 {
-			tags = append(tags, (*json.RawMessage)(&accountTags))
-		} else {
-			tags = append(tags, nil)
+	tagsByAccountID := make(map[string]*json.RawMessage)
+	programsByAccountID := make(map[string][]string)
+	var accountIDs []string
+	err := pg.ForQueryRows(ctx, m.db, q, controlPrograms, func(accountID string, program []byte, change bool, keyGen uint64, alias sql.NullString, accountTags []byte) {
+		tags, ok := tagsByAccountID[accountID]
+		if !ok {
+			if len(accountTags) > 0 {
+				tags = (*json.RawMessage)(&accountTags)
+			}
+			tagsByAccountID[accountID] = tags
+			accountIDs = append(accountIDs, accountID)
 		}
-	})
-	if err != nil {
-		return err
-	}
-	for i := range ids {
-		maps := controlMaps[string(programs[i])]
-		for _, m := range maps {
-			m["account_id"] = ids[i]
-			if tags[i] != nil {
-				m["account_tags"] = tags[i]
+		programsByAccountID[accountID] = append(programsByAccountID[accountID], string(program))
+		for _, m := range controlMaps[string(program)] {
+			m["account_id"] = accountID
+			m["account_key_generation"] = keyGen
+			if tags != nil {
+				m["account_tags"] = tags
 			}
-			if aliases[i].Valid {
-				m["account_alias"] = aliases[i].String
+			if alias.Valid {
+				m["account_alias"] = alias.String
 			}
 		}
 
 		// Add output-only annotations.
-		outs := outputMaps[string(programs[i])]
-		for _, out := range outs {
-			if changeFlags[i] {
-				out["purpose"] = "change"
-			} else {
-				out["purpose"] = "receive"
-			}
+		purpose := "receive"
+		if change {
+			purpose = "change"
 		}
+		for _, out := range outputMaps[string(program)] {
+			out["purpose"] = purpose
+		}
+	})
+	if err != nil {
+		return errors.Wrap(err, "querying account control programs")
 	}
 
+	// Each account's path lets queries roll up
+	// an account along with its sub-accounts.
+	paths, err := m.accountPaths(ctx, accountIDs)
+	if err != nil {
+		return err
+	}
+	for accountID, programs := range programsByAccountID {
+		for _, program := range programs {
+			for _, m := range controlMaps[program] {
+				m["account_path"] = paths[accountID]
+			}
+		}
+	}
 	return nil
 }
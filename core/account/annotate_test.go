@@ -39,15 +39,19 @@ func TestAnnotateTxs(t *testing.T) {
 		"inputs": []interface{}{},
 		"outputs": []interface{}{
 			map[string]interface{}{
-				"purpose":         "receive",
-				"control_program": hex.EncodeToString(acp1),
-				"account_id":      acc1.ID,
+				"purpose":                "receive",
+				"control_program":        hex.EncodeToString(acp1),
+				"account_id":             acc1.ID,
+				"account_path":           []map[string]interface{}{{"id": acc1.ID}},
+				"account_key_generation": uint64(0),
 			},
 			map[string]interface{}{
-				"purpose":         "receive",
-				"control_program": hex.EncodeToString(acp2),
-				"account_id":      acc2.ID,
-				"account_tags":    (*json.RawMessage)(&wantTags),
+				"purpose":                "receive",
+				"control_program":        hex.EncodeToString(acp2),
+				"account_id":             acc2.ID,
+				"account_path":           []map[string]interface{}{{"id": acc2.ID}},
+				"account_key_generation": uint64(0),
+				"account_tags":           (*json.RawMessage)(&wantTags),
 			},
 		},
 	}}
@@ -0,0 +1,126 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+var (
+	// ErrArchived is returned when spending from, creating
+	// a control program for, or paying to an archived account.
+	ErrArchived = errors.New("account is archived")
+
+	// ErrReservationsOutstanding is returned by Archive when
+	// the context ends before the account's reservations do.
+	ErrReservationsOutstanding = errors.New("account has outstanding reservations")
+)
+
+// archivePollPeriod is how often Archive checks
+// whether an account's reservations have ended.
+const archivePollPeriod = 100 * time.Millisecond
+
+// Archive archives the account with the given ID. An archived
+// account gets no new control programs and can't be spent from
+// or paid to in transactions, but its funds and history can
+// still be queried. Archive returns once none of the account's
+// outputs are reserved. If cancelReservations is set, it cancels
+// outstanding reservations; otherwise it waits for them to
+// expire, and returns ErrReservationsOutstanding if ctx ends
+// first. The account stays archived in that case, and
+// calling Archive again resumes waiting.
+//
+// Reservations are held in memory, so Archive must be
+// called on the core leader.
+func (m *Manager) Archive(ctx context.Context, accountID string, cancelReservations bool) (*Account, error) {
+	signer, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		alias     stdsql.NullString
+		tagsJSON  []byte
+		epochSecs int64
+		parentID  stdsql.NullString
+	)
+	const q = `
+		UPDATE accounts SET archived_at = COALESCE(archived_at, now())
+		WHERE account_id = $1
+		RETURNING alias, tags, epoch_period, parent_id
+	`
+	err = m.db.QueryRow(ctx, q, accountID).Scan(&alias, &tagsJSON, &epochSecs, &parentID)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "account id: %s", accountID)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "archiving account")
+	}
+	account := &Account{
+		Signer:      signer,
+		Alias:       alias.String,
+		Archived:    true,
+		EpochPeriod: time.Duration(epochSecs) * time.Second,
+		ParentID:    parentID.String,
+	}
+	if len(tagsJSON) > 0 {
+		err = json.Unmarshal(tagsJSON, &account.Tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding account tags")
+		}
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+
+	err = m.drainReservations(ctx, accountID, cancelReservations)
+	if err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// drainReservations returns once the account has no outstanding
+// reservations, canceling them if cancel is set. Builds that
+// started before the account was archived may still reserve its
+// outputs, so it checks again after canceling.
+func (m *Manager) drainReservations(ctx context.Context, accountID string, cancel bool) error {
+	ticks := time.NewTicker(archivePollPeriod)
+	defer ticks.Stop()
+	for {
+		rids := m.utxoDB.accountReservations(accountID)
+		if len(rids) == 0 {
+			return nil
+		}
+		if cancel {
+			for _, rid := range rids {
+				// The reservation may have ended since it was listed.
+				m.utxoDB.Cancel(ctx, rid)
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return errors.WithDetailf(ErrReservationsOutstanding, "account %s has %d reservations", accountID, len(rids))
+		case <-ticks.C:
+		}
+	}
+}
+
+// checkArchived returns ErrArchived if the account is archived.
+func (m *Manager) checkArchived(ctx context.Context, accountID string) error {
+	const q = `SELECT EXISTS(SELECT 1 FROM accounts WHERE account_id = $1 AND archived_at IS NOT NULL)`
+	var archived bool
+	err := m.db.QueryRow(ctx, q, accountID).Scan(&archived)
+	if err != nil {
+		return errors.Wrap(err, "checking account archive")
+	}
+	if archived {
+		return errors.WithDetailf(ErrArchived, "account %s", accountID)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestArchive(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", nil)
+
+	res := &reservation{ID: 1, Source: source{AccountID: acc.ID}, Expiry: time.Now().Add(time.Minute)}
+	m.utxoDB.reservations[res.ID] = res
+
+	// The account is archived even if its reservations don't end in time.
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err := m.Archive(waitCtx, acc.ID, false)
+	if errors.Root(err) != ErrReservationsOutstanding {
+		t.Errorf("archive with reservation: got error %v want %v", err, ErrReservationsOutstanding)
+	}
+	_, err = m.CreateControlProgram(ctx, acc.ID, false)
+	if errors.Root(err) != ErrArchived {
+		t.Errorf("create control program: got error %v want %v", err, ErrArchived)
+	}
+	action := m.NewControlAction(bc.AssetAmount{AssetID: bc.AssetID{1}, Amount: 1}, acc.ID, nil)
+	err = action.Build(ctx, new(txbuilder.TemplateBuilder))
+	if errors.Root(err) != ErrArchived {
+		t.Errorf("control action: got error %v want %v", err, ErrArchived)
+	}
+
+	got, err := m.Archive(ctx, acc.ID, true)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !got.Archived || got.ID != acc.ID {
+		t.Errorf("archived account = %+v want account %s archived", got, acc.ID)
+	}
+	if rids := m.utxoDB.accountReservations(acc.ID); len(rids) != 0 {
+		t.Errorf("reservations after archive = %v want none", rids)
+	}
+}
@@ -0,0 +1,150 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// DerivationAuditFormat identifies the format of
+// derivation audits made by DerivationAudit.
+const DerivationAuditFormat = "chain-derivation-audit/1"
+
+// ErrBadDerivationAudit is returned by VerifyDerivationAudit when
+// a control program in an audit isn't the one its keys derive.
+var ErrBadDerivationAudit = errors.New("derivation audit does not verify")
+
+// A DerivationAudit lists every control program derived for an
+// account, with what an auditor needs to derive each one again
+// from the account's root xpubs, and so to verify that the
+// account owns it, without trusting the Core.
+type DerivationAudit struct {
+	Format          string            `json:"format"`
+	AccountID       string            `json:"account_id"`
+	KeyGenerations  []*KeyGeneration  `json:"key_generations"`
+	ControlPrograms []*DerivedProgram `json:"control_programs"`
+	CreatedAt       time.Time         `json:"created_at"`
+}
+
+// A DerivedProgram is one of the control programs
+// in a DerivationAudit.
+//
+// DerivationPath leads from the root xpubs of key generation
+// KeyGeneration to the keys of ControlProgram. The program is
+// a multisig program of those keys, in that order, with the
+// generation's quorum, and possibly with quorum tiers.
+//
+// FirstUsedTxID and FirstUsedBlockHeight identify the first
+// transaction paying to the program, if any.
+type DerivedProgram struct {
+	KeyIndex             uint64               `json:"key_index"`
+	Epoch                *uint64              `json:"epoch,omitempty"`
+	KeyGeneration        uint64               `json:"key_generation"`
+	Change               bool                 `json:"change"`
+	DerivationPath       []chainjson.HexBytes `json:"derivation_path"`
+	ControlProgram       chainjson.HexBytes   `json:"control_program"`
+	FirstUsedTxID        *bc.Hash             `json:"first_used_transaction_id,omitempty"`
+	FirstUsedBlockHeight *uint64              `json:"first_used_block_height,omitempty"`
+}
+
+// DerivationAudit returns the derivation audit of the
+// account, with its control programs in the order of
+// their key indexes.
+func (m *Manager) DerivationAudit(ctx context.Context, accountID string) (*DerivationAudit, error) {
+	account, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	gens, err := m.KeyGenerations(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &DerivationAudit{
+		Format:         DerivationAuditFormat,
+		AccountID:      accountID,
+		KeyGenerations: gens,
+		CreatedAt:      time.Now().UTC(),
+	}
+	const q = `
+		SELECT key_index, epoch, key_generation, change, control_program, first_used_tx, first_used_block_height
+		FROM account_control_programs
+		WHERE signer_id = $1 ORDER BY key_index
+	`
+	err = pg.ForQueryRows(ctx, m.db, q, accountID, func(keyIndex uint64, epoch *uint64, keyGen uint64, change bool, prog []byte, firstTx []byte, firstHeight *uint64) {
+		p := &DerivedProgram{
+			KeyIndex:             keyIndex,
+			Epoch:                epoch,
+			KeyGeneration:        keyGen,
+			Change:               change,
+			ControlProgram:       prog,
+			FirstUsedBlockHeight: firstHeight,
+		}
+		for _, b := range controlProgramPath(account, epoch, keyIndex) {
+			p.DerivationPath = append(p.DerivationPath, b)
+		}
+		if firstTx != nil {
+			var h bc.Hash
+			copy(h[:], firstTx)
+			p.FirstUsedTxID = &h
+		}
+		audit.ControlPrograms = append(audit.ControlPrograms, p)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account control programs")
+	}
+	return audit, nil
+}
+
+// VerifyDerivationAudit derives each control program in the
+// audit again from the root xpubs of its key generation, and
+// tests that the result is the program in the audit. It uses
+// nothing but the audit.
+func VerifyDerivationAudit(audit *DerivationAudit) error {
+	if audit.Format != DerivationAuditFormat {
+		return errors.WithDetailf(ErrBadDerivationAudit, "unknown format %q", audit.Format)
+	}
+	gens := make(map[uint64]*KeyGeneration, len(audit.KeyGenerations))
+	for _, g := range audit.KeyGenerations {
+		gens[g.Generation] = g
+	}
+	for _, p := range audit.ControlPrograms {
+		g, ok := gens[p.KeyGeneration]
+		if !ok {
+			return errors.WithDetailf(ErrBadDerivationAudit, "key index %d: no key generation %d", p.KeyIndex, p.KeyGeneration)
+		}
+		path := make([][]byte, 0, len(p.DerivationPath))
+		for _, b := range p.DerivationPath {
+			path = append(path, b)
+		}
+		var idx [8]byte
+		binary.LittleEndian.PutUint64(idx[:], p.KeyIndex)
+		if len(path) == 0 || !bytes.Equal(path[len(path)-1], idx[:]) {
+			return errors.WithDetailf(ErrBadDerivationAudit, "key index %d: derivation path does not end with the key index", p.KeyIndex)
+		}
+		pubkeys := chainkd.XPubKeys(chainkd.DeriveXPubs(g.XPubs, path))
+
+		want, err := vmutil.P2SPMultiSigProgram(pubkeys, g.Quorum)
+		if err != nil {
+			return errors.WithDetailf(ErrBadDerivationAudit, "key index %d: %s", p.KeyIndex, err)
+		}
+		if _, _, tiers, err := vmutil.ParseTieredMultiSigProgram(p.ControlProgram); err == nil {
+			want, err = vmutil.TieredMultiSigProgram(pubkeys, g.Quorum, tiers)
+			if err != nil {
+				return errors.WithDetailf(ErrBadDerivationAudit, "key index %d: %s", p.KeyIndex, err)
+			}
+		}
+		if !bytes.Equal(want, p.ControlProgram) {
+			return errors.WithDetailf(ErrBadDerivationAudit, "key index %d: control program does not match its derived keys", p.KeyIndex)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestDerivationAudit(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	acc := m.createTestAccount(ctx, t, "", nil)
+	used := m.createTestControlProgram(ctx, t, acc.ID)
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.RotateKeys(ctx, acc.ID, []chainkd.XPub{xpub2}, 1, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	m.createTestControlProgram(ctx, t, acc.ID)
+
+	block := &bc.Block{
+		BlockHeader: bc.BlockHeader{Height: 3},
+		Transactions: []*bc.Tx{
+			bc.NewTx(bc.TxData{
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(bc.AssetID{}, 1, used, nil)},
+			}),
+		},
+	}
+	err = m.indexAccountUTXOs(ctx, block)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	audit, err := m.DerivationAudit(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(audit.KeyGenerations) != 2 || len(audit.ControlPrograms) != 2 {
+		t.Fatalf("got %d key generations, %d control programs, want 2, 2", len(audit.KeyGenerations), len(audit.ControlPrograms))
+	}
+	first := audit.ControlPrograms[0]
+	if first.FirstUsedTxID == nil || *first.FirstUsedTxID != block.Transactions[0].Hash {
+		t.Errorf("first used tx = %v want %v", first.FirstUsedTxID, block.Transactions[0].Hash)
+	}
+	if first.FirstUsedBlockHeight == nil || *first.FirstUsedBlockHeight != 3 {
+		t.Errorf("first used block height = %v want 3", first.FirstUsedBlockHeight)
+	}
+	if audit.ControlPrograms[1].FirstUsedTxID != nil {
+		t.Errorf("unused control program has first used tx %v", audit.ControlPrograms[1].FirstUsedTxID)
+	}
+
+	err = VerifyDerivationAudit(audit)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Claiming the first program for the rotated keys fails.
+	first.KeyGeneration = 1
+	err = VerifyDerivationAudit(audit)
+	if errors.Root(err) != ErrBadDerivationAudit {
+		t.Errorf("verifying tampered audit: err = %v want %v", err, ErrBadDerivationAudit)
+	}
+}
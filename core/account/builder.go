@@ -10,6 +10,7 @@ import (
 	"chain/errors"
 	"chain/log"
 	"chain/protocol/bc"
+	"chain/protocol/vmutil"
 )
 
 func (m *Manager) NewSpendAction(amt bc.AssetAmount, accountID string, refData chainjson.Map, clientToken *string) txbuilder.Action {
@@ -52,12 +53,24 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 	if err != nil {
 		return errors.Wrap(err, "get account info")
 	}
+	err = a.accounts.checkFrozen(ctx, a.AccountID)
+	if err != nil {
+		return err
+	}
+	err = a.accounts.checkArchived(ctx, a.AccountID)
+	if err != nil {
+		return err
+	}
+	err = a.accounts.checkSpendingLimit(ctx, a.AccountID, a.AssetID, a.Amount)
+	if err != nil {
+		return err
+	}
 
 	src := source{
 		AssetID:   a.AssetID,
 		AccountID: a.AccountID,
 	}
-	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, a.ClientToken, b.MaxTime())
+	res, err := a.accounts.utxoDB.Reserve(ctx, src, a.Amount, a.ClientToken, b.ReservationExpiry())
 	if err != nil {
 		return errors.Wrap(err, "reserving utxos")
 	}
@@ -66,7 +79,7 @@ func (a *spendAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) e
 	b.OnRollback(canceler(ctx, a.accounts, res.ID))
 
 	for _, r := range res.UTXOs {
-		txInput, sigInst, err := utxoToInputs(ctx, acct, r, a.ReferenceData)
+		txInput, sigInst, err := a.accounts.utxoToInputs(ctx, acct, r, a.ReferenceData)
 		if err != nil {
 			return errors.Wrap(err, "creating inputs")
 		}
@@ -129,7 +142,7 @@ func (a *spendUTXOAction) Build(ctx context.Context, b *txbuilder.TemplateBuilde
 	}
 
 	out := bc.Outpoint{Hash: *a.TxHash, Index: *a.TxOut}
-	res, err := a.accounts.utxoDB.ReserveUTXO(ctx, out, a.ClientToken, b.MaxTime())
+	res, err := a.accounts.utxoDB.ReserveUTXO(ctx, out, a.ClientToken, b.ReservationExpiry())
 	if err != nil {
 		return err
 	}
@@ -139,7 +152,20 @@ func (a *spendUTXOAction) Build(ctx context.Context, b *txbuilder.TemplateBuilde
 	if err != nil {
 		return err
 	}
-	txInput, sigInst, err := utxoToInputs(ctx, acct, res.UTXOs[0], a.ReferenceData)
+	err = a.accounts.checkFrozen(ctx, res.Source.AccountID)
+	if err != nil {
+		return err
+	}
+	err = a.accounts.checkArchived(ctx, res.Source.AccountID)
+	if err != nil {
+		return err
+	}
+	u := res.UTXOs[0]
+	err = a.accounts.checkSpendingLimit(ctx, res.Source.AccountID, u.AssetID, u.Amount)
+	if err != nil {
+		return err
+	}
+	txInput, sigInst, err := a.accounts.utxoToInputs(ctx, acct, u, a.ReferenceData)
 	if err != nil {
 		return err
 	}
@@ -156,21 +182,35 @@ func canceler(ctx context.Context, m *Manager, rid uint64) func() {
 	}
 }
 
-func utxoToInputs(ctx context.Context, account *signers.Signer, u *utxo, refData []byte) (
+// utxoToInputs returns an input spending u, with instructions to
+// sign it with the keys of the account key generation that
+// created its control program.
+func (m *Manager) utxoToInputs(ctx context.Context, account *signers.Signer, u *utxo, refData []byte) (
 	*bc.TxInput,
 	*txbuilder.SigningInstruction,
 	error,
 ) {
+	keys, err := m.keyGeneration(ctx, account, &u.ControlProgramGeneration)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	txInput := bc.NewSpendInput(u.Hash, u.Index, nil, u.AssetID, u.Amount, u.ControlProgram, refData)
 
 	sigInst := &txbuilder.SigningInstruction{
 		AssetAmount: u.AssetAmount,
 	}
 
-	path := signers.Path(account, signers.AccountKeySpace, u.ControlProgramIndex)
-	keyIDs := txbuilder.KeyIDs(account.XPubs, path)
+	path := controlProgramPath(account, u.ControlProgramEpoch, u.ControlProgramIndex)
+	keyIDs := txbuilder.KeyIDs(keys.XPubs, path)
 
-	sigInst.AddWitnessKeys(keyIDs, account.Quorum)
+	// A tiered control program needs more
+	// signatures to spend larger outputs.
+	quorum := keys.Quorum
+	if _, nrequired, tiers, err := vmutil.ParseTieredMultiSigProgram(u.ControlProgram); err == nil {
+		quorum = vmutil.TierQuorum(nrequired, tiers, u.Amount)
+	}
+	sigInst.AddWitnessKeys(keyIDs, quorum)
 
 	return txInput, sigInst, nil
 }
@@ -209,6 +249,11 @@ func (a *controlAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder)
 		return txbuilder.MissingFieldsError(missing...)
 	}
 
+	err := a.accounts.checkArchived(ctx, a.AccountID)
+	if err != nil {
+		return err
+	}
+
 	// Produce a control program, but don't insert it into the database yet.
 	acp, err := a.accounts.createControlProgram(ctx, a.AccountID, false)
 	if err != nil {
@@ -0,0 +1,198 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/webhook"
+	"chain/protocol/bc"
+)
+
+// EventReservationConflict is the type of the webhook event
+// sent for each reservation conflict.
+const EventReservationConflict = "reservation_conflict"
+
+// Conflict is an account output that was reserved by this
+// core but spent by a transaction this core didn't submit,
+// for example one built and signed with an external signer.
+// A template built from the reservation can no longer be
+// submitted successfully.
+type Conflict struct {
+	ID            string  `json:"id"`
+	TransactionID bc.Hash `json:"transaction_id"`
+	InputPosition uint32  `json:"input_position"`
+	SpentOutput   struct {
+		TransactionID bc.Hash `json:"transaction_id"`
+		Position      uint32  `json:"position"`
+	} `json:"spent_output"`
+	AccountID   string     `json:"account_id"`
+	AssetID     bc.AssetID `json:"asset_id"`
+	Amount      uint64     `json:"amount"`
+	BlockHeight uint64     `json:"block_height"`
+	Timestamp   time.Time  `json:"timestamp"`
+}
+
+// ConflictEvent is the body of a reservation conflict
+// webhook request.
+type ConflictEvent struct {
+	Type     string    `json:"type"`
+	Conflict *Conflict `json:"conflict"`
+}
+
+// NotifyConflicts makes the account block processor send
+// a webhook event to url for each reservation conflict.
+func (m *Manager) NotifyConflicts(url string) {
+	m.conflictWebhook = &webhook.Sender{URL: url}
+}
+
+type spendRef struct {
+	hash  bc.Hash
+	index uint32
+}
+
+// detectConflicts records the outputs spent in b that are
+// reserved, unless the spending tx was submitted through this
+// core. It cancels the reservations holding them, so the rest
+// of their outputs can be reserved again.
+func (m *Manager) detectConflicts(ctx context.Context, b *bc.Block) error {
+	var outpoints []bc.Outpoint
+	spenders := make(map[bc.Outpoint]spendRef)
+	for _, tx := range b.Transactions {
+		for i, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			o := in.Outpoint()
+			outpoints = append(outpoints, o)
+			spenders[o] = spendRef{tx.Hash, uint32(i)}
+		}
+	}
+	reserved := m.utxoDB.reservedOutpoints(outpoints)
+	if len(reserved) == 0 {
+		return nil
+	}
+
+	var hashes pq.ByteaArray
+	for o := range reserved {
+		h := spenders[o].hash
+		hashes = append(hashes, h[:])
+	}
+	submitted := make(map[bc.Hash]bool)
+	const q = `SELECT tx_hash FROM submitted_txs WHERE tx_hash = ANY($1::bytea[])`
+	err := pg.ForQueryRows(ctx, m.db, q, hashes, func(h bc.Hash) {
+		submitted[h] = true
+	})
+	if err != nil {
+		return errors.Wrap(err, "looking up submitted txs")
+	}
+
+	canceled := make(map[uint64]bool)
+	for _, o := range outpoints {
+		res, ok := reserved[o]
+		if !ok || submitted[spenders[o].hash] {
+			continue
+		}
+		c := &Conflict{
+			TransactionID: spenders[o].hash,
+			InputPosition: spenders[o].index,
+			BlockHeight:   b.Height,
+			Timestamp:     b.Time(),
+		}
+		c.SpentOutput.TransactionID = o.Hash
+		c.SpentOutput.Position = o.Index
+		for _, u := range res.UTXOs {
+			if u.Outpoint == o {
+				c.AccountID = u.AccountID
+				c.AssetID = u.AssetID
+				c.Amount = u.Amount
+			}
+		}
+
+		inserted, err := m.insertConflict(ctx, c)
+		if err != nil {
+			return err
+		}
+		if !canceled[res.ID] {
+			canceled[res.ID] = true
+			err = m.utxoDB.Cancel(ctx, res.ID)
+			if err != nil {
+				// It may have expired in the meantime.
+				log.Error(ctx, err, "at", "canceling conflicting reservation")
+			}
+		}
+		// A block processed again doesn't notify again.
+		if inserted && m.conflictWebhook != nil {
+			go m.notifyConflict(ctx, c)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) insertConflict(ctx context.Context, c *Conflict) (bool, error) {
+	const q = `
+		INSERT INTO reservation_conflicts (tx_hash, input_index, spent_tx_hash, spent_output_index,
+			account_id, asset_id, amount, block_height, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (spent_tx_hash, spent_output_index) DO NOTHING
+		RETURNING id
+	`
+	var id int64
+	err := m.db.QueryRow(ctx, q, c.TransactionID, c.InputPosition, c.SpentOutput.TransactionID, c.SpentOutput.Position,
+		c.AccountID, c.AssetID, c.Amount, c.BlockHeight, c.Timestamp).Scan(&id)
+	if err == stdsql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "saving reservation conflict")
+	}
+	c.ID = strconv.FormatInt(id, 10)
+	return true, nil
+}
+
+// notifyConflict sends a webhook event for c.
+// Events that can't be delivered are logged; the
+// conflict can still be found with ListConflicts.
+func (m *Manager) notifyConflict(ctx context.Context, c *Conflict) {
+	err := m.conflictWebhook.Send(ctx, &ConflictEvent{Type: EventReservationConflict, Conflict: c})
+	if err != nil {
+		log.Error(ctx, err, "at", "sending reservation conflict webhook", "id", c.ID)
+	}
+}
+
+// ListConflicts returns up to limit reservation conflicts,
+// oldest first, starting after the one with ID after.
+func (m *Manager) ListConflicts(ctx context.Context, after int64, limit int) ([]*Conflict, error) {
+	const q = `
+		SELECT id, tx_hash, input_index, spent_tx_hash, spent_output_index,
+			account_id, asset_id, amount, block_height, timestamp
+		FROM reservation_conflicts
+		WHERE id > $1 ORDER BY id LIMIT $2
+	`
+	var conflicts []*Conflict
+	err := pg.ForQueryRows(ctx, m.db, q, after, limit, func(
+		id int64, hash bc.Hash, index uint32, spentHash bc.Hash, spentIndex uint32,
+		accountID string, assetID bc.AssetID, amount, height uint64, timestamp time.Time,
+	) {
+		c := &Conflict{
+			ID:            strconv.FormatInt(id, 10),
+			TransactionID: hash,
+			InputPosition: index,
+			AccountID:     accountID,
+			AssetID:       assetID,
+			Amount:        amount,
+			BlockHeight:   height,
+			Timestamp:     timestamp,
+		}
+		c.SpentOutput.TransactionID = spentHash
+		c.SpentOutput.Position = spentIndex
+		conflicts = append(conflicts, c)
+	})
+	return conflicts, errors.Wrap(err, "listing reservation conflicts")
+}
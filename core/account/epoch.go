@@ -0,0 +1,43 @@
+package account
+
+import (
+	"context"
+	"time"
+
+	"chain/core/signers"
+	"chain/errors"
+)
+
+// ErrBadEpochPeriod is returned by Create when the
+// epoch period is not a whole number of seconds.
+var ErrBadEpochPeriod = errors.New("invalid account epoch period")
+
+// epoch returns the epoch in effect at t for the given period.
+func epoch(period time.Duration, t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(period/time.Second)
+}
+
+// controlProgramPath returns the derivation path of the keys of
+// an account's control program, given its epoch, if any, and
+// its key index.
+func controlProgramPath(account *signers.Signer, epoch *uint64, keyIndex uint64) [][]byte {
+	if epoch == nil {
+		return signers.Path(account, signers.AccountKeySpace, keyIndex)
+	}
+	return signers.Path(account, signers.AccountKeySpace, *epoch, keyIndex)
+}
+
+// epochPeriod returns the epoch period of the account,
+// or zero if its control programs don't use epochs.
+func (m *Manager) epochPeriod(ctx context.Context, accountID string) (time.Duration, error) {
+	var secs int64
+	err := m.db.QueryRow(ctx, `SELECT epoch_period FROM accounts WHERE account_id = $1`, accountID).Scan(&secs)
+	if err != nil {
+		return 0, errors.Wrap(err, "loading account epoch period")
+	}
+	return time.Duration(secs) * time.Second, nil
+}
+
+func validEpochPeriod(period time.Duration) bool {
+	return period >= 0 && period%time.Second == 0
+}
@@ -0,0 +1,72 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/protocol/state"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestEpochControlPrograms(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	_, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, 1500*time.Millisecond, "")
+	if errors.Root(err) != ErrBadEpochPeriod {
+		t.Errorf("create with fractional epoch period: got error %v want %v", err, ErrBadEpochPeriod)
+	}
+
+	const period = 24 * time.Hour
+	acc, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub}, 1, "", nil, period, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if acc.EpochPeriod != period {
+		t.Errorf("account epoch period = %s want %s", acc.EpochPeriod, period)
+	}
+
+	before := epoch(period, time.Now())
+	cp, err := m.createControlProgram(ctx, acc.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if cp.epoch == nil || (*cp.epoch != before && *cp.epoch != before+1) {
+		t.Fatalf("control program epoch = %v want %d", cp.epoch, before)
+	}
+	err = m.insertAccountControlProgram(ctx, cp)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// The control program's keys are derived through its epoch.
+	path := controlProgramPath(acc.Signer, cp.epoch, cp.keyIndex)
+	pubs := chainkd.XPubKeys(chainkd.DeriveXPubs(acc.XPubs, path))
+	want, err := vmutil.P2SPMultiSigProgram(pubs, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(cp.controlProgram, want) {
+		t.Errorf("control program = %x want %x", cp.controlProgram, want)
+	}
+
+	// Payments to it are recognized with its epoch, as
+	// they will be after later epochs begin.
+	out := bc.NewTxOutput(bc.AssetID{}, 1, cp.controlProgram, nil)
+	got, err := m.loadAccountInfo(ctx, []*state.Output{{TxOutput: *out}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || got[0].AccountID != acc.ID || got[0].epoch == nil || *got[0].epoch != *cp.epoch {
+		t.Errorf("loadAccountInfo = %+v want account %s epoch %d", got, acc.ID, *cp.epoch)
+	}
+}
@@ -0,0 +1,208 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrFrozen is returned when spending from, or creating a
+	// control program for, a frozen account, or freezing it again.
+	ErrFrozen = errors.New("account is frozen")
+
+	// ErrNotFrozen is returned by Unfreeze when the
+	// account is not frozen.
+	ErrNotFrozen = errors.New("account is not frozen")
+
+	// ErrBadFreezeReason is returned by Freeze when the
+	// reason is not one of FreezeReasons.
+	ErrBadFreezeReason = errors.New("invalid freeze reason")
+
+	// ErrNoActor is returned when freezing or unfreezing
+	// an account without naming who is doing it.
+	ErrNoActor = errors.New("actor is required")
+)
+
+// FreezeReasons lists the reason codes accepted by Freeze.
+var FreezeReasons = []string{
+	"legal_hold",
+	"court_order",
+	"regulatory",
+	"fraud_investigation",
+	"other",
+}
+
+// Freeze records a period during which an account is frozen.
+// A frozen account keeps receiving payments, which are indexed
+// as usual, but its funds can't be spent and no new control
+// programs can be created for it. UnfrozenBy and UnfrozenAt
+// are nil while the freeze is in effect.
+type Freeze struct {
+	ID         string     `json:"id"`
+	AccountID  string     `json:"account_id"`
+	Reason     string     `json:"reason"`
+	FrozenBy   string     `json:"frozen_by"`
+	FrozenAt   time.Time  `json:"frozen_at"`
+	UnfrozenBy *string    `json:"unfrozen_by"`
+	UnfrozenAt *time.Time `json:"unfrozen_at"`
+}
+
+// Freeze freezes an account. Actor identifies the person or
+// system responsible and is recorded with the freeze.
+// It cancels the account's outstanding reservations, so
+// transactions built before the freeze can't hold on to its
+// outputs; those transactions are refused by CheckFrozenSpends
+// if they're signed or submitted anyway. Like Archive, it must
+// be called in the process holding the reservations.
+func (m *Manager) Freeze(ctx context.Context, accountID, reason, actor string) (*Freeze, error) {
+	if !validFreezeReason(reason) {
+		return nil, errors.WithDetailf(ErrBadFreezeReason, "reason %q", reason)
+	}
+	if actor == "" {
+		return nil, ErrNoActor
+	}
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		INSERT INTO account_freezes (account_id, reason, frozen_by) VALUES ($1, $2, $3)
+		ON CONFLICT (account_id) WHERE unfrozen_at IS NULL DO NOTHING
+		RETURNING id, frozen_at
+	`
+	f := &Freeze{AccountID: accountID, Reason: reason, FrozenBy: actor}
+	var id int64
+	err = m.db.QueryRow(ctx, q, accountID, reason, actor).Scan(&id, &f.FrozenAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrFrozen, "account %s", accountID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting account freeze")
+	}
+	f.ID = strconv.FormatInt(id, 10)
+
+	err = m.drainReservations(ctx, accountID, true)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Unfreeze ends the freeze in effect on an account.
+// Callers must restrict it to privileged users.
+func (m *Manager) Unfreeze(ctx context.Context, accountID, actor string) (*Freeze, error) {
+	if actor == "" {
+		return nil, ErrNoActor
+	}
+	const q = `
+		UPDATE account_freezes SET unfrozen_by = $2, unfrozen_at = now()
+		WHERE account_id = $1 AND unfrozen_at IS NULL
+		RETURNING id, reason, frozen_by, frozen_at, unfrozen_at
+	`
+	f := &Freeze{AccountID: accountID, UnfrozenBy: &actor}
+	var (
+		id         int64
+		unfrozenAt time.Time
+	)
+	err := m.db.QueryRow(ctx, q, accountID, actor).Scan(&id, &f.Reason, &f.FrozenBy, &f.FrozenAt, &unfrozenAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrNotFrozen, "account %s", accountID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "updating account freeze")
+	}
+	f.ID = strconv.FormatInt(id, 10)
+	f.UnfrozenAt = &unfrozenAt
+	return f, nil
+}
+
+// ListFreezes returns up to limit freezes, current and past,
+// oldest first, starting after the one with ID after.
+// If accountID is not empty, it returns only that account's.
+func (m *Manager) ListFreezes(ctx context.Context, accountID string, after int64, limit int) ([]*Freeze, error) {
+	const q = `
+		SELECT id, account_id, reason, frozen_by, frozen_at, unfrozen_by, unfrozen_at
+		FROM account_freezes
+		WHERE ($1 = '' OR account_id = $1) AND id > $2
+		ORDER BY id LIMIT $3
+	`
+	var freezes []*Freeze
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, after, limit, func(
+		id int64, accountID, reason, frozenBy string, frozenAt time.Time,
+		unfrozenBy stdsql.NullString, unfrozenAt pq.NullTime,
+	) {
+		f := &Freeze{
+			ID:        strconv.FormatInt(id, 10),
+			AccountID: accountID,
+			Reason:    reason,
+			FrozenBy:  frozenBy,
+			FrozenAt:  frozenAt,
+		}
+		if unfrozenBy.Valid {
+			f.UnfrozenBy = &unfrozenBy.String
+		}
+		if unfrozenAt.Valid {
+			f.UnfrozenAt = &unfrozenAt.Time
+		}
+		freezes = append(freezes, f)
+	})
+	return freezes, errors.Wrap(err, "listing account freezes")
+}
+
+// checkFrozen returns ErrFrozen if the account is frozen.
+func (m *Manager) checkFrozen(ctx context.Context, accountID string) error {
+	const q = `SELECT EXISTS(SELECT 1 FROM account_freezes WHERE account_id = $1 AND unfrozen_at IS NULL)`
+	var frozen bool
+	err := m.db.QueryRow(ctx, q, accountID).Scan(&frozen)
+	if err != nil {
+		return errors.Wrap(err, "checking account freeze")
+	}
+	if frozen {
+		return errors.WithDetailf(ErrFrozen, "account %s", accountID)
+	}
+	return nil
+}
+
+// CheckFrozenSpends returns ErrFrozen if tx spends an output
+// of a frozen account. Spend actions already refuse frozen
+// accounts, but a transaction built before its account was
+// frozen must be stopped when it is signed or submitted.
+func (m *Manager) CheckFrozenSpends(ctx context.Context, tx *bc.Tx) error {
+	txHashes, indexes := prevoutDBKeys(tx)
+	if len(txHashes) == 0 {
+		return nil
+	}
+	const q = `
+		SELECT u.account_id FROM account_utxos u
+		JOIN account_freezes f ON f.account_id = u.account_id AND f.unfrozen_at IS NULL
+		WHERE (u.tx_hash, u.index) IN (SELECT unnest($1::bytea[]), unnest($2::integer[]))
+		LIMIT 1
+	`
+	var accountID string
+	err := m.db.QueryRow(ctx, q, txHashes, indexes).Scan(&accountID)
+	if err == stdsql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "checking account freezes")
+	}
+	return errors.WithDetailf(ErrFrozen, "account %s", accountID)
+}
+
+func validFreezeReason(reason string) bool {
+	for _, r := range FreezeReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
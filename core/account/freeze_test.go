@@ -0,0 +1,67 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestFreeze(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", nil)
+
+	_, err := m.Freeze(ctx, acc.ID, "bogus", "alice")
+	if errors.Root(err) != ErrBadFreezeReason {
+		t.Errorf("freeze with bad reason: got error %v want %v", err, ErrBadFreezeReason)
+	}
+	_, err = m.Freeze(ctx, acc.ID, "legal_hold", "")
+	if errors.Root(err) != ErrNoActor {
+		t.Errorf("freeze with no actor: got error %v want %v", err, ErrNoActor)
+	}
+
+	f, err := m.Freeze(ctx, acc.ID, "legal_hold", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if f.UnfrozenAt != nil {
+		t.Errorf("new freeze has unfrozen_at %v", f.UnfrozenAt)
+	}
+	_, err = m.Freeze(ctx, acc.ID, "other", "alice")
+	if errors.Root(err) != ErrFrozen {
+		t.Errorf("second freeze: got error %v want %v", err, ErrFrozen)
+	}
+	_, err = m.CreateControlProgram(ctx, acc.ID, false)
+	if errors.Root(err) != ErrFrozen {
+		t.Errorf("create control program: got error %v want %v", err, ErrFrozen)
+	}
+
+	f, err = m.Unfreeze(ctx, acc.ID, "bob")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if f.Reason != "legal_hold" || f.FrozenBy != "alice" || f.UnfrozenBy == nil || *f.UnfrozenBy != "bob" {
+		t.Errorf("unfreeze = %+v", f)
+	}
+	_, err = m.Unfreeze(ctx, acc.ID, "bob")
+	if errors.Root(err) != ErrNotFrozen {
+		t.Errorf("second unfreeze: got error %v want %v", err, ErrNotFrozen)
+	}
+	_, err = m.CreateControlProgram(ctx, acc.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	freezes, err := m.ListFreezes(ctx, acc.ID, 0, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(freezes) != 1 || freezes[0].ID != f.ID {
+		t.Errorf("ListFreezes = %+v want [%+v]", freezes, f)
+	}
+}
@@ -21,8 +21,12 @@ const PinName = "account"
 // for indexing and retrieval.
 // If the Core is configured not to provide search services,
 // SaveAnnotatedAccount can be a no-op.
+//
+// UpdateAccountAnnotations is called when an account's tags
+// change, to annotate indexed transactions again.
 type Saver interface {
 	SaveAnnotatedAccount(context.Context, string, map[string]interface{}) error
+	UpdateAccountAnnotations(ctx context.Context, accountID string, tags map[string]interface{}) error
 }
 
 func (m *Manager) indexAnnotatedAccount(ctx context.Context, a *Account) error {
@@ -42,19 +46,32 @@ func (m *Manager) indexAnnotatedAccount(ctx context.Context, a *Account) error {
 			"account_derivation_path": jsonPath,
 		})
 	}
-	return m.indexer.SaveAnnotatedAccount(ctx, a.ID, map[string]interface{}{
-		"id":     a.ID,
-		"alias":  a.Alias,
-		"keys":   keys,
-		"tags":   a.Tags,
-		"quorum": a.Quorum,
-	})
+	annotated := map[string]interface{}{
+		"id":          a.ID,
+		"alias":       a.Alias,
+		"keys":        keys,
+		"tags":        a.Tags,
+		"quorum":      a.Quorum,
+		"is_archived": "no",
+	}
+	if a.Archived {
+		annotated["is_archived"] = "yes"
+	}
+	if a.EpochPeriod > 0 {
+		annotated["epoch_period"] = json.Duration{Duration: a.EpochPeriod}
+	}
+	if a.ParentID != "" {
+		annotated["parent_id"] = a.ParentID
+	}
+	return m.indexer.SaveAnnotatedAccount(ctx, a.ID, annotated)
 }
 
 type output struct {
 	state.Output
 	AccountID string
 	keyIndex  uint64
+	epoch     *uint64
+	keyGen    uint64
 }
 
 func (m *Manager) ProcessBlocks(ctx context.Context) {
@@ -88,6 +105,18 @@ func (m *Manager) indexAccountUTXOs(ctx context.Context, b *bc.Block) error {
 		return errors.Wrap(err, "upserting confirmed account utxos")
 	}
 
+	err = m.recordFirstUses(ctx, b, accOuts)
+	if err != nil {
+		return errors.Wrap(err, "recording first use of control programs")
+	}
+
+	// Look for reserved UTXOs spent by transactions
+	// this core didn't submit before they're deleted.
+	err = m.detectConflicts(ctx, b)
+	if err != nil {
+		return errors.Wrap(err, "detecting reservation conflicts")
+	}
+
 	// Delete consumed account UTXOs.
 	deltxhash, delindex := prevoutDBKeys(b.Transactions...)
 	const delQ = `
@@ -130,16 +159,18 @@ func (m *Manager) loadAccountInfo(ctx context.Context, outs []*state.Output) ([]
 	result := make([]*output, 0, len(outs))
 
 	const q = `
-		SELECT signer_id, key_index, control_program
+		SELECT signer_id, key_index, epoch, key_generation, control_program
 		FROM account_control_programs
 		WHERE control_program IN (SELECT unnest($1::bytea[]))
 	`
-	err := pg.ForQueryRows(ctx, m.db, q, scripts, func(accountID string, keyIndex uint64, program []byte) {
+	err := pg.ForQueryRows(ctx, m.db, q, scripts, func(accountID string, keyIndex uint64, epoch *uint64, keyGen uint64, program []byte) {
 		for _, out := range outsByScript[string(program)] {
 			newOut := &output{
 				Output:    *out,
 				AccountID: accountID,
 				keyIndex:  keyIndex,
+				epoch:     epoch,
+				keyGen:    keyGen,
 			}
 			result = append(result, newOut)
 		}
@@ -163,6 +194,8 @@ func (m *Manager) upsertConfirmedAccountOutputs(ctx context.Context, outs []*out
 		accountID pq.StringArray
 		cpIndex   pq.Int64Array
 		program   pq.ByteaArray
+		cpEpoch   pq.Int64Array // -1 for no epoch
+		cpGen     pq.Int64Array
 	)
 	for _, out := range outs {
 		txHash = append(txHash, out.Outpoint.Hash[:])
@@ -172,13 +205,20 @@ func (m *Manager) upsertConfirmedAccountOutputs(ctx context.Context, outs []*out
 		accountID = append(accountID, out.AccountID)
 		cpIndex = append(cpIndex, int64(out.keyIndex))
 		program = append(program, out.ControlProgram)
+		if out.epoch != nil {
+			cpEpoch = append(cpEpoch, int64(*out.epoch))
+		} else {
+			cpEpoch = append(cpEpoch, -1)
+		}
+		cpGen = append(cpGen, int64(out.keyGen))
 	}
 
 	const q = `
 		INSERT INTO account_utxos (tx_hash, index, asset_id, amount, account_id, control_program_index,
-			control_program, confirmed_in)
+			control_program, confirmed_in, control_program_epoch, control_program_generation)
 		SELECT unnest($1::bytea[]), unnest($2::bigint[]), unnest($3::bytea[]),  unnest($4::bigint[]),
-			   unnest($5::text[]), unnest($6::bigint[]), unnest($7::bytea[]), $8
+			   unnest($5::text[]), unnest($6::bigint[]), unnest($7::bytea[]), $8,
+			   NULLIF(unnest($9::bigint[]), -1), unnest($10::integer[])
 		ON CONFLICT (tx_hash, index) DO NOTHING
 	`
 	_, err := m.db.Exec(ctx, q,
@@ -190,6 +230,47 @@ func (m *Manager) upsertConfirmedAccountOutputs(ctx context.Context, outs []*out
 		cpIndex,
 		program,
 		block.Height,
+		cpEpoch,
+		cpGen,
 	)
 	return errors.Wrap(err)
 }
+
+// recordFirstUses records, for each account control program
+// that outs pay to for the first time, the transaction in b
+// that first pays to it. Control programs already used keep
+// their first use, so processing a block again changes nothing.
+func (m *Manager) recordFirstUses(ctx context.Context, b *bc.Block, outs []*output) error {
+	if len(outs) == 0 {
+		return nil
+	}
+	ours := make(map[string]bool, len(outs))
+	for _, out := range outs {
+		ours[string(out.ControlProgram)] = true
+	}
+
+	var (
+		programs pq.ByteaArray
+		txHashes pq.ByteaArray
+	)
+	for _, tx := range b.Transactions {
+		for _, out := range tx.Outputs {
+			prog := string(out.ControlProgram)
+			if !ours[prog] {
+				continue
+			}
+			delete(ours, prog) // keep the earliest in the block
+			programs = append(programs, out.ControlProgram)
+			txHashes = append(txHashes, tx.Hash[:])
+		}
+	}
+
+	const q = `
+		UPDATE account_control_programs acp
+		SET first_used_tx = u.tx_hash, first_used_block_height = $3
+		FROM (SELECT unnest($1::bytea[]) AS control_program, unnest($2::bytea[]) AS tx_hash) u
+		WHERE acp.control_program = u.control_program AND acp.first_used_tx IS NULL
+	`
+	_, err := m.db.Exec(ctx, q, programs, txHashes, b.Height)
+	return errors.Wrap(err)
+}
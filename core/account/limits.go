@@ -0,0 +1,322 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrSpendingLimit is returned when building or submitting
+	// a transaction that would take an account over one of
+	// its spending limits.
+	ErrSpendingLimit = errors.New("account spending limit exceeded")
+
+	// ErrBadSpendingLimit is returned by SetSpendingLimit when
+	// the window is not a positive whole number of seconds,
+	// or the maximum amount is too large.
+	ErrBadSpendingLimit = errors.New("invalid account spending limit")
+)
+
+// SpendingLimit limits the amount of an asset an account can
+// spend in any period of length Window. Spent is the amount
+// counted against the limit in the window ending now.
+//
+// An account's spending is its net outflow of the asset in
+// each transaction: what it spends less any change paid back
+// to it. Transactions count from when they're submitted by
+// this core. Only spending of assets with a limit is recorded,
+// so a new limit starts with nothing spent.
+type SpendingLimit struct {
+	AccountID string             `json:"account_id"`
+	AssetID   bc.AssetID         `json:"asset_id"`
+	MaxAmount uint64             `json:"max_amount"`
+	Window    chainjson.Duration `json:"window"`
+	Spent     uint64             `json:"spent"`
+}
+
+// SetSpendingLimit sets the limit on the account's spending
+// of the asset, replacing any limit already set. Callers
+// must restrict it to privileged users.
+func (m *Manager) SetSpendingLimit(ctx context.Context, accountID string, assetID bc.AssetID, maxAmount uint64, window time.Duration) (*SpendingLimit, error) {
+	if window <= 0 || window%time.Second != 0 {
+		return nil, errors.WithDetailf(ErrBadSpendingLimit, "window %s", window)
+	}
+	if maxAmount > math.MaxInt64 {
+		return nil, errors.WithDetailf(ErrBadSpendingLimit, "max amount %d", maxAmount)
+	}
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		INSERT INTO account_spending_limits (account_id, asset_id, max_amount, window_secs)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (account_id, asset_id) DO UPDATE SET max_amount = $3, window_secs = $4
+	`
+	_, err = m.db.Exec(ctx, q, accountID, assetID, int64(maxAmount), int64(window/time.Second))
+	if err != nil {
+		return nil, errors.Wrap(err, "setting account spending limit")
+	}
+	spent, err := m.spentInWindow(ctx, accountID, assetID, window)
+	if err != nil {
+		return nil, err
+	}
+	return &SpendingLimit{
+		AccountID: accountID,
+		AssetID:   assetID,
+		MaxAmount: maxAmount,
+		Window:    chainjson.Duration{Duration: window},
+		Spent:     spent,
+	}, nil
+}
+
+// DeleteSpendingLimit removes the limit, if any, on the
+// account's spending of the asset. Callers must restrict
+// it to privileged users.
+func (m *Manager) DeleteSpendingLimit(ctx context.Context, accountID string, assetID bc.AssetID) error {
+	const q = `
+		WITH deleted AS (
+			DELETE FROM account_spending_limits WHERE account_id = $1 AND asset_id = $2
+		)
+		DELETE FROM account_spends WHERE account_id = $1 AND asset_id = $2
+	`
+	_, err := m.db.Exec(ctx, q, accountID, assetID)
+	return errors.Wrap(err, "deleting account spending limit")
+}
+
+// ListSpendingLimits returns the account's spending
+// limits, with the amount spent against each.
+func (m *Manager) ListSpendingLimits(ctx context.Context, accountID string) ([]*SpendingLimit, error) {
+	const q = `
+		SELECT l.asset_id, l.max_amount, l.window_secs, COALESCE(SUM(s.amount), 0)::bigint
+		FROM account_spending_limits l
+		LEFT JOIN account_spends s ON s.account_id = l.account_id AND s.asset_id = l.asset_id
+			AND s.spent_at > now() - l.window_secs * interval '1 second'
+		WHERE l.account_id = $1
+		GROUP BY l.asset_id, l.max_amount, l.window_secs
+		ORDER BY l.asset_id
+	`
+	var limits []*SpendingLimit
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, func(assetID bc.AssetID, maxAmount, windowSecs, spent int64) {
+		limits = append(limits, &SpendingLimit{
+			AccountID: accountID,
+			AssetID:   assetID,
+			MaxAmount: uint64(maxAmount),
+			Window:    chainjson.Duration{Duration: time.Duration(windowSecs) * time.Second},
+			Spent:     uint64(spent),
+		})
+	})
+	return limits, errors.Wrap(err, "listing account spending limits")
+}
+
+// RecordSpends counts each account's spending in tx against its
+// limits. It returns ErrSpendingLimit, and records nothing, if
+// tx would take an account over one. Recording the same tx again
+// has no effect. Submitters should call it before sending tx to
+// the generator, and ReleaseSpends if tx is rejected.
+//
+// Checks in one process are serialized, but submissions through
+// different processes may together exceed a limit.
+func (m *Manager) RecordSpends(ctx context.Context, tx *bc.Tx) error {
+	spends, err := m.txSpends(ctx, tx)
+	if err != nil || len(spends) == 0 {
+		return err
+	}
+
+	m.spendMu.Lock()
+	defer m.spendMu.Unlock()
+
+	var recorded bool
+	err = m.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM account_spends WHERE tx_hash = $1)`, tx.Hash).Scan(&recorded)
+	if err != nil {
+		return errors.Wrap(err, "checking recorded spends")
+	}
+	if recorded {
+		return nil
+	}
+
+	var (
+		accountIDs []string
+		assetIDs   pq.ByteaArray
+		amounts    pq.Int64Array
+	)
+	for src, amount := range spends {
+		limit, err := m.spendingLimit(ctx, src.AccountID, src.AssetID)
+		if err != nil {
+			return err
+		}
+		if limit == nil {
+			continue
+		}
+		err = limit.check(amount)
+		if err != nil {
+			return err
+		}
+		accountIDs = append(accountIDs, src.AccountID)
+		assetIDs = append(assetIDs, src.AssetID[:])
+		amounts = append(amounts, int64(amount))
+	}
+	if len(accountIDs) == 0 {
+		return nil
+	}
+
+	const q = `
+		INSERT INTO account_spends (tx_hash, account_id, asset_id, amount)
+		SELECT $1, unnest($2::text[]), unnest($3::bytea[]), unnest($4::bigint[])
+		ON CONFLICT DO NOTHING
+	`
+	_, err = m.db.Exec(ctx, q, tx.Hash, pq.StringArray(accountIDs), assetIDs, amounts)
+	return errors.Wrap(err, "recording account spends")
+}
+
+// ReleaseSpends removes the spending recorded for the
+// transaction with the given hash, for use when it is
+// rejected and can never be confirmed.
+func (m *Manager) ReleaseSpends(ctx context.Context, txHash bc.Hash) error {
+	_, err := m.db.Exec(ctx, `DELETE FROM account_spends WHERE tx_hash = $1`, txHash)
+	return errors.Wrap(err, "releasing account spends")
+}
+
+// checkSpendingLimit returns ErrSpendingLimit if spending amount
+// of the asset would take the account over its limit. It is an
+// early check for transaction builders; RecordSpends is the one
+// that counts.
+func (m *Manager) checkSpendingLimit(ctx context.Context, accountID string, assetID bc.AssetID, amount uint64) error {
+	limit, err := m.spendingLimit(ctx, accountID, assetID)
+	if err != nil || limit == nil {
+		return err
+	}
+	return limit.check(amount)
+}
+
+// check returns ErrSpendingLimit if spending amount
+// more would exceed the limit.
+func (l *SpendingLimit) check(amount uint64) error {
+	if l.Spent+amount <= l.MaxAmount {
+		return nil
+	}
+	var left uint64
+	if l.Spent < l.MaxAmount {
+		left = l.MaxAmount - l.Spent
+	}
+	return errors.WithDetailf(ErrSpendingLimit, "account %s has %d of asset %s left to spend in %s",
+		l.AccountID, left, l.AssetID, l.Window.Duration)
+}
+
+// spendingLimit returns the account's limit on spending the
+// asset, with the amount spent against it, or nil if it
+// has none.
+func (m *Manager) spendingLimit(ctx context.Context, accountID string, assetID bc.AssetID) (*SpendingLimit, error) {
+	const q = `SELECT max_amount, window_secs FROM account_spending_limits WHERE account_id = $1 AND asset_id = $2`
+	var maxAmount, windowSecs int64
+	err := m.db.QueryRow(ctx, q, accountID, assetID).Scan(&maxAmount, &windowSecs)
+	if err == stdsql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account spending limit")
+	}
+	window := time.Duration(windowSecs) * time.Second
+	spent, err := m.spentInWindow(ctx, accountID, assetID, window)
+	if err != nil {
+		return nil, err
+	}
+	return &SpendingLimit{
+		AccountID: accountID,
+		AssetID:   assetID,
+		MaxAmount: uint64(maxAmount),
+		Window:    chainjson.Duration{Duration: window},
+		Spent:     spent,
+	}, nil
+}
+
+func (m *Manager) spentInWindow(ctx context.Context, accountID string, assetID bc.AssetID, window time.Duration) (uint64, error) {
+	const q = `
+		SELECT COALESCE(SUM(amount), 0)::bigint FROM account_spends
+		WHERE account_id = $1 AND asset_id = $2 AND spent_at > now() - $3 * interval '1 second'
+	`
+	var spent int64
+	err := m.db.QueryRow(ctx, q, accountID, assetID, int64(window/time.Second)).Scan(&spent)
+	return uint64(spent), errors.Wrap(err, "summing account spends")
+}
+
+// txSpends returns the net amount of each asset each
+// local account spends in tx, for those that spend any.
+func (m *Manager) txSpends(ctx context.Context, tx *bc.Tx) (map[source]uint64, error) {
+	var (
+		hashes  pq.ByteaArray
+		indexes pq.Int64Array
+		progs   pq.ByteaArray
+	)
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			continue
+		}
+		o := in.Outpoint()
+		hashes = append(hashes, o.Hash[:])
+		indexes = append(indexes, int64(o.Index))
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	for _, out := range tx.Outputs {
+		progs = append(progs, out.ControlProgram)
+	}
+
+	out := make(map[source]int64)
+	const inputsQ = `
+		SELECT account_id, asset_id, amount FROM account_utxos
+		WHERE (tx_hash, index) IN (SELECT unnest($1::bytea[]), unnest($2::integer[]))
+	`
+	err := pg.ForQueryRows(ctx, m.db, inputsQ, hashes, indexes, func(accountID string, assetID bc.AssetID, amount int64) {
+		out[source{AccountID: accountID, AssetID: assetID}] += amount
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading spent account outputs")
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	// Subtract change: payments back to the
+	// accounts spending the same asset.
+	accountByProg := make(map[string]string)
+	const progsQ = `
+		SELECT signer_id, control_program FROM account_control_programs
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
+	`
+	err = pg.ForQueryRows(ctx, m.db, progsQ, progs, func(accountID string, prog []byte) {
+		accountByProg[string(prog)] = accountID
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account control programs")
+	}
+	for _, o := range tx.Outputs {
+		accountID, ok := accountByProg[string(o.ControlProgram)]
+		if !ok {
+			continue
+		}
+		src := source{AccountID: accountID, AssetID: o.AssetID}
+		if _, ok := out[src]; ok {
+			out[src] -= int64(o.Amount)
+		}
+	}
+
+	spends := make(map[source]uint64)
+	for src, amount := range out {
+		if amount > 0 {
+			spends[src] = uint64(amount)
+		}
+	}
+	return spends, nil
+}
@@ -0,0 +1,117 @@
+package account_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/generator"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txbuilder"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestSpendingLimits(t *testing.T) {
+	var (
+		_, db    = pgtest.NewDB(t, pgtest.SchemaPath)
+		ctx      = context.Background()
+		c        = prottest.NewChain(t)
+		g        = generator.New(c, nil, db)
+		pinStore = pin.NewStore(db)
+		accounts = account.NewManager(db, c, pinStore)
+		assets   = asset.NewRegistry(db, c, pinStore)
+		indexer  = query.NewIndexer(db, c, pinStore)
+
+		accID   = coretest.CreateAccount(ctx, t, accounts, "", nil)
+		assetID = coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+		_       = coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 10, accID)
+	)
+
+	coretest.CreatePins(ctx, t, pinStore)
+	assets.IndexAssets(indexer)
+	accounts.IndexAccounts(indexer)
+	go accounts.ProcessBlocks(ctx)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	_, err := accounts.SetSpendingLimit(ctx, accID, assetID, 5, 1500*time.Millisecond)
+	if errors.Root(err) != account.ErrBadSpendingLimit {
+		t.Errorf("set limit with fractional window: got error %v want %v", err, account.ErrBadSpendingLimit)
+	}
+	_, err = accounts.SetSpendingLimit(ctx, accID, assetID, 5, time.Hour)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	build := func(amount uint64) (*bc.Tx, error) {
+		action := accounts.NewSpendAction(bc.AssetAmount{AssetID: assetID, Amount: amount}, accID, nil, nil)
+		var builder txbuilder.TemplateBuilder
+		err := action.Build(ctx, &builder)
+		if err != nil {
+			return nil, err
+		}
+		_, tx, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+		return bc.NewTx(*tx), nil
+	}
+
+	_, err = build(6)
+	if errors.Root(err) != account.ErrSpendingLimit {
+		t.Errorf("build over limit: got error %v want %v", err, account.ErrSpendingLimit)
+	}
+
+	// Spending 4 of the 10-unit output leaves 6 in change,
+	// which doesn't count against the limit.
+	tx, err := build(4)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = accounts.RecordSpends(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = accounts.RecordSpends(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	limits, err := accounts.ListSpendingLimits(ctx, accID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(limits) != 1 || limits[0].Spent != 4 || limits[0].MaxAmount != 5 {
+		t.Fatalf("limits = %+v want one with 4 of 5 spent", limits)
+	}
+
+	// Another 2 would take the account over the limit.
+	tx2 := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  tx.Inputs,
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 8, tx.Outputs[0].ControlProgram, nil)},
+	})
+	err = accounts.RecordSpends(ctx, tx2)
+	if errors.Root(err) != account.ErrSpendingLimit {
+		t.Errorf("record over limit: got error %v want %v", err, account.ErrSpendingLimit)
+	}
+
+	err = accounts.ReleaseSpends(ctx, tx.Hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	limits, err = accounts.ListSpendingLimits(ctx, accID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(limits) != 1 || limits[0].Spent != 0 {
+		t.Errorf("limits after release = %+v want nothing spent", limits)
+	}
+}
@@ -0,0 +1,115 @@
+package account
+
+import (
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// PaymentURIScheme is the URI scheme of payment requests.
+const PaymentURIScheme = "chain"
+
+// ErrBadPaymentURI is returned by ParsePaymentURI
+// when a payment URI is malformed.
+var ErrBadPaymentURI = errors.New("invalid payment URI")
+
+// A PaymentRequest asks a payer to pay Amount units of
+// AssetID to ControlProgram, on the blockchain identified
+// by BlockchainID, before ExpiresAt. Only ControlProgram is
+// required; a zero field leaves that choice to the payer.
+//
+// Its URI form is
+//
+//	chain:<control program, hex>?network=<blockchain ID>&asset=<asset ID>&amount=<amount>&expires=<RFC 3339 time>&memo=<text>
+//
+// with the query parameters in any order. Parsers ignore
+// parameters they don't know, so new ones can be added.
+type PaymentRequest struct {
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	BlockchainID   *bc.Hash           `json:"blockchain_id,omitempty"`
+	AssetID        *bc.AssetID        `json:"asset_id,omitempty"`
+	Amount         uint64             `json:"amount,omitempty"`
+	ExpiresAt      *time.Time         `json:"expires_at,omitempty"`
+	Memo           string             `json:"memo,omitempty"`
+}
+
+// URI returns the payment URI of r.
+func (r *PaymentRequest) URI() string {
+	v := make(url.Values)
+	if r.BlockchainID != nil {
+		v.Set("network", r.BlockchainID.String())
+	}
+	if r.AssetID != nil {
+		v.Set("asset", r.AssetID.String())
+	}
+	if r.Amount > 0 {
+		v.Set("amount", strconv.FormatUint(r.Amount, 10))
+	}
+	if r.ExpiresAt != nil {
+		v.Set("expires", r.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if r.Memo != "" {
+		v.Set("memo", r.Memo)
+	}
+	u := url.URL{
+		Scheme:   PaymentURIScheme,
+		Opaque:   hex.EncodeToString(r.ControlProgram),
+		RawQuery: strings.Replace(v.Encode(), "+", "%20", -1),
+	}
+	return u.String()
+}
+
+// ParsePaymentURI parses the payment URI s.
+func ParsePaymentURI(s string) (*PaymentRequest, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadPaymentURI, err.Error())
+	}
+	if u.Scheme != PaymentURIScheme || u.Opaque == "" {
+		return nil, errors.WithDetailf(ErrBadPaymentURI, "want %s:<control program>", PaymentURIScheme)
+	}
+	prog, err := hex.DecodeString(u.Opaque)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadPaymentURI, "control program is not hex")
+	}
+	v, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadPaymentURI, err.Error())
+	}
+
+	r := &PaymentRequest{ControlProgram: prog, Memo: v.Get("memo")}
+	if s := v.Get("network"); s != "" {
+		r.BlockchainID = new(bc.Hash)
+		err = r.BlockchainID.UnmarshalText([]byte(s))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadPaymentURI, "invalid network %q", s)
+		}
+	}
+	if s := v.Get("asset"); s != "" {
+		r.AssetID = new(bc.AssetID)
+		err = r.AssetID.UnmarshalText([]byte(s))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadPaymentURI, "invalid asset %q", s)
+		}
+	}
+	if s := v.Get("amount"); s != "" {
+		r.Amount, err = strconv.ParseUint(s, 10, 64)
+		if err != nil || r.Amount == 0 {
+			return nil, errors.WithDetailf(ErrBadPaymentURI, "invalid amount %q", s)
+		}
+	}
+	if s := v.Get("expires"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadPaymentURI, "invalid expiration %q", s)
+		}
+		r.ExpiresAt = &t
+	}
+	return r, nil
+}
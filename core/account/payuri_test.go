@@ -0,0 +1,58 @@
+package account
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestPaymentURI(t *testing.T) {
+	network := bc.Hash{1}
+	asset := bc.AssetID{2}
+	exp := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	r := &PaymentRequest{
+		ControlProgram: []byte{0x76, 0xa9},
+		BlockchainID:   &network,
+		AssetID:        &asset,
+		Amount:         150,
+		ExpiresAt:      &exp,
+		Memo:           "invoice 12 & tip",
+	}
+
+	uri := r.URI()
+	got, err := ParsePaymentURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, r) {
+		t.Errorf("ParsePaymentURI(%q) = %+v want %+v", uri, got, r)
+	}
+
+	got, err = ParsePaymentURI("chain:76a9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PaymentRequest{ControlProgram: []byte{0x76, 0xa9}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("minimal URI = %+v want %+v", got, want)
+	}
+
+	cases := []string{
+		"bitcoin:76a9",
+		"chain:",
+		"chain:zz",
+		"chain:76a9?amount=0",
+		"chain:76a9?amount=-1",
+		"chain:76a9?asset=00",
+		"chain:76a9?expires=tomorrow",
+	}
+	for _, c := range cases {
+		_, err := ParsePaymentURI(c)
+		if errors.Root(err) != ErrBadPaymentURI {
+			t.Errorf("ParsePaymentURI(%q) err = %v want %v", c, err, ErrBadPaymentURI)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package account
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+)
+
+// DefaultReceiverExpiry is how long receivers last
+// unless the core or the request sets otherwise.
+const DefaultReceiverExpiry = 30 * 24 * time.Hour
+
+// receiverCleanupGrace is how long after a receiver expires
+// ExpireReceivers waits before deleting it, so payments sent
+// just before it expired can still land and be recognized.
+const receiverCleanupGrace = 24 * time.Hour
+
+// ErrBadReceiverExpiry is returned when creating or extending
+// a receiver with an expiration time that has already passed.
+var ErrBadReceiverExpiry = errors.New("invalid receiver expiration")
+
+var (
+	receiversExpvar  = expvar.NewMap("receivers")
+	receiversDeleted = new(expvar.Int)
+	receiverCleanups = new(expvar.Int)
+)
+
+func init() {
+	receiversExpvar.Set("deleted", receiversDeleted)
+	receiversExpvar.Set("cleanups", receiverCleanups)
+}
+
+// A Receiver is a control program for receiving payments to an
+// account until it expires. Payments to a receiver after it
+// expires may not be recognized: ExpireReceivers deletes it
+// once no unspent outputs of the account use it.
+type Receiver struct {
+	AccountID      string             `json:"account_id"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+}
+
+// SetReceiverExpiry sets how long receivers created without
+// an expiration time last. By default, it is
+// DefaultReceiverExpiry.
+func (m *Manager) SetReceiverExpiry(d time.Duration) {
+	m.receiverExpiry = d
+}
+
+// CreateReceiver creates a receiver for the account, expiring
+// at expiresAt or, if it's zero, after the core's receiver
+// expiry. Like CreateControlProgram, it returns ErrFrozen if
+// the account is frozen, and ErrArchived if it's archived.
+func (m *Manager) CreateReceiver(ctx context.Context, accountID string, expiresAt time.Time) (*Receiver, error) {
+	if expiresAt.IsZero() {
+		d := m.receiverExpiry
+		if d <= 0 {
+			d = DefaultReceiverExpiry
+		}
+		expiresAt = time.Now().Add(d)
+	}
+	if !expiresAt.After(time.Now()) {
+		return nil, errors.WithDetailf(ErrBadReceiverExpiry, "expires at %s", expiresAt)
+	}
+	err := m.checkFrozen(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkArchived(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	cp, err := m.createControlProgram(ctx, accountID, false)
+	if err != nil {
+		return nil, err
+	}
+	var epoch *int64
+	if cp.epoch != nil {
+		e := int64(*cp.epoch)
+		epoch = &e
+	}
+	const q = `
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change, epoch, key_generation, expires_at)
+		VALUES ($1, $2, $3, FALSE, $4, $5, $6)
+	`
+	_, err = m.db.Exec(ctx, q, cp.accountID, cp.keyIndex, cp.controlProgram, epoch, int64(cp.keyGeneration), expiresAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting receiver")
+	}
+	return &Receiver{
+		AccountID:      cp.accountID,
+		ControlProgram: cp.controlProgram,
+		ExpiresAt:      expiresAt,
+	}, nil
+}
+
+// ExtendReceiver changes the expiration time of the account's
+// receiver with the given control program. It returns
+// pg.ErrUserInputNotFound if there's no such receiver, as when
+// it has expired and been deleted.
+func (m *Manager) ExtendReceiver(ctx context.Context, accountID string, controlProgram []byte, expiresAt time.Time) (*Receiver, error) {
+	if !expiresAt.After(time.Now()) {
+		return nil, errors.WithDetailf(ErrBadReceiverExpiry, "expires at %s", expiresAt)
+	}
+	const q = `
+		UPDATE account_control_programs SET expires_at = $3
+		WHERE signer_id = $1 AND control_program = $2 AND expires_at IS NOT NULL
+	`
+	res, err := m.db.Exec(ctx, q, accountID, controlProgram, expiresAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "extending receiver")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "extending receiver")
+	}
+	if n == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "account %s has no receiver %x", accountID, controlProgram)
+	}
+	return &Receiver{AccountID: accountID, ControlProgram: controlProgram, ExpiresAt: expiresAt}, nil
+}
+
+// ListReceivers returns up to limit of the account's receivers
+// that haven't expired, in the order they were created, starting
+// after the control program with key index after. It also returns
+// the key index of the last receiver, to continue from.
+func (m *Manager) ListReceivers(ctx context.Context, accountID string, after uint64, limit int) ([]*Receiver, uint64, error) {
+	const q = `
+		SELECT key_index, control_program, expires_at FROM account_control_programs
+		WHERE signer_id = $1 AND expires_at > now() AND key_index > $2
+		ORDER BY key_index LIMIT $3
+	`
+	var receivers []*Receiver
+	last := after
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, int64(after), limit, func(keyIndex int64, prog []byte, expiresAt time.Time) {
+		receivers = append(receivers, &Receiver{
+			AccountID:      accountID,
+			ControlProgram: prog,
+			ExpiresAt:      expiresAt,
+		})
+		last = uint64(keyIndex)
+	})
+	return receivers, last, errors.Wrap(err, "listing receivers")
+}
+
+// ExpireReceivers periodically deletes receivers that expired
+// more than a grace period ago, unless unspent outputs of the
+// account use them. It blocks until the context is canceled.
+func (m *Manager) ExpireReceivers(ctx context.Context, period time.Duration) {
+	ticks := time.Tick(period)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Messagef(ctx, "Deposed, ExpireReceivers exiting")
+			return
+		case <-ticks:
+			n, err := m.deleteExpiredReceivers(ctx)
+			if err != nil {
+				log.Error(ctx, err)
+				continue
+			}
+			receiversDeleted.Add(n)
+			receiverCleanups.Add(1)
+		}
+	}
+}
+
+func (m *Manager) deleteExpiredReceivers(ctx context.Context) (int64, error) {
+	const q = `
+		DELETE FROM account_control_programs acp
+		WHERE expires_at < now() - $1 * interval '1 second'
+			AND NOT EXISTS (SELECT 1 FROM account_utxos u WHERE u.control_program = acp.control_program)
+	`
+	res, err := m.db.Exec(ctx, q, int64(receiverCleanupGrace/time.Second))
+	if err != nil {
+		return 0, errors.Wrap(err, "deleting expired receivers")
+	}
+	n, err := res.RowsAffected()
+	return n, errors.Wrap(err, "deleting expired receivers")
+}
@@ -0,0 +1,78 @@
+package account
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestReceivers(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", nil)
+
+	_, err := m.CreateReceiver(ctx, acc.ID, time.Now().Add(-time.Minute))
+	if errors.Root(err) != ErrBadReceiverExpiry {
+		t.Errorf("create expired receiver: got error %v want %v", err, ErrBadReceiverExpiry)
+	}
+
+	m.SetReceiverExpiry(time.Hour)
+	r1, err := m.CreateReceiver(ctx, acc.ID, time.Time{})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if d := r1.ExpiresAt.Sub(time.Now()); d < 59*time.Minute || d > time.Hour {
+		t.Errorf("default receiver expires in %s want 1h", d)
+	}
+	r2, err := m.CreateReceiver(ctx, acc.ID, time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	got, last, err := m.ListReceivers(ctx, acc.ID, 0, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || string(got[0].ControlProgram) != string(r1.ControlProgram) {
+		t.Fatalf("first page = %+v want %x", got, r1.ControlProgram)
+	}
+	got, _, err = m.ListReceivers(ctx, acc.ID, last, 10)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || string(got[0].ControlProgram) != string(r2.ControlProgram) {
+		t.Fatalf("second page = %+v want %x", got, r2.ControlProgram)
+	}
+
+	// Expire r2 long enough ago to be cleaned up.
+	_, err = db.Exec(ctx, `UPDATE account_control_programs SET expires_at = now() - interval '2 days' WHERE control_program = $1`, []byte(r2.ControlProgram))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	n, err := m.deleteExpiredReceivers(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d receivers want 1", n)
+	}
+	_, err = m.ExtendReceiver(ctx, acc.ID, r2.ControlProgram, time.Now().Add(time.Hour))
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("extend deleted receiver: got error %v want %v", err, pg.ErrUserInputNotFound)
+	}
+
+	extended, err := m.ExtendReceiver(ctx, acc.ID, r1.ControlProgram, time.Now().Add(48*time.Hour))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if extended.ExpiresAt.Sub(r1.ExpiresAt) < 46*time.Hour {
+		t.Errorf("extended expiry %s want 2 days out", extended.ExpiresAt)
+	}
+}
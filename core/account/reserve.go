@@ -31,6 +31,11 @@ var (
 	// new change outputs will be created
 	// in sufficient amounts to satisfy the request.
 	ErrReserved = errors.New("reservation found outputs already reserved")
+
+	// ErrNotReserved is returned by ExtendReservations when
+	// an output the transaction spends is no longer reserved
+	// for it, as when its reservation has expired.
+	ErrNotReserved = errors.New("outputs are not reserved")
 )
 
 // utxo describes an individual account utxo.
@@ -39,8 +44,10 @@ type utxo struct {
 	bc.AssetAmount
 	ControlProgram []byte
 
-	AccountID           string
-	ControlProgramIndex uint64
+	AccountID                string
+	ControlProgramIndex      uint64
+	ControlProgramEpoch      *uint64
+	ControlProgramGeneration uint64 // account key generation
 }
 
 func (u *utxo) source() source {
@@ -54,7 +61,8 @@ type source struct {
 }
 
 // reservation describes a reservation of a set of UTXOs belonging
-// to a particular account. Reservations are immutable.
+// to a particular account. Reservations are immutable; extending
+// one replaces it with a copy.
 type reservation struct {
 	ID          uint64
 	Source      source
@@ -199,6 +207,97 @@ func (re *reserver) Cancel(ctx context.Context, rid uint64) error {
 	return nil
 }
 
+// Extend moves the expiration of the reservations holding outs
+// to exp. Every UTXO a reservation holds must be among outs, so a
+// transaction can only extend the reservations made for it.
+// It returns ErrNotReserved if some of outs aren't reserved.
+func (re *reserver) Extend(outs []bc.Outpoint, exp time.Time) error {
+	rids, err := re.reservationsFor(outs, false)
+	if err != nil {
+		return err
+	}
+
+	re.reservationsMu.Lock()
+	defer re.reservationsMu.Unlock()
+	for rid := range rids {
+		if _, ok := re.reservations[rid]; !ok {
+			// It expired since reservedOutpoints.
+			return errors.WithDetailf(ErrNotReserved, "reservation %d expired", rid)
+		}
+	}
+	for rid := range rids {
+		res := *re.reservations[rid]
+		res.Expiry = exp
+		re.reservations[rid] = &res
+	}
+	return nil
+}
+
+// Release cancels the reservations holding outs, making
+// their UTXOs available at once. As with Extend, every
+// UTXO a reservation holds must be among outs. Outputs
+// that aren't reserved are skipped, so releasing twice is
+// harmless. It returns the number of UTXOs released.
+func (re *reserver) Release(ctx context.Context, outs []bc.Outpoint) (int, error) {
+	rids, err := re.reservationsFor(outs, true)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for rid, count := range rids {
+		// Any error means the reservation
+		// expired since reservedOutpoints.
+		if re.Cancel(ctx, rid) == nil {
+			n += count
+		}
+	}
+	return n, nil
+}
+
+// reservationsFor returns the IDs of the reservations holding
+// outs, with the number of outs each holds. It returns
+// ErrNotReserved if a reservation also holds UTXOs not among
+// outs or, unless skipUnreserved is set, if one of outs
+// isn't reserved.
+func (re *reserver) reservationsFor(outs []bc.Outpoint, skipUnreserved bool) (map[uint64]int, error) {
+	reserved := re.reservedOutpoints(outs)
+	spent := make(map[bc.Outpoint]bool, len(outs))
+	for _, o := range outs {
+		spent[o] = true
+	}
+	rids := make(map[uint64]int)
+	for _, o := range outs {
+		res, ok := reserved[o]
+		if !ok {
+			if skipUnreserved {
+				continue
+			}
+			return nil, errors.WithDetailf(ErrNotReserved, "output %s is not reserved", o)
+		}
+		for _, u := range res.UTXOs {
+			if !spent[u.Outpoint] {
+				return nil, errors.WithDetailf(ErrNotReserved, "output %s is reserved with outputs the transaction doesn't spend", o)
+			}
+		}
+		rids[res.ID]++
+	}
+	return rids, nil
+}
+
+// accountReservations returns the IDs of the
+// outstanding reservations of the given account.
+func (re *reserver) accountReservations(accountID string) []uint64 {
+	re.reservationsMu.Lock()
+	defer re.reservationsMu.Unlock()
+	var rids []uint64
+	for rid, res := range re.reservations {
+		if res.Source.AccountID == accountID {
+			rids = append(rids, rid)
+		}
+	}
+	return rids
+}
+
 // ExpireReservations cleans up all reservations that have expired,
 // making their UTXOs available for reservation again.
 func (re *reserver) ExpireReservations(ctx context.Context) error {
@@ -229,6 +328,41 @@ func (re *reserver) ExpireReservations(ctx context.Context) error {
 	return nil
 }
 
+// reservedOutpoints returns the reservations holding
+// any of the given outpoints, keyed by outpoint.
+func (re *reserver) reservedOutpoints(outs []bc.Outpoint) map[bc.Outpoint]*reservation {
+	re.sourcesMu.Lock()
+	srs := make([]*sourceReserver, 0, len(re.sources))
+	for _, sr := range re.sources {
+		srs = append(srs, sr)
+	}
+	re.sourcesMu.Unlock()
+
+	rids := make(map[bc.Outpoint]uint64)
+	for _, sr := range srs {
+		sr.mu.Lock()
+		for _, o := range outs {
+			if rid, ok := sr.reserved[o]; ok {
+				rids[o] = rid
+			}
+		}
+		sr.mu.Unlock()
+	}
+	if len(rids) == 0 {
+		return nil
+	}
+
+	res := make(map[bc.Outpoint]*reservation, len(rids))
+	re.reservationsMu.Lock()
+	defer re.reservationsMu.Unlock()
+	for o, rid := range rids {
+		if r, ok := re.reservations[rid]; ok {
+			res[o] = r
+		}
+	}
+	return res
+}
+
 func (re *reserver) checkUTXO(u *utxo) bool {
 	_, s := re.c.State()
 	return s.Tree.ContainsKey(state.OutputKey(u.Outpoint))
@@ -381,13 +515,13 @@ func (sr *sourceReserver) refillCache(ctx context.Context) error {
 
 func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64) ([]*utxo, error) {
 	const q = `
-		SELECT tx_hash, index, amount, control_program_index, control_program
+		SELECT tx_hash, index, amount, control_program_index, control_program_epoch, control_program_generation, control_program
 		FROM account_utxos
 		WHERE account_id = $1 AND asset_id = $2 AND confirmed_in > $3
 	`
 	var utxos []*utxo
 	err := pg.ForQueryRows(ctx, db, q, src.AccountID, src.AssetID, height,
-		func(txHash bc.Hash, index uint32, amount uint64, cpIndex uint64, controlProg []byte) {
+		func(txHash bc.Hash, index uint32, amount uint64, cpIndex uint64, cpEpoch *uint64, cpGeneration uint64, controlProg []byte) {
 			utxos = append(utxos, &utxo{
 				Outpoint: bc.Outpoint{
 					Hash:  txHash,
@@ -397,9 +531,11 @@ func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64)
 					Amount:  amount,
 					AssetID: src.AssetID,
 				},
-				ControlProgram:      controlProg,
-				AccountID:           src.AccountID,
-				ControlProgramIndex: cpIndex,
+				ControlProgram:           controlProg,
+				AccountID:                src.AccountID,
+				ControlProgramIndex:      cpIndex,
+				ControlProgramEpoch:      cpEpoch,
+				ControlProgramGeneration: cpGeneration,
 			})
 		})
 	if err != nil {
@@ -410,12 +546,12 @@ func findMatchingUTXOs(ctx context.Context, db pg.DB, src source, height uint64)
 
 func findSpecificUTXO(ctx context.Context, db pg.DB, out bc.Outpoint) (*utxo, error) {
 	const q = `
-		SELECT account_id, asset_id, amount, control_program_index, control_program
+		SELECT account_id, asset_id, amount, control_program_index, control_program_epoch, control_program_generation, control_program
 		FROM account_utxos
 		WHERE tx_hash = $1 AND index = $2
 	`
 	u := new(utxo)
-	err := db.QueryRow(ctx, q, out.Hash, out.Index).Scan(&u.AccountID, &u.AssetID, &u.Amount, &u.ControlProgramIndex, &u.ControlProgram)
+	err := db.QueryRow(ctx, q, out.Hash, out.Index).Scan(&u.AccountID, &u.AssetID, &u.Amount, &u.ControlProgramIndex, &u.ControlProgramEpoch, &u.ControlProgramGeneration, &u.ControlProgram)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
 	} else if err != nil {
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/protocol/state"
@@ -74,3 +75,123 @@ func TestCancelReservation(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestReservedOutpoints(t *testing.T) {
+	re := newReserver(nil, nil, nil)
+	u := &utxo{
+		Outpoint:  bc.Outpoint{Hash: bc.Hash{1}, Index: 2},
+		AccountID: "acc1",
+	}
+	err := re.source(u.source()).reserveUTXO(1, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := &reservation{ID: 1, Source: u.source(), UTXOs: []*utxo{u}}
+	re.reservations[1] = res
+
+	other := bc.Outpoint{Hash: bc.Hash{3}}
+	got := re.reservedOutpoints([]bc.Outpoint{other, u.Outpoint})
+	if len(got) != 1 || got[u.Outpoint] != res {
+		t.Errorf("reservedOutpoints = %v want only %v", got, u.Outpoint)
+	}
+
+	re.Cancel(context.Background(), 1)
+	got = re.reservedOutpoints([]bc.Outpoint{u.Outpoint})
+	if len(got) != 0 {
+		t.Errorf("after cancel, reservedOutpoints = %v want none", got)
+	}
+}
+
+func TestExtendReservation(t *testing.T) {
+	re := newReserver(nil, nil, nil)
+	reserve := func(rid uint64, outs ...bc.Outpoint) *reservation {
+		res := &reservation{ID: rid, Source: source{AccountID: "acc1"}, Expiry: time.Now()}
+		for _, o := range outs {
+			u := &utxo{Outpoint: o, AccountID: "acc1"}
+			err := re.source(u.source()).reserveUTXO(rid, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.UTXOs = append(res.UTXOs, u)
+		}
+		re.reservations[rid] = res
+		return res
+	}
+	a, b, c := bc.Outpoint{Hash: bc.Hash{1}}, bc.Outpoint{Hash: bc.Hash{2}}, bc.Outpoint{Hash: bc.Hash{3}}
+	reserve(1, a)
+	reserve(2, b, c)
+
+	exp := time.Now().Add(time.Hour)
+	err := re.Extend([]bc.Outpoint{a, b, c}, exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rid := uint64(1); rid <= 2; rid++ {
+		if got := re.reservations[rid].Expiry; !got.Equal(exp) {
+			t.Errorf("reservation %d expiry = %s want %s", rid, got, exp)
+		}
+	}
+
+	// A transaction spending only part of a
+	// reservation can't extend it.
+	err = re.Extend([]bc.Outpoint{b}, exp.Add(time.Hour))
+	if errors.Root(err) != ErrNotReserved {
+		t.Errorf("extending part of a reservation: err = %v want %v", err, ErrNotReserved)
+	}
+
+	re.Cancel(context.Background(), 1)
+	err = re.Extend([]bc.Outpoint{a}, exp)
+	if errors.Root(err) != ErrNotReserved {
+		t.Errorf("extending a canceled reservation: err = %v want %v", err, ErrNotReserved)
+	}
+}
+
+func TestReleaseReservation(t *testing.T) {
+	ctx := context.Background()
+	re := newReserver(nil, nil, nil)
+	reserve := func(rid uint64, outs ...bc.Outpoint) {
+		res := &reservation{ID: rid, Source: source{AccountID: "acc1"}, Expiry: time.Now().Add(time.Hour)}
+		for _, o := range outs {
+			u := &utxo{Outpoint: o, AccountID: "acc1"}
+			err := re.source(u.source()).reserveUTXO(rid, u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.UTXOs = append(res.UTXOs, u)
+		}
+		re.reservations[rid] = res
+	}
+	a, b, c := bc.Outpoint{Hash: bc.Hash{1}}, bc.Outpoint{Hash: bc.Hash{2}}, bc.Outpoint{Hash: bc.Hash{3}}
+	reserve(1, a)
+	reserve(2, b, c)
+
+	// A transaction spending only part of a
+	// reservation can't release it.
+	_, err := re.Release(ctx, []bc.Outpoint{a, b})
+	if errors.Root(err) != ErrNotReserved {
+		t.Errorf("releasing part of a reservation: err = %v want %v", err, ErrNotReserved)
+	}
+	if len(re.reservations) != 2 {
+		t.Errorf("got %d reservations after failed release, want 2", len(re.reservations))
+	}
+
+	n, err := re.Release(ctx, []bc.Outpoint{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("released %d outputs want 3", n)
+	}
+	if len(re.reservations) != 0 {
+		t.Errorf("got %d reservations after release, want 0", len(re.reservations))
+	}
+	if got := re.reservedOutpoints([]bc.Outpoint{a, b, c}); len(got) != 0 {
+		t.Errorf("outputs still reserved after release: %v", got)
+	}
+
+	// Releasing again does nothing.
+	n, err = re.Release(ctx, []bc.Outpoint{a, b, c})
+	if err != nil || n != 0 {
+		t.Errorf("releasing again = %d, %v want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,220 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/signers"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// A KeyGeneration is one of the sets of keys an account has had.
+// Generation 0 holds the keys the account was created with, and
+// RotateKeys adds each one after. Control programs are created
+// with the account's latest generation, and outputs they control
+// are spent with the keys of the generation that created them.
+type KeyGeneration struct {
+	AccountID  string         `json:"account_id"`
+	Generation uint64         `json:"key_generation"`
+	XPubs      []chainkd.XPub `json:"root_xpubs"`
+	Quorum     int            `json:"quorum"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// RotateKeys replaces the account's keys and quorum going
+// forward. Control programs created for the account afterward
+// use the new keys, while outputs the account already has, or
+// receives through older control programs, still need the old
+// keys to spend. Unlike UpgradeQuorum, the account keeps its
+// ID, alias and history, and no funds need to move.
+//
+// Sub-accounts keep the keys they were created with.
+func (m *Manager) RotateKeys(ctx context.Context, accountID string, xpubs []chainkd.XPub, quorum int, clientToken string) (*KeyGeneration, error) {
+	err := signers.CheckKeys(xpubs, quorum)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := m.quorumTiers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkTiers(tiers, len(xpubs))
+	if err != nil {
+		return nil, err
+	}
+
+	var xpubBytes pq.ByteaArray
+	for _, xpub := range xpubs {
+		xpubBytes = append(xpubBytes, xpub[:])
+	}
+	nullToken := stdsql.NullString{
+		String: clientToken,
+		Valid:  clientToken != "",
+	}
+
+	// The first rotation also records the account's
+	// original keys, as generation 0.
+	const q = `
+		WITH latest AS (
+			SELECT COALESCE(MAX(generation), 0) AS generation
+			FROM account_key_generations WHERE account_id = $1
+		), original AS (
+			INSERT INTO account_key_generations (account_id, generation, xpubs, quorum)
+			SELECT id, 0, xpubs, quorum FROM signers WHERE id = $1
+			ON CONFLICT (account_id, generation) DO NOTHING
+		), rotated AS (
+			INSERT INTO account_key_generations (account_id, generation, xpubs, quorum, client_token)
+			SELECT $1, generation + 1, $2, $3, $4 FROM latest
+			ON CONFLICT (client_token) DO NOTHING
+			RETURNING generation, created_at
+		)
+		UPDATE signers SET xpubs = $2, quorum = $3
+		FROM rotated WHERE id = $1
+		RETURNING rotated.generation, rotated.created_at
+	`
+	gen := &KeyGeneration{AccountID: accountID, XPubs: xpubs, Quorum: quorum}
+	err = m.db.QueryRow(ctx, q, accountID, xpubBytes, quorum, nullToken).Scan(&gen.Generation, &gen.CreatedAt)
+	if err == stdsql.ErrNoRows && clientToken != "" {
+		// A retried request finds the
+		// generation it added the first time.
+		return m.keyGenerationByClientToken(ctx, accountID, clientToken)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "rotating account keys")
+	}
+
+	m.invalidate(accountID, "")
+	err = m.reindexAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return gen, nil
+}
+
+// KeyGenerations returns the account's key
+// generations, oldest first.
+func (m *Manager) KeyGenerations(ctx context.Context, accountID string) ([]*KeyGeneration, error) {
+	account, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	const q = `
+		SELECT generation, xpubs, quorum, created_at FROM account_key_generations
+		WHERE account_id = $1 ORDER BY generation
+	`
+	var gens []*KeyGeneration
+	err = pg.ForQueryRows(ctx, m.db, q, accountID, func(generation uint64, xpubBytes pq.ByteaArray, quorum int, createdAt time.Time) error {
+		xpubs, err := signers.ConvertKeys(xpubBytes)
+		if err != nil {
+			return errors.Wrap(err, "decoding account key generation")
+		}
+		gens = append(gens, &KeyGeneration{
+			AccountID:  accountID,
+			Generation: generation,
+			XPubs:      xpubs,
+			Quorum:     quorum,
+			CreatedAt:  createdAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing account key generations")
+	}
+	if len(gens) == 0 {
+		// The account has never been rotated.
+		gens = append(gens, &KeyGeneration{AccountID: accountID, XPubs: account.XPubs, Quorum: account.Quorum})
+	}
+	return gens, nil
+}
+
+// keyGeneration returns the account's key generation with the
+// given number, or its latest generation if generation is nil.
+func (m *Manager) keyGeneration(ctx context.Context, account *signers.Signer, generation *uint64) (*KeyGeneration, error) {
+	const q = `
+		SELECT generation, xpubs, quorum, created_at FROM account_key_generations
+		WHERE account_id = $1 AND ($2::bigint IS NULL OR generation = $2)
+		ORDER BY generation DESC LIMIT 1
+	`
+	gen, err := scanKeyGeneration(account.ID, m.db.QueryRow(ctx, q, account.ID, generation))
+	if errors.Root(err) == stdsql.ErrNoRows && (generation == nil || *generation == 0) {
+		// Accounts that have never been rotated
+		// only have the keys they were created with.
+		return &KeyGeneration{AccountID: account.ID, XPubs: account.XPubs, Quorum: account.Quorum}, nil
+	}
+	return gen, err
+}
+
+func (m *Manager) keyGenerationByClientToken(ctx context.Context, accountID, clientToken string) (*KeyGeneration, error) {
+	const q = `
+		SELECT generation, xpubs, quorum, created_at FROM account_key_generations
+		WHERE account_id = $1 AND client_token = $2
+	`
+	return scanKeyGeneration(accountID, m.db.QueryRow(ctx, q, accountID, clientToken))
+}
+
+func scanKeyGeneration(accountID string, row interface {
+	Scan(...interface{}) error
+}) (*KeyGeneration, error) {
+	var (
+		gen       = &KeyGeneration{AccountID: accountID}
+		xpubBytes [][]byte
+	)
+	err := row.Scan(&gen.Generation, (*pq.ByteaArray)(&xpubBytes), &gen.Quorum, &gen.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account key generation")
+	}
+	gen.XPubs, err = signers.ConvertKeys(xpubBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding account key generation")
+	}
+	return gen, nil
+}
+
+// reindexAccount saves the account's annotated
+// form again, after its keys change.
+func (m *Manager) reindexAccount(ctx context.Context, accountID string) error {
+	signer, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	var (
+		alias     stdsql.NullString
+		tagsJSON  []byte
+		archived  bool
+		epochSecs int64
+		parentID  stdsql.NullString
+	)
+	const q = `
+		SELECT alias, tags, archived_at IS NOT NULL, epoch_period, parent_id
+		FROM accounts WHERE account_id = $1
+	`
+	err = m.db.QueryRow(ctx, q, accountID).Scan(&alias, &tagsJSON, &archived, &epochSecs, &parentID)
+	if err != nil {
+		return errors.Wrap(err, "loading account")
+	}
+	account := &Account{
+		Signer:      signer,
+		Alias:       alias.String,
+		Archived:    archived,
+		EpochPeriod: time.Duration(epochSecs) * time.Second,
+		ParentID:    parentID.String,
+	}
+	if len(tagsJSON) > 0 {
+		err = json.Unmarshal(tagsJSON, &account.Tags)
+		if err != nil {
+			return errors.Wrap(err, "decoding account tags")
+		}
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	return errors.Wrap(err, "indexing annotated account")
+}
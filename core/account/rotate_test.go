@@ -0,0 +1,80 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestRotateKeys(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", nil)
+	oldProg := m.createTestControlProgram(ctx, t, acc.ID)
+
+	_, newXPub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := m.RotateKeys(ctx, acc.ID, []chainkd.XPub{newXPub}, 1, "rotate-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if gen.Generation != 1 {
+		t.Errorf("generation = %d want 1", gen.Generation)
+	}
+	retried, err := m.RotateKeys(ctx, acc.ID, []chainkd.XPub{newXPub}, 1, "rotate-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if retried.Generation != 1 {
+		t.Errorf("retried generation = %d want 1", retried.Generation)
+	}
+
+	gens, err := m.KeyGenerations(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(gens) != 2 || gens[0].XPubs[0] != testutil.TestXPub || gens[1].XPubs[0] != newXPub {
+		t.Fatalf("key generations = %+v want original then rotated keys", gens)
+	}
+
+	cp, err := m.createControlProgram(ctx, acc.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if cp.keyGeneration != 1 {
+		t.Errorf("new control program generation = %d want 1", cp.keyGeneration)
+	}
+	if bytes.Equal(cp.controlProgram, oldProg) {
+		t.Error("new control program uses the old keys")
+	}
+
+	// Outputs to control programs from before the
+	// rotation are still spent with the old keys.
+	signer, err := m.findByID(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	u := &utxo{
+		AssetAmount:    bc.AssetAmount{Amount: 1},
+		ControlProgram: oldProg,
+		AccountID:      acc.ID,
+	}
+	_, sigInst, err := m.utxoToInputs(ctx, signer, u, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	sw := sigInst.WitnessComponents[0].(*txbuilder.SignatureWitness)
+	if len(sw.Keys) != 1 || sw.Keys[0].XPub != testutil.TestXPub {
+		t.Errorf("signing keys = %+v want the original key", sw.Keys)
+	}
+}
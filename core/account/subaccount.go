@@ -0,0 +1,71 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+
+	"github.com/lib/pq"
+
+	"chain/core/signers"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// CreateChild creates a sub-account of the account with the given
+// parent ID. The sub-account has the keys, quorum and epoch period
+// of its parent, but its control programs are derived along a path
+// that extends the parent's, so they are distinct from those of
+// the parent and of the parent's other sub-accounts.
+func (m *Manager) CreateChild(ctx context.Context, parentID, alias string, tags map[string]interface{}, clientToken string) (*Account, error) {
+	parent, err := m.findByID(ctx, parentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading parent account")
+	}
+	err = m.checkArchived(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	epochPeriod, err := m.epochPeriod(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := signers.CreateChild(ctx, m.db, parent, clientToken)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	account, err := m.insertAccount(ctx, m.db, signer, alias, tags, epochPeriod, parentID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.indexAnnotatedAccount(ctx, account)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+	return account, nil
+}
+
+// accountPaths returns the path of each account in accountIDs:
+// the IDs and aliases of its ancestors, outermost first,
+// followed by the account itself.
+func (m *Manager) accountPaths(ctx context.Context, accountIDs []string) (map[string][]map[string]interface{}, error) {
+	const q = `
+		WITH RECURSIVE ancestors (account_id, ancestor_id, parent_id, alias, depth) AS (
+			SELECT account_id, account_id, parent_id, alias, 0
+			FROM accounts WHERE account_id IN (SELECT unnest($1::text[]))
+		UNION ALL
+			SELECT a.account_id, p.account_id, p.parent_id, p.alias, a.depth + 1
+			FROM ancestors a JOIN accounts p ON p.account_id = a.parent_id
+		)
+		SELECT account_id, ancestor_id, alias FROM ancestors
+		ORDER BY account_id, depth DESC
+	`
+	paths := make(map[string][]map[string]interface{})
+	err := pg.ForQueryRows(ctx, m.db, q, pq.StringArray(accountIDs), func(accountID, ancestorID string, alias stdsql.NullString) {
+		elem := map[string]interface{}{"id": ancestorID}
+		if alias.Valid {
+			elem["alias"] = alias.String
+		}
+		paths[accountID] = append(paths[accountID], elem)
+	})
+	return paths, errors.Wrap(err, "querying account paths")
+}
@@ -0,0 +1,82 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"chain/core/signers"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/prottest"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestCreateChild(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	parent := m.createTestAccount(ctx, t, "treasury", nil)
+	child, err := m.CreateChild(ctx, parent.ID, "payroll", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	grandchild, err := m.CreateChild(ctx, child.ID, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if child.ParentID != parent.ID || grandchild.ParentID != child.ID {
+		t.Errorf("parent IDs = %s, %s want %s, %s", child.ParentID, grandchild.ParentID, parent.ID, child.ID)
+	}
+
+	// Each account's path extends its parent's.
+	parentPath := signers.Path(parent.Signer, signers.AccountKeySpace)
+	childPath := signers.Path(child.Signer, signers.AccountKeySpace)
+	grandchildPath := signers.Path(grandchild.Signer, signers.AccountKeySpace)
+	if len(childPath) != len(parentPath)+1 || !reflect.DeepEqual(childPath[:len(parentPath)], parentPath) {
+		t.Errorf("child path = %x, want extension of %x", childPath, parentPath)
+	}
+	if len(grandchildPath) != len(childPath)+1 || !reflect.DeepEqual(grandchildPath[:len(childPath)], childPath) {
+		t.Errorf("grandchild path = %x, want extension of %x", grandchildPath, childPath)
+	}
+
+	// Control programs are derived along the full path.
+	cp, err := m.createControlProgram(ctx, grandchild.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	path := controlProgramPath(grandchild.Signer, nil, cp.keyIndex)
+	pubs := chainkd.XPubKeys(chainkd.DeriveXPubs(grandchild.XPubs, path))
+	want, err := vmutil.P2SPMultiSigProgram(pubs, 1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(cp.controlProgram, want) {
+		t.Errorf("control program = %x want %x", cp.controlProgram, want)
+	}
+	err = m.insertAccountControlProgram(ctx, cp)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Outputs are annotated with the whole account path,
+	// so they can be rolled up into every ancestor.
+	out := map[string]interface{}{"control_program": hex.EncodeToString(cp.controlProgram)}
+	txs := []map[string]interface{}{{"inputs": []interface{}{}, "outputs": []interface{}{out}}}
+	err = m.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	wantPath := []map[string]interface{}{
+		{"id": parent.ID, "alias": "treasury"},
+		{"id": child.ID, "alias": "payroll"},
+		{"id": grandchild.ID},
+	}
+	if !reflect.DeepEqual(out["account_path"], wantPath) {
+		t.Errorf("account_path = %v want %v", out["account_path"], wantPath)
+	}
+}
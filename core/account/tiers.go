@@ -0,0 +1,125 @@
+package account
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/vmutil"
+)
+
+// ErrBadQuorumTiers is returned by SetQuorumTiers when the tiers
+// are invalid for the account's keys, and by RotateKeys when the
+// new keys are too few for the account's tiers.
+var ErrBadQuorumTiers = errors.New("invalid account quorum tiers")
+
+// A QuorumTier requires Quorum signatures to spend an output of at
+// least Amount units from the account. Below the lowest tier, the
+// account's own quorum applies.
+type QuorumTier struct {
+	Amount uint64 `json:"amount"`
+	Quorum int    `json:"quorum"`
+}
+
+// SetQuorumTiers replaces the account's quorum tiers, sorted by
+// amount. Control programs created for the account afterward
+// compile the tiers in, so the quorum needed to spend each output
+// depends on its amount. Outputs to earlier control programs keep
+// the policy they were created with. An empty list of tiers
+// removes them.
+func (m *Manager) SetQuorumTiers(ctx context.Context, accountID string, tiers []QuorumTier) ([]QuorumTier, error) {
+	account, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := m.keyGeneration(ctx, account, nil)
+	if err != nil {
+		return nil, err
+	}
+	tiers = append([]QuorumTier{}, tiers...)
+	sort.Sort(byAmount(tiers))
+	err = checkTiers(tiers, len(keys.XPubs))
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, quorums := pq.Int64Array{}, pq.Int64Array{}
+	for _, t := range tiers {
+		amounts = append(amounts, int64(t.Amount))
+		quorums = append(quorums, int64(t.Quorum))
+	}
+	const q = `
+		WITH removed AS (
+			DELETE FROM account_quorum_tiers
+			WHERE account_id = $1 AND NOT amount = ANY($2::bigint[])
+		)
+		INSERT INTO account_quorum_tiers (account_id, amount, quorum)
+		SELECT $1, unnest($2::bigint[]), unnest($3::integer[])
+		ON CONFLICT (account_id, amount) DO UPDATE SET quorum = excluded.quorum
+	`
+	_, err = m.db.Exec(ctx, q, accountID, amounts, quorums)
+	if err != nil {
+		return nil, errors.Wrap(err, "setting account quorum tiers")
+	}
+	return tiers, nil
+}
+
+// QuorumTiers returns the account's quorum
+// tiers, in increasing order of amount.
+func (m *Manager) QuorumTiers(ctx context.Context, accountID string) ([]QuorumTier, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return m.quorumTiers(ctx, accountID)
+}
+
+func (m *Manager) quorumTiers(ctx context.Context, accountID string) ([]QuorumTier, error) {
+	const q = `
+		SELECT amount, quorum FROM account_quorum_tiers
+		WHERE account_id = $1 ORDER BY amount
+	`
+	tiers := []QuorumTier{}
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, func(amount uint64, quorum int) {
+		tiers = append(tiers, QuorumTier{Amount: amount, Quorum: quorum})
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account quorum tiers")
+	}
+	return tiers, nil
+}
+
+// checkTiers checks sorted tiers against
+// an account with nkeys keys.
+func checkTiers(tiers []QuorumTier, nkeys int) error {
+	for i, t := range tiers {
+		if t.Amount == 0 || t.Amount > math.MaxInt64 {
+			return errors.WithDetail(ErrBadQuorumTiers, "tier amounts must be positive 63-bit integers")
+		}
+		if i > 0 && t.Amount == tiers[i-1].Amount {
+			return errors.WithDetailf(ErrBadQuorumTiers, "more than one tier for amount %d", t.Amount)
+		}
+		if t.Quorum <= 0 || t.Quorum > nkeys {
+			return errors.WithDetailf(ErrBadQuorumTiers, "tier quorum %d must be between 1 and the %d account keys", t.Quorum, nkeys)
+		}
+	}
+	return nil
+}
+
+type byAmount []QuorumTier
+
+func (a byAmount) Len() int           { return len(a) }
+func (a byAmount) Less(i, j int) bool { return a[i].Amount < a[j].Amount }
+func (a byAmount) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+
+func vmTiers(tiers []QuorumTier) []vmutil.QuorumTier {
+	var res []vmutil.QuorumTier
+	for _, t := range tiers {
+		res = append(res, vmutil.QuorumTier{Amount: t.Amount, Quorum: t.Quorum})
+	}
+	return res
+}
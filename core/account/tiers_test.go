@@ -0,0 +1,85 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/protocol/vmutil"
+	"chain/testutil"
+)
+
+func TestQuorumTiers(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, err := m.Create(ctx, []chainkd.XPub{testutil.TestXPub, xpub2}, 1, "", nil, 0, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = m.SetQuorumTiers(ctx, acc.ID, []QuorumTier{{Amount: 100, Quorum: 3}})
+	if errors.Root(err) != ErrBadQuorumTiers {
+		t.Errorf("setting tier quorum above key count: err = %v want %v", err, ErrBadQuorumTiers)
+	}
+
+	tiers, err := m.SetQuorumTiers(ctx, acc.ID, []QuorumTier{{Amount: 100, Quorum: 2}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := m.QuorumTiers(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 1 || got[0] != tiers[0] {
+		t.Fatalf("quorum tiers = %+v want %+v", got, tiers)
+	}
+
+	prog := m.createTestControlProgram(ctx, t, acc.ID)
+	if _, _, _, err := vmutil.ParseTieredMultiSigProgram(prog); err != nil {
+		t.Fatalf("control program is not tiered: %v", err)
+	}
+
+	signer, err := m.findByID(ctx, acc.ID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	cases := []struct {
+		amount uint64
+		quorum int
+	}{
+		{99, 1},
+		{100, 2},
+		{1000, 2},
+	}
+	for _, c := range cases {
+		u := &utxo{
+			AssetAmount:    bc.AssetAmount{Amount: c.amount},
+			ControlProgram: prog,
+			AccountID:      acc.ID,
+		}
+		_, sigInst, err := m.utxoToInputs(ctx, signer, u, nil)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		sw := sigInst.WitnessComponents[0].(*txbuilder.SignatureWitness)
+		if sw.Quorum != c.quorum {
+			t.Errorf("spending %d: quorum = %d want %d", c.amount, sw.Quorum, c.quorum)
+		}
+	}
+
+	_, err = m.RotateKeys(ctx, acc.ID, []chainkd.XPub{xpub2}, 1, "rotate-1")
+	if errors.Root(err) != ErrBadQuorumTiers {
+		t.Errorf("rotating to fewer keys than a tier quorum: err = %v want %v", err, ErrBadQuorumTiers)
+	}
+}
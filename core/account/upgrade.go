@@ -0,0 +1,236 @@
+package account
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/signers"
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrUpgraded is returned by UpgradeQuorum when the
+	// account already has a successor.
+	ErrUpgraded = errors.New("account already has a successor")
+
+	// ErrNotUpgraded is returned when migrating an account
+	// that has no successor.
+	ErrNotUpgraded = errors.New("account has no successor")
+)
+
+// An Upgrade links an account to its successor, a new account
+// with different keys or a different quorum. Funds move to the
+// successor in transactions built from MigrationActions, signed
+// with the original account's keys.
+type Upgrade struct {
+	AccountID   string     `json:"account_id"`
+	SuccessorID string     `json:"successor_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+
+	// Remaining lists the funds still held by the
+	// original account, by asset.
+	Remaining []bc.AssetAmount `json:"remaining"`
+}
+
+// UpgradeQuorum provisions a successor for an account, with
+// the given keys and quorum and the account's tags and epoch
+// period. Control
+// programs created for the account afterward belong to the
+// successor, so new payments go straight to it.
+func (m *Manager) UpgradeQuorum(ctx context.Context, accountID string, xpubs []chainkd.XPub, quorum int, clientToken string) (*Upgrade, error) {
+	_, err := m.findByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := m.successor(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != "" {
+		// A retried request with the same client token
+		// finds the upgrade it made the first time.
+		var retried bool
+		const q = `SELECT EXISTS(SELECT 1 FROM signers WHERE id = $1 AND client_token = $2)`
+		err = m.db.QueryRow(ctx, q, existing, clientToken).Scan(&retried)
+		if err != nil {
+			return nil, errors.Wrap(err, "checking account successor")
+		}
+		if !retried {
+			return nil, errors.WithDetailf(ErrUpgraded, "successor %s", existing)
+		}
+		return m.Migration(ctx, accountID)
+	}
+
+	var (
+		tags      map[string]interface{}
+		tagsJSON  []byte
+		epochSecs int64
+	)
+	const tagsQ = `SELECT tags, epoch_period FROM accounts WHERE account_id = $1`
+	err = m.db.QueryRow(ctx, tagsQ, accountID).Scan(&tagsJSON, &epochSecs)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account tags")
+	}
+	if len(tagsJSON) > 0 {
+		err = json.Unmarshal(tagsJSON, &tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding account tags")
+		}
+	}
+
+	// The successor and the upgrade linking it are stored
+	// together, so a failed upgrade leaves no stray account.
+	dbtx, err := m.db.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin transaction")
+	}
+	defer dbtx.Rollback(ctx)
+
+	signer, err := signers.Create(ctx, dbtx, "account", xpubs, quorum, clientToken)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	successor, err := m.insertAccount(ctx, dbtx, signer, "", tags, time.Duration(epochSecs)*time.Second, "")
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		INSERT INTO account_upgrades (account_id, successor_id) VALUES ($1, $2)
+		ON CONFLICT (account_id) DO NOTHING
+	`
+	res, err := dbtx.Exec(ctx, q, accountID, successor.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting account upgrade")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, errors.Wrap(err)
+	} else if n == 0 {
+		// A concurrent request upgraded it first.
+		return nil, errors.WithDetailf(ErrUpgraded, "account %s", accountID)
+	}
+	err = dbtx.Commit(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "commit transaction")
+	}
+
+	err = m.indexAnnotatedAccount(ctx, successor)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated account")
+	}
+	return m.Migration(ctx, accountID)
+}
+
+// latestSuccessor follows the chain of upgrades from an
+// account and returns the ID of the last account in it,
+// or accountID itself if it has no successor.
+func (m *Manager) latestSuccessor(ctx context.Context, accountID string) (string, error) {
+	const q = `
+		WITH RECURSIVE upgrades (id, depth) AS (
+			SELECT $1::text, 0
+			UNION ALL
+			SELECT u.successor_id, depth + 1
+			FROM account_upgrades u JOIN upgrades ON u.account_id = upgrades.id
+		)
+		SELECT id FROM upgrades ORDER BY depth DESC LIMIT 1
+	`
+	var id string
+	err := m.db.QueryRow(ctx, q, accountID).Scan(&id)
+	return id, errors.Wrap(err, "looking up latest account successor")
+}
+
+// successor returns the ID of the account's successor,
+// or "" if it has none.
+func (m *Manager) successor(ctx context.Context, accountID string) (string, error) {
+	var id string
+	err := m.db.QueryRow(ctx, `SELECT successor_id FROM account_upgrades WHERE account_id = $1`, accountID).Scan(&id)
+	if err == stdsql.ErrNoRows {
+		return "", nil
+	}
+	return id, errors.Wrap(err, "looking up account successor")
+}
+
+// Migration reports the progress of moving an account's funds
+// to its successor. The migration is complete, and CompletedAt
+// set, once the original account holds nothing.
+func (m *Manager) Migration(ctx context.Context, accountID string) (*Upgrade, error) {
+	u := &Upgrade{AccountID: accountID}
+	var completedAt pq.NullTime
+	const q = `SELECT successor_id, created_at, completed_at FROM account_upgrades WHERE account_id = $1`
+	err := m.db.QueryRow(ctx, q, accountID).Scan(&u.SuccessorID, &u.CreatedAt, &completedAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(ErrNotUpgraded, "account %s", accountID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading account upgrade")
+	}
+
+	u.Remaining, err = m.balances(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if !completedAt.Valid && len(u.Remaining) == 0 {
+		const markQ = `
+			UPDATE account_upgrades SET completed_at = now()
+			WHERE account_id = $1 AND completed_at IS NULL
+			RETURNING completed_at
+		`
+		err = m.db.QueryRow(ctx, markQ, accountID).Scan(&completedAt)
+		if err != nil && err != stdsql.ErrNoRows {
+			return nil, errors.Wrap(err, "completing account upgrade")
+		}
+	}
+	if completedAt.Valid {
+		u.CompletedAt = &completedAt.Time
+	}
+	return u, nil
+}
+
+// balances returns the confirmed balances
+// of an account, by asset.
+func (m *Manager) balances(ctx context.Context, accountID string) ([]bc.AssetAmount, error) {
+	const q = `
+		SELECT asset_id, sum(amount) FROM account_utxos
+		WHERE account_id = $1 GROUP BY asset_id ORDER BY asset_id
+	`
+	var amounts []bc.AssetAmount
+	err := pg.ForQueryRows(ctx, m.db, q, accountID, func(assetID bc.AssetID, amount uint64) {
+		amounts = append(amounts, bc.AssetAmount{AssetID: assetID, Amount: amount})
+	})
+	return amounts, errors.Wrap(err, "querying account balances")
+}
+
+// MigrationActions returns actions that move all of an
+// account's funds to its successor, one spend and one
+// control action per asset. A transaction built from them
+// must be signed with the original account's keys.
+func (m *Manager) MigrationActions(ctx context.Context, accountID string) ([]txbuilder.Action, error) {
+	successorID, err := m.successor(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if successorID == "" {
+		return nil, errors.WithDetailf(ErrNotUpgraded, "account %s", accountID)
+	}
+	amounts, err := m.balances(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	var actions []txbuilder.Action
+	for _, amt := range amounts {
+		actions = append(actions,
+			m.NewSpendAction(amt, accountID, nil, nil),
+			m.NewControlAction(amt, successorID, nil),
+		)
+	}
+	return actions, nil
+}
@@ -0,0 +1,99 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestUpgradeQuorum(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", map[string]interface{}{"type": "savings"})
+
+	_, err := m.Migration(ctx, acc.ID)
+	if errors.Root(err) != ErrNotUpgraded {
+		t.Errorf("migration before upgrade: got error %v want %v", err, ErrNotUpgraded)
+	}
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpubs := []chainkd.XPub{testutil.TestXPub, xpub2}
+	u, err := m.UpgradeQuorum(ctx, acc.ID, xpubs, 2, "upgrade-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// The account holds nothing, so there's nothing to migrate.
+	if u.CompletedAt == nil || len(u.Remaining) != 0 {
+		t.Errorf("upgrade = %+v, want completed", u)
+	}
+
+	// Retrying with the same client token finds the same upgrade.
+	u2, err := m.UpgradeQuorum(ctx, acc.ID, xpubs, 2, "upgrade-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if u2.SuccessorID != u.SuccessorID {
+		t.Errorf("retried upgrade successor = %s want %s", u2.SuccessorID, u.SuccessorID)
+	}
+	_, err = m.UpgradeQuorum(ctx, acc.ID, xpubs, 2, "upgrade-2")
+	if errors.Root(err) != ErrUpgraded {
+		t.Errorf("second upgrade: got error %v want %v", err, ErrUpgraded)
+	}
+
+	succ, err := m.findByID(ctx, u.SuccessorID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if succ.Quorum != 2 || len(succ.XPubs) != 2 {
+		t.Errorf("successor signer = %+v, want 2-of-2", succ)
+	}
+
+	prog, err := m.CreateControlProgram(ctx, acc.ID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var signerID string
+	err = db.QueryRow(ctx, `SELECT signer_id FROM account_control_programs WHERE control_program = $1`, prog).Scan(&signerID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if signerID != u.SuccessorID {
+		t.Errorf("control program signer = %s want %s", signerID, u.SuccessorID)
+	}
+}
+
+func TestUpgradeQuorumAtomic(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := NewManager(db, prottest.NewChain(t), nil)
+	ctx := context.Background()
+	acc := m.createTestAccount(ctx, t, "", nil)
+
+	// Make recording the upgrade fail.
+	_, err := db.Exec(ctx, `ALTER TABLE account_upgrades ADD CONSTRAINT no_upgrades CHECK (account_id = '')`)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = m.UpgradeQuorum(ctx, acc.ID, []chainkd.XPub{testutil.TestXPub}, 1, "upgrade-1")
+	if err == nil {
+		t.Fatal("upgrade succeeded, want error")
+	}
+
+	// The successor was rolled back with it.
+	var n int
+	err = db.QueryRow(ctx, `SELECT COUNT(*) FROM signers WHERE client_token = 'upgrade-1'`).Scan(&n)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != 0 {
+		t.Errorf("got %d successor signers want 0", n)
+	}
+}
@@ -2,11 +2,18 @@ package core
 
 import (
 	"context"
-	"sync"
+	"strconv"
+	"time"
 
+	"chain/core/account"
+	"chain/core/leader"
+	"chain/core/query"
 	"chain/core/signers"
+	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
-	"chain/net/http/reqid"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
 )
 
 // This type enforces JSON field ordering in API output.
@@ -16,6 +23,14 @@ type accountResponse struct {
 	Keys   interface{} `json:"keys"`
 	Quorum interface{} `json:"quorum"`
 	Tags   interface{} `json:"tags"`
+
+	IsArchived interface{} `json:"is_archived"`
+
+	// Only accounts with an epoch period have this.
+	EpochPeriod interface{} `json:"epoch_period,omitempty"`
+
+	// Only sub-accounts have this.
+	ParentID interface{} `json:"parent_id,omitempty"`
 }
 
 type accountKey struct {
@@ -31,46 +46,406 @@ func (h *Handler) createAccount(ctx context.Context, ins []struct {
 	Alias     string
 	Tags      map[string]interface{}
 
+	// EpochPeriod, if set, separates the derivation of the
+	// account's control programs into epochs of this length.
+	EpochPeriod chainjson.Duration `json:"epoch_period"`
+
+	// ParentID or ParentAlias, if set, makes the account a
+	// sub-account of the given account. Sub-accounts take their
+	// keys, quorum and epoch period from their parent.
+	ParentID    string `json:"parent_id"`
+	ParentAlias string `json:"parent_alias"`
+
 	// ClientToken is the application's unique token for the account. Every account
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create account requests. Duplicate create account requests
 	// with the same client_token will only create one account.
 	ClientToken string `json:"client_token"`
-}) interface{} {
-	responses := make([]interface{}, len(ins))
-	var wg sync.WaitGroup
-	wg.Add(len(responses))
-
-	for i := range responses {
-		go func(i int) {
-			subctx := reqid.NewSubContext(ctx, reqid.New())
-			defer wg.Done()
-			defer batchRecover(subctx, &responses[i])
-
-			acc, err := h.Accounts.Create(subctx, ins[i].RootXPubs, ins[i].Quorum, ins[i].Alias, ins[i].Tags, ins[i].ClientToken)
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		var (
+			acc *account.Account
+			err error
+		)
+		if ins[i].ParentID != "" || ins[i].ParentAlias != "" {
+			var parentID string
+			parentID, err = h.requestAccountID(ctx, ins[i].ParentID, ins[i].ParentAlias)
 			if err != nil {
-				responses[i] = err
-				return
-			}
-			path := signers.Path(acc.Signer, signers.AccountKeySpace)
-			var keys []accountKey
-			for _, xpub := range acc.XPubs {
-				keys = append(keys, accountKey{
-					RootXPub:              xpub,
-					AccountXPub:           xpub.Derive(path),
-					AccountDerivationPath: path,
-				})
+				return nil, err
 			}
-			responses[i] = &accountResponse{
-				ID:     acc.ID,
-				Alias:  acc.Alias,
-				Keys:   keys,
-				Quorum: acc.Quorum,
-				Tags:   acc.Tags,
-			}
-		}(i)
+			acc, err = h.Accounts.CreateChild(ctx, parentID, ins[i].Alias, ins[i].Tags, ins[i].ClientToken)
+		} else {
+			acc, err = h.Accounts.Create(ctx, ins[i].RootXPubs, ins[i].Quorum, ins[i].Alias, ins[i].Tags, ins[i].EpochPeriod.Duration, ins[i].ClientToken)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newAccountResponse(acc), nil
+	})
+}
+
+func newAccountResponse(acc *account.Account) *accountResponse {
+	path := signers.Path(acc.Signer, signers.AccountKeySpace)
+	var keys []accountKey
+	for _, xpub := range acc.XPubs {
+		keys = append(keys, accountKey{
+			RootXPub:              xpub,
+			AccountXPub:           xpub.Derive(path),
+			AccountDerivationPath: path,
+		})
+	}
+	isArchived := "no"
+	if acc.Archived {
+		isArchived = "yes"
+	}
+	resp := &accountResponse{
+		ID:         acc.ID,
+		Alias:      acc.Alias,
+		Keys:       keys,
+		Quorum:     acc.Quorum,
+		Tags:       acc.Tags,
+		IsArchived: isArchived,
+	}
+	if acc.EpochPeriod > 0 {
+		resp.EpochPeriod = chainjson.Duration{Duration: acc.EpochPeriod}
 	}
+	if acc.ParentID != "" {
+		resp.ParentID = acc.ParentID
+	}
+	return resp
+}
+
+// POST /update-account-tags
+//
+// updateAccountTags replaces the tags of accounts, identified
+// by id or alias, and annotates their transaction history
+// with the new tags.
+func (h *Handler) updateAccountTags(ctx context.Context, ins []struct {
+	ID    string
+	Alias string
+	Tags  map[string]interface{}
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		id, err := h.requestAccountID(ctx, ins[i].ID, ins[i].Alias)
+		if err != nil {
+			return nil, err
+		}
+		acc, err := h.Accounts.UpdateTags(ctx, id, ins[i].Tags)
+		if err != nil {
+			return nil, err
+		}
+		return newAccountResponse(acc), nil
+	})
+}
+
+// defaultArchiveTimeout is how long /archive-account
+// waits for an account's reservations to expire.
+const defaultArchiveTimeout = 30 * time.Second
+
+// archiveAccount archives an account, identified by account_id
+// or account_alias. Archived accounts can't send or receive
+// funds and are left out of /list-accounts unless
+// include_archived is set; their balances and history can
+// still be queried. It returns once the account's outputs are
+// no longer reserved, canceling reservations if
+// cancel_reservations is set, and otherwise waiting up to
+// timeout for them to expire.
+//
+// POST /archive-account
+func (h *Handler) archiveAccount(ctx context.Context, x struct {
+	AccountID          string             `json:"account_id"`
+	AccountAlias       string             `json:"account_alias"`
+	CancelReservations bool               `json:"cancel_reservations"`
+	Timeout            chainjson.Duration `json:"timeout"`
+}) (*accountResponse, error) {
+	// Reservations are held by the leader.
+	if !leader.IsLeading() {
+		var resp accountResponse
+		err := h.forwardToLeader(ctx, "/archive-account", x, &resp)
+		return &resp, err
+	}
+
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := withStageTimeout(ctx, x.Timeout, defaultArchiveTimeout)
+	defer cancel()
+	acc, err := h.Accounts.Archive(ctx, accountID, x.CancelReservations)
+	if err != nil {
+		return nil, err
+	}
+	return newAccountResponse(acc), nil
+}
 
-	wg.Wait()
-	return responses
+// POST /list-reservation-conflicts
+func (h *Handler) listReservationConflicts(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var after int64
+	if x.After != "" {
+		var err error
+		after, err = strconv.ParseInt(x.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(query.ErrBadAfter, "value: %q", x.After)
+		}
+	}
+
+	conflicts, err := h.Accounts.ListConflicts(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	if len(conflicts) > 0 {
+		outQuery.After = conflicts[len(conflicts)-1].ID
+	}
+
+	return &page{
+		Items:    httpjson.Array(conflicts),
+		LastPage: len(conflicts) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+type accountFreezeRequest struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+	Reason       string `json:"reason"`
+	Actor        string `json:"actor"`
+}
+
+// requestAccountID returns accountID, or if it's
+// empty, the ID of the account with the given alias.
+func (h *Handler) requestAccountID(ctx context.Context, accountID, alias string) (string, error) {
+	if accountID != "" {
+		return accountID, nil
+	}
+	acc, err := h.Accounts.FindByAlias(ctx, alias)
+	if err != nil {
+		return "", err
+	}
+	return acc.ID, nil
+}
+
+// freezeAccount freezes an account, blocking spends from
+// it and the creation of control programs for it. The
+// reason and the actor responsible are required.
+//
+// POST /freeze-account
+func (h *Handler) freezeAccount(ctx context.Context, x accountFreezeRequest) (*account.Freeze, error) {
+	// Freezing cancels reservations, which are held by the leader.
+	if !leader.IsLeading() {
+		var resp account.Freeze
+		err := h.forwardToLeader(ctx, "/freeze-account", x, &resp)
+		return &resp, err
+	}
+
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.Freeze(ctx, accountID, x.Reason, x.Actor)
+}
+
+// unfreezeAccount ends the freeze on an account.
+// It requires an admin access token.
+//
+// POST /unfreeze-account
+func (h *Handler) unfreezeAccount(ctx context.Context, x accountFreezeRequest) (*account.Freeze, error) {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.Unfreeze(ctx, accountID, x.Actor)
+}
+
+// POST /list-account-freezes
+func (h *Handler) listAccountFreezes(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var after int64
+	if x.After != "" {
+		var err error
+		after, err = strconv.ParseInt(x.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(query.ErrBadAfter, "value: %q", x.After)
+		}
+	}
+
+	freezes, err := h.Accounts.ListFreezes(ctx, x.AccountID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	if len(freezes) > 0 {
+		outQuery.After = freezes[len(freezes)-1].ID
+	}
+
+	return &page{
+		Items:    httpjson.Array(freezes),
+		LastPage: len(freezes) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// POST /upgrade-account-quorum
+func (h *Handler) upgradeAccountQuorum(ctx context.Context, x struct {
+	AccountID    string         `json:"account_id"`
+	AccountAlias string         `json:"account_alias"`
+	RootXPubs    []chainkd.XPub `json:"root_xpubs"`
+	Quorum       int
+	ClientToken  string `json:"client_token"`
+}) (*account.Upgrade, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.UpgradeQuorum(ctx, accountID, x.RootXPubs, x.Quorum, x.ClientToken)
+}
+
+// rotateAccountKeys replaces an account's keys and quorum
+// for control programs created from now on. Outputs already
+// controlled by the account still need the old keys to spend.
+//
+// POST /rotate-account-keys
+func (h *Handler) rotateAccountKeys(ctx context.Context, x struct {
+	AccountID    string         `json:"account_id"`
+	AccountAlias string         `json:"account_alias"`
+	RootXPubs    []chainkd.XPub `json:"root_xpubs"`
+	Quorum       int
+	ClientToken  string `json:"client_token"`
+}) (*account.KeyGeneration, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.RotateKeys(ctx, accountID, x.RootXPubs, x.Quorum, x.ClientToken)
+}
+
+// POST /list-account-key-generations
+func (h *Handler) listAccountKeyGenerations(ctx context.Context, x struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) (interface{}, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	gens, err := h.Accounts.KeyGenerations(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(gens), nil
+}
+
+// exportAccountDerivationAudit returns every control program
+// derived for an account, with the derivation paths, keys and
+// first uses an auditor needs to verify them independently.
+//
+// POST /export-account-derivation-audit
+func (h *Handler) exportAccountDerivationAudit(ctx context.Context, x struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) (*account.DerivationAudit, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.DerivationAudit(ctx, accountID)
+}
+
+// setAccountQuorumTiers replaces an account's amount-tiered
+// signing policy for control programs created from now on.
+// It requires an admin access token.
+//
+// POST /set-account-quorum-tiers
+func (h *Handler) setAccountQuorumTiers(ctx context.Context, x struct {
+	AccountID    string               `json:"account_id"`
+	AccountAlias string               `json:"account_alias"`
+	Tiers        []account.QuorumTier `json:"tiers"`
+}) (interface{}, error) {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := h.Accounts.SetQuorumTiers(ctx, accountID, x.Tiers)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(tiers), nil
+}
+
+// POST /list-account-quorum-tiers
+func (h *Handler) listAccountQuorumTiers(ctx context.Context, x struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}) (interface{}, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := h.Accounts.QuorumTiers(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(tiers), nil
+}
+
+type accountMigrationRequest struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+}
+
+// POST /get-account-migration
+func (h *Handler) getAccountMigration(ctx context.Context, x accountMigrationRequest) (*account.Upgrade, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.Migration(ctx, accountID)
+}
+
+// buildAccountMigration builds a transaction template moving
+// all of an upgraded account's funds to its successor. It must
+// be signed with the original account's keys.
+//
+// POST /build-account-migration
+func (h *Handler) buildAccountMigration(ctx context.Context, x accountMigrationRequest) (*txbuilder.Template, error) {
+	// Like /build-transaction, this needs the leader's reservations.
+	if !leader.IsLeading() {
+		var tpl txbuilder.Template
+		err := h.forwardToLeader(ctx, "/build-account-migration", x, &tpl)
+		return &tpl, err
+	}
+
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := h.Accounts.MigrationActions(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := txbuilder.Build(ctx, nil, actions, time.Now().Add(defaultTxTTL))
+	if err != nil {
+		return nil, err
+	}
+	if tpl.SigningInstructions == nil {
+		tpl.SigningInstructions = []*txbuilder.SigningInstruction{}
+	}
+	return tpl, nil
 }
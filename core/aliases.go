@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"chain/core/asset"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+const (
+	// maxResolveAliases bounds the number of aliases
+	// and IDs of each kind in a /resolve-aliases request.
+	maxResolveAliases = 1000
+
+	// resolveAliasesMaxAge is how long, in seconds, clients may
+	// cache a /resolve-aliases response. Asset aliases can be
+	// changed, and an unresolved alias may be taken at any time,
+	// so this is kept short.
+	resolveAliasesMaxAge = 60
+)
+
+// POST /resolve-aliases
+//
+// resolveAliases maps account and asset aliases to IDs, and IDs to
+// aliases, so a client can look up everything it needs to build a
+// transaction in one request. Aliases and IDs that aren't found are
+// left out of the response.
+func (h *Handler) resolveAliases(ctx context.Context, x struct {
+	AccountAliases []string     `json:"account_aliases"`
+	AccountIDs     []string     `json:"account_ids"`
+	AssetAliases   []string     `json:"asset_aliases"`
+	AssetIDs       []bc.AssetID `json:"asset_ids"`
+}) (interface{}, error) {
+	for _, n := range []int{len(x.AccountAliases), len(x.AccountIDs), len(x.AssetAliases), len(x.AssetIDs)} {
+		if n > maxResolveAliases {
+			return nil, errors.WithDetailf(httpjson.ErrBadRequest, "at most %d aliases or IDs of each kind", maxResolveAliases)
+		}
+	}
+
+	accountIDs, accountAliases, err := h.Accounts.ResolveAliases(ctx, x.AccountAliases, x.AccountIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Asset aliases are looked up in the namespace of the
+	// access token, and reported as they were requested.
+	requested := make(map[string]string, len(x.AssetAliases))
+	qualified := make([]string, 0, len(x.AssetAliases))
+	for _, alias := range x.AssetAliases {
+		q, err := qualifyAssetAlias(ctx, alias)
+		if err != nil {
+			return nil, err
+		}
+		requested[q] = alias
+		qualified = append(qualified, q)
+	}
+	found, assetAliases, err := h.Assets.ResolveAliases(ctx, qualified, x.AssetIDs)
+	if err != nil {
+		return nil, err
+	}
+	assetIDs := make(map[string]bc.AssetID, len(found))
+	for q, id := range found {
+		assetIDs[requested[q]] = id
+	}
+
+	cacheControl := fmt.Sprintf("private, max-age=%d", resolveAliasesMaxAge)
+	httpjson.ResponseWriter(ctx).Header().Set("Cache-Control", cacheControl)
+
+	return struct {
+		AccountIDs     map[string]string     `json:"account_ids"`
+		AccountAliases map[string]string     `json:"account_aliases"`
+		AssetIDs       map[string]bc.AssetID `json:"asset_ids"`
+		AssetAliases   map[bc.AssetID]string `json:"asset_aliases"`
+	}{accountIDs, accountAliases, assetIDs, assetAliases}, nil
+}
+
+// qualifyAssetAlias returns an asset alias given in a request
+// as seen from the namespace of the request's access token.
+func qualifyAssetAlias(ctx context.Context, alias string) (string, error) {
+	return asset.QualifyAlias(aliasNamespace(ctx), alias)
+}
+
+// findAssetByAlias looks up an asset by an alias given in
+// a request, in the namespace of its access token.
+func (h *Handler) findAssetByAlias(ctx context.Context, alias string) (*asset.Asset, error) {
+	alias, err := qualifyAssetAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Assets.FindByAlias(ctx, alias)
+}
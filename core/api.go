@@ -12,16 +12,26 @@ import (
 
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
+	"chain/core/balancehook"
 	"chain/core/config"
+	"chain/core/counterparty"
+	"chain/core/dupdetect"
+	"chain/core/feedsink"
 	"chain/core/leader"
 	"chain/core/mockhsm"
+	"chain/core/msgrelay"
 	"chain/core/pin"
+	"chain/core/progtemplate"
 	"chain/core/query"
+	"chain/core/quota"
 	"chain/core/rpc"
+	"chain/core/swap"
 	"chain/core/txbuilder"
 	"chain/core/txdb"
 	"chain/core/txfeed"
+	"chain/core/watch"
 	"chain/database/pg"
 	"chain/encoding/json"
 	"chain/errors"
@@ -51,22 +61,50 @@ var (
 
 // Handler serves the Chain HTTP API
 type Handler struct {
-	Chain         *protocol.Chain
-	Store         *txdb.Store
-	PinStore      *pin.Store
-	Assets        *asset.Registry
-	Accounts      *account.Manager
-	HSM           *mockhsm.HSM
-	Indexer       *query.Indexer
-	TxFeeds       *txfeed.Tracker
-	AccessTokens  *accesstoken.CredentialStore
-	Config        *config.Config
-	Submitter     txbuilder.Submitter
-	DB            pg.DB
-	Addr          string
-	AltAuth       func(*http.Request) bool
-	Signer        func(context.Context, *bc.Block) ([]byte, error)
-	RequestLimits []RequestLimit
+	Chain          *protocol.Chain
+	Store          *txdb.Store
+	PinStore       *pin.Store
+	Assets         *asset.Registry
+	Accounts       *account.Manager
+	HSM            *mockhsm.HSM
+	Indexer        *query.Indexer
+	TxFeeds        *txfeed.Tracker
+	AccessTokens   *accesstoken.CredentialStore
+	Approvals      *approval.Manager
+	Quotas         *quota.Manager
+	Counterparties *counterparty.Registry
+	Templates      *progtemplate.Registry
+	Swaps          *swap.Book
+	Duplicates     *dupdetect.Detector
+	Watches        *watch.Registry
+	BalanceHooks   *balancehook.Notifier
+	FeedSinks      *feedsink.Pusher
+	Messages       *msgrelay.Relay
+	Config         *config.Config
+	Submitter      txbuilder.Submitter
+	DB             pg.DB
+	Addr           string
+	AltAuth        func(*http.Request) bool
+	Signer         func(context.Context, *bc.Block) ([]byte, error)
+	RequestLimits  []RequestLimit
+
+	// RPCReplayWindow, if nonzero, is how far the time of a
+	// request authenticated with a network token may be from
+	// the current time. Such requests are rejected if they're
+	// outside the window or reuse a nonce from within it.
+	RPCReplayWindow time.Duration
+
+	// ClientTokenWindow is how long a submit result is kept
+	// for retries with the same client token. Zero means
+	// DefaultClientTokenWindow.
+	ClientTokenWindow time.Duration
+
+	// Replica, if set, makes this process a query replica: it
+	// serves only query and list requests, and never leads.
+	// MaxStaleness, if nonzero, is how far behind the leader
+	// a replica may be and still serve requests.
+	Replica      bool
+	MaxStaleness time.Duration
 
 	once           sync.Once
 	handler        http.Handler
@@ -74,6 +112,12 @@ type Handler struct {
 
 	healthMu     sync.Mutex
 	healthErrors map[string]interface{}
+
+	replica replicaState
+
+	// authn is set by init. Token handlers use it
+	// to drop cached checks of changed tokens.
+	authn *apiAuthn
 }
 
 type RequestLimit struct {
@@ -99,7 +143,11 @@ func (h *Handler) init() {
 	h.actionDecoders = map[string]func(data []byte) (txbuilder.Action, error){
 		"control_account":                h.Accounts.DecodeControlAction,
 		"control_program":                txbuilder.DecodeControlProgramAction,
+		"control_program_time_locked":    txbuilder.DecodeControlTimeLockedAction,
+		"control_with_template":          h.Templates.DecodeControlWithTemplateAction,
 		"issue":                          h.Assets.DecodeIssueAction,
+		"issue_many":                     h.Assets.DecodeIssueManyAction,
+		"pay_fee":                        txbuilder.DecodePayFeeAction,
 		"spend_account":                  h.Accounts.DecodeSpendAction,
 		"spend_account_unspent_output":   h.Accounts.DecodeSpendUTXOAction,
 		"set_transaction_reference_data": txbuilder.DecodeSetTxRefDataAction,
@@ -117,9 +165,31 @@ func (h *Handler) init() {
 	m.Handle("/", alwaysError(errNotFound))
 
 	m.Handle("/create-account", needConfig(h.createAccount))
+	m.Handle("/update-account-tags", needConfig(h.updateAccountTags))
 	m.Handle("/create-asset", needConfig(h.createAsset))
+	m.Handle("/update-asset", needConfig(h.updateAsset))
+	m.Handle("/import-asset", needConfig(h.importAsset))
+	m.Handle("/archive-asset", needConfig(h.archiveAsset))
+	m.Handle("/unarchive-asset", needConfig(h.unarchiveAsset))
 	m.Handle("/build-transaction", needConfig(h.build))
+	m.Handle("/extend-transaction-reservations", needConfig(h.extendReservations))
+	m.Handle("/release-transaction-reservations", needConfig(h.releaseReservations))
+	m.Handle("/set-build-quota", needConfig(h.setBuildQuota))
+	m.Handle("/delete-build-quota", needConfig(h.deleteBuildQuota))
+	m.Handle("/list-build-quotas", needConfig(h.listBuildQuotas))
+	m.Handle("/get-signing-hashes", needConfig(h.getSigningHashes))
+	m.Handle("/add-signatures", needConfig(h.addSignatures))
+	m.Handle("/encode-transaction-templates", needConfig(h.encodeTemplates))
+	m.Handle("/decode-transaction-templates", needConfig(h.decodeTemplates))
+	m.Handle("/merge-transaction-templates", needConfig(h.mergeTemplates))
+	m.Handle("/get-transaction-effects", needConfig(h.getTransactionEffects))
+	m.Handle("/inspect-transaction-templates", needConfig(h.inspectTemplates))
 	m.Handle("/submit-transaction", needConfig(h.submit))
+	m.Handle("/validate-transaction", needConfig(h.validateTransactions))
+	m.Handle("/check-transaction-conflicts", needConfig(h.checkTransactionConflicts))
+	m.Handle("/submit-raw-transaction", needConfig(h.submitRaw))
+	m.Handle("/submit-transaction-async", needConfig(h.submitAsync))
+	m.Handle("/get-submission-status", needConfig(h.getSubmissionStatus))
 	m.Handle("/create-control-program", needConfig(h.createControlProgram))
 	m.Handle("/create-transaction-feed", needConfig(h.createTxFeed))
 	m.Handle("/get-transaction-feed", needConfig(h.getTxFeed))
@@ -129,21 +199,101 @@ func (h *Handler) init() {
 	m.Handle("/mockhsm/list-keys", needConfig(h.mockhsmListKeys))
 	m.Handle("/mockhsm/delkey", needConfig(h.mockhsmDelKey))
 	m.Handle("/mockhsm/sign-transaction", needConfig(h.mockhsmSignTemplates))
+	m.Handle("/set-issuance-approval-policy", needConfig(h.setIssuanceApprovalPolicy))
+	m.Handle("/list-issuance-approval-policies", needConfig(h.listIssuanceApprovalPolicies))
+	m.Handle("/approve-issuance", needConfig(h.approveIssuance))
+	m.Handle("/get-issuance-approval", needConfig(h.getIssuanceApproval))
 	m.Handle("/list-accounts", needConfig(h.listAccounts))
+	m.Handle("/rotate-asset-keys", needConfig(h.rotateAssetKeys))
 	m.Handle("/list-assets", needConfig(h.listAssets))
+	m.Handle("/stream-assets", http.HandlerFunc(h.streamAssets))
+	m.Handle("/get-state-diff", http.HandlerFunc(h.getStateDiff))
 	m.Handle("/list-transaction-feeds", needConfig(h.listTxFeeds))
 	m.Handle("/list-transactions", needConfig(h.listTransactions))
 	m.Handle("/list-balances", needConfig(h.listBalances))
+	m.Handle("/resolve-aliases", needConfig(h.resolveAliases))
+	m.Handle("/set-counterparty-label", needConfig(h.setCounterpartyLabel))
+	m.Handle("/list-counterparty-labels", needConfig(h.listCounterpartyLabels))
+	m.Handle("/register-program-template", needConfig(h.registerProgramTemplate))
+	m.Handle("/list-program-templates", needConfig(h.listProgramTemplates))
+	m.Handle("/create-swap-offer", needConfig(h.createSwapOffer))
+	m.Handle("/list-swap-offers", needConfig(h.listSwapOffers))
+	m.Handle("/get-swap-offer", needConfig(h.getSwapOffer))
+	m.Handle("/cancel-swap-offer", needConfig(h.cancelSwapOffer))
+	m.Handle("/fill-swap-offer", needConfig(h.fillSwapOffer))
+	m.Handle("/list-duplicate-payments", needConfig(h.listDuplicatePayments))
+	m.Handle("/import-watch-descriptor", needConfig(h.importWatchDescriptor))
+	m.Handle("/list-watch-descriptors", needConfig(h.listWatchDescriptors))
+	m.Handle("/get-watch-balances", needConfig(h.getWatchBalances))
+	m.Handle("/create-balance-webhook", needConfig(h.createBalanceWebhook))
+	m.Handle("/list-balance-webhooks", needConfig(h.listBalanceWebhooks))
+	m.Handle("/delete-balance-webhook", needConfig(h.deleteBalanceWebhook))
+	m.Handle("/create-balance-alert-rule", needConfig(h.createBalanceAlertRule))
+	m.Handle("/list-balance-alert-rules", needConfig(h.listBalanceAlertRules))
+	m.Handle("/delete-balance-alert-rule", needConfig(h.deleteBalanceAlertRule))
+	m.Handle("/list-balance-alerts", needConfig(h.listBalanceAlerts))
+	m.Handle("/send-message", needConfig(h.sendMessage))
+	m.Handle("/fetch-messages", needConfig(h.fetchMessages))
+	m.Handle("/list-reservation-conflicts", needConfig(h.listReservationConflicts))
+	m.Handle("/freeze-account", needConfig(h.freezeAccount))
+	m.Handle("/unfreeze-account", needConfig(h.unfreezeAccount))
+	m.Handle("/archive-account", needConfig(h.archiveAccount))
+	m.Handle("/list-account-freezes", needConfig(h.listAccountFreezes))
+	m.Handle("/create-account-receiver", needConfig(h.createAccountReceiver))
+	m.Handle("/extend-account-receiver", needConfig(h.extendAccountReceiver))
+	m.Handle("/list-account-receivers", needConfig(h.listAccountReceivers))
+	m.Handle("/create-payment-uri", needConfig(h.createPaymentURI))
+	m.Handle("/parse-payment-uri", needConfig(h.parsePaymentURI))
+	m.Handle("/set-account-spending-limit", needConfig(h.setAccountSpendingLimit))
+	m.Handle("/delete-account-spending-limit", needConfig(h.deleteAccountSpendingLimit))
+	m.Handle("/list-account-spending-limits", needConfig(h.listAccountSpendingLimits))
+	m.Handle("/upgrade-account-quorum", needConfig(h.upgradeAccountQuorum))
+	m.Handle("/get-account-migration", needConfig(h.getAccountMigration))
+	m.Handle("/rotate-account-keys", needConfig(h.rotateAccountKeys))
+	m.Handle("/list-account-key-generations", needConfig(h.listAccountKeyGenerations))
+	m.Handle("/export-account-derivation-audit", needConfig(h.exportAccountDerivationAudit))
+	m.Handle("/set-account-quorum-tiers", needConfig(h.setAccountQuorumTiers))
+	m.Handle("/list-account-quorum-tiers", needConfig(h.listAccountQuorumTiers))
+	m.Handle("/build-account-migration", needConfig(h.buildAccountMigration))
+	m.Handle("/get-transaction-failure", needConfig(h.getTransactionFailure))
+	m.Handle("/get-transaction-rejection", needConfig(h.getTxRejection))
+	m.Handle("/list-transaction-rejections", needConfig(h.listTxRejections))
+	m.Handle("/disassemble-program", needConfig(h.disassembleProgram))
+	m.Handle("/get-network-info", needConfig(h.getNetworkInfo))
+	m.Handle("/list-pending-transactions", needConfig(h.listPendingTransactions))
+	m.Handle("/count-pending-transactions", needConfig(h.countPendingTransactions))
+	m.Handle("/get-pending-transaction", needConfig(h.getPendingTransaction))
+	m.Handle("/evict-pending-transaction", needConfig(h.evictPendingTransaction))
+	m.Handle("/get-generator-ordering", needConfig(h.getGeneratorOrdering))
+	m.Handle("/set-generator-ordering", needConfig(h.setGeneratorOrdering))
+	m.Handle("/preview-generator-block", needConfig(h.previewBlock))
+	m.Handle("/list-supply-caps", needConfig(h.listSupplyCaps))
+	m.Handle("/propose-supply-caps", needConfig(h.proposeSupplyCaps))
+	m.Handle("/list-pending-supply-caps", needConfig(h.listPendingSupplyCaps))
+	m.Handle("/approve-supply-caps", needConfig(h.approveSupplyCaps))
+	m.Handle("/generate-account-statement", needConfig(h.generateAccountStatement))
+	m.Handle("/export-analytics-flows", needConfig(h.exportAnalyticsFlows))
+	m.Handle("/list-asset-circulation", needConfig(h.listAssetCirculation))
+	m.Handle("/create-query-session", needConfig(h.createQuerySession))
 	m.Handle("/list-unspent-outputs", needConfig(h.listUnspentOutputs))
 	m.Handle("/reset", needConfig(h.reset))
 
 	m.Handle(networkRPCPrefix+"submit", needConfig(func(ctx context.Context, tx *bc.Tx) error {
-		return h.Submitter.Submit(ctx, tx)
+		return h.Submitter.Submit(submitterContext(ctx), tx)
 	}))
+	m.Handle(networkRPCPrefix+"submit-batch", needConfig(h.submitBatchRPC))
+	m.Handle(networkRPCPrefix+"check-conflicts", needConfig(h.checkConflictsRPC))
+	m.Handle(networkRPCPrefix+"list-rejections", needConfig(h.listRejectionsRPC))
 	m.Handle(networkRPCPrefix+"get-blocks", needConfig(h.getBlocksRPC)) // DEPRECATED: use get-block instead
 	m.Handle(networkRPCPrefix+"get-block", needConfig(h.getBlockRPC))
+	m.Handle(networkRPCPrefix+"get-block-range", http.HandlerFunc(h.getBlockRangeRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot-info", needConfig(h.getSnapshotInfoRPC))
 	m.Handle(networkRPCPrefix+"get-snapshot", http.HandlerFunc(h.getSnapshotRPC))
+	m.Handle(networkRPCPrefix+"stream-block-headers", http.HandlerFunc(h.streamBlockHeadersRPC))
+	m.Handle(networkRPCPrefix+"get-relay-key", needConfig(h.getRelayKeyRPC))
+	m.Handle(networkRPCPrefix+"deliver-message", needConfig(h.deliverMessageRPC))
+	m.Handle(networkRPCPrefix+"get-attestation", needConfig(h.getAttestationRPC))
+	m.Handle(networkRPCPrefix+"preview-block", needConfig(h.previewBlockRPC))
 	m.Handle(networkRPCPrefix+"signer/sign-block", needConfig(h.leaderSignHandler(h.Signer)))
 	m.Handle(networkRPCPrefix+"block-height", needConfig(func(ctx context.Context) map[string]uint64 {
 		h := h.Chain.Height()
@@ -152,12 +302,21 @@ func (h *Handler) init() {
 		}
 	}))
 
+	m.Handle(explorerPrefix+"search", needConfig(h.explorerSearch))
+	m.Handle(explorerPrefix+"list-blocks", needConfig(h.explorerListBlocks))
+	m.Handle(explorerPrefix+"stats", needConfig(h.explorerStats))
+	m.Handle(explorerPrefix+"list-asset-circulation", needConfig(h.explorerListAssetCirculation))
+
 	m.Handle("/create-access-token", jsonHandler(h.createAccessToken))
 	m.Handle("/list-access-tokens", jsonHandler(h.listAccessTokens))
+	m.Handle("/rotate-access-token", jsonHandler(h.rotateAccessToken))
 	m.Handle("/delete-access-token", jsonHandler(h.deleteAccessToken))
+	m.Handle("/list-token-usage", jsonHandler(h.listTokenUsage))
 	m.Handle("/configure", jsonHandler(h.configure))
 	m.Handle("/info", jsonHandler(h.info))
+	m.Handle("/errors", jsonHandler(ErrorCatalog))
 
+	m.Handle("/debug/query-stats", needConfig(h.queryStats))
 	m.Handle("/debug/vars", http.HandlerFunc(expvarHandler))
 	m.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
 	m.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
@@ -171,14 +330,20 @@ func (h *Handler) init() {
 		m.ServeHTTP(w, req)
 	})
 
-	var handler = (&apiAuthn{
+	authn := &apiAuthn{
 		tokens:   h.AccessTokens,
 		tokenMap: make(map[string]tokenResult),
 		alt:      h.AltAuth,
-	}).handler(latencyHandler)
+	}
+	if h.RPCReplayWindow > 0 {
+		authn.replay = newReplayGuard(h.DB, h.RPCReplayWindow)
+	}
+	h.authn = authn
+	var handler = authn.handler(usageHandler(latencyHandler))
 	handler = maxBytes(handler)
 	handler = webAssetsHandler(handler)
 	handler = healthHandler(handler)
+	handler = h.replicaHandler(handler)
 	for _, l := range h.RequestLimits {
 		handler = limit.Handler(handler, alwaysError(errRateLimited), l.PerSecond, l.Burst, l.Key)
 	}
@@ -203,7 +368,7 @@ type requestQuery struct {
 	PageSize     int           `json:"page_size"`
 
 	// AscLongPoll and Timeout are used by /list-transactions
-	// to facilitate notifications.
+	// and /list-balance-alerts to facilitate notifications.
 	AscLongPoll bool          `json:"ascending_with_long_poll,omitempty"`
 	Timeout     json.Duration `json:"timeout"`
 
@@ -221,11 +386,43 @@ type requestQuery struct {
 	TimestampMS uint64 `json:"timestamp,omitempty"`
 
 	// This is used for filtering results from /list-access-tokens
-	// Value must be "client" or "network"
+	// Value must be "client", "network", "explorer" or "admin"
 	Type string `json:"type"`
 
+	// DisplayAssetID and DisplayAssetAlias are used by /list-balances
+	// and /list-transactions to show amounts converted to a display asset.
+	DisplayAssetID    string `json:"display_asset_id,omitempty"`
+	DisplayAssetAlias string `json:"display_asset_alias,omitempty"`
+
+	// IncludePending is used by /list-balances and /list-transactions
+	// to include transactions submitted by this core but not yet in
+	// a block.
+	IncludePending bool `json:"include_pending,omitempty"`
+
+	// IncludeSpent is used by /list-unspent-outputs to include
+	// outputs spent before the query's timestamp.
+	IncludeSpent bool `json:"include_spent,omitempty"`
+
+	// Session is used by /list-balances, /list-unspent-outputs
+	// and /list-transactions to query the blockchain as of
+	// the block pinned by /create-query-session.
+	Session *query.Checkpoint `json:"session,omitempty"`
+
+	// IncludeArchived is used by /list-assets and /list-accounts
+	// to include archived assets and accounts.
+	IncludeArchived bool `json:"include_archived,omitempty"`
+
+	// AccountID is used to filter results from /list-account-freezes,
+	// and is required by /list-account-receivers.
+	AccountID string `json:"account_id,omitempty"`
+
 	// Aliases is used to filter results from /mockshm/list-keys
 	Aliases []string `json:"aliases,omitempty"`
+
+	// OfferedAssetID and RequestedAssetID are used
+	// to filter results from /list-swap-offers.
+	OfferedAssetID   *bc.AssetID `json:"offered_asset_id,omitempty"`
+	RequestedAssetID *bc.AssetID `json:"requested_asset_id,omitempty"`
 }
 
 // Used as a response object for api queries
@@ -284,7 +481,7 @@ func (h *Handler) leaderSignHandler(f func(context.Context, *bc.Block) ([]byte,
 		}
 		var resp []byte
 		err := h.forwardToLeader(ctx, "/rpc/signer/sign-block", b, &resp)
-		return resp, err
+		return resp, SignBlockError(err)
 	}
 }
 
@@ -310,12 +507,17 @@ func (h *Handler) forwardToLeader(ctx context.Context, path string, body interfa
 		BaseURL: "http://" + addr,
 	}
 
+	// Forward the query parameters, such as batch options.
+	req := httpjson.Request(ctx)
+	if req.URL.RawQuery != "" {
+		l.BaseURL += "?" + req.URL.RawQuery
+	}
+
 	// Forward the request credentials if we have them.
 	// TODO(jackson): Don't use the incoming request's credentials and
 	// have an alternative authentication scheme between processes of the
 	// same Core. For now, we only call the leader for the purpose of
 	// forwarding a request, so this is OK.
-	req := httpjson.Request(ctx)
 	user, pass, ok := req.BasicAuth()
 	if ok {
 		l.AccessToken = fmt.Sprintf("%s:%s", user, pass)
@@ -298,7 +298,7 @@ func TestTransfer(t *testing.T) {
 		testutil.FatalErr(t, err)
 	}
 	coretest.SignTxTemplate(t, ctx, txTemplate, &testutil.TestXPrv)
-	_, err = handler.submitSingle(ctx, txTemplate, "none")
+	_, err = handler.submitSingle(ctx, txTemplate, &submitArg{WaitUntil: "none"})
 	if err != nil && errors.Root(err) != context.DeadlineExceeded {
 		testutil.FatalErr(t, err)
 	}
@@ -338,7 +338,7 @@ func TestTransfer(t *testing.T) {
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	_, err = handler.submitSingle(ctx, txTemplate, "none")
+	_, err = handler.submitSingle(ctx, txTemplate, &submitArg{WaitUntil: "none"})
 	if err != nil && errors.Root(err) != context.DeadlineExceeded {
 		testutil.FatalErr(t, err)
 	}
@@ -0,0 +1,272 @@
+// Package approval implements an approval workflow for
+// transactions that issue assets.
+//
+// An asset may have a policy naming the access tokens that
+// can approve its issuance and how many of them must do so.
+// A transaction issuing such an asset is held pending until
+// enough approvers have approved it; until then, the core
+// won't sign or submit it. Each step is recorded, giving an
+// audit trail for every transaction that needed approval.
+package approval
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrPending is returned when a transaction issues an asset
+	// whose policy hasn't yet been satisfied.
+	ErrPending = errors.New("issuance pending approval")
+	// ErrNotApprover is returned when a token approves a transaction
+	// that issues no asset it may approve.
+	ErrNotApprover = errors.New("token is not an approver")
+	// ErrBadPolicy is returned when SetPolicy is called
+	// with an invalid policy.
+	ErrBadPolicy = errors.New("invalid approval policy")
+)
+
+// Events in the audit trail of a transaction.
+const (
+	EventRequested = "requested"
+	EventApproved  = "approved"
+	EventSigned    = "signed"
+	EventSubmitted = "submitted"
+)
+
+// Policy says who must approve the issuance of an asset.
+type Policy struct {
+	AssetID   bc.AssetID `json:"asset_id"`
+	Threshold int        `json:"threshold"`
+	Approvers []string   `json:"approvers"`
+}
+
+// AssetStatus reports the approvals given toward
+// the policy of one asset issued by a transaction.
+type AssetStatus struct {
+	Policy
+	Approvals []string `json:"approvals"`
+}
+
+func (s *AssetStatus) approved() bool {
+	return len(s.Approvals) >= s.Threshold
+}
+
+// Event is one entry in the audit trail of a transaction.
+type Event struct {
+	Type    string    `json:"type"`
+	TokenID string    `json:"token_id"`
+	Time    time.Time `json:"time"`
+}
+
+// Request is a transaction that needs approval, with its
+// current status and its audit trail, oldest event first.
+type Request struct {
+	TxHash   bc.Hash        `json:"transaction_id"`
+	RawTx    *bc.TxData     `json:"raw_transaction"`
+	Approved bool           `json:"approved"`
+	Assets   []*AssetStatus `json:"assets"`
+	Events   []*Event       `json:"events"`
+}
+
+// Manager stores approval policies and tracks
+// the approval of transactions.
+type Manager struct {
+	DB pg.DB
+}
+
+// SetPolicy sets the approval policy for an asset.
+// A policy with no approvers and a zero threshold
+// removes the asset's policy.
+func (m *Manager) SetPolicy(ctx context.Context, p Policy) error {
+	if p.Threshold == 0 && len(p.Approvers) == 0 {
+		const q = `DELETE FROM issuance_approval_policies WHERE asset_id = $1`
+		_, err := m.DB.Exec(ctx, q, p.AssetID)
+		return errors.Wrap(err, "deleting approval policy")
+	}
+	if p.Threshold < 1 || p.Threshold > len(p.Approvers) {
+		return errors.WithDetailf(ErrBadPolicy, "threshold must be between 1 and %d", len(p.Approvers))
+	}
+	seen := make(map[string]bool)
+	for _, a := range p.Approvers {
+		if a == "" || seen[a] {
+			return errors.WithDetailf(ErrBadPolicy, "approver %q is empty or repeated", a)
+		}
+		seen[a] = true
+	}
+
+	const q = `
+		INSERT INTO issuance_approval_policies (asset_id, threshold, approvers)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (asset_id) DO UPDATE SET threshold = excluded.threshold, approvers = excluded.approvers
+	`
+	_, err := m.DB.Exec(ctx, q, p.AssetID, p.Threshold, pq.StringArray(p.Approvers))
+	return errors.Wrap(err, "saving approval policy")
+}
+
+// Policies returns the approval policies of all assets.
+func (m *Manager) Policies(ctx context.Context) ([]*Policy, error) {
+	const q = `
+		SELECT asset_id, threshold, approvers FROM issuance_approval_policies
+		ORDER BY asset_id
+	`
+	var policies []*Policy
+	err := pg.ForQueryRows(ctx, m.DB, q, func(assetID bc.AssetID, threshold int, approvers pq.StringArray) {
+		policies = append(policies, &Policy{AssetID: assetID, Threshold: threshold, Approvers: approvers})
+	})
+	return policies, errors.Wrap(err, "listing approval policies")
+}
+
+// Check reports whether tx issues any asset with an
+// approval policy. If it does and it doesn't yet have
+// enough approvals, Check records a request for approval
+// on behalf of tokenID and returns ErrPending.
+func (m *Manager) Check(ctx context.Context, tx *bc.TxData, tokenID string) (gated bool, err error) {
+	hash := tx.Hash()
+	assets, err := m.status(ctx, hash, tx)
+	if err != nil || len(assets) == 0 {
+		return false, err
+	}
+	for _, a := range assets {
+		if a.approved() {
+			continue
+		}
+
+		const q = `
+			WITH req AS (
+				INSERT INTO issuance_approval_requests (tx_hash, raw_tx) VALUES ($1, $2)
+				ON CONFLICT (tx_hash) DO NOTHING
+				RETURNING tx_hash
+			)
+			INSERT INTO issuance_approval_events (tx_hash, type, token_id)
+			SELECT tx_hash, $3, $4 FROM req
+		`
+		_, err = m.DB.Exec(ctx, q, hash, tx, EventRequested, tokenID)
+		if err != nil {
+			return true, errors.Wrap(err, "requesting approval")
+		}
+		err = errors.WithDetailf(ErrPending, "issuance of asset %s has %d of %d approvals", a.AssetID, len(a.Approvals), a.Threshold)
+		return true, errors.WithData(err, "transaction_id", hash)
+	}
+	return true, nil
+}
+
+// Record adds an event to the audit trail of the
+// transaction with the given hash.
+func (m *Manager) Record(ctx context.Context, hash bc.Hash, typ, tokenID string) error {
+	const q = `INSERT INTO issuance_approval_events (tx_hash, type, token_id) VALUES ($1, $2, $3)`
+	_, err := m.DB.Exec(ctx, q, hash, typ, tokenID)
+	return errors.Wrap(err, "recording approval event")
+}
+
+// Approve records tokenID's approval of the pending
+// transaction with the given hash. The token must be
+// an approver for at least one asset it issues.
+func (m *Manager) Approve(ctx context.Context, hash bc.Hash, tokenID string) (*Request, error) {
+	req, err := m.Get(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !isApprover(req.Assets, tokenID) {
+		return nil, errors.WithDetailf(ErrNotApprover, "token %q may not approve transaction %s", tokenID, hash)
+	}
+
+	const q = `
+		WITH a AS (
+			INSERT INTO issuance_approvals (tx_hash, token_id) VALUES ($1, $2)
+			ON CONFLICT (tx_hash, token_id) DO NOTHING
+			RETURNING tx_hash
+		)
+		INSERT INTO issuance_approval_events (tx_hash, type, token_id)
+		SELECT tx_hash, $3, $2 FROM a
+	`
+	_, err = m.DB.Exec(ctx, q, hash, tokenID, EventApproved)
+	if err != nil {
+		return nil, errors.Wrap(err, "approving transaction")
+	}
+	return m.Get(ctx, hash)
+}
+
+func isApprover(assets []*AssetStatus, tokenID string) bool {
+	for _, a := range assets {
+		for _, id := range a.Approvers {
+			if id == tokenID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Get returns the approval request for the
+// transaction with the given hash.
+func (m *Manager) Get(ctx context.Context, hash bc.Hash) (*Request, error) {
+	req := &Request{TxHash: hash, RawTx: new(bc.TxData)}
+	const q = `SELECT raw_tx FROM issuance_approval_requests WHERE tx_hash = $1`
+	err := m.DB.QueryRow(ctx, q, hash).Scan(req.RawTx)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no approval request for transaction %s", hash)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching approval request")
+	}
+
+	req.Assets, err = m.status(ctx, hash, req.RawTx)
+	if err != nil {
+		return nil, err
+	}
+	req.Approved = true
+	for _, a := range req.Assets {
+		req.Approved = req.Approved && a.approved()
+	}
+
+	const eventsQ = `
+		SELECT type, token_id, created_at FROM issuance_approval_events
+		WHERE tx_hash = $1 ORDER BY id
+	`
+	err = pg.ForQueryRows(ctx, m.DB, eventsQ, hash, func(typ, tokenID string, t time.Time) {
+		req.Events = append(req.Events, &Event{Type: typ, TokenID: tokenID, Time: t})
+	})
+	return req, errors.Wrap(err, "listing approval events")
+}
+
+// status returns the approval status of each asset issued by tx
+// that has an approval policy. Only approvals by tokens
+// the current policy names as approvers are counted.
+func (m *Manager) status(ctx context.Context, hash bc.Hash, tx *bc.TxData) ([]*AssetStatus, error) {
+	var assetIDs pq.ByteaArray
+	for _, in := range tx.Inputs {
+		if in.IsIssuance() {
+			assetID := in.AssetID()
+			assetIDs = append(assetIDs, assetID[:])
+		}
+	}
+	if len(assetIDs) == 0 {
+		return nil, nil
+	}
+
+	const q = `
+		SELECT p.asset_id, p.threshold, p.approvers,
+			array(SELECT a.token_id FROM issuance_approvals a
+				WHERE a.tx_hash = $2 AND a.token_id = ANY(p.approvers)
+				ORDER BY a.created_at)
+		FROM issuance_approval_policies p
+		WHERE p.asset_id = ANY($1::bytea[])
+		ORDER BY p.asset_id
+	`
+	var assets []*AssetStatus
+	err := pg.ForQueryRows(ctx, m.DB, q, assetIDs, hash, func(assetID bc.AssetID, threshold int, approvers, approvals pq.StringArray) {
+		assets = append(assets, &AssetStatus{
+			Policy:    Policy{AssetID: assetID, Threshold: threshold, Approvers: approvers},
+			Approvals: approvals,
+		})
+	})
+	return assets, errors.Wrapf(err, "checking approvals for transaction %s", hash)
+}
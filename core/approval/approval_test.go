@@ -0,0 +1,78 @@
+package approval
+
+import (
+	"context"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestApprovalWorkflow(t *testing.T) {
+	ctx := context.Background()
+	m := &Manager{DB: pgtest.NewTx(t)}
+
+	in := bc.NewIssuanceInput([]byte{1}, 100, nil, bc.Hash{}, []byte{0x51}, nil, nil)
+	tx := &bc.TxData{Version: 1, Inputs: []*bc.TxInput{in}}
+	err := m.SetPolicy(ctx, Policy{AssetID: in.AssetID(), Threshold: 2, Approvers: []string{"alice", "bob", "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = m.Check(ctx, tx, "issuer")
+	if errors.Root(err) != ErrPending {
+		t.Fatalf("Check before approval = %v want %v", err, ErrPending)
+	}
+
+	_, err = m.Approve(ctx, tx.Hash(), "mallory")
+	if errors.Root(err) != ErrNotApprover {
+		t.Fatalf("Approve by non-approver = %v want %v", err, ErrNotApprover)
+	}
+	for _, id := range []string{"alice", "alice", "bob"} {
+		_, err = m.Approve(ctx, tx.Hash(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gated, err := m.Check(ctx, tx, "issuer")
+	if err != nil || !gated {
+		t.Fatalf("Check after approval = %t, %v want true, nil", gated, err)
+	}
+
+	req, err := m.Get(ctx, tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.Approved {
+		t.Error("request not approved")
+	}
+	var types []string
+	for _, e := range req.Events {
+		types = append(types, e.Type)
+	}
+	want := []string{EventRequested, EventApproved, EventApproved}
+	if len(types) != len(want) || types[0] != want[0] || types[1] != want[1] || types[2] != want[2] {
+		t.Errorf("events = %v want %v", types, want)
+	}
+}
+
+func TestSetPolicyErrors(t *testing.T) {
+	ctx := context.Background()
+	m := new(Manager) // invalid policies are rejected before using the database
+
+	cases := []Policy{
+		{Threshold: 1},
+		{Threshold: 0, Approvers: []string{"a"}},
+		{Threshold: 2, Approvers: []string{"a"}},
+		{Threshold: 1, Approvers: []string{"a", "a"}},
+		{Threshold: 1, Approvers: []string{""}},
+	}
+	for _, p := range cases {
+		err := m.SetPolicy(ctx, p)
+		if errors.Root(err) != ErrBadPolicy {
+			t.Errorf("SetPolicy(%+v) = %v want %v", p, err, ErrBadPolicy)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/approval"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errApprovalsDisabled = errors.New("issuance approvals are not enabled")
+
+// checkApproval returns approval.ErrPending if tpl issues
+// an asset whose approval policy hasn't been satisfied.
+// Otherwise, if tpl needed approval, it records event
+// in the transaction's audit trail once f succeeds.
+func (h *Handler) checkApproval(ctx context.Context, tpl *txbuilder.Template, event string, f func() error) error {
+	if h.Approvals == nil || tpl.Transaction == nil || !tpl.Transaction.HasIssuance() {
+		return f()
+	}
+	tokenID, _, _ := httpjson.Request(ctx).BasicAuth()
+	gated, err := h.Approvals.Check(ctx, tpl.Transaction, tokenID)
+	if err != nil {
+		return err
+	}
+	err = f()
+	if err != nil || !gated {
+		return err
+	}
+	return h.Approvals.Record(ctx, tpl.Transaction.Hash(), event, tokenID)
+}
+
+// setIssuanceApprovalPolicy sets or removes the approval policy
+// of an asset. It requires an admin access token, so the issuers
+// a policy restrains can't lift it.
+//
+// POST /set-issuance-approval-policy
+func (h *Handler) setIssuanceApprovalPolicy(ctx context.Context, p approval.Policy) error {
+	if h.Approvals == nil {
+		return errApprovalsDisabled
+	}
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	return h.Approvals.SetPolicy(ctx, p)
+}
+
+func (h *Handler) listIssuanceApprovalPolicies(ctx context.Context) ([]*approval.Policy, error) {
+	if h.Approvals == nil {
+		return nil, errApprovalsDisabled
+	}
+	return h.Approvals.Policies(ctx)
+}
+
+// approveIssuance records the approval of a pending
+// transaction by the access token authenticating the request.
+//
+// POST /approve-issuance
+func (h *Handler) approveIssuance(ctx context.Context, x struct {
+	TxID bc.Hash `json:"transaction_id"`
+}) (*approval.Request, error) {
+	if h.Approvals == nil {
+		return nil, errApprovalsDisabled
+	}
+	tokenID, _, _ := httpjson.Request(ctx).BasicAuth()
+	return h.Approvals.Approve(ctx, x.TxID, tokenID)
+}
+
+// getIssuanceApproval returns the approval status and
+// audit trail of a transaction that needed approval.
+//
+// POST /get-issuance-approval
+func (h *Handler) getIssuanceApproval(ctx context.Context, x struct {
+	TxID bc.Hash `json:"transaction_id"`
+}) (*approval.Request, error) {
+	if h.Approvals == nil {
+		return nil, errApprovalsDisabled
+	}
+	return h.Approvals.Get(ctx, x.TxID)
+}
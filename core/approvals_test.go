@@ -0,0 +1,39 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/accesstoken"
+	"chain/core/approval"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestSetIssuanceApprovalPolicyAdmin(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	h := &Handler{
+		AccessTokens: &accesstoken.CredentialStore{DB: db},
+		Approvals:    &approval.Manager{DB: db},
+	}
+	client, err := h.AccessTokens.Create(ctx, "issuer", "client", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := h.AccessTokens.Create(ctx, "admin", "admin", "", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The issuer can't lift the policy restraining it.
+	err = h.setIssuanceApprovalPolicy(tokenContext(t, client), approval.Policy{})
+	if errors.Root(err) != errNotAdmin {
+		t.Errorf("client setting policy: error = %v want %v", err, errNotAdmin)
+	}
+	err = h.setIssuanceApprovalPolicy(tokenContext(t, admin), approval.Policy{Threshold: 1, Approvers: []string{"admin"}})
+	if err != nil {
+		t.Errorf("admin setting policy: %v", err)
+	}
+}
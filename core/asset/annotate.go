@@ -10,10 +10,18 @@ import (
 	"chain/database/pg"
 	"chain/errors"
 	"chain/log"
+	"chain/protocol/bc"
 )
 
+// AnnotateTxs adds asset data to transactions. The data for every
+// asset appearing in txs is loaded in a single query.
 func (reg *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
-	assetIDStrMap := make(map[string]bool)
+	var (
+		empty        = map[string]interface{}{}
+		unknownAsset = &assetAnnotation{isLocal: "no", tags: empty, def: empty}
+		annotations  = make(map[string]*assetAnnotation)
+		assetIDs     pq.ByteaArray
+	)
 
 	// Collect all of the asset IDs appearing in the entire block. We only
 	// check the outputs because every transaction should balance.
@@ -34,51 +42,63 @@ func (reg *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface
 				log.Error(ctx, errors.Wrap(fmt.Errorf("bad asset_id type %T", out["asset_id"])))
 				continue
 			}
-			assetIDStrMap[assetIDStr] = true
+			if _, ok := annotations[assetIDStr]; ok {
+				continue
+			}
+			var assetID bc.AssetID
+			err := assetID.UnmarshalText([]byte(assetIDStr))
+			if err != nil {
+				log.Error(ctx, errors.Wrap(err, "bad asset_id"))
+				continue
+			}
+			annotations[assetIDStr] = nil
+			assetIDs = append(assetIDs, assetID[:])
 		}
 	}
-	if len(assetIDStrMap) == 0 {
+	if len(assetIDs) == 0 {
 		return nil
 	}
 
-	// Look up all the asset tags for all applicable assets.
-	assetIDStrs := make([]string, 0, len(assetIDStrMap))
-	for assetIDStr := range assetIDStrMap {
-		assetIDStrs = append(assetIDStrs, assetIDStr)
-	}
-	var (
-		tagsByAssetIDStr    = make(map[string]map[string]interface{}, len(assetIDStrs))
-		defsByAssetIDStr    = make(map[string]map[string]interface{}, len(assetIDStrs))
-		aliasesByAssetIDStr = make(map[string]string, len(assetIDStrs))
-		localByAssetIDStr   = make(map[string]bool, len(assetIDStrs))
-	)
+	// Look up the tags, alias and definition of every asset at once.
 	const q = `
-		SELECT encode(id, 'hex'), COALESCE(alias, ''), signer_id IS NOT NULL, tags, definition
+		SELECT id, COALESCE(alias, ''), signer_id IS NOT NULL, tags, definition, d.document
 		FROM assets
 		LEFT JOIN asset_tags ON asset_id=id
-		WHERE id IN (SELECT decode(unnest($1::text[]), 'hex'))
+		LEFT JOIN asset_definition_documents d
+			ON d.hash = CASE WHEN definition->'definition_reference'->>'hash' ~ '^[0-9a-f]{64}$'
+				THEN decode(definition->'definition_reference'->>'hash', 'hex') END
+		WHERE id IN (SELECT unnest($1::bytea[]))
 	`
-	err := pg.ForQueryRows(ctx, reg.db, q, pq.StringArray(assetIDStrs),
-		func(assetIDStr, alias string, local bool, tagsBlob []byte, defBlob []byte) error {
-			if alias != "" {
-				aliasesByAssetIDStr[assetIDStr] = alias
+	err := pg.ForQueryRows(ctx, reg.db, q, assetIDs,
+		func(assetID bc.AssetID, alias string, local bool, tagsBlob []byte, defBlob []byte, doc []byte) error {
+			a := &assetAnnotation{
+				alias:   alias,
+				isLocal: "no",
+				tags:    empty,
+				def:     empty,
+			}
+			if local {
+				a.isLocal = "yes"
 			}
-			localByAssetIDStr[assetIDStr] = local
 			if len(tagsBlob) > 0 {
 				var tags map[string]interface{}
 				err := json.Unmarshal(tagsBlob, &tags)
 				if err != nil {
 					return err
 				}
-				tagsByAssetIDStr[assetIDStr] = tags
+				if tags != nil {
+					a.tags = tags
+				}
 			}
 			if len(defBlob) > 0 {
 				var def map[string]interface{}
 				err := json.Unmarshal(defBlob, &def)
-				if err == nil { // ignore non-json defs
-					defsByAssetIDStr[assetIDStr] = def
+				if err == nil && def != nil { // ignore non-json defs
+					a.def = def
 				}
 			}
+			a.refDef = referencedDefinition(doc)
+			annotations[assetID.String()] = a
 			return nil
 		},
 	)
@@ -86,7 +106,18 @@ func (reg *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface
 		return errors.Wrap(err, "querying assets")
 	}
 
-	empty := map[string]interface{}{}
+	// Assets whose keys were rotated are annotated
+	// with the alias of their latest successor.
+	successorAliases, err := reg.successorAliases(ctx, assetIDs)
+	if err != nil {
+		return err
+	}
+	for id, alias := range successorAliases {
+		if a := annotations[id.String()]; a != nil && a.alias == "" {
+			a.alias = alias
+		}
+	}
+
 	applyAnnotations := func(s interface{}) {
 		asSlice, ok := s.([]interface{})
 		if !ok {
@@ -104,25 +135,18 @@ func (reg *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface
 				log.Error(ctx, errors.Wrap(fmt.Errorf("bad asset_id type %T", asMap["asset_id"])))
 				continue
 			}
-			tags := tagsByAssetIDStr[assetIDStr]
-			if tags != nil {
-				asMap["asset_tags"] = tags
-			} else {
-				asMap["asset_tags"] = empty
-			}
-			if alias, ok := aliasesByAssetIDStr[assetIDStr]; ok {
-				asMap["asset_alias"] = alias
+			a := annotations[assetIDStr]
+			if a == nil {
+				a = unknownAsset
 			}
-			if localByAssetIDStr[assetIDStr] {
-				asMap["asset_is_local"] = "yes"
-			} else {
-				asMap["asset_is_local"] = "no"
+			asMap["asset_tags"] = a.tags
+			if a.alias != "" {
+				asMap["asset_alias"] = a.alias
 			}
-			def := defsByAssetIDStr[assetIDStr]
-			if def != nil {
-				asMap["asset_definition"] = def
-			} else {
-				asMap["asset_definition"] = empty
+			asMap["asset_is_local"] = a.isLocal
+			asMap["asset_definition"] = a.def
+			if a.refDef != nil {
+				asMap["referenced_asset_definition"] = a.refDef
 			}
 		}
 	}
@@ -134,3 +158,13 @@ func (reg *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface
 	}
 	return nil
 }
+
+// assetAnnotation holds the annotations shared by
+// every input and output of one asset.
+type assetAnnotation struct {
+	alias   string
+	isLocal string
+	tags    map[string]interface{}
+	def     map[string]interface{}
+	refDef  map[string]interface{} // referenced definition document
+}
@@ -7,6 +7,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
 
 	"golang.org/x/crypto/sha3"
@@ -23,6 +24,7 @@ import (
 	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/vm"
 	"chain/protocol/vmutil"
 )
 
@@ -30,6 +32,10 @@ const maxAssetCache = 1000
 
 var ErrDuplicateAlias = errors.New("duplicate asset alias")
 
+// ErrArchived is returned when an archived
+// asset is named by its alias in a transaction.
+var ErrArchived = errors.New("asset is archived")
+
 func NewRegistry(db pg.DB, chain *protocol.Chain, pinStore *pin.Store) *Registry {
 	return &Registry{
 		db:               db,
@@ -48,6 +54,7 @@ type Registry struct {
 	indexer          Saver
 	initialBlockHash bc.Hash
 	pinStore         *pin.Store
+	defSchema        *Schema
 
 	idGroup    singleflight.Group
 	aliasGroup singleflight.Group
@@ -61,6 +68,13 @@ func (reg *Registry) IndexAssets(indexer Saver) {
 	reg.indexer = indexer
 }
 
+// RequireDefinitionSchema makes Define reject asset
+// definitions that don't conform to schema. It must
+// be called before the registry is in use.
+func (reg *Registry) RequireDefinitionSchema(schema *Schema) {
+	reg.defSchema = schema
+}
+
 type Asset struct {
 	AssetID          bc.AssetID
 	Alias            *string
@@ -69,15 +83,42 @@ type Asset struct {
 	InitialBlockHash bc.Hash
 	Signer           *signers.Signer
 	Tags             map[string]interface{}
-	sortID           string
+
+	// Archived is set for assets that are hidden from
+	// asset lists by default; see Archive.
+	Archived bool
+
+	// MaxIssuance, if nonzero, caps the total amount of the
+	// asset that may be issued; see Define.
+	MaxIssuance uint64
+
+	// SuccessorID is set once the asset's keys have been
+	// rotated, to the ID of the asset that replaces it;
+	// see RotateKeys.
+	SuccessorID *bc.AssetID
+
+	sortID string
 }
 
 func (asset *Asset) SerializedDefinition() ([]byte, error) {
 	return serializeAssetDef(asset.Definition)
 }
 
-// Define defines a new Asset.
-func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
+// Define defines a new Asset. If the registry requires a
+// definition schema, a definition that doesn't conform to it
+// is rejected with ErrBadDefinition.
+//
+// If maxIssuance is nonzero, it caps the issuance of the asset.
+// The issuance program rejects any issuance of more than
+// maxIssuance units, and the registry refuses to build an
+// issuance that would take the total issued beyond it. It
+// must be at most math.MaxInt64.
+func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum int, maxIssuance uint64, definition map[string]interface{}, alias string, tags map[string]interface{}, clientToken string) (*Asset, error) {
+	err := reg.checkDefinition(ctx, definition)
+	if err != nil {
+		return nil, err
+	}
+
 	assetSigner, err := signers.Create(ctx, reg.db, "asset", xpubs, quorum, clientToken)
 	if err != nil {
 		return nil, err
@@ -91,7 +132,7 @@ func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum in
 	path := signers.Path(assetSigner, signers.AssetKeySpace)
 	derivedXPubs := chainkd.DeriveXPubs(assetSigner.XPubs, path)
 	derivedPKs := chainkd.XPubKeys(derivedXPubs)
-	issuanceProgram, err := multisigIssuanceProgram(derivedPKs, assetSigner.Quorum)
+	issuanceProgram, err := multisigIssuanceProgram(derivedPKs, assetSigner.Quorum, maxIssuance)
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +145,7 @@ func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum in
 		AssetID:          bc.ComputeAssetID(issuanceProgram, reg.initialBlockHash, 1, defHash),
 		Signer:           assetSigner,
 		Tags:             tags,
+		MaxIssuance:      maxIssuance,
 	}
 	if alias != "" {
 		asset.Alias = &alias
@@ -118,12 +160,198 @@ func (reg *Registry) Define(ctx context.Context, xpubs []chainkd.XPub, quorum in
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting asset tags")
 	}
+	// A retried Define (with the same client token) may have
+	// replaced the tags of an asset we've already cached.
+	reg.invalidate(asset)
+
+	// A referenced definition document is cached before the
+	// asset is indexed, so the annotated asset can include it.
+	reg.resolveReferences(ctx, []map[string]interface{}{definition})
+
+	err = reg.indexAnnotatedAsset(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated asset")
+	}
+
+	return asset, nil
+}
+
+// Update changes the alias and tags of the asset with the given
+// ID. A nil alias or tags leaves that field unchanged. An empty
+// alias removes the asset's alias.
+//
+// Transactions and outputs already indexed are annotated again
+// with the new alias and tags, so queries on them find the
+// asset's history. A new alias is also given to the history of
+// the assets this one replaced; see RotateKeys.
+func (reg *Registry) Update(ctx context.Context, id bc.AssetID, alias *string, tags *map[string]interface{}) (*Asset, error) {
+	asset, err := assetQuery(ctx, reg.db, "assets.id=$1", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading asset")
+	}
+	// Remove the old alias and tags from the caches
+	// before anything else can see the new ones.
+	reg.invalidate(asset)
+
+	if alias != nil {
+		aliasSQL := sql.NullString{String: *alias, Valid: *alias != ""}
+		const q = `UPDATE assets SET alias = $2 WHERE id = $1`
+		_, err = reg.db.Exec(ctx, q, id, aliasSQL)
+		if pg.IsUniqueViolation(err) {
+			return nil, errors.WithDetail(ErrDuplicateAlias, "an asset with the provided alias already exists")
+		} else if err != nil {
+			return nil, errors.Wrap(err, "updating asset alias")
+		}
+		asset.Alias = nil
+		if aliasSQL.Valid {
+			asset.Alias = alias
+		}
+	}
+	if tags != nil {
+		err = insertAssetTags(ctx, reg.db, id, *tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "updating asset tags")
+		}
+		asset.Tags = *tags
+	}
+	reg.invalidate(asset)
 
 	err = reg.indexAnnotatedAsset(ctx, asset)
 	if err != nil {
 		return nil, errors.Wrap(err, "indexing annotated asset")
 	}
+	if reg.indexer != nil {
+		err = reg.indexer.UpdateAssetAnnotations(ctx, id, asset.Alias, asset.Tags)
+		if err != nil {
+			return nil, errors.Wrap(err, "annotating asset history")
+		}
+		if alias != nil {
+			err = reg.annotatePredecessors(ctx, asset)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return asset, nil
+}
+
+// Import registers an asset that this core hasn't yet seen on
+// the blockchain, so that its alias can name it in transaction
+// actions and queries before any of it arrives. The asset's
+// issuance program and definition are filled in when the core
+// sees it issued. Importing an asset the core already knows
+// sets its alias and tags, if given, as Update does.
+func (reg *Registry) Import(ctx context.Context, id bc.AssetID, alias string, tags map[string]interface{}) (*Asset, error) {
+	aliasSQL := sql.NullString{String: alias, Valid: alias != ""}
+	const q = `
+		INSERT INTO assets (id, alias, initial_block_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+	`
+	res, err := reg.db.Exec(ctx, q, id, aliasSQL, reg.initialBlockHash)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetail(ErrDuplicateAlias, "an asset with the provided alias already exists")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "importing asset")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if n == 0 {
+		var aliasp *string
+		var tagsp *map[string]interface{}
+		if alias != "" {
+			aliasp = &alias
+		}
+		if tags != nil {
+			tagsp = &tags
+		}
+		return reg.Update(ctx, id, aliasp, tagsp)
+	}
+
+	err = insertAssetTags(ctx, reg.db, id, tags)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting asset tags")
+	}
+	asset, err := assetQuery(ctx, reg.db, "assets.id=$1", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading asset")
+	}
+	err = reg.indexAnnotatedAsset(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated asset")
+	}
+	return asset, nil
+}
 
+// checkDefinition checks definition against the registry's
+// schema, if it has one. If definition references an off-chain
+// document, the document is fetched, and its fields are checked
+// along with the definition's own, other than the reference.
+func (reg *Registry) checkDefinition(ctx context.Context, definition map[string]interface{}) error {
+	if reg.defSchema == nil {
+		return nil
+	}
+	ref := definitionRef(definition)
+	def := make(map[string]interface{}, len(definition))
+	if ref != nil {
+		doc, err := reg.document(ctx, ref)
+		if err != nil {
+			return errors.WithDetailf(ErrBadReference, "referenced document: %s", err)
+		}
+		refDef := referencedDefinition(doc)
+		if refDef == nil {
+			return errors.WithDetail(ErrBadDefinition, "referenced document must be a JSON object")
+		}
+		for k, v := range refDef {
+			def[k] = v
+		}
+	}
+	for k, v := range definition {
+		if ref == nil || k != "definition_reference" {
+			def[k] = v
+		}
+	}
+	fieldErrs := reg.defSchema.Validate(def)
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+	err := errors.WithDetailf(ErrBadDefinition, "definition%s %s", fieldErrs[0].Path, fieldErrs[0].Message)
+	return errors.WithData(err, KeyFields, fieldErrs)
+}
+
+// Archive marks the asset with the given ID archived, or not,
+// according to archived. Archived assets are left out of asset
+// lists unless asked for, and their aliases can't be used to
+// build transactions. They remain in transactions and outputs
+// already indexed.
+func (reg *Registry) Archive(ctx context.Context, id bc.AssetID, archived bool) (*Asset, error) {
+	const q = `
+		UPDATE assets SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, now()) END
+		WHERE id = $1
+	`
+	res, err := reg.db.Exec(ctx, q, id, archived)
+	if err != nil {
+		return nil, errors.Wrap(err, "archiving asset")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if n == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "asset id %s", id)
+	}
+
+	asset, err := assetQuery(ctx, reg.db, "assets.id=$1", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading asset")
+	}
+	reg.invalidate(asset)
+
+	err = reg.indexAnnotatedAsset(ctx, asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "indexing annotated asset")
+	}
 	return asset, nil
 }
 
@@ -177,14 +405,78 @@ func (reg *Registry) FindByAlias(ctx context.Context, alias string) (*Asset, err
 
 }
 
+// ResolveAliases looks up the assets with the given aliases
+// and IDs in a single query. It returns the ID of each alias and
+// the alias of each ID. Aliases and IDs that don't belong to a
+// known asset, and assets without an alias, are left out.
+func (reg *Registry) ResolveAliases(ctx context.Context, aliases []string, ids []bc.AssetID) (idsByAlias map[string]bc.AssetID, aliasesByID map[bc.AssetID]string, err error) {
+	idsByAlias = make(map[string]bc.AssetID)
+	aliasesByID = make(map[bc.AssetID]string)
+	if len(aliases) == 0 && len(ids) == 0 {
+		return idsByAlias, aliasesByID, nil
+	}
+
+	wantAlias := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		wantAlias[alias] = true
+	}
+	wantID := make(map[bc.AssetID]bool, len(ids))
+	idBytes := make(pq.ByteaArray, 0, len(ids))
+	for _, id := range ids {
+		wantID[id] = true
+		idBytes = append(idBytes, id[:])
+	}
+
+	const q = `
+		SELECT id, alias FROM assets
+		WHERE alias IS NOT NULL AND (alias = ANY($1) OR id = ANY($2))
+	`
+	err = pg.ForQueryRows(ctx, reg.db, q, pq.StringArray(aliases), idBytes, func(id bc.AssetID, alias string) {
+		if wantAlias[alias] {
+			idsByAlias[alias] = id
+		}
+		if wantID[id] {
+			aliasesByID[id] = alias
+		}
+		reg.cacheMu.Lock()
+		reg.aliasCache.Add(alias, id)
+		reg.cacheMu.Unlock()
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "resolving asset aliases")
+	}
+	return idsByAlias, aliasesByID, nil
+}
+
+// ClearCache removes all assets from the lookup caches. It
+// should be called whenever this process may have missed
+// updates made by other processes, such as when it becomes
+// the core leader.
+func (reg *Registry) ClearCache() {
+	reg.cacheMu.Lock()
+	reg.cache = lru.New(maxAssetCache)
+	reg.aliasCache = lru.New(maxAssetCache)
+	reg.cacheMu.Unlock()
+}
+
+// invalidate removes a single asset from the lookup caches.
+func (reg *Registry) invalidate(a *Asset) {
+	reg.cacheMu.Lock()
+	reg.cache.Remove(a.AssetID)
+	if a.Alias != nil {
+		reg.aliasCache.Remove(*a.Alias)
+	}
+	reg.cacheMu.Unlock()
+}
+
 // insertAsset adds the asset to the database. If the asset has a client token,
 // and there already exists an asset with that client token, insertAsset will
 // lookup and return the existing asset instead.
 func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken string) (*Asset, error) {
 	const q = `
 		INSERT INTO assets
-			(id, alias, signer_id, initial_block_hash, issuance_program, definition, client_token)
-		VALUES($1::bytea, $2, $3, $4, $5, $6, $7)
+			(id, alias, signer_id, initial_block_hash, issuance_program, definition, client_token, max_issuance)
+		VALUES($1::bytea, $2, $3, $4, $5, $6, $7, NULLIF($8::bigint, 0))
 		ON CONFLICT (client_token) DO NOTHING
 		RETURNING sort_id
   `
@@ -207,7 +499,7 @@ func (reg *Registry) insertAsset(ctx context.Context, asset *Asset, clientToken
 		ctx, q,
 		asset.AssetID, asset.Alias, signerID,
 		asset.InitialBlockHash, asset.IssuanceProgram,
-		defParams, nullToken,
+		defParams, nullToken, int64(asset.MaxIssuance),
 	).Scan(&asset.sortID)
 
 	if pg.IsUniqueViolation(err) {
@@ -256,10 +548,12 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 			assets.initial_block_hash, assets.sort_id,
 			signers.id, COALESCE(signers.type, ''), COALESCE(signers.xpubs, '{}'),
 			COALESCE(signers.quorum, 0), COALESCE(signers.key_index, 0),
-			asset_tags.tags
+			asset_tags.tags, assets.archived_at IS NOT NULL,
+			COALESCE(assets.max_issuance, 0), asset_rotations.successor_id
 		FROM assets
 		LEFT JOIN signers ON signers.id=assets.signer_id
 		LEFT JOIN asset_tags ON asset_tags.asset_id=assets.id
+		LEFT JOIN asset_rotations ON asset_rotations.asset_id=assets.id
 		WHERE %s
 		LIMIT 1
 	`
@@ -273,6 +567,7 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		keyIndex   uint64
 		xpubs      [][]byte
 		tags       []byte
+		successor  []byte
 	)
 	err := db.QueryRow(ctx, fmt.Sprintf(baseQ, pred), args...).Scan(
 		&a.AssetID,
@@ -287,6 +582,9 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		&quorum,
 		&keyIndex,
 		&tags,
+		&a.Archived,
+		&a.MaxIssuance,
+		&successor,
 	)
 	if err == sql.ErrNoRows {
 		return nil, pg.ErrUserInputNotFound
@@ -319,6 +617,12 @@ func assetQuery(ctx context.Context, db pg.DB, pred string, args ...interface{})
 		}
 	}
 
+	if len(successor) > 0 {
+		var id bc.AssetID
+		copy(id[:], successor)
+		a.SuccessorID = &id
+	}
+
 	return &a, nil
 }
 
@@ -332,12 +636,23 @@ func serializeAssetDef(def map[string]interface{}) ([]byte, error) {
 	return json.MarshalIndent(def, "", "  ")
 }
 
-func multisigIssuanceProgram(pubkeys []ed25519.PublicKey, nrequired int) ([]byte, error) {
+// multisigIssuanceProgram returns an issuance program requiring
+// nrequired of the keys in pubkeys to sign. If maxIssuance is
+// nonzero, the program is prefixed with
+// AMOUNT <maxIssuance> LESSTHANOREQUAL VERIFY, so that it
+// rejects any issuance of more than maxIssuance units.
+func multisigIssuanceProgram(pubkeys []ed25519.PublicKey, nrequired int, maxIssuance uint64) ([]byte, error) {
 	issuanceProg, err := vmutil.P2SPMultiSigProgram(pubkeys, nrequired)
 	if err != nil {
 		return nil, err
 	}
 	builder := vmutil.NewBuilder()
+	if maxIssuance > 0 {
+		if maxIssuance > math.MaxInt64 {
+			return nil, errors.WithDetailf(vmutil.ErrBadValue, "max issuance must be at most %d", int64(math.MaxInt64))
+		}
+		builder.AddOp(vm.OP_AMOUNT).AddInt64(int64(maxIssuance)).AddOp(vm.OP_LESSTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	}
 	builder.AddRawBytes(issuanceProg)
 	return builder.Program, nil
 }
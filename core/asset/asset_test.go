@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -18,7 +20,7 @@ func TestDefineAsset(t *testing.T) {
 	ctx := context.Background()
 
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "", nil, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -43,11 +45,11 @@ func TestDefineAssetIdempotency(t *testing.T) {
 	ctx := context.Background()
 	token := "test_token"
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset0, err := r.Define(ctx, keys, 1, nil, "", nil, token)
+	asset0, err := r.Define(ctx, keys, 1, 0, nil, "", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
-	asset1, err := r.Define(ctx, keys, 1, nil, "", nil, token)
+	asset1, err := r.Define(ctx, keys, 1, 0, nil, "", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -62,7 +64,7 @@ func TestFindAssetByID(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
 	keys := []chainkd.XPub{testutil.TestXPub}
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, "")
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "", nil, "")
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -76,13 +78,42 @@ func TestFindAssetByID(t *testing.T) {
 	}
 }
 
+func TestFindAssetByIDAfterRedefine(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	token := "test_token"
+
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "", map[string]interface{}{"n": "1"}, token)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	_, err = r.findByID(ctx, asset.AssetID) // populate the cache
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	want := map[string]interface{}{"n": "2"}
+	_, err = r.Define(ctx, keys, 1, 0, nil, "", want, token)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	found, err := r.findByID(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(found.Tags, want) {
+		t.Errorf("found.Tags = %v want %v", found.Tags, want)
+	}
+}
+
 func TestAssetByClientToken(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
 	keys := []chainkd.XPub{testutil.TestXPub}
 	token := "test_token"
 
-	asset, err := r.Define(ctx, keys, 1, nil, "", nil, token)
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "", nil, token)
 	if err != nil {
 		testutil.FatalErr(t, err)
 	}
@@ -95,3 +126,156 @@ func TestAssetByClientToken(t *testing.T) {
 		t.Fatalf("assetByClientToken(\"test_token\")=%x, want %x", found.AssetID[:], asset.AssetID[:])
 	}
 }
+
+func TestResolveAliases(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	gold, err := r.Define(ctx, keys, 1, 0, nil, "gold", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	silver, err := r.Define(ctx, keys, 1, 0, nil, "silver", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	idsByAlias, aliasesByID, err := r.ResolveAliases(ctx, []string{"gold", "bronze"}, []bc.AssetID{silver.AssetID, {1}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	wantIDs := map[string]bc.AssetID{"gold": gold.AssetID}
+	if !reflect.DeepEqual(idsByAlias, wantIDs) {
+		t.Errorf("idsByAlias = %v want %v", idsByAlias, wantIDs)
+	}
+	wantAliases := map[bc.AssetID]string{silver.AssetID: "silver"}
+	if !reflect.DeepEqual(aliasesByID, wantAliases) {
+		t.Errorf("aliasesByID = %v want %v", aliasesByID, wantAliases)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "gold", map[string]interface{}{"a": "b"}, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Cache the asset under its old alias.
+	_, err = r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	alias := "aurum"
+	tags := map[string]interface{}{"c": "d"}
+	_, err = r.Update(ctx, asset.AssetID, &alias, &tags)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = r.FindByAlias(ctx, "gold")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("FindByAlias(gold) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+	found, err := r.FindByAlias(ctx, "aurum")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.AssetID != asset.AssetID || !reflect.DeepEqual(found.Tags, tags) {
+		t.Errorf("FindByAlias(aurum) = %v with tags %v, want %v with tags %v", found.AssetID, found.Tags, asset.AssetID, tags)
+	}
+
+	// A nil alias leaves it unchanged.
+	updated, err := r.Update(ctx, asset.AssetID, nil, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if updated.Alias == nil || *updated.Alias != "aurum" {
+		t.Errorf("alias after update with nil alias = %v want aurum", updated.Alias)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	asset, err := r.Define(ctx, keys, 1, 0, nil, "gold", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Cache the asset before archiving it.
+	_, err = r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	archived, err := r.Archive(ctx, asset.AssetID, true)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !archived.Archived {
+		t.Error("Archive(true) returned an asset that isn't archived")
+	}
+	found, err := r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !found.Archived {
+		t.Error("FindByAlias(gold) returned an asset that isn't archived")
+	}
+
+	_, err = r.Archive(ctx, asset.AssetID, false)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	found, err = r.findByID(ctx, asset.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.Archived {
+		t.Error("findByID returned an archived asset after Archive(false)")
+	}
+
+	_, err = r.Archive(ctx, bc.AssetID{1}, true)
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("Archive(unknown) error = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestImport(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	id := bc.AssetID{1}
+	tags := map[string]interface{}{"issuer": "acme"}
+	imported, err := r.Import(ctx, id, "acme-gold", tags)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if imported.AssetID != id || imported.IssuanceProgram != nil || imported.Signer != nil {
+		t.Errorf("Import() = %+v, want asset %v with no issuance program or signer", imported, id)
+	}
+
+	found, err := r.FindByAlias(ctx, "acme-gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.AssetID != id || !reflect.DeepEqual(found.Tags, tags) {
+		t.Errorf("FindByAlias(acme-gold) = %v with tags %v, want %v with tags %v", found.AssetID, found.Tags, id, tags)
+	}
+
+	// Importing it again sets its alias and keeps its tags.
+	_, err = r.Import(ctx, id, "gold", nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	found, err = r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if found.AssetID != id || !reflect.DeepEqual(found.Tags, tags) {
+		t.Errorf("FindByAlias(gold) = %v with tags %v, want %v with tags %v", found.AssetID, found.Tags, id, tags)
+	}
+}
@@ -2,6 +2,8 @@ package asset
 
 import (
 	"context"
+	"database/sql"
+	stdjson "encoding/json"
 
 	"github.com/lib/pq"
 
@@ -21,8 +23,12 @@ const PinName = "asset"
 // for indexing and retrieval.
 // If the Core is configured not to provide search services,
 // SaveAnnotatedAsset can be a no-op.
+//
+// UpdateAssetAnnotations is called when an asset's alias or
+// tags change, to annotate indexed transactions again.
 type Saver interface {
 	SaveAnnotatedAsset(context.Context, bc.AssetID, map[string]interface{}, string) error
+	UpdateAssetAnnotations(ctx context.Context, assetID bc.AssetID, alias *string, tags map[string]interface{}) error
 }
 
 func (reg *Registry) indexAnnotatedAsset(ctx context.Context, a *Asset) error {
@@ -36,6 +42,27 @@ func (reg *Registry) indexAnnotatedAsset(ctx context.Context, a *Asset) error {
 		"issuance_program": json.HexBytes(a.IssuanceProgram),
 		"tags":             a.Tags,
 		"is_local":         "no",
+		"is_archived":      "no",
+	}
+	if a.Archived {
+		m["is_archived"] = "yes"
+	}
+	if a.MaxIssuance > 0 {
+		m["max_issuance"] = a.MaxIssuance
+	}
+	if a.SuccessorID != nil {
+		m["successor_id"] = *a.SuccessorID
+	}
+	if ref := definitionRef(a.Definition); ref != nil {
+		var doc []byte
+		const q = `SELECT document FROM asset_definition_documents WHERE hash = $1`
+		err := reg.db.QueryRow(ctx, q, ref.Hash[:]).Scan(&doc)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.Wrap(err, "loading asset definition document")
+		}
+		if def := referencedDefinition(doc); def != nil {
+			m["referenced_definition"] = def
+		}
 	}
 	if a.Signer != nil {
 		var keys []map[string]interface{}
@@ -84,13 +111,16 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 		assetIDs         pq.ByteaArray
 		definitions      pq.StringArray
 		issuancePrograms pq.ByteaArray
+		maxIssuances     pq.Int64Array
 		seen             = make(map[bc.AssetID]bool)
+		issued           = make(map[bc.AssetID]uint64)
 	)
 	for _, tx := range b.Transactions {
 		for _, in := range tx.Inputs {
 			if !in.IsIssuance() {
 				continue
 			}
+			issued[in.AssetID()] = addIssued(issued[in.AssetID()], in.Amount())
 			if seen[in.AssetID()] {
 				continue
 			}
@@ -101,6 +131,7 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 				assetIDs = append(assetIDs, id[:])
 				definitions = append(definitions, string(definition))
 				issuancePrograms = append(issuancePrograms, in.IssuanceProgram())
+				maxIssuances = append(maxIssuances, int64(parseIssuanceCap(in.IssuanceProgram())))
 			}
 		}
 	}
@@ -108,9 +139,23 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 		return nil
 	}
 
+	// Fetch the documents referenced by the definitions of
+	// issued assets, so the transaction indexer, which runs
+	// after this block processor, can annotate with them.
+	defs := make([]map[string]interface{}, len(definitions))
+	for i, d := range definitions {
+		stdjson.Unmarshal([]byte(d), &defs[i]) // ignore non-json defs
+	}
+	fetched := make(map[bc.Hash]bool)
+	for _, h := range reg.resolveReferences(ctx, defs) {
+		fetched[h] = true
+	}
+
 	// Insert these assets into the database. If the asset already exists, don't
-	// do anything. Return the asset ID of all inserted assets so we know which
-	// ones we have to save to the query indexer.
+	// do anything, unless it was imported before it was issued, in which case
+	// fill in its issuance program and definition. Return the asset ID of all
+	// inserted and filled-in assets so we know which ones we have to save to
+	// the query indexer.
 	//
 	// For idempotency concerns, we use `first_block_height` to ensure that this
 	// query always returns the full set of new assets at this block. This
@@ -118,9 +163,14 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 	// the annotated asset to the query indexer.
 	const q = `
 		WITH new_assets AS (
-			INSERT INTO assets (id, issuance_program, definition, created_at, initial_block_hash, first_block_height)
-			VALUES(unnest($1::bytea[]), unnest($2::bytea[]), unnest($3::text[])::jsonb, $4, $5, $6)
-			ON CONFLICT (id) DO NOTHING
+			INSERT INTO assets (id, issuance_program, definition, created_at, initial_block_hash, first_block_height, max_issuance)
+			VALUES(unnest($1::bytea[]), unnest($2::bytea[]), unnest($3::text[])::jsonb, $4, $5, $6, NULLIF(unnest($7::bigint[]), 0))
+			ON CONFLICT (id) DO UPDATE SET
+				issuance_program = excluded.issuance_program,
+				definition = excluded.definition,
+				first_block_height = excluded.first_block_height,
+				max_issuance = excluded.max_issuance
+			WHERE assets.issuance_program IS NULL
 			RETURNING id
 		)
 		SELECT id FROM new_assets
@@ -128,16 +178,41 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 		SELECT id FROM assets WHERE first_block_height = $6
 	`
 	var newAssetIDs []bc.AssetID
-	err := pg.ForQueryRows(ctx, reg.db, q, assetIDs, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height,
+	err := pg.ForQueryRows(ctx, reg.db, q, assetIDs, issuancePrograms, definitions, b.Time(), reg.initialBlockHash, b.Height, maxIssuances,
 		func(assetID bc.AssetID) { newAssetIDs = append(newAssetIDs, assetID) })
 	if err != nil {
 		return errors.Wrap(err, "error indexing non-local assets")
 	}
 
+	err = reg.countIssued(ctx, b.Height, issued)
+	if err != nil {
+		return err
+	}
+
+	// An imported asset may be cached from
+	// before its issuance program was known.
+	reg.cacheMu.Lock()
+	for _, assetID := range newAssetIDs {
+		reg.cache.Remove(assetID)
+	}
+	reg.cacheMu.Unlock()
+
 	if reg.indexer == nil {
 		return nil
 	}
 
+	// Assets whose definition documents were just fetched
+	// are indexed again to include them.
+	for i, def := range defs {
+		if ref := definitionRef(def); ref != nil && fetched[ref.Hash] {
+			var assetID bc.AssetID
+			copy(assetID[:], assetIDs[i])
+			if !containsAssetID(newAssetIDs, assetID) {
+				newAssetIDs = append(newAssetIDs, assetID)
+			}
+		}
+	}
+
 	// newAssetIDs now contains only the asset IDs of new, non-local
 	// assets. We need to index them as annotated assets too.
 	for _, assetID := range newAssetIDs {
@@ -153,3 +228,12 @@ func (reg *Registry) indexAssets(ctx context.Context, b *bc.Block) error {
 	}
 	return nil
 }
+
+func containsAssetID(ids []bc.AssetID, id bc.AssetID) bool {
+	for _, x := range ids {
+		if x == id {
+			return true
+		}
+	}
+	return false
+}
@@ -21,12 +21,16 @@ func (f fakeSaver) SaveAnnotatedAsset(ctx context.Context, assetID bc.AssetID, o
 	return f(ctx, assetID, obj, sortID)
 }
 
+func (f fakeSaver) UpdateAssetAnnotations(ctx context.Context, assetID bc.AssetID, alias *string, tags map[string]interface{}) error {
+	return nil
+}
+
 func TestIndexNonLocalAssets(t *testing.T) {
 	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
 	ctx := context.Background()
 
 	// Create a local asset which should be unaffected by a block landing.
-	local, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, nil, "", nil, "")
+	local, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 0, nil, "", nil, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,7 +40,7 @@ func TestIndexNonLocalAssets(t *testing.T) {
 	}
 
 	// Create the issuance program of a remote asset.
-	issuanceProgram, err := multisigIssuanceProgram([]ed25519.PublicKey{testutil.TestPub, testutil.TestPub}, 2)
+	issuanceProgram, err := multisigIssuanceProgram([]ed25519.PublicKey{testutil.TestPub, testutil.TestPub}, 2, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
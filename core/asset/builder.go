@@ -39,16 +39,81 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 		return txbuilder.MissingFieldsError("asset_id")
 	}
 
-	asset, err := a.assets.findByID(ctx, a.AssetID)
+	var nonce [8]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		return err
+	}
+
+	builder.RestrictMinTime(time.Now())
+	return a.assets.addIssuance(ctx, builder, a.AssetAmount, a.ReferenceData, nonce)
+}
+
+// DecodeIssueManyAction decodes an action that issues
+// several assets, each in its own issuance input.
+func (reg *Registry) DecodeIssueManyAction(data []byte) (txbuilder.Action, error) {
+	a := &issueManyAction{assets: reg}
+	err := json.Unmarshal(data, a)
+	return a, err
+}
+
+type issueManyAction struct {
+	assets *Registry
+	Issues []struct {
+		bc.AssetAmount
+		ReferenceData chainjson.Map `json:"reference_data"`
+	} `json:"issues"`
+}
+
+func (a *issueManyAction) Build(ctx context.Context, builder *txbuilder.TemplateBuilder) error {
+	if len(a.Issues) == 0 {
+		return txbuilder.MissingFieldsError("issues")
+	}
+	for i, iss := range a.Issues {
+		if iss.AssetID == (bc.AssetID{}) {
+			return errors.WithDetailf(txbuilder.MissingFieldsError("asset_id"), "issue %d has no asset_id", i)
+		}
+	}
+
+	// Issuances of the same asset must have distinct
+	// nonces, or their inputs would be identical.
+	nonces := make(map[[8]byte]bool, len(a.Issues))
+	for _, iss := range a.Issues {
+		var nonce [8]byte
+		for {
+			_, err := rand.Read(nonce[:])
+			if err != nil {
+				return err
+			}
+			if !nonces[nonce] {
+				break
+			}
+		}
+		nonces[nonce] = true
+
+		err := a.assets.addIssuance(ctx, builder, iss.AssetAmount, iss.ReferenceData, nonce)
+		if err != nil {
+			return err
+		}
+	}
+	builder.RestrictMinTime(time.Now())
+	return nil
+}
+
+// addIssuance adds to builder an input issuing assetAmount,
+// and its signing instruction.
+func (reg *Registry) addIssuance(ctx context.Context, builder *txbuilder.TemplateBuilder, assetAmount bc.AssetAmount, refData chainjson.Map, nonce [8]byte) error {
+	asset, err := reg.findByID(ctx, assetAmount.AssetID)
 	if errors.Root(err) == pg.ErrUserInputNotFound {
-		err = errors.WithDetailf(err, "missing asset with ID %q", a.AssetID)
+		err = errors.WithDetailf(err, "missing asset with ID %q", assetAmount.AssetID)
 	}
 	if err != nil {
 		return err
 	}
-
-	var nonce [8]byte
-	_, err = rand.Read(nonce[:])
+	if asset.SuccessorID != nil {
+		return errors.WithDetailf(ErrRotated, "asset %s was replaced by %s", asset.AssetID, *asset.SuccessorID)
+	}
+	err = reg.checkIssuance(ctx, asset, assetAmount.Amount)
 	if err != nil {
 		return err
 	}
@@ -58,13 +123,12 @@ func (a *issueAction) Build(ctx context.Context, builder *txbuilder.TemplateBuil
 		return err
 	}
 
-	txin := bc.NewIssuanceInput(nonce[:], a.Amount, a.ReferenceData, asset.InitialBlockHash, asset.IssuanceProgram, nil, assetdef)
+	txin := bc.NewIssuanceInput(nonce[:], assetAmount.Amount, refData, asset.InitialBlockHash, asset.IssuanceProgram, nil, assetdef)
 
-	tplIn := &txbuilder.SigningInstruction{AssetAmount: a.AssetAmount}
+	tplIn := &txbuilder.SigningInstruction{AssetAmount: assetAmount}
 	path := signers.Path(asset.Signer, signers.AssetKeySpace)
 	keyIDs := txbuilder.KeyIDs(asset.Signer.XPubs, path)
 	tplIn.AddWitnessKeys(keyIDs, asset.Signer.Quorum)
 
-	builder.RestrictMinTime(time.Now())
 	return builder.AddInput(txin, tplIn)
 }
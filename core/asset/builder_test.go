@@ -0,0 +1,77 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestIssueMany(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	keys := []chainkd.XPub{testutil.TestXPub}
+	gold, err := r.Define(ctx, keys, 1, 0, nil, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	silver, err := r.Define(ctx, keys, 1, 0, nil, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"issues": []interface{}{
+			map[string]interface{}{"asset_id": gold.AssetID, "amount": 10},
+			map[string]interface{}{"asset_id": silver.AssetID, "amount": 20},
+			map[string]interface{}{"asset_id": gold.AssetID, "amount": 30},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	action, err := r.DecodeIssueManyAction(data)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Retire the issued amounts so the transaction balances.
+	var dests []txbuilder.Action
+	for _, aa := range []bc.AssetAmount{{AssetID: gold.AssetID, Amount: 40}, {AssetID: silver.AssetID, Amount: 20}} {
+		b, err := json.Marshal(map[string]interface{}{"asset_id": aa.AssetID, "amount": aa.Amount, "control_program": "6a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest, err := txbuilder.DecodeControlProgramAction(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dests = append(dests, dest)
+	}
+	tpl, err := txbuilder.Build(ctx, nil, append([]txbuilder.Action{action}, dests...), time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	ins := tpl.Transaction.Inputs
+	if len(ins) != 3 || len(tpl.SigningInstructions) != 3 {
+		t.Fatalf("got %d inputs and %d signing instructions, want 3 of each", len(ins), len(tpl.SigningInstructions))
+	}
+	for i, want := range []bc.AssetAmount{{AssetID: gold.AssetID, Amount: 10}, {AssetID: silver.AssetID, Amount: 20}, {AssetID: gold.AssetID, Amount: 30}} {
+		if ins[i].AssetAmount() != want {
+			t.Errorf("input %d issues %v, want %v", i, ins[i].AssetAmount(), want)
+		}
+	}
+	nonce0 := ins[0].TypedInput.(*bc.IssuanceInput).Nonce
+	nonce2 := ins[2].TypedInput.(*bc.IssuanceInput).Nonce
+	if bytes.Equal(nonce0, nonce2) {
+		t.Error("issuances of the same asset have the same nonce")
+	}
+}
@@ -0,0 +1,126 @@
+package asset
+
+import (
+	"context"
+	"math"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+// ErrIssuanceCap is returned when building an issuance that
+// would take an asset beyond its maximum issuance.
+var ErrIssuanceCap = errors.New("asset issuance cap exceeded")
+
+// IssuanceStatus reports how much of a capped asset
+// has been issued in blocks seen by the core.
+type IssuanceStatus struct {
+	MaxIssuance uint64
+	Issued      uint64
+}
+
+// Remaining returns the amount of the asset that may still be issued.
+func (s IssuanceStatus) Remaining() uint64 {
+	if s.Issued >= s.MaxIssuance {
+		return 0
+	}
+	return s.MaxIssuance - s.Issued
+}
+
+// IssuanceStatuses returns the issuance status of each asset
+// in ids that has a maximum issuance. Uncapped assets are
+// left out.
+func (reg *Registry) IssuanceStatuses(ctx context.Context, ids []bc.AssetID) (map[bc.AssetID]IssuanceStatus, error) {
+	statuses := make(map[bc.AssetID]IssuanceStatus)
+	if len(ids) == 0 {
+		return statuses, nil
+	}
+	var idBytes pq.ByteaArray
+	for _, id := range ids {
+		idBytes = append(idBytes, id[:])
+	}
+	const q = `
+		SELECT id, max_issuance, issued FROM assets
+		WHERE id = ANY($1) AND max_issuance IS NOT NULL
+	`
+	err := pg.ForQueryRows(ctx, reg.db, q, idBytes, func(id bc.AssetID, max, issued int64) {
+		statuses[id] = IssuanceStatus{MaxIssuance: uint64(max), Issued: uint64(issued)}
+	})
+	return statuses, errors.Wrap(err, "loading issuance statuses")
+}
+
+// checkIssuance returns ErrIssuanceCap if issuing amount
+// of asset would exceed its maximum issuance. It counts
+// issuances in blocks the core has seen; issuances not
+// yet in a block aren't counted.
+func (reg *Registry) checkIssuance(ctx context.Context, asset *Asset, amount uint64) error {
+	if asset.MaxIssuance == 0 {
+		return nil
+	}
+	statuses, err := reg.IssuanceStatuses(ctx, []bc.AssetID{asset.AssetID})
+	if err != nil {
+		return err
+	}
+	s := statuses[asset.AssetID]
+	if amount > s.Remaining() {
+		return errors.WithDetailf(ErrIssuanceCap, "asset %s has %d of %d remaining to issue", asset.AssetID, s.Remaining(), s.MaxIssuance)
+	}
+	return nil
+}
+
+// parseIssuanceCap returns the maximum issuance compiled
+// into an issuance program by multisigIssuanceProgram,
+// or 0 if the program has none.
+func parseIssuanceCap(prog []byte) uint64 {
+	insts, err := vm.ParseProgram(prog)
+	if err != nil || len(insts) < 4 {
+		return 0
+	}
+	if insts[0].Op != vm.OP_AMOUNT || insts[2].Op != vm.OP_LESSTHANOREQUAL || insts[3].Op != vm.OP_VERIFY {
+		return 0
+	}
+	n, err := vm.AsInt64(insts[1].Data)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return uint64(n)
+}
+
+// addIssued adds amount to sum, saturating at math.MaxInt64
+// so the total fits the issued column.
+func addIssued(sum, amount uint64) uint64 {
+	if amount > math.MaxInt64-sum {
+		return math.MaxInt64
+	}
+	return sum + amount
+}
+
+// countIssued adds the amounts issued in the block at the given
+// height to the issued totals of capped assets. Each asset
+// records the last height counted, so a block processed again
+// after a crash isn't counted twice.
+func (reg *Registry) countIssued(ctx context.Context, height uint64, issued map[bc.AssetID]uint64) error {
+	var (
+		assetIDs pq.ByteaArray
+		amounts  pq.Int64Array
+	)
+	for id, amount := range issued {
+		id := id
+		assetIDs = append(assetIDs, id[:])
+		amounts = append(amounts, int64(amount))
+	}
+	const q = `
+		UPDATE assets
+		SET issued = LEAST(assets.issued::numeric + c.amount, 9223372036854775807)::bigint,
+			issued_height = $3
+		FROM unnest($1::bytea[], $2::bigint[]) AS c(asset_id, amount)
+		WHERE assets.id = c.asset_id AND assets.max_issuance IS NOT NULL
+			AND assets.issued_height < $3
+	`
+	_, err := reg.db.Exec(ctx, q, assetIDs, amounts, int64(height))
+	return errors.Wrap(err, "counting issued amounts")
+}
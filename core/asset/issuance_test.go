@@ -0,0 +1,67 @@
+package asset
+
+import (
+	"context"
+	"testing"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestParseIssuanceCap(t *testing.T) {
+	pubkeys := []ed25519.PublicKey{testutil.TestPub}
+	for _, max := range []uint64{0, 1, 16, 17, 1000000} {
+		prog, err := multisigIssuanceProgram(pubkeys, 1, max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseIssuanceCap(prog); got != max {
+			t.Errorf("parseIssuanceCap(program capped at %d) = %d", max, got)
+		}
+	}
+}
+
+func TestMaxIssuance(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	gold, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 100, nil, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	issued := map[bc.AssetID]uint64{gold.AssetID: 60}
+	err = r.countIssued(ctx, 5, issued)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	// Counting the same block again changes nothing.
+	err = r.countIssued(ctx, 5, issued)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	statuses, err := r.IssuanceStatuses(ctx, []bc.AssetID{gold.AssetID})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := IssuanceStatus{MaxIssuance: 100, Issued: 60}
+	if statuses[gold.AssetID] != want {
+		t.Errorf("issuance status = %+v want %+v", statuses[gold.AssetID], want)
+	}
+
+	builder := new(txbuilder.TemplateBuilder)
+	err = r.NewIssueAction(bc.AssetAmount{AssetID: gold.AssetID, Amount: 40}, nil).Build(ctx, builder)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = r.NewIssueAction(bc.AssetAmount{AssetID: gold.AssetID, Amount: 41}, nil).Build(ctx, builder)
+	if errors.Root(err) != ErrIssuanceCap {
+		t.Errorf("issuing beyond cap: got error %v want %v", err, ErrIssuanceCap)
+	}
+}
@@ -0,0 +1,44 @@
+package asset
+
+import (
+	"strings"
+
+	"chain/errors"
+)
+
+// ErrAliasNamespace is returned by QualifyAlias for
+// an alias in a namespace other than the one given.
+var ErrAliasNamespace = errors.New("asset alias outside namespace")
+
+// An asset alias may begin with a namespace, separated from
+// the rest of the alias by a slash, as in "treasury/gold".
+// Namespaces let several projects share a core without their
+// aliases colliding.
+const namespaceSep = "/"
+
+// AliasNamespace returns the namespace of alias,
+// or "" if it has none.
+func AliasNamespace(alias string) string {
+	i := strings.Index(alias, namespaceSep)
+	if i < 0 {
+		return ""
+	}
+	return alias[:i]
+}
+
+// QualifyAlias returns alias as seen from namespace. With no
+// namespace, alias is returned unchanged. Otherwise an alias
+// without a namespace is put in namespace, and an alias in
+// any other namespace is rejected with ErrAliasNamespace.
+func QualifyAlias(namespace, alias string) (string, error) {
+	if namespace == "" {
+		return alias, nil
+	}
+	switch AliasNamespace(alias) {
+	case "":
+		return namespace + namespaceSep + alias, nil
+	case namespace:
+		return alias, nil
+	}
+	return "", errors.WithDetailf(ErrAliasNamespace, "alias %q is not in namespace %q", alias, namespace)
+}
@@ -0,0 +1,30 @@
+package asset
+
+import (
+	"testing"
+
+	"chain/errors"
+)
+
+func TestQualifyAlias(t *testing.T) {
+	cases := []struct {
+		namespace, alias string
+		want             string
+		wantErr          error
+	}{
+		{"", "gold", "gold", nil},
+		{"", "treasury/gold", "treasury/gold", nil},
+		{"treasury", "gold", "treasury/gold", nil},
+		{"treasury", "treasury/gold", "treasury/gold", nil},
+		{"treasury", "ops/gold", "", ErrAliasNamespace},
+	}
+	for _, c := range cases {
+		got, err := QualifyAlias(c.namespace, c.alias)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("QualifyAlias(%q, %q) error = %v want %v", c.namespace, c.alias, err, c.wantErr)
+		}
+		if got != c.want {
+			t.Errorf("QualifyAlias(%q, %q) = %q want %q", c.namespace, c.alias, got, c.want)
+		}
+	}
+}
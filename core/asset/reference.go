@@ -0,0 +1,193 @@
+package asset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	"chain/database/sql"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// maxDocumentSize bounds the size of a referenced
+// asset definition document.
+const maxDocumentSize = 10 << 20
+
+var (
+	// ErrBadReference is returned when an asset definition
+	// reference has a malformed URL.
+	ErrBadReference = errors.New("invalid asset definition reference")
+
+	// ErrDocumentMismatch is returned when a referenced
+	// document doesn't match the reference's hash.
+	ErrDocumentMismatch = errors.New("asset definition document does not match its hash")
+)
+
+var fetchClient = &http.Client{Timeout: 10 * time.Second}
+
+// A DefinitionRef points to an asset definition document
+// kept off the blockchain. The document is identified by
+// the SHA3-256 hash of its contents.
+type DefinitionRef struct {
+	URL  string  `json:"url"`
+	Hash bc.Hash `json:"hash"`
+}
+
+// ReferenceDefinition returns an asset definition that holds
+// only a reference to a document at rawurl with the given hash.
+// Transaction annotations include the document itself, if it
+// is a JSON object, once the core has fetched it.
+func ReferenceDefinition(rawurl string, hash bc.Hash) (map[string]interface{}, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.WithDetailf(ErrBadReference, "url %q must be an absolute http or https URL", rawurl)
+	}
+	return map[string]interface{}{
+		"definition_reference": map[string]interface{}{
+			"url":  rawurl,
+			"hash": hash.String(),
+		},
+	}, nil
+}
+
+// definitionRef returns the document reference held
+// by an asset definition, or nil if it holds none.
+func definitionRef(def map[string]interface{}) *DefinitionRef {
+	m, ok := def["definition_reference"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rawurl, ok := m["url"].(string)
+	if !ok {
+		return nil
+	}
+	hashStr, ok := m["hash"].(string)
+	if !ok {
+		return nil
+	}
+	ref := &DefinitionRef{URL: rawurl}
+	if ref.Hash.UnmarshalText([]byte(hashStr)) != nil {
+		return nil
+	}
+	return ref
+}
+
+// resolveReferences fetches the documents referenced by the given
+// definitions that aren't already cached. A document that can't be
+// fetched or doesn't match its hash is logged and skipped; it will
+// be tried again the next time its asset is defined or issued.
+// It returns the hashes of the documents newly cached.
+func (reg *Registry) resolveReferences(ctx context.Context, defs []map[string]interface{}) []bc.Hash {
+	var (
+		refs   []*DefinitionRef
+		hashes pq.ByteaArray
+		seen   = make(map[bc.Hash]bool)
+	)
+	for _, def := range defs {
+		ref := definitionRef(def)
+		if ref == nil || seen[ref.Hash] {
+			continue
+		}
+		seen[ref.Hash] = true
+		refs = append(refs, ref)
+		hashes = append(hashes, ref.Hash[:])
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	cached := make(map[bc.Hash]bool)
+	const q = `SELECT hash FROM asset_definition_documents WHERE hash = ANY($1)`
+	err := pg.ForQueryRows(ctx, reg.db, q, hashes, func(h bc.Hash) { cached[h] = true })
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "querying asset definition documents"))
+		return nil
+	}
+
+	var fetched []bc.Hash
+	for _, ref := range refs {
+		if cached[ref.Hash] {
+			continue
+		}
+		_, err := reg.fetchDocument(ctx, ref)
+		if err != nil {
+			log.Error(ctx, err, "url", ref.URL)
+			continue
+		}
+		fetched = append(fetched, ref.Hash)
+	}
+	return fetched
+}
+
+// document returns the document a reference points to,
+// fetching it if it isn't already cached.
+func (reg *Registry) document(ctx context.Context, ref *DefinitionRef) ([]byte, error) {
+	const q = `SELECT document FROM asset_definition_documents WHERE hash = $1`
+	var doc []byte
+	err := reg.db.QueryRow(ctx, q, ref.Hash[:]).Scan(&doc)
+	if err == sql.ErrNoRows {
+		return reg.fetchDocument(ctx, ref)
+	}
+	return doc, errors.Wrap(err, "querying asset definition document")
+}
+
+// fetchDocument downloads the document a reference points to,
+// checks it against the reference's hash and caches it.
+func (reg *Registry) fetchDocument(ctx context.Context, ref *DefinitionRef) ([]byte, error) {
+	req, err := http.NewRequest("GET", ref.URL, nil)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	resp, err := fetchClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching asset definition document")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Wrap(fmt.Errorf("asset definition document returned status %d", resp.StatusCode))
+	}
+	doc, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading asset definition document")
+	}
+	if len(doc) > maxDocumentSize {
+		return nil, errors.Wrap(fmt.Errorf("asset definition document exceeds %d bytes", maxDocumentSize))
+	}
+
+	var h bc.Hash
+	sha3pool.Sum256(h[:], doc)
+	if h != ref.Hash {
+		return nil, errors.WithDetailf(ErrDocumentMismatch, "got hash %s want %s", h, ref.Hash)
+	}
+
+	const q = `
+		INSERT INTO asset_definition_documents (hash, url, document) VALUES ($1, $2, $3)
+		ON CONFLICT (hash) DO NOTHING
+	`
+	_, err = reg.db.Exec(ctx, q, ref.Hash[:], ref.URL, doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "caching asset definition document")
+	}
+	return doc, nil
+}
+
+// referencedDefinition decodes a cached document as a JSON object.
+// It returns nil for documents that aren't JSON objects.
+func referencedDefinition(doc []byte) map[string]interface{} {
+	var m map[string]interface{}
+	if json.Unmarshal(doc, &m) != nil {
+		return nil
+	}
+	return m
+}
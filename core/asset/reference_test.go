@@ -0,0 +1,151 @@
+package asset
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestReferenceDefinition(t *testing.T) {
+	_, err := ReferenceDefinition("ftp://example.com/def.json", bc.Hash{})
+	if errors.Root(err) != ErrBadReference {
+		t.Errorf("ReferenceDefinition(ftp) error = %v want %v", err, ErrBadReference)
+	}
+
+	hash := bc.Hash{1}
+	def, err := ReferenceDefinition("https://example.com/def.json", hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got := definitionRef(def)
+	want := &DefinitionRef{URL: "https://example.com/def.json", Hash: hash}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("definitionRef = %+v want %+v", got, want)
+	}
+	if ref := definitionRef(map[string]interface{}{"name": "gold"}); ref != nil {
+		t.Errorf("definitionRef(plain definition) = %+v want nil", ref)
+	}
+}
+
+func TestAnnotateReferencedDefinition(t *testing.T) {
+	const doc = `{"name": "gold", "terms": "..."}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	reg := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+
+	var hash bc.Hash
+	sha3pool.Sum256(hash[:], []byte(doc))
+	badDef, err := ReferenceDefinition(srv.URL, bc.Hash{1})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	goodDef, err := ReferenceDefinition(srv.URL, hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	bad, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 0, badDef, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	good, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 0, goodDef, "", nil, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	txs := []map[string]interface{}{{
+		"inputs": []interface{}{},
+		"outputs": []interface{}{
+			map[string]interface{}{"asset_id": bad.AssetID.String()},
+			map[string]interface{}{"asset_id": good.AssetID.String()},
+		},
+	}}
+	err = reg.AnnotateTxs(ctx, txs)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	outs := txs[0]["outputs"].([]interface{})
+	if _, ok := outs[0].(map[string]interface{})["referenced_asset_definition"]; ok {
+		t.Error("output with mismatched document has referenced_asset_definition")
+	}
+	got := outs[1].(map[string]interface{})["referenced_asset_definition"]
+	want := map[string]interface{}{"name": "gold", "terms": "..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("referenced_asset_definition = %v want %v", got, want)
+	}
+}
+
+func TestDefineReferencedDefinitionSchema(t *testing.T) {
+	docs := map[string]string{
+		"/good": `{"issuer": "acme"}`,
+		"/bad":  `{"issuer": 5}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		doc, ok := docs[req.URL.Path]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer srv.Close()
+
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"required": ["issuer"],
+		"additionalProperties": false,
+		"properties": {
+			"issuer": {"type": "string"},
+			"class": {"type": "string"}
+		}
+	}`))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	reg := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	reg.RequireDefinitionSchema(schema)
+	ctx := context.Background()
+
+	ref := func(path, doc string, fields map[string]interface{}) map[string]interface{} {
+		var hash bc.Hash
+		sha3pool.Sum256(hash[:], []byte(doc))
+		def, err := ReferenceDefinition(srv.URL+path, hash)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		for k, v := range fields {
+			def[k] = v
+		}
+		return def
+	}
+	cases := []struct {
+		def  map[string]interface{}
+		want error
+	}{
+		{ref("/good", docs["/good"], nil), nil},
+		{ref("/good", docs["/good"], map[string]interface{}{"class": "common"}), nil},
+		{ref("/good", docs["/good"], map[string]interface{}{"class": 1}), ErrBadDefinition},
+		{ref("/bad", docs["/bad"], nil), ErrBadDefinition},
+		{ref("/good", "other", nil), ErrBadReference},
+		{ref("/missing", "", nil), ErrBadReference},
+	}
+	for i, c := range cases {
+		_, err := reg.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 0, c.def, "", nil, "")
+		if errors.Root(err) != c.want {
+			t.Errorf("%d: Define error = %v want %v", i, err, c.want)
+		}
+	}
+}
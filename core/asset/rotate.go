@@ -0,0 +1,197 @@
+package asset
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrRotated is returned by RotateKeys when the asset
+	// already has a successor, and when issuing an asset
+	// whose keys have been rotated.
+	ErrRotated = errors.New("asset already has a successor")
+
+	// ErrRemoteAsset is returned by RotateKeys for an asset
+	// whose keys aren't held by this core.
+	ErrRemoteAsset = errors.New("asset is not local")
+)
+
+// A Rotation links an asset to its successor. An asset's ID is
+// derived from its issuance program, so new keys mean a new
+// asset; the successor has the new keys and quorum and the
+// original asset's definition, tags and alias.
+type Rotation struct {
+	AssetID         bc.AssetID         `json:"asset_id"`
+	SuccessorID     bc.AssetID         `json:"successor_id"`
+	IssuanceProgram chainjson.HexBytes `json:"issuance_program"`
+	CreatedAt       time.Time          `json:"created_at"`
+}
+
+// RotateKeys replaces the keys and quorum behind an asset's
+// issuance program by defining a successor asset with a new
+// issuance program. The asset's alias moves to the successor, so
+// new issuances by alias use the new keys, and the asset itself
+// can no longer be issued. Inputs and outputs of the original
+// asset are annotated with the successor's alias, so queries by
+// alias match both. If the asset has a maximum issuance, the
+// successor's is what remained to be issued.
+func (reg *Registry) RotateKeys(ctx context.Context, id bc.AssetID, xpubs []chainkd.XPub, quorum int, clientToken string) (*Rotation, error) {
+	orig, err := assetQuery(ctx, reg.db, "assets.id=$1", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading asset")
+	}
+	if orig.SuccessorID != nil {
+		// A retried request with the same client token
+		// finds the rotation it made the first time.
+		var retried bool
+		const q = `SELECT EXISTS(SELECT 1 FROM assets WHERE id = $1 AND client_token = $2)`
+		err = reg.db.QueryRow(ctx, q, *orig.SuccessorID, clientToken).Scan(&retried)
+		if err != nil {
+			return nil, errors.Wrap(err, "checking asset successor")
+		}
+		if !retried {
+			return nil, errors.WithDetailf(ErrRotated, "successor %s", *orig.SuccessorID)
+		}
+		return reg.rotation(ctx, id)
+	}
+	if orig.Signer == nil {
+		return nil, errors.WithDetailf(ErrRemoteAsset, "asset %s", id)
+	}
+
+	var maxIssuance uint64
+	if orig.MaxIssuance > 0 {
+		statuses, err := reg.IssuanceStatuses(ctx, []bc.AssetID{id})
+		if err != nil {
+			return nil, err
+		}
+		maxIssuance = statuses[id].Remaining()
+		if maxIssuance == 0 {
+			return nil, errors.WithDetailf(ErrIssuanceCap, "asset %s has nothing remaining to issue", id)
+		}
+	}
+
+	successor, err := reg.Define(ctx, xpubs, quorum, maxIssuance, orig.Definition, "", orig.Tags, clientToken)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		INSERT INTO asset_rotations (asset_id, successor_id) VALUES ($1, $2)
+		ON CONFLICT (asset_id) DO NOTHING
+	`
+	res, err := reg.db.Exec(ctx, q, id, successor.AssetID)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting asset rotation")
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, errors.Wrap(err)
+	} else if n == 0 {
+		// A concurrent request rotated it first.
+		return nil, errors.WithDetailf(ErrRotated, "asset %s", id)
+	}
+	reg.invalidate(orig)
+
+	if orig.Alias != nil {
+		// The alias is unique, so it's removed from
+		// the original asset before the successor gets it.
+		_, err = reg.Update(ctx, id, new(string), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "moving asset alias")
+		}
+		_, err = reg.Update(ctx, successor.AssetID, orig.Alias, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "moving asset alias")
+		}
+	} else {
+		err = reg.indexAnnotatedAsset(ctx, orig)
+		if err != nil {
+			return nil, errors.Wrap(err, "indexing annotated asset")
+		}
+	}
+	return reg.rotation(ctx, id)
+}
+
+// rotation loads the rotation of the asset with the given ID.
+func (reg *Registry) rotation(ctx context.Context, id bc.AssetID) (*Rotation, error) {
+	r := &Rotation{AssetID: id}
+	const q = `
+		SELECT r.successor_id, a.issuance_program, r.created_at
+		FROM asset_rotations r JOIN assets a ON a.id = r.successor_id
+		WHERE r.asset_id = $1
+	`
+	err := reg.db.QueryRow(ctx, q, id).Scan(&r.SuccessorID, (*[]byte)(&r.IssuanceProgram), &r.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "asset %s has no successor", id)
+	}
+	return r, errors.Wrap(err, "loading asset rotation")
+}
+
+// predecessors returns the assets that the asset with the
+// given ID replaced, directly or through earlier rotations.
+func (reg *Registry) predecessors(ctx context.Context, id bc.AssetID) ([]bc.AssetID, error) {
+	const q = `
+		WITH RECURSIVE chain (id) AS (
+			SELECT asset_id FROM asset_rotations WHERE successor_id = $1
+			UNION ALL
+			SELECT r.asset_id FROM asset_rotations r JOIN chain ON r.successor_id = chain.id
+		)
+		SELECT id FROM chain
+	`
+	var ids []bc.AssetID
+	err := pg.ForQueryRows(ctx, reg.db, q, id, func(id bc.AssetID) {
+		ids = append(ids, id)
+	})
+	return ids, errors.Wrap(err, "loading asset predecessors")
+}
+
+// annotatePredecessors annotates the history of the assets
+// that a replaced with a's alias. Each keeps its own tags.
+func (reg *Registry) annotatePredecessors(ctx context.Context, a *Asset) error {
+	ids, err := reg.predecessors(ctx, a.AssetID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		p, err := assetQuery(ctx, reg.db, "assets.id=$1", id)
+		if err != nil {
+			return errors.Wrap(err, "loading asset predecessor")
+		}
+		err = reg.indexer.UpdateAssetAnnotations(ctx, id, a.Alias, p.Tags)
+		if err != nil {
+			return errors.Wrap(err, "annotating asset predecessor history")
+		}
+	}
+	return nil
+}
+
+// successorAliases returns the alias of the latest successor
+// of each asset in ids that has been rotated, if it has one.
+func (reg *Registry) successorAliases(ctx context.Context, ids pq.ByteaArray) (map[bc.AssetID]string, error) {
+	const q = `
+		WITH RECURSIVE chain (origin, id, depth) AS (
+			SELECT asset_id, successor_id, 1 FROM asset_rotations WHERE asset_id = ANY($1)
+			UNION ALL
+			SELECT chain.origin, r.successor_id, chain.depth + 1
+			FROM asset_rotations r JOIN chain ON r.asset_id = chain.id
+		)
+		SELECT DISTINCT ON (origin) origin, COALESCE(assets.alias, '')
+		FROM chain JOIN assets ON assets.id = chain.id
+		ORDER BY origin, depth DESC
+	`
+	aliases := make(map[bc.AssetID]string)
+	err := pg.ForQueryRows(ctx, reg.db, q, ids, func(id bc.AssetID, alias string) {
+		if alias != "" {
+			aliases[id] = alias
+		}
+	})
+	return aliases, errors.Wrap(err, "loading asset successor aliases")
+}
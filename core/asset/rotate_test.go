@@ -0,0 +1,85 @@
+package asset
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestRotateKeys(t *testing.T) {
+	r := NewRegistry(pgtest.NewTx(t), prottest.NewChain(t), nil)
+	ctx := context.Background()
+	tags := map[string]interface{}{"class": "metal"}
+	gold, err := r.Define(ctx, []chainkd.XPub{testutil.TestXPub}, 1, 0, nil, "gold", tags, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	xpubs := []chainkd.XPub{testutil.TestXPub, xpub2}
+	rot, err := r.RotateKeys(ctx, gold.AssetID, xpubs, 2, "rotate-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if rot.SuccessorID == gold.AssetID || bytes.Equal(rot.IssuanceProgram, gold.IssuanceProgram) {
+		t.Errorf("rotation = %+v, want a new asset and issuance program", rot)
+	}
+
+	// Retrying with the same client token finds the same rotation.
+	rot2, err := r.RotateKeys(ctx, gold.AssetID, xpubs, 2, "rotate-1")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if rot2.SuccessorID != rot.SuccessorID {
+		t.Errorf("retried rotation successor = %s want %s", rot2.SuccessorID, rot.SuccessorID)
+	}
+	_, err = r.RotateKeys(ctx, gold.AssetID, xpubs, 2, "rotate-2")
+	if errors.Root(err) != ErrRotated {
+		t.Errorf("second rotation: got error %v want %v", err, ErrRotated)
+	}
+
+	// The alias and tags move to the successor.
+	succ, err := r.FindByAlias(ctx, "gold")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if succ.AssetID != rot.SuccessorID || succ.Signer.Quorum != 2 || succ.Tags["class"] != "metal" {
+		t.Errorf("asset aliased gold = %+v, want 2-of-2 successor %s", succ, rot.SuccessorID)
+	}
+	orig, err := r.findByID(ctx, gold.AssetID)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if orig.Alias != nil || orig.SuccessorID == nil || *orig.SuccessorID != rot.SuccessorID {
+		t.Errorf("original asset = %+v, want no alias and successor %s", orig, rot.SuccessorID)
+	}
+
+	builder := new(txbuilder.TemplateBuilder)
+	err = r.NewIssueAction(bc.AssetAmount{AssetID: gold.AssetID, Amount: 1}, nil).Build(ctx, builder)
+	if errors.Root(err) != ErrRotated {
+		t.Errorf("issuing original asset: got error %v want %v", err, ErrRotated)
+	}
+	err = r.NewIssueAction(bc.AssetAmount{AssetID: rot.SuccessorID, Amount: 1}, nil).Build(ctx, builder)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	aliases, err := r.successorAliases(ctx, [][]byte{gold.AssetID[:]})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if aliases[gold.AssetID] != "gold" {
+		t.Errorf("successor alias of original asset = %q want gold", aliases[gold.AssetID])
+	}
+}
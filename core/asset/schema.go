@@ -0,0 +1,296 @@
+package asset
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"chain/errors"
+)
+
+// KeyFields is the error data key for the
+// field errors of an invalid asset definition.
+const KeyFields = "fields"
+
+var (
+	// ErrBadSchema is returned by ParseSchema
+	// for a malformed or unsupported schema.
+	ErrBadSchema = errors.New("invalid asset definition schema")
+
+	// ErrBadDefinition is returned by Define when the asset
+	// definition doesn't conform to the registry's schema.
+	// Its data holds a list of FieldErrors under KeyFields.
+	ErrBadDefinition = errors.New("asset definition does not match schema")
+)
+
+// A FieldError describes one way in which an asset
+// definition fails to conform to a schema. Path is
+// a JSON Pointer to the offending value.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Schema is a JSON Schema that asset definitions must
+// conform to. It supports the validation keywords type,
+// enum, properties, required, additionalProperties, items,
+// minimum, maximum, minLength, maxLength, pattern,
+// minItems and maxItems. Other keywords are ignored.
+type Schema struct {
+	types                []string
+	enum                 []interface{}
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	noAdditional         bool
+	items                *Schema
+	minimum, maximum     *float64
+	minLength, maxLength *int
+	minItems, maxItems   *int
+	pattern              *regexp.Regexp
+}
+
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// ParseSchema parses a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	s := new(Schema)
+	err := json.Unmarshal(data, s)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadSchema, err.Error())
+	}
+	return s, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type                 json.RawMessage    `json:"type"`
+		Enum                 []interface{}      `json:"enum"`
+		Properties           map[string]*Schema `json:"properties"`
+		Required             []string           `json:"required"`
+		AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+		Items                *Schema            `json:"items"`
+		Minimum              *float64           `json:"minimum"`
+		Maximum              *float64           `json:"maximum"`
+		MinLength            *int               `json:"minLength"`
+		MaxLength            *int               `json:"maxLength"`
+		MinItems             *int               `json:"minItems"`
+		MaxItems             *int               `json:"maxItems"`
+		Pattern              *string            `json:"pattern"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*s = Schema{
+		enum:       raw.Enum,
+		properties: raw.Properties,
+		required:   raw.Required,
+		items:      raw.Items,
+		minimum:    raw.Minimum,
+		maximum:    raw.Maximum,
+		minLength:  raw.MinLength,
+		maxLength:  raw.MaxLength,
+		minItems:   raw.MinItems,
+		maxItems:   raw.MaxItems,
+	}
+
+	if len(raw.Type) > 0 {
+		var one string
+		if json.Unmarshal(raw.Type, &one) == nil {
+			s.types = []string{one}
+		} else if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			return fmt.Errorf("type must be a string or an array of strings")
+		}
+		for _, t := range s.types {
+			if !schemaTypes[t] {
+				return fmt.Errorf("unknown type %q", t)
+			}
+		}
+	}
+
+	if len(raw.AdditionalProperties) > 0 {
+		var allowed bool
+		if json.Unmarshal(raw.AdditionalProperties, &allowed) == nil {
+			s.noAdditional = !allowed
+		} else if err := json.Unmarshal(raw.AdditionalProperties, &s.additionalProperties); err != nil {
+			return err
+		}
+	}
+
+	if raw.Pattern != nil {
+		s.pattern, err = regexp.Compile(*raw.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %s", *raw.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks v, a value decoded from JSON, against the
+// schema. It returns an error for each value that fails,
+// in path order.
+func (s *Schema) Validate(v interface{}) []FieldError {
+	var errs []FieldError
+	s.validate("", normalize(v), &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]FieldError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		fail("must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+
+	if len(s.enum) > 0 {
+		var found bool
+		for _, e := range s.enum {
+			if reflect.DeepEqual(normalize(e), v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of the enumerated values")
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be at most %v", *s.maximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match pattern %q", s.pattern.String())
+		}
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				*errs = append(*errs, FieldError{Path: path + "/" + escapePointer(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + escapePointer(name)
+			if prop, ok := s.properties[name]; ok {
+				prop.validate(p, v[name], errs)
+			} else if s.noAdditional {
+				*errs = append(*errs, FieldError{Path: p, Message: "is not allowed"})
+			} else if s.additionalProperties != nil {
+				s.additionalProperties.validate(p, v[name], errs)
+			}
+		}
+	}
+}
+
+func hasType(v interface{}, types []string) bool {
+	for _, t := range types {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && v == float64(int64(v))) {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize returns v with every number as a float64, so
+// that values decoded with and without json.Decoder.UseNumber
+// compare equal.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, e := range v {
+			a[i] = normalize(e)
+		}
+		return a
+	}
+	return v
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func escapePointer(name string) string {
+	return pointerEscaper.Replace(name)
+}
@@ -0,0 +1,71 @@
+package asset
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"required": ["issuer", "class"],
+		"additionalProperties": false,
+		"properties": {
+			"issuer": {"type": "string", "minLength": 1},
+			"class": {"enum": ["common", "preferred"]},
+			"par": {"type": "integer", "minimum": 0},
+			"codes": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[A-Z]+$"}}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		def  string
+		want []FieldError
+	}{
+		{`{"issuer": "Acme", "class": "common", "par": 1, "codes": ["ACM"]}`, nil},
+		{`{"issuer": "Acme", "class": "common", "par": 1.0}`, nil},
+		{`{"issuer": "", "class": "other"}`, []FieldError{
+			{"/class", "must be one of the enumerated values"},
+			{"/issuer", "must be at least 1 characters long"},
+		}},
+		{`{"class": "common", "par": 1.5, "note": "x"}`, []FieldError{
+			{"/issuer", "is required"},
+			{"/note", "is not allowed"},
+			{"/par", "must be of type integer"},
+		}},
+		{`{"issuer": "Acme", "class": "common", "codes": ["ACM", "acm", "X"]}`, []FieldError{
+			{"/codes", "must have at most 2 items"},
+			{"/codes/1", `must match pattern "^[A-Z]+$"`},
+		}},
+	}
+	for _, c := range cases {
+		// Decode numbers as json.Number, as API requests are.
+		dec := json.NewDecoder(strings.NewReader(c.def))
+		dec.UseNumber()
+		var def map[string]interface{}
+		err := dec.Decode(&def)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := schema.Validate(def)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("Validate(%s) = %v want %v", c.def, got, c.want)
+		}
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	for _, s := range []string{`[]`, `{"type": "date"}`, `{"pattern": "("}`} {
+		_, err := ParseSchema([]byte(s))
+		if errors.Root(err) != ErrBadSchema {
+			t.Errorf("ParseSchema(%s) error = %v want %v", s, err, ErrBadSchema)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package core
 
 import (
 	"context"
-	"sync"
+	"math"
 
+	"chain/core/asset"
 	"chain/core/signers"
 	"chain/crypto/ed25519/chainkd"
 	"chain/encoding/json"
-	"chain/net/http/reqid"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 )
 
 // This type enforces JSON field ordering in API output.
@@ -20,6 +23,15 @@ type assetResponse struct {
 	Definition      interface{} `json:"definition"`
 	Tags            interface{} `json:"tags"`
 	IsLocal         interface{} `json:"is_local"`
+	IsArchived      interface{} `json:"is_archived"`
+
+	// Only assets with a maximum issuance have these.
+	MaxIssuance       interface{} `json:"max_issuance,omitempty"`
+	Issued            interface{} `json:"issued,omitempty"`
+	RemainingIssuance interface{} `json:"remaining_issuance,omitempty"`
+
+	// Only assets whose keys have been rotated have this.
+	SuccessorID interface{} `json:"successor_id,omitempty"`
 }
 
 type assetKey struct {
@@ -36,58 +48,252 @@ func (h *Handler) createAsset(ctx context.Context, ins []struct {
 	Definition map[string]interface{}
 	Tags       map[string]interface{}
 
+	// MaxIssuance, if set, caps the total amount
+	// of the asset that may be issued.
+	MaxIssuance uint64 `json:"max_issuance"`
+
+	// DefinitionReference, if set, points to a definition
+	// document kept off the blockchain. The asset's definition
+	// then holds only the reference, and must be left empty.
+	DefinitionReference *asset.DefinitionRef `json:"definition_reference"`
+
 	// ClientToken is the application's unique token for the asset. Every asset
 	// should have a unique client token. The client token is used to ensure
 	// idempotency of create asset requests. Duplicate create asset requests
 	// with the same client_token will only create one asset.
 	ClientToken string `json:"client_token"`
-}) ([]interface{}, error) {
-	responses := make([]interface{}, len(ins))
-	var wg sync.WaitGroup
-	wg.Add(len(responses))
-
-	for i := range responses {
-		go func(i int) {
-			subctx := reqid.NewSubContext(ctx, reqid.New())
-			defer wg.Done()
-			defer batchRecover(subctx, &responses[i])
-
-			asset, err := h.Assets.Define(
-				subctx,
-				ins[i].RootXPubs,
-				ins[i].Quorum,
-				ins[i].Definition,
-				ins[i].Alias,
-				ins[i].Tags,
-				ins[i].ClientToken,
-			)
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		if ins[i].MaxIssuance > math.MaxInt64 {
+			return nil, errors.WithDetailf(httpjson.ErrBadRequest, "max_issuance must be at most %d", int64(math.MaxInt64))
+		}
+		def := ins[i].Definition
+		if ref := ins[i].DefinitionReference; ref != nil {
+			if len(def) > 0 {
+				return nil, errors.WithDetail(asset.ErrBadReference, "definition must be empty when definition_reference is set")
+			}
+			var err error
+			def, err = asset.ReferenceDefinition(ref.URL, ref.Hash)
 			if err != nil {
-				responses[i] = err
-				return
+				return nil, err
 			}
-			var keys []assetKey
-			for _, xpub := range asset.Signer.XPubs {
-				path := signers.Path(asset.Signer, signers.AssetKeySpace)
-				derived := xpub.Derive(path)
-				keys = append(keys, assetKey{
-					AssetPubkey:         json.HexBytes(derived[:]),
-					RootXPub:            xpub,
-					AssetDerivationPath: path,
-				})
+		}
+		alias := ins[i].Alias
+		if alias != "" {
+			var err error
+			alias, err = qualifyAssetAlias(ctx, alias)
+			if err != nil {
+				return nil, err
+			}
+		}
+		asset, err := h.Assets.Define(
+			ctx,
+			ins[i].RootXPubs,
+			ins[i].Quorum,
+			ins[i].MaxIssuance,
+			def,
+			alias,
+			ins[i].Tags,
+			ins[i].ClientToken,
+		)
+		if err != nil {
+			return nil, err
+		}
+		return newAssetResponse(asset), nil
+	})
+}
+
+// POST /update-asset
+//
+// updateAsset changes the alias and tags of assets, identified
+// by asset_id or asset_alias. An omitted or null alias or tags
+// is left unchanged; an empty alias removes the asset's alias.
+func (h *Handler) updateAsset(ctx context.Context, ins []struct {
+	AssetID    bc.AssetID              `json:"asset_id"`
+	AssetAlias string                  `json:"asset_alias"`
+	Alias      *string                 `json:"alias"`
+	Tags       *map[string]interface{} `json:"tags"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		id := ins[i].AssetID
+		if ins[i].AssetAlias != "" {
+			a, err := h.findAssetByAlias(ctx, ins[i].AssetAlias)
+			if err != nil {
+				return nil, errors.Wrapf(err, "finding asset by alias %s", ins[i].AssetAlias)
 			}
-			responses[i] = &assetResponse{
-				ID:              asset.AssetID,
-				Alias:           asset.Alias,
-				IssuanceProgram: json.HexBytes(asset.IssuanceProgram),
-				Keys:            keys,
-				Quorum:          asset.Signer.Quorum,
-				Definition:      asset.Definition,
-				Tags:            asset.Tags,
-				IsLocal:         "yes",
+			id = a.AssetID
+		}
+		alias := ins[i].Alias
+		if alias != nil && *alias != "" {
+			q, err := qualifyAssetAlias(ctx, *alias)
+			if err != nil {
+				return nil, err
 			}
-		}(i)
+			alias = &q
+		}
+		asset, err := h.Assets.Update(ctx, id, alias, ins[i].Tags)
+		if err != nil {
+			return nil, err
+		}
+		return newAssetResponse(asset), nil
+	})
+}
+
+// rotateAssetKeys replaces the keys and quorum behind an asset's
+// issuance program with a successor asset. See asset.RotateKeys.
+//
+// POST /rotate-asset-keys
+func (h *Handler) rotateAssetKeys(ctx context.Context, x struct {
+	AssetID     bc.AssetID     `json:"asset_id"`
+	AssetAlias  string         `json:"asset_alias"`
+	RootXPubs   []chainkd.XPub `json:"root_xpubs"`
+	Quorum      int
+	ClientToken string `json:"client_token"`
+}) (*asset.Rotation, error) {
+	id := x.AssetID
+	if x.AssetAlias != "" {
+		a, err := h.findAssetByAlias(ctx, x.AssetAlias)
+		if err != nil {
+			return nil, errors.Wrapf(err, "finding asset by alias %s", x.AssetAlias)
+		}
+		id = a.AssetID
+	}
+	return h.Assets.RotateKeys(ctx, id, x.RootXPubs, x.Quorum, x.ClientToken)
+}
+
+func newAssetResponse(a *asset.Asset) *assetResponse {
+	resp := &assetResponse{
+		ID:              a.AssetID,
+		Alias:           a.Alias,
+		IssuanceProgram: json.HexBytes(a.IssuanceProgram),
+		Definition:      a.Definition,
+		Tags:            a.Tags,
+		IsLocal:         "no",
+		IsArchived:      "no",
+	}
+	if a.Archived {
+		resp.IsArchived = "yes"
+	}
+	if a.MaxIssuance > 0 {
+		resp.MaxIssuance = a.MaxIssuance
+	}
+	if a.SuccessorID != nil {
+		resp.SuccessorID = *a.SuccessorID
 	}
+	if a.Signer != nil {
+		var keys []assetKey
+		for _, xpub := range a.Signer.XPubs {
+			path := signers.Path(a.Signer, signers.AssetKeySpace)
+			derived := xpub.Derive(path)
+			keys = append(keys, assetKey{
+				AssetPubkey:         json.HexBytes(derived[:]),
+				RootXPub:            xpub,
+				AssetDerivationPath: path,
+			})
+		}
+		resp.Keys = keys
+		resp.Quorum = a.Signer.Quorum
+		resp.IsLocal = "yes"
+	}
+	return resp
+}
+
+// POST /import-asset
+//
+// importAsset registers assets issued by other cores, before
+// this core has seen them on the blockchain, so their aliases
+// and tags can be used right away.
+func (h *Handler) importAsset(ctx context.Context, ins []struct {
+	AssetID bc.AssetID             `json:"asset_id"`
+	Alias   string                 `json:"alias"`
+	Tags    map[string]interface{} `json:"tags"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		if ins[i].AssetID == (bc.AssetID{}) {
+			return nil, errors.WithDetail(httpjson.ErrBadRequest, "asset_id is required")
+		}
+		alias := ins[i].Alias
+		if alias != "" {
+			var err error
+			alias, err = qualifyAssetAlias(ctx, alias)
+			if err != nil {
+				return nil, err
+			}
+		}
+		asset, err := h.Assets.Import(ctx, ins[i].AssetID, alias, ins[i].Tags)
+		if err != nil {
+			return nil, err
+		}
+		return newAssetResponse(asset), nil
+	})
+}
+
+type archiveAssetRequest struct {
+	AssetID    bc.AssetID `json:"asset_id"`
+	AssetAlias string     `json:"asset_alias"`
+}
+
+// POST /archive-asset
+//
+// archiveAsset archives assets, identified by asset_id or
+// asset_alias. Archived assets are left out of /list-assets
+// unless include_archived is set, and can't be named by
+// alias in transaction actions.
+func (h *Handler) archiveAsset(ctx context.Context, ins []archiveAssetRequest) (interface{}, error) {
+	return h.setArchived(ctx, ins, true)
+}
 
-	wg.Wait()
-	return responses, nil
+// POST /unarchive-asset
+func (h *Handler) unarchiveAsset(ctx context.Context, ins []archiveAssetRequest) (interface{}, error) {
+	return h.setArchived(ctx, ins, false)
+}
+
+func (h *Handler) setArchived(ctx context.Context, ins []archiveAssetRequest, archived bool) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		id := ins[i].AssetID
+		if ins[i].AssetAlias != "" {
+			a, err := h.findAssetByAlias(ctx, ins[i].AssetAlias)
+			if err != nil {
+				return nil, errors.Wrapf(err, "finding asset by alias %s", ins[i].AssetAlias)
+			}
+			id = a.AssetID
+		}
+		asset, err := h.Assets.Archive(ctx, id, archived)
+		if err != nil {
+			return nil, err
+		}
+		return newAssetResponse(asset), nil
+	})
+}
+
+// addIssuanceStatuses fills in how much of each capped
+// asset in resps has been issued, and how much remains.
+func (h *Handler) addIssuanceStatuses(ctx context.Context, resps []*assetResponse) error {
+	var ids []bc.AssetID
+	idsByResp := make(map[*assetResponse]bc.AssetID)
+	for _, r := range resps {
+		s, ok := r.ID.(string)
+		if !ok || r.MaxIssuance == nil {
+			continue
+		}
+		var id bc.AssetID
+		if id.UnmarshalText([]byte(s)) == nil {
+			ids = append(ids, id)
+			idsByResp[r] = id
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	statuses, err := h.Assets.IssuanceStatuses(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for r, id := range idsByResp {
+		if st, ok := statuses[id]; ok {
+			r.Issued = st.Issued
+			r.RemainingIssuance = st.Remaining()
+		}
+	}
+	return nil
 }
@@ -0,0 +1,136 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+)
+
+// streamAssetsChunkSize is the number of assets
+// /stream-assets reads from the database at a time.
+const streamAssetsChunkSize = 1000
+
+// streamAssetsLine is one line of the /stream-assets response.
+// A line holds either an asset, or a cursor from which a new
+// stream resumes after every asset written so far. The last
+// line of a complete stream has Done set; a stream that fails
+// part way through ends with an error line instead.
+type streamAssetsLine struct {
+	Asset  *assetResponse `json:"asset,omitempty"`
+	Cursor string         `json:"cursor,omitempty"`
+	Done   bool           `json:"done,omitempty"`
+	Error  *detailedError `json:"error,omitempty"`
+}
+
+// encodeAssetCursor and decodeAssetCursor translate between
+// the sort ID of an annotated asset and an opaque stream cursor.
+// Sort IDs are assigned when an asset is first indexed and never
+// change, so a cursor stays valid while aliases and tags change.
+func encodeAssetCursor(sortID string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sortID))
+}
+
+func decodeAssetCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.WithDetail(httpjson.ErrBadRequest, "invalid cursor")
+	}
+	return string(b), nil
+}
+
+// streamAssets writes every asset matching the requested filter
+// as newline-delimited JSON, in the order of /list-assets. It
+// takes the /list-assets query, plus an optional cursor from an
+// earlier stream. Assets are read in chunks, each followed by a
+// cursor line, so clients can enumerate a large number of assets
+// in one request and resume from the last cursor if it's cut off.
+//
+// POST /stream-assets
+//
+// This handler doesn't use the httpjson.Handler format
+// so that it can write the response incrementally.
+func (h *Handler) streamAssets(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var in struct {
+		requestQuery
+		Cursor string `json:"cursor"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&in)
+	if err != nil {
+		WriteHTTPError(ctx, rw, httpjson.ErrBadRequest)
+		return
+	}
+	after, err := decodeAssetCursor(in.Cursor)
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+	p, params, err := parseFilter(ctx, in.requestQuery, "alias")
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+
+	// Read the first chunk before writing anything, so that
+	// a bad query still gets an ordinary error response.
+	assets, next, err := h.Indexer.Assets(ctx, p, params, after, streamAssetsChunkSize, in.IncludeArchived)
+	if err != nil {
+		WriteHTTPError(ctx, rw, errors.Wrap(err, "running asset query"))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := rw.(http.Flusher)
+	enc := json.NewEncoder(rw)
+	writeErr := func(err error) {
+		logHTTPError(ctx, err)
+		body, _ := errInfo(err)
+		enc.Encode(streamAssetsLine{Error: &body})
+	}
+	for {
+		resps := make([]*assetResponse, 0, len(assets))
+		for _, a := range assets {
+			resps = append(resps, assetResponseFromMap(a))
+		}
+		err = h.addIssuanceStatuses(ctx, resps)
+		if err != nil {
+			writeErr(err)
+			return
+		}
+		for _, r := range resps {
+			err = enc.Encode(streamAssetsLine{Asset: r})
+			if err != nil {
+				log.Error(ctx, errors.Wrap(err, "writing asset"))
+				return
+			}
+		}
+
+		last := len(assets) < streamAssetsChunkSize
+		err = enc.Encode(streamAssetsLine{Cursor: encodeAssetCursor(next), Done: last})
+		if err != nil {
+			log.Error(ctx, errors.Wrap(err, "writing cursor"))
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if last || ctx.Err() != nil {
+			return
+		}
+
+		assets, next, err = h.Indexer.Assets(ctx, p, params, next, streamAssetsChunkSize, in.IncludeArchived)
+		if err != nil {
+			writeErr(errors.Wrap(err, "running asset query"))
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"chain/core/config"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/database/pg/pgtest"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/testutil"
+)
+
+func TestStreamAssets(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	c := prottest.NewChain(t)
+	indexer := query.NewIndexer(db, c, pin.NewStore(db))
+	h := &Handler{DB: db, Chain: c, Indexer: indexer, Config: &config.Config{}}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id := bc.AssetID{byte(i)}
+		annotated := map[string]interface{}{
+			"id":    id.String(),
+			"alias": fmt.Sprintf("asset-%d", i),
+		}
+		err := indexer.SaveAnnotatedAsset(ctx, id, annotated, fmt.Sprintf("%03d", i))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		ids = append([]string{id.String()}, ids...)
+	}
+
+	stream := func(cursor string) (got []string, last streamAssetsLine) {
+		body := fmt.Sprintf(`{"cursor": %q}`, cursor)
+		req := httptest.NewRequest("POST", "/stream-assets", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.streamAssets(rec, req)
+		dec := json.NewDecoder(rec.Body)
+		for dec.More() {
+			var line struct {
+				Asset *struct {
+					ID string `json:"id"`
+				} `json:"asset"`
+				streamAssetsLine
+			}
+			err := dec.Decode(&line)
+			if err != nil {
+				testutil.FatalErr(t, err)
+			}
+			if line.Asset != nil {
+				got = append(got, line.Asset.ID)
+			} else {
+				last = line.streamAssetsLine
+			}
+		}
+		return got, last
+	}
+
+	got, last := stream("")
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("streamed assets = %v want %v", got, ids)
+	}
+	if !last.Done || last.Cursor != encodeAssetCursor("000") {
+		t.Errorf("last line = %+v want done with cursor for 000", last)
+	}
+
+	// Renaming an asset doesn't move it in the stream.
+	renamed := map[string]interface{}{"id": ids[0], "alias": "renamed"}
+	err := indexer.SaveAnnotatedAsset(ctx, bc.AssetID{2}, renamed, "002")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, _ = stream(encodeAssetCursor("002"))
+	if !reflect.DeepEqual(got, ids[1:]) {
+		t.Errorf("streamed assets after cursor = %v want %v", got, ids[1:])
+	}
+}
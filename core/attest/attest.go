@@ -0,0 +1,88 @@
+// Package attest produces and checks signed statements
+// describing the build of a running core.
+//
+// A core that signs blocks signs its attestation with its
+// block-signing key, so the other members of a federation,
+// who know that key from the consensus program, can check
+// that a peer runs the binary they expect.
+package attest
+
+import (
+	"encoding/json"
+	"time"
+
+	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// hashPrefix separates attestation hashes from
+// other messages signed with the same key.
+const hashPrefix = "chain-core-attestation:"
+
+// ErrBadSignature is returned by Verify when an attestation's
+// signature doesn't match its contents and public key.
+var ErrBadSignature = errors.New("invalid attestation signature")
+
+// Build describes how a core binary was built.
+// Release builds set it with linker flags; see
+// bin/build-cored-release.
+type Build struct {
+	Version    string `json:"version"`
+	Commit     string `json:"build_commit"`
+	Date       string `json:"build_date"`
+	Flags      string `json:"build_flags"`
+	VendorHash string `json:"vendor_hash"`
+	GoVersion  string `json:"go_version"`
+	GOOS       string `json:"goos"`
+	GOARCH     string `json:"goarch"`
+	BinaryHash string `json:"binary_hash"`
+}
+
+// An Attestation is a statement, by the core with ID CoreID
+// on the blockchain BlockchainID, that it runs Build. Nonce
+// is chosen by the verifier, so an old attestation can't be
+// passed off as a fresh one.
+type Attestation struct {
+	Build
+	BlockchainID bc.Hash            `json:"blockchain_id"`
+	CoreID       string             `json:"core_id"`
+	Nonce        chainjson.HexBytes `json:"nonce"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Pubkey       chainjson.HexBytes `json:"pubkey"`
+	Signature    chainjson.HexBytes `json:"signature"`
+}
+
+// Hash returns the hash signed by an attestation: the SHA3-256
+// hash of hashPrefix followed by the attestation's JSON encoding
+// with an empty signature.
+func (a *Attestation) Hash() (bc.Hash, error) {
+	unsigned := *a
+	unsigned.Signature = nil
+	b, err := json.Marshal(&unsigned)
+	if err != nil {
+		return bc.Hash{}, errors.Wrap(err)
+	}
+	var h bc.Hash
+	sha3pool.Sum256(h[:], append([]byte(hashPrefix), b...))
+	return h, nil
+}
+
+// Verify checks that a is signed by its public key. The caller
+// must check that the key belongs to a known peer, and that the
+// nonce is the one it chose.
+func Verify(a *Attestation) error {
+	if len(a.Pubkey) != ed25519.PublicKeySize {
+		return errors.WithDetail(ErrBadSignature, "invalid public key")
+	}
+	h, err := a.Hash()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(a.Pubkey), h[:], a.Signature) {
+		return errors.Wrap(ErrBadSignature)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package attest
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/errors"
+)
+
+func TestVerify(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Attestation{
+		Build:  Build{Commit: "abc123", BinaryHash: "def456"},
+		CoreID: "core1",
+		Nonce:  []byte{1, 2, 3},
+		Pubkey: []byte(pub),
+	}
+	h, err := a.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Signature = ed25519.Sign(prv, h[:])
+
+	err = Verify(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.BinaryHash = "0000"
+	err = Verify(a)
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("Verify(modified) error = %v want %v", err, ErrBadSignature)
+	}
+}
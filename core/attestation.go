@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"runtime"
+	"time"
+
+	"chain/core/attest"
+	"chain/core/config"
+	"chain/crypto/ed25519"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+var errNotSigner = errors.New("attestations are signed with the block-signing key of a block signer")
+
+// build returns the build metadata of the running core.
+func build() attest.Build {
+	return attest.Build{
+		Version:    config.Version,
+		Commit:     config.BuildCommit,
+		Date:       config.BuildDate,
+		Flags:      config.BuildFlags,
+		VendorHash: config.VendorHash,
+		GoVersion:  runtime.Version(),
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		BinaryHash: config.BinaryHash,
+	}
+}
+
+// getAttestationRPC returns a statement of the core's build,
+// signed with its block-signing key. The caller supplies a
+// nonce to show the attestation is fresh.
+//
+// POST /rpc/get-attestation
+func (h *Handler) getAttestationRPC(ctx context.Context, x struct {
+	Nonce chainjson.HexBytes `json:"nonce"`
+}) (*attest.Attestation, error) {
+	if !h.Config.IsSigner {
+		return nil, errors.Wrap(errNotSigner)
+	}
+	pub, err := hex.DecodeString(h.Config.BlockPub)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding block pubkey")
+	}
+
+	a := &attest.Attestation{
+		Build:        build(),
+		BlockchainID: h.Config.BlockchainID,
+		CoreID:       h.Config.ID,
+		Nonce:        x.Nonce,
+		Timestamp:    time.Now().UTC(),
+		Pubkey:       pub,
+	}
+	hash, err := a.Hash()
+	if err != nil {
+		return nil, err
+	}
+	a.Signature, err = h.HSM.Sign(ctx, ed25519.PublicKey(pub), hash[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "signing attestation")
+	}
+	return a, nil
+}
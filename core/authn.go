@@ -21,64 +21,126 @@ type apiAuthn struct {
 	// alternative authentication mechanism,
 	// used when no basic auth creds are provided.
 	alt func(*http.Request) bool
+	// replay, if set, rejects replayed requests
+	// authenticated with network tokens.
+	replay *replayGuard
 
 	tokenMu  sync.Mutex // protects the following
 	tokenMap map[string]tokenResult
 }
 
 type tokenResult struct {
+	id         string
 	valid      bool
+	namespace  string
 	lastLookup time.Time
+
+	// until, if set, is when a valid
+	// token's credentials stop being valid.
+	until time.Time
+}
+
+type namespaceKey struct{}
+
+// aliasNamespace returns the alias namespace of the access
+// token that authenticated the request in ctx, or "" if the
+// token has none.
+func aliasNamespace(ctx context.Context) string {
+	ns, _ := ctx.Value(namespaceKey{}).(string)
+	return ns
 }
 
 func (a *apiAuthn) handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
-		err := a.auth(req)
+		namespace, err := a.auth(req)
 		if err != nil {
 			WriteHTTPError(req.Context(), rw, err)
 			return
 		}
+		if namespace != "" {
+			req = req.WithContext(context.WithValue(req.Context(), namespaceKey{}, namespace))
+		}
 		next.ServeHTTP(rw, req)
 	})
 }
 
-func (a *apiAuthn) auth(req *http.Request) error {
+// auth authenticates req. It returns the alias
+// namespace of the client token used, if any.
+func (a *apiAuthn) auth(req *http.Request) (namespace string, err error) {
 	user, pw, ok := req.BasicAuth()
 	if !ok && a.alt(req) {
-		return nil
+		return "", nil
 	}
 
 	typ := "client"
-	if strings.HasPrefix(req.URL.Path, networkRPCPrefix) {
+	switch {
+	case strings.HasPrefix(req.URL.Path, networkRPCPrefix):
 		typ = "network"
+	case strings.HasPrefix(req.URL.Path, explorerPrefix):
+		typ = "explorer"
+	}
+	namespace, err = a.cachedAuthCheck(req.Context(), typ, user, pw)
+	if err == errNotAuthenticated && typ == "explorer" {
+		// Client tokens can use the explorer too.
+		namespace, err = a.cachedAuthCheck(req.Context(), "client", user, pw)
+	}
+	if err == errNotAuthenticated && typ != "network" {
+		// Admin tokens can do anything a client token can.
+		namespace, err = a.cachedAuthCheck(req.Context(), "admin", user, pw)
+	}
+	if err != nil {
+		return "", err
+	}
+	if typ == "network" && a.replay != nil {
+		return "", a.replay.check(req.Context(), req, pw)
 	}
-	return a.cachedAuthCheck(req.Context(), typ, user, pw)
+	return namespace, nil
 }
 
-func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (bool, error) {
+func (a *apiAuthn) authCheck(ctx context.Context, typ, user, pw string) (bool, time.Time, error) {
 	pwBytes, err := hex.DecodeString(pw)
 	if err != nil {
-		return false, nil
+		return false, time.Time{}, nil
 	}
-	return a.tokens.Check(ctx, user, typ, pwBytes)
+	return a.tokens.CheckUntil(ctx, user, typ, pwBytes)
 }
 
-func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) error {
+func (a *apiAuthn) cachedAuthCheck(ctx context.Context, typ, user, pw string) (namespace string, err error) {
 	a.tokenMu.Lock()
 	res, ok := a.tokenMap[typ+user+pw]
 	a.tokenMu.Unlock()
-	if !ok || time.Now().After(res.lastLookup.Add(tokenExpiry)) {
-		valid, err := a.authCheck(ctx, typ, user, pw)
+	now := time.Now()
+	if !ok || now.After(res.lastLookup.Add(tokenExpiry)) || (!res.until.IsZero() && now.After(res.until)) {
+		valid, until, err := a.authCheck(ctx, typ, user, pw)
 		if err != nil {
-			return errors.Wrap(err)
+			return "", errors.Wrap(err)
+		}
+		res = tokenResult{id: user, valid: valid, lastLookup: now, until: until}
+		if valid && typ == "client" {
+			res.namespace, err = a.tokens.Namespace(ctx, user)
+			if err != nil {
+				return "", errors.Wrap(err)
+			}
 		}
-		res = tokenResult{valid: valid, lastLookup: time.Now()}
 		a.tokenMu.Lock()
 		a.tokenMap[typ+user+pw] = res
 		a.tokenMu.Unlock()
 	}
 	if !res.valid {
-		return errNotAuthenticated
+		return "", errNotAuthenticated
+	}
+	return res.namespace, nil
+}
+
+// forget drops the cached checks of the access token with
+// the given ID, so a rotated or deleted secret stops working
+// on this process at once.
+func (a *apiAuthn) forget(id string) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	for k, res := range a.tokenMap {
+		if res.id == id {
+			delete(a.tokenMap, k)
+		}
 	}
-	return nil
 }
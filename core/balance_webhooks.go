@@ -0,0 +1,185 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/balancehook"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errBalanceHooksDisabled = errors.New("balance webhooks require transaction indexing")
+
+// createBalanceWebhook registers a URL to be sent the balance
+// changes, in each new block, of an account, an asset or both.
+//
+// POST /create-balance-webhook
+func (h *Handler) createBalanceWebhook(ctx context.Context, x struct {
+	AccountID    string      `json:"account_id"`
+	AccountAlias string      `json:"account_alias"`
+	AssetID      *bc.AssetID `json:"asset_id"`
+	AssetAlias   string      `json:"asset_alias"`
+	Trigger      string      `json:"trigger"`
+	Threshold    uint64      `json:"threshold"`
+	URL          string      `json:"url"`
+}) (*balancehook.Webhook, error) {
+	if h.BalanceHooks == nil {
+		return nil, errBalanceHooksDisabled
+	}
+	accountID := x.AccountID
+	if accountID == "" && x.AccountAlias != "" {
+		acc, err := h.Accounts.FindByAlias(ctx, x.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		accountID = acc.ID
+	}
+	assetID := x.AssetID
+	if assetID == nil && x.AssetAlias != "" {
+		a, err := h.findAssetByAlias(ctx, x.AssetAlias)
+		if err != nil {
+			return nil, err
+		}
+		assetID = &a.AssetID
+	}
+	return h.BalanceHooks.Create(ctx, accountID, assetID, x.Trigger, x.Threshold, x.URL)
+}
+
+// POST /list-balance-webhooks
+func (h *Handler) listBalanceWebhooks(ctx context.Context, x requestQuery) (*page, error) {
+	if h.BalanceHooks == nil {
+		return nil, errBalanceHooksDisabled
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	hooks, next, err := h.BalanceHooks.List(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(hooks),
+		LastPage: len(hooks) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// POST /delete-balance-webhook
+func (h *Handler) deleteBalanceWebhook(ctx context.Context, x struct {
+	ID string `json:"id"`
+}) error {
+	if h.BalanceHooks == nil {
+		return errBalanceHooksDisabled
+	}
+	return h.BalanceHooks.Delete(ctx, x.ID)
+}
+
+// createBalanceAlertRule registers a rule alerting when an
+// account's balance of an asset drops below a minimum.
+//
+// POST /create-balance-alert-rule
+func (h *Handler) createBalanceAlertRule(ctx context.Context, x struct {
+	AccountID    string     `json:"account_id"`
+	AccountAlias string     `json:"account_alias"`
+	AssetID      bc.AssetID `json:"asset_id"`
+	AssetAlias   string     `json:"asset_alias"`
+	Minimum      uint64     `json:"minimum"`
+	URL          string     `json:"url"`
+}) (*balancehook.Rule, error) {
+	if h.BalanceHooks == nil {
+		return nil, errBalanceHooksDisabled
+	}
+	accountID := x.AccountID
+	if accountID == "" && x.AccountAlias != "" {
+		acc, err := h.Accounts.FindByAlias(ctx, x.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		accountID = acc.ID
+	}
+	assetID := x.AssetID
+	if assetID == (bc.AssetID{}) && x.AssetAlias != "" {
+		a, err := h.findAssetByAlias(ctx, x.AssetAlias)
+		if err != nil {
+			return nil, err
+		}
+		assetID = a.AssetID
+	}
+	return h.BalanceHooks.CreateRule(ctx, accountID, assetID, x.Minimum, x.URL)
+}
+
+// POST /list-balance-alert-rules
+func (h *Handler) listBalanceAlertRules(ctx context.Context, x requestQuery) (*page, error) {
+	if h.BalanceHooks == nil {
+		return nil, errBalanceHooksDisabled
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	rules, next, err := h.BalanceHooks.ListRules(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(rules),
+		LastPage: len(rules) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// POST /delete-balance-alert-rule
+func (h *Handler) deleteBalanceAlertRule(ctx context.Context, x struct {
+	ID string `json:"id"`
+}) error {
+	if h.BalanceHooks == nil {
+		return errBalanceHooksDisabled
+	}
+	return h.BalanceHooks.DeleteRule(ctx, x.ID)
+}
+
+// listBalanceAlerts lists alerts, oldest first. Like
+// /list-transactions, with ascending_with_long_poll set it
+// waits, up to the timeout, for an alert after the cursor.
+//
+// POST /list-balance-alerts
+func (h *Handler) listBalanceAlerts(ctx context.Context, x requestQuery) (*page, error) {
+	if h.BalanceHooks == nil {
+		return nil, errBalanceHooksDisabled
+	}
+	if x.Timeout.Duration != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, x.Timeout.Duration)
+		defer cancel()
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	alerts, next, err := h.BalanceHooks.Alerts(ctx, x.After, limit, x.AscLongPoll)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(alerts),
+		LastPage: len(alerts) < limit,
+		Next:     outQuery,
+	}, nil
+}
@@ -0,0 +1,243 @@
+package balancehook
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// EventBalanceAlert is the type of the webhook
+// event sent for each alert.
+const EventBalanceAlert = "balance_alert"
+
+// ErrBadRule is returned by CreateRule when the rule is
+// missing its account or asset, or has a zero minimum.
+var ErrBadRule = errors.New("invalid balance alert rule")
+
+// A Rule raises an alert each time an account's balance of
+// an asset drops below a minimum. A balance already below
+// the minimum must recover before it alerts again.
+type Rule struct {
+	ID        string     `json:"id"`
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Minimum   uint64     `json:"minimum"`
+	URL       string     `json:"url,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// fires reports whether a balance changing from
+// before to after drops below r's minimum.
+func (r *Rule) fires(before, after uint64) bool {
+	return before >= r.Minimum && after < r.Minimum
+}
+
+// Alert records a balance dropping below a rule's minimum.
+type Alert struct {
+	ID          string     `json:"id"`
+	RuleID      string     `json:"rule_id"`
+	AccountID   string     `json:"account_id"`
+	AssetID     bc.AssetID `json:"asset_id"`
+	Minimum     uint64     `json:"minimum"`
+	Balance     uint64     `json:"balance"`
+	BlockHeight uint64     `json:"block_height"`
+	Timestamp   time.Time  `json:"timestamp"`
+
+	rule *Rule
+}
+
+// AlertEvent is the body of an alert's webhook request.
+type AlertEvent struct {
+	Type  string `json:"type"`
+	Alert *Alert `json:"alert"`
+}
+
+// CreateRule stores a rule alerting when the account's balance
+// of the asset drops below minimum. If rawURL is set, each alert
+// is also sent there. The rule applies to blocks that land after
+// it is created.
+func (n *Notifier) CreateRule(ctx context.Context, accountID string, assetID bc.AssetID, minimum uint64, rawURL string) (*Rule, error) {
+	if accountID == "" || assetID == (bc.AssetID{}) {
+		return nil, errors.WithDetail(ErrBadRule, "an account and an asset are required")
+	}
+	if minimum == 0 {
+		return nil, errors.WithDetail(ErrBadRule, "minimum must be positive")
+	}
+	if rawURL != "" {
+		err := checkURL(rawURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := &Rule{AccountID: accountID, AssetID: assetID, Minimum: minimum, URL: rawURL}
+	const q = `
+		INSERT INTO balance_alert_rules (account_id, asset_id, minimum, url, created_height)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	url := sql.NullString{String: rawURL, Valid: rawURL != ""}
+	err := n.DB.QueryRow(ctx, q, accountID, assetID, minimum, url, n.Chain.Height()).Scan(&r.ID, &r.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting balance alert rule")
+	}
+	return r, nil
+}
+
+// DeleteRule removes the rule with the given ID.
+// Alerts it raised are kept.
+func (n *Notifier) DeleteRule(ctx context.Context, id string) error {
+	res, err := n.DB.Exec(ctx, `DELETE FROM balance_alert_rules WHERE id = $1`, id)
+	if err != nil {
+		return errors.Wrap(err, "deleting balance alert rule")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "deleting balance alert rule")
+	}
+	if affected == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "balance alert rule %s", id)
+	}
+	return nil
+}
+
+// ListRules returns a page of rules, ordered by ID.
+// It also returns the value of after for the next page.
+func (n *Notifier) ListRules(ctx context.Context, after string, limit int) ([]*Rule, string, error) {
+	const q = `
+		SELECT id, account_id, asset_id, minimum, url, created_at
+		FROM balance_alert_rules WHERE id > $1 ORDER BY id LIMIT $2
+	`
+	rules, err := n.queryRules(ctx, q, after, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing balance alert rules")
+	}
+	if len(rules) > 0 {
+		after = rules[len(rules)-1].ID
+	}
+	return rules, after, nil
+}
+
+// activeRules returns the rules that apply
+// to the block at the given height.
+func (n *Notifier) activeRules(ctx context.Context, height uint64) ([]*Rule, error) {
+	const q = `
+		SELECT id, account_id, asset_id, minimum, url, created_at
+		FROM balance_alert_rules WHERE created_height < $1
+	`
+	rules, err := n.queryRules(ctx, q, height)
+	return rules, errors.Wrap(err, "loading balance alert rules")
+}
+
+func (n *Notifier) queryRules(ctx context.Context, q string, args ...interface{}) ([]*Rule, error) {
+	var rules []*Rule
+	args = append(args, func(id, accountID string, assetID bc.AssetID, minimum uint64, url sql.NullString, createdAt time.Time) {
+		rules = append(rules, &Rule{
+			ID:        id,
+			AccountID: accountID,
+			AssetID:   assetID,
+			Minimum:   minimum,
+			URL:       url.String,
+			CreatedAt: createdAt,
+		})
+	})
+	err := pg.ForQueryRows(ctx, n.DB, q, args...)
+	return rules, err
+}
+
+// raise saves alerts and sends the new ones to
+// the webhooks of the rules that raised them.
+func (n *Notifier) raise(ctx context.Context, alerts []*Alert) error {
+	const q = `
+		INSERT INTO balance_alerts (rule_id, account_id, asset_id, minimum, balance, block_height, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (rule_id, block_height) DO NOTHING
+		RETURNING id
+	`
+	for _, a := range alerts {
+		var id int64
+		err := n.DB.QueryRow(ctx, q, a.RuleID, a.AccountID, a.AssetID, a.Minimum, a.Balance, a.BlockHeight, a.Timestamp).Scan(&id)
+		if err == sql.ErrNoRows {
+			// A block processed again doesn't alert again.
+			continue
+		}
+		if err != nil {
+			return errors.Wrap(err, "saving balance alert")
+		}
+		a.ID = strconv.FormatInt(id, 10)
+		if a.rule.URL != "" {
+			go n.notify(ctx, a.RuleID, a.rule.URL, &AlertEvent{Type: EventBalanceAlert, Alert: a})
+		}
+	}
+	return nil
+}
+
+// Alerts returns up to limit alerts, oldest first, starting
+// after the one with the given ID. It also returns the value
+// of after for the next page. If wait is set and there are no
+// such alerts yet, it waits for blocks to raise some, until
+// ctx is done.
+func (n *Notifier) Alerts(ctx context.Context, after string, limit int, wait bool) ([]*Alert, string, error) {
+	var afterID int64
+	if after != "" {
+		var err error
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(query.ErrBadAfter, "value: %q", after)
+		}
+	}
+
+	// Read the height first, so a block processed
+	// during the first fetch isn't waited for.
+	var height uint64
+	if wait {
+		height = n.PinStore.Height(PinName)
+	}
+	alerts, err := n.fetchAlerts(ctx, afterID, limit)
+	for err == nil && wait && len(alerts) == 0 {
+		height++
+		select {
+		case <-n.PinStore.PinWaiter(PinName, height):
+		case <-ctx.Done():
+			return nil, "", ctx.Err()
+		}
+		alerts, err = n.fetchAlerts(ctx, afterID, limit)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(alerts) > 0 {
+		after = alerts[len(alerts)-1].ID
+	}
+	return alerts, after, nil
+}
+
+func (n *Notifier) fetchAlerts(ctx context.Context, afterID int64, limit int) ([]*Alert, error) {
+	const q = `
+		SELECT id, rule_id, account_id, asset_id, minimum, balance, block_height, timestamp
+		FROM balance_alerts WHERE id > $1 ORDER BY id LIMIT $2
+	`
+	var alerts []*Alert
+	err := pg.ForQueryRows(ctx, n.DB, q, afterID, limit, func(
+		id int64, ruleID, accountID string, assetID bc.AssetID,
+		minimum, balance, height uint64, timestamp time.Time,
+	) {
+		alerts = append(alerts, &Alert{
+			ID:          strconv.FormatInt(id, 10),
+			RuleID:      ruleID,
+			AccountID:   accountID,
+			AssetID:     assetID,
+			Minimum:     minimum,
+			Balance:     balance,
+			BlockHeight: height,
+			Timestamp:   timestamp,
+		})
+	})
+	return alerts, errors.Wrap(err, "listing balance alerts")
+}
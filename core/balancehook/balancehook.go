@@ -0,0 +1,440 @@
+// Package balancehook notifies webhooks when account
+// balances change, as each block is indexed.
+//
+// A webhook watches the balances of one account, of one
+// asset across all accounts, or of one asset in one account.
+// Its trigger decides which changes it hears about: any
+// change, a change of at least a threshold amount, or a
+// balance becoming or ceasing to be zero. A block's changes
+// are the net effect of all its transactions, and each block
+// sends a webhook at most one request, listing them all.
+//
+// An alert rule raises an alert when an account's balance of
+// an asset drops below a minimum. Alerts are stored, to be
+// read with Alerts, and optionally sent to a webhook.
+package balancehook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/webhook"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+// PinName is used to identify the pin associated with
+// the balance webhook block processor.
+const PinName = "balance-webhooks"
+
+// Triggers select the balance changes a webhook hears about.
+const (
+	// TriggerAny fires on every change.
+	TriggerAny = "any"
+
+	// TriggerThreshold fires when the balance goes up
+	// or down by at least the webhook's threshold.
+	TriggerThreshold = "threshold"
+
+	// TriggerZeroCrossing fires when the balance
+	// becomes zero or stops being zero.
+	TriggerZeroCrossing = "zero_crossing"
+)
+
+// EventBalanceChange is the type of the webhook event
+// sent for each block that triggers a webhook.
+const EventBalanceChange = "balance_change"
+
+var (
+	// ErrBadTrigger is returned by Create when the trigger
+	// or threshold is invalid.
+	ErrBadTrigger = errors.New("invalid balance webhook trigger")
+
+	// ErrNoFilter is returned by Create when the webhook
+	// names neither an account nor an asset.
+	ErrNoFilter = errors.New("balance webhook needs an account or asset")
+
+	// ErrBadURL is returned by Create and CreateRule when the
+	// webhook URL is not an absolute http or https URL.
+	ErrBadURL = errors.New("invalid balance webhook URL")
+)
+
+// Webhook describes the balance changes sent to a URL.
+type Webhook struct {
+	ID        string      `json:"id"`
+	AccountID *string     `json:"account_id"`
+	AssetID   *bc.AssetID `json:"asset_id"`
+	Trigger   string      `json:"trigger"`
+	Threshold uint64      `json:"threshold"`
+	URL       string      `json:"url"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// matches reports whether w watches the balance
+// of the given account and asset.
+func (w *Webhook) matches(accountID string, assetID bc.AssetID) bool {
+	return (w.AccountID == nil || *w.AccountID == accountID) &&
+		(w.AssetID == nil || *w.AssetID == assetID)
+}
+
+// fires reports whether w's trigger fires when a
+// balance changes from before to after.
+func (w *Webhook) fires(before, after uint64) bool {
+	switch w.Trigger {
+	case TriggerAny:
+		return before != after
+	case TriggerThreshold:
+		if after > before {
+			return after-before >= w.Threshold
+		}
+		return before-after >= w.Threshold
+	case TriggerZeroCrossing:
+		return (before == 0) != (after == 0)
+	}
+	return false
+}
+
+// Change is the change in one account's
+// balance of one asset over a block.
+type Change struct {
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Previous  uint64     `json:"previous_balance"`
+	Balance   uint64     `json:"balance"`
+	Delta     int64      `json:"delta"`
+}
+
+// Event is the body of a webhook request.
+type Event struct {
+	Type        string    `json:"type"`
+	WebhookID   string    `json:"webhook_id"`
+	BlockHeight uint64    `json:"block_height"`
+	Timestamp   time.Time `json:"timestamp"`
+	Changes     []*Change `json:"changes"`
+}
+
+// Notifier stores balance webhooks and alert rules, and
+// evaluates them against the balance changes in each block,
+// after the block's transactions are indexed.
+type Notifier struct {
+	DB       pg.DB
+	Chain    *protocol.Chain
+	PinStore *pin.Store
+	Client   *http.Client
+}
+
+// Create stores a webhook for the balances of accountID,
+// assetID or both. Threshold is required by TriggerThreshold
+// and not allowed otherwise. The webhook hears about blocks
+// that land after it is created.
+func (n *Notifier) Create(ctx context.Context, accountID string, assetID *bc.AssetID, trigger string, threshold uint64, rawURL string) (*Webhook, error) {
+	if accountID == "" && assetID == nil {
+		return nil, errors.Wrap(ErrNoFilter)
+	}
+	switch trigger {
+	case TriggerAny, TriggerZeroCrossing:
+		if threshold != 0 {
+			return nil, errors.WithDetailf(ErrBadTrigger, "trigger %q takes no threshold", trigger)
+		}
+	case TriggerThreshold:
+		if threshold == 0 {
+			return nil, errors.WithDetail(ErrBadTrigger, "threshold must be positive")
+		}
+	default:
+		return nil, errors.WithDetailf(ErrBadTrigger, "unknown trigger %q", trigger)
+	}
+	err := checkURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Webhook{Trigger: trigger, Threshold: threshold, URL: rawURL, AssetID: assetID}
+	if accountID != "" {
+		w.AccountID = &accountID
+	}
+	const q = `
+		INSERT INTO balance_webhooks (account_id, asset_id, trigger, threshold, url, last_block_height)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err = n.DB.QueryRow(ctx, q, w.AccountID, w.AssetID, trigger, threshold, rawURL, n.Chain.Height()).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting balance webhook")
+	}
+	return w, nil
+}
+
+// checkURL returns ErrBadURL unless rawURL
+// is an absolute http or https URL.
+func checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.WithDetailf(ErrBadURL, "url: %q", rawURL)
+	}
+	return nil
+}
+
+// Delete removes the webhook with the given ID.
+func (n *Notifier) Delete(ctx context.Context, id string) error {
+	res, err := n.DB.Exec(ctx, `DELETE FROM balance_webhooks WHERE id = $1`, id)
+	if err != nil {
+		return errors.Wrap(err, "deleting balance webhook")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "deleting balance webhook")
+	}
+	if affected == 0 {
+		return errors.WithDetailf(pg.ErrUserInputNotFound, "balance webhook %s", id)
+	}
+	return nil
+}
+
+// List returns a page of webhooks, ordered by ID.
+// It also returns the value of after for the next page.
+func (n *Notifier) List(ctx context.Context, after string, limit int) ([]*Webhook, string, error) {
+	const q = `
+		SELECT id, account_id, asset_id, trigger, threshold, url, created_at
+		FROM balance_webhooks WHERE id > $1 ORDER BY id LIMIT $2
+	`
+	hooks, err := n.query(ctx, q, after, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing balance webhooks")
+	}
+	if len(hooks) > 0 {
+		after = hooks[len(hooks)-1].ID
+	}
+	return hooks, after, nil
+}
+
+func (n *Notifier) query(ctx context.Context, q string, args ...interface{}) ([]*Webhook, error) {
+	var hooks []*Webhook
+	args = append(args, func(id string, accountID sql.NullString, assetID *bc.AssetID, trigger string, threshold uint64, url string, createdAt time.Time) {
+		w := &Webhook{
+			ID:        id,
+			AssetID:   assetID,
+			Trigger:   trigger,
+			Threshold: threshold,
+			URL:       url,
+			CreatedAt: createdAt,
+		}
+		if accountID.Valid {
+			w.AccountID = &accountID.String
+		}
+		hooks = append(hooks, w)
+	})
+	err := pg.ForQueryRows(ctx, n.DB, q, args...)
+	return hooks, err
+}
+
+// ProcessBlocks sends each new block's balance changes to
+// the webhooks they trigger, and raises the alerts they set off.
+func (n *Notifier) ProcessBlocks(ctx context.Context) {
+	if n.PinStore == nil {
+		return
+	}
+	n.PinStore.ProcessBlocks(ctx, n.Chain, PinName, n.notifyBlock)
+}
+
+// balanceTx holds the fields of an annotated transaction
+// used to compute balance changes.
+type balanceTx struct {
+	Inputs  []*balanceEntry `json:"inputs"`
+	Outputs []*balanceEntry `json:"outputs"`
+}
+
+type balanceEntry struct {
+	AccountID string     `json:"account_id"`
+	AssetID   bc.AssetID `json:"asset_id"`
+	Amount    uint64     `json:"amount"`
+}
+
+type balanceKey struct {
+	accountID string
+	assetID   bc.AssetID
+}
+
+// deltas returns the net change, over txs, in
+// each balance the transactions touch.
+func deltas(txs []*balanceTx) map[balanceKey]int64 {
+	d := make(map[balanceKey]int64)
+	for _, tx := range txs {
+		for _, in := range tx.Inputs {
+			if in.AccountID != "" {
+				d[balanceKey{in.AccountID, in.AssetID}] -= int64(in.Amount)
+			}
+		}
+		for _, out := range tx.Outputs {
+			if out.AccountID != "" {
+				d[balanceKey{out.AccountID, out.AssetID}] += int64(out.Amount)
+			}
+		}
+	}
+	return d
+}
+
+func (n *Notifier) notifyBlock(ctx context.Context, b *bc.Block) error {
+	<-n.PinStore.PinWaiter(query.TxPinName, b.Height)
+
+	// Webhooks already notified of this block, and webhooks
+	// and rules created after it landed, are left out.
+	const hooksQ = `
+		SELECT id, account_id, asset_id, trigger, threshold, url, created_at
+		FROM balance_webhooks WHERE last_block_height < $1
+	`
+	hooks, err := n.query(ctx, hooksQ, b.Height)
+	if err != nil {
+		return errors.Wrap(err, "loading balance webhooks")
+	}
+	rules, err := n.activeRules(ctx, b.Height)
+	if err != nil {
+		return err
+	}
+	if len(hooks) == 0 && len(rules) == 0 {
+		return nil
+	}
+
+	const txQ = `SELECT data FROM annotated_txs WHERE block_height = $1 ORDER BY tx_pos`
+	var txs []*balanceTx
+	err = pg.ForQueryRows(ctx, n.DB, txQ, b.Height, func(data []byte) error {
+		tx := new(balanceTx)
+		err := json.Unmarshal(data, tx)
+		if err != nil {
+			return errors.Wrap(err, "decoding annotated transaction")
+		}
+		txs = append(txs, tx)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "querying annotated transactions")
+	}
+
+	var (
+		changes = make(map[*Webhook][]*Change)
+		alerts  []*Alert
+	)
+	for key, delta := range deltas(txs) {
+		if delta == 0 {
+			continue
+		}
+		var (
+			watchers []*Webhook
+			matched  []*Rule
+		)
+		for _, w := range hooks {
+			if w.matches(key.accountID, key.assetID) {
+				watchers = append(watchers, w)
+			}
+		}
+		for _, r := range rules {
+			if r.AccountID == key.accountID && r.AssetID == key.assetID {
+				matched = append(matched, r)
+			}
+		}
+		if len(watchers) == 0 && len(matched) == 0 {
+			continue
+		}
+		balance, err := n.balance(ctx, key, b.TimestampMS)
+		if err != nil {
+			return err
+		}
+		c := &Change{
+			AccountID: key.accountID,
+			AssetID:   key.assetID,
+			Previous:  uint64(int64(balance) - delta),
+			Balance:   balance,
+			Delta:     delta,
+		}
+		for _, w := range watchers {
+			if w.fires(c.Previous, c.Balance) {
+				changes[w] = append(changes[w], c)
+			}
+		}
+		for _, r := range matched {
+			if r.fires(c.Previous, c.Balance) {
+				alerts = append(alerts, &Alert{
+					RuleID:      r.ID,
+					AccountID:   c.AccountID,
+					AssetID:     c.AssetID,
+					Minimum:     r.Minimum,
+					Balance:     c.Balance,
+					BlockHeight: b.Height,
+					Timestamp:   b.Time(),
+					rule:        r,
+				})
+			}
+		}
+	}
+
+	for _, w := range hooks {
+		claimed, err := n.claim(ctx, w.ID, b.Height)
+		if err != nil {
+			return err
+		}
+		// A block processed again doesn't notify again.
+		if claimed && len(changes[w]) > 0 {
+			go n.notify(ctx, w.ID, w.URL, &Event{
+				Type:        EventBalanceChange,
+				WebhookID:   w.ID,
+				BlockHeight: b.Height,
+				Timestamp:   b.Time(),
+				Changes:     changes[w],
+			})
+		}
+	}
+	return n.raise(ctx, alerts)
+}
+
+// balance returns the balance for key as of the block
+// with the given timestamp.
+func (n *Notifier) balance(ctx context.Context, key balanceKey, timestampMS uint64) (uint64, error) {
+	const q = `
+		SELECT COALESCE(SUM((data->>'amount')::bigint), 0) FROM annotated_outputs
+		WHERE data @> $1::jsonb AND timespan @> $2::int8
+	`
+	match, err := json.Marshal(map[string]interface{}{"account_id": key.accountID, "asset_id": key.assetID})
+	if err != nil {
+		return 0, errors.Wrap(err)
+	}
+	var balance uint64
+	err = n.DB.QueryRow(ctx, q, string(match), timestampMS).Scan(&balance)
+	return balance, errors.Wrap(err, "loading balance")
+}
+
+// claim records that the webhook has been notified of the
+// block at height. It reports false if it already had been.
+func (n *Notifier) claim(ctx context.Context, id string, height uint64) (bool, error) {
+	const q = `
+		UPDATE balance_webhooks SET last_block_height = $2
+		WHERE id = $1 AND last_block_height < $2
+	`
+	res, err := n.DB.Exec(ctx, q, id, height)
+	if err != nil {
+		return false, errors.Wrap(err, "updating balance webhook")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "updating balance webhook")
+	}
+	return affected > 0, nil
+}
+
+// notify sends ev to url, on behalf of the webhook or
+// rule with the given ID. Events that can't be delivered
+// are logged and dropped.
+func (n *Notifier) notify(ctx context.Context, id, url string, ev interface{}) {
+	s := &webhook.Sender{URL: url, Client: n.Client}
+	err := s.Send(ctx, ev)
+	if err != nil {
+		log.Error(ctx, err, "at", "sending balance webhook", "id", id)
+	}
+}
@@ -0,0 +1,138 @@
+package balancehook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+func TestDeltas(t *testing.T) {
+	var txs []*balanceTx
+	err := json.Unmarshal([]byte(`[
+		{
+			"inputs": [
+				{"account_id": "alice", "asset_id": "0100000000000000000000000000000000000000000000000000000000000000", "amount": 10},
+				{"asset_id": "0200000000000000000000000000000000000000000000000000000000000000", "amount": 5}
+			],
+			"outputs": [
+				{"account_id": "bob", "asset_id": "0100000000000000000000000000000000000000000000000000000000000000", "amount": 7},
+				{"account_id": "alice", "asset_id": "0100000000000000000000000000000000000000000000000000000000000000", "amount": 3},
+				{"account_id": "bob", "asset_id": "0200000000000000000000000000000000000000000000000000000000000000", "amount": 5}
+			]
+		},
+		{
+			"inputs": [
+				{"account_id": "bob", "asset_id": "0100000000000000000000000000000000000000000000000000000000000000", "amount": 7}
+			],
+			"outputs": [
+				{"account_id": "bob", "asset_id": "0100000000000000000000000000000000000000000000000000000000000000", "amount": 7}
+			]
+		}
+	]`), &txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gold, silver := bc.AssetID{1}, bc.AssetID{2}
+	got := deltas(txs)
+	want := map[balanceKey]int64{
+		{"alice", gold}: -7,
+		{"bob", gold}:   7,
+		{"bob", silver}: 5,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("deltas = %v want %v", got, want)
+	}
+}
+
+func TestFires(t *testing.T) {
+	cases := []struct {
+		trigger       string
+		threshold     uint64
+		before, after uint64
+		want          bool
+	}{
+		{TriggerAny, 0, 5, 6, true},
+		{TriggerAny, 0, 5, 5, false},
+		{TriggerThreshold, 10, 5, 15, true},
+		{TriggerThreshold, 10, 15, 5, true},
+		{TriggerThreshold, 10, 5, 14, false},
+		{TriggerZeroCrossing, 0, 0, 1, true},
+		{TriggerZeroCrossing, 0, 1, 0, true},
+		{TriggerZeroCrossing, 0, 1, 2, false},
+	}
+	for _, c := range cases {
+		w := &Webhook{Trigger: c.trigger, Threshold: c.threshold}
+		if got := w.fires(c.before, c.after); got != c.want {
+			t.Errorf("%s(%d) fires(%d, %d) = %v want %v", c.trigger, c.threshold, c.before, c.after, got, c.want)
+		}
+	}
+}
+
+func TestRuleFires(t *testing.T) {
+	r := &Rule{Minimum: 100}
+	cases := []struct {
+		before, after uint64
+		want          bool
+	}{
+		{150, 99, true},
+		{100, 0, true},
+		{150, 100, false},
+		{99, 50, false}, // already below
+		{50, 150, false},
+	}
+	for _, c := range cases {
+		if got := r.fires(c.before, c.after); got != c.want {
+			t.Errorf("fires(%d, %d) = %v want %v", c.before, c.after, got, c.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	alice, gold := "alice", bc.AssetID{1}
+	cases := []struct {
+		w    *Webhook
+		want bool
+	}{
+		{&Webhook{AccountID: &alice}, true},
+		{&Webhook{AssetID: &gold}, true},
+		{&Webhook{AccountID: &alice, AssetID: &gold}, true},
+		{&Webhook{AccountID: &alice, AssetID: &bc.AssetID{2}}, false},
+	}
+	for i, c := range cases {
+		if got := c.w.matches("alice", gold); got != c.want {
+			t.Errorf("case %d: matches = %v want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestNotify(t *testing.T) {
+	got := make(chan *Event, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ev := new(Event)
+		err := json.NewDecoder(req.Body).Decode(ev)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- ev
+	}))
+	defer ts.Close()
+
+	n := new(Notifier)
+	ev := &Event{
+		Type:        EventBalanceChange,
+		WebhookID:   "bwh1",
+		BlockHeight: 3,
+		Changes:     []*Change{{AccountID: "alice", Previous: 10, Balance: 3, Delta: -7}},
+	}
+	n.notify(context.Background(), "bwh1", ts.URL, ev)
+
+	if e := <-got; !reflect.DeepEqual(e, ev) {
+		t.Errorf("webhook event = %+v want %+v", e, ev)
+	}
+}
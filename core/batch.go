@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/net/http/reqid"
+)
+
+// errBatchAborted is the response for batch items that
+// weren't processed because an earlier item failed.
+var errBatchAborted = errors.New("batch aborted")
+
+// Values for the on_error query parameter of batch endpoints.
+const (
+	onErrorAbort    = "abort"
+	onErrorContinue = "continue"
+)
+
+// batchResponse is the response to a batch request that
+// sets on_error. Other batch requests get only the items,
+// as they did before on_error existed.
+type batchResponse struct {
+	Items   []interface{} `json:"items"`
+	Summary batchSummary  `json:"summary"`
+}
+
+type batchSummary struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// runBatch calls f for each of n batch items and returns
+// the responses, converting errors into error responses.
+//
+// The on_error query parameter controls what happens when
+// an item fails. With "continue" (the default), all items
+// are processed concurrently. With "abort", items are
+// processed in order and those after the first failure
+// are skipped. If on_error is given, the response includes
+// a summary of the outcomes.
+func runBatch(ctx context.Context, n int, f func(ctx context.Context, i int) (interface{}, error)) (interface{}, error) {
+	onError := httpjson.QueryValue(ctx, "on_error")
+	if onError != "" && onError != onErrorAbort && onError != onErrorContinue {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "on_error must be %q or %q", onErrorAbort, onErrorContinue)
+	}
+
+	responses := make([]interface{}, n)
+	failed := make([]bool, n)
+	do := func(i int) {
+		subctx := reqid.NewSubContext(ctx, reqid.New())
+		defer func() {
+			// batchRecover has turned any error into an error response.
+			_, failed[i] = responses[i].(detailedError)
+		}()
+		defer batchRecover(subctx, &responses[i])
+
+		resp, err := f(subctx, i)
+		if err != nil {
+			responses[i] = err
+		} else {
+			responses[i] = resp
+		}
+	}
+
+	var summary batchSummary
+	if onError == onErrorAbort {
+		for i := range responses {
+			do(i)
+			if failed[i] {
+				for j := i + 1; j < n; j++ {
+					responses[j], _ = errInfo(errBatchAborted)
+					summary.Skipped++
+				}
+				break
+			}
+		}
+	} else {
+		var wg sync.WaitGroup
+		wg.Add(n)
+		for i := range responses {
+			go func(i int) {
+				defer wg.Done()
+				do(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	if onError == "" {
+		return responses, nil
+	}
+	for i := range responses {
+		if failed[i] {
+			summary.Failed++
+		} else if i < n-summary.Skipped {
+			summary.Succeeded++
+		}
+	}
+	return batchResponse{Items: responses, Summary: summary}, nil
+}
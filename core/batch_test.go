@@ -0,0 +1,55 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+func TestRunBatch(t *testing.T) {
+	f := func(ctx context.Context, i int) (interface{}, error) {
+		if i == 1 {
+			return nil, errNotFound
+		}
+		return i, nil
+	}
+	notFound, _ := errInfo(errNotFound)
+	aborted, _ := errInfo(errBatchAborted)
+
+	cases := []struct {
+		query string
+		want  interface{}
+	}{
+		{"", []interface{}{0, notFound, 2}},
+		{"on_error=continue", batchResponse{
+			Items:   []interface{}{0, notFound, 2},
+			Summary: batchSummary{Succeeded: 2, Failed: 1},
+		}},
+		{"on_error=abort", batchResponse{
+			Items:   []interface{}{0, notFound, aborted},
+			Summary: batchSummary{Succeeded: 1, Failed: 1, Skipped: 1},
+		}},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", "/build-transaction?"+c.query, nil)
+		ctx := httpjson.WithRequest(context.Background(), req)
+		got, err := runBatch(ctx, 3, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("runBatch with %q = %+v want %+v", c.query, got, c.want)
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/build-transaction?on_error=retry", nil)
+	ctx := httpjson.WithRequest(context.Background(), req)
+	_, err := runBatch(ctx, 3, f)
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("runBatch with on_error=retry error = %v want %v", err, httpjson.ErrBadRequest)
+	}
+}
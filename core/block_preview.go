@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/generator"
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/errors"
+)
+
+// previewBlock reports what the generator's next block would
+// contain if it were made now, as in generator.PreviewBlock,
+// so operators can see why transactions aren't going in.
+//
+// POST /preview-generator-block
+func (h *Handler) previewBlock(ctx context.Context) (*generator.BlockPreview, error) {
+	switch s := h.Submitter.(type) {
+	case *generator.Generator:
+		return h.previewBlockRPC(ctx)
+	case *txbuilder.RemoteGenerator:
+		p := new(generator.BlockPreview)
+		err := s.Peer.Call(ctx, networkRPCPrefix+"preview-block", nil, p)
+		return p, errors.Wrap(err, "generator block preview")
+	}
+	return nil, errors.Wrap(errNotGenerator)
+}
+
+// previewBlockRPC reports the generator's next
+// block for a core that isn't the generator.
+func (h *Handler) previewBlockRPC(ctx context.Context) (*generator.BlockPreview, error) {
+	gen, ok := h.Submitter.(*generator.Generator)
+	if !ok {
+		return nil, errors.Wrap(errNotGenerator)
+	}
+	// The pending pool lives in the generator's leader process.
+	if !leader.IsLeading() {
+		p := new(generator.BlockPreview)
+		err := h.forwardToLeader(ctx, networkRPCPrefix+"preview-block", nil, p)
+		return p, err
+	}
+	return gen.PreviewBlock(ctx)
+}
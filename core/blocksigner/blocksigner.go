@@ -71,7 +71,7 @@ func (s *Signer) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte,
 	}
 	prev, err := s.c.GetBlock(ctx, b.Height-1)
 	if err != nil {
-		return nil, errors.Wrap(err, "getting block at height %d", b.Height-1)
+		return nil, errors.Wrapf(err, "getting block at height %d", b.Height-1)
 	}
 	// TODO: Add the ability to change the consensus program
 	// by having a current consensus program, and a potential
@@ -82,6 +82,10 @@ func (s *Signer) ValidateAndSignBlock(ctx context.Context, b *bc.Block) ([]byte,
 	if !bytes.Equal(b.ConsensusProgram, prev.ConsensusProgram) {
 		return nil, errors.Wrap(ErrConsensusChange)
 	}
+	err = checkSupplyCaps(ctx, s.db, b.SupplyCaps)
+	if err != nil {
+		return nil, err
+	}
 	err = s.c.ValidateBlockForSig(ctx, b)
 	if err != nil {
 		return nil, errors.Wrap(err, "validating block for signature")
@@ -0,0 +1,69 @@
+package blocksigner
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+// ErrUnapprovedSupplyCap is returned from ValidateAndSignBlock
+// when the block sets a supply cap the signer hasn't approved.
+var ErrUnapprovedSupplyCap = errors.New("supply cap has not been approved")
+
+// ApproveSupplyCaps records supply caps that blocks this core
+// signs may set, replacing earlier approvals for the same
+// assets. Approving the cap a block sets is what lets a signer
+// vouch for it, so an asset's cap can't be lifted without the
+// signers' consent.
+func ApproveSupplyCaps(ctx context.Context, db pg.DB, caps []bc.SupplyCap) error {
+	err := validation.CheckSupplyCaps(caps)
+	if err != nil {
+		return err
+	}
+	assetIDs, amounts := pq.ByteaArray{}, pq.Int64Array{}
+	for _, c := range caps {
+		assetID := c.AssetID
+		assetIDs = append(assetIDs, assetID[:])
+		amounts = append(amounts, int64(c.Amount))
+	}
+	const q = `
+		INSERT INTO signer_supply_cap_approvals (asset_id, amount)
+		SELECT unnest($1::bytea[]), unnest($2::bigint[])
+		ON CONFLICT (asset_id) DO UPDATE SET amount = excluded.amount
+	`
+	_, err = db.Exec(ctx, q, assetIDs, amounts)
+	return errors.Wrap(err, "saving supply cap approvals")
+}
+
+// checkSupplyCaps tests that the signer has
+// approved each of a block's supply caps.
+func checkSupplyCaps(ctx context.Context, db pg.DB, caps []bc.SupplyCap) error {
+	if len(caps) == 0 {
+		return nil
+	}
+	assetIDs := make(pq.ByteaArray, 0, len(caps))
+	for _, c := range caps {
+		assetID := c.AssetID
+		assetIDs = append(assetIDs, assetID[:])
+	}
+	const q = `SELECT asset_id, amount FROM signer_supply_cap_approvals WHERE asset_id = ANY($1)`
+	approved := make(map[bc.AssetID]uint64, len(caps))
+	err := pg.ForQueryRows(ctx, db, q, assetIDs, func(assetID bc.AssetID, amount uint64) {
+		approved[assetID] = amount
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading supply cap approvals")
+	}
+	for _, c := range caps {
+		amount, ok := approved[c.AssetID]
+		if !ok || amount != c.Amount {
+			return errors.WithDetailf(ErrUnapprovedSupplyCap, "cap of %d on asset %s", c.Amount, c.AssetID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/query"
+	"chain/errors"
+)
+
+// POST /list-asset-circulation
+func (h *Handler) listAssetCirculation(ctx context.Context, x struct {
+	AssetIDs     []string `json:"asset_ids"`
+	AssetAliases []string `json:"asset_aliases"`
+	BlockHeight  uint64   `json:"block_height"`
+}) (interface{}, error) {
+	assetIDs := x.AssetIDs
+	for _, alias := range x.AssetAliases {
+		a, err := h.findAssetByAlias(ctx, alias)
+		if err != nil {
+			return nil, errors.Wrapf(err, "looking up asset %s", alias)
+		}
+		assetIDs = append(assetIDs, a.AssetID.String())
+	}
+
+	circs, height, err := h.Indexer.AssetCirculation(ctx, assetIDs, x.BlockHeight)
+	if err != nil {
+		return nil, err
+	}
+	if circs == nil {
+		circs = []*query.Circulation{}
+	}
+	return struct {
+		BlockHeight uint64               `json:"block_height"`
+		Assets      []*query.Circulation `json:"assets"`
+	}{height, circs}, nil
+}
@@ -22,6 +22,7 @@ import (
 	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 )
 
 const (
@@ -35,6 +36,10 @@ var (
 	ErrBadQuorum       = errors.New("quorum must be greater than 0 if there are signers")
 
 	Version, BuildCommit, BuildDate string
+
+	// BuildFlags and VendorHash are set by release builds.
+	// BinaryHash is the SHA3-256 hash of the running binary.
+	BuildFlags, VendorHash, BinaryHash string
 )
 
 // Config encapsulates Core-level, persistent configuration options.
@@ -50,6 +55,11 @@ type Config struct {
 	Signers              []BlockSigner `json:"block_signer_urls"`
 	Quorum               int
 	MaxIssuanceWindow    chainjson.Duration
+
+	// SupplyCaps are committed in the initial block of a new
+	// blockchain. They aren't stored with the configuration;
+	// the blockchain records them.
+	SupplyCaps []bc.SupplyCap `json:"supply_caps"`
 }
 
 type BlockSigner struct {
@@ -173,6 +183,12 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 		if err != nil {
 			return err
 		}
+		block.SupplyCaps = c.SupplyCaps
+		snapshot := state.Empty()
+		err = validation.ApplySupplyCaps(snapshot, block.SupplyCaps)
+		if err != nil {
+			return err
+		}
 
 		initialBlockHash := block.Hash()
 
@@ -182,7 +198,7 @@ func Configure(ctx context.Context, db pg.DB, c *Config) error {
 			return err
 		}
 
-		err = chain.CommitBlock(ctx, block, state.Empty())
+		err = chain.CommitBlock(ctx, block, snapshot)
 		if err != nil {
 			return err
 		}
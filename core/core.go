@@ -113,6 +113,9 @@ func (h *Handler) leaderInfo(ctx context.Context) (map[string]interface{}, error
 		"version":                           config.Version,
 		"build_commit":                      config.BuildCommit,
 		"build_date":                        config.BuildDate,
+		"build_flags":                       config.BuildFlags,
+		"vendor_hash":                       config.VendorHash,
+		"binary_hash":                       config.BinaryHash,
 		"health":                            h.health(),
 	}
 
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+
+	chainjson "chain/encoding/json"
+	"chain/net/http/httpjson"
+)
+
+// POST /set-counterparty-label
+func (h *Handler) setCounterpartyLabel(ctx context.Context, x struct {
+	Program chainjson.HexBytes `json:"program"`
+	Label   string             `json:"label"`
+}) error {
+	return h.Counterparties.Set(ctx, x.Program, x.Label)
+}
+
+// POST /list-counterparty-labels
+func (h *Handler) listCounterpartyLabels(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	labels, next, err := h.Counterparties.List(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(labels),
+		LastPage: len(labels) < limit,
+		Next:     outQuery,
+	}, nil
+}
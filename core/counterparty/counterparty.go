@@ -0,0 +1,153 @@
+// Package counterparty maintains human-readable labels for
+// control programs and issuance programs belonging to known
+// external parties, and annotates transactions with them.
+package counterparty
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+)
+
+var (
+	// ErrBadProgram is returned when Set is called with an empty program.
+	ErrBadProgram = errors.New("invalid program")
+	// ErrBadLabel is returned when Set is called with a label
+	// that is too long.
+	ErrBadLabel = errors.New("invalid label")
+)
+
+const maxLabelLen = 256
+
+// Label associates a program with the name of the party it belongs to.
+type Label struct {
+	Program chainjson.HexBytes `json:"program"`
+	Label   string             `json:"label"`
+}
+
+// Registry stores counterparty labels.
+type Registry struct {
+	DB pg.DB
+}
+
+// Set labels program. An empty label removes
+// any existing label for program.
+func (r *Registry) Set(ctx context.Context, program []byte, label string) error {
+	if len(program) == 0 {
+		return errors.WithDetail(ErrBadProgram, "program must not be empty")
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		const q = `DELETE FROM counterparty_labels WHERE program = $1`
+		_, err := r.DB.Exec(ctx, q, program)
+		return errors.Wrap(err, "deleting counterparty label")
+	}
+	if len(label) > maxLabelLen {
+		return errors.WithDetailf(ErrBadLabel, "label must be at most %d bytes", maxLabelLen)
+	}
+
+	const q = `
+		INSERT INTO counterparty_labels (program, label) VALUES ($1, $2)
+		ON CONFLICT (program) DO UPDATE SET label = excluded.label
+	`
+	_, err := r.DB.Exec(ctx, q, program, label)
+	return errors.Wrap(err, "saving counterparty label")
+}
+
+// List returns up to limit labels, ordered by program,
+// starting after the program with the hex encoding after.
+// It also returns the value of after for the next page.
+func (r *Registry) List(ctx context.Context, after string, limit int) ([]*Label, string, error) {
+	afterProg := []byte{}
+	if after != "" {
+		var err error
+		afterProg, err = hex.DecodeString(after)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "decoding after")
+		}
+	}
+
+	const q = `
+		SELECT program, label FROM counterparty_labels
+		WHERE program > $1 ORDER BY program LIMIT $2
+	`
+	var labels []*Label
+	err := pg.ForQueryRows(ctx, r.DB, q, afterProg, limit, func(program []byte, label string) {
+		labels = append(labels, &Label{Program: program, Label: label})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing counterparty labels")
+	}
+	if len(labels) > 0 {
+		after = hex.EncodeToString(labels[len(labels)-1].Program)
+	}
+	return labels, after, nil
+}
+
+// AnnotateTxs adds the label of the counterparty to each input
+// and output whose control program or issuance program has one.
+// Inputs and outputs belonging to local accounts aren't labeled,
+// so it must run after the account annotator.
+func (r *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
+	items := make(map[string][]map[string]interface{})
+	var programs pq.ByteaArray
+
+	add := func(s interface{}, fields ...string) {
+		asSlice, ok := s.([]interface{})
+		if !ok {
+			log.Error(ctx, errors.Wrap(fmt.Errorf("expected slice, got %T", s)))
+			return
+		}
+		for _, m := range asSlice {
+			asMap, ok := m.(map[string]interface{})
+			if !ok {
+				log.Error(ctx, errors.Wrap(fmt.Errorf("expected map, got %T", m)))
+				continue
+			}
+			if _, ok := asMap["account_id"]; ok {
+				continue
+			}
+			for _, f := range fields {
+				progHex, ok := asMap[f].(string)
+				if !ok {
+					continue
+				}
+				prog, err := hex.DecodeString(progHex)
+				if err != nil {
+					log.Error(ctx, errors.Wrap(err, "could not decode ", f))
+					continue
+				}
+				if _, ok := items[string(prog)]; !ok {
+					programs = append(programs, prog)
+				}
+				items[string(prog)] = append(items[string(prog)], asMap)
+			}
+		}
+	}
+	for _, tx := range txs {
+		add(tx["inputs"], "control_program", "issuance_program")
+		add(tx["outputs"], "control_program")
+	}
+	if len(programs) == 0 {
+		return nil
+	}
+
+	const q = `
+		SELECT program, label FROM counterparty_labels
+		WHERE program IN (SELECT unnest($1::bytea[]))
+	`
+	err := pg.ForQueryRows(ctx, r.DB, q, programs, func(program []byte, label string) {
+		for _, m := range items[string(program)] {
+			m["counterparty"] = label
+		}
+	})
+	return errors.Wrap(err, "annotating with counterparty labels")
+}
@@ -0,0 +1,81 @@
+package counterparty
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestAnnotateTxs(t *testing.T) {
+	ctx := context.Background()
+	r := &Registry{DB: pgtest.NewTx(t)}
+
+	err := r.Set(ctx, []byte{0x51}, "Acme Bank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Set(ctx, []byte{0x52}, "Globex")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs := []map[string]interface{}{{
+		"inputs": []interface{}{
+			map[string]interface{}{"issuance_program": "52"},
+		},
+		"outputs": []interface{}{
+			map[string]interface{}{"control_program": "51"},
+			map[string]interface{}{"control_program": "51", "account_id": "acc1"},
+			map[string]interface{}{"control_program": "53"},
+		},
+	}}
+	err = r.AnnotateTxs(ctx, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []map[string]interface{}{{
+		"inputs": []interface{}{
+			map[string]interface{}{"issuance_program": "52", "counterparty": "Globex"},
+		},
+		"outputs": []interface{}{
+			map[string]interface{}{"control_program": "51", "counterparty": "Acme Bank"},
+			map[string]interface{}{"control_program": "51", "account_id": "acc1"},
+			map[string]interface{}{"control_program": "53"},
+		},
+	}}
+	if !reflect.DeepEqual(txs, want) {
+		t.Errorf("AnnotateTxs = %v want %v", txs, want)
+	}
+
+	// An empty label removes the label.
+	err = r.Set(ctx, []byte{0x52}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels, _, err := r.List(ctx, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 1 || labels[0].Label != "Acme Bank" {
+		t.Errorf("List = %v want only Acme Bank", labels)
+	}
+}
+
+func TestSetErrors(t *testing.T) {
+	ctx := context.Background()
+	r := new(Registry) // validation fails before any database call
+
+	err := r.Set(ctx, nil, "Acme Bank")
+	if errors.Root(err) != ErrBadProgram {
+		t.Errorf("Set(empty program) = %v want %v", err, ErrBadProgram)
+	}
+	err = r.Set(ctx, []byte{0x51}, strings.Repeat("x", maxLabelLen+1))
+	if errors.Root(err) != ErrBadLabel {
+		t.Errorf("Set(long label) = %v want %v", err, ErrBadLabel)
+	}
+}
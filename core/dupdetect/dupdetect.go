@@ -0,0 +1,323 @@
+// Package dupdetect flags incoming payments that look like
+// duplicates of earlier ones, so they can be reviewed and,
+// if need be, refunded.
+//
+// A payment is an output controlled by a local account in a
+// transaction that spends nothing from that account. It is a
+// suspected duplicate of an earlier payment of the same asset
+// to the same account, within a window of time, if their
+// amounts are equal or their reference data is identical.
+// The reference data of a payment is its output's reference
+// data, or if that is empty, its transaction's.
+package dupdetect
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/webhook"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+// PinName is used to identify the pin associated with
+// the duplicate payment block processor.
+const PinName = "duplicate-payments"
+
+// maxMatches bounds the earlier payments recorded
+// for any one suspected duplicate.
+const maxMatches = 10
+
+// Reasons a payment is suspected to be a duplicate.
+const (
+	ReasonAmount        = "amount"
+	ReasonReferenceData = "reference_data"
+)
+
+// EventDuplicatePayment is the type of the webhook event
+// sent for each suspected duplicate.
+const EventDuplicatePayment = "duplicate_payment"
+
+// Duplicate is a payment suspected to duplicate
+// an earlier one.
+type Duplicate struct {
+	ID                    string     `json:"id"`
+	TransactionID         bc.Hash    `json:"transaction_id"`
+	Position              uint32     `json:"position"`
+	AccountID             string     `json:"account_id"`
+	AssetID               bc.AssetID `json:"asset_id"`
+	Amount                uint64     `json:"amount"`
+	OriginalTransactionID bc.Hash    `json:"original_transaction_id"`
+	OriginalPosition      uint32     `json:"original_position"`
+	Reasons               []string   `json:"reasons"`
+	Timestamp             time.Time  `json:"timestamp"`
+}
+
+// Event is the body of a webhook request.
+type Event struct {
+	Type      string     `json:"type"`
+	Duplicate *Duplicate `json:"duplicate"`
+}
+
+// Detector finds suspected duplicate payments in each
+// block, after the block's transactions are indexed.
+type Detector struct {
+	DB       pg.DB
+	Chain    *protocol.Chain
+	PinStore *pin.Store
+
+	// Window is how far back to look for an earlier payment.
+	Window time.Duration
+
+	// WebhookURL, if set, receives a POST request
+	// with an Event for each suspected duplicate.
+	WebhookURL string
+	Client     *http.Client
+}
+
+// ProcessBlocks checks each new block for duplicate payments.
+func (d *Detector) ProcessBlocks(ctx context.Context) {
+	if d.PinStore == nil {
+		return
+	}
+	d.PinStore.ProcessBlocks(ctx, d.Chain, PinName, d.detectBlock)
+}
+
+// paymentTx holds the fields of an annotated
+// transaction used to detect duplicates.
+type paymentTx struct {
+	ID            bc.Hash         `json:"id"`
+	Position      uint32          `json:"position"`
+	ReferenceData json.RawMessage `json:"reference_data"`
+	Inputs        []struct {
+		AccountID string `json:"account_id"`
+	} `json:"inputs"`
+	Outputs []*paymentOutput `json:"outputs"`
+}
+
+type paymentOutput struct {
+	Position      uint32          `json:"position"`
+	AssetID       bc.AssetID      `json:"asset_id"`
+	Amount        uint64          `json:"amount"`
+	AccountID     string          `json:"account_id"`
+	ReferenceData json.RawMessage `json:"reference_data"`
+}
+
+func (d *Detector) detectBlock(ctx context.Context, b *bc.Block) error {
+	<-d.PinStore.PinWaiter(query.TxPinName, b.Height)
+
+	const q = `SELECT data FROM annotated_txs WHERE block_height = $1 ORDER BY tx_pos`
+	var txs []*paymentTx
+	err := pg.ForQueryRows(ctx, d.DB, q, b.Height, func(data []byte) error {
+		tx := new(paymentTx)
+		err := json.Unmarshal(data, tx)
+		if err != nil {
+			return errors.Wrap(err, "decoding annotated transaction")
+		}
+		txs = append(txs, tx)
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "querying annotated transactions")
+	}
+
+	for _, tx := range txs {
+		for _, out := range payments(tx) {
+			err = d.detect(ctx, b, tx, out)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// payments returns the outputs of tx that pay an account
+// tx spends nothing from.
+func payments(tx *paymentTx) []*paymentOutput {
+	spenders := make(map[string]bool)
+	for _, in := range tx.Inputs {
+		if in.AccountID != "" {
+			spenders[in.AccountID] = true
+		}
+	}
+	var outs []*paymentOutput
+	for _, out := range tx.Outputs {
+		if out.AccountID != "" && !spenders[out.AccountID] {
+			outs = append(outs, out)
+		}
+	}
+	return outs
+}
+
+// paymentRef returns the reference data that identifies
+// a payment, or nil if it has none.
+func paymentRef(tx *paymentTx, out *paymentOutput) []byte {
+	for _, ref := range []json.RawMessage{out.ReferenceData, tx.ReferenceData} {
+		var m map[string]interface{}
+		if json.Unmarshal(ref, &m) == nil && len(m) > 0 {
+			return ref
+		}
+	}
+	return nil
+}
+
+func (d *Detector) detect(ctx context.Context, b *bc.Block, tx *paymentTx, out *paymentOutput) error {
+	const q = `
+		SELECT o.tx_hash, o.output_index, (o.data->>'amount')::numeric = $5,
+			COALESCE(CASE WHEN o.data->'reference_data' = '{}'::jsonb
+				THEN t.data->'reference_data' ELSE o.data->'reference_data' END = $6::jsonb, false)
+		FROM annotated_outputs o
+		JOIN annotated_txs t ON t.block_height = o.block_height AND t.tx_pos = o.tx_pos
+		WHERE o.data @> $1::jsonb AND lower(o.timespan) >= $2
+			AND (o.block_height, o.tx_pos) < ($3, $4)
+			AND NOT t.data @> $7::jsonb
+			AND ((o.data->>'amount')::numeric = $5 OR
+				CASE WHEN o.data->'reference_data' = '{}'::jsonb
+				THEN t.data->'reference_data' ELSE o.data->'reference_data' END = $6::jsonb)
+		ORDER BY o.block_height DESC, o.tx_pos DESC, o.output_index
+		LIMIT $8
+	`
+	match, err := json.Marshal(map[string]interface{}{"account_id": out.AccountID, "asset_id": out.AssetID})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	spender, err := json.Marshal(map[string]interface{}{"inputs": []interface{}{map[string]string{"account_id": out.AccountID}}})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	var ref interface{}
+	if r := paymentRef(tx, out); r != nil {
+		ref = string(r)
+	}
+	var since uint64
+	if w := uint64(d.Window / time.Millisecond); w < b.TimestampMS {
+		since = b.TimestampMS - w
+	}
+
+	var dups []*Duplicate
+	err = pg.ForQueryRows(ctx, d.DB, q, string(match), since, b.Height, tx.Position, out.Amount, ref, string(spender), maxMatches,
+		func(origHash bc.Hash, origIndex uint32, sameAmount, sameRef bool) {
+			dup := &Duplicate{
+				TransactionID:         tx.ID,
+				Position:              out.Position,
+				AccountID:             out.AccountID,
+				AssetID:               out.AssetID,
+				Amount:                out.Amount,
+				OriginalTransactionID: origHash,
+				OriginalPosition:      origIndex,
+				Timestamp:             b.Time(),
+			}
+			if sameAmount {
+				dup.Reasons = append(dup.Reasons, ReasonAmount)
+			}
+			if sameRef {
+				dup.Reasons = append(dup.Reasons, ReasonReferenceData)
+			}
+			dups = append(dups, dup)
+		})
+	if err != nil {
+		return errors.Wrap(err, "finding duplicate payments")
+	}
+
+	for _, dup := range dups {
+		inserted, err := d.insert(ctx, b.Height, dup)
+		if err != nil {
+			return err
+		}
+		// A block processed again doesn't notify again.
+		if inserted && d.WebhookURL != "" {
+			go d.notify(ctx, dup)
+		}
+	}
+	return nil
+}
+
+func (d *Detector) insert(ctx context.Context, height uint64, dup *Duplicate) (bool, error) {
+	const q = `
+		INSERT INTO duplicate_payments (tx_hash, output_index, original_tx_hash, original_output_index,
+			account_id, asset_id, amount, reasons, block_height, timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (tx_hash, output_index, original_tx_hash, original_output_index) DO NOTHING
+		RETURNING id
+	`
+	var id int64
+	err := d.DB.QueryRow(ctx, q, dup.TransactionID, dup.Position, dup.OriginalTransactionID, dup.OriginalPosition,
+		dup.AccountID, dup.AssetID, dup.Amount, pq.StringArray(dup.Reasons), height, dup.Timestamp).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "saving duplicate payment")
+	}
+	dup.ID = strconv.FormatInt(id, 10)
+	return true, nil
+}
+
+// notify sends a webhook event for dup.
+// Events that can't be delivered are logged; the
+// duplicate can still be found with List.
+func (d *Detector) notify(ctx context.Context, dup *Duplicate) {
+	s := &webhook.Sender{URL: d.WebhookURL, Client: d.Client}
+	err := s.Send(ctx, &Event{Type: EventDuplicatePayment, Duplicate: dup})
+	if err != nil {
+		log.Error(ctx, err, "at", "sending duplicate payment webhook", "id", dup.ID)
+	}
+}
+
+// List returns up to limit suspected duplicates, oldest first,
+// starting after the one with the given ID.
+// It also returns the value of after for the next page.
+func (d *Detector) List(ctx context.Context, after string, limit int) ([]*Duplicate, string, error) {
+	var afterID int64
+	if after != "" {
+		var err error
+		afterID, err = strconv.ParseInt(after, 10, 64)
+		if err != nil {
+			return nil, "", errors.WithDetailf(query.ErrBadAfter, "value: %q", after)
+		}
+	}
+
+	const q = `
+		SELECT id, tx_hash, output_index, account_id, asset_id, amount,
+			original_tx_hash, original_output_index, reasons, timestamp
+		FROM duplicate_payments
+		WHERE id > $1 ORDER BY id LIMIT $2
+	`
+	var dups []*Duplicate
+	err := pg.ForQueryRows(ctx, d.DB, q, afterID, limit, func(
+		id int64, hash bc.Hash, index uint32, accountID string, assetID bc.AssetID, amount uint64,
+		origHash bc.Hash, origIndex uint32, reasons pq.StringArray, timestamp time.Time,
+	) {
+		dups = append(dups, &Duplicate{
+			ID:                    strconv.FormatInt(id, 10),
+			TransactionID:         hash,
+			Position:              index,
+			AccountID:             accountID,
+			AssetID:               assetID,
+			Amount:                amount,
+			OriginalTransactionID: origHash,
+			OriginalPosition:      origIndex,
+			Reasons:               reasons,
+			Timestamp:             timestamp,
+		})
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing duplicate payments")
+	}
+	if len(dups) > 0 {
+		after = dups[len(dups)-1].ID
+	}
+	return dups, after, nil
+}
@@ -0,0 +1,72 @@
+package dupdetect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPayments(t *testing.T) {
+	var tx paymentTx
+	err := json.Unmarshal([]byte(`{
+		"reference_data": {"invoice": "1001"},
+		"inputs": [{"account_id": "alice"}, {}],
+		"outputs": [
+			{"position": 0, "account_id": "bob", "reference_data": {}},
+			{"position": 1, "account_id": "alice", "reference_data": {}},
+			{"position": 2, "reference_data": {}},
+			{"position": 3, "account_id": "carol", "reference_data": {"memo": "x"}}
+		]
+	}`), &tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outs := payments(&tx)
+	var got []uint32
+	for _, out := range outs {
+		got = append(got, out.Position)
+	}
+	want := []uint32{0, 3} // alice's output is change
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("payments positions = %v want %v", got, want)
+	}
+
+	if ref := string(paymentRef(&tx, outs[0])); ref != `{"invoice": "1001"}` {
+		t.Errorf("paymentRef(bob) = %s want transaction reference data", ref)
+	}
+	if ref := string(paymentRef(&tx, outs[1])); ref != `{"memo": "x"}` {
+		t.Errorf("paymentRef(carol) = %s want output reference data", ref)
+	}
+
+	tx.ReferenceData = json.RawMessage(`{}`)
+	if ref := paymentRef(&tx, outs[0]); ref != nil {
+		t.Errorf("paymentRef(bob) = %s want nil", ref)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	got := make(chan *Event, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ev := new(Event)
+		err := json.NewDecoder(req.Body).Decode(ev)
+		if err != nil {
+			t.Error(err)
+		}
+		got <- ev
+	}))
+	defer ts.Close()
+
+	d := &Detector{WebhookURL: ts.URL}
+	dup := &Duplicate{ID: "1", AccountID: "bob", Amount: 100, Reasons: []string{ReasonAmount}}
+	d.notify(context.Background(), dup)
+
+	ev := <-got
+	want := &Event{Type: EventDuplicatePayment, Duplicate: dup}
+	if !reflect.DeepEqual(ev, want) {
+		t.Errorf("webhook event = %+v want %+v", ev, want)
+	}
+}
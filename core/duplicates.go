@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+var errDuplicatesDisabled = errors.New("duplicate payment detection is not enabled")
+
+// POST /list-duplicate-payments
+func (h *Handler) listDuplicatePayments(ctx context.Context, x requestQuery) (*page, error) {
+	if h.Duplicates == nil {
+		return nil, errDuplicatesDisabled
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	dups, next, err := h.Duplicates.List(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(dups),
+		LastPage: len(dups) < limit,
+		Next:     outQuery,
+	}, nil
+}
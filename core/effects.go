@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+
+	"chain/core/txbuilder"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// getTransactionEffects returns a human-readable statement of
+// what each transaction template does, naming assets and
+// accounts by their local aliases, with a hash committing to
+// the statement and the transaction.
+//
+// POST /get-transaction-effects
+func (h *Handler) getTransactionEffects(ctx context.Context, x struct {
+	Txs []*txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		if x.Txs[i] == nil || x.Txs[i].Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		return h.txEffects(ctx, x.Txs[i].Transaction)
+	})
+}
+
+// inspectTemplates describes each transaction template for
+// a counterparty to audit before signing: the inputs it
+// spends and the outputs it creates, annotated with the
+// assets and accounts this core knows, and the signatures
+// it still needs.
+//
+// POST /inspect-transaction-templates
+func (h *Handler) inspectTemplates(ctx context.Context, x struct {
+	Txs []*txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := x.Txs[i]
+		if tpl == nil || tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		annotated, err := h.Indexer.AnnotatedTx(ctx, bc.NewTx(*tpl.Transaction))
+		if err != nil {
+			return nil, errors.Wrap(err, "annotating transaction")
+		}
+		pending := txbuilder.PendingSignatures(tpl)
+		if pending == nil {
+			pending = []txbuilder.PendingSignature{}
+		}
+		return map[string]interface{}{
+			"transaction_id":           annotated["id"],
+			"inputs":                   annotated["inputs"],
+			"outputs":                  annotated["outputs"],
+			"reference_data":           annotated["reference_data"],
+			"allow_additional_actions": tpl.AllowAdditional,
+			"pending_signatures":       pending,
+			"fully_signed":             len(pending) == 0,
+		}, nil
+	})
+}
+
+// txEffects renders the effects of tx, with names
+// from the annotations of its assets and accounts.
+func (h *Handler) txEffects(ctx context.Context, tx *bc.TxData) (*txbuilder.Effects, error) {
+	annotated, err := h.Indexer.AnnotatedTx(ctx, bc.NewTx(*tx))
+	if err != nil {
+		return nil, errors.Wrap(err, "annotating transaction")
+	}
+	names := &txbuilder.Names{
+		Assets:   make(map[bc.AssetID]string),
+		Accounts: make(map[string]string),
+	}
+	for _, field := range []string{"inputs", "outputs"} {
+		items, _ := annotated[field].([]interface{})
+		for _, item := range items {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var assetID bc.AssetID
+			s, _ := m["asset_id"].(string)
+			alias, _ := m["asset_alias"].(string)
+			if alias != "" && assetID.UnmarshalText([]byte(s)) == nil {
+				names.Assets[assetID] = alias
+			}
+
+			account, _ := m["account_alias"].(string)
+			if account == "" {
+				account, _ = m["account_id"].(string)
+			}
+			s, _ = m["control_program"].(string)
+			prog, err := hex.DecodeString(s)
+			if account != "" && err == nil {
+				names.Accounts[string(prog)] = account
+			}
+		}
+	}
+	return txbuilder.NewEffects(tx, names), nil
+}
+
+// signEffects adds signatures of the effects of tpl by the
+// root keys of xpubs held by the mock HSM.
+func (h *Handler) signEffects(ctx context.Context, tpl *txbuilder.Template, xpubs []chainkd.XPub) error {
+	effects, err := h.txEffects(ctx, tpl.Transaction)
+	if err != nil {
+		return err
+	}
+	for _, xpub := range xpubs {
+		sig, err := h.mockhsmSignTemplate(ctx, xpub, nil, [32]byte(effects.Hash))
+		if err != nil {
+			return errors.Wrap(err, "signing transaction effects")
+		}
+		if sig == nil {
+			continue
+		}
+		effects.Signatures = append(effects.Signatures, txbuilder.EffectsSignature{XPub: xpub, Signature: sig})
+	}
+	tpl.Effects = effects
+	return nil
+}
@@ -2,23 +2,34 @@ package core
 
 import (
 	"context"
+	"sort"
 
 	"chain/core/accesstoken"
 	"chain/core/account"
+	"chain/core/approval"
 	"chain/core/asset"
+	"chain/core/balancehook"
 	"chain/core/blocksigner"
 	"chain/core/config"
+	"chain/core/counterparty"
+	"chain/core/generator"
 	"chain/core/mockhsm"
+	"chain/core/msgrelay"
+	"chain/core/progtemplate"
 	"chain/core/query"
 	"chain/core/query/filter"
+	"chain/core/quota"
 	"chain/core/rpc"
 	"chain/core/signers"
+	"chain/core/swap"
 	"chain/core/txbuilder"
 	"chain/core/txfeed"
+	"chain/core/watch"
 	"chain/database/pg"
 	"chain/errors"
 	"chain/net/http/httpjson"
 	"chain/protocol"
+	"chain/protocol/validation"
 )
 
 // errorInfo contains a set of error codes to send to the user.
@@ -33,6 +44,7 @@ type detailedError struct {
 	Detail    string                 `json:"detail,omitempty"`
 	Data      map[string]interface{} `json:"data,omitempty"`
 	Temporary bool                   `json:"temporary"`
+	Retriable bool                   `json:"retriable"`
 }
 
 func isTemporary(info errorInfo, err error) bool {
@@ -55,7 +67,48 @@ func isTemporary(info errorInfo, err error) bool {
 	}
 }
 
+// isRetriable reports whether a client may retry the request
+// that produced err, as is, and reasonably expect a different result.
+// It is a superset of isTemporary: it also covers errors, such as
+// rate limiting, where the request was never attempted.
+func isRetriable(info errorInfo, err error) bool {
+	if info.ChainCode == "CH706" { // 1 or more action errors
+		errs := errors.Data(err)["actions"].([]detailedError)
+		for _, actionErr := range errs {
+			if !actionErr.Retriable {
+				return false
+			}
+		}
+		return true
+	}
+	return retriableCodes[info.ChainCode]
+}
+
 var (
+	// retriableCodes lists the error codes for which
+	// a request may be retried unchanged.
+	retriableCodes = map[string]bool{
+		"CH000": true, // internal server error
+		"CH001": true, // request timed out
+		"CH007": true, // request limit exceeded
+		"CH008": true, // electing a new leader
+		"CH011": true, // batch aborted
+		"CH014": true, // replica too stale
+		"CH015": true, // submission interrupted
+		"CH016": true, // no blocks yet
+		"CH761": true, // outputs currently reserved
+	}
+
+	// errorDataFields lists the keys that may appear in
+	// the data object of errors with the given code.
+	errorDataFields = map[string][]string{
+		"CH001": {"status"},
+		"CH010": {"missing_fields"},
+		"CH706": {"actions"},
+		"CH735": {validation.KeyReason, errors.KeyInputIndex, validation.KeyOutpoint},
+		"CH902": {asset.KeyFields},
+	}
+
 	// infoInternal holds the codes we use for an internal error.
 	// It is defined here for easy reference.
 	infoInternal = errorInfo{500, "CH000", "Chain API Error"}
@@ -74,24 +127,41 @@ var (
 		errLeaderElection:            errorInfo{503, "CH008", "Electing a new leader for the core; try again soon"},
 		errNotAuthenticated:          errorInfo{401, "CH009", "Request could not be authenticated"},
 		txbuilder.ErrMissingFields:   errorInfo{400, "CH010", "One or more fields are missing"},
+		errBatchAborted:              errorInfo{400, "CH011", "Not processed because an earlier item in the batch failed"},
+		errReplayedRequest:           errorInfo{401, "CH012", "Request was replayed or is outside the replay window"},
+		errReplicaReadOnly:           errorInfo{400, "CH013", "Request is not served by query replicas"},
+		errReplicaStale:              errorInfo{503, "CH014", "Query replica is too far behind the leader; try again soon"},
+		errSubmissionInterrupted:     errorInfo{503, "CH015", "Submission was interrupted; submit the transaction again"},
+		errNoBlocks:                  errorInfo{400, "CH016", "Core has no blocks yet; try again once it has fetched the initial block"},
 		asset.ErrDuplicateAlias:      errorInfo{400, "CH050", "Alias already exists"},
 		account.ErrDuplicateAlias:    errorInfo{400, "CH050", "Alias already exists"},
 		txfeed.ErrDuplicateAlias:     errorInfo{400, "CH050", "Alias already exists"},
 		mockhsm.ErrDuplicateKeyAlias: errorInfo{400, "CH050", "Alias already exists"},
+		watch.ErrDuplicateAlias:      errorInfo{400, "CH050", "Alias already exists"},
 
 		// Core error namespace
-		errUnconfigured:                errorInfo{400, "CH100", "This core still needs to be configured"},
-		errAlreadyConfigured:           errorInfo{400, "CH101", "This core has already been configured"},
-		config.ErrBadGenerator:         errorInfo{400, "CH102", "Generator URL returned an invalid response"},
-		errBadBlockPub:                 errorInfo{400, "CH103", "Provided Block XPub is invalid"},
-		rpc.ErrWrongNetwork:            errorInfo{502, "CH104", "A peer core is operating on a different blockchain network"},
-		protocol.ErrTheDistantFuture:   errorInfo{400, "CH105", "Requested height is too far ahead"},
-		config.ErrBadSignerURL:         errorInfo{400, "CH106", "Block signer URL is invalid"},
-		config.ErrBadSignerPubkey:      errorInfo{400, "CH107", "Block signer pubkey is invalid"},
-		config.ErrBadQuorum:            errorInfo{400, "CH108", "Quorum must be greater than 0 if there are signers"},
-		errProdReset:                   errorInfo{400, "CH110", "Reset can only be called in a development system"},
-		errNoClientTokens:              errorInfo{400, "CH120", "Cannot enable client authentication with no client tokens"},
-		blocksigner.ErrConsensusChange: errorInfo{400, "CH150", "Refuse to sign block with consensus change"},
+		errUnconfigured:                    errorInfo{400, "CH100", "This core still needs to be configured"},
+		errAlreadyConfigured:               errorInfo{400, "CH101", "This core has already been configured"},
+		config.ErrBadGenerator:             errorInfo{400, "CH102", "Generator URL returned an invalid response"},
+		errBadBlockPub:                     errorInfo{400, "CH103", "Provided Block XPub is invalid"},
+		rpc.ErrWrongNetwork:                errorInfo{502, "CH104", "A peer core is operating on a different blockchain network"},
+		protocol.ErrTheDistantFuture:       errorInfo{400, "CH105", "Requested height is too far ahead"},
+		config.ErrBadSignerURL:             errorInfo{400, "CH106", "Block signer URL is invalid"},
+		config.ErrBadSignerPubkey:          errorInfo{400, "CH107", "Block signer pubkey is invalid"},
+		config.ErrBadQuorum:                errorInfo{400, "CH108", "Quorum must be greater than 0 if there are signers"},
+		errProdReset:                       errorInfo{400, "CH110", "Reset can only be called in a development system"},
+		errNoClientTokens:                  errorInfo{400, "CH120", "Cannot enable client authentication with no client tokens"},
+		errDuplicatesDisabled:              errorInfo{400, "CH130", "Duplicate payment detection is not enabled on this core"},
+		errWatchesDisabled:                 errorInfo{400, "CH131", "Watch descriptors require transaction indexing on this core"},
+		errNotSigner:                       errorInfo{400, "CH132", "Attestations are only available from block signers"},
+		errNotGenerator:                    errorInfo{400, "CH133", "Request is only available on the block generator"},
+		errBalanceHooksDisabled:            errorInfo{400, "CH134", "Balance webhooks require transaction indexing on this core"},
+		errNotBlockSigner:                  errorInfo{400, "CH135", "Supply cap approvals are only available on block signers"},
+		errFeedSinksDisabled:               errorInfo{400, "CH136", "Transaction feed sinks require transaction indexing on this core"},
+		blocksigner.ErrConsensusChange:     errorInfo{400, "CH150", "Refuse to sign block with consensus change"},
+		generator.ErrBadOrdering:           errorInfo{400, "CH151", "Invalid pending pool ordering"},
+		validation.ErrBadSupplyCaps:        errorInfo{400, "CH152", "Invalid supply caps"},
+		blocksigner.ErrUnapprovedSupplyCap: errorInfo{400, "CH153", "Refuse to sign block with unapproved supply cap"},
 
 		// Signers error namespace (2xx)
 		signers.ErrBadQuorum: errorInfo{400, "CH200", "Quorum must be greater than 1 and less than or equal to the length of xpubs"},
@@ -101,44 +171,132 @@ var (
 		signers.ErrDupeXPub:  errorInfo{400, "CH204", "Root XPubs cannot contain the same key more than once"},
 
 		// Access token error namespace (3xx)
-		accesstoken.ErrBadID:       errorInfo{400, "CH300", "Malformed or empty access token id"},
-		accesstoken.ErrBadType:     errorInfo{400, "CH301", "Access tokens must be type client or network"},
-		accesstoken.ErrDuplicateID: errorInfo{400, "CH302", "Access token id is already in use"},
-		errCurrentToken:            errorInfo{400, "CH310", "The access token used to authenticate this request cannot be deleted"},
+		accesstoken.ErrBadID:        errorInfo{400, "CH300", "Malformed or empty access token id"},
+		accesstoken.ErrBadType:      errorInfo{400, "CH301", "Access tokens must be type client, network, explorer or admin"},
+		accesstoken.ErrDuplicateID:  errorInfo{400, "CH302", "Access token id is already in use"},
+		accesstoken.ErrBadExpiry:    errorInfo{400, "CH303", "Access token expiration is invalid"},
+		accesstoken.ErrBadNamespace: errorInfo{400, "CH304", "Access token namespace is invalid"},
+		errCurrentToken:             errorInfo{400, "CH310", "The access token used to authenticate this request cannot be deleted"},
+		errNotAdmin:                 errorInfo{403, "CH311", "This request requires an admin access token"},
+		errNamespacedToken:          errorInfo{403, "CH312", "Access tokens bound to a namespace can't manage access tokens"},
+
+		// Counterparty label error namespace (4xx)
+		counterparty.ErrBadProgram: errorInfo{400, "CH400", "Counterparty program must not be empty"},
+		counterparty.ErrBadLabel:   errorInfo{400, "CH401", "Counterparty label is too long"},
+
+		// Program template error namespace (41x)
+		progtemplate.ErrBadTemplate:  errorInfo{400, "CH410", "Invalid program template"},
+		progtemplate.ErrBadArguments: errorInfo{400, "CH411", "Arguments don't match the program template's parameters"},
+
+		// Watch descriptor error namespace (5xx)
+		watch.ErrBadPath: errorInfo{400, "CH500", "Invalid derivation path template"},
+
+		// Balance webhook error namespace (55x)
+		balancehook.ErrBadTrigger: errorInfo{400, "CH550", "Invalid balance webhook trigger"},
+		balancehook.ErrNoFilter:   errorInfo{400, "CH551", "Balance webhook needs an account or asset"},
+		balancehook.ErrBadURL:     errorInfo{400, "CH552", "Invalid balance webhook URL"},
+		balancehook.ErrBadRule:    errorInfo{400, "CH553", "Invalid balance alert rule"},
 
 		// Query error namespace (6xx)
 		query.ErrBadAfter:               errorInfo{400, "CH600", "Malformed pagination parameter `after`"},
 		query.ErrParameterCountMismatch: errorInfo{400, "CH601", "Incorrect number of parameters to filter"},
 		filter.ErrBadFilter:             errorInfo{400, "CH602", "Malformed query filter"},
+		query.ErrBadPeriod:              errorInfo{400, "CH603", "Invalid statement period"},
+		query.ErrUnreconciled:           errorInfo{500, "CH604", "Account statement does not reconcile with account balances"},
+		query.ErrNoRatesProvider:        errorInfo{400, "CH605", "Display asset conversion is not enabled on this core"},
+		query.ErrBadBlockHeight:         errorInfo{400, "CH606", "Requested block height has not been indexed yet"},
+		query.ErrBadAnonymization:       errorInfo{400, "CH607", "Invalid analytics anonymization"},
+		txfeed.ErrBadSink:               errorInfo{400, "CH608", "Invalid transaction feed sink"},
 
 		// Transaction error namespace (7xx)
 		// Build error namespace (70x)
-		txbuilder.ErrBadRefData: errorInfo{400, "CH700", "Reference data does not match previous transaction's reference data"},
-		errBadActionType:        errorInfo{400, "CH701", "Invalid action type"},
-		errBadAlias:             errorInfo{400, "CH702", "Invalid alias on action"},
-		errBadAction:            errorInfo{400, "CH703", "Invalid action object"},
-		txbuilder.ErrBadAmount:  errorInfo{400, "CH704", "Invalid asset amount"},
-		txbuilder.ErrBlankCheck: errorInfo{400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
-		txbuilder.ErrAction:     errorInfo{400, "CH706", "One or more actions had an error: see attached data"},
+		txbuilder.ErrBadRefData:  errorInfo{400, "CH700", "Reference data does not match previous transaction's reference data"},
+		errBadActionType:         errorInfo{400, "CH701", "Invalid action type"},
+		errBadAlias:              errorInfo{400, "CH702", "Invalid alias on action"},
+		errBadAction:             errorInfo{400, "CH703", "Invalid action object"},
+		txbuilder.ErrBadAmount:   errorInfo{400, "CH704", "Invalid asset amount"},
+		txbuilder.ErrBlankCheck:  errorInfo{400, "CH705", "Unsafe transaction: leaves assets to be taken without requiring payment"},
+		txbuilder.ErrAction:      errorInfo{400, "CH706", "One or more actions had an error: see attached data"},
+		txbuilder.ErrBadTimeLock: errorInfo{400, "CH707", "Invalid time lock"},
 
 		// Submit error namespace (73x)
 		txbuilder.ErrMissingRawTx:          errorInfo{400, "CH730", "Missing raw transaction"},
 		txbuilder.ErrBadInstructionCount:   errorInfo{400, "CH731", "Too many signing instructions in template for transaction"},
 		txbuilder.ErrBadTxInputIdx:         errorInfo{400, "CH732", "Invalid transaction input index"},
 		txbuilder.ErrBadWitnessComponent:   errorInfo{400, "CH733", "Invalid witness component"},
+		errClientTokenReused:               errorInfo{400, "CH734", "Client token was already used for a different transaction"},
 		txbuilder.ErrRejected:              errorInfo{400, "CH735", "Transaction rejected"},
 		txbuilder.ErrNoTxSighashCommitment: errorInfo{400, "CH736", "Transaction is not final, additional actions still allowed"},
+		txbuilder.ErrBadSignature:          errorInfo{400, "CH737", "Signature does not match the template"},
+		txbuilder.ErrBadTemplate:           errorInfo{400, "CH738", "Invalid binary transaction template"},
+		txbuilder.ErrTemplateMismatch:      errorInfo{400, "CH739", "Transaction templates do not match"},
+
+		// Issuance approval error namespace (74x)
+		approval.ErrPending:     errorInfo{400, "CH740", "Issuance is pending approval"},
+		approval.ErrNotApprover: errorInfo{400, "CH741", "Access token is not an approver for this issuance"},
+		approval.ErrBadPolicy:   errorInfo{400, "CH742", "Invalid issuance approval policy"},
+		errApprovalsDisabled:    errorInfo{400, "CH743", "Issuance approvals are not enabled on this core"},
+
+		// Build quota error namespace (75x)
+		quota.ErrExceeded: errorInfo{400, "CH750", "Daily build quota exceeded"},
+		quota.ErrBadQuota: errorInfo{400, "CH751", "Invalid build quota"},
+		errQuotasDisabled: errorInfo{400, "CH752", "Build quotas are not enabled on this core"},
 
 		// account action error namespace (76x)
-		account.ErrInsufficient: errorInfo{400, "CH760", "Insufficient funds for tx"},
-		account.ErrReserved:     errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrInsufficient:            errorInfo{400, "CH760", "Insufficient funds for tx"},
+		account.ErrReserved:                errorInfo{400, "CH761", "Some outputs are reserved; try again"},
+		account.ErrFrozen:                  errorInfo{400, "CH762", "Account is frozen"},
+		account.ErrNotFrozen:               errorInfo{400, "CH763", "Account is not frozen"},
+		account.ErrBadFreezeReason:         errorInfo{400, "CH764", "Invalid account freeze reason"},
+		account.ErrNoActor:                 errorInfo{400, "CH765", "An actor is required to freeze or unfreeze an account"},
+		account.ErrUpgraded:                errorInfo{400, "CH766", "Account already has a successor"},
+		account.ErrNotUpgraded:             errorInfo{400, "CH767", "Account has not been upgraded"},
+		account.ErrArchived:                errorInfo{400, "CH768", "Account is archived"},
+		account.ErrReservationsOutstanding: errorInfo{400, "CH769", "Account has outstanding reservations; try again or cancel them"},
+		account.ErrBadEpochPeriod:          errorInfo{400, "CH770", "Account epoch period must be a whole number of seconds"},
+		account.ErrSpendingLimit:           errorInfo{400, "CH771", "Account spending limit exceeded"},
+		account.ErrBadSpendingLimit:        errorInfo{400, "CH772", "Invalid account spending limit"},
+		account.ErrBadReceiverExpiry:       errorInfo{400, "CH773", "Receiver expiration must be in the future"},
+		account.ErrBadQuorumTiers:          errorInfo{400, "CH774", "Invalid account quorum tiers"},
+		account.ErrNotReserved:             errorInfo{400, "CH775", "Transaction outputs are no longer reserved; build it again"},
+		account.ErrBadPaymentURI:           errorInfo{400, "CH776", "Invalid payment URI"},
+
+		// Swap offer error namespace (78x)
+		swap.ErrBadOffer: errorInfo{400, "CH780", "Invalid swap offer"},
+		swap.ErrNotOpen:  errorInfo{400, "CH781", "Swap offer is not open"},
 
 		// Mock HSM error namespace (80x)
 		mockhsm.ErrInvalidAfter:         errorInfo{400, "CH801", "Invalid `after` in query"},
 		mockhsm.ErrTooManyAliasesToList: errorInfo{400, "CH802", "Too many aliases to list"},
+
+		// Asset definition reference error namespace (90x)
+		asset.ErrBadReference:   errorInfo{400, "CH900", "Invalid asset definition reference"},
+		asset.ErrArchived:       errorInfo{400, "CH901", "Asset is archived"},
+		asset.ErrBadDefinition:  errorInfo{400, "CH902", "Asset definition does not match schema"},
+		asset.ErrIssuanceCap:    errorInfo{400, "CH903", "Asset issuance cap exceeded"},
+		asset.ErrAliasNamespace: errorInfo{403, "CH904", "Asset alias is outside the access token's namespace"},
+		asset.ErrRotated:        errorInfo{400, "CH905", "Asset already has a successor"},
+		asset.ErrRemoteAsset:    errorInfo{400, "CH906", "Asset keys are not held by this core"},
+
+		// Message relay error namespace (91x)
+		msgrelay.ErrUnknownRecipient: errorInfo{400, "CH910", "Control program does not belong to an account on the receiving core"},
+		msgrelay.ErrBadMessage:       errorInfo{400, "CH911", "Invalid message"},
+		msgrelay.ErrDelivery:         errorInfo{502, "CH912", "Message could not be delivered to the receiving core"},
 	}
 )
 
+// SignBlockError returns err, an error from a call
+// to a remote signer's sign-block RPC, with the signer's
+// refusal of an unapproved supply cap as its root.
+// The block generator makes the block again without
+// its caps only when signers refuse it for that reason.
+func SignBlockError(err error) error {
+	if rpc.ErrorCode(err) == errorInfoTab[blocksigner.ErrUnapprovedSupplyCap].ChainCode {
+		return errors.Wrap(blocksigner.ErrUnapprovedSupplyCap, err)
+	}
+	return err
+}
+
 // errInfo returns the HTTP status code to use
 // and a suitable response body describing err
 // by consulting the global lookup table.
@@ -151,7 +309,7 @@ func errInfo(err error) (body detailedError, info errorInfo) {
 	defer func() {
 		if err := recover(); err != nil {
 			info = infoInternal
-			body = detailedError{infoInternal, "", nil, true}
+			body = detailedError{infoInternal, "", nil, true, true}
 		}
 	}()
 	info, ok := errorInfoTab[root]
@@ -162,12 +320,37 @@ func errInfo(err error) (body detailedError, info errorInfo) {
 	body = detailedError{
 		errorInfo: info,
 		Detail:    errors.Detail(err),
-		Data:      errors.Data(err),
+		Data:      errData(err),
 		Temporary: isTemporary(info, err),
+		Retriable: isRetriable(info, err),
 	}
 	return body, info
 }
 
+// errData returns the data items in err, with any error
+// values replaced by their messages, which encode
+// meaningfully as JSON.
+func errData(err error) map[string]interface{} {
+	data := errors.Data(err)
+	var copied map[string]interface{}
+	for k, v := range data {
+		if e, ok := v.(error); ok {
+			if copied == nil {
+				// Don't modify the map held by err.
+				copied = make(map[string]interface{}, len(data))
+				for k, v := range data {
+					copied[k] = v
+				}
+			}
+			copied[k] = e.Error()
+		}
+	}
+	if copied != nil {
+		return copied
+	}
+	return data
+}
+
 // errInfoBodyList calls errInfo for each element in errs
 // and returns the "body".
 func errInfoBodyList(errs []error) (a []detailedError) {
@@ -177,3 +360,41 @@ func errInfoBodyList(errs []error) (a []detailedError) {
 	}
 	return a
 }
+
+// An ErrorCode describes one of the error codes
+// the Chain Core API can return.
+type ErrorCode struct {
+	Code       string   `json:"code"`
+	HTTPStatus int      `json:"http_status"`
+	Message    string   `json:"message"`
+	Retriable  bool     `json:"retriable"`
+	DataFields []string `json:"data_fields,omitempty"`
+}
+
+// ErrorCatalog returns every error code the API can return,
+// ordered by code. Errors in an action list (code CH706)
+// are retriable only if each of the listed errors is.
+func ErrorCatalog() []ErrorCode {
+	infos := map[string]errorInfo{infoInternal.ChainCode: infoInternal}
+	for _, info := range errorInfoTab {
+		infos[info.ChainCode] = info
+	}
+	codes := make([]ErrorCode, 0, len(infos))
+	for code, info := range infos {
+		codes = append(codes, ErrorCode{
+			Code:       code,
+			HTTPStatus: info.HTTPStatus,
+			Message:    info.Message,
+			Retriable:  retriableCodes[code],
+			DataFields: errorDataFields[code],
+		})
+	}
+	sort.Sort(byCode(codes))
+	return codes
+}
+
+type byCode []ErrorCode
+
+func (a byCode) Len() int           { return len(a) }
+func (a byCode) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCode) Less(i, j int) bool { return a[i].Code < a[j].Code }
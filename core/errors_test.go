@@ -1,9 +1,14 @@
 package core
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
 	"testing"
 
+	"chain/core/txbuilder"
 	"chain/database/pg"
 	"chain/errors"
 )
@@ -34,3 +39,53 @@ func TestErrInfo(t *testing.T) {
 type sliceError []int
 
 func (err sliceError) Error() string { return "slice error" }
+
+func TestErrorCatalogGenerated(t *testing.T) {
+	want, err := json.MarshalIndent(ErrorCatalog(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile("../generated/errcodes/errcodes.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), want) {
+		t.Error("generated/errcodes is out of date; run errcatalog to update it")
+	}
+}
+
+func TestRetriable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, true},
+		{pg.ErrUserInputNotFound, false},
+		{errRateLimited, true},
+		{errors.WithData(txbuilder.ErrAction, "actions", []detailedError{{Retriable: true}}), true},
+		{errors.WithData(txbuilder.ErrAction, "actions", []detailedError{{Retriable: true}, {}}), false},
+	}
+
+	for _, test := range cases {
+		body, _ := errInfo(test.err)
+		if body.Retriable != test.want {
+			t.Errorf("errInfo(%v).Retriable = %v want %v", test.err, body.Retriable, test.want)
+		}
+	}
+}
+
+func TestErrData(t *testing.T) {
+	root := errors.New("bad tx")
+	err := errors.WithData(root, "badtx", errors.New("sub"), errors.KeyInputIndex, 2)
+	body, _ := errInfo(errors.Sub(txbuilder.ErrRejected, err))
+	if body.ChainCode != "CH735" {
+		t.Errorf("code = %s want CH735", body.ChainCode)
+	}
+	want := map[string]interface{}{"badtx": "sub", errors.KeyInputIndex: 2}
+	if !reflect.DeepEqual(body.Data, want) {
+		t.Errorf("data = %v want %v", body.Data, want)
+	}
+	if _, ok := errors.Data(err)["badtx"].(error); !ok {
+		t.Error("errInfo modified the error's data")
+	}
+}
@@ -0,0 +1,394 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+// explorerPrefix is the path prefix of the block explorer
+// endpoints. They can be used with explorer access tokens,
+// which can't be used for anything else, as well as with
+// client tokens. They return only data that is on the
+// blockchain, never local account data, so they can back
+// a public, read-only explorer.
+const explorerPrefix = "/explorer/"
+
+// maxSearchResults bounds each kind of result
+// returned by /explorer/search.
+const maxSearchResults = 10
+
+// These types enforce the ordering of JSON fields in API output.
+// They omit the local annotations included by /list-transactions.
+type (
+	explorerTxResp struct {
+		ID            interface{}      `json:"id"`
+		Timestamp     interface{}      `json:"timestamp"`
+		BlockID       interface{}      `json:"block_id"`
+		BlockHeight   interface{}      `json:"block_height"`
+		Position      interface{}      `json:"position"`
+		ReferenceData interface{}      `json:"reference_data"`
+		Inputs        []*explorerInOut `json:"inputs"`
+		Outputs       []*explorerInOut `json:"outputs"`
+	}
+	explorerInOut struct {
+		Type            interface{} `json:"type"`
+		Position        interface{} `json:"position,omitempty"`
+		AssetID         interface{} `json:"asset_id"`
+		AssetDefinition interface{} `json:"asset_definition,omitempty"`
+		Amount          interface{} `json:"amount"`
+		IssuanceProgram interface{} `json:"issuance_program,omitempty"`
+		ControlProgram  interface{} `json:"control_program,omitempty"`
+		SpentOutput     interface{} `json:"spent_output,omitempty"`
+		ReferenceData   interface{} `json:"reference_data"`
+	}
+	explorerBlock struct {
+		ID               bc.Hash   `json:"id"`
+		Height           uint64    `json:"height"`
+		Timestamp        time.Time `json:"timestamp"`
+		PreviousBlockID  bc.Hash   `json:"previous_block_id"`
+		TransactionCount int       `json:"transaction_count"`
+		TransactionIDs   []bc.Hash `json:"transaction_ids"`
+
+		// Cost is the work of validating the block, if it
+		// has been indexed.
+		Cost *validation.Cost `json:"cost,omitempty"`
+	}
+	explorerCirculation struct {
+		AssetID     interface{} `json:"asset_id"`
+		Circulation uint64      `json:"circulation"`
+	}
+)
+
+func explorerInOutFromMap(m map[string]interface{}) *explorerInOut {
+	return &explorerInOut{
+		Type:            m["type"],
+		Position:        m["position"],
+		AssetID:         m["asset_id"],
+		AssetDefinition: m["asset_definition"],
+		Amount:          m["amount"],
+		IssuanceProgram: m["issuance_program"],
+		ControlProgram:  m["control_program"],
+		SpentOutput:     m["spent_output"],
+		ReferenceData:   m["reference_data"],
+	}
+}
+
+func explorerTx(raw interface{}) (*explorerTxResp, error) {
+	tjson, ok := raw.(*json.RawMessage)
+	if !ok || tjson == nil {
+		return nil, fmt.Errorf("unexpected value %T in Indexer.Transactions output", raw)
+	}
+	var tx struct {
+		ID            interface{}              `json:"id"`
+		Timestamp     interface{}              `json:"timestamp"`
+		BlockID       interface{}              `json:"block_id"`
+		BlockHeight   interface{}              `json:"block_height"`
+		Position      interface{}              `json:"position"`
+		ReferenceData interface{}              `json:"reference_data"`
+		Inputs        []map[string]interface{} `json:"inputs"`
+		Outputs       []map[string]interface{} `json:"outputs"`
+	}
+	err := json.Unmarshal(*tjson, &tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding Indexer.Transactions output")
+	}
+	r := &explorerTxResp{
+		ID:            tx.ID,
+		Timestamp:     tx.Timestamp,
+		BlockID:       tx.BlockID,
+		BlockHeight:   tx.BlockHeight,
+		Position:      tx.Position,
+		ReferenceData: tx.ReferenceData,
+		Inputs:        make([]*explorerInOut, 0, len(tx.Inputs)),
+		Outputs:       make([]*explorerInOut, 0, len(tx.Outputs)),
+	}
+	for _, in := range tx.Inputs {
+		r.Inputs = append(r.Inputs, explorerInOutFromMap(in))
+	}
+	for _, out := range tx.Outputs {
+		r.Outputs = append(r.Outputs, explorerInOutFromMap(out))
+	}
+	return r, nil
+}
+
+// explorerTxs returns the most recent transactions
+// matching the filter, up to limit.
+func (h *Handler) explorerTxs(ctx context.Context, f string, vals []interface{}, limit int) ([]*explorerTxResp, error) {
+	p, err := filter.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	after := query.TxAfter{FromBlockHeight: math.MaxInt64, FromPosition: math.MaxInt32}
+	txs, _, err := h.Indexer.Transactions(ctx, p, vals, after, limit, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "running tx query")
+	}
+	resp := make([]*explorerTxResp, 0, len(txs))
+	for _, t := range txs {
+		r, err := explorerTx(t)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, r)
+	}
+	return resp, nil
+}
+
+// explorerCirculations returns the units in circulation of
+// each asset, or of the one asset with the given ID.
+func (h *Handler) explorerCirculations(ctx context.Context, assetID string) ([]*explorerCirculation, error) {
+	f, vals := "", []interface{}(nil)
+	if assetID != "" {
+		f, vals = "asset_id=$1", []interface{}{assetID}
+	}
+	p, err := filter.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	sumBy, err := filter.ParseField("asset_id")
+	if err != nil {
+		return nil, err
+	}
+	balances, err := h.Indexer.Balances(ctx, p, vals, []filter.Field{sumBy}, math.MaxInt64, false)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]*explorerCirculation, 0, len(balances))
+	for _, b := range balances {
+		bal, ok := b.(*query.Balance)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T in Indexer.Balances output", b)
+		}
+		resp = append(resp, &explorerCirculation{
+			AssetID:     bal.SumBy["asset_id"],
+			Circulation: bal.Amount,
+		})
+	}
+	return resp, nil
+}
+
+func newExplorerBlock(b *bc.Block) *explorerBlock {
+	eb := &explorerBlock{
+		ID:               b.Hash(),
+		Height:           b.Height,
+		Timestamp:        b.Time(),
+		PreviousBlockID:  b.PreviousBlockHash,
+		TransactionCount: len(b.Transactions),
+		TransactionIDs:   make([]bc.Hash, 0, len(b.Transactions)),
+	}
+	for _, tx := range b.Transactions {
+		eb.TransactionIDs = append(eb.TransactionIDs, tx.Hash)
+	}
+	return eb
+}
+
+// addBlockCosts sets the cost of each block in blocks
+// that has been indexed.
+func (h *Handler) addBlockCosts(ctx context.Context, blocks []*explorerBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	from, to := blocks[0].Height, blocks[0].Height
+	for _, b := range blocks {
+		if b.Height < from {
+			from = b.Height
+		}
+		if b.Height > to {
+			to = b.Height
+		}
+	}
+	costs, err := h.Indexer.BlockCosts(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		if cost, ok := costs[b.Height]; ok {
+			b.Cost = &cost
+		}
+	}
+	return nil
+}
+
+// explorerSearch is an http handler that finds the transactions,
+// blocks, assets and unspent outputs identified by a hex string:
+// a transaction ID, block ID, asset ID, or a control program or
+// issuance program.
+//
+// POST /explorer/search
+func (h *Handler) explorerSearch(ctx context.Context, in struct {
+	Query string `json:"query"`
+}) (interface{}, error) {
+	q := strings.ToLower(strings.TrimSpace(in.Query))
+	b, err := hex.DecodeString(q)
+	if err != nil || len(b) == 0 {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "query must be a hex-encoded ID or program")
+	}
+
+	result := struct {
+		Transactions []*explorerTxResp      `json:"transactions"`
+		Blocks       []*explorerBlock       `json:"blocks"`
+		Assets       []*explorerCirculation `json:"assets"`
+	}{
+		Blocks: []*explorerBlock{},
+		Assets: []*explorerCirculation{},
+	}
+
+	seen := make(map[interface{}]bool)
+	addTxs := func(txs []*explorerTxResp) {
+		for _, tx := range txs {
+			if !seen[tx.ID] {
+				seen[tx.ID] = true
+				result.Transactions = append(result.Transactions, tx)
+			}
+		}
+	}
+
+	if len(b) == len(bc.Hash{}) {
+		txs, err := h.explorerTxs(ctx, "id=$1", []interface{}{q}, 1)
+		if err != nil {
+			return nil, err
+		}
+		addTxs(txs)
+
+		var height uint64
+		err = h.DB.QueryRow(ctx, `SELECT height FROM blocks WHERE block_hash = $1`, b).Scan(&height)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, errors.Wrap(err, "looking up block")
+		}
+		if err == nil {
+			block, err := h.Chain.GetBlock(ctx, height)
+			if err != nil {
+				return nil, err
+			}
+			result.Blocks = append(result.Blocks, newExplorerBlock(block))
+			err = h.addBlockCosts(ctx, result.Blocks)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		result.Assets, err = h.explorerCirculations(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Assets) > 0 {
+			txs, err := h.explorerTxs(ctx, "inputs(asset_id=$1) OR outputs(asset_id=$1)", []interface{}{q}, maxSearchResults)
+			if err != nil {
+				return nil, err
+			}
+			addTxs(txs)
+		}
+	}
+
+	const programFilter = "inputs(issuance_program=$1) OR inputs(control_program=$1) OR outputs(control_program=$1)"
+	txs, err := h.explorerTxs(ctx, programFilter, []interface{}{q}, maxSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	addTxs(txs)
+	if result.Transactions == nil {
+		result.Transactions = []*explorerTxResp{}
+	}
+	return result, nil
+}
+
+// explorerListBlocks is an http handler for listing
+// the most recent blocks, newest first.
+//
+// POST /explorer/list-blocks
+func (h *Handler) explorerListBlocks(ctx context.Context, in requestQuery) (page, error) {
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	next := h.Chain.Height() + 1
+	if in.After != "" {
+		after, err := strconv.ParseUint(in.After, 10, 64)
+		if err != nil {
+			return page{}, errors.WithDetailf(query.ErrBadAfter, "value: %q", in.After)
+		}
+		if after < next {
+			next = after
+		}
+	}
+
+	blocks := make([]*explorerBlock, 0, limit)
+	for next > 1 && len(blocks) < limit {
+		next--
+		b, err := h.Chain.GetBlock(ctx, next)
+		if err != nil {
+			return page{}, err
+		}
+		blocks = append(blocks, newExplorerBlock(b))
+	}
+	err := h.addBlockCosts(ctx, blocks)
+	if err != nil {
+		return page{}, err
+	}
+
+	out := in
+	out.After = strconv.FormatUint(next, 10)
+	return page{
+		Items:    httpjson.Array(blocks),
+		LastPage: next <= 1,
+		Next:     out,
+	}, nil
+}
+
+// explorerStats is an http handler reporting
+// statistics about the blockchain.
+//
+// POST /explorer/stats
+func (h *Handler) explorerStats(ctx context.Context) (interface{}, error) {
+	stats, err := h.Indexer.ChainStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := h.Chain.State()
+	resp := struct {
+		BlockchainID   bc.Hash   `json:"blockchain_id"`
+		BlockHeight    uint64    `json:"block_height"`
+		BlockTimestamp time.Time `json:"block_timestamp"`
+		*query.ChainStats
+	}{
+		BlockchainID: h.Config.BlockchainID,
+		ChainStats:   stats,
+	}
+	if block != nil {
+		resp.BlockHeight = block.Height
+		resp.BlockTimestamp = block.Time()
+	}
+	return resp, nil
+}
+
+// explorerListAssetCirculation is an http handler reporting
+// the units in circulation of each asset, or of one asset.
+//
+// POST /explorer/list-asset-circulation
+func (h *Handler) explorerListAssetCirculation(ctx context.Context, in struct {
+	AssetID string `json:"asset_id"`
+}) (page, error) {
+	circ, err := h.explorerCirculations(ctx, in.AssetID)
+	if err != nil {
+		return page{}, err
+	}
+	return page{
+		Items:    httpjson.Array(circ),
+		LastPage: true,
+	}, nil
+}
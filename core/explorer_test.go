@@ -0,0 +1,54 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExplorerTxOmitsLocalData(t *testing.T) {
+	raw := json.RawMessage(`{
+		"id": "abc",
+		"is_local": "yes",
+		"reference_data": {},
+		"inputs": [{
+			"type": "spend", "asset_id": "a1", "asset_alias": "gold", "amount": 5,
+			"account_id": "acc1", "account_alias": "alice", "account_tags": {"x": 1},
+			"control_program": "51", "reference_data": {}
+		}],
+		"outputs": [{
+			"type": "control", "position": 0, "purpose": "receive", "asset_id": "a1", "amount": 5,
+			"account_id": "acc2", "counterparty": "Acme Bank", "control_program": "52", "reference_data": {}
+		}]
+	}`)
+	tx, err := explorerTx(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		IsLocal interface{}              `json:"is_local"`
+		Inputs  []map[string]interface{} `json:"inputs"`
+		Outputs []map[string]interface{} `json:"outputs"`
+	}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.IsLocal != nil {
+		t.Errorf("is_local = %v, want omitted", got.IsLocal)
+	}
+	for _, item := range append(got.Inputs, got.Outputs...) {
+		for _, k := range []string{"account_id", "account_alias", "account_tags", "account_path", "account_key_generation", "asset_alias", "purpose", "counterparty"} {
+			if _, ok := item[k]; ok {
+				t.Errorf("explorer item has local field %s: %v", k, item)
+			}
+		}
+		if item["control_program"] == nil || item["amount"] == nil {
+			t.Errorf("explorer item lacks blockchain data: %v", item)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Package failover fails the processes of a Chain Core over
+// from their primary database to a standby when the primary
+// is lost.
+//
+// The processes coordinate through etcd, whose raft log
+// keeps two keys for the core: which database is active,
+// and a lease on leadership. A process leads only while it
+// holds both the lease and the leader row in its database
+// (see package leader), and it takes the lease only while
+// its database is the active one. Only the lease holder
+// switches the active database, so once it has, no process
+// can lead against the old primary: a process there either
+// holds no lease or steps down when it can't renew it,
+// before the lease expires.
+//
+// The core doesn't promote the standby itself. It fails over
+// once the primary has been unreachable, or read-only, for a
+// while and the standby accepts writes. Failing back to the
+// primary is done by hand, by setting the active database key
+// in etcd and restarting the processes.
+package failover
+
+import (
+	"context"
+	"time"
+
+	"github.com/coreos/etcd/client"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+// Names of the databases.
+const (
+	Primary = "primary"
+	Standby = "standby"
+)
+
+// LeaseTTL is how long the leader lease lasts without renewal.
+// It matches the expiry of the leader row in the database.
+const LeaseTTL = 10 * time.Second
+
+// ErrFailedOver is returned by Monitor when the
+// process's database is no longer the active one.
+var ErrFailedOver = errors.New("core database failed over")
+
+var errReadOnly = errors.New("database is read-only")
+
+// checkPeriod is how often Monitor checks the databases.
+var checkPeriod = 5 * time.Second
+
+// A Coordinator fails a core over between databases,
+// and fences leadership to the active database.
+// It implements leader.Fence.
+type Coordinator struct {
+	Keys client.KeysAPI
+
+	// Prefix is the etcd key under which the
+	// core's active database and lease are kept.
+	Prefix string
+
+	// Database is Primary or Standby: the
+	// database this process uses.
+	Database string
+
+	// After is how long the primary must be
+	// lost before the core fails over.
+	After time.Duration
+}
+
+func (c *Coordinator) databaseKey() string { return c.Prefix + "/database" }
+func (c *Coordinator) leaseKey() string    { return c.Prefix + "/leader" }
+
+// Active returns the name of the active database.
+// If none is recorded yet, the primary is.
+func (c *Coordinator) Active(ctx context.Context) (string, error) {
+	_, err := c.Keys.Set(ctx, c.databaseKey(), Primary, &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err == nil {
+		return Primary, nil
+	} else if !hasCode(err, client.ErrorCodeNodeExist) {
+		return "", errors.Wrap(err, "recording active database")
+	}
+	resp, err := c.Keys.Get(ctx, c.databaseKey(), nil)
+	if err != nil {
+		return "", errors.Wrap(err, "getting active database")
+	}
+	return resp.Node.Value, nil
+}
+
+// Acquire takes the leader lease for key, if it is free or
+// key already holds it, and the process's database is the
+// active one.
+func (c *Coordinator) Acquire(ctx context.Context, key string) (bool, error) {
+	_, err := c.Keys.Set(ctx, c.leaseKey(), key, &client.SetOptions{PrevExist: client.PrevNoExist, TTL: LeaseTTL})
+	if hasCode(err, client.ErrorCodeNodeExist) {
+		// A restarted leader holds the lease it had.
+		ok, err := c.Renew(ctx, key)
+		if !ok || err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, errors.Wrap(err, "acquiring leader lease")
+	}
+
+	// The active database changes only under the
+	// lease, so it can't change while key holds it.
+	active, err := c.Active(ctx)
+	if err == nil && active != c.Database {
+		err = ErrFailedOver
+	}
+	if err != nil {
+		c.Release(ctx, key)
+		return false, err
+	}
+	return true, nil
+}
+
+// Renew extends the leader lease held by key.
+// It reports false if key doesn't hold it.
+func (c *Coordinator) Renew(ctx context.Context, key string) (bool, error) {
+	_, err := c.Keys.Set(ctx, c.leaseKey(), key, &client.SetOptions{PrevValue: key, TTL: LeaseTTL})
+	if hasCode(err, client.ErrorCodeTestFailed) || client.IsKeyNotFound(err) {
+		return false, nil
+	}
+	return err == nil, errors.Wrap(err, "renewing leader lease")
+}
+
+// Release gives up the leader lease, if key holds it.
+func (c *Coordinator) Release(ctx context.Context, key string) {
+	_, err := c.Keys.Delete(ctx, c.leaseKey(), &client.DeleteOptions{PrevValue: key})
+	if err != nil && !hasCode(err, client.ErrorCodeTestFailed) && !client.IsKeyNotFound(err) {
+		log.Error(ctx, err, "releasing leader lease")
+	}
+}
+
+// Writable returns a check that db is
+// reachable and accepts writes.
+func Writable(db pg.DB) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var inRecovery bool
+		err := db.QueryRow(ctx, `SELECT pg_is_in_recovery()`).Scan(&inRecovery)
+		if err != nil {
+			return err
+		}
+		if inRecovery {
+			return errReadOnly
+		}
+		return nil
+	}
+}
+
+// Monitor checks the databases periodically, with the
+// functions primary and standby, such as those returned
+// by Writable. When the primary has failed every check for
+// c.After and the standby passes, it takes the leader lease
+// for key and makes the standby the active database.
+//
+// It returns ErrFailedOver once the process's database
+// is no longer active, whether it or another process
+// failed over, so the process can restart on the standby.
+func (c *Coordinator) Monitor(ctx context.Context, key string, primary, standby func(context.Context) error) error {
+	var lostSince time.Time
+	ticks := time.Tick(checkPeriod)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticks:
+		}
+
+		active, err := c.Active(ctx)
+		if err != nil {
+			log.Error(ctx, err)
+			continue
+		}
+		if active != c.Database {
+			return ErrFailedOver
+		}
+		if c.Database != Primary {
+			continue // nothing to fail over to
+		}
+
+		err = primary(ctx)
+		if err == nil {
+			lostSince = time.Time{}
+			continue
+		}
+		if lostSince.IsZero() {
+			lostSince = time.Now()
+			log.Error(ctx, err, "checking primary database")
+		}
+		if time.Since(lostSince) < c.After {
+			continue
+		}
+		err = standby(ctx)
+		if err != nil {
+			log.Error(ctx, err, "checking standby database")
+			continue
+		}
+
+		done, err := c.failOver(ctx, key)
+		if err != nil {
+			log.Error(ctx, err)
+		} else if done {
+			return ErrFailedOver
+		}
+	}
+}
+
+// failOver makes the standby the active database, if
+// key can take the leader lease. While the primary's
+// leader can still renew the lease, it can't.
+// It reports whether it failed over.
+func (c *Coordinator) failOver(ctx context.Context, key string) (bool, error) {
+	ok, err := c.Acquire(ctx, key)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer c.Release(ctx, key)
+
+	_, err = c.Keys.Set(ctx, c.databaseKey(), Standby, &client.SetOptions{PrevValue: Primary})
+	if err != nil {
+		return false, errors.Wrap(err, "switching active database")
+	}
+	log.Messagef(ctx, "Failed over to the standby database")
+	return true, nil
+}
+
+func hasCode(err error, code int) bool {
+	cerr, ok := err.(client.Error)
+	return ok && cerr.Code == code
+}
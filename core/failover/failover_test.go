@@ -0,0 +1,229 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// testKeys is an in-memory etcd keyspace. It
+// implements the conditions of Get, Set and Delete
+// that Coordinator uses, but not TTLs.
+type testKeys struct {
+	client.KeysAPI
+
+	mu   sync.Mutex
+	vals map[string]string
+}
+
+func newTestKeys() *testKeys {
+	return &testKeys{vals: make(map[string]string)}
+}
+
+func (k *testKeys) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	v, ok := k.vals[key]
+	if !ok {
+		return nil, client.Error{Code: client.ErrorCodeKeyNotFound}
+	}
+	return &client.Response{Node: &client.Node{Key: key, Value: v}}, nil
+}
+
+func (k *testKeys) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	prev, ok := k.vals[key]
+	if opts != nil {
+		if opts.PrevExist == client.PrevNoExist && ok {
+			return nil, client.Error{Code: client.ErrorCodeNodeExist}
+		}
+		if opts.PrevValue != "" && !ok {
+			return nil, client.Error{Code: client.ErrorCodeKeyNotFound}
+		}
+		if opts.PrevValue != "" && prev != opts.PrevValue {
+			return nil, client.Error{Code: client.ErrorCodeTestFailed}
+		}
+	}
+	k.vals[key] = value
+	return &client.Response{Node: &client.Node{Key: key, Value: value}}, nil
+}
+
+func (k *testKeys) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	prev, ok := k.vals[key]
+	if !ok {
+		return nil, client.Error{Code: client.ErrorCodeKeyNotFound}
+	}
+	if opts != nil && opts.PrevValue != "" && prev != opts.PrevValue {
+		return nil, client.Error{Code: client.ErrorCodeTestFailed}
+	}
+	delete(k.vals, key)
+	return &client.Response{}, nil
+}
+
+func (k *testKeys) value(key string) string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.vals[key]
+}
+
+func TestActive(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeys()
+	c := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary}
+
+	active, err := c.Active(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != Primary || keys.value("/core/database") != Primary {
+		t.Errorf("active = %s, recorded %q; want %s", active, keys.value("/core/database"), Primary)
+	}
+
+	keys.vals["/core/database"] = Standby
+	active, err = c.Active(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != Standby {
+		t.Errorf("active = %s want %s", active, Standby)
+	}
+}
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeys()
+	c := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary}
+
+	ok, err := c.Acquire(ctx, "a")
+	if err != nil || !ok {
+		t.Fatalf("Acquire(a) = %v, %v want true", ok, err)
+	}
+	// A leader restarted with the same key keeps its lease.
+	ok, err = c.Acquire(ctx, "a")
+	if err != nil || !ok {
+		t.Fatalf("Acquire(a) again = %v, %v want true", ok, err)
+	}
+	ok, err = c.Acquire(ctx, "b")
+	if err != nil || ok {
+		t.Fatalf("Acquire(b) = %v, %v want false", ok, err)
+	}
+	ok, err = c.Renew(ctx, "b")
+	if err != nil || ok {
+		t.Fatalf("Renew(b) = %v, %v want false", ok, err)
+	}
+	ok, err = c.Renew(ctx, "a")
+	if err != nil || !ok {
+		t.Fatalf("Renew(a) = %v, %v want true", ok, err)
+	}
+
+	// Releasing someone else's lease does nothing.
+	c.Release(ctx, "b")
+	if got := keys.value("/core/leader"); got != "a" {
+		t.Fatalf("lease held by %q want a", got)
+	}
+	c.Release(ctx, "a")
+	ok, err = c.Renew(ctx, "a")
+	if err != nil || ok {
+		t.Fatalf("Renew(a) after release = %v, %v want false", ok, err)
+	}
+	ok, err = c.Acquire(ctx, "b")
+	if err != nil || !ok {
+		t.Fatalf("Acquire(b) after release = %v, %v want true", ok, err)
+	}
+}
+
+func TestAcquireFailedOver(t *testing.T) {
+	ctx := context.Background()
+	keys := newTestKeys()
+	keys.vals["/core/database"] = Standby
+	c := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary}
+
+	ok, err := c.Acquire(ctx, "a")
+	if err != ErrFailedOver || ok {
+		t.Fatalf("Acquire = %v, %v want false, %v", ok, err, ErrFailedOver)
+	}
+	if got := keys.value("/core/leader"); got != "" {
+		t.Errorf("lease held by %q want none", got)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	defer func(d time.Duration) { checkPeriod = d }(checkPeriod)
+	checkPeriod = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lost := func(context.Context) error { return errors.New("unreachable") }
+	up := func(context.Context) error { return nil }
+
+	// While the primary's leader holds the lease,
+	// the standby can't be made active.
+	keys := newTestKeys()
+	keys.vals["/core/leader"] = "leader"
+	c := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary}
+	mctx, mcancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	err := c.Monitor(mctx, "b", lost, up)
+	mcancel()
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Monitor with lease held = %v want %v", err, context.DeadlineExceeded)
+	}
+	if got := keys.value("/core/database"); got != Primary {
+		t.Fatalf("active database = %s want %s", got, Primary)
+	}
+
+	// Once it's lost the lease, the monitor fails over.
+	delete(keys.vals, "/core/leader")
+	err = c.Monitor(ctx, "b", lost, up)
+	if err != ErrFailedOver {
+		t.Fatalf("Monitor = %v want %v", err, ErrFailedOver)
+	}
+	if got := keys.value("/core/database"); got != Standby {
+		t.Errorf("active database = %s want %s", got, Standby)
+	}
+	if got := keys.value("/core/leader"); got != "" {
+		t.Errorf("lease held by %q want none", got)
+	}
+
+	// Other processes on the primary see the failover.
+	other := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary}
+	err = other.Monitor(ctx, "c", up, up)
+	if err != ErrFailedOver {
+		t.Errorf("other Monitor = %v want %v", err, ErrFailedOver)
+	}
+}
+
+func TestMonitorAfter(t *testing.T) {
+	defer func(d time.Duration) { checkPeriod = d }(checkPeriod)
+	checkPeriod = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	lost := func(context.Context) error { return errors.New("unreachable") }
+	up := func(context.Context) error { return nil }
+
+	// A primary lost for less than After isn't failed over,
+	// nor is one lost when the standby is too.
+	keys := newTestKeys()
+	c := &Coordinator{Keys: keys, Prefix: "/core", Database: Primary, After: time.Hour}
+	err := c.Monitor(ctx, "a", lost, up)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Monitor = %v want %v", err, context.DeadlineExceeded)
+	}
+	c.After = 0
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = c.Monitor(ctx, "a", lost, lost)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Monitor with standby lost = %v want %v", err, context.DeadlineExceeded)
+	}
+	if got := keys.value("/core/database"); got != Primary {
+		t.Errorf("active database = %s want %s", got, Primary)
+	}
+}
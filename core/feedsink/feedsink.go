@@ -0,0 +1,314 @@
+// Package feedsink pushes the transactions matching transaction
+// feeds to the feeds' sinks, as each block is indexed.
+//
+// A sink is an AWS SQS queue. Each matching transaction is sent
+// as one message, in the order of the blockchain, encoded as
+// JSON or as CBOR. SQS message bodies are text, so CBOR bodies
+// are base64-encoded. Messages carry the feed ID, the block
+// height and the content type as message attributes, plus the
+// value of the sink's ordering key, if the transaction has one.
+//
+// A message the queue doesn't accept after several attempts is
+// sent to the sink's dead-letter queue, if it has one, along
+// with the error. Otherwise it is logged and dropped.
+//
+// A block is marked sent once its messages are delivered.
+// If the core stops while delivering a block, the block is
+// sent again when it restarts, so a queue may get a message
+// more than once.
+package feedsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/query/filter"
+	"chain/core/txfeed"
+	"chain/database/pg"
+	"chain/encoding/cbor"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol"
+	"chain/protocol/bc"
+)
+
+// PinName is used to identify the pin associated with
+// the txfeed sink block processor.
+const PinName = "txfeed-sinks"
+
+// Attempts is how many times a message is sent
+// to a queue before it is dead-lettered.
+const Attempts = 3
+
+// backoff is the wait after the first failed attempt.
+// It doubles after each one.
+var backoff = time.Second
+
+// Content types of message bodies.
+const (
+	contentJSON = "application/json"
+	contentCBOR = "application/cbor"
+)
+
+// Queue sends messages to SQS queues.
+// It is implemented by *sqs.SQS.
+type Queue interface {
+	SendMessage(*sqs.SendMessageInput) (*sqs.SendMessageOutput, error)
+}
+
+// Pusher pushes the txs matching each feed with
+// a sink to the sink, after each block is indexed.
+type Pusher struct {
+	DB       pg.DB
+	Chain    *protocol.Chain
+	PinStore *pin.Store
+	SQS      Queue
+}
+
+// ProcessBlocks pushes each new block's
+// matching transactions to the feeds' sinks.
+func (p *Pusher) ProcessBlocks(ctx context.Context) {
+	if p.PinStore == nil {
+		return
+	}
+	p.PinStore.ProcessBlocks(ctx, p.Chain, PinName, p.pushBlock)
+}
+
+type feedSink struct {
+	feedID string
+	filter string
+	sink   txfeed.Sink
+}
+
+func (p *Pusher) pushBlock(ctx context.Context, b *bc.Block) error {
+	<-p.PinStore.PinWaiter(query.TxPinName, b.Height)
+
+	// Sinks already sent this block, and sinks
+	// created after it landed, are left out.
+	const q = `
+		SELECT f.id, f.filter, s.type, s.queue_url,
+			COALESCE(s.dead_letter_queue_url, ''), s.encoding, COALESCE(s.ordering_key, '')
+		FROM txfeed_sinks s JOIN txfeeds f ON f.id = s.feed_id
+		WHERE s.last_block_height < $1
+	`
+	var sinks []*feedSink
+	err := pg.ForQueryRows(ctx, p.DB, q, b.Height, func(feedID, fil, typ, queueURL, dlq, encoding, key string) {
+		sinks = append(sinks, &feedSink{
+			feedID: feedID,
+			filter: fil,
+			sink: txfeed.Sink{
+				Type:               typ,
+				QueueURL:           queueURL,
+				DeadLetterQueueURL: dlq,
+				Encoding:           encoding,
+				OrderingKey:        key,
+			},
+		})
+	})
+	if err != nil {
+		return errors.Wrap(err, "loading txfeed sinks")
+	}
+
+	var wg sync.WaitGroup
+	for _, fs := range sinks {
+		txs, err := p.matchingTxs(ctx, fs.filter, b.Height)
+		if err != nil {
+			return err
+		}
+		if len(txs) == 0 {
+			continue
+		}
+		msgs := make([]*sqs.SendMessageInput, 0, len(txs))
+		for _, tx := range txs {
+			m, err := message(fs.feedID, &fs.sink, b.Height, tx)
+			if err != nil {
+				log.Error(ctx, err, "at", "encoding txfeed message", "feed", fs.feedID)
+				continue
+			}
+			msgs = append(msgs, m)
+		}
+		wg.Add(1)
+		go func(fs *feedSink) {
+			defer wg.Done()
+			p.deliver(ctx, fs.feedID, &fs.sink, msgs)
+		}(fs)
+	}
+	// Wait for the sinks, so each one gets
+	// the blocks' transactions in order.
+	wg.Wait()
+
+	// The block is marked sent only once it has been
+	// delivered, so a block interrupted by a crash is
+	// sent again rather than lost.
+	for _, fs := range sinks {
+		err := p.markSent(ctx, fs.feedID, b.Height)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchingTxs returns the annotated txs of the block
+// at height that match fil, in block order.
+func (p *Pusher) matchingTxs(ctx context.Context, fil string, height uint64) ([][]byte, error) {
+	pred, err := filter.Parse(fil)
+	if err != nil {
+		return nil, err
+	}
+	expr, err := filter.AsSQL(pred, "data", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting to SQL")
+	}
+	q := "SELECT data FROM annotated_txs WHERE "
+	vals := expr.Values
+	if len(expr.SQL) > 0 {
+		q += expr.SQL + " AND "
+	}
+	q += "block_height = $" + strconv.Itoa(len(vals)+1) + " ORDER BY tx_pos"
+	vals = append(vals, height)
+
+	var txs [][]byte
+	err = pg.ForQueryRows(ctx, p.DB, q, append(vals, func(data []byte) {
+		txs = append(txs, data)
+	})...)
+	return txs, errors.Wrap(err, "querying annotated transactions")
+}
+
+// markSent records that the feed's sink has been
+// sent the block at height, so it isn't sent again.
+func (p *Pusher) markSent(ctx context.Context, feedID string, height uint64) error {
+	const q = `
+		UPDATE txfeed_sinks SET last_block_height = $2
+		WHERE feed_id = $1 AND last_block_height < $2
+	`
+	_, err := p.DB.Exec(ctx, q, feedID, height)
+	return errors.Wrap(err, "updating txfeed sink")
+}
+
+// message returns the message sending the annotated tx,
+// as JSON data, to sink s of the given feed.
+func message(feedID string, s *txfeed.Sink, height uint64, data []byte) (*sqs.SendMessageInput, error) {
+	body, contentType := string(data), contentJSON
+	if s.Encoding == txfeed.EncodingCBOR {
+		b, err := cbor.FromJSON(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "encoding cbor")
+		}
+		body, contentType = base64.StdEncoding.EncodeToString(b), contentCBOR
+	}
+	attrs := map[string]*sqs.MessageAttributeValue{
+		"feed_id":      stringAttr(feedID),
+		"block_height": {DataType: aws.String("Number"), StringValue: aws.String(strconv.FormatUint(height, 10))},
+		"content_type": stringAttr(contentType),
+	}
+	if s.OrderingKey != "" {
+		key, ok, err := fieldValue(data, s.OrderingKey)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			attrs["ordering_key"] = stringAttr(key)
+		}
+	}
+	return &sqs.SendMessageInput{
+		QueueURL:          aws.String(s.QueueURL),
+		MessageBody:       aws.String(body),
+		MessageAttributes: attrs,
+	}, nil
+}
+
+func stringAttr(s string) *sqs.MessageAttributeValue {
+	return &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(s)}
+}
+
+// fieldValue returns the value of field, a path of object
+// keys such as reference_data.customer, in the JSON object
+// data. Strings are returned as they are, other values as
+// JSON. It reports false if data has no such field.
+func fieldValue(data []byte, field string) (string, bool, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&v)
+	if err != nil {
+		return "", false, errors.Wrap(err, "decoding annotated transaction")
+	}
+	for _, k := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false, nil
+		}
+		v, ok = obj[k]
+		if !ok {
+			return "", false, nil
+		}
+	}
+	if s, ok := v.(string); ok {
+		return s, true, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false, errors.Wrap(err)
+	}
+	return string(b), true, nil
+}
+
+// deliver sends msgs, in order, to sink s of the
+// given feed, dead-lettering those it can't send.
+func (p *Pusher) deliver(ctx context.Context, feedID string, s *txfeed.Sink, msgs []*sqs.SendMessageInput) {
+	for _, m := range msgs {
+		err := p.send(ctx, m)
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if s.DeadLetterQueueURL == "" {
+			log.Error(ctx, err, "at", "sending txfeed message", "feed", feedID)
+			continue
+		}
+		dead := *m
+		dead.QueueURL = aws.String(s.DeadLetterQueueURL)
+		dead.MessageAttributes = make(map[string]*sqs.MessageAttributeValue, len(m.MessageAttributes)+1)
+		for k, v := range m.MessageAttributes {
+			dead.MessageAttributes[k] = v
+		}
+		dead.MessageAttributes["error"] = stringAttr(err.Error())
+		err = p.send(ctx, &dead)
+		if err != nil {
+			log.Error(ctx, err, "at", "dead-lettering txfeed message", "feed", feedID)
+		}
+	}
+}
+
+// send sends m, retrying with backoff if the queue
+// doesn't accept it. It returns the last error if
+// every attempt fails.
+func (p *Pusher) send(ctx context.Context, m *sqs.SendMessageInput) error {
+	wait := backoff
+	for i := 0; ; i++ {
+		_, err := p.SQS.SendMessage(m)
+		if err == nil || i+1 == Attempts {
+			return errors.Wrap(err, "sending sqs message")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+			wait *= 2
+		}
+	}
+}
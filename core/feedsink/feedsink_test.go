@@ -0,0 +1,153 @@
+package feedsink
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"chain/core/txfeed"
+	"chain/encoding/cbor"
+)
+
+const testTx = `{"id":"abc","reference_data":{"customer":"alice","n":7}}`
+
+func TestMessage(t *testing.T) {
+	s := &txfeed.Sink{
+		Type:        txfeed.SinkSQS,
+		QueueURL:    "https://sqs.us-east-1.amazonaws.com/1/feed",
+		Encoding:    txfeed.EncodingJSON,
+		OrderingKey: "reference_data.customer",
+	}
+	m, err := message("feed1", s, 5, []byte(testTx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *m.QueueURL != s.QueueURL || *m.MessageBody != testTx {
+		t.Errorf("message to %s with body %s, want %s and %s", *m.QueueURL, *m.MessageBody, s.QueueURL, testTx)
+	}
+	for k, want := range map[string]string{
+		"feed_id":      "feed1",
+		"block_height": "5",
+		"content_type": contentJSON,
+		"ordering_key": "alice",
+	} {
+		a := m.MessageAttributes[k]
+		if a == nil || *a.StringValue != want {
+			t.Errorf("attribute %s = %v want %s", k, a, want)
+		}
+	}
+
+	s.Encoding = txfeed.EncodingCBOR
+	s.OrderingKey = "reference_data.missing"
+	m, err = message("feed1", s, 5, []byte(testTx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cbor.FromJSON([]byte(testTx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *m.MessageBody != base64.StdEncoding.EncodeToString(want) {
+		t.Errorf("cbor body = %s want %x base64-encoded", *m.MessageBody, want)
+	}
+	if got := *m.MessageAttributes["content_type"].StringValue; got != contentCBOR {
+		t.Errorf("content type = %s want %s", got, contentCBOR)
+	}
+	if a := m.MessageAttributes["ordering_key"]; a != nil {
+		t.Errorf("ordering key = %v want none", a)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	cases := []struct {
+		field string
+		want  string
+		ok    bool
+	}{
+		{"id", "abc", true},
+		{"reference_data.customer", "alice", true},
+		{"reference_data.n", "7", true},
+		{"reference_data", `{"customer":"alice","n":7}`, true},
+		{"reference_data.other", "", false},
+		{"id.sub", "", false},
+	}
+	for _, c := range cases {
+		got, ok, err := fieldValue([]byte(testTx), c.field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want || ok != c.ok {
+			t.Errorf("fieldValue(%s) = %q, %v want %q, %v", c.field, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+// testQueue records the messages it's sent, by queue.
+// Sending a message to queue fails the number of times
+// given in fails for its body.
+type testQueue struct {
+	queue string
+	fails map[string]int
+	sent  map[string][]*sqs.SendMessageInput
+}
+
+func (q *testQueue) SendMessage(m *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	url, body := *m.QueueURL, *m.MessageBody
+	if url == q.queue && q.fails[body] > 0 {
+		q.fails[body]--
+		return nil, errors.New("unavailable")
+	}
+	q.sent[url] = append(q.sent[url], m)
+	return new(sqs.SendMessageOutput), nil
+}
+
+func TestDeliver(t *testing.T) {
+	defer func(d time.Duration) { backoff = d }(backoff)
+	backoff = time.Millisecond
+
+	const (
+		queue = "https://sqs.us-east-1.amazonaws.com/1/feed"
+		dlq   = "https://sqs.us-east-1.amazonaws.com/1/dead"
+	)
+	s := &txfeed.Sink{Type: txfeed.SinkSQS, QueueURL: queue, DeadLetterQueueURL: dlq, Encoding: txfeed.EncodingJSON}
+	var msgs []*sqs.SendMessageInput
+	for _, tx := range []string{`{"id":"1"}`, `{"id":"2"}`, `{"id":"3"}`} {
+		m, err := message("feed1", s, 5, []byte(tx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, m)
+	}
+
+	// The first message is retried until it's sent, and the
+	// second fails every attempt and is dead-lettered.
+	q := &testQueue{
+		queue: queue,
+		fails: map[string]int{`{"id":"1"}`: 1, `{"id":"2"}`: Attempts},
+		sent:  make(map[string][]*sqs.SendMessageInput),
+	}
+	p := &Pusher{SQS: q}
+	p.deliver(context.Background(), "feed1", s, msgs)
+
+	var got []string
+	for _, m := range q.sent[queue] {
+		got = append(got, *m.MessageBody)
+	}
+	if len(got) != 2 || got[0] != `{"id":"1"}` || got[1] != `{"id":"3"}` {
+		t.Errorf("sent %v want ids 1 and 3 in order", got)
+	}
+	dead := q.sent[dlq]
+	if len(dead) != 1 || *dead[0].MessageBody != `{"id":"2"}` {
+		t.Fatalf("dead-lettered %v want id 2", dead)
+	}
+	if dead[0].MessageAttributes["error"] == nil {
+		t.Error("dead-lettered message has no error attribute")
+	}
+	if msgs[1].MessageAttributes["error"] != nil || *msgs[1].QueueURL != queue {
+		t.Error("dead-lettering changed the original message")
+	}
+}
@@ -0,0 +1,169 @@
+package fetch
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// MaxBlockRange is the most blocks a peer
+// serves in one get-block-range response.
+const MaxBlockRange = 1000
+
+// maxRangeBlockSize bounds the size of a block in a
+// block range, so a corrupt length can't exhaust memory.
+const maxRangeBlockSize = 256 << 20
+
+// blockRangeReadTimeout is how long downloadBlockRange
+// waits for progress reading a response before giving up.
+const blockRangeReadTimeout = 30 * time.Second
+
+var (
+	// ErrBadBlockRange is returned when decoding a block range
+	// that is corrupt, truncated, or has blocks out of order.
+	ErrBadBlockRange = errors.New("invalid block range")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// BlockRangeRequest is the request body of
+// the get-block-range RPC.
+type BlockRangeRequest struct {
+	StartHeight uint64 `json:"start_height"`
+	EndHeight   uint64 `json:"end_height"`
+}
+
+// A BlockRangeWriter writes the response body of the
+// get-block-range RPC: a gzip stream of frames, one per
+// block, in height order, followed by an empty frame.
+// Each frame holds the block's length as a uvarint, its
+// raw bytes, and their CRC-32C checksum, big-endian.
+// Readers can tell a complete range from a truncated
+// one by the empty frame at the end.
+type BlockRangeWriter struct {
+	gz  *gzip.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+// NewBlockRangeWriter returns a BlockRangeWriter writing to w.
+func NewBlockRangeWriter(w io.Writer) *BlockRangeWriter {
+	return &BlockRangeWriter{gz: gzip.NewWriter(w)}
+}
+
+// WriteBlock writes a frame holding the raw block.
+func (w *BlockRangeWriter) WriteBlock(raw []byte) error {
+	n := binary.PutUvarint(w.buf[:], uint64(len(raw)))
+	_, err := w.gz.Write(w.buf[:n])
+	if err != nil {
+		return err
+	}
+	_, err = w.gz.Write(raw)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(w.buf[:4], crc32.Checksum(raw, crcTable))
+	_, err = w.gz.Write(w.buf[:4])
+	return err
+}
+
+// Flush flushes the frames written so far to the
+// underlying writer.
+func (w *BlockRangeWriter) Flush() error {
+	return w.gz.Flush()
+}
+
+// Close writes the final empty frame and closes the gzip
+// stream. It doesn't close the underlying writer.
+func (w *BlockRangeWriter) Close() error {
+	_, err := w.gz.Write([]byte{0})
+	if err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// ReadBlockRange reads a get-block-range response body from r,
+// calling fn with each block in turn. It checks each block's
+// checksum, and that the blocks' heights run consecutively
+// from start. It returns the number of blocks it read.
+// If fn returns an error, ReadBlockRange stops and returns it.
+func ReadBlockRange(r io.Reader, start uint64, fn func(*bc.Block) error) (n uint64, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, errors.Sub(ErrBadBlockRange, err)
+	}
+	defer gz.Close()
+	br := bufio.NewReader(gz)
+
+	for ; ; n++ {
+		size, err := binary.ReadUvarint(br)
+		if err != nil {
+			return n, errors.Sub(ErrBadBlockRange, err)
+		}
+		if size == 0 {
+			return n, nil
+		}
+		if size > maxRangeBlockSize {
+			return n, errors.WithDetailf(ErrBadBlockRange, "block of %d bytes", size)
+		}
+		raw := make([]byte, size+4)
+		_, err = io.ReadFull(br, raw)
+		if err != nil {
+			return n, errors.Sub(ErrBadBlockRange, err)
+		}
+		raw, sum := raw[:size], raw[size:]
+		if crc32.Checksum(raw, crcTable) != binary.BigEndian.Uint32(sum) {
+			return n, errors.WithDetailf(ErrBadBlockRange, "checksum mismatch at height %d", start+n)
+		}
+		block := new(bc.Block)
+		err = block.Scan(raw)
+		if err != nil {
+			return n, errors.Sub(ErrBadBlockRange, err)
+		}
+		if block.Height != start+n {
+			return n, errors.WithDetailf(ErrBadBlockRange, "got block at height %d, want %d", block.Height, start+n)
+		}
+		err = fn(block)
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+// downloadBlockRange fetches the blocks from start to end,
+// inclusive, with a single get-block-range RPC, sending each
+// one on blockch as it arrives. The peer may send fewer blocks
+// than requested. It returns the number of blocks sent.
+func downloadBlockRange(ctx context.Context, peer *rpc.Client, start, end uint64, blockch chan<- *bc.Block) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	req := BlockRangeRequest{StartHeight: start, EndHeight: end}
+	body, err := peer.CallRaw(ctx, "/rpc/get-block-range", req)
+	if err != nil {
+		return 0, errors.Wrap(err, "get block range rpc")
+	}
+	defer body.Close()
+
+	pr := &progressReader{reader: body}
+	pr.setTimeout(blockRangeReadTimeout, cancel)
+	defer pr.timer.Stop()
+
+	n, err := ReadBlockRange(pr, start, func(b *bc.Block) error {
+		select {
+		case blockch <- b:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	return n, errors.Wrapf(err, "reading block range from height %d", start)
+}
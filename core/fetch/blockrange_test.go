@@ -0,0 +1,68 @@
+package fetch
+
+import (
+	"bytes"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func writeTestRange(t *testing.T, heights ...uint64) []byte {
+	var buf bytes.Buffer
+	w := NewBlockRangeWriter(&buf)
+	for _, h := range heights {
+		raw, err := (&bc.Block{BlockHeader: bc.BlockHeader{Version: 1, Height: h}}).Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = w.WriteBlock(raw.([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBlockRangeRoundTrip(t *testing.T) {
+	data := writeTestRange(t, 5, 6, 7)
+	var got []uint64
+	n, err := ReadBlockRange(bytes.NewReader(data), 5, func(b *bc.Block) error {
+		got = append(got, b.Height)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || len(got) != 3 || got[0] != 5 || got[2] != 7 {
+		t.Errorf("read %d blocks at heights %v, want 3 at 5..7", n, got)
+	}
+}
+
+func TestBlockRangeInvalid(t *testing.T) {
+	data := writeTestRange(t, 5, 6, 7)
+	cases := []struct {
+		name  string
+		data  []byte
+		start uint64
+		wantN uint64
+	}{
+		{"truncated", data[:len(data)-12], 5, 0},
+		{"out of order", writeTestRange(t, 5, 7), 5, 1},
+		{"wrong start", data, 4, 0},
+		{"not gzip", []byte("blocks"), 5, 0},
+	}
+	for _, c := range cases {
+		n, err := ReadBlockRange(bytes.NewReader(c.data), c.start, func(*bc.Block) error { return nil })
+		if errors.Root(err) != ErrBadBlockRange {
+			t.Errorf("%s: got error %v want %v", c.name, err, ErrBadBlockRange)
+		}
+		if c.name != "truncated" && n != c.wantN {
+			t.Errorf("%s: read %d blocks, want %d", c.name, n, c.wantN)
+		}
+	}
+}
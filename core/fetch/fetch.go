@@ -126,7 +126,10 @@ func Fetch(ctx context.Context, c *protocol.Chain, peer *rpc.Client, health func
 // DownloadBlocks starts a goroutine to download blocks from
 // the given peer, starting at the given height and incrementing from there.
 // It will re-attempt downloads for the next block in the network
-// until it is available. It returns two channels, one for reading blocks
+// until it is available. While the generator is more than one block
+// ahead, it fetches compressed ranges of blocks with a single request
+// each, falling back to one request per block if the peer doesn't
+// support ranges. It returns two channels, one for reading blocks
 // and the other for reading errors. Progress will halt unless callers are
 // reading from both. DownloadBlocks will continue even if it encounters errors,
 // until its context is done.
@@ -136,6 +139,7 @@ func DownloadBlocks(ctx context.Context, peer *rpc.Client, height uint64) (chan
 	go func() {
 		var nfailures uint // for backoff
 		var ntimeouts uint // for backoff
+		useRanges := true
 		for {
 			select {
 			case <-ctx.Done():
@@ -143,6 +147,28 @@ func DownloadBlocks(ctx context.Context, peer *rpc.Client, height uint64) (chan
 				close(errch)
 				return
 			default:
+				if gh, _ := GeneratorHeight(); useRanges && gh > height {
+					end := gh
+					if end-height >= MaxBlockRange {
+						end = height + MaxBlockRange - 1
+					}
+					n, err := downloadBlockRange(ctx, peer, height, end, blockch)
+					height += n
+					if rpc.IsNotFound(err) {
+						// The peer predates get-block-range.
+						useRanges = false
+						continue
+					}
+					if err != nil {
+						errch <- err
+						nfailures++
+						time.Sleep(backoffDur(nfailures))
+						continue
+					}
+					ntimeouts, nfailures = 0, 0
+					continue
+				}
+
 				block, err := getBlock(ctx, peer, height, timeoutBackoffDur(ntimeouts))
 				if err != nil {
 					errch <- err
@@ -234,7 +260,7 @@ func getBlock(ctx context.Context, peer *rpc.Client, height uint64, timeout time
 	defer cancel()
 
 	var block *bc.Block
-	err := peer.Call(ctx, "/rpc/get-block", height, &block)
+	err := peer.CallHedged(ctx, "/rpc/get-block", height, &block)
 	if ctx.Err() == context.DeadlineExceeded {
 		return nil, nil
 	}
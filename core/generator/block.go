@@ -6,14 +6,17 @@ import (
 	"sync"
 	"time"
 
+	"chain/core/blocksigner"
 	"chain/crypto/ed25519"
 	"chain/database/pg"
 	"chain/database/sql"
 	"chain/errors"
 	"chain/log"
 	"chain/metrics"
+	"chain/protocol"
 	"chain/protocol/bc"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 	"chain/protocol/vmutil"
 )
 
@@ -43,24 +46,198 @@ func (g *Generator) makeBlock(ctx context.Context) error {
 	t0 := time.Now()
 	defer recordSince(t0)
 
+	// The ordering can be changed by any process,
+	// so reload it for each block.
+	o, err := GetOrdering(ctx, g.db)
+	if err != nil {
+		log.Error(ctx, err)
+	} else {
+		g.ordering = o
+	}
+
+	caps, err := PendingSupplyCaps(ctx, g.db)
+	if err != nil {
+		return err
+	}
+	// A cap below the amount of its asset already issued
+	// can never be committed, so drop it rather than fail
+	// to make every block from now on.
+	if bad := badSupplyCaps(g.latestSnapshot, caps); len(bad) > 0 {
+		log.Write(ctx, "at", "dropping supply caps below issued amount", "caps", bad)
+		err = deletePendingSupplyCaps(ctx, g.db, bad)
+		if err != nil {
+			return err
+		}
+		caps, err = PendingSupplyCaps(ctx, g.db)
+		if err != nil {
+			return err
+		}
+	}
+
 	g.mu.Lock()
-	txs := g.pool
+	pool, txTree := g.pool, g.poolTree
 	g.pool = nil
 	g.poolHashes = make(map[bc.Hash]bool)
+	g.poolTree = new(validation.MerkleTree)
 	g.mu.Unlock()
 
-	b, s, err := g.chain.GenerateBlock(ctx, g.latestBlock, g.latestSnapshot, time.Now(), txs)
+	var txs []*bc.Tx
+	if g.ordering.Policy == OrderFIFO {
+		for _, p := range pool {
+			txs = append(txs, p.tx)
+		}
+	} else {
+		txs = g.ordering.order(pool)
+		txTree = nil
+	}
+
+	b, s, err := g.chain.GenerateBlockFromTree(ctx, g.latestBlock, g.latestSnapshot, time.Now(), txs, txTree, caps)
 	if err != nil {
 		return errors.Wrap(err, "generate")
 	}
-	if len(b.Transactions) == 0 {
-		return nil // don't bother making an empty block
+	// A block setting caps is signed here, before the pending
+	// pool is settled, in case it must be made again without them.
+	var refused []bc.SupplyCap
+	signed := len(caps) > 0
+	if signed {
+		err = savePendingBlock(ctx, g.db, b)
+		if err != nil {
+			return err
+		}
+		err = g.getAndAddBlockSignatures(ctx, b, g.latestBlock)
+		if err != nil && errors.Root(err) != blocksigner.ErrUnapprovedSupplyCap {
+			// The caps weren't refused, so keep them
+			// and the txs for the next block.
+			g.requeue(pool)
+			return errors.Wrap(err, "sign")
+		} else if err != nil {
+			// Signers refuse blocks setting caps they haven't
+			// approved. Rather than let a proposal stop the
+			// blockchain, make the block again without the caps,
+			// and drop them if that block is signed. (A signer
+			// that signed the block with the caps won't sign
+			// another at its height, so this needs a quorum of
+			// the others.)
+			log.Error(ctx, err, "signing block with supply caps")
+			refused, caps = caps, nil
+			b, s, err = g.chain.GenerateBlockFromTree(ctx, g.latestBlock, g.latestSnapshot, time.Now(), txs, txTree, nil)
+			if err != nil {
+				return errors.Wrap(err, "generate")
+			}
+			err = savePendingBlock(ctx, g.db, b)
+			if err != nil {
+				return err
+			}
+			err = g.getAndAddBlockSignatures(ctx, b, g.latestBlock)
+			if err != nil {
+				return errors.Wrap(err, "sign")
+			}
+			log.Write(ctx, "at", "dropping supply caps refused by signers", "caps", refused)
+		}
+	}
+	var rest []pendingTx
+	if len(b.Transactions) >= protocol.MaxBlockTxs || g.blockStoppedEarly(b, txs) {
+		rest = leftovers(pool, txs, b.Transactions)
+		g.requeue(rest)
+	}
+
+	// Every tx of pool that isn't requeued is either in
+	// b or rejected, and no longer needs to be saved.
+	requeued := make(map[bc.Hash]bool, len(rest))
+	for _, p := range rest {
+		requeued[p.tx.Hash] = true
+	}
+	included := make(map[bc.Hash]bool, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		included[tx.Hash] = true
+	}
+	var (
+		done     []bc.Hash
+		rejected []*bc.Tx
+	)
+	for _, p := range pool {
+		if !requeued[p.tx.Hash] {
+			done = append(done, p.tx.Hash)
+			if !included[p.tx.Hash] {
+				rejected = append(rejected, p.tx)
+			}
+		}
+	}
+	// The rejection log is only informational;
+	// failing to write it doesn't stop the block.
+	err = g.recordRejections(ctx, b, s, rejected)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+
+	if signed {
+		err = g.commitSignedBlock(ctx, b, s)
+	} else {
+		if len(b.Transactions) == 0 {
+			return deletePendingTxs(ctx, g.db, done) // don't bother making an empty block
+		}
+		err = savePendingBlock(ctx, g.db, b)
+		if err != nil {
+			return err
+		}
+		err = g.commitBlock(ctx, b, s)
 	}
-	err = savePendingBlock(ctx, g.db, b)
 	if err != nil {
 		return err
 	}
-	return g.commitBlock(ctx, b, s)
+	// The caps are either committed in b or refused.
+	err = deletePendingSupplyCaps(ctx, g.db, append(caps, refused...))
+	if err != nil {
+		return err
+	}
+	return deletePendingTxs(ctx, g.db, done)
+}
+
+// blockStoppedEarly reports whether b may have stopped short of
+// txs because it reached the chain's maximum block cost. If it
+// didn't, the txs following its last tx were all rejected, and
+// requeueing them only gives them a second try.
+func (g *Generator) blockStoppedEarly(b *bc.Block, txs []*bc.Tx) bool {
+	return g.chain.MaxBlockCost > 0 && len(b.Transactions) > 0 && len(b.Transactions) < len(txs)
+}
+
+// leftovers returns the txs of pool that a full block left
+// unconsidered: those following, in txs, the last tx of the
+// block. They are returned in the order of txs.
+func leftovers(pool []pendingTx, txs, included []*bc.Tx) []pendingTx {
+	last := included[len(included)-1].Hash
+	i := 0
+	for i < len(txs) && txs[i].Hash != last {
+		i++
+	}
+	submitters := make(map[bc.Hash]string, len(pool))
+	for _, p := range pool {
+		submitters[p.tx.Hash] = p.submitter
+	}
+	var rest []pendingTx
+	for _, tx := range txs[i+1:] {
+		rest = append(rest, pendingTx{tx: tx, submitter: submitters[tx.Hash]})
+	}
+	return rest
+}
+
+// badSupplyCaps returns the caps that can't be applied to
+// snapshot because they are below the amount of their asset
+// already issued. Issued amounts only grow, so they never can be.
+func badSupplyCaps(snapshot *state.Snapshot, caps []bc.SupplyCap) []bc.SupplyCap {
+	var bad []bc.SupplyCap
+	for _, c := range caps {
+		// Apply each cap to a scratch snapshot
+		// holding only what it reads.
+		scratch := &state.Snapshot{Issued: snapshot.Issued, SupplyCaps: state.SupplyCaps{}}
+		if sc, ok := snapshot.SupplyCaps[c.AssetID]; ok {
+			scratch.SupplyCaps[c.AssetID] = sc
+		}
+		if validation.ApplySupplyCaps(scratch, []bc.SupplyCap{c}) != nil {
+			bad = append(bad, c)
+		}
+	}
+	return bad
 }
 
 func (g *Generator) commitBlock(ctx context.Context, b *bc.Block, s *state.Snapshot) error {
@@ -68,8 +245,13 @@ func (g *Generator) commitBlock(ctx context.Context, b *bc.Block, s *state.Snaps
 	if err != nil {
 		return errors.Wrap(err, "sign")
 	}
+	return g.commitSignedBlock(ctx, b, s)
+}
 
-	err = g.chain.CommitBlock(ctx, b, s)
+// commitSignedBlock commits b, which already
+// has its signatures, to the blockchain.
+func (g *Generator) commitSignedBlock(ctx context.Context, b *bc.Block, s *state.Snapshot) error {
+	err := g.chain.CommitBlock(ctx, b, s)
 	if err != nil {
 		return errors.Wrap(err, "commit")
 	}
@@ -99,9 +281,10 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 
 	goodSigs := make([][]byte, len(pubkeys))
 	replies := make([][]byte, len(g.signers))
+	errs := make([]error, len(g.signers))
 	done := make(chan int, len(g.signers))
 	for i, signer := range g.signers {
-		go getSig(ctx, signer, b, &replies[i], i, done)
+		go getSig(ctx, signer, b, &replies[i], &errs[i], i, done)
 	}
 
 	nready := 0
@@ -120,6 +303,12 @@ func (g *Generator) getAndAddBlockSignatures(ctx context.Context, b, prevBlock *
 	}
 
 	if nready < quorum {
+		// Every signer has replied.
+		for _, err := range errs {
+			if errors.Root(err) == blocksigner.ErrUnapprovedSupplyCap {
+				return errors.Wrapf(err, "got %d of %d needed signatures", nready, quorum)
+			}
+		}
 		return fmt.Errorf("got %d of %d needed signatures", nready, quorum)
 	}
 	b.Witness = nonNilSigs(goodSigs)
@@ -135,12 +324,13 @@ func indexKey(keys []ed25519.PublicKey, msg, sig []byte) int {
 	return -1
 }
 
-func getSig(ctx context.Context, signer BlockSigner, b *bc.Block, sig *[]byte, i int, done chan int) {
+func getSig(ctx context.Context, signer BlockSigner, b *bc.Block, sig *[]byte, errp *error, i int, done chan int) {
 	var err error
 	*sig, err = signer.SignBlock(ctx, b)
 	if err != nil && ctx.Err() != context.Canceled {
 		log.Write(ctx, "error", err, "signer", signer)
 	}
+	*errp = err
 	done <- i
 }
 
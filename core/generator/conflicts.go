@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+// Kinds of Conflict.
+const (
+	// ConflictSpentOutput is a spend of an output that isn't
+	// in the blockchain state: it was spent, or never existed.
+	ConflictSpentOutput = "spent_output"
+
+	// ConflictPendingSpend is a spend of an output that a
+	// different tx in the pending pool also spends.
+	ConflictPendingSpend = "pending_spend"
+
+	// ConflictDuplicateIssuance is an issuance whose nonce
+	// was already used in a recent block.
+	ConflictDuplicateIssuance = "duplicate_issuance"
+
+	// ConflictPendingIssuance is an issuance whose nonce is
+	// used by a different tx in the pending pool.
+	ConflictPendingIssuance = "pending_issuance"
+)
+
+// A Conflict is an input of a transaction that
+// keeps it out of the generator's next block.
+type Conflict struct {
+	InputIndex   int          `json:"input_index"`
+	Kind         string       `json:"kind"`
+	Outpoint     *bc.Outpoint `json:"outpoint,omitempty"`
+	IssuanceHash *bc.Hash     `json:"issuance_hash,omitempty"`
+
+	// PendingTxID is the tx in the pending pool
+	// it conflicts with, for the pending kinds.
+	PendingTxID *bc.Hash `json:"pending_transaction_id,omitempty"`
+}
+
+// A ConflictReport says whether a transaction
+// can go in the generator's next block.
+type ConflictReport struct {
+	// Pending is set if the transaction itself
+	// is already in the pending pool.
+	Pending   bool        `json:"pending"`
+	Conflicts []*Conflict `json:"conflicts"`
+}
+
+// CheckConflicts checks the inputs of tx against the
+// pending pool and the latest blockchain state, and reports
+// the spent outputs and reused issuance nonces that would
+// keep it out of a block. It doesn't validate tx otherwise.
+//
+// A tx that is already in a block conflicts with that
+// block, as the outputs it spends are no longer there.
+func (g *Generator) CheckConflicts(tx *bc.Tx) *ConflictReport {
+	_, snapshot := g.chain.State()
+
+	g.mu.Lock()
+	report := &ConflictReport{Pending: g.poolHashes[tx.Hash], Conflicts: []*Conflict{}}
+	spentBy := make(map[bc.Outpoint]bc.Hash)
+	issuedBy := make(map[bc.Hash]bc.Hash)
+	for _, p := range g.pool {
+		if p.tx.Hash == tx.Hash {
+			continue
+		}
+		for i, in := range p.tx.Inputs {
+			if isIssuance(in) {
+				if h, ok := nonceIssuanceHash(p.tx, i); ok {
+					issuedBy[h] = p.tx.Hash
+				}
+				continue
+			}
+			spentBy[in.Outpoint()] = p.tx.Hash
+		}
+	}
+	g.mu.Unlock()
+
+	for i, in := range tx.Inputs {
+		if isIssuance(in) {
+			h, ok := nonceIssuanceHash(tx, i)
+			if !ok {
+				continue
+			}
+			if snapshot != nil {
+				if _, ok := snapshot.Issuances[h]; ok {
+					report.add(&Conflict{InputIndex: i, Kind: ConflictDuplicateIssuance, IssuanceHash: &h})
+					continue
+				}
+			}
+			if other, ok := issuedBy[h]; ok {
+				report.add(&Conflict{InputIndex: i, Kind: ConflictPendingIssuance, IssuanceHash: &h, PendingTxID: &other})
+			}
+			continue
+		}
+
+		o := in.Outpoint()
+		if snapshot != nil {
+			k, val := state.OutputTreeItem(state.Prevout(in))
+			if !snapshot.Tree.Contains(k, val) {
+				report.add(&Conflict{InputIndex: i, Kind: ConflictSpentOutput, Outpoint: &o})
+				continue
+			}
+		}
+		if other, ok := spentBy[o]; ok {
+			report.add(&Conflict{InputIndex: i, Kind: ConflictPendingSpend, Outpoint: &o, PendingTxID: &other})
+		}
+	}
+	return report
+}
+
+func (r *ConflictReport) add(c *Conflict) {
+	r.Conflicts = append(r.Conflicts, c)
+}
+
+func isIssuance(in *bc.TxInput) bool {
+	_, ok := in.TypedInput.(*bc.IssuanceInput)
+	return ok
+}
+
+// nonceIssuanceHash returns the issuance hash of the
+// issuance input i of tx, if it has a nonce. Only
+// issuances with nonces can conflict.
+func nonceIssuanceHash(tx *bc.Tx, i int) (bc.Hash, bool) {
+	ii, ok := tx.Inputs[i].TypedInput.(*bc.IssuanceInput)
+	if !ok || tx.Inputs[i].AssetVersion != 1 || len(ii.Nonce) == 0 {
+		return bc.Hash{}, false
+	}
+	h, err := tx.IssuanceHash(i)
+	if err != nil {
+		return bc.Hash{}, false
+	}
+	return h, true
+}
@@ -34,8 +34,13 @@ type Generator struct {
 	signers []BlockSigner
 
 	mu         sync.Mutex
-	pool       []*bc.Tx // in topological order
+	pool       []pendingTx // in topological order
 	poolHashes map[bc.Hash]bool
+	poolTree   *validation.MerkleTree // of pool, so we needn't rehash it in makeBlock
+
+	// ordering is the pending pool ordering used for the
+	// last block, kept in case it can't be reloaded.
+	ordering Ordering
 
 	// latestBlock and latestSnapshot are current as long as this
 	// process remains the leader process. If the process is demoted,
@@ -56,6 +61,8 @@ func New(
 		chain:      c,
 		signers:    s,
 		poolHashes: make(map[bc.Hash]bool),
+		poolTree:   new(validation.MerkleTree),
+		ordering:   Ordering{Policy: OrderFIFO},
 	}
 }
 
@@ -65,25 +72,105 @@ func (g *Generator) PendingTxs() []*bc.Tx {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	txs := make([]*bc.Tx, len(g.pool))
-	copy(txs, g.pool)
+	txs := make([]*bc.Tx, 0, len(g.pool))
+	for _, p := range g.pool {
+		txs = append(txs, p.tx)
+	}
 	return txs
 }
 
 // Submit adds a new pending tx to the pending tx pool.
+// The tx is attributed to the submitter named in ctx,
+// if any; see NewSubmitterContext.
 func (g *Generator) Submit(ctx context.Context, tx *bc.Tx) error {
 	g.mu.Lock()
-	defer g.mu.Unlock()
+	dup := g.poolHashes[tx.Hash]
+	g.mu.Unlock()
+	if dup {
+		return nil
+	}
 
+	// Save the tx before accepting it, so it isn't lost if
+	// this process exits before the tx is in a block.
+	p := pendingTx{tx: tx, submitter: submitterFromContext(ctx)}
+	err := savePendingTx(ctx, g.db, p)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.poolHashes[tx.Hash] {
 		return nil
 	}
-
 	g.poolHashes[tx.Hash] = true
-	g.pool = append(g.pool, tx)
+	g.pool = append(g.pool, p)
+	g.poolTree.Append(tx)
 	return nil
 }
 
+// Evict removes the tx with the given hash from the pending
+// pool, so it won't be included in a block, and reports
+// whether it was in the pool. A block already being made
+// when Evict is called may still include it.
+func (g *Generator) Evict(ctx context.Context, hash bc.Hash) (bool, error) {
+	err := deletePendingTxs(ctx, g.db, []bc.Hash{hash})
+	if err != nil {
+		return false, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.poolHashes[hash] {
+		return false, nil
+	}
+	pool := make([]pendingTx, 0, len(g.pool)-1)
+	g.poolTree = new(validation.MerkleTree)
+	for _, p := range g.pool {
+		if p.tx.Hash != hash {
+			pool = append(pool, p)
+			g.poolTree.Append(p.tx)
+		}
+	}
+	g.pool = pool
+	delete(g.poolHashes, hash)
+	return true, nil
+}
+
+// requeue returns txs left out of a full block to the
+// front of the pending pool, ahead of txs that arrived
+// while the block was being made.
+func (g *Generator) requeue(txs []pendingTx) {
+	if len(txs) == 0 {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pool := txs
+	for _, p := range g.pool {
+		if !containsTx(txs, p.tx.Hash) {
+			pool = append(pool, p)
+		}
+	}
+	g.pool = pool
+	g.poolHashes = make(map[bc.Hash]bool, len(pool))
+	g.poolTree = new(validation.MerkleTree)
+	for _, p := range pool {
+		g.poolHashes[p.tx.Hash] = true
+		g.poolTree.Append(p.tx)
+	}
+}
+
+func containsTx(txs []pendingTx, hash bc.Hash) bool {
+	for _, p := range txs {
+		if p.tx.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
 // Generate runs in a loop, making one new block
 // every block period. It returns when its context
 // is canceled.
@@ -118,11 +205,22 @@ func (g *Generator) Generate(
 
 		// g.commitBlock will update g.latestBlock and g.latestSnapshot.
 		err = g.commitBlock(ctx, b, s)
-		if err != nil {
+		if err != nil && len(b.SupplyCaps) > 0 {
+			// The signers may have refused the caps.
+			// Make a new block instead, as makeBlock would.
+			log.Error(ctx, err, "recovering block with supply caps")
+		} else if err != nil {
 			log.Fatal(ctx, log.KeyError, err)
 		}
 	}
 
+	// Restore the pending txs that the previous
+	// leader accepted but didn't put in a block.
+	err = g.recoverPool(ctx, g.latestBlock)
+	if err != nil {
+		log.Fatal(ctx, log.KeyError, err)
+	}
+
 	ticks := time.Tick(period)
 	for {
 		select {
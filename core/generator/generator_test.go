@@ -2,13 +2,17 @@ package generator
 
 import (
 	"context"
+	"reflect"
 	"testing"
 	"time"
 
+	"chain/core/blocksigner"
 	"chain/crypto/ed25519"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol"
 	"chain/protocol/bc"
+	"chain/protocol/memstore"
 	"chain/protocol/prottest"
 	"chain/protocol/state"
 	"chain/protocol/validation"
@@ -111,6 +115,37 @@ func TestGetAndAddBlockSignaturesInitialBlock(t *testing.T) {
 	}
 }
 
+func TestPreviewBlock(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+
+	// A tx with no inputs can't go in a block.
+	tx := bc.NewTx(bc.TxData{Version: 1, MinTime: 1})
+	g := New(c, nil, dbtx)
+	err := g.Submit(ctx, tx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	p, err := g.PreviewBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if p.Height != c.Height()+1 || p.PendingCount != 1 {
+		t.Errorf("preview height %d, pending count %d, want %d, 1", p.Height, p.PendingCount, c.Height()+1)
+	}
+	if len(p.TransactionIDs) != 0 || len(p.Deferred) != 0 {
+		t.Errorf("preview includes %v and defers %v, want neither", p.TransactionIDs, p.Deferred)
+	}
+	if len(p.Rejected) != 1 || p.Rejected[0].TxHash != tx.Hash {
+		t.Errorf("preview rejects %+v, want only the tx", p.Rejected)
+	}
+	if got := g.PendingTxs(); len(got) != 1 || got[0].Hash != tx.Hash {
+		t.Errorf("pending txs after preview = %v, want the tx", got)
+	}
+}
+
 type testSigner struct {
 	pubKey  ed25519.PublicKey
 	privKey ed25519.PrivateKey
@@ -124,3 +159,317 @@ func (s testSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error)
 func (s testSigner) String() string {
 	return "test-signer"
 }
+
+func TestRecoverPool(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+
+	tx1 := bc.NewTx(bc.TxData{Version: 1, MinTime: 1})
+	tx2 := bc.NewTx(bc.TxData{Version: 1, MinTime: 2})
+	g := New(c, nil, dbtx)
+	for _, tx := range []*bc.Tx{tx1, tx2, tx1} {
+		err := g.Submit(NewSubmitterContext(ctx, "alice"), tx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	// A new leader recovers the pool, less the
+	// txs of a block the old leader committed.
+	g2 := New(c, nil, dbtx)
+	err := g2.recoverPool(ctx, &bc.Block{Transactions: []*bc.Tx{tx1}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(g2.pool) != 1 || g2.pool[0].tx.Hash != tx2.Hash || g2.pool[0].submitter != "alice" {
+		t.Errorf("recovered pool = %+v, want only tx2 from alice", g2.pool)
+	}
+	saved, err := loadPendingTxs(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(saved) != 1 || saved[0].tx.Hash != tx2.Hash {
+		t.Errorf("saved pool = %+v, want only tx2", saved)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+
+	tx1 := bc.NewTx(bc.TxData{Version: 1, MinTime: 1})
+	tx2 := bc.NewTx(bc.TxData{Version: 1, MinTime: 2})
+	g := New(c, nil, dbtx)
+	for _, tx := range []*bc.Tx{tx1, tx2} {
+		err := g.Submit(ctx, tx)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+
+	evicted, err := g.Evict(ctx, tx1.Hash)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !evicted {
+		t.Error("Evict(tx1) = false want true")
+	}
+	if got := g.PendingTxs(); len(got) != 1 || got[0].Hash != tx2.Hash {
+		t.Errorf("pending txs = %v, want only tx2", got)
+	}
+	if g.poolHashes[tx1.Hash] {
+		t.Error("tx1 still in pool hashes")
+	}
+	saved, err := loadPendingTxs(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(saved) != 1 || saved[0].tx.Hash != tx2.Hash {
+		t.Errorf("saved pool = %+v, want only tx2", saved)
+	}
+
+	evicted, err = g.Evict(ctx, tx1.Hash)
+	if err != nil || evicted {
+		t.Errorf("Evict(tx1) again = %v, %v want false, nil", evicted, err)
+	}
+}
+
+func TestProposeSupplyCaps(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+	gold, silver := bc.AssetID{1}, bc.AssetID{2}
+
+	err := ProposeSupplyCaps(ctx, dbtx, []bc.SupplyCap{{AssetID: gold, Amount: 100}, {AssetID: silver, Amount: 5}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = ProposeSupplyCaps(ctx, dbtx, []bc.SupplyCap{{AssetID: gold, Amount: 200}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err := PendingSupplyCaps(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := []bc.SupplyCap{{AssetID: gold, Amount: 200}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pending supply caps = %+v want %+v", got, want)
+	}
+
+	// Committing an older proposal keeps the newer one.
+	err = deletePendingSupplyCaps(ctx, dbtx, []bc.SupplyCap{{AssetID: gold, Amount: 100}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = PendingSupplyCaps(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("after deleting an older cap, pending supply caps = %+v want %+v", got, want)
+	}
+
+	err = ProposeSupplyCaps(ctx, dbtx, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	got, err = PendingSupplyCaps(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("after withdrawing, pending supply caps = %+v want none", got)
+	}
+}
+
+// capRefusingSigner signs only blocks that set no supply caps,
+// like a signer that hasn't approved a proposed cap.
+type capRefusingSigner struct {
+	testSigner
+}
+
+func (s capRefusingSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
+	if len(b.SupplyCaps) > 0 {
+		return nil, errors.Wrap(blocksigner.ErrUnapprovedSupplyCap)
+	}
+	return s.testSigner.SignBlock(ctx, b)
+}
+
+func TestMakeBlockCapsRefused(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b1, err := protocol.NewInitialBlock([]ed25519.PublicKey{pubKey}, 1, time.Now())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c, err := protocol.NewChain(ctx, b1.Hash(), memstore.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.CommitBlock(ctx, b1, state.Empty())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	err = ProposeSupplyCaps(ctx, dbtx, []bc.SupplyCap{{AssetID: bc.AssetID{1}, Amount: 100}})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	g := New(c, []BlockSigner{capRefusingSigner{testSigner{pubKey, privKey}}}, dbtx)
+	g.latestBlock, g.latestSnapshot = c.State()
+	err = g.makeBlock(ctx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// The block is made without the refused caps,
+	// and the proposal is dropped.
+	if c.Height() != 2 {
+		t.Fatalf("height = %d want 2", c.Height())
+	}
+	b2, err := c.GetBlock(ctx, 2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(b2.SupplyCaps) != 0 {
+		t.Errorf("block caps = %+v want none", b2.SupplyCaps)
+	}
+	got, err := PendingSupplyCaps(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got) != 0 {
+		t.Errorf("pending supply caps = %+v want none", got)
+	}
+}
+
+// unreachableSigner fails to sign every block,
+// like a signer that can't be reached.
+type unreachableSigner struct{}
+
+func (unreachableSigner) SignBlock(ctx context.Context, b *bc.Block) ([]byte, error) {
+	return nil, errors.New("connection refused")
+}
+
+func (unreachableSigner) String() string {
+	return "unreachable-signer"
+}
+
+func TestMakeBlockCapsUnsigned(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+
+	pubKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b1, err := protocol.NewInitialBlock([]ed25519.PublicKey{pubKey}, 1, time.Now())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	c, err := protocol.NewChain(ctx, b1.Hash(), memstore.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.CommitBlock(ctx, b1, state.Empty())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	caps := []bc.SupplyCap{{AssetID: bc.AssetID{1}, Amount: 100}}
+	err = ProposeSupplyCaps(ctx, dbtx, caps)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A signer that fails for another reason hasn't
+	// refused the caps, so they're kept for the next block.
+	g := New(c, []BlockSigner{unreachableSigner{}}, dbtx)
+	g.latestBlock, g.latestSnapshot = c.State()
+	err = g.makeBlock(ctx)
+	if err == nil {
+		t.Fatal("makeBlock succeeded without signatures")
+	}
+	if c.Height() != 1 {
+		t.Errorf("height = %d want 1", c.Height())
+	}
+	got, err := PendingSupplyCaps(ctx, dbtx)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !reflect.DeepEqual(got, caps) {
+		t.Errorf("pending supply caps = %+v want %+v", got, caps)
+	}
+}
+
+func TestCheckConflicts(t *testing.T) {
+	c := prottest.NewChain(t)
+	g := New(c, nil, nil)
+
+	// NewIssuanceTx leaves the hash unset.
+	iss := bc.NewTx(prottest.NewIssuanceTx(t, c).TxData)
+	prottest.MakeBlock(t, c, []*bc.Tx{iss})
+	out := iss.Outputs[0]
+	spend := func(ref byte) *bc.Tx {
+		return bc.NewTx(bc.TxData{
+			Version:       1,
+			Inputs:        []*bc.TxInput{bc.NewSpendInput(iss.Hash, 0, nil, out.AssetID, out.Amount, out.ControlProgram, nil)},
+			Outputs:       []*bc.TxOutput{bc.NewTxOutput(out.AssetID, out.Amount, []byte{1}, nil)},
+			ReferenceData: []byte{ref},
+		})
+	}
+	spend1, spend2 := spend(1), spend(2)
+	iss2 := bc.NewTx(prottest.NewIssuanceTx(t, c).TxData)
+	iss2Copy := iss2.TxData
+	iss2Copy.ReferenceData = []byte{1}
+	for _, tx := range []*bc.Tx{spend1, iss2} {
+		g.pool = append(g.pool, pendingTx{tx: tx})
+		g.poolHashes[tx.Hash] = true
+	}
+
+	unknown := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{9}, 0, nil, out.AssetID, 1, nil, nil)},
+	})
+
+	cases := []struct {
+		tx          *bc.Tx
+		wantPending bool
+		wantKind    string
+		wantOther   *bc.Hash
+	}{
+		{tx: spend1, wantPending: true},
+		{tx: spend2, wantKind: ConflictPendingSpend, wantOther: &spend1.Hash},
+		{tx: iss, wantKind: ConflictDuplicateIssuance},
+		{tx: bc.NewTx(iss2Copy), wantKind: ConflictPendingIssuance, wantOther: &iss2.Hash},
+		{tx: unknown, wantKind: ConflictSpentOutput},
+	}
+	for i, c := range cases {
+		r := g.CheckConflicts(c.tx)
+		if r.Pending != c.wantPending {
+			t.Errorf("case %d: pending = %t want %t", i, r.Pending, c.wantPending)
+		}
+		if c.wantKind == "" {
+			if len(r.Conflicts) != 0 {
+				t.Errorf("case %d: got conflicts %+v, want none", i, r.Conflicts)
+			}
+			continue
+		}
+		if len(r.Conflicts) != 1 {
+			t.Errorf("case %d: got %d conflicts, want 1", i, len(r.Conflicts))
+			continue
+		}
+		got := r.Conflicts[0]
+		if got.Kind != c.wantKind || !reflect.DeepEqual(got.PendingTxID, c.wantOther) {
+			t.Errorf("case %d: conflict = %s with %v, want %s with %v", i, got.Kind, got.PendingTxID, c.wantKind, c.wantOther)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// Ordering policies for the pending pool.
+const (
+	// OrderFIFO takes transactions in the order they arrived.
+	OrderFIFO = "fifo"
+
+	// OrderPriority takes transactions from submitters in
+	// higher tiers first, and in arrival order within a tier.
+	OrderPriority = "priority"
+
+	// OrderRoundRobin takes one transaction from each
+	// submitter in turn, so a burst from one submitter
+	// doesn't hold back the others.
+	OrderRoundRobin = "round_robin"
+
+	// OrderFee takes transactions paying higher fees first,
+	// and in arrival order among those paying the same fee.
+	// A transaction's fee is the amount of the fee asset it
+	// sends to the fee program; see vmutil.FeeProgram.
+	OrderFee = "fee"
+)
+
+// ErrBadOrdering is returned by SetOrdering when
+// the ordering has an unknown policy.
+var ErrBadOrdering = errors.New("invalid pending pool ordering")
+
+// Ordering configures the order in which pending transactions
+// are considered for a block. Once a block is full, the rest
+// wait for the next one.
+//
+// Submitters are identified by the ID of the access token
+// used to submit a transaction; see NewSubmitterContext.
+type Ordering struct {
+	Policy string `json:"policy"`
+
+	// Tiers gives the priority tier of each submitter for
+	// OrderPriority. Submitters not listed are in tier 0.
+	Tiers map[string]int `json:"tiers,omitempty"`
+
+	// FeeAssetID is the asset in which fees
+	// are paid for OrderFee.
+	FeeAssetID *bc.AssetID `json:"fee_asset_id,omitempty"`
+}
+
+// SetOrdering saves the ordering of the pending pool. The
+// generator applies it from the next block it makes.
+func SetOrdering(ctx context.Context, db pg.DB, o Ordering) error {
+	switch o.Policy {
+	case OrderFIFO, OrderPriority, OrderRoundRobin:
+	case OrderFee:
+		if o.FeeAssetID == nil {
+			return errors.WithDetail(ErrBadOrdering, "fee policy requires fee_asset_id")
+		}
+	default:
+		return errors.WithDetailf(ErrBadOrdering, "unknown policy %q", o.Policy)
+	}
+	tiers, err := json.Marshal(o.Tiers)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	const q = `
+		INSERT INTO generator_ordering (policy, tiers, fee_asset_id) VALUES ($1, $2, $3)
+		ON CONFLICT (singleton) DO UPDATE
+		SET policy = excluded.policy, tiers = excluded.tiers, fee_asset_id = excluded.fee_asset_id
+	`
+	_, err = db.Exec(ctx, q, o.Policy, tiers, o.FeeAssetID)
+	return errors.Wrap(err, "saving generator ordering")
+}
+
+// GetOrdering returns the ordering of the pending pool.
+// It is OrderFIFO unless it has been set.
+func GetOrdering(ctx context.Context, db pg.DB) (Ordering, error) {
+	o := Ordering{Policy: OrderFIFO}
+	var tiers []byte
+	const q = `SELECT policy, tiers, fee_asset_id FROM generator_ordering`
+	err := db.QueryRow(ctx, q).Scan(&o.Policy, &tiers, &o.FeeAssetID)
+	if err == sql.ErrNoRows {
+		return o, nil
+	}
+	if err != nil {
+		return o, errors.Wrap(err, "loading generator ordering")
+	}
+	err = json.Unmarshal(tiers, &o.Tiers)
+	return o, errors.Wrap(err, "decoding generator ordering tiers")
+}
+
+type submitterKey struct{}
+
+// NewSubmitterContext returns a context carrying the name of
+// the submitter of the transactions passed to Submit with it.
+func NewSubmitterContext(ctx context.Context, submitter string) context.Context {
+	return context.WithValue(ctx, submitterKey{}, submitter)
+}
+
+func submitterFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(submitterKey{}).(string)
+	return s
+}
+
+// pendingTx is a transaction in the pending pool.
+type pendingTx struct {
+	tx        *bc.Tx
+	submitter string
+}
+
+// order returns the transactions of pool, which is in arrival
+// order, in the order given by o. A transaction that spends an
+// output of another pending transaction always follows it.
+func (o Ordering) order(pool []pendingTx) []*bc.Tx {
+	ordered := make([]pendingTx, len(pool))
+	copy(ordered, pool)
+
+	switch o.Policy {
+	case OrderPriority:
+		sort.Stable(byTier{ordered, o.Tiers})
+	case OrderRoundRobin:
+		// Give each transaction a turn, its position among
+		// its submitter's transactions, and take the turns
+		// in order, each in arrival order.
+		turns := make([]int, len(ordered))
+		seen := make(map[string]int)
+		for i, p := range ordered {
+			turns[i] = seen[p.submitter]
+			seen[p.submitter]++
+		}
+		sort.Stable(byTurn{ordered, turns})
+	case OrderFee:
+		fees := make([]uint64, len(ordered))
+		if o.FeeAssetID != nil {
+			for i, p := range ordered {
+				fees[i] = Fee(p.tx, *o.FeeAssetID)
+			}
+		}
+		sort.Stable(byFee{ordered, fees})
+	}
+
+	// Move each transaction after any pending
+	// transaction whose outputs it spends.
+	byHash := make(map[bc.Hash]*bc.Tx, len(ordered))
+	for _, p := range ordered {
+		byHash[p.tx.Hash] = p.tx
+	}
+	done := make(map[bc.Hash]bool, len(ordered))
+	txs := make([]*bc.Tx, 0, len(ordered))
+	var visit func(tx *bc.Tx)
+	visit = func(tx *bc.Tx) {
+		if done[tx.Hash] {
+			return
+		}
+		done[tx.Hash] = true
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			if prev := byHash[in.Outpoint().Hash]; prev != nil {
+				visit(prev)
+			}
+		}
+		txs = append(txs, tx)
+	}
+	for _, p := range ordered {
+		visit(p.tx)
+	}
+	return txs
+}
+
+type byTier struct {
+	txs   []pendingTx
+	tiers map[string]int
+}
+
+func (a byTier) Len() int      { return len(a.txs) }
+func (a byTier) Swap(i, j int) { a.txs[i], a.txs[j] = a.txs[j], a.txs[i] }
+func (a byTier) Less(i, j int) bool {
+	return a.tiers[a.txs[i].submitter] > a.tiers[a.txs[j].submitter]
+}
+
+type byTurn struct {
+	txs   []pendingTx
+	turns []int
+}
+
+func (a byTurn) Len() int { return len(a.txs) }
+func (a byTurn) Swap(i, j int) {
+	a.txs[i], a.txs[j] = a.txs[j], a.txs[i]
+	a.turns[i], a.turns[j] = a.turns[j], a.turns[i]
+}
+func (a byTurn) Less(i, j int) bool { return a.turns[i] < a.turns[j] }
+
+type byFee struct {
+	txs  []pendingTx
+	fees []uint64
+}
+
+func (a byFee) Len() int { return len(a.txs) }
+func (a byFee) Swap(i, j int) {
+	a.txs[i], a.txs[j] = a.txs[j], a.txs[i]
+	a.fees[i], a.fees[j] = a.fees[j], a.fees[i]
+}
+func (a byFee) Less(i, j int) bool { return a.fees[i] > a.fees[j] }
+
+// Fee returns the amount of assetID that
+// tx pays as a fee to the fee program.
+func Fee(tx *bc.Tx, assetID bc.AssetID) uint64 {
+	var fee uint64
+	for _, out := range tx.Outputs {
+		if out.AssetID == assetID && vmutil.IsFeeProgram(out.ControlProgram) {
+			fee += out.Amount
+		}
+	}
+	return fee
+}
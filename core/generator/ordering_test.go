@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+func TestOrder(t *testing.T) {
+	// a1, a2 and a3 come from submitter a; b1 and b2 from b.
+	// a3 spends an output of b2.
+	newTx := func(refData string, spends ...*bc.Tx) *bc.Tx {
+		data := bc.TxData{Version: 1, ReferenceData: []byte(refData)}
+		for _, prev := range spends {
+			data.Inputs = append(data.Inputs, bc.NewSpendInput(prev.Hash, 0, nil, bc.AssetID{}, 1, nil, nil))
+		}
+		return bc.NewTx(data)
+	}
+	a1, a2, b1 := newTx("a1"), newTx("a2"), newTx("b1")
+	b2 := newTx("b2")
+	a3 := newTx("a3", b2)
+	pool := []pendingTx{{a1, "a"}, {a2, "a"}, {b1, "b"}, {a3, "a"}, {b2, "b"}}
+
+	cases := []struct {
+		ordering Ordering
+		want     []*bc.Tx
+	}{
+		{Ordering{Policy: OrderFIFO}, []*bc.Tx{a1, a2, b1, b2, a3}},
+		{Ordering{Policy: OrderPriority, Tiers: map[string]int{"b": 1}}, []*bc.Tx{b1, b2, a1, a2, a3}},
+		{Ordering{Policy: OrderRoundRobin}, []*bc.Tx{a1, b1, a2, b2, a3}},
+	}
+	for _, c := range cases {
+		got := c.ordering.order(pool)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.ordering.Policy, hashes(got), hashes(c.want))
+		}
+	}
+}
+
+func TestOrderFee(t *testing.T) {
+	feeAsset := bc.AssetID{1}
+	newTx := func(refData string, outs ...*bc.TxOutput) *bc.Tx {
+		return bc.NewTx(bc.TxData{Version: 1, ReferenceData: []byte(refData), Outputs: outs})
+	}
+	none := newTx("none")
+	low := newTx("low", bc.NewTxOutput(feeAsset, 1, vmutil.FeeProgram(), nil))
+	high := newTx("high",
+		bc.NewTxOutput(feeAsset, 2, vmutil.FeeProgram(), nil),
+		bc.NewTxOutput(feeAsset, 2, vmutil.FeeProgram(), nil),
+	)
+	other := newTx("other", bc.NewTxOutput(bc.AssetID{2}, 10, vmutil.FeeProgram(), nil))
+	retired := newTx("retired", bc.NewTxOutput(feeAsset, 10, []byte{byte(vm.OP_FAIL)}, nil))
+	pool := []pendingTx{{none, "a"}, {low, "a"}, {other, "b"}, {high, "b"}, {retired, "a"}}
+
+	o := Ordering{Policy: OrderFee, FeeAssetID: &feeAsset}
+	got := o.order(pool)
+	want := []*bc.Tx{high, low, none, other, retired}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", hashes(got), hashes(want))
+	}
+}
+
+func hashes(txs []*bc.Tx) []bc.Hash {
+	var h []bc.Hash
+	for _, tx := range txs {
+		h = append(h, tx.Hash)
+	}
+	return h
+}
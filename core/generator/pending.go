@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+// savePendingTx persists a tx accepted into the pending pool,
+// so that it survives a restart of the generator. Saving a tx
+// that is already saved does nothing.
+func savePendingTx(ctx context.Context, db pg.DB, p pendingTx) error {
+	const q = `
+		INSERT INTO generator_pending_txs (tx_hash, data, submitter) VALUES ($1, $2, $3)
+		ON CONFLICT (tx_hash) DO NOTHING
+	`
+	_, err := db.Exec(ctx, q, p.tx.Hash, p.tx, p.submitter)
+	return errors.Wrap(err, "generator_pending_txs insert query")
+}
+
+// deletePendingTxs removes txs that have left the pending
+// pool, either into a block or because they were rejected.
+func deletePendingTxs(ctx context.Context, db pg.DB, hashes []bc.Hash) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+	byteas := make(pq.ByteaArray, 0, len(hashes))
+	for _, h := range hashes {
+		byteas = append(byteas, h[:])
+	}
+	const q = `DELETE FROM generator_pending_txs WHERE tx_hash = ANY($1)`
+	_, err := db.Exec(ctx, q, byteas)
+	return errors.Wrap(err, "generator_pending_txs delete query")
+}
+
+// loadPendingTxs returns the saved pending txs in the
+// order they were submitted.
+func loadPendingTxs(ctx context.Context, db pg.DB) ([]pendingTx, error) {
+	const q = `SELECT data, submitter FROM generator_pending_txs ORDER BY id`
+	var pool []pendingTx
+	err := pg.ForQueryRows(ctx, db, q, func(data bc.TxData, submitter string) {
+		pool = append(pool, pendingTx{tx: bc.NewTx(data), submitter: submitter})
+	})
+	return pool, errors.Wrap(err, "generator_pending_txs select query")
+}
+
+// recoverPool restores the pending pool saved by an earlier
+// leader process. Txs in latest, which that process may have
+// committed without deleting them, are dropped, as are any
+// duplicates of txs already in the pool.
+func (g *Generator) recoverPool(ctx context.Context, latest *bc.Block) error {
+	saved, err := loadPendingTxs(ctx, g.db)
+	if err != nil {
+		return err
+	}
+	var committed []bc.Hash
+	if latest != nil {
+		for _, tx := range latest.Transactions {
+			committed = append(committed, tx.Hash)
+		}
+	}
+	err = deletePendingTxs(ctx, g.db, committed)
+	if err != nil {
+		return err
+	}
+	inBlock := make(map[bc.Hash]bool, len(committed))
+	for _, h := range committed {
+		inBlock[h] = true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pool := make([]pendingTx, 0, len(saved)+len(g.pool))
+	hashes := make(map[bc.Hash]bool, len(saved)+len(g.pool))
+	for _, p := range append(saved, g.pool...) {
+		if inBlock[p.tx.Hash] || hashes[p.tx.Hash] {
+			continue
+		}
+		hashes[p.tx.Hash] = true
+		pool = append(pool, p)
+	}
+	g.pool = pool
+	g.poolHashes = hashes
+	g.poolTree = new(validation.MerkleTree)
+	for _, p := range pool {
+		g.poolTree.Append(p.tx)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+// A BlockPreview describes the block the generator would
+// make from its pending pool now, without making it.
+type BlockPreview struct {
+	Height      uint64 `json:"height"`
+	TimestampMS uint64 `json:"timestamp_ms"`
+
+	// PendingCount is the number of txs in the pending pool.
+	PendingCount int `json:"pending_count"`
+
+	// TransactionIDs are the txs that would go in
+	// the block, in order.
+	TransactionIDs []bc.Hash `json:"transaction_ids"`
+
+	// Size is the size of the block in bytes, without
+	// signatures, and Cost its validation cost.
+	Size int64           `json:"size"`
+	Cost validation.Cost `json:"cost"`
+
+	// Rejected are the txs the block would leave out and
+	// drop from the pending pool, and why. Reason is empty
+	// for a tx that wasn't rejected on its own, such as one
+	// that conflicts with a tx in the block.
+	Rejected []*Rejection `json:"rejected"`
+
+	// Deferred are the txs a full block would leave
+	// in the pending pool for a later block.
+	Deferred []bc.Hash `json:"deferred"`
+}
+
+// PreviewBlock reports what the next block would contain if
+// the generator made it now from its pending pool, using the
+// current ordering and pending supply caps. It changes neither
+// the pool nor the blockchain.
+func (g *Generator) PreviewBlock(ctx context.Context) (*BlockPreview, error) {
+	o, err := GetOrdering(ctx, g.db)
+	if err != nil {
+		return nil, err
+	}
+	caps, err := PendingSupplyCaps(ctx, g.db)
+	if err != nil {
+		return nil, err
+	}
+	prev, snapshot := g.chain.State()
+	if prev == nil {
+		return nil, errors.New("no initial block")
+	}
+
+	g.mu.Lock()
+	pool := make([]pendingTx, len(g.pool))
+	copy(pool, g.pool)
+	g.mu.Unlock()
+
+	now := time.Now()
+	txs := o.order(pool)
+	b, s, err := g.chain.GenerateBlockFromTree(ctx, prev, snapshot, now, txs, nil, caps)
+	if err != nil {
+		return nil, errors.Wrap(err, "generate")
+	}
+
+	p := &BlockPreview{
+		Height:         b.Height,
+		TimestampMS:    b.TimestampMS,
+		PendingCount:   len(pool),
+		TransactionIDs: []bc.Hash{},
+		Rejected:       []*Rejection{},
+		Deferred:       []bc.Hash{},
+	}
+	p.Size, err = b.WriteTo(ioutil.Discard)
+	if err != nil {
+		return nil, errors.Wrap(err, "measuring block")
+	}
+	p.Cost, err = g.chain.BlockCost(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "costing block")
+	}
+
+	included := make(map[bc.Hash]bool, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		included[tx.Hash] = true
+		p.TransactionIDs = append(p.TransactionIDs, tx.Hash)
+	}
+	var rest []pendingTx
+	if len(b.Transactions) >= protocol.MaxBlockTxs || g.blockStoppedEarly(b, txs) {
+		rest = leftovers(pool, txs, b.Transactions)
+	}
+	deferred := make(map[bc.Hash]bool, len(rest))
+	for _, r := range rest {
+		deferred[r.tx.Hash] = true
+	}
+	for _, tx := range txs {
+		if included[tx.Hash] {
+			continue
+		}
+		if deferred[tx.Hash] {
+			p.Deferred = append(p.Deferred, tx.Hash)
+			continue
+		}
+		r := g.rejection(ctx, b, s, tx)
+		if r == nil {
+			// Dropped for no reason of its own,
+			// as makeBlock would drop it.
+			r = &Rejection{TxHash: tx.Hash, BlockHeight: b.Height}
+		}
+		r.RejectedAt = now
+		p.Rejected = append(p.Rejected, r)
+	}
+	return p, nil
+}
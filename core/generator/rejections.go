@@ -0,0 +1,129 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/validation"
+)
+
+// A Rejection is a transaction that left the pending
+// pool without going in a block, and why.
+type Rejection struct {
+	TxHash bc.Hash `json:"transaction_id"`
+
+	// Reason is one of the validation.Reason constants.
+	Reason string `json:"reason"`
+	Detail string `json:"detail"`
+
+	// InputIndex and Outpoint are set for
+	// the reasons that blame an input.
+	InputIndex *int         `json:"input_index,omitempty"`
+	Outpoint   *bc.Outpoint `json:"outpoint,omitempty"`
+
+	BlockHeight uint64    `json:"block_height"`
+	RejectedAt  time.Time `json:"rejected_at"`
+}
+
+// recordRejections saves why each of txs, left out of b,
+// was rejected. Snapshot is the state after b.
+func (g *Generator) recordRejections(ctx context.Context, b *bc.Block, snapshot *state.Snapshot, txs []*bc.Tx) error {
+	const q = `
+		INSERT INTO generator_rejections
+			(tx_hash, reason, detail, input_index, outpoint_tx_hash, outpoint_index, block_height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tx_hash) DO UPDATE
+		SET reason = excluded.reason, detail = excluded.detail, input_index = excluded.input_index,
+			outpoint_tx_hash = excluded.outpoint_tx_hash, outpoint_index = excluded.outpoint_index,
+			block_height = excluded.block_height, rejected_at = now()
+	`
+	for _, tx := range txs {
+		r := g.rejection(ctx, b, snapshot, tx)
+		if r == nil {
+			continue
+		}
+		var (
+			index, outIndex sql.NullInt64
+			outHash         []byte
+		)
+		if r.InputIndex != nil {
+			index = sql.NullInt64{Int64: int64(*r.InputIndex), Valid: true}
+		}
+		if r.Outpoint != nil {
+			outHash = r.Outpoint.Hash[:]
+			outIndex = sql.NullInt64{Int64: int64(r.Outpoint.Index), Valid: true}
+		}
+		_, err := g.db.Exec(ctx, q, tx.Hash, r.Reason, r.Detail, index, outHash, outIndex, b.Height)
+		if err != nil {
+			return errors.Wrap(err, "generator_rejections insert query")
+		}
+	}
+	return nil
+}
+
+// rejection returns why tx, left out of b, was rejected,
+// or nil if it wasn't rejected on its own. Snapshot is the
+// state after b. RejectedAt is left zero.
+func (g *Generator) rejection(ctx context.Context, b *bc.Block, snapshot *state.Snapshot, tx *bc.Tx) *Rejection {
+	err := g.chain.CheckTxInBlock(snapshot, b, tx)
+	if err == nil {
+		// It could go in a later block, so it wasn't
+		// rejected on its own; don't guess why.
+		return nil
+	}
+	reason := validation.Reason(err)
+	if reason == "" {
+		log.Error(ctx, errors.Wrapf(err, "checking rejected tx %s", tx.Hash))
+		return nil
+	}
+	r := &Rejection{TxHash: tx.Hash, Reason: reason, Detail: errors.Detail(err), BlockHeight: b.Height}
+	data := errors.Data(err)
+	if i, ok := data[errors.KeyInputIndex].(int); ok {
+		r.InputIndex = &i
+	}
+	if o, ok := data[validation.KeyOutpoint].(bc.Outpoint); ok {
+		r.Outpoint = &o
+	}
+	return r
+}
+
+// Rejections returns up to limit of the transactions rejected
+// in the last day, most recent first, starting after the
+// rejection with sequence number after, if it's nonzero. It
+// also returns the value of after for the next page. If txHash
+// is non-nil, it returns only the rejection of that tx.
+func (g *Generator) Rejections(ctx context.Context, txHash *bc.Hash, after uint64, limit int) ([]*Rejection, uint64, error) {
+	const q = `
+		SELECT seq, tx_hash, reason, detail, input_index, outpoint_tx_hash, outpoint_index, block_height, rejected_at
+		FROM generator_rejections
+		WHERE rejected_at > now() - interval '1 day'
+			AND ($1::bytea IS NULL OR tx_hash = $1)
+			AND ($2 = 0 OR seq < $2)
+		ORDER BY seq DESC LIMIT $3
+	`
+	var hash []byte
+	if txHash != nil {
+		hash = txHash[:]
+	}
+	var rejections []*Rejection
+	err := pg.ForQueryRows(ctx, g.db, q, hash, after, limit, func(seq uint64, h bc.Hash, reason, detail string, index sql.NullInt64, outHash []byte, outIndex sql.NullInt64, height uint64, at time.Time) {
+		r := &Rejection{TxHash: h, Reason: reason, Detail: detail, BlockHeight: height, RejectedAt: at}
+		if index.Valid {
+			i := int(index.Int64)
+			r.InputIndex = &i
+		}
+		if outIndex.Valid {
+			r.Outpoint = &bc.Outpoint{Index: uint32(outIndex.Int64)}
+			copy(r.Outpoint.Hash[:], outHash)
+		}
+		rejections = append(rejections, r)
+		after = seq
+	})
+	return rejections, after, errors.Wrap(err, "generator_rejections select query")
+}
@@ -0,0 +1,74 @@
+package generator
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+)
+
+// ProposeSupplyCaps saves supply caps for the generator to
+// commit in the blocks it makes, replacing any earlier proposal.
+// An empty list withdraws the proposal. The block signers must
+// approve the caps before they will sign blocks setting them;
+// see blocksigner.ApproveSupplyCaps. If they refuse, the
+// generator makes its block without the caps and drops them.
+func ProposeSupplyCaps(ctx context.Context, db pg.DB, caps []bc.SupplyCap) error {
+	err := validation.CheckSupplyCaps(caps)
+	if err != nil {
+		return err
+	}
+	assetIDs, amounts := supplyCapArrays(caps)
+	const q = `
+		WITH removed AS (
+			DELETE FROM generator_pending_supply_caps
+			WHERE NOT asset_id = ANY($1::bytea[])
+		)
+		INSERT INTO generator_pending_supply_caps (asset_id, amount)
+		SELECT unnest($1::bytea[]), unnest($2::bigint[])
+		ON CONFLICT (asset_id) DO UPDATE SET amount = excluded.amount
+	`
+	_, err = db.Exec(ctx, q, assetIDs, amounts)
+	return errors.Wrap(err, "saving proposed supply caps")
+}
+
+// PendingSupplyCaps returns the proposed supply caps
+// not yet committed in a block.
+func PendingSupplyCaps(ctx context.Context, db pg.DB) ([]bc.SupplyCap, error) {
+	const q = `SELECT asset_id, amount FROM generator_pending_supply_caps ORDER BY asset_id`
+	caps := []bc.SupplyCap{}
+	err := pg.ForQueryRows(ctx, db, q, func(assetID bc.AssetID, amount uint64) {
+		caps = append(caps, bc.SupplyCap{AssetID: assetID, Amount: amount})
+	})
+	return caps, errors.Wrap(err, "loading proposed supply caps")
+}
+
+// deletePendingSupplyCaps removes supply caps committed in a
+// block. A cap proposed again since, with a different amount,
+// is kept for the next block.
+func deletePendingSupplyCaps(ctx context.Context, db pg.DB, caps []bc.SupplyCap) error {
+	if len(caps) == 0 {
+		return nil
+	}
+	assetIDs, amounts := supplyCapArrays(caps)
+	const q = `
+		DELETE FROM generator_pending_supply_caps
+		WHERE (asset_id, amount) IN (SELECT unnest($1::bytea[]), unnest($2::bigint[]))
+	`
+	_, err := db.Exec(ctx, q, assetIDs, amounts)
+	return errors.Wrap(err, "deleting committed supply caps")
+}
+
+func supplyCapArrays(caps []bc.SupplyCap) (pq.ByteaArray, pq.Int64Array) {
+	assetIDs, amounts := pq.ByteaArray{}, pq.Int64Array{}
+	for _, c := range caps {
+		assetID := c.AssetID
+		assetIDs = append(assetIDs, assetID[:])
+		amounts = append(amounts, int64(c.Amount))
+	}
+	return assetIDs, amounts
+}
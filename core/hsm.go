@@ -3,10 +3,12 @@ package core
 import (
 	"context"
 
+	"chain/core/approval"
 	"chain/core/mockhsm"
 	"chain/core/txbuilder"
 	"chain/crypto/ed25519/chainkd"
 	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 )
 
 func (h *Handler) mockhsmCreateKey(ctx context.Context, in struct{ Alias string }) (result *mockhsm.XPub, err error) {
@@ -48,7 +50,22 @@ func (h *Handler) mockhsmSignTemplates(ctx context.Context, x struct {
 }) []interface{} {
 	resp := make([]interface{}, 0, len(x.Txs))
 	for _, tx := range x.Txs {
-		err := txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
+		var err error
+		if tx.Transaction != nil {
+			// Don't sign spends from accounts frozen
+			// since the transaction was built.
+			err = h.Accounts.CheckFrozenSpends(ctx, bc.NewTx(*tx.Transaction))
+		}
+		if err == nil {
+			err = h.checkApproval(ctx, tx, approval.EventSigned, func() error {
+				return txbuilder.Sign(ctx, tx, x.XPubs, h.mockhsmSignTemplate)
+			})
+		}
+		if err == nil {
+			// Sign what the transaction does along with it,
+			// so what the keys authorized can be reviewed.
+			err = h.signEffects(ctx, tx, x.XPubs)
+		}
 		if err != nil {
 			info, _ := errInfo(err)
 			resp = append(resp, info)
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,7 +31,9 @@ func TestMockHSM(t *testing.T) {
 	assets := asset.NewRegistry(db, c, pinStore)
 	accounts := account.NewManager(db, c, pinStore)
 	coretest.CreatePins(ctx, t, pinStore)
-	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	indexer := query.NewIndexer(db, c, pinStore)
+	indexer.RegisterAnnotator(accounts.AnnotateTxs)
+	accounts.IndexAccounts(indexer)
 	go accounts.ProcessBlocks(ctx)
 	mockhsm := mockhsm.New(db)
 
@@ -37,7 +41,7 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1.XPub}, 1, "", nil, "")
+	acct1, err := accounts.Create(ctx, []chainkd.XPub{xpub1.XPub}, 1, "", nil, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,7 +50,7 @@ func TestMockHSM(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	acct2, err := accounts.Create(ctx, []chainkd.XPub{xpub2}, 1, "", nil, "")
+	acct2, err := accounts.Create(ctx, []chainkd.XPub{xpub2}, 1, "", nil, 0, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -84,7 +88,7 @@ func TestMockHSM(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	h := &Handler{HSM: mockhsm}
+	h := &Handler{HSM: mockhsm, Indexer: indexer}
 	outTmpls := h.mockhsmSignTemplates(ctx, struct {
 		Txs   []*txbuilder.Template `json:"transactions"`
 		XPubs []chainkd.XPub        `json:"xpubs"`
@@ -102,6 +106,19 @@ func TestMockHSM(t *testing.T) {
 
 	inspectSigInst(t, outTmpl.SigningInstructions[0], true)
 	inspectSigInst(t, outTmpl.SigningInstructions[1], false)
+
+	// The effects of the transaction are signed by the key that signed it.
+	err = txbuilder.VerifyEffects(outTmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sigs := outTmpl.Effects.Signatures; len(sigs) != 1 || sigs[0].XPub != xpub1.XPub {
+		t.Errorf("effects signatures = %+v want one by %x", sigs, xpub1.XPub)
+	}
+	wantLine := fmt.Sprintf("pay 20 of asset %s to account %q\n", asset2ID, acct1.ID)
+	if !strings.Contains(outTmpl.Effects.Text, wantLine) {
+		t.Errorf("effects text = %q want line %q", outTmpl.Effects.Text, wantLine)
+	}
 }
 
 func inspectSigInst(t *testing.T, si *txbuilder.SigningInstruction, expectSig bool) {
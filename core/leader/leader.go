@@ -27,6 +27,28 @@ func IsLeading() bool {
 	return l
 }
 
+// A Fence is a lease, kept apart from the core's database,
+// that a process must also hold to lead. It keeps a process
+// from leading against a database the core has failed over
+// from. Its lease must last at least 10 seconds unrenewed.
+// See package failover.
+type Fence interface {
+	// Acquire takes the lease for key,
+	// reporting whether it did.
+	Acquire(ctx context.Context, key string) (bool, error)
+
+	// Renew extends the lease held by key,
+	// reporting false if key doesn't hold it.
+	Renew(ctx context.Context, key string) (bool, error)
+
+	// Release gives up the lease, if key holds it.
+	Release(ctx context.Context, key string)
+}
+
+// fenceTimeout bounds each call to a Fence, so a leader cut off
+// from it steps down before its lease can expire.
+const fenceTimeout = 2 * time.Second
+
 // Run runs as a goroutine, trying once every five seconds to become
 // the leader for the core.  If it succeeds, then it calls the
 // function lead (for generating or fetching blocks, and for
@@ -38,12 +60,19 @@ func IsLeading() bool {
 // The Chain Core has up to a 10-second refractory period after
 // shutdown, during which no process can become the new leader.
 func Run(db *sql.DB, addr string, lead func(context.Context)) {
+	RunFenced(db, addr, nil, lead)
+}
+
+// RunFenced is like Run, but if fence is non-nil, the
+// process leads only while it also holds fence's lease.
+func RunFenced(db *sql.DB, addr string, fence Fence, lead func(context.Context)) {
 	ctx := context.Background()
 	// We use our process's address as the key, because it's unique
 	// among all processes within a Core and it allows a restarted
 	// leader to immediately return to its leadership.
 	l := &leader{
 		db:      db,
+		fence:   fence,
 		key:     addr,
 		lead:    lead,
 		address: addr,
@@ -59,6 +88,7 @@ func Run(db *sql.DB, addr string, lead func(context.Context)) {
 type leader struct {
 	// config
 	db      *sql.DB
+	fence   Fence
 	key     string
 	lead    func(context.Context)
 	address string
@@ -85,20 +115,21 @@ func update(ctx context.Context, l *leader) {
 		res, err := l.db.Exec(ctx, updateQ, l.key)
 		if err == nil {
 			rowsAffected, err := res.RowsAffected()
-			if err == nil && rowsAffected > 0 {
+			if err == nil && rowsAffected > 0 && l.renewFence(ctx) {
 				// still leading
 				return
 			}
 		}
 
 		// Either the UPDATE affected no rows, or it (or RowsAffected)
-		// produced an error.
+		// produced an error, or the fence wasn't renewed.
 
 		if err != nil {
 			log.Error(ctx, err)
 		}
 		log.Messagef(ctx, "No longer core leader")
 		l.cancel()
+		l.releaseFence(ctx)
 		l.leading = false
 
 		lock.Lock()
@@ -114,18 +145,28 @@ func update(ctx context.Context, l *leader) {
 		// On success, this process's leadership expires in 10 seconds
 		// unless it's renewed in the UPDATE query above.
 		// That extends it for another 10 seconds.
+		//
+		// The fence, if any, is taken first, so a process
+		// holding the row without the fence can't keep
+		// the fence's holder from leading.
+		if !l.acquireFence(ctx) {
+			return
+		}
 		res, err := l.db.Exec(ctx, insertQ, l.key, l.address)
 		if err != nil {
 			log.Error(ctx, err)
+			l.releaseFence(ctx)
 			return
 		}
 		rowsAffected, err := res.RowsAffected()
 		if err != nil {
 			log.Error(ctx, err)
+			l.releaseFence(ctx)
 			return
 		}
 
 		if rowsAffected == 0 {
+			l.releaseFence(ctx)
 			return
 		}
 
@@ -142,6 +183,41 @@ func update(ctx context.Context, l *leader) {
 	}
 }
 
+func (l *leader) acquireFence(ctx context.Context) bool {
+	if l.fence == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, fenceTimeout)
+	defer cancel()
+	ok, err := l.fence.Acquire(ctx, l.key)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+	return ok && err == nil
+}
+
+func (l *leader) renewFence(ctx context.Context) bool {
+	if l.fence == nil {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(ctx, fenceTimeout)
+	defer cancel()
+	ok, err := l.fence.Renew(ctx, l.key)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+	return ok && err == nil
+}
+
+func (l *leader) releaseFence(ctx context.Context) {
+	if l.fence == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, fenceTimeout)
+	defer cancel()
+	l.fence.Release(ctx, l.key)
+}
+
 // Address retrieves the IP address of the current
 // core leader.
 func Address(ctx context.Context, db pg.DB) (string, error) {
@@ -155,3 +231,24 @@ func Address(ctx context.Context, db pg.DB) (string, error) {
 
 	return addr, nil
 }
+
+// Staleness returns the time since the core's leader last renewed
+// its leadership, as recorded in db. The leader renews every five
+// seconds, so that's as stale as a process sharing the leader's
+// database gets while the leader is up. It's more if the leader
+// has stopped, or if db lags behind the leader's database.
+func Staleness(ctx context.Context, db pg.DB) (time.Duration, error) {
+	const q = `
+		SELECT EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - (expiry - INTERVAL '10 seconds'))::float8
+		FROM leader
+	`
+	var secs float64
+	err := db.QueryRow(ctx, q).Scan(&secs)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not fetch leader expiry")
+	}
+	if secs < 0 {
+		secs = 0
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/core/msgrelay"
+	"chain/core/rpc"
+	chainjson "chain/encoding/json"
+	"chain/net/http/httpjson"
+)
+
+// POST /send-message
+func (h *Handler) sendMessage(ctx context.Context, x struct {
+	CoreURL        string             `json:"core_url"`
+	AccessToken    string             `json:"access_token"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	Payload        json.RawMessage    `json:"payload"`
+}) error {
+	client := &rpc.Client{
+		BaseURL:      x.CoreURL,
+		AccessToken:  x.AccessToken,
+		BlockchainID: h.Config.BlockchainID.String(),
+		CoreID:       h.Config.ID,
+	}
+	return msgrelay.Send(ctx, client, x.ControlProgram, x.Payload)
+}
+
+// fetchMessages removes and returns the oldest messages
+// other cores have delivered to this core's accounts.
+//
+// POST /fetch-messages
+func (h *Handler) fetchMessages(ctx context.Context, x struct {
+	PageSize int `json:"page_size"`
+}) (interface{}, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	msgs, err := h.Messages.Fetch(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(msgs), nil
+}
+
+// POST /rpc/get-relay-key
+func (h *Handler) getRelayKeyRPC(ctx context.Context) (map[string]interface{}, error) {
+	pub, err := h.Messages.PublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"public_key": chainjson.HexBytes(pub[:])}, nil
+}
+
+// POST /rpc/deliver-message
+func (h *Handler) deliverMessageRPC(ctx context.Context, x struct {
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	Message        chainjson.HexBytes `json:"message"`
+}) error {
+	return h.Messages.Deliver(ctx, x.ControlProgram, x.Message)
+}
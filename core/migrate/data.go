@@ -96,4 +96,449 @@ var migrations = []migration{
 		ALTER TABLE assets ALTER COLUMN initial_block_hash SET DATA TYPE bytea USING decode(initial_block_hash, 'hex');
 		ALTER TABLE config ALTER COLUMN blockchain_id SET DATA TYPE bytea USING decode(blockchain_id, 'hex');
 	`},
+	{Name: "2017-01-13.0.core.rpc-nonces.sql", SQL: `
+		CREATE TABLE rpc_nonces (
+			nonce bytea PRIMARY KEY,
+			expires_at timestamp with time zone NOT NULL
+		);
+		CREATE INDEX rpc_nonces_expires_at_idx ON rpc_nonces (expires_at);
+	`},
+	{Name: "2017-01-16.0.core.token-usage.sql", SQL: `
+		CREATE TABLE token_usage (
+			token_id text PRIMARY KEY,
+			requests bigint NOT NULL,
+			cpu_time bigint NOT NULL,
+			db_time bigint NOT NULL,
+			bytes_returned bigint NOT NULL,
+			created_at timestamp with time zone NOT NULL
+		);
+	`},
+	{Name: "2017-01-18.0.core.issuance-approvals.sql", SQL: `
+		CREATE TABLE issuance_approval_policies (
+			asset_id bytea PRIMARY KEY,
+			threshold integer NOT NULL,
+			approvers text[] NOT NULL
+		);
+		CREATE TABLE issuance_approval_requests (
+			tx_hash bytea PRIMARY KEY,
+			raw_tx bytea NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE TABLE issuance_approvals (
+			tx_hash bytea NOT NULL,
+			token_id text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tx_hash, token_id)
+		);
+		CREATE TABLE issuance_approval_events (
+			id bigserial PRIMARY KEY,
+			tx_hash bytea NOT NULL,
+			type text NOT NULL,
+			token_id text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE INDEX issuance_approval_events_tx_hash_idx ON issuance_approval_events (tx_hash);
+	`},
+	{Name: "2017-01-19.0.core.counterparty-labels.sql", SQL: `
+		CREATE TABLE counterparty_labels (
+			program bytea PRIMARY KEY,
+			label text NOT NULL
+		);
+	`},
+	{Name: "2017-01-20.0.core.duplicate-payments.sql", SQL: `
+		CREATE TABLE duplicate_payments (
+			id bigserial PRIMARY KEY,
+			tx_hash bytea NOT NULL,
+			output_index integer NOT NULL,
+			original_tx_hash bytea NOT NULL,
+			original_output_index integer NOT NULL,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			amount bigint NOT NULL,
+			reasons text[] NOT NULL,
+			block_height bigint NOT NULL,
+			timestamp timestamp with time zone NOT NULL,
+			CONSTRAINT duplicate_payments_outpoints_key UNIQUE (tx_hash, output_index, original_tx_hash, original_output_index)
+		);
+	`},
+	{Name: "2017-01-23.0.core.explorer-tokens.sql", SQL: `
+		ALTER TYPE access_token_type ADD VALUE 'explorer';
+	`},
+	{Name: "2017-01-24.0.core.pending-txs.sql", SQL: `
+		CREATE TABLE pending_txs (
+			tx_hash bytea PRIMARY KEY,
+			data jsonb NOT NULL,
+			max_time_ms bigint NOT NULL,
+			submitted_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE TABLE pending_outputs (
+			tx_hash bytea NOT NULL REFERENCES pending_txs ON DELETE CASCADE,
+			output_index integer NOT NULL,
+			data jsonb NOT NULL,
+			PRIMARY KEY (tx_hash, output_index)
+		);
+		CREATE TABLE pending_spends (
+			tx_hash bytea NOT NULL REFERENCES pending_txs ON DELETE CASCADE,
+			spent_tx_hash bytea NOT NULL,
+			spent_output_index integer NOT NULL
+		);
+		CREATE INDEX ON pending_spends (tx_hash);
+		CREATE INDEX ON pending_spends (spent_tx_hash, spent_output_index);
+	`},
+	{Name: "2017-01-25.0.core.reservation-conflicts.sql", SQL: `
+		CREATE TABLE reservation_conflicts (
+			id bigserial PRIMARY KEY,
+			tx_hash bytea NOT NULL,
+			input_index integer NOT NULL,
+			spent_tx_hash bytea NOT NULL,
+			spent_output_index integer NOT NULL,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			amount bigint NOT NULL,
+			block_height bigint NOT NULL,
+			timestamp timestamp with time zone NOT NULL,
+			CONSTRAINT reservation_conflicts_spent_output_key UNIQUE (spent_tx_hash, spent_output_index)
+		);
+	`},
+	{Name: "2017-01-25.1.core.admin-tokens.sql", SQL: `
+		ALTER TYPE access_token_type ADD VALUE 'admin';
+	`},
+	{Name: "2017-01-25.2.core.account-freezes.sql", SQL: `
+		CREATE TABLE account_freezes (
+			id bigserial PRIMARY KEY,
+			account_id text NOT NULL,
+			reason text NOT NULL,
+			frozen_by text NOT NULL,
+			frozen_at timestamp with time zone DEFAULT now() NOT NULL,
+			unfrozen_by text,
+			unfrozen_at timestamp with time zone
+		);
+		CREATE UNIQUE INDEX account_freezes_account_id_idx ON account_freezes (account_id) WHERE unfrozen_at IS NULL;
+	`},
+	{Name: "2017-01-25.3.core.account-upgrades.sql", SQL: `
+		CREATE TABLE account_upgrades (
+			account_id text PRIMARY KEY,
+			successor_id text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			completed_at timestamp with time zone
+		);
+	`},
+	{Name: "2017-01-26.0.core.tx-failures.sql", SQL: `
+		CREATE TABLE tx_failures (
+			tx_hash bytea PRIMARY KEY,
+			input_index integer NOT NULL,
+			error text NOT NULL,
+			program bytea NOT NULL,
+			pc bigint NOT NULL,
+			stack bytea[] NOT NULL,
+			failed_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-26.1.core.watch-descriptors.sql", SQL: `
+		CREATE TABLE watch_descriptors (
+			id text DEFAULT next_chain_id('wat'::text) PRIMARY KEY,
+			alias text UNIQUE,
+			xpubs bytea[] NOT NULL,
+			quorum integer NOT NULL,
+			derivation_path text NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE TABLE watch_programs (
+			descriptor_id text NOT NULL,
+			key_index bigint NOT NULL,
+			control_program bytea NOT NULL,
+			used boolean DEFAULT false NOT NULL,
+			PRIMARY KEY (descriptor_id, key_index)
+		);
+		CREATE INDEX watch_programs_control_program_idx ON watch_programs (control_program);
+	`},
+	{Name: "2017-01-26.2.core.asset-definition-documents.sql", SQL: `
+		CREATE TABLE asset_definition_documents (
+			hash bytea PRIMARY KEY,
+			url text NOT NULL,
+			document bytea NOT NULL,
+			fetched_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-26.3.core.message-relay.sql", SQL: `
+		CREATE TABLE relay_key (
+			singleton boolean DEFAULT true PRIMARY KEY,
+			private_key bytea NOT NULL,
+			CONSTRAINT relay_key_singleton CHECK (singleton)
+		);
+		CREATE TABLE relayed_messages (
+			id bigserial PRIMARY KEY,
+			account_id text NOT NULL,
+			control_program bytea NOT NULL,
+			message bytea NOT NULL,
+			received_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-26.4.core.generator-ordering.sql", SQL: `
+		CREATE TABLE generator_ordering (
+			singleton boolean DEFAULT true PRIMARY KEY,
+			policy text NOT NULL,
+			tiers jsonb NOT NULL,
+			CONSTRAINT generator_ordering_singleton CHECK (singleton)
+		);
+	`},
+	{Name: "2017-01-26.5.core.access-token-expiry.sql", SQL: `
+		ALTER TABLE access_tokens
+			ADD COLUMN expires_at timestamp with time zone,
+			ADD COLUMN last_used_at timestamp with time zone,
+			ADD COLUMN previous_hashed_secret bytea,
+			ADD COLUMN previous_expires_at timestamp with time zone;
+	`},
+	{Name: "2017-01-26.6.core.build-quotas.sql", SQL: `
+		CREATE TABLE build_quotas (
+			subject_type text NOT NULL,
+			subject_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			quota bigint NOT NULL,
+			PRIMARY KEY (subject_type, subject_id, asset_id)
+		);
+		CREATE TABLE build_quota_usage (
+			subject_type text NOT NULL,
+			subject_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			day date NOT NULL,
+			used bigint NOT NULL,
+			quota bigint NOT NULL,
+			PRIMARY KEY (subject_type, subject_id, asset_id, day),
+			CONSTRAINT build_quota_usage_within_quota CHECK (used <= quota)
+		);
+	`},
+	{Name: "2017-01-26.7.core.archive-assets.sql", SQL: `
+		ALTER TABLE assets ADD COLUMN archived_at timestamp with time zone;
+	`},
+	{Name: "2017-01-26.8.core.import-assets.sql", SQL: `
+		ALTER TABLE assets ALTER COLUMN issuance_program DROP NOT NULL;
+	`},
+	{Name: "2017-01-26.9.core.tx-submissions.sql", SQL: `
+		CREATE TABLE tx_submissions (
+			id text DEFAULT next_chain_id('sub'::text) PRIMARY KEY,
+			tx_hash bytea NOT NULL,
+			status text NOT NULL,
+			block_height bigint,
+			error jsonb,
+			submitted_at timestamp with time zone DEFAULT now() NOT NULL,
+			updated_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-27.0.core.asset-max-issuance.sql", SQL: `
+		ALTER TABLE assets
+			ADD COLUMN max_issuance bigint,
+			ADD COLUMN issued bigint DEFAULT 0 NOT NULL,
+			ADD COLUMN issued_height bigint DEFAULT 0 NOT NULL;
+	`},
+	{Name: "2017-01-27.1.core.access-token-namespace.sql", SQL: `
+		ALTER TABLE access_tokens ADD COLUMN namespace text;
+	`},
+	{Name: "2017-01-27.2.core.asset-rotations.sql", SQL: `
+		CREATE TABLE asset_rotations (
+			asset_id bytea PRIMARY KEY,
+			successor_id bytea NOT NULL UNIQUE,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-27.3.core.block-cost.sql", SQL: `
+		ALTER TABLE query_blocks
+			ADD COLUMN run_cost bigint DEFAULT 0 NOT NULL,
+			ADD COLUMN sig_checks bigint DEFAULT 0 NOT NULL,
+			ADD COLUMN bytes bigint DEFAULT 0 NOT NULL;
+	`},
+	{Name: "2017-01-27.4.core.generator-pending-txs.sql", SQL: `
+		CREATE TABLE generator_pending_txs (
+			id bigserial PRIMARY KEY,
+			tx_hash bytea NOT NULL UNIQUE,
+			data bytea NOT NULL,
+			submitter text DEFAULT '' NOT NULL
+		);
+	`},
+	{Name: "2017-01-27.5.core.account-archive.sql", SQL: `
+		ALTER TABLE accounts ADD COLUMN archived_at timestamp with time zone;
+	`},
+	{Name: "2017-01-27.6.core.account-epochs.sql", SQL: `
+		ALTER TABLE accounts ADD COLUMN epoch_period bigint DEFAULT 0 NOT NULL;
+		ALTER TABLE account_control_programs ADD COLUMN epoch bigint;
+		ALTER TABLE account_utxos ADD COLUMN control_program_epoch bigint;
+	`},
+	{Name: "2017-01-27.7.core.sub-accounts.sql", SQL: `
+		ALTER TABLE signers ADD COLUMN parent_key_indexes bigint[] DEFAULT '{}' NOT NULL;
+		ALTER TABLE accounts ADD COLUMN parent_id text;
+	`},
+	{Name: "2017-01-27.8.core.account-spending-limits.sql", SQL: `
+		CREATE TABLE account_spending_limits (
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			max_amount bigint NOT NULL,
+			window_secs bigint NOT NULL,
+			PRIMARY KEY (account_id, asset_id)
+		);
+		CREATE TABLE account_spends (
+			tx_hash bytea NOT NULL,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			amount bigint NOT NULL,
+			spent_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (tx_hash, account_id, asset_id)
+		);
+		CREATE INDEX ON account_spends (account_id, asset_id, spent_at);
+	`},
+	{Name: "2017-01-27.9.core.receivers.sql", SQL: `
+		ALTER TABLE account_control_programs ADD COLUMN expires_at timestamp with time zone;
+		CREATE INDEX ON account_control_programs (expires_at) WHERE expires_at IS NOT NULL;
+	`},
+	{Name: "2017-01-28.0.core.account-key-rotation.sql", SQL: `
+		CREATE TABLE account_key_generations (
+			account_id text NOT NULL,
+			generation integer NOT NULL,
+			xpubs bytea[] NOT NULL,
+			quorum integer NOT NULL,
+			client_token text UNIQUE,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (account_id, generation)
+		);
+		ALTER TABLE account_control_programs ADD COLUMN key_generation integer DEFAULT 0 NOT NULL;
+		ALTER TABLE account_utxos ADD COLUMN control_program_generation integer DEFAULT 0 NOT NULL;
+	`},
+	{Name: "2017-01-28.1.core.balance-webhooks.sql", SQL: `
+		CREATE TABLE balance_webhooks (
+			id text DEFAULT next_chain_id('bwh') PRIMARY KEY,
+			account_id text,
+			asset_id bytea,
+			trigger text NOT NULL,
+			threshold bigint DEFAULT 0 NOT NULL,
+			url text NOT NULL,
+			last_block_height bigint DEFAULT 0 NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-28.2.core.balance-alerts.sql", SQL: `
+		CREATE TABLE balance_alert_rules (
+			id text DEFAULT next_chain_id('bar') PRIMARY KEY,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			minimum bigint NOT NULL,
+			url text,
+			created_height bigint NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE TABLE balance_alerts (
+			id bigserial PRIMARY KEY,
+			rule_id text NOT NULL,
+			account_id text NOT NULL,
+			asset_id bytea NOT NULL,
+			minimum bigint NOT NULL,
+			balance bigint NOT NULL,
+			block_height bigint NOT NULL,
+			timestamp timestamp with time zone NOT NULL,
+			UNIQUE (rule_id, block_height)
+		);
+	`},
+	{Name: "2017-01-28.3.core.account-quorum-tiers.sql", SQL: `
+		CREATE TABLE account_quorum_tiers (
+			account_id text NOT NULL,
+			amount bigint NOT NULL,
+			quorum integer NOT NULL,
+			PRIMARY KEY (account_id, amount)
+		);
+	`},
+	{Name: "2017-01-28.4.core.supply-caps.sql", SQL: `
+		CREATE TABLE generator_pending_supply_caps (
+			asset_id bytea PRIMARY KEY,
+			amount bigint NOT NULL
+		);
+		CREATE TABLE signer_supply_cap_approvals (
+			asset_id bytea PRIMARY KEY,
+			amount bigint NOT NULL
+		);
+	`},
+	{Name: "2017-01-28.5.core.generator-fee-ordering.sql", SQL: `
+		ALTER TABLE generator_ordering ADD COLUMN fee_asset_id bytea;
+	`},
+	{Name: "2017-01-28.6.core.account-control-program-first-use.sql", SQL: `
+		ALTER TABLE account_control_programs
+			ADD COLUMN first_used_tx bytea,
+			ADD COLUMN first_used_block_height bigint;
+		UPDATE account_control_programs acp
+			SET first_used_tx = o.tx_hash, first_used_block_height = o.block_height
+			FROM (
+				SELECT DISTINCT ON (data->>'control_program') data->>'control_program' AS control_program, tx_hash, block_height
+				FROM annotated_outputs
+				ORDER BY data->>'control_program', block_height, tx_pos, output_index
+			) o
+			WHERE encode(acp.control_program, 'hex') = o.control_program;
+	`},
+	{Name: "2017-01-28.7.core.submit-client-tokens.sql", SQL: `
+		CREATE TABLE submit_client_tokens (
+			client_token text NOT NULL,
+			position integer NOT NULL,
+			tx_hash bytea NOT NULL,
+			response jsonb NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			PRIMARY KEY (client_token, position)
+		);
+	`},
+	{Name: "2017-01-28.8.core.program-templates.sql", SQL: `
+		CREATE TABLE program_templates (
+			name text NOT NULL PRIMARY KEY,
+			program text NOT NULL,
+			params jsonb NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+		CREATE TABLE program_template_instances (
+			control_program bytea NOT NULL PRIMARY KEY,
+			template_name text NOT NULL REFERENCES program_templates (name)
+		);
+	`},
+	{Name: "2017-01-28.9.core.generator-rejections.sql", SQL: `
+		CREATE TABLE generator_rejections (
+			seq bigserial NOT NULL,
+			tx_hash bytea NOT NULL PRIMARY KEY,
+			reason text NOT NULL,
+			detail text NOT NULL,
+			input_index integer,
+			outpoint_tx_hash bytea,
+			outpoint_index bigint,
+			block_height bigint NOT NULL,
+			rejected_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-29.0.core.swap-offers.sql", SQL: `
+		CREATE TABLE swap_offers (
+			id text DEFAULT next_chain_id('swp'::text) PRIMARY KEY,
+			tx bytea NOT NULL,
+			spends text[] NOT NULL,
+			offered_asset_id bytea NOT NULL,
+			offered_amount bigint NOT NULL,
+			requested_asset_id bytea NOT NULL,
+			requested_amount bigint NOT NULL,
+			status text DEFAULT 'open' NOT NULL,
+			closed_by bytea,
+			created_at timestamp with time zone DEFAULT now() NOT NULL,
+			expires_at timestamp with time zone NOT NULL
+		);
+		CREATE INDEX swap_offers_spends_idx ON swap_offers USING gin (spends);
+	`},
+	{Name: "2017-01-29.1.core.submit-client-tokens-access-token.sql", SQL: `
+		ALTER TABLE submit_client_tokens ADD COLUMN access_token_id text DEFAULT '' NOT NULL;
+		ALTER TABLE submit_client_tokens DROP CONSTRAINT submit_client_tokens_pkey;
+		ALTER TABLE submit_client_tokens ADD PRIMARY KEY (access_token_id, client_token, position);
+	`},
+	{Name: "2017-01-29.2.core.txfeed-sinks.sql", SQL: `
+		CREATE TABLE txfeed_sinks (
+			feed_id text PRIMARY KEY REFERENCES txfeeds (id) ON DELETE CASCADE,
+			type text NOT NULL,
+			queue_url text NOT NULL,
+			dead_letter_queue_url text,
+			encoding text NOT NULL,
+			ordering_key text,
+			last_block_height bigint NOT NULL,
+			created_at timestamp with time zone DEFAULT now() NOT NULL
+		);
+	`},
+	{Name: "2017-01-29.3.core.tx-submissions-tx.sql", SQL: `
+		ALTER TABLE tx_submissions ADD COLUMN tx bytea;
+	`},
 }
@@ -0,0 +1,287 @@
+// Package msgrelay carries encrypted messages between cores.
+//
+// A payer's core delivers a message, such as invoice details
+// or a compliance envelope, to the core that created the
+// receiver's control program. The receiving core keeps the
+// message until one of its clients fetches it.
+//
+// Each core has a curve25519 relay key. A message is sealed
+// to the receiving core's public key with a fresh ephemeral
+// key: the two are combined by Diffie-Hellman, and the shared
+// secret, hashed with SHA3-256, keys AES-256-GCM. Since every
+// key is used once, the nonce is always zero.
+package msgrelay
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/curve25519"
+
+	"chain/core/rpc"
+	"chain/crypto/sha3pool"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// maxPayloadSize bounds the size of a message payload.
+const maxPayloadSize = 64 << 10
+
+var (
+	// ErrUnknownRecipient is returned by Deliver when the control
+	// program doesn't belong to an account on this core.
+	ErrUnknownRecipient = errors.New("control program does not belong to a local account")
+
+	// ErrBadMessage is returned when a message can't be
+	// decrypted or its payload is not valid JSON.
+	ErrBadMessage = errors.New("invalid message")
+
+	// ErrDelivery is returned by Send when the
+	// receiving core doesn't accept a message.
+	ErrDelivery = errors.New("message delivery failed")
+)
+
+// A Message is a decrypted message delivered to a local account.
+type Message struct {
+	ID             string             `json:"id"`
+	AccountID      string             `json:"account_id"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	Payload        json.RawMessage    `json:"payload"`
+	ReceivedAt     time.Time          `json:"received_at"`
+}
+
+// Relay stores the core's relay key and
+// the messages delivered to it.
+type Relay struct {
+	DB pg.DB
+}
+
+// PublicKey returns the core's relay public key,
+// generating the key pair the first time.
+func (r *Relay) PublicKey(ctx context.Context) ([32]byte, error) {
+	var pub [32]byte
+	priv, err := r.privateKey(ctx)
+	if err != nil {
+		return pub, err
+	}
+	curve25519.ScalarBaseMult(&pub, &priv)
+	return pub, nil
+}
+
+func (r *Relay) privateKey(ctx context.Context) ([32]byte, error) {
+	var (
+		priv [32]byte
+		b    []byte
+	)
+	const selectQ = `SELECT private_key FROM relay_key`
+	err := r.DB.QueryRow(ctx, selectQ).Scan(&b)
+	if err == sql.ErrNoRows {
+		_, err = rand.Read(priv[:])
+		if err != nil {
+			return priv, errors.Wrap(err, "generating relay key")
+		}
+		// If another process generated a key first,
+		// the insert does nothing and we use that one.
+		const insertQ = `INSERT INTO relay_key (private_key) VALUES ($1) ON CONFLICT (singleton) DO NOTHING`
+		_, err = r.DB.Exec(ctx, insertQ, priv[:])
+		if err != nil {
+			return priv, errors.Wrap(err, "saving relay key")
+		}
+		err = r.DB.QueryRow(ctx, selectQ).Scan(&b)
+	}
+	if err != nil {
+		return priv, errors.Wrap(err, "loading relay key")
+	}
+	copy(priv[:], b)
+	return priv, nil
+}
+
+// Seal encrypts plaintext so that only the holder
+// of the private key for pub can read it.
+func Seal(pub [32]byte, plaintext []byte) ([]byte, error) {
+	var ephPriv, ephPub [32]byte
+	_, err := rand.Read(ephPriv[:])
+	if err != nil {
+		return nil, errors.Wrap(err, "generating ephemeral key")
+	}
+	curve25519.ScalarBaseMult(&ephPub, &ephPriv)
+
+	aead, err := messageCipher(ephPriv, pub, ephPub, pub)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	return aead.Seal(ephPub[:], nonce, plaintext, nil), nil
+}
+
+// open decrypts a message sealed to the public key of priv.
+func open(priv [32]byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < 32 {
+		return nil, errors.WithDetail(ErrBadMessage, "message is too short")
+	}
+	var ephPub, pub [32]byte
+	copy(ephPub[:], sealed)
+	curve25519.ScalarBaseMult(&pub, &priv)
+
+	aead, err := messageCipher(priv, ephPub, ephPub, pub)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	plaintext, err := aead.Open(nil, nonce, sealed[32:], nil)
+	if err != nil {
+		return nil, errors.WithDetail(ErrBadMessage, "message could not be decrypted")
+	}
+	return plaintext, nil
+}
+
+// messageCipher returns the cipher for a message with the
+// given ephemeral and recipient public keys. priv and peer
+// are the local private key and the other party's public key.
+func messageCipher(priv, peer, ephPub, recipientPub [32]byte) (cipher.AEAD, error) {
+	var shared, key [32]byte
+	curve25519.ScalarMult(&shared, &priv, &peer)
+
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	h.Write(shared[:])
+	h.Write(ephPub[:])
+	h.Write(recipientPub[:])
+	h.Read(key[:])
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.Wrap(err)
+}
+
+// Send delivers payload, which must be JSON, to the core
+// at the other end of client, addressed to the account
+// that owns program. It's sealed to that core's relay key.
+func Send(ctx context.Context, client *rpc.Client, program, payload []byte) error {
+	err := checkPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	var keyResp struct {
+		PublicKey chainjson.HexBytes `json:"public_key"`
+	}
+	err = client.Call(ctx, "/rpc/get-relay-key", nil, &keyResp)
+	if err != nil {
+		return errors.WithDetail(ErrDelivery, err.Error())
+	}
+	if len(keyResp.PublicKey) != 32 {
+		return errors.WithDetail(ErrDelivery, "receiving core returned an invalid relay key")
+	}
+	var pub [32]byte
+	copy(pub[:], keyResp.PublicKey)
+
+	sealed, err := Seal(pub, payload)
+	if err != nil {
+		return err
+	}
+	req := struct {
+		ControlProgram chainjson.HexBytes `json:"control_program"`
+		Message        chainjson.HexBytes `json:"message"`
+	}{program, sealed}
+	err = client.Call(ctx, "/rpc/deliver-message", req, nil)
+	if err != nil {
+		return errors.WithDetail(ErrDelivery, err.Error())
+	}
+	return nil
+}
+
+func checkPayload(payload []byte) error {
+	if len(payload) > maxPayloadSize {
+		return errors.WithDetailf(ErrBadMessage, "payload exceeds %d bytes", maxPayloadSize)
+	}
+	var v interface{}
+	if json.Unmarshal(payload, &v) != nil {
+		return errors.WithDetail(ErrBadMessage, "payload must be valid JSON")
+	}
+	return nil
+}
+
+// Deliver stores a sealed message for the local account
+// that owns program. The message stays encrypted until
+// it's fetched.
+func (r *Relay) Deliver(ctx context.Context, program, sealed []byte) error {
+	var accountID string
+	const accountQ = `
+		SELECT signer_id FROM account_control_programs
+		WHERE control_program = $1 LIMIT 1
+	`
+	err := r.DB.QueryRow(ctx, accountQ, program).Scan(&accountID)
+	if err == sql.ErrNoRows {
+		return errors.WithDetailf(ErrUnknownRecipient, "control program %x", program)
+	}
+	if err != nil {
+		return errors.Wrap(err, "looking up message recipient")
+	}
+
+	priv, err := r.privateKey(ctx)
+	if err != nil {
+		return err
+	}
+	payload, err := open(priv, sealed)
+	if err != nil {
+		return err
+	}
+	err = checkPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	const q = `
+		INSERT INTO relayed_messages (account_id, control_program, message)
+		VALUES ($1, $2, $3)
+	`
+	_, err = r.DB.Exec(ctx, q, accountID, program, sealed)
+	return errors.Wrap(err, "storing relayed message")
+}
+
+// Fetch removes and returns up to limit of the
+// oldest messages delivered to this core.
+func (r *Relay) Fetch(ctx context.Context, limit int) ([]*Message, error) {
+	priv, err := r.privateKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	const q = `
+		WITH fetched AS (
+			DELETE FROM relayed_messages WHERE id IN (
+				SELECT id FROM relayed_messages ORDER BY id LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, account_id, control_program, message, received_at
+		)
+		SELECT id, account_id, control_program, message, received_at
+		FROM fetched ORDER BY id
+	`
+	var msgs []*Message
+	err = pg.ForQueryRows(ctx, r.DB, q, limit, func(id int64, accountID string, program, sealed []byte, receivedAt time.Time) error {
+		payload, err := open(priv, sealed)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, &Message{
+			ID:             strconv.FormatInt(id, 10),
+			AccountID:      accountID,
+			ControlProgram: program,
+			Payload:        payload,
+			ReceivedAt:     receivedAt,
+		})
+		return nil
+	})
+	return msgs, errors.Wrap(err, "fetching relayed messages")
+}
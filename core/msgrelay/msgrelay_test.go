@@ -0,0 +1,96 @@
+package msgrelay
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"golang.org/x/crypto/curve25519"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+)
+
+func TestSealOpen(t *testing.T) {
+	priv := [32]byte{1, 2, 3}
+	var pub [32]byte
+	curve25519.ScalarBaseMult(&pub, &priv)
+
+	sealed, err := Seal(pub, []byte(`{"invoice":"123"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := open(priv, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte(`{"invoice":"123"}`)) {
+		t.Errorf("open = %s want %s", got, `{"invoice":"123"}`)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = open(priv, sealed)
+	if errors.Root(err) != ErrBadMessage {
+		t.Errorf("open(tampered) error = %v want %v", err, ErrBadMessage)
+	}
+}
+
+func TestDeliverFetch(t *testing.T) {
+	ctx := context.Background()
+	db := pgtest.NewTx(t)
+	r := &Relay{DB: db}
+
+	const q = `
+		INSERT INTO account_control_programs (signer_id, key_index, control_program, change)
+		VALUES ('acc1', 1, '\x51', false)
+	`
+	_, err := db.Exec(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub, err := r.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, err := r.PublicKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub != pub2 {
+		t.Fatalf("relay key changed from %x to %x", pub, pub2)
+	}
+
+	sealed, err := Seal(pub, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Deliver(ctx, []byte{0x52}, sealed)
+	if errors.Root(err) != ErrUnknownRecipient {
+		t.Errorf("Deliver(unknown program) error = %v want %v", err, ErrUnknownRecipient)
+	}
+	err = r.Deliver(ctx, []byte{0x51}, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, err := r.Fetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("got %d messages want 1", len(msgs))
+	}
+	if msgs[0].AccountID != "acc1" || string(msgs[0].Payload) != `{"a":1}` {
+		t.Errorf("got message %+v", msgs[0])
+	}
+
+	// Fetched messages are removed.
+	msgs, err = r.Fetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("got %d messages after fetching want 0", len(msgs))
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/leader"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// errNoBlocks is returned for network info requests to a core
+// that doesn't have the initial block yet, such as a new
+// participant still fetching it from the generator.
+var errNoBlocks = errors.New("no blocks yet")
+
+// networkInfo describes the blockchain network a core is on.
+// Clients can compare it with what they expect, to be sure
+// they're talking to the intended network.
+type networkInfo struct {
+	InitialBlockHash bc.Hash `json:"initial_block_hash"`
+	BlockHeight      uint64  `json:"block_height"`
+	BlockVersion     uint64  `json:"block_version"`
+
+	// MaxIssuanceWindowMS is only known to the generator,
+	// and is 0 on other cores.
+	MaxIssuanceWindowMS uint64 `json:"max_issuance_window_ms"`
+
+	// ConsensusProgram is the program of the latest block,
+	// which the next block must satisfy. SignerPubkeys and
+	// Quorum are decoded from it.
+	ConsensusProgram chainjson.HexBytes   `json:"consensus_program"`
+	SignerPubkeys    []chainjson.HexBytes `json:"signer_pubkeys"`
+	Quorum           int                  `json:"quorum"`
+
+	// BlockIntervalMS is the time between the latest
+	// block and the one before it, or 0 if there is
+	// only one block.
+	BlockIntervalMS uint64 `json:"block_interval_ms"`
+}
+
+// POST /get-network-info
+func (h *Handler) getNetworkInfo(ctx context.Context) (*networkInfo, error) {
+	if !leader.IsLeading() {
+		var resp networkInfo
+		err := h.forwardToLeader(ctx, "/get-network-info", nil, &resp)
+		return &resp, err
+	}
+
+	b, _ := h.Chain.State()
+	if b == nil {
+		return nil, errors.Wrap(errNoBlocks)
+	}
+	info := &networkInfo{
+		InitialBlockHash:    h.Config.BlockchainID,
+		BlockHeight:         b.Height,
+		BlockVersion:        b.Version,
+		MaxIssuanceWindowMS: bc.DurationMillis(h.Config.MaxIssuanceWindow.Duration),
+		ConsensusProgram:    b.ConsensusProgram,
+		SignerPubkeys:       []chainjson.HexBytes{},
+	}
+
+	pubkeys, quorum, err := vmutil.ParseBlockMultiSigProgram(b.ConsensusProgram)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing consensus program")
+	}
+	for _, pub := range pubkeys {
+		info.SignerPubkeys = append(info.SignerPubkeys, chainjson.HexBytes(pub))
+	}
+	info.Quorum = quorum
+
+	if b.Height > 1 {
+		prev, err := h.Chain.GetBlock(ctx, b.Height-1)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting previous block")
+		}
+		info.BlockIntervalMS = b.TimestampMS - prev.TimestampMS
+	}
+	return info, nil
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+
+	"chain/core/generator"
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errNotGenerator = errors.New("core is not the block generator")
+
+// POST /get-generator-ordering
+func (h *Handler) getGeneratorOrdering(ctx context.Context) (generator.Ordering, error) {
+	if !h.Config.IsGenerator {
+		return generator.Ordering{}, errors.Wrap(errNotGenerator)
+	}
+	return generator.GetOrdering(ctx, h.DB)
+}
+
+// setGeneratorOrdering changes the order in which the generator
+// considers pending transactions for a block. It takes effect
+// from the next block. It requires an admin access token.
+//
+// POST /set-generator-ordering
+func (h *Handler) setGeneratorOrdering(ctx context.Context, o generator.Ordering) error {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !h.Config.IsGenerator {
+		return errors.Wrap(errNotGenerator)
+	}
+	return generator.SetOrdering(ctx, h.DB, o)
+}
+
+// submitterContext names the submitter of transactions sent
+// to the generator with the access token of the request, so
+// the generator can order them by submitter.
+func submitterContext(ctx context.Context) context.Context {
+	id, _, _ := httpjson.Request(ctx).BasicAuth()
+	return generator.NewSubmitterContext(ctx, id)
+}
+
+// submitBatchRPC submits txs in order, so a transaction
+// can spend the outputs of an earlier one in the batch. It
+// returns a result for each transaction: an empty object
+// if it was accepted, or an error response if not, so one
+// bad transaction doesn't fail the others.
+func (h *Handler) submitBatchRPC(ctx context.Context, txs []*bc.Tx) []interface{} {
+	ctx = submitterContext(ctx)
+	results := make([]interface{}, len(txs))
+	for i, tx := range txs {
+		err := h.Submitter.Submit(ctx, tx)
+		if err != nil {
+			results[i], _ = errInfo(err)
+		} else {
+			results[i] = struct{}{}
+		}
+	}
+	return results
+}
+
+// checkTransactionConflicts asks the generator whether each
+// transaction spends an output that's already spent, or reuses
+// an issuance nonce, either in the blockchain or in a different
+// transaction in its pending pool. A client retrying a submit
+// can use it to tell a transaction that will conflict from one
+// that failed for a transient reason.
+//
+// POST /check-transaction-conflicts
+func (h *Handler) checkTransactionConflicts(ctx context.Context, x struct {
+	Transactions []txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	// The pending pool lives in the generator's leader process.
+	if h.Config.IsGenerator && !leader.IsLeading() {
+		var resp json.RawMessage
+		err := h.forwardToLeader(ctx, "/check-transaction-conflicts", x, &resp)
+		return resp, err
+	}
+
+	return runBatch(ctx, len(x.Transactions), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := &x.Transactions[i]
+		if tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		return h.generatorConflicts(ctx, bc.NewTx(*tpl.Transaction))
+	})
+}
+
+// generatorConflicts checks tx for conflicts with the
+// local generator or, if there is none, the remote one.
+func (h *Handler) generatorConflicts(ctx context.Context, tx *bc.Tx) (*generator.ConflictReport, error) {
+	switch s := h.Submitter.(type) {
+	case *generator.Generator:
+		return s.CheckConflicts(tx), nil
+	case *txbuilder.RemoteGenerator:
+		report := new(generator.ConflictReport)
+		err := s.Peer.Call(ctx, "/rpc/check-conflicts", tx, report)
+		return report, errors.Wrap(err, "generator conflict check")
+	}
+	return nil, errors.Wrap(errNotGenerator)
+}
+
+// checkConflictsRPC reports the conflicts of tx
+// for a core that isn't the generator.
+func (h *Handler) checkConflictsRPC(ctx context.Context, tx *bc.Tx) (*generator.ConflictReport, error) {
+	gen, ok := h.Submitter.(*generator.Generator)
+	if !ok {
+		return nil, errors.Wrap(errNotGenerator)
+	}
+	if !leader.IsLeading() {
+		report := new(generator.ConflictReport)
+		err := h.forwardToLeader(ctx, networkRPCPrefix+"check-conflicts", tx, report)
+		return report, err
+	}
+	return gen.CheckConflicts(tx), nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/generator"
+	"chain/core/leader"
+	"chain/core/query"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// POST /list-pending-transactions
+func (h *Handler) listPendingTransactions(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var after *query.PendingTxsAfter
+	if x.After != "" {
+		a, err := query.DecodePendingTxsAfter(x.After)
+		if err != nil {
+			return nil, err
+		}
+		after = &a
+	}
+
+	txs, err := h.Indexer.ListPendingTxs(ctx, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	if len(txs) > 0 {
+		last := txs[len(txs)-1]
+		outQuery.After = query.PendingTxsAfter{SubmittedAt: last.SubmittedAt, Hash: last.ID}.String()
+	}
+
+	return &page{
+		Items:    httpjson.Array(txs),
+		LastPage: len(txs) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// POST /count-pending-transactions
+func (h *Handler) countPendingTransactions(ctx context.Context) (map[string]uint64, error) {
+	count, oldest, err := h.Indexer.CountPendingTxs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]uint64{
+		"count":         count,
+		"oldest_age_ms": oldest,
+	}, nil
+}
+
+// POST /get-pending-transaction
+func (h *Handler) getPendingTransaction(ctx context.Context, x struct {
+	ID bc.Hash `json:"id"`
+}) (*query.PendingTx, error) {
+	return h.Indexer.PendingTx(ctx, x.ID)
+}
+
+// evictPendingTransaction removes a tx from this core's pending
+// pool, so queries no longer include it. On the generator, it
+// also removes the tx from the generator's pool, so it isn't
+// included in a block. Other cores can't recall a tx they've
+// sent to the generator. It requires an admin access token.
+//
+// POST /evict-pending-transaction
+func (h *Handler) evictPendingTransaction(ctx context.Context, x struct {
+	ID bc.Hash `json:"id"`
+}) error {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	var evicted bool
+	if gen, ok := h.Submitter.(*generator.Generator); ok {
+		// The generator's pool lives in its leader process.
+		if !leader.IsLeading() {
+			return h.forwardToLeader(ctx, "/evict-pending-transaction", x, nil)
+		}
+		evicted, err = gen.Evict(ctx, x.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if !evicted {
+		_, err = h.Indexer.PendingTx(ctx, x.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return h.Indexer.RemovePendingTx(ctx, x.ID)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/vm"
+	"chain/protocol/vmutil"
+)
+
+type programInstruction struct {
+	Op   string             `json:"op"`
+	Data chainjson.HexBytes `json:"data,omitempty"`
+	Role string             `json:"role,omitempty"`
+}
+
+// disassembleProgram breaks a control or issuance program into
+// instructions. It identifies standard templates, and the role
+// of data they push, such as public keys and hashes.
+//
+// POST /disassemble-program
+func (h *Handler) disassembleProgram(ctx context.Context, x struct {
+	Program chainjson.HexBytes `json:"program"`
+}) (interface{}, error) {
+	d, err := vmutil.DisassembleProgram(x.Program)
+	if err != nil {
+		return nil, errors.WithDetailf(httpjson.ErrBadRequest, "invalid program: %s", err)
+	}
+	insts := make([]programInstruction, 0, len(d.Instructions))
+	for _, inst := range d.Instructions {
+		insts = append(insts, programInstruction{
+			Op:   inst.Op.String(),
+			Data: inst.Data,
+			Role: inst.Role,
+		})
+	}
+	// The retirement data after FAIL need not parse,
+	// in which case there's no assembly text.
+	asm, _ := vm.Disassemble(x.Program)
+	return map[string]interface{}{
+		"program_type": d.Type,
+		"program_asm":  asm,
+		"instructions": insts,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package progtemplate
+
+import (
+	"context"
+	stdjson "encoding/json"
+
+	"chain/core/txbuilder"
+	chainjson "chain/encoding/json"
+	"chain/protocol/bc"
+)
+
+// DecodeControlWithTemplateAction decodes an action that pays
+// to the control program made from a registered template.
+func (r *Registry) DecodeControlWithTemplateAction(data []byte) (txbuilder.Action, error) {
+	a := &controlWithTemplateAction{reg: r}
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+type controlWithTemplateAction struct {
+	reg *Registry
+	bc.AssetAmount
+	Template      string                        `json:"template"`
+	Arguments     map[string]stdjson.RawMessage `json:"arguments"`
+	ReferenceData chainjson.Map                 `json:"reference_data"`
+}
+
+func (a *controlWithTemplateAction) Build(ctx context.Context, b *txbuilder.TemplateBuilder) error {
+	var missing []string
+	if a.Template == "" {
+		missing = append(missing, "template")
+	}
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if len(missing) > 0 {
+		return txbuilder.MissingFieldsError(missing...)
+	}
+
+	prog, err := a.reg.Instantiate(ctx, a.Template, a.Arguments)
+	if err != nil {
+		return err
+	}
+	out := bc.NewTxOutput(a.AssetID, a.Amount, prog, a.ReferenceData)
+	return b.AddOutput(out)
+}
@@ -0,0 +1,302 @@
+// Package progtemplate maintains a registry of parameterized
+// control programs, such as escrow or vesting contracts, that
+// transactions can pay to by name, and annotates transactions
+// with the template of each program made from one.
+package progtemplate
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/vm"
+)
+
+var (
+	// ErrBadTemplate is returned by Register for a
+	// template that is malformed or already registered.
+	ErrBadTemplate = errors.New("invalid program template")
+
+	// ErrBadArguments is returned when the arguments given
+	// for a template don't match its parameters.
+	ErrBadArguments = errors.New("invalid program template arguments")
+)
+
+// Parameter types.
+const (
+	TypeInteger = "integer" // pushed as a number
+	TypeData    = "data"    // hex, pushed as bytes
+)
+
+const maxNameLen = 256
+
+var (
+	nameRE        = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+	placeholderRE = regexp.MustCompile(`\{([a-z_][a-z0-9_]*)\}`)
+)
+
+// A Template is a control program in VM assembly, as accepted
+// by vm.Assemble, in which a placeholder such as {recipient}
+// stands for the value of the parameter of that name.
+type Template struct {
+	Name    string  `json:"name"`
+	Program string  `json:"program"`
+	Params  []Param `json:"params"`
+}
+
+// A Param is a parameter of a Template.
+type Param struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Registry stores program templates and
+// the control programs made from them.
+type Registry struct {
+	DB pg.DB
+}
+
+// Register adds t to the registry. Templates can't be
+// changed once registered, so that the programs made from
+// one always match it.
+func (r *Registry) Register(ctx context.Context, t *Template) error {
+	err := t.check()
+	if err != nil {
+		return err
+	}
+	params, err := stdjson.Marshal(t.Params)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	const q = `
+		INSERT INTO program_templates (name, program, params) VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO NOTHING
+	`
+	res, err := r.DB.Exec(ctx, q, t.Name, t.Program, params)
+	if err != nil {
+		return errors.Wrap(err, "saving program template")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "saving program template")
+	}
+	if n == 0 {
+		return errors.WithDetailf(ErrBadTemplate, "template %q is already registered", t.Name)
+	}
+	return nil
+}
+
+// Find returns the template with the given name.
+func (r *Registry) Find(ctx context.Context, name string) (*Template, error) {
+	const q = `SELECT program, params FROM program_templates WHERE name = $1`
+	t := &Template{Name: name}
+	var params []byte
+	err := r.DB.QueryRow(ctx, q, name).Scan(&t.Program, &params)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "program template %q", name)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading program template")
+	}
+	err = stdjson.Unmarshal(params, &t.Params)
+	return t, errors.Wrap(err, "decoding program template params")
+}
+
+// List returns up to limit templates, ordered by name,
+// starting after the template named after. It also returns
+// the value of after for the next page.
+func (r *Registry) List(ctx context.Context, after string, limit int) ([]*Template, string, error) {
+	const q = `
+		SELECT name, program, params FROM program_templates
+		WHERE name > $1 ORDER BY name LIMIT $2
+	`
+	var templates []*Template
+	err := pg.ForQueryRows(ctx, r.DB, q, after, limit, func(name, program string, params []byte) error {
+		t := &Template{Name: name, Program: program}
+		templates = append(templates, t)
+		return stdjson.Unmarshal(params, &t.Params)
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing program templates")
+	}
+	if len(templates) > 0 {
+		after = templates[len(templates)-1].Name
+	}
+	return templates, after, nil
+}
+
+// Instantiate returns the control program made from the
+// named template with args, and records that it was made
+// from the template, so that AnnotateTxs can label it.
+func (r *Registry) Instantiate(ctx context.Context, name string, args map[string]stdjson.RawMessage) ([]byte, error) {
+	t, err := r.Find(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := t.Instantiate(args)
+	if err != nil {
+		return nil, err
+	}
+	const q = `
+		INSERT INTO program_template_instances (control_program, template_name) VALUES ($1, $2)
+		ON CONFLICT (control_program) DO NOTHING
+	`
+	_, err = r.DB.Exec(ctx, q, prog, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving program template instance")
+	}
+	return prog, nil
+}
+
+// Instantiate returns the control program made from t with
+// args, which must have a value for each of its parameters:
+// a JSON number for an integer, and a hex string for data.
+func (t *Template) Instantiate(args map[string]stdjson.RawMessage) ([]byte, error) {
+	values := make(map[string]string, len(t.Params))
+	for _, p := range t.Params {
+		arg, ok := args[p.Name]
+		if !ok {
+			return nil, errors.WithDetailf(ErrBadArguments, "missing argument %q", p.Name)
+		}
+		switch p.Type {
+		case TypeInteger:
+			var n int64
+			err := stdjson.Unmarshal(arg, &n)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadArguments, "argument %q must be an integer", p.Name)
+			}
+			values[p.Name] = strconv.FormatInt(n, 10)
+		case TypeData:
+			var b chainjson.HexBytes
+			err := stdjson.Unmarshal(arg, &b)
+			if err != nil {
+				return nil, errors.WithDetailf(ErrBadArguments, "argument %q must be hex", p.Name)
+			}
+			values[p.Name] = "0x" + hex.EncodeToString(b)
+		}
+	}
+	if len(args) > len(t.Params) {
+		for name := range args {
+			if _, ok := values[name]; !ok {
+				return nil, errors.WithDetailf(ErrBadArguments, "unknown argument %q", name)
+			}
+		}
+	}
+
+	asm := placeholderRE.ReplaceAllStringFunc(t.Program, func(s string) string {
+		return values[s[1:len(s)-1]]
+	})
+	prog, err := vm.Assemble(asm)
+	if err != nil {
+		return nil, errors.WithDetailf(ErrBadArguments, "assembling program: %s", err)
+	}
+	return prog, nil
+}
+
+// check tests that t is well formed: its parameters are
+// named, typed and distinct, its placeholders and parameters
+// match, and it assembles with zero arguments.
+func (t *Template) check() error {
+	if len(t.Name) > maxNameLen || !nameRE.MatchString(t.Name) {
+		return errors.WithDetail(ErrBadTemplate, "name must be lowercase letters, digits and underscores")
+	}
+	zero := make(map[string]stdjson.RawMessage, len(t.Params))
+	for _, p := range t.Params {
+		if !nameRE.MatchString(p.Name) {
+			return errors.WithDetailf(ErrBadTemplate, "invalid parameter name %q", p.Name)
+		}
+		if _, ok := zero[p.Name]; ok {
+			return errors.WithDetailf(ErrBadTemplate, "duplicate parameter %q", p.Name)
+		}
+		switch p.Type {
+		case TypeInteger:
+			zero[p.Name] = stdjson.RawMessage(`0`)
+		case TypeData:
+			zero[p.Name] = stdjson.RawMessage(`""`)
+		default:
+			return errors.WithDetailf(ErrBadTemplate, "parameter %q: type must be %q or %q", p.Name, TypeInteger, TypeData)
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, m := range placeholderRE.FindAllStringSubmatch(t.Program, -1) {
+		if _, ok := zero[m[1]]; !ok {
+			return errors.WithDetailf(ErrBadTemplate, "placeholder {%s} is not a parameter", m[1])
+		}
+		used[m[1]] = true
+	}
+	for _, p := range t.Params {
+		if !used[p.Name] {
+			return errors.WithDetailf(ErrBadTemplate, "parameter %q is not used", p.Name)
+		}
+	}
+
+	_, err := t.Instantiate(zero)
+	if err != nil {
+		return errors.WithDetail(ErrBadTemplate, errors.Detail(err))
+	}
+	return nil
+}
+
+// AnnotateTxs adds the name of the template of each input's
+// and output's control program, if it was made from one.
+func (r *Registry) AnnotateTxs(ctx context.Context, txs []map[string]interface{}) error {
+	items := make(map[string][]map[string]interface{})
+	var programs pq.ByteaArray
+
+	add := func(s interface{}) {
+		asSlice, ok := s.([]interface{})
+		if !ok {
+			log.Error(ctx, errors.Wrap(fmt.Errorf("expected slice, got %T", s)))
+			return
+		}
+		for _, m := range asSlice {
+			asMap, ok := m.(map[string]interface{})
+			if !ok {
+				log.Error(ctx, errors.Wrap(fmt.Errorf("expected map, got %T", m)))
+				continue
+			}
+			progHex, ok := asMap["control_program"].(string)
+			if !ok {
+				continue
+			}
+			prog, err := hex.DecodeString(progHex)
+			if err != nil {
+				log.Error(ctx, errors.Wrap(err, "could not decode control_program"))
+				continue
+			}
+			if _, ok := items[string(prog)]; !ok {
+				programs = append(programs, prog)
+			}
+			items[string(prog)] = append(items[string(prog)], asMap)
+		}
+	}
+	for _, tx := range txs {
+		add(tx["inputs"])
+		add(tx["outputs"])
+	}
+	if len(programs) == 0 {
+		return nil
+	}
+
+	const q = `
+		SELECT control_program, template_name FROM program_template_instances
+		WHERE control_program IN (SELECT unnest($1::bytea[]))
+	`
+	err := pg.ForQueryRows(ctx, r.DB, q, programs, func(program []byte, name string) {
+		for _, m := range items[string(program)] {
+			m["program_template"] = name
+		}
+	})
+	return errors.Wrap(err, "annotating program templates")
+}
@@ -0,0 +1,68 @@
+package progtemplate
+
+import (
+	"bytes"
+	stdjson "encoding/json"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/vm"
+)
+
+func TestInstantiate(t *testing.T) {
+	tmpl := &Template{
+		Name:    "vesting",
+		Program: "BLOCKTIME {unlock_time} GREATERTHAN VERIFY TXSIGHASH {pubkey} CHECKSIG",
+		Params: []Param{
+			{Name: "unlock_time", Type: TypeInteger},
+			{Name: "pubkey", Type: TypeData},
+		},
+	}
+	err := tmpl.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tmpl.Instantiate(map[string]stdjson.RawMessage{
+		"unlock_time": stdjson.RawMessage(`1500000000`),
+		"pubkey":      stdjson.RawMessage(`"abcd"`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := vm.Assemble("BLOCKTIME 1500000000 GREATERTHAN VERIFY TXSIGHASH 0xabcd CHECKSIG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Instantiate = %x want %x", got, want)
+	}
+
+	badArgs := []map[string]stdjson.RawMessage{
+		{"unlock_time": stdjson.RawMessage(`1`)},
+		{"unlock_time": stdjson.RawMessage(`"1"`), "pubkey": stdjson.RawMessage(`"ab"`)},
+		{"unlock_time": stdjson.RawMessage(`1`), "pubkey": stdjson.RawMessage(`"xyz"`)},
+		{"unlock_time": stdjson.RawMessage(`1`), "pubkey": stdjson.RawMessage(`"ab"`), "extra": stdjson.RawMessage(`1`)},
+	}
+	for _, args := range badArgs {
+		_, err := tmpl.Instantiate(args)
+		if errors.Root(err) != ErrBadArguments {
+			t.Errorf("Instantiate(%s) err = %v want %v", args, err, ErrBadArguments)
+		}
+	}
+
+	badTemplates := []*Template{
+		{Name: "Bad Name", Program: "TRUE"},
+		{Name: "t", Program: "{x} VERIFY"},
+		{Name: "t", Program: "TRUE", Params: []Param{{Name: "x", Type: TypeInteger}}},
+		{Name: "t", Program: "{x} VERIFY", Params: []Param{{Name: "x", Type: "string"}}},
+		{Name: "t", Program: "{x} VERIFY", Params: []Param{{Name: "x", Type: TypeData}, {Name: "x", Type: TypeData}}},
+		{Name: "t", Program: "{x} NOSUCHOP", Params: []Param{{Name: "x", Type: TypeData}}},
+	}
+	for _, bt := range badTemplates {
+		err := bt.check()
+		if errors.Root(err) != ErrBadTemplate {
+			t.Errorf("check(%+v) err = %v want %v", bt, err, ErrBadTemplate)
+		}
+	}
+}
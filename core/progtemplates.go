@@ -0,0 +1,35 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/progtemplate"
+	"chain/net/http/httpjson"
+)
+
+// POST /register-program-template
+func (h *Handler) registerProgramTemplate(ctx context.Context, t progtemplate.Template) error {
+	return h.Templates.Register(ctx, &t)
+}
+
+// POST /list-program-templates
+func (h *Handler) listProgramTemplates(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	templates, next, err := h.Templates.List(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(templates),
+		LastPage: len(templates) < limit,
+		Next:     outQuery,
+	}, nil
+}
@@ -1,15 +1,20 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
+	"time"
 
+	"chain/core/asset"
 	"chain/core/query"
 	"chain/core/query/filter"
 	"chain/errors"
 	"chain/net/http/httpjson"
+	"chain/protocol/bc"
 )
 
 // These types enforce the ordering of JSON fields in API output.
@@ -19,13 +24,21 @@ type (
 		AssetID         interface{} `json:"asset_id"`
 		AssetAlias      interface{} `json:"asset_alias,omitempty"`
 		AssetDefinition interface{} `json:"asset_definition"`
+		AssetRefDef     interface{} `json:"referenced_asset_definition,omitempty"`
 		AssetTags       interface{} `json:"asset_tags,omitempty"`
 		AssetIsLocal    interface{} `json:"asset_is_local"`
 		Amount          interface{} `json:"amount"`
+		DisplayValue    interface{} `json:"display_value,omitempty"`
 		IssuanceProgram interface{} `json:"issuance_program,omitempty"`
 		SpentOutput     interface{} `json:"spent_output,omitempty"`
+		ProgramType     interface{} `json:"program_type,omitempty"`
+		UnlockHeight    interface{} `json:"unlock_height,omitempty"`
+		UnlockTime      interface{} `json:"unlock_time,omitempty"`
+		Counterparty    interface{} `json:"counterparty,omitempty"`
+		ProgramTemplate interface{} `json:"program_template,omitempty"`
 		*txAccount
 		ReferenceData interface{} `json:"reference_data"`
+		Custom        interface{} `json:"custom,omitempty"`
 		IsLocal       interface{} `json:"is_local"`
 	}
 	txoutResp struct {
@@ -35,12 +48,20 @@ type (
 		AssetID         interface{} `json:"asset_id"`
 		AssetAlias      interface{} `json:"asset_alias,omitempty"`
 		AssetDefinition interface{} `json:"asset_definition"`
+		AssetRefDef     interface{} `json:"referenced_asset_definition,omitempty"`
 		AssetTags       interface{} `json:"asset_tags"`
 		AssetIsLocal    interface{} `json:"asset_is_local"`
 		Amount          interface{} `json:"amount"`
+		DisplayValue    interface{} `json:"display_value,omitempty"`
+		Counterparty    interface{} `json:"counterparty,omitempty"`
+		ProgramTemplate interface{} `json:"program_template,omitempty"`
 		*txAccount
 		ControlProgram interface{} `json:"control_program"`
+		ProgramType    interface{} `json:"program_type,omitempty"`
+		UnlockHeight   interface{} `json:"unlock_height,omitempty"`
+		UnlockTime     interface{} `json:"unlock_time,omitempty"`
 		ReferenceData  interface{} `json:"reference_data"`
+		Custom         interface{} `json:"custom,omitempty"`
 		IsLocal        interface{} `json:"is_local"`
 	}
 	txResp struct {
@@ -50,9 +71,12 @@ type (
 		BlockHeight   interface{} `json:"block_height"`
 		Position      interface{} `json:"position"`
 		ReferenceData interface{} `json:"reference_data"`
+		Custom        interface{} `json:"custom,omitempty"`
 		IsLocal       interface{} `json:"is_local"`
+		Pending       interface{} `json:"pending,omitempty"`
 		Inputs        interface{} `json:"inputs"`
 		Outputs       interface{} `json:"outputs"`
+		Fees          interface{} `json:"fees,omitempty"`
 	}
 	txAccount struct {
 		AccountID    interface{} `json:"account_id"`
@@ -66,6 +90,11 @@ type (
 //
 // POST /list-transactions
 func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result page, err error) {
+	err = h.applySession(ctx, &in)
+	if err != nil {
+		return result, err
+	}
+
 	var c context.CancelFunc
 	timeout := in.Timeout.Duration
 	if timeout != 0 {
@@ -83,7 +112,7 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 	}
 
 	// Build the filter predicate.
-	p, err = filter.Parse(in.Filter)
+	p, in.FilterParams, err = parseFilter(ctx, in, "asset_alias")
 	if err != nil {
 		return result, err
 	}
@@ -107,10 +136,26 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 		}
 	}
 
+	conv, err := h.displayConverter(ctx, in)
+	if err != nil {
+		return result, err
+	}
+
 	txns, nextAfter, err := h.Indexer.Transactions(ctx, p, in.FilterParams, after, limit, in.AscLongPoll)
 	if err != nil {
 		return result, errors.Wrap(err, "running tx query")
 	}
+	lastPage := len(txns) < limit
+
+	// Pending txs are newer than any in a block, so they
+	// belong at the start of the first page.
+	if in.IncludePending && in.After == "" && in.EndTimeMS == 0 && !in.AscLongPoll {
+		pending, err := h.Indexer.PendingTransactions(ctx, p, in.FilterParams, limit)
+		if err != nil {
+			return result, errors.Wrap(err, "running pending tx query")
+		}
+		txns = append(pending, txns...)
+	}
 
 	resp := make([]*txResp, 0, len(txns))
 	for _, t := range txns {
@@ -122,7 +167,9 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 			return result, fmt.Errorf("unexpected nil in Indexer.Transactions output")
 		}
 		var tx map[string]interface{}
-		err = json.Unmarshal(*tjson, &tx)
+		dec := json.NewDecoder(bytes.NewReader(*tjson))
+		dec.UseNumber() // amounts can exceed the precision of float64
+		err = dec.Decode(&tx)
 		if err != nil {
 			return result, errors.Wrap(err, "decoding Indexer.Transactions output")
 		}
@@ -155,26 +202,53 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 			outputs = append(outputs, output)
 		}
 
+		var txTime time.Time
+		if conv != nil {
+			txTime = time.Now() // pending txs have no timestamp
+			if ts, ok := tx["timestamp"].(string); ok {
+				txTime, err = time.Parse(time.RFC3339, ts)
+				if err != nil {
+					return result, errors.Wrap(err, "decoding transaction timestamp")
+				}
+			}
+		}
+
 		inResps := make([]*txinResp, 0, len(inputs))
 		for _, in := range inputs {
+			display, err := convertAmount(ctx, conv, in, txTime)
+			if err != nil {
+				return result, err
+			}
 			r := &txinResp{
 				Type:            in["type"],
 				AssetID:         in["asset_id"],
 				AssetAlias:      in["asset_alias"],
 				AssetDefinition: in["asset_definition"],
+				AssetRefDef:     in["referenced_asset_definition"],
 				AssetTags:       in["asset_tags"],
 				AssetIsLocal:    in["asset_is_local"],
 				Amount:          in["amount"],
+				DisplayValue:    display,
 				IssuanceProgram: in["issuance_program"],
 				SpentOutput:     in["spent_output"],
+				ProgramType:     in["program_type"],
+				UnlockHeight:    in["unlock_height"],
+				UnlockTime:      in["unlock_time"],
+				Counterparty:    in["counterparty"],
+				ProgramTemplate: in["program_template"],
 				txAccount:       txAccountFromMap(in),
 				ReferenceData:   in["reference_data"],
+				Custom:          in["custom"],
 				IsLocal:         in["is_local"],
 			}
 			inResps = append(inResps, r)
 		}
 		outResps := make([]*txoutResp, 0, len(outputs))
 		for _, out := range outputs {
+			display, err := convertAmount(ctx, conv, out, txTime)
+			if err != nil {
+				return result, err
+			}
 			r := &txoutResp{
 				Type:            out["type"],
 				Purpose:         out["purpose"],
@@ -182,12 +256,20 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 				AssetID:         out["asset_id"],
 				AssetAlias:      out["asset_alias"],
 				AssetDefinition: out["asset_definition"],
+				AssetRefDef:     out["referenced_asset_definition"],
 				AssetTags:       out["asset_tags"],
 				AssetIsLocal:    out["asset_is_local"],
 				Amount:          out["amount"],
+				DisplayValue:    display,
+				Counterparty:    out["counterparty"],
+				ProgramTemplate: out["program_template"],
 				txAccount:       txAccountFromMap(out),
 				ControlProgram:  out["control_program"],
+				ProgramType:     out["program_type"],
+				UnlockHeight:    out["unlock_height"],
+				UnlockTime:      out["unlock_time"],
 				ReferenceData:   out["reference_data"],
+				Custom:          out["custom"],
 				IsLocal:         out["is_local"],
 			}
 			outResps = append(outResps, r)
@@ -199,9 +281,12 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 			BlockHeight:   tx["block_height"],
 			Position:      tx["position"],
 			ReferenceData: tx["reference_data"],
+			Custom:        tx["custom"],
 			IsLocal:       tx["is_local"],
+			Pending:       tx["pending"],
 			Inputs:        inResps,
 			Outputs:       outResps,
+			Fees:          tx["fees"],
 		}
 		resp = append(resp, r)
 	}
@@ -210,13 +295,123 @@ func (h *Handler) listTransactions(ctx context.Context, in requestQuery) (result
 	out.After = nextAfter.String()
 	return page{
 		Items:    httpjson.Array(resp),
-		LastPage: len(resp) < limit,
+		LastPage: lastPage,
 		Next:     out,
 	}, nil
 }
 
+type analyticsRequest struct {
+	requestQuery
+	Anonymization query.Anonymization `json:"anonymization"`
+}
+
+// exportAnalyticsFlows is an http handler returning the inputs
+// and outputs of transactions matching a filter, oldest first,
+// flattened and anonymized for analytics.
+//
+// POST /export-analytics-flows
+func (h *Handler) exportAnalyticsFlows(ctx context.Context, in analyticsRequest) (interface{}, error) {
+	limit := in.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	p, params, err := parseFilter(ctx, in.requestQuery, "asset_alias")
+	if err != nil {
+		return nil, err
+	}
+	after := query.TxAfter{StopBlockHeight: math.MaxInt64}
+	if in.After != "" {
+		after, err = query.DecodeTxAfter(in.After)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding `after`")
+		}
+	}
+
+	flows, next, last, err := h.Indexer.ExportFlows(ctx, p, params, after, limit, in.Anonymization)
+	if err != nil {
+		return nil, errors.Wrap(err, "exporting flows")
+	}
+	out := in
+	out.After = next.String()
+	return struct {
+		Items    interface{}      `json:"items"`
+		Next     analyticsRequest `json:"next"`
+		LastPage bool             `json:"last_page"`
+	}{httpjson.Array(flows), out, last}, nil
+}
+
+// parseFilter parses the filter of a query. With an access
+// token bound to a namespace, the asset aliases it compares
+// to attr are qualified with the namespace.
+func parseFilter(ctx context.Context, in requestQuery, attr string) (filter.Predicate, []interface{}, error) {
+	p, err := filter.Parse(in.Filter)
+	if err != nil {
+		return p, nil, err
+	}
+	namespace := aliasNamespace(ctx)
+	if namespace == "" {
+		return p, in.FilterParams, nil
+	}
+	return filter.MapStrings(p, in.FilterParams, []string{attr}, func(alias string) (string, error) {
+		return asset.QualifyAlias(namespace, alias)
+	})
+}
+
+// displayConverter returns a converter to the display asset
+// named in the query, or nil if it names none.
+func (h *Handler) displayConverter(ctx context.Context, in requestQuery) (*query.Converter, error) {
+	var assetID bc.AssetID
+	switch {
+	case in.DisplayAssetID != "":
+		err := assetID.UnmarshalText([]byte(in.DisplayAssetID))
+		if err != nil {
+			return nil, errors.WithDetail(httpjson.ErrBadRequest, "invalid display_asset_id")
+		}
+	case in.DisplayAssetAlias != "":
+		a, err := h.findAssetByAlias(ctx, in.DisplayAssetAlias)
+		if err != nil {
+			return nil, errors.Wrapf(err, "looking up display asset %s", in.DisplayAssetAlias)
+		}
+		assetID = a.AssetID
+	default:
+		return nil, nil
+	}
+	return h.Indexer.NewConverter(assetID)
+}
+
+// convertAmount returns the amount of an annotated input or
+// output converted to the display asset at time t, or nil if
+// there is no display asset or no rate.
+func convertAmount(ctx context.Context, conv *query.Converter, m map[string]interface{}, t time.Time) (interface{}, error) {
+	if conv == nil {
+		return nil, nil
+	}
+	n, ok := m["amount"].(json.Number)
+	if !ok {
+		return nil, nil
+	}
+	amount, err := strconv.ParseUint(string(n), 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding amount")
+	}
+	var assetID bc.AssetID
+	s, _ := m["asset_id"].(string)
+	err = assetID.UnmarshalText([]byte(s))
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding asset id")
+	}
+	c, err := conv.Convert(ctx, amount, assetID, t)
+	if c == nil {
+		// Don't return a typed nil; it would be
+		// encoded as null instead of omitted.
+		return nil, err
+	}
+	return c, nil
+}
+
 // listAccounts is an http handler for listing accounts matching
-// an index or an ad-hoc filter.
+// an index or an ad-hoc filter. Archived accounts are left out
+// unless include_archived is set.
 //
 // POST /list-accounts
 func (h *Handler) listAccounts(ctx context.Context, in requestQuery) (page, error) {
@@ -233,7 +428,7 @@ func (h *Handler) listAccounts(ctx context.Context, in requestQuery) (page, erro
 	after := in.After
 
 	// Use the filter engine for querying account tags.
-	accounts, after, err := h.Indexer.Accounts(ctx, p, in.FilterParams, after, limit)
+	accounts, after, err := h.Indexer.Accounts(ctx, p, in.FilterParams, after, limit, in.IncludeArchived)
 	if err != nil {
 		return page{}, errors.Wrap(err, "running acc query")
 	}
@@ -256,11 +451,14 @@ func (h *Handler) listAccounts(ctx context.Context, in requestQuery) (page, erro
 			}
 		}
 		r := &accountResponse{
-			ID:     a["id"],
-			Alias:  a["alias"],
-			Keys:   orderedKeys,
-			Quorum: a["quorum"],
-			Tags:   a["tags"],
+			ID:          a["id"],
+			Alias:       a["alias"],
+			Keys:        orderedKeys,
+			Quorum:      a["quorum"],
+			Tags:        a["tags"],
+			IsArchived:  a["is_archived"],
+			EpochPeriod: a["epoch_period"],
+			ParentID:    a["parent_id"],
 		}
 		result = append(result, r)
 	}
@@ -277,9 +475,14 @@ func (h *Handler) listAccounts(ctx context.Context, in requestQuery) (page, erro
 
 // POST /list-balances
 func (h *Handler) listBalances(ctx context.Context, in requestQuery) (result page, err error) {
+	err = h.applySession(ctx, &in)
+	if err != nil {
+		return result, err
+	}
+
 	var p filter.Predicate
 	var sumBy []filter.Field
-	p, err = filter.Parse(in.Filter)
+	p, in.FilterParams, err = parseFilter(ctx, in, "asset_alias")
 	if err != nil {
 		return result, err
 	}
@@ -305,12 +508,29 @@ func (h *Handler) listBalances(ctx context.Context, in requestQuery) (result pag
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
 
+	conv, err := h.displayConverter(ctx, in)
+	if err != nil {
+		return result, err
+	}
+
 	// TODO(jackson): paginate this endpoint.
-	balances, err := h.Indexer.Balances(ctx, p, in.FilterParams, sumBy, timestampMS)
+	pending := in.IncludePending && in.TimestampMS == 0
+	balances, err := h.Indexer.Balances(ctx, p, in.FilterParams, sumBy, timestampMS, pending)
 	if err != nil {
 		return result, err
 	}
 
+	if conv != nil {
+		t := time.Now()
+		if in.TimestampMS != 0 {
+			t = time.Unix(0, int64(in.TimestampMS)*int64(time.Millisecond))
+		}
+		err = conv.ConvertBalances(ctx, balances, t)
+		if err != nil {
+			return result, err
+		}
+	}
+
 	result.Items = httpjson.Array(balances)
 	result.LastPage = true
 	result.Next = in
@@ -326,19 +546,33 @@ type utxoResp struct {
 	AssetID         interface{} `json:"asset_id"`
 	AssetAlias      interface{} `json:"asset_alias"`
 	AssetDefinition interface{} `json:"asset_definition"`
+	AssetRefDef     interface{} `json:"referenced_asset_definition,omitempty"`
 	AssetTags       interface{} `json:"asset_tags"`
 	AssetIsLocal    interface{} `json:"asset_is_local"`
 	Amount          interface{} `json:"amount"`
 	AccountID       interface{} `json:"account_id"`
 	AccountAlias    interface{} `json:"account_alias"`
 	AccountTags     interface{} `json:"account_tags"`
+	AccountKeyGen   interface{} `json:"account_key_generation,omitempty"`
+	Counterparty    interface{} `json:"counterparty,omitempty"`
+	ProgramTemplate interface{} `json:"program_template,omitempty"`
 	ControlProgram  interface{} `json:"control_program"`
+	ProgramType     interface{} `json:"program_type,omitempty"`
+	UnlockHeight    interface{} `json:"unlock_height,omitempty"`
+	UnlockTime      interface{} `json:"unlock_time,omitempty"`
+	SpentBy         interface{} `json:"spent_by,omitempty"`
 	ReferenceData   interface{} `json:"reference_data"`
+	Custom          interface{} `json:"custom,omitempty"`
 	IsLocal         interface{} `json:"is_local"`
 }
 
 // POST /list-unspent-outputs
 func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (result page, err error) {
+	err = h.applySession(ctx, &in)
+	if err != nil {
+		return result, err
+	}
+
 	limit := in.PageSize
 	if limit == 0 {
 		limit = defGenericPageSize
@@ -346,7 +580,7 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 
 	// Build the filter predicate.
 	var p filter.Predicate
-	p, err = filter.Parse(in.Filter)
+	p, in.FilterParams, err = parseFilter(ctx, in, "asset_alias")
 	if err != nil {
 		return result, err
 	}
@@ -365,7 +599,7 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 	} else if timestampMS > math.MaxInt64 {
 		return result, errors.WithDetail(httpjson.ErrBadRequest, "timestamp is too large")
 	}
-	outputs, nextAfter, err := h.Indexer.Outputs(ctx, p, in.FilterParams, timestampMS, after, limit)
+	outputs, nextAfter, err := h.Indexer.Outputs(ctx, p, in.FilterParams, timestampMS, in.IncludeSpent, after, limit)
 	if err != nil {
 		return result, errors.Wrap(err, "querying outputs")
 	}
@@ -380,7 +614,9 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 			return result, fmt.Errorf("unexpected nil in Indexer.Outputs output")
 		}
 		var out map[string]interface{}
-		err = json.Unmarshal(*ojson, &out)
+		dec := json.NewDecoder(bytes.NewReader(*ojson))
+		dec.UseNumber() // amounts can exceed the precision of float64
+		err = dec.Decode(&out)
 		if err != nil {
 			return result, errors.Wrap(err, "decoding Indexer.Outputs output")
 		}
@@ -392,14 +628,23 @@ func (h *Handler) listUnspentOutputs(ctx context.Context, in requestQuery) (resu
 			AssetID:         out["asset_id"],
 			AssetAlias:      out["asset_alias"],
 			AssetDefinition: out["asset_definition"],
+			AssetRefDef:     out["referenced_asset_definition"],
 			AssetTags:       out["asset_tags"],
 			AssetIsLocal:    out["asset_is_local"],
 			Amount:          out["amount"],
 			AccountID:       out["account_id"],
 			AccountAlias:    out["account_alias"],
 			AccountTags:     out["account_tags"],
+			AccountKeyGen:   out["account_key_generation"],
+			Counterparty:    out["counterparty"],
+			ProgramTemplate: out["program_template"],
 			ControlProgram:  out["control_program"],
+			ProgramType:     out["program_type"],
+			UnlockHeight:    out["unlock_height"],
+			UnlockTime:      out["unlock_time"],
+			SpentBy:         out["spent_by"],
 			ReferenceData:   out["reference_data"],
+			Custom:          out["custom"],
 			IsLocal:         out["is_local"],
 		}
 		resp = append(resp, r)
@@ -425,7 +670,7 @@ func (h *Handler) listAssets(ctx context.Context, in requestQuery) (page, error)
 	}
 
 	// Build the filter predicate.
-	p, err := filter.Parse(in.Filter)
+	p, params, err := parseFilter(ctx, in, "alias")
 	if err != nil {
 		return page{}, err
 	}
@@ -433,41 +678,19 @@ func (h *Handler) listAssets(ctx context.Context, in requestQuery) (page, error)
 
 	// Use the query engine for querying asset tags.
 	var assets []map[string]interface{}
-	assets, after, err = h.Indexer.Assets(ctx, p, in.FilterParams, after, limit)
+	assets, after, err = h.Indexer.Assets(ctx, p, params, after, limit, in.IncludeArchived)
 	if err != nil {
 		return page{}, errors.Wrap(err, "running asset query")
 	}
 
 	result := make([]*assetResponse, 0, len(assets))
 	for _, a := range assets {
-		var orderedKeys []assetKey
-		keys, ok := a["keys"].([]interface{})
-		if ok {
-			for _, key := range keys {
-				mapKey, ok := key.(map[string]interface{})
-				if !ok {
-					continue
-				}
-				orderedKeys = append(orderedKeys, assetKey{
-					AssetPubkey:         mapKey["asset_pubkey"],
-					RootXPub:            mapKey["root_xpub"],
-					AssetDerivationPath: mapKey["asset_derivation_path"],
-				})
-			}
-		}
-		r := &assetResponse{
-			ID:              a["id"],
-			IssuanceProgram: a["issuance_program"],
-			Keys:            orderedKeys,
-			Quorum:          a["quorum"],
-			Definition:      a["definition"],
-			Tags:            a["tags"],
-			IsLocal:         a["is_local"],
-		}
-		if alias, ok := a["alias"].(string); ok && alias != "" {
-			r.Alias = &alias
-		}
-		result = append(result, r)
+		result = append(result, assetResponseFromMap(a))
+	}
+
+	err = h.addIssuanceStatuses(ctx, result)
+	if err != nil {
+		return page{}, err
 	}
 
 	out := in
@@ -479,6 +702,42 @@ func (h *Handler) listAssets(ctx context.Context, in requestQuery) (page, error)
 	}, nil
 }
 
+// assetResponseFromMap converts an annotated asset,
+// as stored by the query indexer, to its API form.
+func assetResponseFromMap(a map[string]interface{}) *assetResponse {
+	var orderedKeys []assetKey
+	keys, ok := a["keys"].([]interface{})
+	if ok {
+		for _, key := range keys {
+			mapKey, ok := key.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			orderedKeys = append(orderedKeys, assetKey{
+				AssetPubkey:         mapKey["asset_pubkey"],
+				RootXPub:            mapKey["root_xpub"],
+				AssetDerivationPath: mapKey["asset_derivation_path"],
+			})
+		}
+	}
+	r := &assetResponse{
+		ID:              a["id"],
+		IssuanceProgram: a["issuance_program"],
+		Keys:            orderedKeys,
+		Quorum:          a["quorum"],
+		Definition:      a["definition"],
+		Tags:            a["tags"],
+		IsLocal:         a["is_local"],
+		IsArchived:      a["is_archived"],
+		MaxIssuance:     a["max_issuance"],
+		SuccessorID:     a["successor_id"],
+	}
+	if alias, ok := a["alias"].(string); ok && alias != "" {
+		r.Alias = &alias
+	}
+	return r
+}
+
 func txAccountFromMap(m map[string]interface{}) *txAccount {
 	if _, ok := m["account_id"]; !ok {
 		return nil
@@ -514,3 +773,36 @@ func (h *Handler) listTxFeeds(ctx context.Context, in requestQuery) (page, error
 		Next:     out,
 	}, nil
 }
+
+// queryStats is an http handler reporting execution statistics
+// for each filter queried, and the most recent slow queries.
+//
+// POST /debug/query-stats
+func (h *Handler) queryStats(ctx context.Context) interface{} {
+	filters, slow := h.Indexer.QueryStats()
+	return struct {
+		Filters     []query.FilterStats `json:"filters"`
+		SlowQueries []query.SlowQuery   `json:"slow_queries"`
+	}{filters, slow}
+}
+
+// generateAccountStatement is an http handler returning a
+// reconciled statement of an account's transactions over
+// a period.
+//
+// POST /generate-account-statement
+func (h *Handler) generateAccountStatement(ctx context.Context, in struct {
+	AccountID    string `json:"account_id"`
+	AccountAlias string `json:"account_alias"`
+	query.Period
+}) (*query.Statement, error) {
+	accountID := in.AccountID
+	if accountID == "" {
+		acc, err := h.Accounts.FindByAlias(ctx, in.AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		accountID = acc.ID
+	}
+	return h.Indexer.GenerateStatement(ctx, accountID, in.Period)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
 	"chain/core/query/filter"
 	"chain/errors"
@@ -26,8 +27,55 @@ func (ind *Indexer) SaveAnnotatedAccount(ctx context.Context, accountID string,
 	return errors.Wrap(err, "saving annotated account")
 }
 
+// UpdateAccountAnnotations replaces the account tags on the
+// indexed inputs and outputs of the account, after they've
+// changed. The annotated account itself is saved with
+// SaveAnnotatedAccount.
+func (ind *Indexer) UpdateAccountAnnotations(ctx context.Context, accountID string, tags map[string]interface{}) error {
+	annotations := map[string]interface{}{}
+	if len(tags) > 0 {
+		annotations["account_tags"] = tags
+	}
+	patch, err := json.Marshal(annotations)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	match, err := json.Marshal(map[string]interface{}{"account_id": accountID})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	const outputsQ = `
+		UPDATE annotated_outputs SET data = (data - 'account_tags') || $2::jsonb
+		WHERE data @> $1::jsonb
+	`
+	_, err = ind.db.Exec(ctx, outputsQ, match, patch)
+	if err != nil {
+		return errors.Wrap(err, "updating annotated outputs")
+	}
+
+	// Rewrite the inputs and outputs arrays of each
+	// transaction, patching the entries for this account.
+	const txsQ = `
+		UPDATE annotated_txs SET data = jsonb_set(jsonb_set(data,
+			'{inputs}', COALESCE((
+				SELECT jsonb_agg(CASE WHEN e @> $1::jsonb THEN (e - 'account_tags') || $2::jsonb ELSE e END ORDER BY i)
+				FROM jsonb_array_elements(data->'inputs') WITH ORDINALITY AS t(e, i)
+			), '[]')),
+			'{outputs}', COALESCE((
+				SELECT jsonb_agg(CASE WHEN e @> $1::jsonb THEN (e - 'account_tags') || $2::jsonb ELSE e END ORDER BY i)
+				FROM jsonb_array_elements(data->'outputs') WITH ORDINALITY AS t(e, i)
+			), '[]'))
+		WHERE data @> jsonb_build_object('inputs', jsonb_build_array($1::jsonb))
+			OR data @> jsonb_build_object('outputs', jsonb_build_array($1::jsonb))
+	`
+	_, err = ind.db.Exec(ctx, txsQ, match, patch)
+	return errors.Wrap(err, "updating annotated transactions")
+}
+
 // Accounts queries the blockchain for accounts matching the query `q`.
-func (ind *Indexer) Accounts(ctx context.Context, p filter.Predicate, vals []interface{}, after string, limit int) ([]map[string]interface{}, string, error) {
+// Archived accounts are left out unless includeArchived is set.
+func (ind *Indexer) Accounts(ctx context.Context, p filter.Predicate, vals []interface{}, after string, limit int, includeArchived bool) ([]map[string]interface{}, string, error) {
 	if len(vals) != p.Parameters {
 		return nil, "", ErrParameterCountMismatch
 	}
@@ -36,7 +84,8 @@ func (ind *Indexer) Accounts(ctx context.Context, p filter.Predicate, vals []int
 		return nil, "", errors.Wrap(err, "converting to SQL")
 	}
 
-	queryStr, queryArgs := constructAccountsQuery(expr, after, limit)
+	queryStr, queryArgs := constructAccountsQuery(expr, after, limit, includeArchived)
+	start := time.Now()
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, "", errors.Wrap(err, "executing acc query")
@@ -63,10 +112,11 @@ func (ind *Indexer) Accounts(ctx context.Context, p filter.Predicate, vals []int
 		after = accID
 		accounts = append(accounts, account)
 	}
+	ind.recordQuery(ctx, "accounts", p, queryStr, queryArgs, time.Since(start), len(accounts))
 	return accounts, after, errors.Wrap(rows.Err())
 }
 
-func constructAccountsQuery(expr filter.SQLExpr, after string, limit int) (string, []interface{}) {
+func constructAccountsQuery(expr filter.SQLExpr, after string, limit int, includeArchived bool) (string, []interface{}) {
 	var buf bytes.Buffer
 	var vals []interface{}
 
@@ -81,6 +131,10 @@ func constructAccountsQuery(expr filter.SQLExpr, after string, limit int) (strin
 		buf.WriteString(") AND ")
 	}
 
+	if !includeArchived {
+		buf.WriteString(`NOT (data @> '{"is_archived": "yes"}') AND `)
+	}
+
 	// add after conditions
 	buf.WriteString(fmt.Sprintf("($%d='' OR id < $%d) ", len(vals)+1, len(vals)+1))
 	vals = append(vals, after)
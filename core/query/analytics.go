@@ -0,0 +1,214 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"golang.org/x/crypto/sha3"
+
+	"chain/core/query/filter"
+	"chain/errors"
+)
+
+// ErrBadAnonymization is returned by ExportFlows
+// when the anonymization settings are invalid.
+var ErrBadAnonymization = errors.New("invalid anonymization")
+
+// An Anonymization says how ExportFlows hides
+// sensitive information in the flows it exports.
+type Anonymization struct {
+	// HashAccountIDs replaces account IDs with keyed hashes,
+	// so flows of the same account can be grouped without
+	// revealing which account it is. Salt is the key; it is
+	// required, and exports with the same salt hash the same
+	// account the same way.
+	HashAccountIDs bool   `json:"hash_account_ids"`
+	Salt           string `json:"salt"`
+
+	// DropReferenceData leaves out the reference
+	// data of transactions, inputs and outputs.
+	DropReferenceData bool `json:"drop_reference_data"`
+
+	// AmountBuckets, if set, replaces each amount with the
+	// range between the bucket boundaries it falls in.
+	AmountBuckets []uint64 `json:"amount_buckets,omitempty"`
+}
+
+// A Flow is one input or output of a transaction, flattened
+// for analysis and stripped of what an Anonymization hides.
+// Account annotations other than the ID, such as aliases and
+// tags, are always left out.
+//
+// With amount buckets, Amount is left out, and the amount
+// is at least AmountMin and, if AmountMax is set, less than
+// AmountMax.
+type Flow struct {
+	TransactionID            string                 `json:"transaction_id"`
+	BlockHeight              uint64                 `json:"block_height"`
+	Timestamp                string                 `json:"timestamp"`
+	Type                     string                 `json:"type"`
+	Position                 int                    `json:"position"`
+	AssetID                  string                 `json:"asset_id"`
+	AssetAlias               string                 `json:"asset_alias,omitempty"`
+	AccountID                string                 `json:"account_id,omitempty"`
+	Purpose                  string                 `json:"purpose,omitempty"`
+	Amount                   *uint64                `json:"amount,omitempty"`
+	AmountMin                *uint64                `json:"amount_min,omitempty"`
+	AmountMax                *uint64                `json:"amount_max,omitempty"`
+	ReferenceData            map[string]interface{} `json:"reference_data,omitempty"`
+	TransactionReferenceData map[string]interface{} `json:"transaction_reference_data,omitempty"`
+}
+
+func (a *Anonymization) check() error {
+	if a.HashAccountIDs && a.Salt == "" {
+		return errors.WithDetail(ErrBadAnonymization, "hashing account IDs requires a salt")
+	}
+	for i := 1; i < len(a.AmountBuckets); i++ {
+		if a.AmountBuckets[i] <= a.AmountBuckets[i-1] {
+			return errors.WithDetail(ErrBadAnonymization, "amount buckets must be increasing")
+		}
+	}
+	return nil
+}
+
+func (a *Anonymization) accountID(id string) string {
+	if !a.HashAccountIDs {
+		return id
+	}
+	mac := hmac.New(sha3.New256, []byte(a.Salt))
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// amount sets the amount fields of f.
+func (a *Anonymization) amount(f *Flow, amount uint64) {
+	if len(a.AmountBuckets) == 0 {
+		f.Amount = &amount
+		return
+	}
+	// i is the index of the first boundary above amount.
+	i := sort.Search(len(a.AmountBuckets), func(i int) bool { return a.AmountBuckets[i] > amount })
+	var min uint64
+	if i > 0 {
+		min = a.AmountBuckets[i-1]
+	}
+	f.AmountMin = &min
+	if i < len(a.AmountBuckets) {
+		max := a.AmountBuckets[i]
+		f.AmountMax = &max
+	}
+}
+
+// ExportFlows returns the inputs and outputs of up to limit
+// transactions matching the filter predicate p, oldest first,
+// starting after the transaction identified by after, as flows
+// anonymized by anon. It also returns the cursor of the last
+// transaction, to continue from, and whether there were fewer
+// than limit transactions left.
+func (ind *Indexer) ExportFlows(ctx context.Context, p filter.Predicate, vals []interface{}, after TxAfter, limit int, anon Anonymization) (flows []*Flow, next *TxAfter, last bool, err error) {
+	err = anon.check()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(vals) != p.Parameters {
+		return nil, nil, false, ErrParameterCountMismatch
+	}
+	expr, err := filter.AsSQL(p, "data", vals)
+	if err != nil {
+		return nil, nil, false, errors.Wrap(err, "converting to SQL")
+	}
+
+	// Unlike ascending transaction queries, exports
+	// don't wait for new blocks when they run out.
+	queryStr, queryArgs := constructTransactionsQuery(expr, after, true, limit)
+	txs, next, err := ind.fetchTransactions(ctx, p, queryStr, queryArgs, after, limit)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	flows = make([]*Flow, 0, 2*len(txs))
+	for _, t := range txs {
+		txFlows, err := anon.flows(*t.(*json.RawMessage))
+		if err != nil {
+			return nil, nil, false, err
+		}
+		flows = append(flows, txFlows...)
+	}
+	return flows, next, len(txs) < limit, nil
+}
+
+// annotatedIO holds the fields of an annotated
+// input or output that flows use.
+type annotatedIO struct {
+	Type          string                 `json:"type"`
+	AssetID       string                 `json:"asset_id"`
+	AssetAlias    string                 `json:"asset_alias"`
+	AccountID     string                 `json:"account_id"`
+	Purpose       string                 `json:"purpose"`
+	Amount        json.Number            `json:"amount"`
+	ReferenceData map[string]interface{} `json:"reference_data"`
+}
+
+// flows returns the anonymized flows of the annotated transaction data.
+func (a *Anonymization) flows(data []byte) ([]*Flow, error) {
+	var tx struct {
+		ID            string                 `json:"id"`
+		BlockHeight   uint64                 `json:"block_height"`
+		Timestamp     string                 `json:"timestamp"`
+		ReferenceData map[string]interface{} `json:"reference_data"`
+		Inputs        []annotatedIO          `json:"inputs"`
+		Outputs       []annotatedIO          `json:"outputs"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // amounts can exceed the precision of float64
+	err := dec.Decode(&tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding annotated transaction")
+	}
+
+	var flows []*Flow
+	add := func(io annotatedIO, pos int) error {
+		amount, err := strconv.ParseUint(string(io.Amount), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "decoding amount of transaction %s", tx.ID)
+		}
+		f := &Flow{
+			TransactionID: tx.ID,
+			BlockHeight:   tx.BlockHeight,
+			Timestamp:     tx.Timestamp,
+			Type:          io.Type,
+			Position:      pos,
+			AssetID:       io.AssetID,
+			AssetAlias:    io.AssetAlias,
+			Purpose:       io.Purpose,
+		}
+		if io.AccountID != "" {
+			f.AccountID = a.accountID(io.AccountID)
+		}
+		a.amount(f, amount)
+		if !a.DropReferenceData {
+			f.ReferenceData = io.ReferenceData
+			f.TransactionReferenceData = tx.ReferenceData
+		}
+		flows = append(flows, f)
+		return nil
+	}
+	for i, in := range tx.Inputs {
+		err = add(in, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i, out := range tx.Outputs {
+		err = add(out, i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return flows, nil
+}
@@ -0,0 +1,90 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/errors"
+)
+
+func TestAnonymizeFlows(t *testing.T) {
+	const data = `{
+		"id": "t1",
+		"block_height": 7,
+		"timestamp": "2017-01-26T00:00:00Z",
+		"reference_data": {"invoice": "123"},
+		"inputs": [{
+			"type": "spend",
+			"asset_id": "a1",
+			"account_id": "acc1",
+			"account_alias": "alice",
+			"account_tags": {"name": "Alice"},
+			"amount": 9007199254740993,
+			"reference_data": {"note": "x"}
+		}],
+		"outputs": [{
+			"type": "control",
+			"position": 0,
+			"asset_id": "a1",
+			"account_id": "acc2",
+			"purpose": "receive",
+			"amount": 150
+		}]
+	}`
+
+	anon := Anonymization{
+		HashAccountIDs:    true,
+		Salt:              "s",
+		DropReferenceData: true,
+		AmountBuckets:     []uint64{100, 1000},
+	}
+	err := anon.check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flows, err := anon.flows([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flows) != 2 {
+		t.Fatalf("got %d flows want 2", len(flows))
+	}
+
+	in, out := flows[0], flows[1]
+	if in.AccountID == "acc1" || in.AccountID != anon.accountID("acc1") || in.AccountID == out.AccountID {
+		t.Errorf("account IDs = %q, %q, want distinct keyed hashes", in.AccountID, out.AccountID)
+	}
+	if in.ReferenceData != nil || in.TransactionReferenceData != nil {
+		t.Error("reference data was not dropped")
+	}
+	if in.Amount != nil || *in.AmountMin != 1000 || in.AmountMax != nil {
+		t.Errorf("input amount bucket = %v, %v want 1000 and up", in.AmountMin, in.AmountMax)
+	}
+	if *out.AmountMin != 100 || *out.AmountMax != 1000 {
+		t.Errorf("output amount bucket = %d, %d want 100, 1000", *out.AmountMin, *out.AmountMax)
+	}
+	if out.Purpose != "receive" || out.Type != "control" || out.BlockHeight != 7 {
+		t.Errorf("output flow = %+v", out)
+	}
+
+	// Without anonymization, amounts are exact.
+	flows, err = new(Anonymization).flows([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *flows[0].Amount != 9007199254740993 || flows[0].AccountID != "acc1" {
+		t.Errorf("input flow = %+v", flows[0])
+	}
+	if !reflect.DeepEqual(flows[0].TransactionReferenceData, map[string]interface{}{"invoice": "123"}) {
+		t.Errorf("transaction reference data = %v", flows[0].TransactionReferenceData)
+	}
+
+	err = (&Anonymization{HashAccountIDs: true}).check()
+	if errors.Root(err) != ErrBadAnonymization {
+		t.Errorf("hashing without a salt: err = %v want %v", err, ErrBadAnonymization)
+	}
+	err = (&Anonymization{AmountBuckets: []uint64{10, 10}}).check()
+	if errors.Root(err) != ErrBadAnonymization {
+		t.Errorf("non-increasing buckets: err = %v want %v", err, ErrBadAnonymization)
+	}
+}
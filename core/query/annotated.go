@@ -13,15 +13,22 @@ import (
 	"chain/protocol/vmutil"
 )
 
+// transactionObject returns the annotated form of orig.
+// If b is nil, orig is pending: it has been submitted but
+// is not yet in a block.
 func transactionObject(orig *bc.Tx, b *bc.Block, indexInBlock uint32) map[string]interface{} {
 	m := map[string]interface{}{
 		"id":             orig.Hash.String(),
-		"timestamp":      b.Time().Format(time.RFC3339),
-		"block_id":       b.Hash().String(),
-		"block_height":   b.Height,
-		"position":       indexInBlock,
 		"reference_data": unmarshalReferenceData(orig.ReferenceData),
 	}
+	if b != nil {
+		m["timestamp"] = b.Time().Format(time.RFC3339)
+		m["block_id"] = b.Hash().String()
+		m["block_height"] = b.Height
+		m["position"] = indexInBlock
+	} else {
+		m["pending"] = true
+	}
 
 	inputs := make([]interface{}, 0, len(orig.Inputs))
 	for _, in := range orig.Inputs {
@@ -33,10 +40,34 @@ func transactionObject(orig *bc.Tx, b *bc.Block, indexInBlock uint32) map[string
 	}
 	m["inputs"] = inputs
 	m["outputs"] = outputs
+	m["fees"] = transactionFees(orig)
 
 	return m
 }
 
+// transactionFees sums the amounts of each asset that orig
+// pays to the fee program, in order of first appearance.
+func transactionFees(orig *bc.Tx) []interface{} {
+	fees := make([]interface{}, 0)
+	byAsset := make(map[bc.AssetID]map[string]interface{})
+	for _, out := range orig.Outputs {
+		if !vmutil.IsFeeProgram(out.ControlProgram) {
+			continue
+		}
+		fee := byAsset[out.AssetID]
+		if fee == nil {
+			fee = map[string]interface{}{
+				"asset_id": out.AssetID.String(),
+				"amount":   uint64(0),
+			}
+			byAsset[out.AssetID] = fee
+			fees = append(fees, fee)
+		}
+		fee["amount"] = fee["amount"].(uint64) + out.Amount
+	}
+	return fees
+}
+
 func transactionInput(in *bc.TxInput) map[string]interface{} {
 	obj := map[string]interface{}{
 		"asset_id":       in.AssetID().String(),
@@ -47,10 +78,13 @@ func transactionInput(in *bc.TxInput) map[string]interface{} {
 	if in.IsIssuance() {
 		obj["type"] = "issue"
 		obj["issuance_program"] = hex.EncodeToString(in.IssuanceProgram())
+		obj["program_type"] = vmutil.ProgramType(in.IssuanceProgram())
 	} else {
 		outpoint := in.Outpoint()
 		obj["type"] = "spend"
 		obj["control_program"] = hex.EncodeToString(in.ControlProgram())
+		obj["program_type"] = vmutil.ProgramType(in.ControlProgram())
+		addTimeLock(obj, in.ControlProgram())
 		obj["spent_output"] = map[string]interface{}{
 			"transaction_id": outpoint.Hash.String(),
 			"position":       outpoint.Index,
@@ -65,9 +99,12 @@ func transactionOutput(out *bc.TxOutput, idx uint32) map[string]interface{} {
 		"asset_id":        out.AssetID.String(),
 		"amount":          out.Amount,
 		"control_program": hex.EncodeToString(out.ControlProgram),
+		"program_type":    vmutil.ProgramType(out.ControlProgram),
 		"reference_data":  unmarshalReferenceData(out.ReferenceData),
 	}
 
+	addTimeLock(obj, out.ControlProgram)
+
 	if vmutil.IsUnspendable(out.ControlProgram) {
 		obj["type"] = "retire"
 	} else {
@@ -76,6 +113,22 @@ func transactionOutput(out *bc.TxOutput, idx uint32) map[string]interface{} {
 	return obj
 }
 
+// addTimeLock adds the unlock height and time of
+// prog to obj, if prog is a time lock program.
+func addTimeLock(obj map[string]interface{}, prog []byte) {
+	_, height, unlockTime, err := vmutil.ParseTimeLockProgram(prog)
+	if err != nil {
+		return
+	}
+	if height > 0 {
+		obj["unlock_height"] = height
+	}
+	if unlockTime > 0 {
+		t := time.Unix(0, int64(unlockTime)*int64(time.Millisecond)).UTC()
+		obj["unlock_time"] = t.Format(time.RFC3339)
+	}
+}
+
 func unmarshalReferenceData(data []byte) map[string]interface{} {
 	var obj map[string]interface{}
 	err := json.Unmarshal(data, &obj)
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
 	"chain/core/query/filter"
 	"chain/errors"
@@ -27,8 +28,57 @@ func (ind *Indexer) SaveAnnotatedAsset(ctx context.Context, assetID bc.AssetID,
 	return errors.Wrap(err, "saving annotated asset")
 }
 
+// UpdateAssetAnnotations replaces the asset alias and tags on the
+// indexed inputs and outputs of the asset, after they've changed.
+// The annotated asset itself is saved with SaveAnnotatedAsset.
+func (ind *Indexer) UpdateAssetAnnotations(ctx context.Context, assetID bc.AssetID, alias *string, tags map[string]interface{}) error {
+	if tags == nil {
+		tags = map[string]interface{}{}
+	}
+	annotations := map[string]interface{}{"asset_tags": tags}
+	if alias != nil && *alias != "" {
+		annotations["asset_alias"] = *alias
+	}
+	patch, err := json.Marshal(annotations)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	match, err := json.Marshal(map[string]interface{}{"asset_id": assetID})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+
+	const outputsQ = `
+		UPDATE annotated_outputs SET data = (data - 'asset_alias') || $2::jsonb
+		WHERE data @> $1::jsonb
+	`
+	_, err = ind.db.Exec(ctx, outputsQ, match, patch)
+	if err != nil {
+		return errors.Wrap(err, "updating annotated outputs")
+	}
+
+	// Rewrite the inputs and outputs arrays of each
+	// transaction, patching the entries for this asset.
+	const txsQ = `
+		UPDATE annotated_txs SET data = jsonb_set(jsonb_set(data,
+			'{inputs}', COALESCE((
+				SELECT jsonb_agg(CASE WHEN e @> $1::jsonb THEN (e - 'asset_alias') || $2::jsonb ELSE e END ORDER BY i)
+				FROM jsonb_array_elements(data->'inputs') WITH ORDINALITY AS t(e, i)
+			), '[]')),
+			'{outputs}', COALESCE((
+				SELECT jsonb_agg(CASE WHEN e @> $1::jsonb THEN (e - 'asset_alias') || $2::jsonb ELSE e END ORDER BY i)
+				FROM jsonb_array_elements(data->'outputs') WITH ORDINALITY AS t(e, i)
+			), '[]'))
+		WHERE data @> jsonb_build_object('inputs', jsonb_build_array($1::jsonb))
+			OR data @> jsonb_build_object('outputs', jsonb_build_array($1::jsonb))
+	`
+	_, err = ind.db.Exec(ctx, txsQ, match, patch)
+	return errors.Wrap(err, "updating annotated transactions")
+}
+
 // Assets queries the blockchain for annotated assets matching the query.
-func (ind *Indexer) Assets(ctx context.Context, p filter.Predicate, vals []interface{}, after string, limit int) ([]map[string]interface{}, string, error) {
+// Archived assets are left out unless includeArchived is set.
+func (ind *Indexer) Assets(ctx context.Context, p filter.Predicate, vals []interface{}, after string, limit int, includeArchived bool) ([]map[string]interface{}, string, error) {
 	if len(vals) != p.Parameters {
 		return nil, "", ErrParameterCountMismatch
 	}
@@ -37,7 +87,8 @@ func (ind *Indexer) Assets(ctx context.Context, p filter.Predicate, vals []inter
 		return nil, "", errors.Wrap(err, "converting to SQL")
 	}
 
-	queryStr, queryArgs := constructAssetsQuery(expr, after, limit)
+	queryStr, queryArgs := constructAssetsQuery(expr, after, limit, includeArchived)
+	start := time.Now()
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, "", errors.Wrap(err, "executing assets query")
@@ -71,10 +122,11 @@ func (ind *Indexer) Assets(ctx context.Context, p filter.Predicate, vals []inter
 		return nil, "", errors.Wrap(err)
 	}
 
+	ind.recordQuery(ctx, "assets", p, queryStr, queryArgs, time.Since(start), len(assets))
 	return assets, after, nil
 }
 
-func constructAssetsQuery(expr filter.SQLExpr, after string, limit int) (string, []interface{}) {
+func constructAssetsQuery(expr filter.SQLExpr, after string, limit int, includeArchived bool) (string, []interface{}) {
 	var buf bytes.Buffer
 	var vals []interface{}
 
@@ -89,6 +141,10 @@ func constructAssetsQuery(expr filter.SQLExpr, after string, limit int) (string,
 		buf.WriteString(") AND ")
 	}
 
+	if !includeArchived {
+		buf.WriteString(`NOT (data @> '{"is_archived": "yes"}') AND `)
+	}
+
 	// add after conditions
 	buf.WriteString(fmt.Sprintf("($%d='' OR sort_id < $%d) ", len(vals)+1, len(vals)+1))
 	vals = append(vals, after)
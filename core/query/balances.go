@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -12,8 +13,20 @@ import (
 	"chain/errors"
 )
 
+// Balance is one result of a balances query. Its fields are in
+// the order of the JSON API output. DisplayValue is set only by
+// a Converter.
+type Balance struct {
+	SumBy        map[string]interface{} `json:"sum_by,omitempty"`
+	Amount       uint64                 `json:"amount"`
+	DisplayValue *Converted             `json:"display_value,omitempty"`
+}
+
 // Balances performs a balances query against the annotated_outputs.
-func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []interface{}, sumBy []filter.Field, timestampMS uint64) ([]interface{}, error) {
+// If pending is true, the balances also reflect the pending txs
+// saved by IndexPendingTx: they include the pending txs' outputs
+// and exclude the outputs they spend.
+func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []interface{}, sumBy []filter.Field, timestampMS uint64, pending bool) ([]interface{}, error) {
 	if len(vals) != p.Parameters {
 		return nil, ErrParameterCountMismatch
 	}
@@ -21,7 +34,8 @@ func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []int
 	if err != nil {
 		return nil, err
 	}
-	queryStr, queryArgs := constructBalancesQuery(expr, sumBy, timestampMS)
+	queryStr, queryArgs := constructBalancesQuery(expr, sumBy, timestampMS, pending)
+	start := time.Now()
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, err
@@ -47,46 +61,55 @@ func (ind *Indexer) Balances(ctx context.Context, p filter.Predicate, vals []int
 		for i, f := range sumBy {
 			sumByValues[f.String()] = scanArguments[i+1]
 		}
-		// This struct enforces JSON field ordering in API output.
-		item := struct {
-			SumBy  map[string]interface{} `json:"sum_by,omitempty"`
-			Amount uint64                 `json:"amount"`
-		}{
-			Amount: balance,
-		}
+		item := &Balance{Amount: balance}
 		if len(sumByValues) > 0 {
 			item.SumBy = sumByValues
 		}
 		balances = append(balances, item)
 	}
+	ind.recordQuery(ctx, "balances", p, queryStr, queryArgs, time.Since(start), len(balances))
 	return balances, errors.Wrap(rows.Err())
 }
 
-func constructBalancesQuery(expr filter.SQLExpr, sumBy []filter.Field, timestampMS uint64) (string, []interface{}) {
+func constructBalancesQuery(expr filter.SQLExpr, sumBy []filter.Field, timestampMS uint64, pending bool) (string, []interface{}) {
 	var buf bytes.Buffer
 
+	vals := make([]interface{}, 0, 1+len(expr.Values))
+	vals = append(vals, expr.Values...)
+
+	vals = append(vals, timestampMS)
+	timestampValIndex := len(vals)
+
+	from := pq.QuoteIdentifier("annotated_outputs")
+	if pending {
+		const unspent = `(tx_hash, output_index) NOT IN (SELECT spent_tx_hash, spent_output_index FROM pending_spends)`
+		from = fmt.Sprintf("(SELECT data FROM annotated_outputs WHERE timespan @> $%d::int8 AND %s", timestampValIndex, unspent) +
+			" UNION ALL SELECT data FROM pending_outputs WHERE " + unspent + ") outputs"
+	}
+
 	buf.WriteString("SELECT COALESCE(SUM((data->>'amount')::bigint), 0)")
 	for _, field := range sumBy {
 		buf.WriteString(", ")
 		buf.WriteString(filter.FieldAsSQL("data", field))
 	}
 	buf.WriteString(" FROM ")
-	buf.WriteString(pq.QuoteIdentifier("annotated_outputs"))
-	buf.WriteString(" WHERE ")
-	if len(expr.SQL) > 0 {
-		buf.WriteString("(")
-		buf.WriteString(expr.SQL)
-		buf.WriteString(") AND ")
+	buf.WriteString(from)
+	if pending {
+		// The timespan condition is in the subquery.
+		if len(expr.SQL) > 0 {
+			buf.WriteString(" WHERE ")
+			buf.WriteString(expr.SQL)
+		}
+	} else {
+		buf.WriteString(" WHERE ")
+		if len(expr.SQL) > 0 {
+			buf.WriteString("(")
+			buf.WriteString(expr.SQL)
+			buf.WriteString(") AND ")
+		}
+		buf.WriteString(fmt.Sprintf("timespan @> $%d::int8", timestampValIndex))
 	}
 
-	vals := make([]interface{}, 0, 1+len(expr.Values))
-	vals = append(vals, expr.Values...)
-
-	vals = append(vals, timestampMS)
-	timestampValIndex := len(vals)
-
-	buf.WriteString(fmt.Sprintf("timespan @> $%d::int8", timestampValIndex))
-
 	if len(sumBy) > 0 {
 		buf.WriteString(" GROUP BY ")
 		for i := range sumBy {
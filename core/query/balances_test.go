@@ -18,6 +18,7 @@ func TestConstructBalancesQuery(t *testing.T) {
 		predicate  string
 		sumBy      []string
 		values     []interface{}
+		pending    bool
 		wantQuery  string
 		wantValues []interface{}
 	}{
@@ -47,6 +48,14 @@ func TestConstructBalancesQuery(t *testing.T) {
 			wantQuery:  `SELECT COALESCE(SUM((data->>'amount')::bigint), 0), "data"->'asset_tags'->>'currency' FROM "annotated_outputs" WHERE ((data @> $1::jsonb)) AND timespan @> $2::int8 GROUP BY 2`,
 			wantValues: []interface{}{`{"account_id":"foo"}`, now},
 		},
+		{
+			predicate:  "account_id = $1",
+			sumBy:      []string{"asset_id"},
+			values:     []interface{}{"abc"},
+			pending:    true,
+			wantQuery:  `SELECT COALESCE(SUM((data->>'amount')::bigint), 0), "data"->>'asset_id' FROM (SELECT data FROM annotated_outputs WHERE timespan @> $2::int8 AND (tx_hash, output_index) NOT IN (SELECT spent_tx_hash, spent_output_index FROM pending_spends) UNION ALL SELECT data FROM pending_outputs WHERE (tx_hash, output_index) NOT IN (SELECT spent_tx_hash, spent_output_index FROM pending_spends)) outputs WHERE (data @> $1::jsonb) GROUP BY 2`,
+			wantValues: []interface{}{`{"account_id":"abc"}`, now},
+		},
 	}
 
 	for i, tc := range testCases {
@@ -67,7 +76,7 @@ func TestConstructBalancesQuery(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		query, values := constructBalancesQuery(expr, fields, now)
+		query, values := constructBalancesQuery(expr, fields, now, tc.pending)
 		if query != tc.wantQuery {
 			t.Errorf("case %d: got\n%s\nwant\n%s", i, query, tc.wantQuery)
 		}
@@ -197,7 +206,7 @@ func TestQueryBalances(t *testing.T) {
 			fields = append(fields, f)
 		}
 
-		balances, err := indexer.Balances(ctx, p, tc.values, fields, bc.Millis(tc.when))
+		balances, err := indexer.Balances(ctx, p, tc.values, fields, bc.Millis(tc.when), false)
 		if err != nil {
 			t.Fatal(err)
 		}
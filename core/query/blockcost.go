@@ -0,0 +1,52 @@
+package query
+
+import (
+	"context"
+	"expvar"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/validation"
+)
+
+var (
+	blockCostExpvar = expvar.NewMap("blockcost")
+	lastRunCost     = new(expvar.Int)
+	lastSigChecks   = new(expvar.Int)
+	lastBytes       = new(expvar.Int)
+	maxRunCost      = new(expvar.Int)
+)
+
+func init() {
+	blockCostExpvar.Set("last_run_cost", lastRunCost)
+	blockCostExpvar.Set("last_sig_checks", lastSigChecks)
+	blockCostExpvar.Set("last_bytes", lastBytes)
+	blockCostExpvar.Set("max_run_cost", maxRunCost)
+}
+
+// recordBlockCost publishes the cost of the most recently
+// indexed block, and the highest run cost of any block
+// indexed since the process started.
+func recordBlockCost(cost validation.Cost) {
+	lastRunCost.Set(cost.RunCost)
+	lastSigChecks.Set(cost.SigChecks)
+	lastBytes.Set(cost.Bytes)
+	if cost.RunCost > maxRunCost.Value() {
+		maxRunCost.Set(cost.RunCost)
+	}
+}
+
+// BlockCosts returns the validation cost of each indexed block
+// with height in [from, to], keyed by height. Blocks indexed
+// before costs were recorded have a zero cost.
+func (ind *Indexer) BlockCosts(ctx context.Context, from, to uint64) (map[uint64]validation.Cost, error) {
+	const q = `
+		SELECT height, run_cost, sig_checks, bytes FROM query_blocks
+		WHERE height BETWEEN $1 AND $2
+	`
+	costs := make(map[uint64]validation.Cost)
+	err := pg.ForQueryRows(ctx, ind.db, q, from, to, func(height uint64, runCost, sigChecks, bytes int64) {
+		costs[height] = validation.Cost{RunCost: runCost, SigChecks: sigChecks, Bytes: bytes}
+	})
+	return costs, errors.Wrap(err, "querying block costs")
+}
@@ -0,0 +1,30 @@
+package query
+
+import "testing"
+
+func TestBlockCosts(t *testing.T) {
+	ctx, indexer, _, _, _, _, _, _ := setupQueryTest(t)
+
+	height := indexer.c.Height()
+	costs, err := indexer.BlockCosts(ctx, 1, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(costs)) != height {
+		t.Errorf("got costs of %d blocks want %d", len(costs), height)
+	}
+
+	// The test blocks issue and spend assets, so
+	// some programs ran and some bytes were indexed.
+	var sawRun, sawBytes bool
+	for _, cost := range costs {
+		sawRun = sawRun || cost.RunCost > 0
+		sawBytes = sawBytes || cost.Bytes > 0
+	}
+	if !sawRun || !sawBytes {
+		t.Errorf("block costs = %+v, want some nonzero run cost and bytes", costs)
+	}
+	if maxRunCost.Value() == 0 {
+		t.Error("block cost metrics not recorded")
+	}
+}
@@ -0,0 +1,38 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"math"
+
+	"chain/errors"
+)
+
+// A Checkpoint identifies the state of the index as of one
+// block. Queries at the checkpoint's timestamp see the
+// outputs and transactions of that block and earlier ones.
+type Checkpoint struct {
+	BlockHeight uint64 `json:"block_height"`
+	TimestampMS uint64 `json:"timestamp"`
+}
+
+// LookupCheckpoint returns the checkpoint of the indexed block
+// at height, or of the most recently indexed block if height
+// is 0. It returns ErrBadBlockHeight if the block hasn't been
+// indexed.
+func (ind *Indexer) LookupCheckpoint(ctx context.Context, height uint64) (Checkpoint, error) {
+	if height > math.MaxInt64 {
+		return Checkpoint{}, errors.WithDetailf(ErrBadBlockHeight, "block height %d is too large", height)
+	}
+	const q = `
+		SELECT height, timestamp FROM query_blocks
+		WHERE $1 = 0 OR height = $1
+		ORDER BY height DESC LIMIT 1
+	`
+	var c Checkpoint
+	err := ind.db.QueryRow(ctx, q, height).Scan(&c.BlockHeight, &c.TimestampMS)
+	if err == sql.ErrNoRows {
+		return c, errors.WithDetailf(ErrBadBlockHeight, "block %d has not been indexed", height)
+	}
+	return c, errors.Wrap(err, "querying `query_blocks`")
+}
@@ -0,0 +1,32 @@
+package query
+
+import (
+	"testing"
+
+	"chain/errors"
+)
+
+func TestLookupCheckpoint(t *testing.T) {
+	ctx, indexer, _, _, _, _, _, _ := setupQueryTest(t)
+
+	latest, err := indexer.LookupCheckpoint(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.BlockHeight != indexer.c.Height() {
+		t.Errorf("latest height = %d want %d", latest.BlockHeight, indexer.c.Height())
+	}
+
+	first, err := indexer.LookupCheckpoint(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.BlockHeight != 1 || first.TimestampMS > latest.TimestampMS {
+		t.Errorf("checkpoint at height 1 = %+v, want height 1 no later than %+v", first, latest)
+	}
+
+	_, err = indexer.LookupCheckpoint(ctx, latest.BlockHeight+1)
+	if errors.Root(err) != ErrBadBlockHeight {
+		t.Errorf("err = %v want %v", err, ErrBadBlockHeight)
+	}
+}
@@ -0,0 +1,90 @@
+package query
+
+import (
+	"context"
+	"math"
+
+	"github.com/lib/pq"
+
+	"chain/errors"
+)
+
+// ErrBadBlockHeight is returned by AssetCirculation and
+// LookupCheckpoint when the requested block height hasn't
+// been indexed yet.
+var ErrBadBlockHeight = errors.New("block height not yet indexed")
+
+// Circulation summarizes the units of an asset issued and
+// retired on the blockchain, up to and including a block.
+// Outstanding is Issued less Retired.
+type Circulation struct {
+	AssetID     string `json:"asset_id"`
+	AssetAlias  string `json:"asset_alias,omitempty"`
+	Issued      uint64 `json:"issued"`
+	Retired     uint64 `json:"retired"`
+	Outstanding uint64 `json:"outstanding"`
+}
+
+// AssetCirculation returns the cumulative units issued and
+// retired of each asset as of the block at height, along with
+// the height used. A height of 0 means the most recently
+// indexed block. If assetIDs is non-empty, it returns only
+// those assets; otherwise it returns every asset ever issued.
+//
+// The totals come from the issuance inputs and retirement
+// outputs of the annotated transactions.
+func (ind *Indexer) AssetCirculation(ctx context.Context, assetIDs []string, height uint64) ([]*Circulation, uint64, error) {
+	var indexed uint64
+	err := ind.db.QueryRow(ctx, `SELECT COALESCE(max(height), 0) FROM query_blocks`).Scan(&indexed)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "querying indexed height")
+	}
+	if height == 0 {
+		height = indexed
+	}
+	if height > indexed || height > math.MaxInt64 {
+		return nil, 0, errors.WithDetailf(ErrBadBlockHeight, "latest indexed block is %d", indexed)
+	}
+
+	const q = `
+		SELECT asset_id, max(asset_alias), sum(issued), sum(retired) FROM (
+			SELECT i->>'asset_id' AS asset_id, i->>'asset_alias' AS asset_alias,
+				(i->>'amount')::numeric AS issued, 0 AS retired
+			FROM annotated_txs, jsonb_array_elements(data->'inputs') i
+			WHERE block_height <= $1 AND i->>'type' = 'issue'
+			UNION ALL
+			SELECT o->>'asset_id', o->>'asset_alias',
+				0, (o->>'amount')::numeric
+			FROM annotated_txs, jsonb_array_elements(data->'outputs') o
+			WHERE block_height <= $1 AND o->>'type' = 'retire'
+		) amounts
+		WHERE COALESCE(cardinality($2::text[]), 0) = 0 OR asset_id = ANY($2::text[])
+		GROUP BY asset_id
+		ORDER BY asset_id
+	`
+	rows, err := ind.db.Query(ctx, q, height, pq.StringArray(assetIDs))
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "querying asset circulation")
+	}
+	defer rows.Close()
+
+	var circs []*Circulation
+	for rows.Next() {
+		var (
+			c     Circulation
+			alias *string
+		)
+		err := rows.Scan(&c.AssetID, &alias, &c.Issued, &c.Retired)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "scanning asset circulation")
+		}
+		if alias != nil {
+			c.AssetAlias = *alias
+		}
+		if c.Retired <= c.Issued {
+			c.Outstanding = c.Issued - c.Retired
+		}
+		circs = append(circs, &c)
+	}
+	return circs, height, errors.Wrap(rows.Err(), "end scanning asset circulation")
+}
@@ -0,0 +1,43 @@
+package query
+
+import (
+	"testing"
+
+	"chain/errors"
+)
+
+func TestAssetCirculation(t *testing.T) {
+	ctx, indexer, _, _, _, _, asset1, asset2 := setupQueryTest(t)
+
+	circs, height, err := indexer.AssetCirculation(ctx, []string{asset1.String(), asset2.String()}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != indexer.c.Height() {
+		t.Errorf("height = %d want %d", height, indexer.c.Height())
+	}
+	got := make(map[string]uint64)
+	for _, c := range circs {
+		if c.Retired != 0 || c.Outstanding != c.Issued {
+			t.Errorf("asset %s: issued %d, retired %d, outstanding %d", c.AssetID, c.Issued, c.Retired, c.Outstanding)
+		}
+		got[c.AssetID] = c.Issued
+	}
+	want := map[string]uint64{asset1.String(): 867, asset2.String(): 100}
+	if len(got) != len(want) || got[asset1.String()] != 867 || got[asset2.String()] != 100 {
+		t.Errorf("issued = %v want %v", got, want)
+	}
+
+	circs, _, err = indexer.AssetCirculation(ctx, []string{asset1.String()}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(circs) != 0 {
+		t.Errorf("circulation at height 1 = %+v, want none", circs)
+	}
+
+	_, _, err = indexer.AssetCirculation(ctx, nil, height+1)
+	if errors.Root(err) != ErrBadBlockHeight {
+		t.Errorf("err = %v want %v", err, ErrBadBlockHeight)
+	}
+}
@@ -0,0 +1,128 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"chain/errors"
+)
+
+// An Enricher computes custom fields for annotated transactions
+// and their inputs and outputs, such as an internal cost center.
+// Each method receives a fully annotated object, including the
+// account and asset annotations, and returns the fields to add,
+// or nil for none. The fields are stored under the object's
+// "custom" key, so they are persisted with the annotated
+// transaction and output and can be used in filters, e.g.
+// "outputs(custom.cost_center=$1)".
+//
+// Enrichers are compiled into the core. A package providing
+// one registers it with RegisterEnricher in its init function;
+// importing that package from the cored command enables it.
+type Enricher interface {
+	EnrichTx(ctx context.Context, tx map[string]interface{}) (map[string]interface{}, error)
+	EnrichInput(ctx context.Context, tx, in map[string]interface{}) (map[string]interface{}, error)
+	EnrichOutput(ctx context.Context, tx, out map[string]interface{}) (map[string]interface{}, error)
+}
+
+var (
+	enrichersMu sync.Mutex
+	enrichers   = make(map[string]Enricher)
+)
+
+// RegisterEnricher makes an enricher available to every indexer
+// under the given name. Enrichers run in order of name; if two
+// set the same custom field, the later one wins.
+// It panics if name is already registered or e is nil.
+func RegisterEnricher(name string, e Enricher) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	if e == nil {
+		panic("query: RegisterEnricher enricher is nil")
+	}
+	if _, dup := enrichers[name]; dup {
+		panic("query: RegisterEnricher called twice for " + name)
+	}
+	enrichers[name] = e
+}
+
+type namedEnricher struct {
+	name string
+	Enricher
+}
+
+func registeredEnrichers() []namedEnricher {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	var names []string
+	for name := range enrichers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	es := make([]namedEnricher, 0, len(names))
+	for _, name := range names {
+		es = append(es, namedEnricher{name, enrichers[name]})
+	}
+	return es
+}
+
+// enrich adds the custom fields computed by es to txs.
+// It must run after all other annotators.
+func enrich(ctx context.Context, es []namedEnricher, txs []map[string]interface{}) error {
+	for _, tx := range txs {
+		ins, ok := tx["inputs"].([]interface{})
+		if !ok {
+			return errors.Wrap(fmt.Errorf("bad inputs type %T", tx["inputs"]))
+		}
+		outs, ok := tx["outputs"].([]interface{})
+		if !ok {
+			return errors.Wrap(fmt.Errorf("bad outputs type %T", tx["outputs"]))
+		}
+		for _, e := range es {
+			fields, err := e.EnrichTx(ctx, tx)
+			if err != nil {
+				return errors.Wrapf(err, "enriching tx with %s", e.name)
+			}
+			addCustom(tx, fields)
+			for _, inObj := range ins {
+				in, ok := inObj.(map[string]interface{})
+				if !ok {
+					return errors.Wrap(fmt.Errorf("bad input type %T", inObj))
+				}
+				fields, err := e.EnrichInput(ctx, tx, in)
+				if err != nil {
+					return errors.Wrapf(err, "enriching input with %s", e.name)
+				}
+				addCustom(in, fields)
+			}
+			for _, outObj := range outs {
+				out, ok := outObj.(map[string]interface{})
+				if !ok {
+					return errors.Wrap(fmt.Errorf("bad output type %T", outObj))
+				}
+				fields, err := e.EnrichOutput(ctx, tx, out)
+				if err != nil {
+					return errors.Wrapf(err, "enriching output with %s", e.name)
+				}
+				addCustom(out, fields)
+			}
+		}
+	}
+	return nil
+}
+
+func addCustom(obj, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		return
+	}
+	custom, ok := obj["custom"].(map[string]interface{})
+	if !ok {
+		custom = make(map[string]interface{}, len(fields))
+		obj["custom"] = custom
+	}
+	for k, v := range fields {
+		custom[k] = v
+	}
+}
@@ -0,0 +1,90 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type costCenters map[string]string // account ID -> cost center
+
+func (cc costCenters) EnrichTx(ctx context.Context, tx map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (cc costCenters) EnrichInput(ctx context.Context, tx, in map[string]interface{}) (map[string]interface{}, error) {
+	return cc.fields(in), nil
+}
+
+func (cc costCenters) EnrichOutput(ctx context.Context, tx, out map[string]interface{}) (map[string]interface{}, error) {
+	return cc.fields(out), nil
+}
+
+func (cc costCenters) fields(m map[string]interface{}) map[string]interface{} {
+	id, _ := m["account_id"].(string)
+	if c, ok := cc[id]; ok {
+		return map[string]interface{}{"cost_center": c}
+	}
+	return nil
+}
+
+type txCounter struct{ n int }
+
+func (c *txCounter) EnrichTx(ctx context.Context, tx map[string]interface{}) (map[string]interface{}, error) {
+	c.n++
+	return map[string]interface{}{"seq": c.n, "cost_center": "overridden"}, nil
+}
+
+func (c *txCounter) EnrichInput(ctx context.Context, tx, in map[string]interface{}) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (c *txCounter) EnrichOutput(ctx context.Context, tx, out map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"cost_center": "overridden"}, nil
+}
+
+func TestEnrich(t *testing.T) {
+	in := map[string]interface{}{"account_id": "acc1"}
+	out1 := map[string]interface{}{"account_id": "acc2"}
+	out2 := map[string]interface{}{"control_program": "51"}
+	tx := map[string]interface{}{
+		"inputs":  []interface{}{in},
+		"outputs": []interface{}{out1, out2},
+	}
+
+	es := []namedEnricher{
+		{"a", costCenters{"acc1": "sales", "acc2": "ops"}},
+	}
+	err := enrich(context.Background(), es, []map[string]interface{}{tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tx["custom"]; ok {
+		t.Errorf("tx custom = %v, want none", tx["custom"])
+	}
+	if _, ok := out2["custom"]; ok {
+		t.Errorf("out2 custom = %v, want none", out2["custom"])
+	}
+	want := map[string]interface{}{"cost_center": "sales"}
+	if !reflect.DeepEqual(in["custom"], want) {
+		t.Errorf("in custom = %v want %v", in["custom"], want)
+	}
+	want = map[string]interface{}{"cost_center": "ops"}
+	if !reflect.DeepEqual(out1["custom"], want) {
+		t.Errorf("out1 custom = %v want %v", out1["custom"], want)
+	}
+
+	// A later enricher adds to, and overrides, earlier fields.
+	err = enrich(context.Background(), []namedEnricher{{"b", new(txCounter)}}, []map[string]interface{}{tx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]interface{}{"seq": 1, "cost_center": "overridden"}
+	if !reflect.DeepEqual(tx["custom"], want) {
+		t.Errorf("tx custom = %v want %v", tx["custom"], want)
+	}
+	want = map[string]interface{}{"cost_center": "overridden"}
+	if !reflect.DeepEqual(out1["custom"], want) {
+		t.Errorf("out1 custom = %v want %v", out1["custom"], want)
+	}
+}
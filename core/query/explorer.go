@@ -0,0 +1,41 @@
+package query
+
+import (
+	"context"
+	"math"
+
+	"chain/errors"
+)
+
+// statsBlocks is the number of recent blocks
+// used to compute the average block interval.
+const statsBlocks = 100
+
+// ChainStats summarizes the indexed blockchain.
+type ChainStats struct {
+	TransactionCount       uint64 `json:"transaction_count"`
+	UnspentOutputCount     uint64 `json:"unspent_output_count"`
+	AssetCount             uint64 `json:"asset_count"`
+	AverageBlockIntervalMS uint64 `json:"average_block_interval_ms"`
+}
+
+// ChainStats returns statistics about the indexed blockchain.
+// AssetCount counts the assets with units in circulation.
+func (ind *Indexer) ChainStats(ctx context.Context) (*ChainStats, error) {
+	const q = `
+		SELECT
+			(SELECT count(*) FROM annotated_txs),
+			(SELECT count(*) FROM annotated_outputs WHERE timespan @> $1::int8),
+			(SELECT count(DISTINCT data->>'asset_id') FROM annotated_outputs WHERE timespan @> $1::int8),
+			(SELECT COALESCE((max(timestamp) - min(timestamp)) / NULLIF(count(*) - 1, 0), 0)
+				FROM (SELECT timestamp FROM query_blocks ORDER BY height DESC LIMIT $2) b)
+	`
+	s := new(ChainStats)
+	err := ind.db.QueryRow(ctx, q, int64(math.MaxInt64), statsBlocks).Scan(
+		&s.TransactionCount,
+		&s.UnspentOutputCount,
+		&s.AssetCount,
+		&s.AverageBlockIntervalMS,
+	)
+	return s, errors.Wrap(err, "querying chain stats")
+}
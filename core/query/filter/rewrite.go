@@ -0,0 +1,97 @@
+package filter
+
+import "fmt"
+
+// MapStrings returns p and values with each string compared
+// to one of the given attributes replaced by the result of fn.
+// Strings are replaced whether they appear in p as literals or
+// as the values of placeholders. Attributes match at the top
+// level and within environments, as in `inputs(asset_alias = 'a')`,
+// but not as fields of objects, like `tags.asset_alias`.
+func MapStrings(p Predicate, values []interface{}, attrs []string, fn func(string) (string, error)) (Predicate, []interface{}, error) {
+	if p.expr == nil {
+		return p, values, nil
+	}
+	m := &stringMapper{
+		attrs:  make(map[string]bool, len(attrs)),
+		values: append([]interface{}(nil), values...),
+		fn:     fn,
+	}
+	for _, a := range attrs {
+		m.attrs[a] = true
+	}
+	e, err := m.mapExpr(p.expr)
+	if err != nil {
+		return p, values, err
+	}
+	p.expr = e
+	return p, m.values, nil
+}
+
+type stringMapper struct {
+	attrs  map[string]bool
+	values []interface{}
+	fn     func(string) (string, error)
+}
+
+func (m *stringMapper) mapExpr(e expr) (expr, error) {
+	switch e := e.(type) {
+	case parenExpr:
+		inner, err := m.mapExpr(e.inner)
+		return parenExpr{inner: inner}, err
+	case envExpr:
+		inner, err := m.mapExpr(e.expr)
+		return envExpr{ident: e.ident, expr: inner}, err
+	case binaryExpr:
+		if e.op.name == "=" {
+			if m.isAttr(e.l) {
+				r, err := m.mapValue(e.r)
+				return binaryExpr{op: e.op, l: e.l, r: r}, err
+			}
+			if m.isAttr(e.r) {
+				l, err := m.mapValue(e.l)
+				return binaryExpr{op: e.op, l: l, r: e.r}, err
+			}
+			return e, nil
+		}
+		l, err := m.mapExpr(e.l)
+		if err != nil {
+			return e, err
+		}
+		r, err := m.mapExpr(e.r)
+		return binaryExpr{op: e.op, l: l, r: r}, err
+	}
+	return e, nil
+}
+
+func (m *stringMapper) isAttr(e expr) bool {
+	attr, ok := e.(attrExpr)
+	return ok && m.attrs[attr.attr]
+}
+
+func (m *stringMapper) mapValue(e expr) (expr, error) {
+	switch e := e.(type) {
+	case valueExpr:
+		if e.typ != tokString {
+			return e, nil
+		}
+		s, err := m.fn(e.value[1 : len(e.value)-1])
+		if err != nil {
+			return e, err
+		}
+		return valueExpr{typ: tokString, value: fmt.Sprintf("'%s'", s)}, nil
+	case placeholderExpr:
+		i := e.num - 1
+		if i < 0 || i >= len(m.values) {
+			return e, nil
+		}
+		if s, ok := m.values[i].(string); ok {
+			v, err := m.fn(s)
+			if err != nil {
+				return e, err
+			}
+			m.values[i] = v
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,54 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMapStrings(t *testing.T) {
+	upper := func(s string) (string, error) { return strings.ToUpper(s), nil }
+	testCases := []struct {
+		q       string
+		values  []interface{}
+		wantQ   string
+		wantVal []interface{}
+	}{
+		{
+			q:     `alias = 'a'`,
+			wantQ: `alias = 'A'`,
+		},
+		{
+			q:     `'a' = alias AND id = 'b'`,
+			wantQ: `'A' = alias AND id = 'b'`,
+		},
+		{
+			q:       `inputs(alias = 'a') OR (outputs(alias = $1))`,
+			values:  []interface{}{"b"},
+			wantQ:   `inputs(alias = 'A') OR (outputs(alias = $1))`,
+			wantVal: []interface{}{"B"},
+		},
+		{
+			q:       `tags.alias = 'a' AND id = $1`,
+			values:  []interface{}{"b"},
+			wantQ:   `tags.alias = 'a' AND id = $1`,
+			wantVal: []interface{}{"b"},
+		},
+	}
+	for _, tc := range testCases {
+		p, err := Parse(tc.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, vals, err := MapStrings(p, tc.values, []string{"alias"}, upper)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != tc.wantQ {
+			t.Errorf("MapStrings(%q) = %q want %q", tc.q, got.String(), tc.wantQ)
+		}
+		if !reflect.DeepEqual(vals, tc.wantVal) {
+			t.Errorf("MapStrings(%q) values = %v want %v", tc.q, vals, tc.wantVal)
+		}
+	}
+}
@@ -51,16 +51,30 @@ func (ind *Indexer) IndexTransactions(ctx context.Context, b *bc.Block) error {
 		return err
 	}
 
-	return ind.insertAnnotatedOutputs(ctx, b, txs)
+	err = ind.insertAnnotatedOutputs(ctx, b, txs)
+	if err != nil {
+		return err
+	}
+
+	return ind.removePendingTxs(ctx, b)
 }
 
 func (ind *Indexer) insertBlock(ctx context.Context, b *bc.Block) error {
+	cost, err := ind.c.BlockCost(b)
+	if err != nil {
+		return errors.Wrap(err, "metering block")
+	}
 	const q = `
-		INSERT INTO query_blocks (height, timestamp) VALUES($1, $2)
+		INSERT INTO query_blocks (height, timestamp, run_cost, sig_checks, bytes)
+		VALUES($1, $2, $3, $4, $5)
 		ON CONFLICT (height) DO NOTHING
 	`
-	_, err := ind.db.Exec(ctx, q, b.Height, b.TimestampMS)
-	return errors.Wrap(err, "inserting block timestamp")
+	_, err = ind.db.Exec(ctx, q, b.Height, b.TimestampMS, cost.RunCost, cost.SigChecks, cost.Bytes)
+	if err != nil {
+		return errors.Wrap(err, "inserting block timestamp")
+	}
+	recordBlockCost(cost)
+	return nil
 }
 
 func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *bc.Block) ([]map[string]interface{}, error) {
@@ -76,13 +90,10 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *bc.Block) ([]map[
 		annotatedTxsDecoded = append(annotatedTxsDecoded, transactionObject(tx, b, uint32(pos)))
 	}
 
-	for _, annotator := range ind.annotators {
-		err := annotator(ctx, annotatedTxsDecoded)
-		if err != nil {
-			return nil, errors.Wrap(err, "adding external annotations")
-		}
+	err := ind.annotate(ctx, annotatedTxsDecoded)
+	if err != nil {
+		return nil, err
 	}
-	localAnnotator(ctx, annotatedTxsDecoded)
 
 	for _, decoded := range annotatedTxsDecoded {
 		b, err := json.Marshal(decoded)
@@ -98,13 +109,25 @@ func (ind *Indexer) insertAnnotatedTxs(ctx context.Context, b *bc.Block) ([]map[
 		SELECT $1, unnest($2::integer[]), unnest($3::bytea[]), unnest($4::jsonb[])
 		ON CONFLICT (block_height, tx_pos) DO NOTHING;
 	`
-	_, err := ind.db.Exec(ctx, insertQ, b.Height, positions, hashes, annotatedTxs)
+	_, err = ind.db.Exec(ctx, insertQ, b.Height, positions, hashes, annotatedTxs)
 	if err != nil {
 		return nil, errors.Wrap(err, "inserting annotated_txs to db")
 	}
 	return annotatedTxsDecoded, nil
 }
 
+// annotate runs every annotator over txs.
+func (ind *Indexer) annotate(ctx context.Context, txs []map[string]interface{}) error {
+	for _, annotator := range ind.annotators {
+		err := annotator(ctx, txs)
+		if err != nil {
+			return errors.Wrap(err, "adding external annotations")
+		}
+	}
+	localAnnotator(ctx, txs)
+	return enrich(ctx, ind.enrichers, txs)
+}
+
 func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, annotatedTxs []map[string]interface{}) error {
 	var (
 		outputTxPositions pg.Uint32s
@@ -113,14 +136,18 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 		outputData        pq.StringArray
 		prevoutHashes     pq.ByteaArray
 		prevoutIndexes    pg.Uint32s
+		spendTxHashes     pq.ByteaArray
+		spendInputIndexes pg.Uint32s
 	)
 
 	for pos, tx := range b.Transactions {
-		for _, in := range tx.Inputs {
+		for inIndex, in := range tx.Inputs {
 			if !in.IsIssuance() {
 				outpoint := in.Outpoint()
 				prevoutHashes = append(prevoutHashes, outpoint.Hash[:])
 				prevoutIndexes = append(prevoutIndexes, outpoint.Index)
+				spendTxHashes = append(spendTxHashes, tx.Hash[:])
+				spendInputIndexes = append(spendInputIndexes, uint32(inIndex))
 			}
 		}
 
@@ -171,10 +198,22 @@ func (ind *Indexer) insertAnnotatedOutputs(ctx context.Context, b *bc.Block, ann
 		return errors.Wrap(err, "batch inserting annotated outputs")
 	}
 
+	// If AnnotateSpentBy is set, each spent output also
+	// records the input that spent it.
 	const updateQ = `
-		UPDATE annotated_outputs SET timespan = INT8RANGE(LOWER(timespan), $1)
-		WHERE (tx_hash, output_index) IN (SELECT unnest($2::bytea[]), unnest($3::integer[]))
+		UPDATE annotated_outputs o SET timespan = INT8RANGE(LOWER(o.timespan), $1),
+			data = CASE WHEN $6 THEN o.data || jsonb_build_object('spent_by', jsonb_build_object(
+				'transaction_id', encode(s.spend_tx_hash, 'hex'),
+				'position', s.spend_input_index,
+				'block_height', $7::bigint
+			)) ELSE o.data END
+		FROM (
+			SELECT unnest($2::bytea[]) AS tx_hash, unnest($3::integer[]) AS output_index,
+				unnest($4::bytea[]) AS spend_tx_hash, unnest($5::integer[]) AS spend_input_index
+		) s
+		WHERE (o.tx_hash, o.output_index) = (s.tx_hash, s.output_index)
 	`
-	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, prevoutHashes, prevoutIndexes)
+	_, err = ind.db.Exec(ctx, updateQ, b.TimestampMS, prevoutHashes, prevoutIndexes,
+		spendTxHashes, spendInputIndexes, ind.AnnotateSpentBy, b.Height)
 	return errors.Wrap(err, "updating spent annotated outputs")
 }
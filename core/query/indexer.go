@@ -1,6 +1,8 @@
 package query
 
 import (
+	"time"
+
 	"chain/core/pin"
 	"chain/database/pg"
 	"chain/protocol"
@@ -9,10 +11,13 @@ import (
 // NewIndexer constructs a new indexer for indexing transactions.
 func NewIndexer(db pg.DB, c *protocol.Chain, pinStore *pin.Store) *Indexer {
 	indexer := &Indexer{
-		db:       db,
-		c:        c,
-		pinStore: pinStore,
+		db:                 db,
+		c:                  c,
+		pinStore:           pinStore,
+		SlowQueryThreshold: DefaultSlowQueryThreshold,
+		enrichers:          registeredEnrichers(),
 	}
+	indexer.stats.filters = make(map[filterKey]*FilterStats)
 	return indexer
 }
 
@@ -22,4 +27,20 @@ type Indexer struct {
 	c          *protocol.Chain
 	pinStore   *pin.Store
 	annotators []Annotator
+	enrichers  []namedEnricher
+
+	// SlowQueryThreshold is the duration above which a query
+	// is logged and reported as slow. Zero disables reporting.
+	SlowQueryThreshold time.Duration
+
+	// Rates, if set, supplies the exchange rates used to show
+	// query results converted to a display asset.
+	Rates RatesProvider
+
+	// AnnotateSpentBy, if set, adds a spent_by field to each
+	// indexed output when it is spent, giving the spending
+	// transaction, input position and block height.
+	AnnotateSpentBy bool
+
+	stats queryStats
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/lib/pq"
 
@@ -48,7 +49,10 @@ func DecodeOutputsAfter(str string) (c *OutputsAfter, err error) {
 	}, nil
 }
 
-func (ind *Indexer) Outputs(ctx context.Context, p filter.Predicate, vals []interface{}, timestampMS uint64, after *OutputsAfter, limit int) ([]interface{}, *OutputsAfter, error) {
+// Outputs returns the outputs matching p that were unspent at
+// timestampMS. If includeSpent is set, it also returns outputs
+// created by then but spent before it.
+func (ind *Indexer) Outputs(ctx context.Context, p filter.Predicate, vals []interface{}, timestampMS uint64, includeSpent bool, after *OutputsAfter, limit int) ([]interface{}, *OutputsAfter, error) {
 	if len(vals) != p.Parameters {
 		return nil, nil, ErrParameterCountMismatch
 	}
@@ -56,7 +60,8 @@ func (ind *Indexer) Outputs(ctx context.Context, p filter.Predicate, vals []inte
 	if err != nil {
 		return nil, nil, err
 	}
-	queryStr, queryArgs := constructOutputsQuery(expr, timestampMS, after, limit)
+	queryStr, queryArgs := constructOutputsQuery(expr, timestampMS, includeSpent, after, limit)
+	start := time.Now()
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, nil, err
@@ -91,10 +96,11 @@ func (ind *Indexer) Outputs(ctx context.Context, p filter.Predicate, vals []inte
 		return nil, nil, err
 	}
 
+	ind.recordQuery(ctx, "outputs", p, queryStr, queryArgs, time.Since(start), len(outputs))
 	return outputs, &newAfter, nil
 }
 
-func constructOutputsQuery(expr filter.SQLExpr, timestampMS uint64, after *OutputsAfter, limit int) (string, []interface{}) {
+func constructOutputsQuery(expr filter.SQLExpr, timestampMS uint64, includeSpent bool, after *OutputsAfter, limit int) (string, []interface{}) {
 	var sql bytes.Buffer
 
 	sql.WriteString("SELECT block_height, tx_pos, output_index, data FROM ")
@@ -109,6 +115,9 @@ func constructOutputsQuery(expr filter.SQLExpr, timestampMS uint64, after *Outpu
 
 	where := strings.TrimSpace(expr.SQL)
 	timespanExpr := fmt.Sprintf("timespan @> $%d::int8", timestampValIndex)
+	if includeSpent {
+		timespanExpr = fmt.Sprintf("timespan && int8range(NULL, $%d::int8, '[]')", timestampValIndex)
+	}
 
 	if where == "" {
 		sql.WriteString(timespanExpr)
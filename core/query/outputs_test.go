@@ -60,7 +60,7 @@ func TestOutputsAfter(t *testing.T) {
 	}
 
 	indexer := NewIndexer(db, &protocol.Chain{}, nil)
-	results, after, err := indexer.Outputs(ctx, q, nil, 25, nil, 2)
+	results, after, err := indexer.Outputs(ctx, q, nil, 25, false, nil, 2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,7 +71,7 @@ func TestOutputsAfter(t *testing.T) {
 		t.Errorf("got after=%q want 1:1:1", after.String())
 	}
 
-	results, after, err = indexer.Outputs(ctx, q, nil, 25, after, 2)
+	results, after, err = indexer.Outputs(ctx, q, nil, 25, false, after, 2)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -88,11 +88,12 @@ func TestConstructOutputsQuery(t *testing.T) {
 	nowMillis := bc.Millis(now)
 
 	testCases := []struct {
-		filter     string
-		values     []interface{}
-		after      *OutputsAfter
-		wantQuery  string
-		wantValues []interface{}
+		filter       string
+		values       []interface{}
+		includeSpent bool
+		after        *OutputsAfter
+		wantQuery    string
+		wantValues   []interface{}
 	}{
 		{
 			// empty filter
@@ -105,6 +106,13 @@ func TestConstructOutputsQuery(t *testing.T) {
 			wantQuery:  `SELECT block_height, tx_pos, output_index, data FROM "annotated_outputs" WHERE ((data @> $1::jsonb)) AND timespan @> $2::int8 ORDER BY block_height DESC, tx_pos DESC, output_index DESC LIMIT 10`,
 			wantValues: []interface{}{`{"account_id":"abc","asset_id":"foo"}`, nowMillis},
 		},
+		{
+			filter:       "spent_by.transaction_id = $1",
+			values:       []interface{}{"abc"},
+			includeSpent: true,
+			wantQuery:    `SELECT block_height, tx_pos, output_index, data FROM "annotated_outputs" WHERE ((data @> $1::jsonb)) AND timespan && int8range(NULL, $2::int8, '[]') ORDER BY block_height DESC, tx_pos DESC, output_index DESC LIMIT 10`,
+			wantValues:   []interface{}{`{"spent_by":{"transaction_id":"abc"}}`, nowMillis},
+		},
 		{
 			filter: "asset_id = $1 AND account_id = 'abc'",
 			values: []interface{}{"foo"},
@@ -127,7 +135,7 @@ func TestConstructOutputsQuery(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		query, values := constructOutputsQuery(expr, nowMillis, tc.after, 10)
+		query, values := constructOutputsQuery(expr, nowMillis, tc.includeSpent, tc.after, 10)
 		if query != tc.wantQuery {
 			t.Errorf("case %d: got %s want %s", i, query, tc.wantQuery)
 		}
@@ -241,7 +249,7 @@ func TestQueryOutputs(t *testing.T) {
 		if err != nil {
 			t.Fatal(err)
 		}
-		outputs, _, err := indexer.Outputs(ctx, f, tc.values, bc.Millis(tc.when), nil, 1000)
+		outputs, _, err := indexer.Outputs(ctx, f, tc.values, bc.Millis(tc.when), false, nil, 1000)
 		if err != nil {
 			t.Fatal(err)
 		}
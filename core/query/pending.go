@@ -0,0 +1,278 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// AnnotatedTx returns the annotated form of tx, as a
+// pending transaction, without saving it.
+func (ind *Indexer) AnnotatedTx(ctx context.Context, tx *bc.Tx) (map[string]interface{}, error) {
+	txs := []map[string]interface{}{transactionObject(tx, nil, 0)}
+	err := ind.annotate(ctx, txs)
+	if err != nil {
+		return nil, err
+	}
+	return txs[0], nil
+}
+
+// IndexPendingTx saves the annotated form of tx, which this core
+// has submitted but which is not yet in a block, so that queries
+// can include it. Its annotated transaction has "pending": true
+// and no block fields.
+//
+// The pending tx is removed when it lands in a block, when
+// another transaction in a block spends one of its inputs,
+// or when a block passes its max time.
+func (ind *Indexer) IndexPendingTx(ctx context.Context, tx *bc.Tx) error {
+	annotated, err := ind.AnnotatedTx(ctx, tx)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(annotated)
+	if err != nil {
+		return errors.Wrap(err, "serializing pending tx")
+	}
+
+	var (
+		outputIndexes pg.Uint32s
+		outputData    pq.StringArray
+		spentHashes   pq.ByteaArray
+		spentIndexes  pg.Uint32s
+	)
+	for _, in := range tx.Inputs {
+		if !in.IsIssuance() {
+			outpoint := in.Outpoint()
+			spentHashes = append(spentHashes, outpoint.Hash[:])
+			spentIndexes = append(spentIndexes, outpoint.Index)
+		}
+	}
+	outs, ok := annotated["outputs"].([]interface{})
+	if !ok {
+		return errors.Wrap(fmt.Errorf("bad outputs type %T", annotated["outputs"]))
+	}
+	for outIndex, out := range outs {
+		txOut, ok := out.(map[string]interface{})
+		if !ok {
+			return errors.Wrap(fmt.Errorf("bad output type %T", out))
+		}
+		if typ, ok := txOut["type"].(string); ok && typ == "retire" {
+			continue
+		}
+		txOut["transaction_id"] = tx.Hash
+		b, err := json.Marshal(txOut)
+		if err != nil {
+			return errors.Wrap(err, "serializing pending output")
+		}
+		outputIndexes = append(outputIndexes, uint32(outIndex))
+		outputData = append(outputData, string(b))
+	}
+
+	// A tx with indexed outputs has already landed, so it
+	// is not pending; this happens when a submit is retried.
+	const q = `
+		WITH tx AS (
+			INSERT INTO pending_txs (tx_hash, data, max_time_ms)
+			SELECT $1, $2, $3
+			WHERE NOT EXISTS (SELECT 1 FROM annotated_outputs WHERE tx_hash = $1)
+			ON CONFLICT (tx_hash) DO NOTHING
+			RETURNING tx_hash
+		), outs AS (
+			INSERT INTO pending_outputs (tx_hash, output_index, data)
+			SELECT tx_hash, unnest($4::integer[]), unnest($5::jsonb[]) FROM tx
+		)
+		INSERT INTO pending_spends (tx_hash, spent_tx_hash, spent_output_index)
+		SELECT tx_hash, unnest($6::bytea[]), unnest($7::integer[]) FROM tx
+	`
+	_, err = ind.db.Exec(ctx, q, tx.Hash[:], string(data), tx.MaxTime,
+		outputIndexes, outputData, spentHashes, spentIndexes)
+	return errors.Wrap(err, "inserting pending tx")
+}
+
+// RemovePendingTx removes a pending tx saved by IndexPendingTx,
+// for example because it could not be submitted.
+func (ind *Indexer) RemovePendingTx(ctx context.Context, hash bc.Hash) error {
+	_, err := ind.db.Exec(ctx, `DELETE FROM pending_txs WHERE tx_hash = $1`, hash[:])
+	return errors.Wrap(err, "deleting pending tx")
+}
+
+// removePendingTxs removes the pending txs that landed in b,
+// that conflict with a tx in b, or that expired before b.
+func (ind *Indexer) removePendingTxs(ctx context.Context, b *bc.Block) error {
+	var (
+		hashes       pq.ByteaArray
+		spentHashes  pq.ByteaArray
+		spentIndexes pg.Uint32s
+	)
+	for _, tx := range b.Transactions {
+		hashes = append(hashes, tx.Hash[:])
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				outpoint := in.Outpoint()
+				spentHashes = append(spentHashes, outpoint.Hash[:])
+				spentIndexes = append(spentIndexes, outpoint.Index)
+			}
+		}
+	}
+
+	const q = `
+		DELETE FROM pending_txs
+		WHERE tx_hash = ANY($1::bytea[])
+			OR (max_time_ms > 0 AND max_time_ms < $2)
+			OR tx_hash IN (
+				SELECT tx_hash FROM pending_spends
+				WHERE (spent_tx_hash, spent_output_index) IN (SELECT unnest($3::bytea[]), unnest($4::integer[]))
+			)
+	`
+	_, err := ind.db.Exec(ctx, q, hashes, b.TimestampMS, spentHashes, spentIndexes)
+	return errors.Wrap(err, "removing pending txs")
+}
+
+// PendingTransactions returns up to limit of the pending txs
+// matching the filter predicate p, most recently submitted first.
+func (ind *Indexer) PendingTransactions(ctx context.Context, p filter.Predicate, vals []interface{}, limit int) ([]interface{}, error) {
+	if len(vals) != p.Parameters {
+		return nil, ErrParameterCountMismatch
+	}
+	expr, err := filter.AsSQL(p, "data", vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "converting to SQL")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("SELECT data FROM pending_txs")
+	if len(expr.SQL) > 0 {
+		buf.WriteString(" WHERE ")
+		buf.WriteString(expr.SQL)
+	}
+	buf.WriteString(" ORDER BY submitted_at DESC LIMIT " + strconv.Itoa(limit))
+
+	queryStr := buf.String()
+	start := time.Now()
+	rows, err := ind.db.Query(ctx, queryStr, expr.Values...)
+	if err != nil {
+		return nil, errors.Wrap(err, "executing pending txn query")
+	}
+	defer rows.Close()
+
+	var txns []interface{}
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning pending transaction row")
+		}
+		txns = append(txns, (*json.RawMessage)(&data))
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	ind.recordQuery(ctx, "pending_transactions", p, queryStr, expr.Values, time.Since(start), len(txns))
+	return txns, nil
+}
+
+// PendingTx describes a pending tx in this core's pool of
+// submitted transactions that have not yet landed in a block.
+// Transaction, the annotated tx, is set only by PendingTx.
+type PendingTx struct {
+	ID          bc.Hash          `json:"id"`
+	SubmittedAt time.Time        `json:"submitted_at"`
+	AgeMS       uint64           `json:"age_ms"`
+	MaxTimeMS   uint64           `json:"max_time_ms"`
+	Transaction *json.RawMessage `json:"transaction,omitempty"`
+}
+
+// PendingTxsAfter is a cursor into the list of pending txs,
+// which are ordered by submission time and then by hash.
+type PendingTxsAfter struct {
+	SubmittedAt time.Time
+	Hash        bc.Hash
+}
+
+func (after PendingTxsAfter) String() string {
+	return fmt.Sprintf("%d:%x", after.SubmittedAt.UnixNano(), after.Hash[:])
+}
+
+func DecodePendingTxsAfter(str string) (c PendingTxsAfter, err error) {
+	var (
+		nanos int64
+		hash  []byte
+	)
+	_, err = fmt.Sscanf(str, "%d:%x", &nanos, &hash)
+	if err != nil {
+		return c, errors.Wrap(ErrBadAfter, err.Error())
+	}
+	if len(hash) != len(c.Hash) {
+		return c, errors.Wrap(ErrBadAfter)
+	}
+	c.SubmittedAt = time.Unix(0, nanos)
+	copy(c.Hash[:], hash)
+	return c, nil
+}
+
+// ListPendingTxs returns up to limit of the pending txs,
+// oldest first, starting after the given cursor.
+func (ind *Indexer) ListPendingTxs(ctx context.Context, after *PendingTxsAfter, limit int) ([]*PendingTx, error) {
+	var (
+		afterTime time.Time
+		afterHash []byte
+	)
+	if after != nil {
+		afterTime, afterHash = after.SubmittedAt, after.Hash[:]
+	}
+	const q = `
+		SELECT tx_hash, submitted_at, max_time_ms, ` + pendingAgeMS + ` FROM pending_txs
+		WHERE $1::bytea IS NULL OR (submitted_at, tx_hash) > ($2, $1)
+		ORDER BY submitted_at, tx_hash LIMIT $3
+	`
+	var txs []*PendingTx
+	err := pg.ForQueryRows(ctx, ind.db, q, afterHash, afterTime, limit, func(hash bc.Hash, submittedAt time.Time, maxTime, age uint64) {
+		txs = append(txs, &PendingTx{ID: hash, SubmittedAt: submittedAt, AgeMS: age, MaxTimeMS: maxTime})
+	})
+	return txs, errors.Wrap(err, "listing pending txs")
+}
+
+// CountPendingTxs returns the number of pending txs
+// and the age of the oldest, in milliseconds.
+func (ind *Indexer) CountPendingTxs(ctx context.Context) (count, oldestAgeMS uint64, err error) {
+	const q = `SELECT count(*), COALESCE(max(` + pendingAgeMS + `), 0) FROM pending_txs`
+	err = ind.db.QueryRow(ctx, q).Scan(&count, &oldestAgeMS)
+	return count, oldestAgeMS, errors.Wrap(err, "counting pending txs")
+}
+
+// PendingTx returns the pending tx with the given hash,
+// including its annotated form.
+func (ind *Indexer) PendingTx(ctx context.Context, hash bc.Hash) (*PendingTx, error) {
+	const q = `
+		SELECT submitted_at, max_time_ms, ` + pendingAgeMS + `, data FROM pending_txs
+		WHERE tx_hash = $1
+	`
+	tx := &PendingTx{ID: hash}
+	var data []byte
+	err := ind.db.QueryRow(ctx, q, hash[:]).Scan(&tx.SubmittedAt, &tx.MaxTimeMS, &tx.AgeMS, &data)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no pending tx %s", hash)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading pending tx")
+	}
+	tx.Transaction = (*json.RawMessage)(&data)
+	return tx, nil
+}
+
+// pendingAgeMS is an SQL expression for the age
+// of a pending tx, in milliseconds.
+const pendingAgeMS = `(extract(epoch FROM now() - submitted_at) * 1000)::bigint`
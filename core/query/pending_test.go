@@ -0,0 +1,30 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestPendingTxsAfter(t *testing.T) {
+	want := PendingTxsAfter{
+		SubmittedAt: time.Date(2017, 1, 26, 12, 0, 0, 123456000, time.UTC),
+		Hash:        bc.Hash{1, 2, 3},
+	}
+	got, err := DecodePendingTxsAfter(want.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.SubmittedAt.Equal(want.SubmittedAt) || got.Hash != want.Hash {
+		t.Errorf("DecodePendingTxsAfter(%q) = %+v want %+v", want.String(), got, want)
+	}
+
+	for _, str := range []string{"hello", "1:abcd"} {
+		_, err := DecodePendingTxsAfter(str)
+		if errors.Root(err) != ErrBadAfter {
+			t.Errorf("DecodePendingTxsAfter(%q) error = %v want %v", str, err, ErrBadAfter)
+		}
+	}
+}
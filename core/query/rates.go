@@ -0,0 +1,204 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+var (
+	// ErrNoRate is returned by a RatesProvider
+	// that has no rate for the requested assets and time.
+	ErrNoRate = errors.New("no exchange rate")
+
+	// ErrNoRatesProvider is returned by NewConverter
+	// when the Indexer has no RatesProvider.
+	ErrNoRatesProvider = errors.New("no rates provider")
+)
+
+// displayPrecision is the number of decimal places
+// kept in converted amounts.
+const displayPrecision = 8
+
+// A RatesProvider supplies the exchange rates used
+// to convert amounts of an asset to a display asset.
+type RatesProvider interface {
+	// Rate returns the number of units of asset to
+	// worth one unit of asset from at time t.
+	// It returns ErrNoRate if it doesn't know the rate.
+	Rate(ctx context.Context, from, to bc.AssetID, t time.Time) (*big.Rat, error)
+}
+
+// Converted is an amount converted to a display asset.
+type Converted struct {
+	AssetID bc.AssetID        `json:"asset_id"`
+	Amount  chainjson.Decimal `json:"amount"`
+	Rate    chainjson.Decimal `json:"rate"`
+}
+
+// A Converter converts amounts to one display asset,
+// remembering the rates it has used. It is meant to
+// serve a single query.
+type Converter struct {
+	rates RatesProvider
+	to    bc.AssetID
+	cache map[rateKey]*big.Rat
+}
+
+type rateKey struct {
+	from bc.AssetID
+	ms   uint64
+}
+
+// NewConverter returns a Converter to the display asset to
+// using ind's RatesProvider.
+func (ind *Indexer) NewConverter(to bc.AssetID) (*Converter, error) {
+	if ind.Rates == nil {
+		return nil, errors.WithDetail(ErrNoRatesProvider, "this core has no source of exchange rates")
+	}
+	return &Converter{
+		rates: ind.Rates,
+		to:    to,
+		cache: make(map[rateKey]*big.Rat),
+	}, nil
+}
+
+// Convert converts amount units of asset from to the display
+// asset at the rate in effect at time t. If the RatesProvider
+// has no rate, Convert returns nil and no error, so that a
+// query can still show the amounts it can convert.
+func (c *Converter) Convert(ctx context.Context, amount uint64, from bc.AssetID, t time.Time) (*Converted, error) {
+	rate, err := c.rate(ctx, from, t)
+	if err != nil || rate == nil {
+		return nil, err
+	}
+	v := new(big.Rat).SetInt(new(big.Int).SetUint64(amount))
+	v.Mul(v, rate)
+	return &Converted{
+		AssetID: c.to,
+		Amount:  decimal(v),
+		Rate:    decimal(rate),
+	}, nil
+}
+
+func (c *Converter) rate(ctx context.Context, from bc.AssetID, t time.Time) (*big.Rat, error) {
+	if from == c.to {
+		return big.NewRat(1, 1), nil
+	}
+	k := rateKey{from, bc.Millis(t)}
+	if r, ok := c.cache[k]; ok {
+		return r, nil
+	}
+	r, err := c.rates.Rate(ctx, from, c.to, t)
+	if errors.Root(err) == ErrNoRate {
+		r, err = nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetching rate from %s to %s", from, c.to)
+	}
+	c.cache[k] = r
+	return r, nil
+}
+
+// ConvertBalances adds to each of balances, as returned by
+// Balances, its amount converted to the display asset at the
+// rate in effect at time t. Balances not summed by asset_id
+// hold more than one asset, so they can't be converted.
+func (c *Converter) ConvertBalances(ctx context.Context, balances []interface{}, t time.Time) error {
+	for _, b := range balances {
+		bal, ok := b.(*Balance)
+		if !ok {
+			return errors.Wrap(fmt.Errorf("unexpected balance type %T", b))
+		}
+		id, ok := bal.SumBy["asset_id"].(**string)
+		if !ok || *id == nil {
+			continue
+		}
+		var assetID bc.AssetID
+		err := assetID.UnmarshalText([]byte(**id))
+		if err != nil {
+			return errors.Wrap(err, "decoding asset id")
+		}
+		bal.DisplayValue, err = c.Convert(ctx, bal.Amount, assetID, t)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decimal formats r with displayPrecision decimal
+// places, without trailing zeros.
+func decimal(r *big.Rat) chainjson.Decimal {
+	s := r.FloatString(displayPrecision)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return chainjson.Decimal(s)
+}
+
+// HTTPRates is a RatesProvider backed by an HTTP service.
+// For each rate it sends a GET request to URL with query
+// parameters from and to, the asset IDs, and time, in
+// milliseconds since the epoch. The service responds
+// with a JSON object such as {"rate": "1.25"}, or status
+// 404 if it has no rate.
+type HTTPRates struct {
+	URL    string
+	Client *http.Client
+}
+
+// Rate implements RatesProvider.
+func (h *HTTPRates) Rate(ctx context.Context, from, to bc.AssetID, t time.Time) (*big.Rat, error) {
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing rates url")
+	}
+	q := u.Query()
+	q.Set("from", from.String())
+	q.Set("to", to.String())
+	q.Set("time", strconv.FormatUint(bc.Millis(t), 10))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "requesting rate")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoRate
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Wrap(fmt.Errorf("rates service returned status %d", resp.StatusCode))
+	}
+
+	var body struct {
+		Rate chainjson.Decimal `json:"rate"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding rate")
+	}
+	r := body.Rate.Rat()
+	if r == nil || r.Sign() < 0 {
+		return nil, errors.Wrap(fmt.Errorf("invalid rate %q", body.Rate))
+	}
+	return r, nil
+}
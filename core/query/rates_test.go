@@ -0,0 +1,97 @@
+package query
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+type fakeRates struct {
+	rates map[bc.AssetID]*big.Rat
+	calls int
+}
+
+func (f *fakeRates) Rate(ctx context.Context, from, to bc.AssetID, t time.Time) (*big.Rat, error) {
+	f.calls++
+	r, ok := f.rates[from]
+	if !ok {
+		return nil, ErrNoRate
+	}
+	return r, nil
+}
+
+func TestConvert(t *testing.T) {
+	ctx := context.Background()
+	usd, eur, gold := bc.AssetID{1}, bc.AssetID{2}, bc.AssetID{3}
+	rates := &fakeRates{rates: map[bc.AssetID]*big.Rat{eur: big.NewRat(108, 100)}}
+
+	ind := &Indexer{}
+	_, err := ind.NewConverter(usd)
+	if errors.Root(err) != ErrNoRatesProvider {
+		t.Fatalf("NewConverter without rates = %v want %v", err, ErrNoRatesProvider)
+	}
+	ind.Rates = rates
+	conv, err := ind.NewConverter(usd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	cases := []struct {
+		amount uint64
+		asset  bc.AssetID
+		want   *Converted
+	}{
+		{250, eur, &Converted{AssetID: usd, Amount: "270", Rate: "1.08"}},
+		{1, eur, &Converted{AssetID: usd, Amount: "1.08", Rate: "1.08"}},
+		{7, usd, &Converted{AssetID: usd, Amount: "7", Rate: "1"}},
+		{5, gold, nil},
+	}
+	for _, c := range cases {
+		got, err := conv.Convert(ctx, c.amount, c.asset, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got == nil) != (c.want == nil) || got != nil && *got != *c.want {
+			t.Errorf("Convert(%d, %x) = %+v want %+v", c.amount, c.asset[:1], got, c.want)
+		}
+	}
+	if rates.calls != 2 {
+		t.Errorf("rate lookups = %d want 2 (one per asset and time)", rates.calls)
+	}
+}
+
+func TestHTTPRates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ms, _ := strconv.ParseUint(req.URL.Query().Get("time"), 10, 64)
+		if ms == 0 || req.URL.Query().Get("from") == "" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write([]byte(`{"rate": "0.5"}`))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	h := &HTTPRates{URL: ts.URL}
+	r, err := h.Rate(ctx, bc.AssetID{1}, bc.AssetID{2}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := decimal(r); got != chainjson.Decimal("0.5") {
+		t.Errorf("Rate = %s want 0.5", got)
+	}
+
+	_, err = h.Rate(ctx, bc.AssetID{1}, bc.AssetID{2}, time.Unix(0, 0))
+	if errors.Root(err) != ErrNoRate {
+		t.Errorf("Rate for unknown time = %v want %v", err, ErrNoRate)
+	}
+}
@@ -0,0 +1,324 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"chain/database/pg"
+	"chain/errors"
+)
+
+var (
+	// ErrBadPeriod is returned by GenerateStatement when
+	// the period doesn't end after it starts.
+	ErrBadPeriod = errors.New("invalid statement period")
+
+	// ErrUnreconciled is returned by GenerateStatement when
+	// the transactions in a period don't account for the
+	// difference between the opening and closing balances.
+	ErrUnreconciled = errors.New("statement does not reconcile")
+)
+
+// Period is a span of time, in milliseconds since the epoch.
+// It includes blocks with timestamps after StartTimeMS,
+// up to and including EndTimeMS.
+type Period struct {
+	StartTimeMS uint64 `json:"start_time"`
+	EndTimeMS   uint64 `json:"end_time"`
+}
+
+// Statement lists the changes to an account's balances over a
+// period. Each asset's opening balance, plus its credits, less
+// its debits, equals its closing balance.
+type Statement struct {
+	AccountID    string            `json:"account_id"`
+	AccountAlias string            `json:"account_alias,omitempty"`
+	Period       Period            `json:"period"`
+	Assets       []*AssetStatement `json:"assets"`
+	Entries      []*StatementEntry `json:"entries"`
+}
+
+// AssetStatement summarizes a statement for one asset.
+type AssetStatement struct {
+	AssetID        string `json:"asset_id"`
+	AssetAlias     string `json:"asset_alias,omitempty"`
+	OpeningBalance uint64 `json:"opening_balance"`
+	Credits        uint64 `json:"credits"`
+	Debits         uint64 `json:"debits"`
+	ClosingBalance uint64 `json:"closing_balance"`
+}
+
+// StatementEntry is the effect of one transaction on the
+// account's balance of one asset. Balance is the account's
+// balance of the asset after the transaction.
+type StatementEntry struct {
+	TransactionID  string                 `json:"transaction_id"`
+	Timestamp      time.Time              `json:"timestamp"`
+	BlockHeight    uint64                 `json:"block_height"`
+	AssetID        string                 `json:"asset_id"`
+	AssetAlias     string                 `json:"asset_alias,omitempty"`
+	Credit         uint64                 `json:"credit"`
+	Debit          uint64                 `json:"debit"`
+	Balance        uint64                 `json:"balance"`
+	Counterparties []*Counterparty        `json:"counterparties"`
+	ReferenceData  map[string]interface{} `json:"reference_data"`
+}
+
+// Counterparty is the other side of a statement entry:
+// where a debit went to, or where a credit came from.
+// Type is "account" for an account in this core,
+// "external" for a control program it doesn't know,
+// "issue" for a credit of newly issued units,
+// or "retire" for a debit of retired units.
+type Counterparty struct {
+	Type           string                 `json:"type"`
+	AccountID      string                 `json:"account_id,omitempty"`
+	AccountAlias   string                 `json:"account_alias,omitempty"`
+	ControlProgram string                 `json:"control_program,omitempty"`
+	Amount         uint64                 `json:"amount"`
+	ReferenceData  map[string]interface{} `json:"reference_data"`
+}
+
+// statementTx holds the fields of an annotated transaction
+// used in a statement.
+type statementTx struct {
+	ID            string                 `json:"id"`
+	Timestamp     time.Time              `json:"timestamp"`
+	BlockHeight   uint64                 `json:"block_height"`
+	ReferenceData map[string]interface{} `json:"reference_data"`
+	Inputs        []*statementIO         `json:"inputs"`
+	Outputs       []*statementIO         `json:"outputs"`
+}
+
+type statementIO struct {
+	Type           string                 `json:"type"`
+	AssetID        string                 `json:"asset_id"`
+	AssetAlias     string                 `json:"asset_alias"`
+	Amount         uint64                 `json:"amount"`
+	AccountID      string                 `json:"account_id"`
+	AccountAlias   string                 `json:"account_alias"`
+	ControlProgram string                 `json:"control_program"`
+	ReferenceData  map[string]interface{} `json:"reference_data"`
+}
+
+// GenerateStatement returns a statement of the given account's
+// transactions during period. An EndTimeMS of 0 means now.
+//
+// The opening and closing balances come from the annotated
+// outputs controlled by the account at the start and end of
+// the period, and the entries from the annotated transactions
+// in between. If the entries don't account for the difference
+// in balances, GenerateStatement returns ErrUnreconciled.
+func (ind *Indexer) GenerateStatement(ctx context.Context, accountID string, period Period) (*Statement, error) {
+	if period.EndTimeMS == 0 {
+		period.EndTimeMS = uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	}
+	if period.EndTimeMS <= period.StartTimeMS || period.EndTimeMS > math.MaxInt64 {
+		return nil, errors.WithDetail(ErrBadPeriod, "end_time must be after start_time")
+	}
+
+	stmt := &Statement{AccountID: accountID, Period: period}
+	assets := make(map[string]*AssetStatement)
+	asset := func(id, alias string) *AssetStatement {
+		a := assets[id]
+		if a == nil {
+			a = &AssetStatement{AssetID: id}
+			assets[id] = a
+		}
+		if alias != "" {
+			a.AssetAlias = alias
+		}
+		return a
+	}
+
+	opening, err := ind.accountBalances(ctx, accountID, period.StartTimeMS)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range opening {
+		asset(b.AssetID, b.AssetAlias).OpeningBalance = b.Amount
+	}
+	closing, err := ind.accountBalances(ctx, accountID, period.EndTimeMS)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range closing {
+		asset(b.AssetID, b.AssetAlias).ClosingBalance = b.Amount
+	}
+
+	txs, err := ind.accountTxs(ctx, accountID, period)
+	if err != nil {
+		return nil, err
+	}
+	balances := make(map[string]uint64)
+	for _, a := range assets {
+		balances[a.AssetID] = a.OpeningBalance
+	}
+	for _, tx := range txs {
+		for _, e := range statementEntries(accountID, tx) {
+			if stmt.AccountAlias == "" {
+				stmt.AccountAlias = accountAlias(accountID, tx)
+			}
+			a := asset(e.AssetID, e.AssetAlias)
+			a.Credits += e.Credit
+			a.Debits += e.Debit
+			balances[e.AssetID] += e.Credit
+			balances[e.AssetID] -= e.Debit
+			e.Balance = balances[e.AssetID]
+			stmt.Entries = append(stmt.Entries, e)
+		}
+	}
+
+	for _, a := range assets {
+		if a.OpeningBalance+a.Credits != a.ClosingBalance+a.Debits {
+			return nil, errors.WithDetailf(ErrUnreconciled,
+				"asset %s: opening balance %d plus credits %d less debits %d does not equal closing balance %d",
+				a.AssetID, a.OpeningBalance, a.Credits, a.Debits, a.ClosingBalance)
+		}
+		stmt.Assets = append(stmt.Assets, a)
+	}
+	sort.Sort(byAssetID(stmt.Assets))
+	return stmt, nil
+}
+
+// statementEntries returns an entry for each asset
+// whose balance in the account tx changes.
+func statementEntries(accountID string, tx *statementTx) []*StatementEntry {
+	var (
+		entries []*StatementEntry
+		byAsset = make(map[string]*StatementEntry)
+	)
+	entry := func(item *statementIO) *StatementEntry {
+		e := byAsset[item.AssetID]
+		if e == nil {
+			e = &StatementEntry{
+				TransactionID: tx.ID,
+				Timestamp:     tx.Timestamp,
+				BlockHeight:   tx.BlockHeight,
+				AssetID:       item.AssetID,
+				AssetAlias:    item.AssetAlias,
+				ReferenceData: tx.ReferenceData,
+			}
+			byAsset[item.AssetID] = e
+			entries = append(entries, e)
+		}
+		return e
+	}
+	for _, in := range tx.Inputs {
+		if in.AccountID == accountID {
+			entry(in).Debit += in.Amount
+		}
+	}
+	for _, out := range tx.Outputs {
+		if out.AccountID == accountID {
+			entry(out).Credit += out.Amount
+		}
+	}
+
+	var changed []*StatementEntry
+	for _, e := range entries {
+		if e.Credit == e.Debit {
+			continue // e.g. a transfer between two of the account's own outputs
+		}
+		// A net debit went to the transaction's other outputs;
+		// a net credit came from its other inputs.
+		others := tx.Inputs
+		if e.Debit > e.Credit {
+			others = tx.Outputs
+		}
+		for _, item := range others {
+			if item.AssetID != e.AssetID || item.AccountID == accountID {
+				continue
+			}
+			c := &Counterparty{Amount: item.Amount, ReferenceData: item.ReferenceData}
+			switch {
+			case item.AccountID != "":
+				c.Type, c.AccountID, c.AccountAlias = "account", item.AccountID, item.AccountAlias
+			case item.Type == "issue" || item.Type == "retire":
+				c.Type = item.Type
+			default:
+				c.Type, c.ControlProgram = "external", item.ControlProgram
+			}
+			e.Counterparties = append(e.Counterparties, c)
+		}
+		changed = append(changed, e)
+	}
+	return changed
+}
+
+func accountAlias(accountID string, tx *statementTx) string {
+	for _, items := range [][]*statementIO{tx.Inputs, tx.Outputs} {
+		for _, item := range items {
+			if item.AccountID == accountID && item.AccountAlias != "" {
+				return item.AccountAlias
+			}
+		}
+	}
+	return ""
+}
+
+type assetBalance struct {
+	AssetID    string
+	AssetAlias string
+	Amount     uint64
+}
+
+// accountBalances returns the account's balance of each asset
+// at the given time, from the annotated outputs.
+func (ind *Indexer) accountBalances(ctx context.Context, accountID string, timestampMS uint64) ([]assetBalance, error) {
+	const q = `
+		SELECT data->>'asset_id', COALESCE(MAX(data->>'asset_alias'), ''), SUM((data->>'amount')::numeric)
+		FROM annotated_outputs
+		WHERE data @> $1::jsonb AND timespan @> $2::int8
+		GROUP BY data->>'asset_id'
+	`
+	match, err := json.Marshal(map[string]string{"account_id": accountID})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var balances []assetBalance
+	err = pg.ForQueryRows(ctx, ind.db, q, string(match), timestampMS, func(assetID, alias string, amount uint64) {
+		balances = append(balances, assetBalance{assetID, alias, amount})
+	})
+	return balances, errors.Wrap(err, "querying account balances")
+}
+
+// accountTxs returns the annotated transactions during period
+// that spend from or pay to the account, in blockchain order.
+func (ind *Indexer) accountTxs(ctx context.Context, accountID string, period Period) ([]*statementTx, error) {
+	const q = `
+		SELECT t.data FROM annotated_txs t
+		JOIN query_blocks b ON b.height = t.block_height
+		WHERE b.timestamp > $1 AND b.timestamp <= $2
+			AND (t.data @> $3::jsonb OR t.data @> $4::jsonb)
+		ORDER BY t.block_height, t.tx_pos
+	`
+	in, err := json.Marshal(map[string]interface{}{"inputs": []interface{}{map[string]string{"account_id": accountID}}})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	out, err := json.Marshal(map[string]interface{}{"outputs": []interface{}{map[string]string{"account_id": accountID}}})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var txs []*statementTx
+	err = pg.ForQueryRows(ctx, ind.db, q, period.StartTimeMS, period.EndTimeMS, string(in), string(out), func(data []byte) error {
+		tx := new(statementTx)
+		err := json.Unmarshal(data, tx)
+		if err != nil {
+			return errors.Wrap(err, "decoding annotated transaction")
+		}
+		txs = append(txs, tx)
+		return nil
+	})
+	return txs, errors.Wrap(err, "querying account transactions")
+}
+
+type byAssetID []*AssetStatement
+
+func (a byAssetID) Len() int           { return len(a) }
+func (a byAssetID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAssetID) Less(i, j int) bool { return a[i].AssetID < a[j].AssetID }
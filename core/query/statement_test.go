@@ -0,0 +1,44 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatementEntries(t *testing.T) {
+	tx := &statementTx{
+		ID: "tx1",
+		Inputs: []*statementIO{
+			{Type: "spend", AssetID: "a1", Amount: 100, AccountID: "acc1"},
+			{Type: "issue", AssetID: "a2", Amount: 5},
+			{Type: "spend", AssetID: "a3", Amount: 7, AccountID: "acc1"},
+		},
+		Outputs: []*statementIO{
+			{Type: "control", AssetID: "a1", Amount: 60, AccountID: "acc2", AccountAlias: "bob"},
+			{Type: "control", AssetID: "a1", Amount: 30, ControlProgram: "beef"},
+			{Type: "control", AssetID: "a1", Amount: 10, AccountID: "acc1"},
+			{Type: "control", AssetID: "a2", Amount: 5, AccountID: "acc1"},
+			{Type: "control", AssetID: "a3", Amount: 7, AccountID: "acc1"},
+		},
+	}
+	got := statementEntries("acc1", tx)
+	want := []*StatementEntry{{
+		TransactionID: "tx1",
+		AssetID:       "a1",
+		Credit:        10,
+		Debit:         100,
+		Counterparties: []*Counterparty{
+			{Type: "account", AccountID: "acc2", AccountAlias: "bob", Amount: 60},
+			{Type: "external", ControlProgram: "beef", Amount: 30},
+		},
+	}, {
+		TransactionID:  "tx1",
+		AssetID:        "a2",
+		Credit:         5,
+		Counterparties: []*Counterparty{{Type: "issue", Amount: 5}},
+	}}
+	// a3 moves between the account's own outputs, so has no entry.
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("statementEntries:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
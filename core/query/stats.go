@@ -0,0 +1,248 @@
+package query
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chain/core/query/filter"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+)
+
+// DefaultSlowQueryThreshold is the default value of
+// Indexer.SlowQueryThreshold.
+const DefaultSlowQueryThreshold = time.Second
+
+const (
+	maxFilterStats = 1000 // distinct filters tracked individually
+	maxSlowQueries = 100  // most recent slow queries retained
+	otherFilters   = "(other)"
+
+	explainTimeout = time.Minute
+)
+
+// FilterStats holds execution statistics for all queries
+// of one kind (e.g. "transactions") with one filter.
+type FilterStats struct {
+	Kind   string             `json:"kind"`
+	Filter string             `json:"filter"`
+	Count  int64              `json:"count"`
+	Rows   int64              `json:"rows"`
+	Total  chainjson.Duration `json:"total_duration"`
+	Max    chainjson.Duration `json:"max_duration"`
+}
+
+// SlowQuery describes a single query that took longer than
+// the indexer's SlowQueryThreshold.
+//
+// RowsScanned, Indexes and SequentialScan come from running
+// the query again with EXPLAIN ANALYZE. That happens in the
+// background, one query at a time, and Explained reports
+// whether it's done; a query that arrives while another is
+// being explained isn't explained at all. RowsScanned is the number of
+// table rows read, including those rejected by the filter,
+// Indexes names the indexes used, and SequentialScan reports
+// whether any table was read in full.
+type SlowQuery struct {
+	Kind           string             `json:"kind"`
+	Filter         string             `json:"filter"`
+	Rows           int                `json:"rows"`
+	Duration       chainjson.Duration `json:"duration"`
+	Time           time.Time          `json:"time"`
+	Explained      bool               `json:"explained"`
+	RowsScanned    int64              `json:"rows_scanned"`
+	Indexes        []string           `json:"indexes"`
+	SequentialScan bool               `json:"sequential_scan"`
+
+	seq uint64
+}
+
+type filterKey struct {
+	kind, filter string
+}
+
+type queryStats struct {
+	mu         sync.Mutex
+	filters    map[filterKey]*FilterStats
+	slow       []SlowQuery // oldest first
+	slowSeq    uint64
+	explaining bool
+}
+
+// recordQuery records the execution of a query of the given kind,
+// run as sql with args. The duration should include only time spent
+// in the database and scanning rows, not time spent waiting for new
+// blocks.
+func (ind *Indexer) recordQuery(ctx context.Context, kind string, p filter.Predicate, sql string, args []interface{}, dur time.Duration, rows int) {
+	f := p.String()
+	slow := ind.SlowQueryThreshold > 0 && dur >= ind.SlowQueryThreshold
+	if slow {
+		log.Write(ctx, log.KeyMessage, "slow query", "kind", kind, "filter", f, "duration", dur, "rows", rows)
+	}
+
+	s := &ind.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := filterKey{kind, f}
+	fs := s.filters[key]
+	if fs == nil {
+		if len(s.filters) >= maxFilterStats {
+			key.filter = otherFilters
+			fs = s.filters[key]
+		}
+		if fs == nil {
+			fs = &FilterStats{Kind: key.kind, Filter: key.filter}
+			s.filters[key] = fs
+		}
+	}
+	fs.Count++
+	fs.Rows += int64(rows)
+	fs.Total.Duration += dur
+	if dur > fs.Max.Duration {
+		fs.Max.Duration = dur
+	}
+
+	if slow {
+		if len(s.slow) == maxSlowQueries {
+			copy(s.slow, s.slow[1:])
+			s.slow = s.slow[:len(s.slow)-1]
+		}
+		s.slowSeq++
+		s.slow = append(s.slow, SlowQuery{
+			Kind:     kind,
+			Filter:   f,
+			Rows:     rows,
+			Duration: chainjson.Duration{Duration: dur},
+			Time:     time.Now(),
+			seq:      s.slowSeq,
+		})
+		if ind.db != nil && !s.explaining {
+			s.explaining = true
+			go ind.explain(s.slowSeq, sql, args)
+		}
+	}
+}
+
+// explain runs sql again with EXPLAIN ANALYZE and records
+// the rows it scanned and the indexes it used in the slow
+// query numbered seq.
+func (ind *Indexer) explain(seq uint64, sql string, args []interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	var plan []byte
+	err := ind.db.QueryRow(ctx, "EXPLAIN (ANALYZE, FORMAT JSON) "+sql, args...).Scan(&plan)
+	var ps planStats
+	if err == nil {
+		ps, err = parsePlan(plan)
+	}
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "explaining slow query"))
+	}
+
+	s := &ind.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.explaining = false
+	if err != nil {
+		return
+	}
+	for i := range s.slow {
+		if q := &s.slow[i]; q.seq == seq {
+			q.Explained = true
+			q.RowsScanned = ps.rowsScanned
+			q.Indexes = ps.indexes
+			q.SequentialScan = ps.seqScan
+		}
+	}
+}
+
+// planNode is a node of a query plan
+// from EXPLAIN (ANALYZE, FORMAT JSON).
+type planNode struct {
+	NodeType            string     `json:"Node Type"`
+	RelationName        string     `json:"Relation Name"`
+	IndexName           string     `json:"Index Name"`
+	ActualRows          float64    `json:"Actual Rows"`
+	ActualLoops         float64    `json:"Actual Loops"`
+	RowsRemovedByFilter float64    `json:"Rows Removed by Filter"`
+	Plans               []planNode `json:"Plans"`
+}
+
+type planStats struct {
+	rowsScanned int64
+	indexes     []string
+	seqScan     bool
+}
+
+// parsePlan summarizes the output of EXPLAIN (ANALYZE, FORMAT JSON).
+func parsePlan(b []byte) (planStats, error) {
+	var plans []struct {
+		Plan planNode `json:"Plan"`
+	}
+	err := json.Unmarshal(b, &plans)
+	if err != nil {
+		return planStats{}, errors.Wrap(err, "decoding query plan")
+	}
+
+	var ps planStats
+	indexes := make(map[string]bool)
+	var walk func(n *planNode)
+	walk = func(n *planNode) {
+		if n.IndexName != "" {
+			indexes[n.IndexName] = true
+		}
+		// Scans that read a table name it; the rows they read
+		// are those they return plus those the filter removed,
+		// per loop.
+		if n.RelationName != "" && strings.HasSuffix(n.NodeType, "Scan") {
+			ps.rowsScanned += int64((n.ActualRows + n.RowsRemovedByFilter) * n.ActualLoops)
+		}
+		if n.NodeType == "Seq Scan" {
+			ps.seqScan = true
+		}
+		for i := range n.Plans {
+			walk(&n.Plans[i])
+		}
+	}
+	for i := range plans {
+		walk(&plans[i].Plan)
+	}
+	for name := range indexes {
+		ps.indexes = append(ps.indexes, name)
+	}
+	sort.Strings(ps.indexes)
+	return ps, nil
+}
+
+// QueryStats returns execution statistics for every filter
+// queried since the indexer was created, ordered by total
+// time spent, and the most recent slow queries, newest first.
+func (ind *Indexer) QueryStats() ([]FilterStats, []SlowQuery) {
+	s := &ind.stats
+	s.mu.Lock()
+	filters := make([]FilterStats, 0, len(s.filters))
+	for _, fs := range s.filters {
+		filters = append(filters, *fs)
+	}
+	slow := make([]SlowQuery, 0, len(s.slow))
+	for i := len(s.slow) - 1; i >= 0; i-- {
+		slow = append(slow, s.slow[i])
+	}
+	s.mu.Unlock()
+
+	sort.Sort(byTotalDuration(filters))
+	return filters, slow
+}
+
+type byTotalDuration []FilterStats
+
+func (a byTotalDuration) Len() int           { return len(a) }
+func (a byTotalDuration) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byTotalDuration) Less(i, j int) bool { return a[i].Total.Duration > a[j].Total.Duration }
@@ -0,0 +1,66 @@
+package query
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"chain/core/query/filter"
+)
+
+func TestQueryStats(t *testing.T) {
+	ctx := context.Background()
+	ind := NewIndexer(nil, nil, nil)
+	ind.SlowQueryThreshold = time.Second
+
+	p1, err := filter.Parse("asset_alias = $1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := filter.Parse("account_alias = $1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ind.recordQuery(ctx, "outputs", p1, "", nil, 10*time.Millisecond, 3)
+	ind.recordQuery(ctx, "outputs", p1, "", nil, 30*time.Millisecond, 5)
+	ind.recordQuery(ctx, "transactions", p2, "", nil, 2*time.Second, 1)
+
+	filters, slow := ind.QueryStats()
+	if len(filters) != 2 {
+		t.Fatalf("got %d filter stats want 2", len(filters))
+	}
+	if filters[0].Kind != "transactions" {
+		t.Errorf("slowest kind = %q want transactions", filters[0].Kind)
+	}
+	got := filters[1]
+	if got.Count != 2 || got.Rows != 8 || got.Total.Duration != 40*time.Millisecond || got.Max.Duration != 30*time.Millisecond {
+		t.Errorf("outputs stats = %+v", got)
+	}
+	if len(slow) != 1 || slow[0].Filter != p2.String() {
+		t.Errorf("slow queries = %+v want one for %q", slow, p2.String())
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	plan := `[{"Plan": {
+		"Node Type": "Limit", "Actual Rows": 2, "Actual Loops": 1,
+		"Plans": [{
+			"Node Type": "Nested Loop", "Actual Rows": 2, "Actual Loops": 1,
+			"Plans": [
+				{"Node Type": "Seq Scan", "Relation Name": "annotated_txs",
+					"Actual Rows": 2, "Actual Loops": 1, "Rows Removed by Filter": 98},
+				{"Node Type": "Index Scan", "Relation Name": "annotated_outputs",
+					"Index Name": "annotated_outputs_pkey", "Actual Rows": 1, "Actual Loops": 2}
+			]
+		}]
+	}}]`
+	got, err := parsePlan([]byte(plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := planStats{rowsScanned: 102, indexes: []string{"annotated_outputs_pkey"}, seqScan: true}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePlan = %+v want %+v", got, want)
+	}
+}
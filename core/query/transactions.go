@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"time"
 
 	"chain/core/query/filter"
 	"chain/errors"
@@ -83,9 +84,9 @@ func (ind *Indexer) Transactions(ctx context.Context, p filter.Predicate, vals [
 	queryStr, queryArgs := constructTransactionsQuery(expr, after, asc, limit)
 
 	if asc {
-		return ind.waitForAndFetchTransactions(ctx, queryStr, queryArgs, after, limit)
+		return ind.waitForAndFetchTransactions(ctx, p, queryStr, queryArgs, after, limit)
 	}
-	return ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+	return ind.fetchTransactions(ctx, p, queryStr, queryArgs, after, limit)
 }
 
 // If asc is true, the transactions will be returned from "in front" of the `after`
@@ -125,7 +126,8 @@ func constructTransactionsQuery(expr filter.SQLExpr, after TxAfter, asc bool, li
 	return buf.String(), vals
 }
 
-func (ind *Indexer) fetchTransactions(ctx context.Context, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
+func (ind *Indexer) fetchTransactions(ctx context.Context, p filter.Predicate, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
+	start := time.Now()
 	rows, err := ind.db.Query(ctx, queryStr, queryArgs...)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "executing txn query")
@@ -145,6 +147,7 @@ func (ind *Indexer) fetchTransactions(ctx context.Context, queryStr string, quer
 	if err != nil {
 		return nil, nil, errors.Wrap(err)
 	}
+	ind.recordQuery(ctx, "transactions", p, queryStr, queryArgs, time.Since(start), len(txns))
 	return txns, &after, nil
 }
 
@@ -154,7 +157,7 @@ type fetchResp struct {
 	err   error
 }
 
-func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
+func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, p filter.Predicate, queryStr string, queryArgs []interface{}, after TxAfter, limit int) ([]interface{}, *TxAfter, error) {
 	resp := make(chan fetchResp, 1)
 	go func() {
 		var (
@@ -164,13 +167,14 @@ func (ind *Indexer) waitForAndFetchTransactions(ctx context.Context, queryStr st
 		)
 
 		for h := ind.c.Height(); len(txs) == 0; h++ {
-			<-ind.pinStore.PinWaiter(TxPinName, h)
-			if err != nil {
-				resp <- fetchResp{nil, nil, err}
+			select {
+			case <-ind.pinStore.PinWaiter(TxPinName, h):
+			case <-ctx.Done():
+				resp <- fetchResp{nil, nil, ctx.Err()}
 				return
 			}
 
-			txs, aft, err = ind.fetchTransactions(ctx, queryStr, queryArgs, after, limit)
+			txs, aft, err = ind.fetchTransactions(ctx, p, queryStr, queryArgs, after, limit)
 			if err != nil {
 				resp <- fetchResp{nil, nil, err}
 				return
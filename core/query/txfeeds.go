@@ -42,6 +42,10 @@ func (ind *Indexer) TxFeeds(ctx context.Context, after string, limit int) ([]*tx
 		return nil, "", errors.Wrap(err)
 	}
 
+	err = txfeed.LoadSinks(ctx, ind.db, txfeeds)
+	if err != nil {
+		return nil, "", err
+	}
 	return txfeeds, after, nil
 }
 
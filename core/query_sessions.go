@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/query"
+	"chain/errors"
+	"chain/net/http/httpjson"
+)
+
+// POST /create-query-session
+//
+// createQuerySession pins a block, by default the most recently
+// indexed one. List requests that carry the returned session
+// see the blockchain as of that block, however many blocks
+// arrive in between, so several queries can be reconciled
+// with each other.
+func (h *Handler) createQuerySession(ctx context.Context, in struct {
+	BlockHeight uint64 `json:"block_height"`
+}) (query.Checkpoint, error) {
+	return h.Indexer.LookupCheckpoint(ctx, in.BlockHeight)
+}
+
+// applySession restricts in to the block pinned by its
+// session, if it has one. The session's timestamp is looked
+// up again, so a client can't move it away from the block.
+func (h *Handler) applySession(ctx context.Context, in *requestQuery) error {
+	if in.Session == nil {
+		return nil
+	}
+	if in.Session.BlockHeight == 0 {
+		return errors.WithDetail(httpjson.ErrBadRequest, "session has no block_height")
+	}
+	cp, err := h.Indexer.LookupCheckpoint(ctx, in.Session.BlockHeight)
+	if err != nil {
+		return err
+	}
+	if in.TimestampMS != 0 && in.TimestampMS != cp.TimestampMS {
+		return errors.WithDetail(httpjson.ErrBadRequest, "timestamp can't be used with a session")
+	}
+	if in.AscLongPoll {
+		return errors.WithDetail(httpjson.ErrBadRequest, "ascending_with_long_poll can't be used with a session")
+	}
+	in.TimestampMS = cp.TimestampMS
+	if in.EndTimeMS == 0 || in.EndTimeMS > cp.TimestampMS {
+		in.EndTimeMS = cp.TimestampMS
+	}
+	in.Session = &cp
+	return nil
+}
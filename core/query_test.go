@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"math/big"
 	"testing"
 	"time"
 
@@ -49,3 +51,33 @@ func TestQueryWithClockSkew(t *testing.T) {
 		t.Errorf("got=%d txs, want %d", count, 1)
 	}
 }
+
+type noRates struct{}
+
+func (noRates) Rate(context.Context, bc.AssetID, bc.AssetID, time.Time) (*big.Rat, error) {
+	return nil, query.ErrNoRate
+}
+
+func TestConvertAmountPrecision(t *testing.T) {
+	ctx := context.Background()
+	ind := &query.Indexer{Rates: noRates{}}
+	assetID := bc.AssetID{1}
+	conv, err := ind.NewConverter(assetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2^53+1 can't be held exactly by a float64.
+	m := map[string]interface{}{
+		"amount":   json.Number("9007199254740993"),
+		"asset_id": assetID.String(),
+	}
+	got, err := convertAmount(ctx, conv, m, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := got.(*query.Converted)
+	if !ok || c.Amount != "9007199254740993" {
+		t.Errorf("convertAmount = %+v want amount 9007199254740993", got)
+	}
+}
@@ -0,0 +1,272 @@
+// Package quota limits the transactions that access tokens
+// and accounts may build each business day.
+//
+// A quota caps either the number of transactions built in a
+// day, or the total amount of one asset they move. A token's
+// quotas count every transaction built with it; an account's
+// quotas count the transactions that spend from it. Quotas
+// are charged when a transaction is built, whether or not
+// it's later submitted.
+package quota
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Subject types.
+const (
+	SubjectToken   = "token"
+	SubjectAccount = "account"
+)
+
+var (
+	// ErrExceeded is returned by Charge when a transaction
+	// would take a subject over one of its quotas.
+	ErrExceeded = errors.New("daily quota exceeded")
+
+	// ErrBadQuota is returned by Set when
+	// called with an invalid quota.
+	ErrBadQuota = errors.New("invalid quota")
+)
+
+// A Quota limits the transactions a token or account may
+// build in a business day. With no AssetID, Limit caps the
+// number of transactions. Otherwise it caps the total amount
+// of that asset the transactions spend or issue.
+type Quota struct {
+	SubjectType string      `json:"subject_type"`
+	SubjectID   string      `json:"subject_id"`
+	AssetID     *bc.AssetID `json:"asset_id,omitempty"`
+	Limit       uint64      `json:"limit"`
+}
+
+// Status reports how much of a quota has
+// been used on the current business day.
+type Status struct {
+	Quota
+	Day       string `json:"day"`
+	Used      uint64 `json:"used"`
+	Remaining uint64 `json:"remaining"`
+}
+
+// A Charge is what building a transaction counts against
+// the quotas of one subject. Amounts holds the amount of
+// each asset spent or issued on the subject's behalf.
+type Charge struct {
+	SubjectType string
+	SubjectID   string
+	Amounts     map[bc.AssetID]uint64
+}
+
+// Manager stores quotas and the daily usage counted against them.
+type Manager struct {
+	DB pg.DB
+
+	// Location defines the business day. If it's nil,
+	// days run from midnight to midnight UTC.
+	Location *time.Location
+}
+
+// day returns the business day containing t.
+func (m *Manager) day(t time.Time) string {
+	loc := m.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format("2006-01-02")
+}
+
+// Set creates or replaces a quota.
+func (m *Manager) Set(ctx context.Context, q Quota) error {
+	if q.SubjectType != SubjectToken && q.SubjectType != SubjectAccount {
+		return errors.WithDetailf(ErrBadQuota, "subject_type must be %q or %q", SubjectToken, SubjectAccount)
+	}
+	if q.SubjectID == "" {
+		return errors.WithDetail(ErrBadQuota, "subject_id is required")
+	}
+	if q.Limit > math.MaxInt64 {
+		return errors.WithDetailf(ErrBadQuota, "limit must be at most %d", uint64(math.MaxInt64))
+	}
+	const insertQ = `
+		INSERT INTO build_quotas (subject_type, subject_id, asset_id, quota)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subject_type, subject_id, asset_id) DO UPDATE SET quota = excluded.quota
+	`
+	_, err := m.DB.Exec(ctx, insertQ, q.SubjectType, q.SubjectID, assetKey(q.AssetID), int64(q.Limit))
+	return errors.Wrap(err, "saving quota")
+}
+
+// Delete removes a quota. Deleting a
+// quota that doesn't exist does nothing.
+func (m *Manager) Delete(ctx context.Context, subjectType, subjectID string, assetID *bc.AssetID) error {
+	const q = `
+		DELETE FROM build_quotas
+		WHERE subject_type = $1 AND subject_id = $2 AND asset_id = $3
+	`
+	_, err := m.DB.Exec(ctx, q, subjectType, subjectID, assetKey(assetID))
+	return errors.Wrap(err, "deleting quota")
+}
+
+// List returns the status of the quotas of the given
+// subject, or of every quota if subjectID is empty,
+// for the business day containing now.
+func (m *Manager) List(ctx context.Context, subjectType, subjectID string, now time.Time) ([]*Status, error) {
+	day := m.day(now)
+	const q = `
+		SELECT q.subject_type, q.subject_id, q.asset_id, q.quota, COALESCE(u.used, 0)
+		FROM build_quotas q
+		LEFT JOIN build_quota_usage u
+			ON u.subject_type = q.subject_type AND u.subject_id = q.subject_id
+			AND u.asset_id = q.asset_id AND u.day = $3::date
+		WHERE ($1 = '' OR q.subject_type = $1) AND ($2 = '' OR q.subject_id = $2)
+		ORDER BY q.subject_type, q.subject_id, q.asset_id
+	`
+	var statuses []*Status
+	err := pg.ForQueryRows(ctx, m.DB, q, subjectType, subjectID, day, func(typ, id string, asset []byte, limit, used int64) {
+		s := &Status{
+			Quota: Quota{SubjectType: typ, SubjectID: id, Limit: uint64(limit)},
+			Day:   day,
+			Used:  uint64(used),
+		}
+		if len(asset) > 0 {
+			var assetID bc.AssetID
+			copy(assetID[:], asset)
+			s.AssetID = &assetID
+		}
+		if s.Used < s.Limit {
+			s.Remaining = s.Limit - s.Used
+		}
+		statuses = append(statuses, s)
+	})
+	return statuses, errors.Wrap(err, "listing quotas")
+}
+
+// Charge counts one transaction, and the amounts in charges,
+// against the quotas of each subject for the business day
+// containing now. If any quota would be exceeded, nothing is
+// counted and Charge returns ErrExceeded.
+func (m *Manager) Charge(ctx context.Context, charges []Charge, now time.Time) error {
+	return m.apply(ctx, charges, now, 1)
+}
+
+// Refund undoes a Charge made with the same arguments,
+// for a transaction that couldn't be built after all.
+func (m *Manager) Refund(ctx context.Context, charges []Charge, now time.Time) error {
+	return m.apply(ctx, charges, now, -1)
+}
+
+// apply adds sign times each charge to the day's usage. Usage
+// rows hold a copy of their quota and a constraint that keeps
+// usage within it, so a charge that would exceed any quota
+// fails as a whole.
+func (m *Manager) apply(ctx context.Context, charges []Charge, now time.Time, sign int64) error {
+	type key struct {
+		typ, id string
+		asset   string
+	}
+	sums := make(map[key]uint64)
+	for _, c := range charges {
+		if c.SubjectID == "" {
+			continue
+		}
+		sums[key{c.SubjectType, c.SubjectID, ""}] = 1
+		for assetID, amount := range c.Amounts {
+			k := key{c.SubjectType, c.SubjectID, string(assetID[:])}
+			sum := sums[k] + amount
+			if sum < amount {
+				sum = math.MaxUint64
+			}
+			sums[k] = sum
+		}
+	}
+	if len(sums) == 0 {
+		return nil
+	}
+
+	var (
+		types, ids pq.StringArray
+		assets     pq.ByteaArray
+		amounts    pq.Int64Array
+	)
+	for k, amount := range sums {
+		if amount > math.MaxInt64 {
+			if sign > 0 {
+				return errors.WithDetailf(ErrExceeded, "amount exceeds the quota of %s %s", k.typ, k.id)
+			}
+			amount = math.MaxInt64
+		}
+		types = append(types, k.typ)
+		ids = append(ids, k.id)
+		assets = append(assets, []byte(k.asset))
+		amounts = append(amounts, sign*int64(amount))
+	}
+
+	day := m.day(now)
+	if sign < 0 {
+		const q = `
+			UPDATE build_quota_usage u SET used = greatest(u.used + c.amount, 0)
+			FROM unnest($1::text[], $2::text[], $3::bytea[], $4::bigint[])
+				AS c(subject_type, subject_id, asset_id, amount)
+			WHERE u.subject_type = c.subject_type AND u.subject_id = c.subject_id
+				AND u.asset_id = c.asset_id AND u.day = $5::date
+		`
+		_, err := m.DB.Exec(ctx, q, types, ids, assets, amounts, day)
+		return errors.Wrap(err, "refunding quotas")
+	}
+
+	const q = `
+		INSERT INTO build_quota_usage (subject_type, subject_id, asset_id, day, used, quota)
+		SELECT q.subject_type, q.subject_id, q.asset_id, $5::date, c.amount, q.quota
+		FROM unnest($1::text[], $2::text[], $3::bytea[], $4::bigint[])
+			AS c(subject_type, subject_id, asset_id, amount)
+		JOIN build_quotas q USING (subject_type, subject_id, asset_id)
+		ON CONFLICT (subject_type, subject_id, asset_id, day) DO UPDATE
+		SET used = build_quota_usage.used + excluded.used, quota = excluded.quota
+	`
+	_, err := m.DB.Exec(ctx, q, types, ids, assets, amounts, day)
+	if pg.IsCheckViolation(err) {
+		return m.exceeded(ctx, charges, now)
+	}
+	return errors.Wrap(err, "charging quotas")
+}
+
+// exceeded returns an ErrExceeded naming a quota
+// that charges would exceed, if it can find one.
+func (m *Manager) exceeded(ctx context.Context, charges []Charge, now time.Time) error {
+	for _, c := range charges {
+		statuses, err := m.List(ctx, c.SubjectType, c.SubjectID, now)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			want := uint64(1)
+			if s.AssetID != nil {
+				want = c.Amounts[*s.AssetID]
+			}
+			if want > s.Remaining {
+				if s.AssetID == nil {
+					return errors.WithDetailf(ErrExceeded, "%s %s has built its %d transactions for %s", s.SubjectType, s.SubjectID, s.Limit, s.Day)
+				}
+				return errors.WithDetailf(ErrExceeded, "%s %s has %d of asset %s remaining for %s", s.SubjectType, s.SubjectID, s.Remaining, s.AssetID, s.Day)
+			}
+		}
+	}
+	return errors.Wrap(ErrExceeded)
+}
+
+// assetKey returns the asset_id column value for a quota:
+// the asset ID, or empty for a transaction count quota.
+func assetKey(assetID *bc.AssetID) []byte {
+	if assetID == nil {
+		return []byte{}
+	}
+	return assetID[:]
+}
@@ -0,0 +1,87 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestCharge(t *testing.T) {
+	ctx := context.Background()
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	m := &Manager{DB: db}
+	asset := bc.AssetID{1}
+	now := time.Date(2017, 1, 26, 12, 0, 0, 0, time.UTC)
+
+	err := m.Set(ctx, Quota{SubjectType: SubjectToken, SubjectID: "treasury", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Set(ctx, Quota{SubjectType: SubjectAccount, SubjectID: "acc1", AssetID: &asset, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	charge := func(amount uint64) []Charge {
+		return []Charge{
+			{SubjectType: SubjectToken, SubjectID: "treasury", Amounts: map[bc.AssetID]uint64{asset: amount}},
+			{SubjectType: SubjectAccount, SubjectID: "acc1", Amounts: map[bc.AssetID]uint64{asset: amount}},
+		}
+	}
+
+	err = m.Charge(ctx, charge(60), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Charge(ctx, charge(60), now)
+	if errors.Root(err) != ErrExceeded {
+		t.Fatalf("Charge over the amount quota = %v want %v", err, ErrExceeded)
+	}
+
+	// The failed charge counted nothing, so the
+	// token has one transaction left today.
+	err = m.Charge(ctx, charge(40), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Charge(ctx, charge(0), now)
+	if errors.Root(err) != ErrExceeded {
+		t.Fatalf("Charge over the transaction quota = %v want %v", err, ErrExceeded)
+	}
+
+	err = m.Refund(ctx, charge(40), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := m.List(ctx, SubjectAccount, "acc1", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Used != 60 || statuses[0].Remaining != 40 {
+		t.Errorf("account quota status = %+v want used 60, remaining 40", statuses)
+	}
+
+	// Quotas start over the next business day.
+	err = m.Charge(ctx, charge(100), now.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDay(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2017, 1, 27, 3, 0, 0, 0, time.UTC)
+	if got := (&Manager{}).day(now); got != "2017-01-27" {
+		t.Errorf("day in UTC = %s want 2017-01-27", got)
+	}
+	if got := (&Manager{Location: nyc}).day(now); got != "2017-01-26" {
+		t.Errorf("day in New York = %s want 2017-01-26", got)
+	}
+}
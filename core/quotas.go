@@ -0,0 +1,137 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chain/core/quota"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errQuotasDisabled = errors.New("build quotas are not enabled")
+
+// quotaCharges returns what building a transaction counts
+// against quotas: one transaction and the amounts spent and
+// issued for the request's access token, and one transaction
+// and the amounts spent for each account it spends from.
+// actions holds the JSON encoding of each spend_account and
+// issue action, with any aliases already resolved.
+func quotaCharges(ctx context.Context, actions [][]byte) ([]quota.Charge, error) {
+	tokenID, _, _ := httpjson.Request(ctx).BasicAuth()
+	token := quota.Charge{
+		SubjectType: quota.SubjectToken,
+		SubjectID:   tokenID,
+		Amounts:     make(map[bc.AssetID]uint64),
+	}
+	accounts := make(map[string]quota.Charge)
+	for _, b := range actions {
+		var a struct {
+			bc.AssetAmount
+			AccountID string `json:"account_id"`
+		}
+		err := json.Unmarshal(b, &a)
+		if err != nil {
+			return nil, errors.WithDetail(errBadAction, err.Error())
+		}
+		token.Amounts[a.AssetID] += a.Amount
+		if a.AccountID == "" {
+			continue
+		}
+		c, ok := accounts[a.AccountID]
+		if !ok {
+			c = quota.Charge{
+				SubjectType: quota.SubjectAccount,
+				SubjectID:   a.AccountID,
+				Amounts:     make(map[bc.AssetID]uint64),
+			}
+			accounts[a.AccountID] = c
+		}
+		c.Amounts[a.AssetID] += a.Amount
+	}
+
+	charges := []quota.Charge{token}
+	for _, c := range accounts {
+		charges = append(charges, c)
+	}
+	return charges, nil
+}
+
+// buildWithQuotas charges charges against the build quotas,
+// then calls build. If build fails, the charge is refunded.
+func (h *Handler) buildWithQuotas(ctx context.Context, charges []quota.Charge, build func() error) error {
+	if h.Quotas == nil {
+		return build()
+	}
+	now := time.Now()
+	err := h.Quotas.Charge(ctx, charges, now)
+	if err != nil {
+		return err
+	}
+	err = build()
+	if err != nil {
+		refundErr := h.Quotas.Refund(ctx, charges, now)
+		if refundErr != nil {
+			log.Error(ctx, refundErr, "refunding build quotas")
+		}
+	}
+	return err
+}
+
+// POST /set-build-quota
+//
+// setBuildQuota creates or replaces a daily build quota.
+// It requires an admin access token.
+func (h *Handler) setBuildQuota(ctx context.Context, q quota.Quota) error {
+	if h.Quotas == nil {
+		return errQuotasDisabled
+	}
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	return h.Quotas.Set(ctx, q)
+}
+
+// POST /delete-build-quota
+//
+// deleteBuildQuota removes a daily build quota.
+// It requires an admin access token.
+func (h *Handler) deleteBuildQuota(ctx context.Context, x struct {
+	SubjectType string      `json:"subject_type"`
+	SubjectID   string      `json:"subject_id"`
+	AssetID     *bc.AssetID `json:"asset_id"`
+}) error {
+	if h.Quotas == nil {
+		return errQuotasDisabled
+	}
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	return h.Quotas.Delete(ctx, x.SubjectType, x.SubjectID, x.AssetID)
+}
+
+// POST /list-build-quotas
+//
+// listBuildQuotas reports the quotas of a subject, or all
+// quotas, with the amount used and remaining today.
+func (h *Handler) listBuildQuotas(ctx context.Context, x struct {
+	SubjectType string `json:"subject_type"`
+	SubjectID   string `json:"subject_id"`
+}) ([]*quota.Status, error) {
+	if h.Quotas == nil {
+		return nil, errQuotasDisabled
+	}
+	statuses, err := h.Quotas.List(ctx, x.SubjectType, x.SubjectID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if statuses == nil {
+		statuses = []*quota.Status{}
+	}
+	return statuses, nil
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"chain/core/account"
+	"chain/core/query"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// createAccountReceiver creates receivers: control programs
+// for accounts that expire at expires_at or, if it's unset,
+// after the core's default receiver expiry.
+//
+// POST /create-account-receiver
+func (h *Handler) createAccountReceiver(ctx context.Context, ins []struct {
+	AccountID    string    `json:"account_id"`
+	AccountAlias string    `json:"account_alias"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		accountID, err := h.requestAccountID(ctx, ins[i].AccountID, ins[i].AccountAlias)
+		if err != nil {
+			return nil, err
+		}
+		return h.Accounts.CreateReceiver(ctx, accountID, ins[i].ExpiresAt)
+	})
+}
+
+// extendAccountReceiver changes when an account's
+// receiver expires.
+//
+// POST /extend-account-receiver
+func (h *Handler) extendAccountReceiver(ctx context.Context, x struct {
+	AccountID      string             `json:"account_id"`
+	AccountAlias   string             `json:"account_alias"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+}) (*account.Receiver, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.ExtendReceiver(ctx, accountID, x.ControlProgram, x.ExpiresAt)
+}
+
+// listAccountReceivers lists the receivers of the account
+// with the given account_id that haven't expired, oldest first.
+//
+// POST /list-account-receivers
+func (h *Handler) listAccountReceivers(ctx context.Context, x requestQuery) (*page, error) {
+	if x.AccountID == "" {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "account_id is required")
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	var after uint64
+	if x.After != "" {
+		var err error
+		after, err = strconv.ParseUint(x.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(query.ErrBadAfter, "value: %q", x.After)
+		}
+	}
+
+	receivers, last, err := h.Accounts.ListReceivers(ctx, x.AccountID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = strconv.FormatUint(last, 10)
+
+	return &page{
+		Items:    httpjson.Array(receivers),
+		LastPage: len(receivers) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+type paymentURIResp struct {
+	URI string `json:"uri"`
+	*account.PaymentRequest
+}
+
+// createPaymentURI makes payment URIs, which wallets of any
+// vendor can parse, asking for payment to a control_program
+// or, if it's unset, to a new receiver for the given account.
+// The asset, amount, expiration and memo are optional. The
+// URI names the blockchain of this core.
+//
+// POST /create-payment-uri
+func (h *Handler) createPaymentURI(ctx context.Context, ins []struct {
+	AccountID      string             `json:"account_id"`
+	AccountAlias   string             `json:"account_alias"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+	AssetID        *bc.AssetID        `json:"asset_id"`
+	AssetAlias     string             `json:"asset_alias"`
+	Amount         uint64             `json:"amount"`
+	ExpiresAt      time.Time          `json:"expires_at"`
+	Memo           string             `json:"memo"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		in := ins[i]
+		r := &account.PaymentRequest{
+			ControlProgram: in.ControlProgram,
+			BlockchainID:   &h.Config.BlockchainID,
+			AssetID:        in.AssetID,
+			Amount:         in.Amount,
+			Memo:           in.Memo,
+		}
+		if !in.ExpiresAt.IsZero() {
+			r.ExpiresAt = &in.ExpiresAt
+		}
+		if len(r.ControlProgram) == 0 {
+			accountID, err := h.requestAccountID(ctx, in.AccountID, in.AccountAlias)
+			if err != nil {
+				return nil, err
+			}
+			rcvr, err := h.Accounts.CreateReceiver(ctx, accountID, in.ExpiresAt)
+			if err != nil {
+				return nil, err
+			}
+			r.ControlProgram = rcvr.ControlProgram
+			r.ExpiresAt = &rcvr.ExpiresAt
+		}
+		if r.AssetID == nil && in.AssetAlias != "" {
+			a, err := h.Assets.FindByAlias(ctx, in.AssetAlias)
+			if err != nil {
+				return nil, err
+			}
+			r.AssetID = &a.AssetID
+		}
+		return &paymentURIResp{URI: r.URI(), PaymentRequest: r}, nil
+	})
+}
+
+// parsePaymentURI parses payment URIs. It rejects URIs
+// for blockchains other than this core's.
+//
+// POST /parse-payment-uri
+func (h *Handler) parsePaymentURI(ctx context.Context, ins []struct {
+	URI string `json:"uri"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		r, err := account.ParsePaymentURI(ins[i].URI)
+		if err != nil {
+			return nil, err
+		}
+		if r.BlockchainID != nil && *r.BlockchainID != h.Config.BlockchainID {
+			return nil, errors.WithDetailf(account.ErrBadPaymentURI, "for blockchain %s", r.BlockchainID)
+		}
+		return &paymentURIResp{URI: ins[i].URI, PaymentRequest: r}, nil
+	})
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"strconv"
+
+	"chain/core/generator"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// rejectionsQuery selects rejections from the generator's
+// log, as in generator.Rejections.
+type rejectionsQuery struct {
+	TxHash *bc.Hash `json:"tx_hash,omitempty"`
+	After  uint64   `json:"after"`
+	Limit  int      `json:"limit"`
+}
+
+type rejectionsPage struct {
+	Rejections []*generator.Rejection `json:"rejections"`
+	After      uint64                 `json:"after"`
+}
+
+// POST /list-transaction-rejections
+func (h *Handler) listTxRejections(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+	q := rejectionsQuery{Limit: limit}
+	if x.After != "" {
+		var err error
+		q.After, err = strconv.ParseUint(x.After, 10, 64)
+		if err != nil {
+			return nil, errors.WithDetailf(httpjson.ErrBadRequest, "invalid after %q", x.After)
+		}
+	}
+
+	p, err := h.generatorRejections(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = strconv.FormatUint(p.After, 10)
+
+	return &page{
+		Items:    httpjson.Array(p.Rejections),
+		LastPage: len(p.Rejections) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// getTxRejection returns why the generator rejected
+// a transaction in the last day.
+//
+// POST /get-transaction-rejection
+func (h *Handler) getTxRejection(ctx context.Context, x struct {
+	ID bc.Hash `json:"id"`
+}) (*generator.Rejection, error) {
+	p, err := h.generatorRejections(ctx, rejectionsQuery{TxHash: &x.ID, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(p.Rejections) == 0 {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no rejection recorded for tx %s", x.ID)
+	}
+	return p.Rejections[0], nil
+}
+
+// generatorRejections reads the rejection log of the
+// generator, asking it for the log if it's remote.
+func (h *Handler) generatorRejections(ctx context.Context, q rejectionsQuery) (*rejectionsPage, error) {
+	switch s := h.Submitter.(type) {
+	case *generator.Generator:
+		rejections, after, err := s.Rejections(ctx, q.TxHash, q.After, q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		return &rejectionsPage{Rejections: rejections, After: after}, nil
+	case *txbuilder.RemoteGenerator:
+		p := new(rejectionsPage)
+		err := s.Peer.Call(ctx, "/rpc/list-rejections", q, p)
+		return p, errors.Wrap(err, "generator rejection log")
+	}
+	return nil, errors.Wrap(errNotGenerator)
+}
+
+// listRejectionsRPC reads the rejection log
+// for a core that isn't the generator.
+func (h *Handler) listRejectionsRPC(ctx context.Context, q rejectionsQuery) (*rejectionsPage, error) {
+	if _, ok := h.Submitter.(*generator.Generator); !ok {
+		return nil, errors.Wrap(errNotGenerator)
+	}
+	if q.Limit <= 0 {
+		q.Limit = defGenericPageSize
+	}
+	return h.generatorRejections(ctx, q)
+}
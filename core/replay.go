@@ -0,0 +1,159 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"chain/core/rpc"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/log"
+)
+
+var errReplayedRequest = errors.New("replayed request")
+
+// maxSeenNonces bounds the in-memory nonce cache.
+// Nonces evicted from it are still found in the database.
+const maxSeenNonces = 10000
+
+// replayGuard rejects network RPCs whose timestamp
+// is outside a window around the current time, or
+// whose nonce it has seen before, or whose MAC (see
+// rpc.MAC) doesn't match the secret of the token that
+// authenticated it. Nonces are stored
+// in the database until their request falls outside
+// the window, so a request captured on the wire can't
+// be replayed against a restarted process, or against
+// another process of the same core.
+type replayGuard struct {
+	db     pg.DB
+	window time.Duration
+
+	mu        sync.Mutex // protects the following
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+func newReplayGuard(db pg.DB, window time.Duration) *replayGuard {
+	return &replayGuard{
+		db:     db,
+		window: window,
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// check checks req, authenticated with the token secret,
+// and records its nonce. It reads req.Body and replaces
+// it with a reader over the same bytes.
+func (g *replayGuard) check(ctx context.Context, req *http.Request, secret string) error {
+	ms, err := strconv.ParseInt(req.Header.Get(rpc.HeaderRequestTime), 10, 64)
+	if err != nil {
+		return errors.WithDetail(errReplayedRequest, "missing or invalid "+rpc.HeaderRequestTime+" header")
+	}
+	reqTime := time.Unix(0, ms*int64(time.Millisecond))
+	now := time.Now()
+	if reqTime.Before(now.Add(-g.window)) || reqTime.After(now.Add(g.window)) {
+		return errors.WithDetail(errReplayedRequest, "request time is outside the replay window")
+	}
+	nonce, err := hex.DecodeString(req.Header.Get(rpc.HeaderNonce))
+	if err != nil || len(nonce) < 8 {
+		return errors.WithDetail(errReplayedRequest, "missing or invalid "+rpc.HeaderNonce+" header")
+	}
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return errors.Wrap(err, "reading request body")
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	mac, err := hex.DecodeString(req.Header.Get(rpc.HeaderMAC))
+	if err != nil {
+		return errors.WithDetail(errReplayedRequest, "missing or invalid "+rpc.HeaderMAC+" header")
+	}
+	want, _ := hex.DecodeString(rpc.MAC(secret, req.Method, req.URL.Path, body, req.Header.Get(rpc.HeaderRequestTime), req.Header.Get(rpc.HeaderNonce)))
+	if !hmac.Equal(mac, want) {
+		return errors.WithDetail(errReplayedRequest, "request MAC doesn't match")
+	}
+
+	// A nonce need only be remembered while a request
+	// carrying it would pass the time check above.
+	expires := reqTime.Add(g.window)
+	key := string(nonce)
+
+	g.mu.Lock()
+	_, dup := g.seen[key]
+	prune := now.Sub(g.lastPrune) > g.window
+	if prune {
+		g.lastPrune = now
+	}
+	g.mu.Unlock()
+	if dup {
+		return errors.WithDetail(errReplayedRequest, "nonce has already been used")
+	}
+
+	if prune {
+		// Don't let the end of this request cancel the prune.
+		go g.prune(context.Background())
+	}
+
+	const q = `
+		INSERT INTO rpc_nonces (nonce, expires_at) VALUES ($1, $2)
+		ON CONFLICT (nonce) DO NOTHING
+	`
+	res, err := g.db.Exec(ctx, q, nonce, expires)
+	if err != nil {
+		return errors.Wrap(err, "storing rpc nonce")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "storing rpc nonce")
+	}
+	if n == 0 {
+		return errors.WithDetail(errReplayedRequest, "nonce has already been used")
+	}
+
+	// Remember the nonce only once it's stored, so a failed
+	// insert doesn't leave it burned here but not elsewhere.
+	g.mu.Lock()
+	g.evictLocked(now)
+	g.seen[key] = expires
+	g.mu.Unlock()
+	return nil
+}
+
+// evictLocked removes expired nonces from the in-memory
+// cache, and then, if it is still full, an arbitrary one.
+// g.mu must be held.
+func (g *replayGuard) evictLocked(now time.Time) {
+	if len(g.seen) < maxSeenNonces {
+		return
+	}
+	for k, exp := range g.seen {
+		if now.After(exp) {
+			delete(g.seen, k)
+		}
+	}
+	for k := range g.seen {
+		if len(g.seen) < maxSeenNonces {
+			break
+		}
+		delete(g.seen, k)
+	}
+}
+
+func (g *replayGuard) prune(ctx context.Context) {
+	const q = `DELETE FROM rpc_nonces WHERE expires_at < now()`
+	_, err := g.db.Exec(ctx, q)
+	if err != nil {
+		log.Error(ctx, err, "at", "pruning rpc nonces")
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+)
+
+func TestReplayGuardRejects(t *testing.T) {
+	g := newReplayGuard(nil, time.Minute)
+	ms := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	const secret = "abcd1234"
+	now, nonce := ms(time.Now()), rpc.Nonce()
+	goodMAC := rpc.MAC(secret, "POST", "/rpc/submit", []byte("{}"), now, nonce)
+	cases := []struct {
+		reqTime, nonce, mac string
+	}{
+		{"", rpc.Nonce(), ""},
+		{"abc", rpc.Nonce(), ""},
+		{ms(time.Now().Add(-2 * time.Minute)), rpc.Nonce(), ""},
+		{ms(time.Now().Add(2 * time.Minute)), rpc.Nonce(), ""},
+		{now, "", ""},
+		{now, "not hex", ""},
+		{now, "abcd", ""},
+		{now, nonce, ""},
+		{now, nonce, "not hex"},
+		{now, nonce, rpc.MAC("wrong secret", "POST", "/rpc/submit", []byte("{}"), now, nonce)},
+		{now, rpc.Nonce(), goodMAC}, // fresh nonce, captured MAC
+		{ms(time.Now().Add(time.Second)), nonce, goodMAC},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", "/rpc/submit", strings.NewReader("{}"))
+		req.Header.Set(rpc.HeaderRequestTime, c.reqTime)
+		req.Header.Set(rpc.HeaderNonce, c.nonce)
+		req.Header.Set(rpc.HeaderMAC, c.mac)
+		err := g.check(context.Background(), req, secret)
+		if errors.Root(err) != errReplayedRequest {
+			t.Errorf("check(time=%q, nonce=%q, mac=%q) = %v want %v", c.reqTime, c.nonce, c.mac, err, errReplayedRequest)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"chain/core/leader"
+	"chain/errors"
+)
+
+// Headers describing the process that served a request.
+const (
+	// HeaderRole is "leader", "follower" or "replica".
+	HeaderRole = "Chain-Core-Role"
+
+	// HeaderStaleness is, on replicas, how far behind
+	// the core's leader the replica's view may be.
+	HeaderStaleness = "Chain-Core-Staleness"
+
+	// HeaderMaxStaleness is, on replicas, the most
+	// staleness the replica will serve requests with.
+	// Clients may send it to ask for a lower bound.
+	HeaderMaxStaleness = "Chain-Max-Staleness"
+)
+
+// stalenessCheckPeriod is how long a replica reuses
+// its last staleness measurement.
+const stalenessCheckPeriod = time.Second
+
+var (
+	errReplicaReadOnly = errors.New("request not served by query replicas")
+	errReplicaStale    = errors.New("replica too stale")
+)
+
+// replicaPaths lists the endpoints a query replica serves.
+// Everything else must go to the leader or a follower.
+var replicaPaths = map[string]bool{
+	"/list-accounts":                          true,
+	"/list-assets":                            true,
+	"/list-transactions":                      true,
+	"/list-balances":                          true,
+	"/list-unspent-outputs":                   true,
+	"/list-asset-circulation":                 true,
+	"/list-account-receivers":                 true,
+	"/list-account-spending-limits":           true,
+	"/list-account-key-generations":           true,
+	"/list-account-quorum-tiers":              true,
+	"/list-account-freezes":                   true,
+	"/list-counterparty-labels":               true,
+	"/list-program-templates":                 true,
+	"/list-swap-offers":                       true,
+	"/get-swap-offer":                         true,
+	"/list-duplicate-payments":                true,
+	"/list-watch-descriptors":                 true,
+	"/get-watch-balances":                     true,
+	"/list-balance-webhooks":                  true,
+	"/list-balance-alert-rules":               true,
+	"/list-balance-alerts":                    true,
+	"/generate-account-statement":             true,
+	"/create-query-session":                   true,
+	"/resolve-aliases":                        true,
+	"/disassemble-program":                    true,
+	"/get-state-diff":                         true,
+	"/info":                                   true,
+	"/errors":                                 true,
+	explorerPrefix + "search":                 true,
+	explorerPrefix + "list-blocks":            true,
+	explorerPrefix + "stats":                  true,
+	explorerPrefix + "list-asset-circulation": true,
+	networkRPCPrefix + "block-height":         true,
+}
+
+// replicaPrefixes lists the path prefixes a query
+// replica serves, in addition to replicaPaths.
+var replicaPrefixes = []string{"/dashboard/", "/docs/", "/debug/"}
+
+// replicaState caches a replica's staleness.
+type replicaState struct {
+	mu        sync.Mutex
+	checked   time.Time
+	staleness time.Duration
+}
+
+// role returns this process's role in the core,
+// as sent in HeaderRole.
+func (h *Handler) role() string {
+	switch {
+	case h.Replica:
+		return "replica"
+	case leader.IsLeading():
+		return "leader"
+	default:
+		return "follower"
+	}
+}
+
+// replicaHandler sets HeaderRole on every response. On a query
+// replica, it also rejects requests the replica doesn't serve and,
+// for the rest, sets HeaderStaleness and rejects the request if
+// the replica is staler than the request or the core allows.
+func (h *Handler) replicaHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(HeaderRole, h.role())
+		if !h.Replica || req.URL.Path == "/" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := req.Context()
+		if !servedByReplica(req.URL.Path) {
+			WriteHTTPError(ctx, w, errors.WithDetailf(errReplicaReadOnly, "send %s to the core's leader", req.URL.Path))
+			return
+		}
+
+		maxStaleness := h.MaxStaleness
+		if s := req.Header.Get(HeaderMaxStaleness); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				WriteHTTPError(ctx, w, errors.WithDetailf(errBadReqHeader, "%s: %q", HeaderMaxStaleness, s))
+				return
+			}
+			if maxStaleness == 0 || d < maxStaleness {
+				maxStaleness = d
+			}
+		}
+		if maxStaleness > 0 {
+			w.Header().Set(HeaderMaxStaleness, maxStaleness.String())
+		}
+
+		staleness, err := h.staleness(ctx)
+		if err != nil {
+			WriteHTTPError(ctx, w, err)
+			return
+		}
+		w.Header().Set(HeaderStaleness, staleness.String())
+		if maxStaleness > 0 && staleness > maxStaleness {
+			WriteHTTPError(ctx, w, errors.WithDetailf(errReplicaStale, "replica is %s behind the leader, more than %s", staleness, maxStaleness))
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// staleness returns how far behind the core's leader this
+// replica may be, measuring it at most once per
+// stalenessCheckPeriod.
+func (h *Handler) staleness(ctx context.Context) (time.Duration, error) {
+	s := &h.replica
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.checked) < stalenessCheckPeriod {
+		return s.staleness, nil
+	}
+	staleness, err := leader.Staleness(ctx, h.DB)
+	if err != nil {
+		return 0, err
+	}
+	s.staleness, s.checked = staleness, time.Now()
+	return staleness, nil
+}
+
+func servedByReplica(path string) bool {
+	if replicaPaths[path] {
+		return true
+	}
+	for _, prefix := range replicaPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+)
+
+func TestReplicaHandler(t *testing.T) {
+	db := pgtest.NewTx(t)
+	ctx := context.Background()
+	_, err := db.Exec(ctx, `INSERT INTO leader (leader_key, address, expiry) VALUES ('k', 'a', now() + interval '10 seconds')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{DB: db, Replica: true, MaxStaleness: time.Minute}
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+	handler := h.replicaHandler(next)
+
+	serve := func(path, maxStaleness string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, nil)
+		if maxStaleness != "" {
+			req.Header.Set(HeaderMaxStaleness, maxStaleness)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := serve("/list-accounts", "")
+	if resp.Code != 200 {
+		t.Fatalf("list-accounts status = %d want 200: %s", resp.Code, resp.Body)
+	}
+	if got := resp.Header().Get(HeaderRole); got != "replica" {
+		t.Errorf("role = %q want replica", got)
+	}
+	if got := resp.Header().Get(HeaderMaxStaleness); got != "1m0s" {
+		t.Errorf("max staleness = %q want 1m0s", got)
+	}
+	if d, err := time.ParseDuration(resp.Header().Get(HeaderStaleness)); err != nil || d > 5*time.Second {
+		t.Errorf("staleness = %q want at most 5s", resp.Header().Get(HeaderStaleness))
+	}
+
+	resp = serve("/create-account", "")
+	if resp.Code != 400 {
+		t.Errorf("create-account status = %d want 400", resp.Code)
+	}
+
+	resp = serve("/list-accounts", "bogus")
+	if resp.Code != 400 {
+		t.Errorf("bad max staleness status = %d want 400", resp.Code)
+	}
+
+	// Make the leader look like it last renewed a minute and a half ago.
+	_, err = db.Exec(ctx, `UPDATE leader SET expiry = now() - interval '80 seconds'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.replica.checked = time.Time{}
+	resp = serve("/list-accounts", "")
+	if resp.Code != 503 {
+		t.Errorf("stale replica status = %d want 503", resp.Code)
+	}
+}
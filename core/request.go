@@ -3,6 +3,7 @@ package core
 import (
 	"context"
 
+	"chain/core/asset"
 	"chain/encoding/json"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -18,29 +19,61 @@ type buildRequest struct {
 	Tx      *bc.TxData               `json:"base_transaction"`
 	Actions []map[string]interface{} `json:"actions"`
 	TTL     json.Duration            `json:"ttl"`
+
+	// ReservationTTL is how long the outputs the actions spend
+	// stay reserved. It defaults to, and can't exceed, TTL.
+	ReservationTTL json.Duration `json:"reservation_ttl"`
 }
 
 func (h *Handler) filterAliases(ctx context.Context, br *buildRequest) error {
 	for i, m := range br.Actions {
-		id, _ := m["assset_id"].(string)
-		alias, _ := m["asset_alias"].(string)
-		if id == "" && alias != "" {
-			asset, err := h.Assets.FindByAlias(ctx, alias)
-			if err != nil {
-				return errors.WithDetailf(err, "invalid asset alias %s on action %d", alias, i)
+		err := h.filterAssetAlias(ctx, m)
+		if err != nil {
+			return errors.WithData(err, errors.KeyActionIndex, i)
+		}
+
+		// An issue_many action names an asset in each issue.
+		issues, _ := m["issues"].([]interface{})
+		for _, iss := range issues {
+			if iss, ok := iss.(map[string]interface{}); ok {
+				err := h.filterAssetAlias(ctx, iss)
+				if err != nil {
+					return errors.WithData(err, errors.KeyActionIndex, i)
+				}
 			}
-			m["asset_id"] = asset.AssetID
 		}
 
-		id, _ = m["account_id"].(string)
-		alias, _ = m["account_alias"].(string)
+		id, _ := m["account_id"].(string)
+		alias, _ := m["account_alias"].(string)
 		if id == "" && alias != "" {
 			acc, err := h.Accounts.FindByAlias(ctx, alias)
 			if err != nil {
-				return errors.WithDetailf(err, "invalid account alias %s on action %d", alias, i)
+				err = errors.WithDetail(err, "invalid account alias")
+				return errors.WithData(err, errors.KeyActionIndex, i, errors.KeyAccountAlias, alias)
 			}
 			m["account_id"] = acc.ID
 		}
 	}
 	return nil
 }
+
+// filterAssetAlias sets the asset_id of m from
+// its asset_alias, if it has one and no asset_id.
+func (h *Handler) filterAssetAlias(ctx context.Context, m map[string]interface{}) error {
+	id, _ := m["assset_id"].(string)
+	alias, _ := m["asset_alias"].(string)
+	if id != "" || alias == "" {
+		return nil
+	}
+	a, err := h.findAssetByAlias(ctx, alias)
+	if err != nil {
+		err = errors.WithDetail(err, "invalid asset alias")
+		return errors.WithData(err, errors.KeyAssetAlias, alias)
+	}
+	if a.Archived {
+		err = errors.WithDetail(asset.ErrArchived, "invalid asset alias")
+		return errors.WithData(err, errors.KeyAssetAlias, alias)
+	}
+	m["asset_id"] = a.AssetID
+	return nil
+}
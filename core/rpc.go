@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"chain/core/fetch"
 	chainjson "chain/encoding/json"
 	"chain/errors"
+	"chain/log"
 	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 )
@@ -39,6 +41,59 @@ func (h *Handler) getBlocksRPC(ctx context.Context, afterHeight uint64) ([]chain
 	return []chainjson.HexBytes{block}, nil
 }
 
+// getBlockRangeRPC streams the blocks from the requested start
+// height to the end height, inclusive, as a single compressed
+// response with a checksum for each block. See
+// fetch.BlockRangeWriter for the format. It waits, like
+// getBlockRPC, for the block at the start height, but sends no
+// blocks past the current height, and no more than
+// fetch.MaxBlockRange of them.
+//
+// This handler doesn't use the httpjson.Handler format
+// so that it can write the response incrementally.
+func (h *Handler) getBlockRangeRPC(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var x fetch.BlockRangeRequest
+	err := json.NewDecoder(req.Body).Decode(&x)
+	if err != nil || x.StartHeight == 0 || x.EndHeight < x.StartHeight {
+		WriteHTTPError(ctx, rw, httpjson.ErrBadRequest)
+		return
+	}
+	err = <-h.Chain.BlockSoonWaiter(ctx, x.StartHeight)
+	if err != nil {
+		WriteHTTPError(ctx, rw, errors.Wrapf(err, "waiting for block at height %d", x.StartHeight))
+		return
+	}
+	end := x.EndHeight
+	if end-x.StartHeight >= fetch.MaxBlockRange {
+		end = x.StartHeight + fetch.MaxBlockRange - 1
+	}
+	if height := h.Chain.Height(); end > height {
+		end = height
+	}
+
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	w := fetch.NewBlockRangeWriter(rw)
+	err = h.Store.ForRawBlocks(ctx, x.StartHeight, end, func(height uint64, data []byte) error {
+		return w.WriteBlock(data)
+	})
+	if err != nil {
+		// Leave off the final frame, so
+		// the client sees the range is cut short.
+		log.Error(ctx, err)
+		return
+	}
+	err = w.Close()
+	if err != nil {
+		log.Error(ctx, err)
+	}
+}
+
 type snapshotInfoResp struct {
 	Height       uint64  `json:"height"`
 	Size         uint64  `json:"size"`
@@ -79,3 +134,87 @@ func (h *Handler) getSnapshotRPC(rw http.ResponseWriter, req *http.Request) {
 	rw.Header().Set("Content-Type", "application/x-protobuf")
 	rw.Write(data)
 }
+
+// blockHeaderResp is one line of the
+// /rpc/stream-block-headers response.
+type blockHeaderResp struct {
+	Height            uint64               `json:"height"`
+	Hash              bc.Hash              `json:"hash"`
+	PreviousBlockHash bc.Hash              `json:"previous_block_hash"`
+	ConsensusProgram  chainjson.HexBytes   `json:"consensus_program"`
+	Witness           []chainjson.HexBytes `json:"witness"`
+	Header            chainjson.HexBytes   `json:"header"`
+}
+
+// streamBlockHeadersRPC streams the header of each block, starting
+// at the requested height, as newline-delimited JSON. It waits for
+// new blocks and writes each one as soon as it lands, so a verifier
+// can follow the chain without fetching full blocks. The stream
+// ends when the client disconnects or the server's write timeout
+// elapses; the client resumes from the next height it needs.
+//
+// This handler doesn't use the httpjson.Handler format
+// so that it can write the response incrementally.
+func (h *Handler) streamBlockHeadersRPC(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var height uint64
+	err := json.NewDecoder(req.Body).Decode(&height)
+	if err != nil {
+		WriteHTTPError(ctx, rw, httpjson.ErrBadRequest)
+		return
+	}
+	if height == 0 {
+		height = 1
+	}
+	err = <-h.Chain.BlockSoonWaiter(ctx, height)
+	if err != nil {
+		WriteHTTPError(ctx, rw, errors.Wrapf(err, "waiting for block at height %d", height))
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := rw.(http.Flusher)
+	enc := json.NewEncoder(rw)
+	for ; ; height++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.Chain.BlockWaiter(height):
+		}
+
+		raw, err := h.Store.GetRawBlockHeader(ctx, height)
+		if err != nil {
+			log.Error(ctx, err)
+			return
+		}
+		var header bc.BlockHeader
+		err = header.Scan(raw)
+		if err != nil {
+			log.Error(ctx, errors.Wrap(err, "decoding block header"))
+			return
+		}
+		resp := &blockHeaderResp{
+			Height:            header.Height,
+			Hash:              header.Hash(),
+			PreviousBlockHash: header.PreviousBlockHash,
+			ConsensusProgram:  header.ConsensusProgram,
+			Witness:           make([]chainjson.HexBytes, 0, len(header.Witness)),
+			Header:            raw,
+		}
+		for _, w := range header.Witness {
+			resp.Witness = append(resp.Witness, w)
+		}
+		err = enc.Encode(resp)
+		if err != nil {
+			return // the client went away
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
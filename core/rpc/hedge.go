@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	"chain/errors"
+)
+
+var (
+	hedgeExpvar = expvar.NewMap("rpchedge")
+	hedgesSent  = new(expvar.Int)
+	hedgeWins   = new(expvar.Int)
+)
+
+func init() {
+	hedgeExpvar.Set("sent", hedgesSent)
+	hedgeExpvar.Set("wins", hedgeWins)
+}
+
+// A HedgePolicy configures speculative ("hedged") retries of
+// idempotent calls. If a call made with CallHedged hasn't completed
+// within Delay, an identical request is sent and whichever response
+// arrives first is used.
+//
+// Budget bounds the extra load on the peer: at most Budget hedged
+// requests are sent per call, averaged over the lifetime of the
+// policy. For example, a Budget of 0.1 allows one hedge for every
+// ten calls.
+//
+// A HedgePolicy may be shared by several Clients, in which case
+// they share a budget.
+type HedgePolicy struct {
+	calls  int64 // atomic; must be first for alignment
+	hedges int64 // atomic
+
+	Delay  time.Duration
+	Budget float64
+}
+
+// reserve reports whether a hedged request may be sent,
+// and if so, counts it against the budget.
+func (h *HedgePolicy) reserve() bool {
+	calls := atomic.LoadInt64(&h.calls)
+	for {
+		hedges := atomic.LoadInt64(&h.hedges)
+		if float64(hedges+1) > h.Budget*float64(calls) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&h.hedges, hedges, hedges+1) {
+			return true
+		}
+	}
+}
+
+type hedgeResult struct {
+	body   []byte
+	err    error
+	hedged bool
+}
+
+// CallHedged is like Call, but it sends a second request if the
+// first is slow, according to c.Hedge. It must only be used for
+// idempotent procedures. If c.Hedge is nil, it is equivalent to Call.
+func (c *Client) CallHedged(ctx context.Context, path string, request, response interface{}) error {
+	h := c.Hedge
+	if h == nil || h.Delay <= 0 {
+		return c.Call(ctx, path, request, response)
+	}
+	atomic.AddInt64(&h.calls, 1)
+
+	// Cancel the losing request once we have a response.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	send := func(hedged bool) {
+		r, err := c.CallRaw(ctx, path, request)
+		if err != nil {
+			results <- hedgeResult{err: err, hedged: hedged}
+			return
+		}
+		defer r.Close()
+		b, err := ioutil.ReadAll(r)
+		results <- hedgeResult{body: b, err: errors.Wrap(err), hedged: hedged}
+	}
+	go send(false)
+	pending := 1
+
+	timer := time.NewTimer(h.Delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			if h.reserve() {
+				hedgesSent.Add(1)
+				pending++
+				go send(true)
+			}
+		case res := <-results:
+			pending--
+			if res.err != nil && pending > 0 {
+				// Wait for the other request.
+				continue
+			}
+			if res.err != nil {
+				return res.err
+			}
+			if res.hedged {
+				hedgeWins.Add(1)
+			}
+			if response == nil {
+				return nil
+			}
+			return errors.Wrap(json.NewDecoder(bytes.NewReader(res.body)).Decode(response))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCallHedged(t *testing.T) {
+	var n int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			// Stall the first request until the client gives up on it.
+			<-req.Context().Done()
+			return
+		}
+		rw.Write([]byte(`"hedged"`))
+	}))
+	defer server.Close()
+
+	client := &Client{
+		BaseURL: server.URL,
+		Hedge:   &HedgePolicy{Delay: 10 * time.Millisecond, Budget: 1},
+	}
+	wins := hedgeWins.Value()
+
+	var got string
+	err := client.CallHedged(context.Background(), "/hedge", nil, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "hedged" {
+		t.Errorf("got=%q want=%q", got, "hedged")
+	}
+	if hedgeWins.Value() != wins+1 {
+		t.Errorf("hedge wins = %d want %d", hedgeWins.Value(), wins+1)
+	}
+}
+
+func TestHedgeBudget(t *testing.T) {
+	h := &HedgePolicy{Budget: 0.5}
+	var allowed int
+	for i := 0; i < 10; i++ {
+		h.calls++
+		if h.reserve() {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("allowed %d hedges want 5", allowed)
+	}
+}
@@ -4,11 +4,16 @@ package rpc
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -21,6 +26,9 @@ const (
 	HeaderBlockchainID = "Blockchain-ID"
 	HeaderCoreID       = "Chain-Core-ID"
 	HeaderTimeout      = "RPC-Timeout"
+	HeaderRequestTime  = "Chain-Request-Time"
+	HeaderNonce        = "Chain-Request-Nonce"
+	HeaderMAC          = "Chain-Request-MAC"
 )
 
 // ErrWrongNetwork is returned when a peer's blockchain ID differs from
@@ -37,6 +45,40 @@ type Client struct {
 	BuildTag     string
 	BlockchainID string
 	CoreID       string
+
+	// Hedge, if set, configures speculative retries
+	// for calls made with CallHedged.
+	Hedge *HedgePolicy
+}
+
+// Nonce returns a random value for the HeaderNonce field.
+// Together with HeaderRequestTime, it identifies a request
+// uniquely, so a receiver that remembers recent nonces can
+// refuse a captured request sent a second time.
+func Nonce() string {
+	var b [16]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// MAC returns the value for the HeaderMAC field: an HMAC-SHA256,
+// keyed by the access token secret, over the request's method,
+// path, body hash, time, and nonce. The basic-auth credentials
+// and the other headers can be copied from a captured request,
+// but without the secret a fresh time and nonce can't be
+// given a valid MAC.
+func MAC(secret, method, path string, body []byte, reqTime, nonce string) string {
+	bodyHash := sha256.Sum256(body)
+	h := hmac.New(sha256.New, []byte(secret))
+	for _, s := range []string{method, path, hex.EncodeToString(bodyHash[:]), reqTime, nonce} {
+		// Length-prefix each field so no two
+		// distinct requests hash the same.
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 func (c Client) userAgent() string {
@@ -49,6 +91,7 @@ func (c Client) userAgent() string {
 type errStatusCode struct {
 	URL        string
 	StatusCode int
+	Code       string // Chain error code, if the node sent one
 }
 
 func (e errStatusCode) Error() string {
@@ -56,6 +99,22 @@ func (e errStatusCode) Error() string {
 		e.URL, e.StatusCode, http.StatusText(e.StatusCode))
 }
 
+// IsNotFound reports whether err is from a call to a
+// path the remote node doesn't serve, such as a procedure
+// added in a later version of Chain Core.
+func IsNotFound(err error) bool {
+	e, ok := errors.Root(err).(errStatusCode)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// ErrorCode returns the Chain error code of the
+// response to the call that returned err, or the
+// empty string if there is none.
+func ErrorCode(err error) string {
+	e, _ := errors.Root(err).(errStatusCode)
+	return e.Code
+}
+
 // Call calls a remote procedure on another node, specified by the path.
 func (c *Client) Call(ctx context.Context, path string, request, response interface{}) error {
 	r, err := c.CallRaw(ctx, path, request)
@@ -78,12 +137,16 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	}
 	u.Path = path
 
-	var bodyReader io.Reader
+	var (
+		bodyReader io.Reader
+		body       []byte
+	)
 	if request != nil {
 		var jsonBody bytes.Buffer
 		if err := json.NewEncoder(&jsonBody).Encode(request); err != nil {
 			return nil, errors.Wrap(err)
 		}
+		body = jsonBody.Bytes()
 		bodyReader = &jsonBody
 	}
 
@@ -92,8 +155,8 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 		return nil, errors.Wrap(err)
 	}
 
+	var username, password string
 	if c.AccessToken != "" {
-		var username, password string
 		toks := strings.SplitN(c.AccessToken, ":", 2)
 		if len(toks) > 0 {
 			username = toks[0]
@@ -111,6 +174,16 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	req.Header.Set(HeaderBlockchainID, c.BlockchainID)
 	req.Header.Set(HeaderCoreID, c.CoreID)
 
+	// Let the receiver reject a replay of this request.
+	// See Nonce.
+	reqTime := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	nonce := Nonce()
+	req.Header.Set(HeaderRequestTime, reqTime)
+	req.Header.Set(HeaderNonce, nonce)
+	if password != "" {
+		req.Header.Set(HeaderMAC, MAC(password, req.Method, u.Path, body, reqTime, nonce))
+	}
+
 	// Propagate our deadline if we have one.
 	deadline, ok := ctx.Deadline()
 	if ok {
@@ -130,10 +203,15 @@ func (c *Client) CallRaw(ctx context.Context, path string, request interface{})
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		resp.Body.Close()
+		defer resp.Body.Close()
+		var errResp struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&errResp) // best effort
 		return nil, errStatusCode{
 			URL:        cleanedURLString(u),
 			StatusCode: resp.StatusCode,
+			Code:       errResp.Code,
 		}
 	}
 	return resp.Body, nil
@@ -3,8 +3,13 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"chain/core/config"
 	"chain/core/txdb"
 	"chain/database/pg/pgtest"
 	"chain/protocol/prottest"
@@ -45,3 +50,47 @@ func TestGetBlock(t *testing.T) {
 		t.Errorf("got=%x, want=%s", block, buf.Bytes())
 	}
 }
+
+func TestStreamBlockHeaders(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	store := txdb.NewStore(db)
+	chain := prottest.NewChainWithStorage(t, store)
+	h := &Handler{Chain: chain, Store: store, Config: &config.Config{}}
+	prottest.MakeBlock(t, chain, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest("POST", "/rpc/stream-block-headers", strings.NewReader("1"))
+	req = req.WithContext(ctx)
+	pr, pw := io.Pipe()
+	rec := &pipeRecorder{ResponseRecorder: httptest.NewRecorder(), w: pw}
+	go func() {
+		h.streamBlockHeadersRPC(rec, req)
+		pw.Close()
+	}()
+
+	dec := json.NewDecoder(pr)
+	for height := uint64(1); height <= 2; height++ {
+		var resp blockHeaderResp
+		err := dec.Decode(&resp)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		b, err := store.GetBlock(ctx, height)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if resp.Height != height || resp.Hash != b.Hash() {
+			t.Errorf("header %d = %d %s want %d %s", height, resp.Height, resp.Hash, height, b.Hash())
+		}
+	}
+}
+
+// pipeRecorder sends the response body to a pipe,
+// so a test can read it while the handler runs.
+type pipeRecorder struct {
+	*httptest.ResponseRecorder
+	w io.Writer
+}
+
+func (r *pipeRecorder) Write(p []byte) (int, error) { return r.w.Write(p) }
@@ -33,7 +33,9 @@ SET search_path = public, pg_catalog;
 
 CREATE TYPE access_token_type AS ENUM (
     'client',
-    'network'
+    'network',
+    'explorer',
+    'admin'
 );
 
 
@@ -154,7 +156,12 @@ CREATE TABLE access_tokens (
     sort_id text DEFAULT next_chain_id('at'::text),
     type access_token_type NOT NULL,
     hashed_secret bytea NOT NULL,
-    created timestamp with time zone DEFAULT now() NOT NULL
+    created timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone,
+    previous_hashed_secret bytea,
+    previous_expires_at timestamp with time zone,
+    namespace text
 );
 
 
@@ -178,7 +185,108 @@ CREATE TABLE account_control_programs (
     signer_id text NOT NULL,
     key_index bigint NOT NULL,
     control_program bytea NOT NULL,
-    change boolean NOT NULL
+    change boolean NOT NULL,
+    epoch bigint,
+    expires_at timestamp with time zone,
+    key_generation integer DEFAULT 0 NOT NULL,
+    first_used_tx bytea,
+    first_used_block_height bigint
+);
+
+
+--
+-- Name: account_freezes; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_freezes (
+    id bigint NOT NULL,
+    account_id text NOT NULL,
+    reason text NOT NULL,
+    frozen_by text NOT NULL,
+    frozen_at timestamp with time zone DEFAULT now() NOT NULL,
+    unfrozen_by text,
+    unfrozen_at timestamp with time zone
+);
+
+
+--
+-- Name: account_freezes_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE account_freezes_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: account_freezes_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE account_freezes_id_seq OWNED BY account_freezes.id;
+
+
+--
+-- Name: account_key_generations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_key_generations (
+    account_id text NOT NULL,
+    generation integer NOT NULL,
+    xpubs bytea[] NOT NULL,
+    quorum integer NOT NULL,
+    client_token text,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: account_quorum_tiers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_quorum_tiers (
+    account_id text NOT NULL,
+    amount bigint NOT NULL,
+    quorum integer NOT NULL
+);
+
+
+--
+-- Name: account_spending_limits; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_spending_limits (
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    max_amount bigint NOT NULL,
+    window_secs bigint NOT NULL
+);
+
+
+--
+-- Name: account_spends; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_spends (
+    tx_hash bytea NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL,
+    spent_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: account_upgrades; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE account_upgrades (
+    account_id text NOT NULL,
+    successor_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    completed_at timestamp with time zone
 );
 
 
@@ -194,7 +302,9 @@ CREATE TABLE account_utxos (
     account_id text NOT NULL,
     control_program_index bigint NOT NULL,
     control_program bytea NOT NULL,
-    confirmed_in bigint NOT NULL
+    confirmed_in bigint NOT NULL,
+    control_program_epoch bigint,
+    control_program_generation integer DEFAULT 0 NOT NULL
 );
 
 
@@ -205,7 +315,10 @@ CREATE TABLE account_utxos (
 CREATE TABLE accounts (
     account_id text NOT NULL,
     tags jsonb,
-    alias text
+    alias text,
+    archived_at timestamp with time zone,
+    epoch_period bigint DEFAULT 0 NOT NULL,
+    parent_id text
 );
 
 
@@ -256,6 +369,29 @@ CREATE TABLE annotated_txs (
 );
 
 
+--
+-- Name: asset_definition_documents; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE asset_definition_documents (
+    hash bytea NOT NULL,
+    url text NOT NULL,
+    document bytea NOT NULL,
+    fetched_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: asset_rotations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE asset_rotations (
+    asset_id bytea NOT NULL,
+    successor_id bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: asset_tags; Type: TABLE; Schema: public; Owner: -
 --
@@ -275,13 +411,17 @@ CREATE TABLE assets (
     created_at timestamp with time zone DEFAULT now() NOT NULL,
     definition_mutable boolean DEFAULT false NOT NULL,
     sort_id text DEFAULT next_chain_id('asset'::text) NOT NULL,
-    issuance_program bytea NOT NULL,
+    issuance_program bytea,
     client_token text,
     initial_block_hash bytea NOT NULL,
     signer_id text,
     definition jsonb,
     alias text,
-    first_block_height bigint
+    first_block_height bigint,
+    archived_at timestamp with time zone,
+    max_issuance bigint,
+    issued bigint DEFAULT 0 NOT NULL,
+    issued_height bigint DEFAULT 0 NOT NULL
 );
 
 
@@ -297,6 +437,72 @@ CREATE SEQUENCE assets_key_index_seq
     CACHE 1;
 
 
+--
+-- Name: balance_alert_rules; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE balance_alert_rules (
+    id text DEFAULT next_chain_id('bar'::text) NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    minimum bigint NOT NULL,
+    url text,
+    created_height bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: balance_alerts; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE balance_alerts (
+    id bigint NOT NULL,
+    rule_id text NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    minimum bigint NOT NULL,
+    balance bigint NOT NULL,
+    block_height bigint NOT NULL,
+    "timestamp" timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: balance_alerts_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE balance_alerts_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: balance_alerts_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE balance_alerts_id_seq OWNED BY balance_alerts.id;
+
+
+--
+-- Name: balance_webhooks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE balance_webhooks (
+    id text DEFAULT next_chain_id('bwh'::text) NOT NULL,
+    account_id text,
+    asset_id bytea,
+    trigger text NOT NULL,
+    threshold bigint DEFAULT 0 NOT NULL,
+    url text NOT NULL,
+    last_block_height bigint DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: block_processors; Type: TABLE; Schema: public; Owner: -
 --
@@ -319,6 +525,33 @@ CREATE TABLE blocks (
 );
 
 
+--
+-- Name: build_quota_usage; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE build_quota_usage (
+    subject_type text NOT NULL,
+    subject_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    day date NOT NULL,
+    used bigint NOT NULL,
+    quota bigint NOT NULL,
+    CONSTRAINT build_quota_usage_within_quota CHECK ((used <= quota))
+);
+
+
+--
+-- Name: build_quotas; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE build_quotas (
+    subject_type text NOT NULL,
+    subject_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    quota bigint NOT NULL
+);
+
+
 --
 -- Name: chain_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
@@ -351,6 +584,67 @@ CREATE TABLE config (
 );
 
 
+--
+-- Name: counterparty_labels; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE counterparty_labels (
+    program bytea NOT NULL,
+    label text NOT NULL
+);
+
+
+--
+-- Name: duplicate_payments; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE duplicate_payments (
+    id bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    output_index integer NOT NULL,
+    original_tx_hash bytea NOT NULL,
+    original_output_index integer NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL,
+    reasons text[] NOT NULL,
+    block_height bigint NOT NULL,
+    "timestamp" timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: duplicate_payments_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE duplicate_payments_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: duplicate_payments_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE duplicate_payments_id_seq OWNED BY duplicate_payments.id;
+
+
+--
+-- Name: generator_ordering; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE generator_ordering (
+    singleton boolean DEFAULT true NOT NULL,
+    policy text NOT NULL,
+    tiers jsonb NOT NULL,
+    fee_asset_id bytea,
+    CONSTRAINT generator_ordering_singleton CHECK (singleton)
+);
+
+
 --
 -- Name: generator_pending_block; Type: TABLE; Schema: public; Owner: -
 --
@@ -363,34 +657,32 @@ CREATE TABLE generator_pending_block (
 
 
 --
--- Name: leader; Type: TABLE; Schema: public; Owner: -
+-- Name: generator_pending_supply_caps; Type: TABLE; Schema: public; Owner: -
 --
 
-CREATE TABLE leader (
-    singleton boolean DEFAULT true NOT NULL,
-    leader_key text NOT NULL,
-    expiry timestamp with time zone DEFAULT '1970-01-01 00:00:00-08'::timestamp with time zone NOT NULL,
-    address text NOT NULL,
-    CONSTRAINT leader_singleton CHECK (singleton)
+CREATE TABLE generator_pending_supply_caps (
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL
 );
 
 
 --
--- Name: migrations; Type: TABLE; Schema: public; Owner: -
+-- Name: generator_pending_txs; Type: TABLE; Schema: public; Owner: -
 --
 
-CREATE TABLE migrations (
-    filename text NOT NULL,
-    hash text NOT NULL,
-    applied_at timestamp with time zone DEFAULT now() NOT NULL
+CREATE TABLE generator_pending_txs (
+    id bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    data bytea NOT NULL,
+    submitter text DEFAULT ''::text NOT NULL
 );
 
 
 --
--- Name: mockhsm_sort_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+-- Name: generator_pending_txs_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
 
-CREATE SEQUENCE mockhsm_sort_id_seq
+CREATE SEQUENCE generator_pending_txs_id_seq
     START WITH 1
     INCREMENT BY 1
     NO MINVALUE
@@ -399,23 +691,34 @@ CREATE SEQUENCE mockhsm_sort_id_seq
 
 
 --
--- Name: mockhsm; Type: TABLE; Schema: public; Owner: -
+-- Name: generator_pending_txs_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
 --
 
-CREATE TABLE mockhsm (
-    pub bytea NOT NULL,
-    prv bytea NOT NULL,
-    alias text,
-    sort_id bigint DEFAULT nextval('mockhsm_sort_id_seq'::regclass) NOT NULL,
-    key_type text DEFAULT 'chain_kd'::text NOT NULL
+ALTER SEQUENCE generator_pending_txs_id_seq OWNED BY generator_pending_txs.id;
+
+
+--
+-- Name: generator_rejections; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE generator_rejections (
+    seq bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    reason text NOT NULL,
+    detail text NOT NULL,
+    input_index integer,
+    outpoint_tx_hash bytea,
+    outpoint_index bigint,
+    block_height bigint NOT NULL,
+    rejected_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
 --
--- Name: pool_tx_sort_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+-- Name: generator_rejections_seq_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
 
-CREATE SEQUENCE pool_tx_sort_id_seq
+CREATE SEQUENCE generator_rejections_seq_seq
     START WITH 1
     INCREMENT BY 1
     NO MINVALUE
@@ -424,20 +727,30 @@ CREATE SEQUENCE pool_tx_sort_id_seq
 
 
 --
--- Name: query_blocks; Type: TABLE; Schema: public; Owner: -
+-- Name: generator_rejections_seq_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
 --
 
-CREATE TABLE query_blocks (
-    height bigint NOT NULL,
-    "timestamp" bigint NOT NULL
+ALTER SEQUENCE generator_rejections_seq_seq OWNED BY generator_rejections.seq;
+
+
+--
+-- Name: issuance_approval_events; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE issuance_approval_events (
+    id bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    type text NOT NULL,
+    token_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
 --
--- Name: reservation_seq; Type: SEQUENCE; Schema: public; Owner: -
+-- Name: issuance_approval_events_id_seq; Type: SEQUENCE; Schema: public; Owner: -
 --
 
-CREATE SEQUENCE reservation_seq
+CREATE SEQUENCE issuance_approval_events_id_seq
     START WITH 1
     INCREMENT BY 1
     NO MINVALUE
@@ -446,231 +759,914 @@ CREATE SEQUENCE reservation_seq
 
 
 --
--- Name: signed_blocks; Type: TABLE; Schema: public; Owner: -
+-- Name: issuance_approval_events_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
 --
 
-CREATE TABLE signed_blocks (
-    block_height bigint NOT NULL,
-    block_hash bytea NOT NULL
+ALTER SEQUENCE issuance_approval_events_id_seq OWNED BY issuance_approval_events.id;
+
+
+--
+-- Name: issuance_approval_policies; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE issuance_approval_policies (
+    asset_id bytea NOT NULL,
+    threshold integer NOT NULL,
+    approvers text[] NOT NULL
 );
 
 
 --
--- Name: signers; Type: TABLE; Schema: public; Owner: -
+-- Name: issuance_approval_requests; Type: TABLE; Schema: public; Owner: -
 --
 
-CREATE TABLE signers (
-    id text NOT NULL,
-    type text NOT NULL,
-    key_index bigint NOT NULL,
-    quorum integer NOT NULL,
-    client_token text,
-    xpubs bytea[] NOT NULL
+CREATE TABLE issuance_approval_requests (
+    tx_hash bytea NOT NULL,
+    raw_tx bytea NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
 );
 
 
 --
--- Name: signers_key_index_seq; Type: SEQUENCE; Schema: public; Owner: -
+-- Name: issuance_approvals; Type: TABLE; Schema: public; Owner: -
 --
 
-CREATE SEQUENCE signers_key_index_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1;
+CREATE TABLE issuance_approvals (
+    tx_hash bytea NOT NULL,
+    token_id text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: leader; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE leader (
+    singleton boolean DEFAULT true NOT NULL,
+    leader_key text NOT NULL,
+    expiry timestamp with time zone DEFAULT '1970-01-01 00:00:00-08'::timestamp with time zone NOT NULL,
+    address text NOT NULL,
+    CONSTRAINT leader_singleton CHECK (singleton)
+);
+
+
+--
+-- Name: migrations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE migrations (
+    filename text NOT NULL,
+    hash text NOT NULL,
+    applied_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: mockhsm_sort_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE mockhsm_sort_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: mockhsm; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE mockhsm (
+    pub bytea NOT NULL,
+    prv bytea NOT NULL,
+    alias text,
+    sort_id bigint DEFAULT nextval('mockhsm_sort_id_seq'::regclass) NOT NULL,
+    key_type text DEFAULT 'chain_kd'::text NOT NULL
+);
+
+
+--
+-- Name: pending_outputs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE pending_outputs (
+    tx_hash bytea NOT NULL,
+    output_index integer NOT NULL,
+    data jsonb NOT NULL
+);
+
+
+--
+-- Name: pending_spends; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE pending_spends (
+    tx_hash bytea NOT NULL,
+    spent_tx_hash bytea NOT NULL,
+    spent_output_index integer NOT NULL
+);
+
+
+--
+-- Name: pending_txs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE pending_txs (
+    tx_hash bytea NOT NULL,
+    data jsonb NOT NULL,
+    max_time_ms bigint NOT NULL,
+    submitted_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: pool_tx_sort_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE pool_tx_sort_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: program_template_instances; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE program_template_instances (
+    control_program bytea NOT NULL,
+    template_name text NOT NULL
+);
+
+
+--
+-- Name: program_templates; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE program_templates (
+    name text NOT NULL,
+    program text NOT NULL,
+    params jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: query_blocks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE query_blocks (
+    height bigint NOT NULL,
+    "timestamp" bigint NOT NULL,
+    run_cost bigint DEFAULT 0 NOT NULL,
+    sig_checks bigint DEFAULT 0 NOT NULL,
+    bytes bigint DEFAULT 0 NOT NULL
+);
+
+
+--
+-- Name: relay_key; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE relay_key (
+    singleton boolean DEFAULT true NOT NULL,
+    private_key bytea NOT NULL,
+    CONSTRAINT relay_key_singleton CHECK (singleton)
+);
+
+
+--
+-- Name: relayed_messages; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE relayed_messages (
+    id bigint NOT NULL,
+    account_id text NOT NULL,
+    control_program bytea NOT NULL,
+    message bytea NOT NULL,
+    received_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: relayed_messages_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE relayed_messages_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: relayed_messages_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE relayed_messages_id_seq OWNED BY relayed_messages.id;
+
+
+--
+-- Name: reservation_conflicts; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE reservation_conflicts (
+    id bigint NOT NULL,
+    tx_hash bytea NOT NULL,
+    input_index integer NOT NULL,
+    spent_tx_hash bytea NOT NULL,
+    spent_output_index integer NOT NULL,
+    account_id text NOT NULL,
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL,
+    block_height bigint NOT NULL,
+    "timestamp" timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: reservation_conflicts_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE reservation_conflicts_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: reservation_conflicts_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE reservation_conflicts_id_seq OWNED BY reservation_conflicts.id;
+
+
+--
+-- Name: reservation_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE reservation_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: rpc_nonces; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE rpc_nonces (
+    nonce bytea NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: signed_blocks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signed_blocks (
+    block_height bigint NOT NULL,
+    block_hash bytea NOT NULL
+);
+
+
+--
+-- Name: signer_supply_cap_approvals; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signer_supply_cap_approvals (
+    asset_id bytea NOT NULL,
+    amount bigint NOT NULL
+);
+
+
+--
+-- Name: signers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE signers (
+    id text NOT NULL,
+    type text NOT NULL,
+    key_index bigint NOT NULL,
+    quorum integer NOT NULL,
+    client_token text,
+    xpubs bytea[] NOT NULL,
+    parent_key_indexes bigint[] DEFAULT '{}'::bigint[] NOT NULL
+);
+
+
+--
+-- Name: signers_key_index_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE signers_key_index_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: signers_key_index_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
+
+
+--
+-- Name: snapshots; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE snapshots (
+    height bigint NOT NULL,
+    data bytea NOT NULL
+);
+
+
+--
+-- Name: submit_client_tokens; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE submit_client_tokens (
+    client_token text NOT NULL,
+    "position" integer NOT NULL,
+    tx_hash bytea NOT NULL,
+    response jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    access_token_id text DEFAULT ''::text NOT NULL
+);
+
+
+--
+-- Name: submitted_txs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE submitted_txs (
+    tx_hash bytea NOT NULL,
+    height bigint NOT NULL,
+    submitted_at timestamp without time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: swap_offers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE swap_offers (
+    id text DEFAULT next_chain_id('swp'::text) NOT NULL,
+    tx bytea NOT NULL,
+    spends text[] NOT NULL,
+    offered_asset_id bytea NOT NULL,
+    offered_amount bigint NOT NULL,
+    requested_asset_id bytea NOT NULL,
+    requested_amount bigint NOT NULL,
+    status text DEFAULT 'open'::text NOT NULL,
+    closed_by bytea,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: token_usage; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE token_usage (
+    token_id text NOT NULL,
+    requests bigint NOT NULL,
+    cpu_time bigint NOT NULL,
+    db_time bigint NOT NULL,
+    bytes_returned bigint NOT NULL,
+    created_at timestamp with time zone NOT NULL
+);
+
+
+--
+-- Name: tx_failures; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE tx_failures (
+    tx_hash bytea NOT NULL,
+    input_index integer NOT NULL,
+    error text NOT NULL,
+    program bytea NOT NULL,
+    pc bigint NOT NULL,
+    stack bytea[] NOT NULL,
+    failed_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: tx_submissions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE tx_submissions (
+    id text DEFAULT next_chain_id('sub'::text) NOT NULL,
+    tx_hash bytea NOT NULL,
+    status text NOT NULL,
+    block_height bigint,
+    error jsonb,
+    submitted_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL,
+    tx bytea
+);
+
+
+--
+-- Name: txfeed_sinks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE txfeed_sinks (
+    feed_id text NOT NULL,
+    type text NOT NULL,
+    queue_url text NOT NULL,
+    dead_letter_queue_url text,
+    encoding text NOT NULL,
+    ordering_key text,
+    last_block_height bigint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: txfeeds; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE txfeeds (
+    id text DEFAULT next_chain_id('cur'::text) NOT NULL,
+    alias text,
+    filter text,
+    after text,
+    client_token text
+);
+
+
+--
+-- Name: watch_descriptors; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE watch_descriptors (
+    id text DEFAULT next_chain_id('wat'::text) NOT NULL,
+    alias text,
+    xpubs bytea[] NOT NULL,
+    quorum integer NOT NULL,
+    derivation_path text NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: watch_programs; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE watch_programs (
+    descriptor_id text NOT NULL,
+    key_index bigint NOT NULL,
+    control_program bytea NOT NULL,
+    used boolean DEFAULT false NOT NULL
+);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_freezes ALTER COLUMN id SET DEFAULT nextval('account_freezes_id_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY balance_alerts ALTER COLUMN id SET DEFAULT nextval('balance_alerts_id_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY duplicate_payments ALTER COLUMN id SET DEFAULT nextval('duplicate_payments_id_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY generator_pending_txs ALTER COLUMN id SET DEFAULT nextval('generator_pending_txs_id_seq'::regclass);
+
+
+--
+-- Name: seq; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY generator_rejections ALTER COLUMN seq SET DEFAULT nextval('generator_rejections_seq_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY issuance_approval_events ALTER COLUMN id SET DEFAULT nextval('issuance_approval_events_id_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY relayed_messages ALTER COLUMN id SET DEFAULT nextval('relayed_messages_id_seq'::regclass);
+
+
+--
+-- Name: id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reservation_conflicts ALTER COLUMN id SET DEFAULT nextval('reservation_conflicts_id_seq'::regclass);
+
+
+--
+-- Name: key_index; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signers ALTER COLUMN key_index SET DEFAULT nextval('signers_key_index_seq'::regclass);
+
+
+--
+-- Name: access_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY access_tokens
+    ADD CONSTRAINT access_tokens_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: account_control_programs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_control_programs
+    ADD CONSTRAINT account_control_programs_pkey PRIMARY KEY (control_program);
+
+
+--
+-- Name: account_freezes_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_freezes
+    ADD CONSTRAINT account_freezes_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: account_key_generations_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_key_generations
+    ADD CONSTRAINT account_key_generations_client_token_key UNIQUE (client_token);
+
+
+--
+-- Name: account_key_generations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_key_generations
+    ADD CONSTRAINT account_key_generations_pkey PRIMARY KEY (account_id, generation);
+
+
+--
+-- Name: account_quorum_tiers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_quorum_tiers
+    ADD CONSTRAINT account_quorum_tiers_pkey PRIMARY KEY (account_id, amount);
+
+
+--
+-- Name: account_tags_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY accounts
+    ADD CONSTRAINT account_tags_pkey PRIMARY KEY (account_id);
+
+
+--
+-- Name: account_spending_limits_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_spending_limits
+    ADD CONSTRAINT account_spending_limits_pkey PRIMARY KEY (account_id, asset_id);
+
+
+--
+-- Name: account_spends_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_spends
+    ADD CONSTRAINT account_spends_pkey PRIMARY KEY (tx_hash, account_id, asset_id);
+
+
+--
+-- Name: account_upgrades_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_upgrades
+    ADD CONSTRAINT account_upgrades_pkey PRIMARY KEY (account_id);
+
+
+--
+-- Name: account_utxos_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY account_utxos
+    ADD CONSTRAINT account_utxos_pkey PRIMARY KEY (tx_hash, index);
+
+
+--
+-- Name: accounts_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY accounts
+    ADD CONSTRAINT accounts_alias_key UNIQUE (alias);
+
+
+--
+-- Name: annotated_accounts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY annotated_accounts
+    ADD CONSTRAINT annotated_accounts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: annotated_assets_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY annotated_assets
+    ADD CONSTRAINT annotated_assets_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: annotated_outputs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY annotated_outputs
+    ADD CONSTRAINT annotated_outputs_pkey PRIMARY KEY (block_height, tx_pos, output_index);
+
+
+--
+-- Name: annotated_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY annotated_txs
+    ADD CONSTRAINT annotated_txs_pkey PRIMARY KEY (block_height, tx_pos);
+
+
+--
+-- Name: asset_definition_documents_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_definition_documents
+    ADD CONSTRAINT asset_definition_documents_pkey PRIMARY KEY (hash);
+
+
+--
+-- Name: asset_rotations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_rotations
+    ADD CONSTRAINT asset_rotations_pkey PRIMARY KEY (asset_id);
+
+
+--
+-- Name: asset_rotations_successor_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_rotations
+    ADD CONSTRAINT asset_rotations_successor_id_key UNIQUE (successor_id);
+
+
+--
+-- Name: asset_tags_asset_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY asset_tags
+    ADD CONSTRAINT asset_tags_asset_id_key UNIQUE (asset_id);
+
+
+--
+-- Name: assets_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY assets
+    ADD CONSTRAINT assets_alias_key UNIQUE (alias);
+
+
+--
+-- Name: assets_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY assets
+    ADD CONSTRAINT assets_client_token_key UNIQUE (client_token);
+
+
+--
+-- Name: assets_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY assets
+    ADD CONSTRAINT assets_pkey PRIMARY KEY (id);
 
 
 --
--- Name: signers_key_index_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+-- Name: balance_alert_rules_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER SEQUENCE signers_key_index_seq OWNED BY signers.key_index;
+ALTER TABLE ONLY balance_alert_rules
+    ADD CONSTRAINT balance_alert_rules_pkey PRIMARY KEY (id);
 
 
 --
--- Name: snapshots; Type: TABLE; Schema: public; Owner: -
+-- Name: balance_alerts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE TABLE snapshots (
-    height bigint NOT NULL,
-    data bytea NOT NULL
-);
+ALTER TABLE ONLY balance_alerts
+    ADD CONSTRAINT balance_alerts_pkey PRIMARY KEY (id);
 
 
 --
--- Name: submitted_txs; Type: TABLE; Schema: public; Owner: -
+-- Name: balance_alerts_rule_id_block_height_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE TABLE submitted_txs (
-    tx_hash bytea NOT NULL,
-    height bigint NOT NULL,
-    submitted_at timestamp without time zone DEFAULT now() NOT NULL
-);
+ALTER TABLE ONLY balance_alerts
+    ADD CONSTRAINT balance_alerts_rule_id_block_height_key UNIQUE (rule_id, block_height);
 
 
 --
--- Name: txfeeds; Type: TABLE; Schema: public; Owner: -
+-- Name: balance_webhooks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-CREATE TABLE txfeeds (
-    id text DEFAULT next_chain_id('cur'::text) NOT NULL,
-    alias text,
-    filter text,
-    after text,
-    client_token text
-);
+ALTER TABLE ONLY balance_webhooks
+    ADD CONSTRAINT balance_webhooks_pkey PRIMARY KEY (id);
 
 
 --
--- Name: key_index; Type: DEFAULT; Schema: public; Owner: -
+-- Name: block_processors_name_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY signers ALTER COLUMN key_index SET DEFAULT nextval('signers_key_index_seq'::regclass);
+ALTER TABLE ONLY block_processors
+    ADD CONSTRAINT block_processors_name_key UNIQUE (name);
 
 
 --
--- Name: access_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: blocks_height_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY access_tokens
-    ADD CONSTRAINT access_tokens_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY blocks
+    ADD CONSTRAINT blocks_height_key UNIQUE (height);
 
 
 --
--- Name: account_control_programs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY account_control_programs
-    ADD CONSTRAINT account_control_programs_pkey PRIMARY KEY (control_program);
+ALTER TABLE ONLY blocks
+    ADD CONSTRAINT blocks_pkey PRIMARY KEY (block_hash);
 
 
 --
--- Name: account_tags_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: build_quota_usage_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY accounts
-    ADD CONSTRAINT account_tags_pkey PRIMARY KEY (account_id);
+ALTER TABLE ONLY build_quota_usage
+    ADD CONSTRAINT build_quota_usage_pkey PRIMARY KEY (subject_type, subject_id, asset_id, day);
 
 
 --
--- Name: account_utxos_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: build_quotas_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY account_utxos
-    ADD CONSTRAINT account_utxos_pkey PRIMARY KEY (tx_hash, index);
+ALTER TABLE ONLY build_quotas
+    ADD CONSTRAINT build_quotas_pkey PRIMARY KEY (subject_type, subject_id, asset_id);
 
 
 --
--- Name: accounts_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: config_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY accounts
-    ADD CONSTRAINT accounts_alias_key UNIQUE (alias);
+ALTER TABLE ONLY config
+    ADD CONSTRAINT config_pkey PRIMARY KEY (singleton);
 
 
 --
--- Name: annotated_accounts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: counterparty_labels_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY annotated_accounts
-    ADD CONSTRAINT annotated_accounts_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY counterparty_labels
+    ADD CONSTRAINT counterparty_labels_pkey PRIMARY KEY (program);
 
 
 --
--- Name: annotated_assets_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: duplicate_payments_outpoints_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY annotated_assets
-    ADD CONSTRAINT annotated_assets_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY duplicate_payments
+    ADD CONSTRAINT duplicate_payments_outpoints_key UNIQUE (tx_hash, output_index, original_tx_hash, original_output_index);
 
 
 --
--- Name: annotated_outputs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: duplicate_payments_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY annotated_outputs
-    ADD CONSTRAINT annotated_outputs_pkey PRIMARY KEY (block_height, tx_pos, output_index);
+ALTER TABLE ONLY duplicate_payments
+    ADD CONSTRAINT duplicate_payments_pkey PRIMARY KEY (id);
 
 
 --
--- Name: annotated_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_ordering_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY annotated_txs
-    ADD CONSTRAINT annotated_txs_pkey PRIMARY KEY (block_height, tx_pos);
+ALTER TABLE ONLY generator_ordering
+    ADD CONSTRAINT generator_ordering_pkey PRIMARY KEY (singleton);
 
 
 --
--- Name: asset_tags_asset_id_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_pending_block_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY asset_tags
-    ADD CONSTRAINT asset_tags_asset_id_key UNIQUE (asset_id);
+ALTER TABLE ONLY generator_pending_block
+    ADD CONSTRAINT generator_pending_block_pkey PRIMARY KEY (singleton);
 
 
 --
--- Name: assets_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_pending_supply_caps_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY assets
-    ADD CONSTRAINT assets_alias_key UNIQUE (alias);
+ALTER TABLE ONLY generator_pending_supply_caps
+    ADD CONSTRAINT generator_pending_supply_caps_pkey PRIMARY KEY (asset_id);
 
 
 --
--- Name: assets_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_pending_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY assets
-    ADD CONSTRAINT assets_client_token_key UNIQUE (client_token);
+ALTER TABLE ONLY generator_pending_txs
+    ADD CONSTRAINT generator_pending_txs_pkey PRIMARY KEY (id);
 
 
 --
--- Name: assets_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_pending_txs_tx_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY assets
-    ADD CONSTRAINT assets_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY generator_pending_txs
+    ADD CONSTRAINT generator_pending_txs_tx_hash_key UNIQUE (tx_hash);
 
 
 --
--- Name: block_processors_name_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: generator_rejections_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY block_processors
-    ADD CONSTRAINT block_processors_name_key UNIQUE (name);
+ALTER TABLE ONLY generator_rejections
+    ADD CONSTRAINT generator_rejections_pkey PRIMARY KEY (tx_hash);
 
 
 --
--- Name: blocks_height_key; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: issuance_approval_events_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY blocks
-    ADD CONSTRAINT blocks_height_key UNIQUE (height);
+ALTER TABLE ONLY issuance_approval_events
+    ADD CONSTRAINT issuance_approval_events_pkey PRIMARY KEY (id);
 
 
 --
--- Name: blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: issuance_approval_policies_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY blocks
-    ADD CONSTRAINT blocks_pkey PRIMARY KEY (block_hash);
+ALTER TABLE ONLY issuance_approval_policies
+    ADD CONSTRAINT issuance_approval_policies_pkey PRIMARY KEY (asset_id);
 
 
 --
--- Name: config_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: issuance_approval_requests_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY config
-    ADD CONSTRAINT config_pkey PRIMARY KEY (singleton);
+ALTER TABLE ONLY issuance_approval_requests
+    ADD CONSTRAINT issuance_approval_requests_pkey PRIMARY KEY (tx_hash);
 
 
 --
--- Name: generator_pending_block_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+-- Name: issuance_approvals_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
 
-ALTER TABLE ONLY generator_pending_block
-    ADD CONSTRAINT generator_pending_block_pkey PRIMARY KEY (singleton);
+ALTER TABLE ONLY issuance_approvals
+    ADD CONSTRAINT issuance_approvals_pkey PRIMARY KEY (tx_hash, token_id);
 
 
 --
@@ -705,6 +1701,38 @@ ALTER TABLE ONLY mockhsm
     ADD CONSTRAINT mockhsm_pkey PRIMARY KEY (pub);
 
 
+--
+-- Name: pending_outputs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY pending_outputs
+    ADD CONSTRAINT pending_outputs_pkey PRIMARY KEY (tx_hash, output_index);
+
+
+--
+-- Name: pending_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY pending_txs
+    ADD CONSTRAINT pending_txs_pkey PRIMARY KEY (tx_hash);
+
+
+--
+-- Name: program_template_instances_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY program_template_instances
+    ADD CONSTRAINT program_template_instances_pkey PRIMARY KEY (control_program);
+
+
+--
+-- Name: program_templates_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY program_templates
+    ADD CONSTRAINT program_templates_pkey PRIMARY KEY (name);
+
+
 --
 -- Name: query_blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -713,6 +1741,46 @@ ALTER TABLE ONLY query_blocks
     ADD CONSTRAINT query_blocks_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: relay_key_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY relay_key
+    ADD CONSTRAINT relay_key_pkey PRIMARY KEY (singleton);
+
+
+--
+-- Name: relayed_messages_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY relayed_messages
+    ADD CONSTRAINT relayed_messages_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reservation_conflicts_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reservation_conflicts
+    ADD CONSTRAINT reservation_conflicts_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: reservation_conflicts_spent_output_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY reservation_conflicts
+    ADD CONSTRAINT reservation_conflicts_spent_output_key UNIQUE (spent_tx_hash, spent_output_index);
+
+
+--
+-- Name: rpc_nonces_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY rpc_nonces
+    ADD CONSTRAINT rpc_nonces_pkey PRIMARY KEY (nonce);
+
+
 --
 -- Name: signers_client_token_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -721,6 +1789,14 @@ ALTER TABLE ONLY signers
     ADD CONSTRAINT signers_client_token_key UNIQUE (client_token);
 
 
+--
+-- Name: signer_supply_cap_approvals_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY signer_supply_cap_approvals
+    ADD CONSTRAINT signer_supply_cap_approvals_pkey PRIMARY KEY (asset_id);
+
+
 --
 -- Name: signers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -745,6 +1821,14 @@ ALTER TABLE ONLY snapshots
     ADD CONSTRAINT state_trees_pkey PRIMARY KEY (height);
 
 
+--
+-- Name: submit_client_tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY submit_client_tokens
+    ADD CONSTRAINT submit_client_tokens_pkey PRIMARY KEY (access_token_id, client_token, "position");
+
+
 --
 -- Name: submitted_txs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -753,6 +1837,46 @@ ALTER TABLE ONLY submitted_txs
     ADD CONSTRAINT submitted_txs_pkey PRIMARY KEY (tx_hash);
 
 
+--
+-- Name: swap_offers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY swap_offers
+    ADD CONSTRAINT swap_offers_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: token_usage_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY token_usage
+    ADD CONSTRAINT token_usage_pkey PRIMARY KEY (token_id);
+
+
+--
+-- Name: tx_failures_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY tx_failures
+    ADD CONSTRAINT tx_failures_pkey PRIMARY KEY (tx_hash);
+
+
+--
+-- Name: tx_submissions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY tx_submissions
+    ADD CONSTRAINT tx_submissions_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: txfeed_sinks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY txfeed_sinks
+    ADD CONSTRAINT txfeed_sinks_pkey PRIMARY KEY (feed_id);
+
+
 --
 -- Name: txfeeds_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -777,6 +1901,51 @@ ALTER TABLE ONLY txfeeds
     ADD CONSTRAINT txfeeds_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: watch_descriptors_alias_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY watch_descriptors
+    ADD CONSTRAINT watch_descriptors_alias_key UNIQUE (alias);
+
+
+--
+-- Name: watch_descriptors_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY watch_descriptors
+    ADD CONSTRAINT watch_descriptors_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: watch_programs_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY watch_programs
+    ADD CONSTRAINT watch_programs_pkey PRIMARY KEY (descriptor_id, key_index);
+
+
+--
+-- Name: account_control_programs_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_control_programs_expires_at_idx ON account_control_programs USING btree (expires_at) WHERE (expires_at IS NOT NULL);
+
+
+--
+-- Name: account_freezes_account_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX account_freezes_account_id_idx ON account_freezes USING btree (account_id) WHERE (unfrozen_at IS NULL);
+
+
+--
+-- Name: account_spends_account_id_asset_id_spent_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX account_spends_account_id_asset_id_spent_at_idx ON account_spends USING btree (account_id, asset_id, spent_at);
+
+
 --
 -- Name: account_utxos_asset_id_account_id_confirmed_in_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -840,6 +2009,27 @@ CREATE INDEX annotated_txs_data_idx ON annotated_txs USING gin (data jsonb_path_
 CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 
 
+--
+-- Name: issuance_approval_events_tx_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX issuance_approval_events_tx_hash_idx ON issuance_approval_events USING btree (tx_hash);
+
+
+--
+-- Name: pending_spends_spent_tx_hash_spent_output_index_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX pending_spends_spent_tx_hash_spent_output_index_idx ON pending_spends USING btree (spent_tx_hash, spent_output_index);
+
+
+--
+-- Name: pending_spends_tx_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX pending_spends_tx_hash_idx ON pending_spends USING btree (tx_hash);
+
+
 --
 -- Name: query_blocks_timestamp_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -847,6 +2037,13 @@ CREATE INDEX assets_sort_id ON assets USING btree (sort_id);
 CREATE INDEX query_blocks_timestamp_idx ON query_blocks USING btree ("timestamp");
 
 
+--
+-- Name: rpc_nonces_expires_at_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX rpc_nonces_expires_at_idx ON rpc_nonces USING btree (expires_at);
+
+
 --
 -- Name: signed_blocks_block_height_idx; Type: INDEX; Schema: public; Owner: -
 --
@@ -861,6 +2058,52 @@ CREATE UNIQUE INDEX signed_blocks_block_height_idx ON signed_blocks USING btree
 CREATE INDEX signers_type_id_idx ON signers USING btree (type, id);
 
 
+--
+-- Name: swap_offers_spends_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX swap_offers_spends_idx ON swap_offers USING gin (spends);
+
+
+--
+-- Name: watch_programs_control_program_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX watch_programs_control_program_idx ON watch_programs USING btree (control_program);
+
+
+--
+-- Name: pending_outputs_tx_hash_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY pending_outputs
+    ADD CONSTRAINT pending_outputs_tx_hash_fkey FOREIGN KEY (tx_hash) REFERENCES pending_txs(tx_hash) ON DELETE CASCADE;
+
+
+--
+-- Name: pending_spends_tx_hash_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY pending_spends
+    ADD CONSTRAINT pending_spends_tx_hash_fkey FOREIGN KEY (tx_hash) REFERENCES pending_txs(tx_hash) ON DELETE CASCADE;
+
+
+--
+-- Name: program_template_instances_template_name_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY program_template_instances
+    ADD CONSTRAINT program_template_instances_template_name_fkey FOREIGN KEY (template_name) REFERENCES program_templates(name);
+
+
+--
+-- Name: txfeed_sinks_feed_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY txfeed_sinks
+    ADD CONSTRAINT txfeed_sinks_feed_id_fkey FOREIGN KEY (feed_id) REFERENCES txfeeds(id) ON DELETE CASCADE;
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -879,3 +2122,48 @@ insert into migrations (filename, hash) values ('2016-11-28.0.core.submitted-txs
 insert into migrations (filename, hash) values ('2017-01-05.0.core.rename_block_key.sql', 'ba6a62e498236ec9d2f13238a945829a5cab83f897068fef57a2c152a2e36037');
 insert into migrations (filename, hash) values ('2017-01-10.0.signers.xpubs-type.sql', '4a4d6c736a2bf65e69abbdc87771faa1dc17a0106b2651a6a58af067708d095a');
 insert into migrations (filename, hash) values ('2017-01-11.0.core.hash-bytea.sql', '9f7f15df3479c38f193884a2d3cb7ae8001ed08607f9cc661fd5c420e248688d');
+insert into migrations (filename, hash) values ('2017-01-13.0.core.rpc-nonces.sql', '014f5218dcf0b4cc70a4f533d646c1bd5455290f5ef362c74943770751ae07fd');
+insert into migrations (filename, hash) values ('2017-01-16.0.core.token-usage.sql', 'a806b5bbb1cb08719ab7398f10f90a0547214335314c6e9bfd22a9a1429bb3d4');
+insert into migrations (filename, hash) values ('2017-01-18.0.core.issuance-approvals.sql', '51f1aa93b5018843be8d2b518d846c9a4ccb978558a92a11632a7ff482e4050d');
+insert into migrations (filename, hash) values ('2017-01-19.0.core.counterparty-labels.sql', '56935051a948142031d3f034856c411d3e6ed4f5a4554ff9301f2935620f7611');
+insert into migrations (filename, hash) values ('2017-01-20.0.core.duplicate-payments.sql', 'dee9bafca2ac4e82de96923b99a396b9e6ba01663ad2f3aa5fbd63d209e207af');
+insert into migrations (filename, hash) values ('2017-01-23.0.core.explorer-tokens.sql', 'af1afe0d58c7df652ac7c8bda8c56dafa575cf8609eeec0c2abdb186502c20d2');
+insert into migrations (filename, hash) values ('2017-01-24.0.core.pending-txs.sql', '8bbb565d6bc4577df05f3b65aa3af9d6e92eff19e6a3045e0190167cb4d7f062');
+insert into migrations (filename, hash) values ('2017-01-25.0.core.reservation-conflicts.sql', '088fd7dbc6e762bd9631b4878a653c5e8beb29492afc4a38afe41622d90b3eb3');
+insert into migrations (filename, hash) values ('2017-01-25.1.core.admin-tokens.sql', '5bcf618ed1b0719118e5b8d0ca9c97fccd9c778a9dfa3003cb6b415ab8271adf');
+insert into migrations (filename, hash) values ('2017-01-25.2.core.account-freezes.sql', 'ceb8c94c4261d45713faef90834c058c64ffef9ef57b8066f72faf599de6c43a');
+insert into migrations (filename, hash) values ('2017-01-25.3.core.account-upgrades.sql', 'ecfb9b19c2bad93a9df9457d3a02cf0f374ccb38684d0ffb8c52c8439d3aed68');
+insert into migrations (filename, hash) values ('2017-01-26.0.core.tx-failures.sql', 'd80a3657a0c19ded46f9a8218c1d186858f43e73518b926158fbfe2068429701');
+insert into migrations (filename, hash) values ('2017-01-26.1.core.watch-descriptors.sql', '5ba4b66f484bb7adaee125a9df5be29197b08ec278c17a24de8037f4b520799f');
+insert into migrations (filename, hash) values ('2017-01-26.2.core.asset-definition-documents.sql', 'a3728d5dcb14b7393d79e2bdc9998192df45d17a6938b17ab6fb66deff1db92b');
+insert into migrations (filename, hash) values ('2017-01-26.3.core.message-relay.sql', '7ccf0cd58492e435d3e1bde295055e9753b12cc40e79ed5ce6c99f6557eb6777');
+insert into migrations (filename, hash) values ('2017-01-26.4.core.generator-ordering.sql', '58fb0d9aee36084254c7b6d831f2ed400011126b5b85a7def71cce40589ec96d');
+insert into migrations (filename, hash) values ('2017-01-26.5.core.access-token-expiry.sql', '98e5beb1a0b669d769e0b5118c58a2b82f8401a45b75a94d48b69addb87afce0');
+insert into migrations (filename, hash) values ('2017-01-26.6.core.build-quotas.sql', '57deea2048d24cc7365d1d77c540324dbbe1f24947b33a42620ca56e7829d0d0');
+insert into migrations (filename, hash) values ('2017-01-26.7.core.archive-assets.sql', '69f59b770e52a6cbbe48ca3637e728411136d2170e7c205c9d0da114be653d04');
+insert into migrations (filename, hash) values ('2017-01-26.8.core.import-assets.sql', '6aeec44504b4c2a90130a381b05fac401f0417d72d05c3d81ebffe584c2868d1');
+insert into migrations (filename, hash) values ('2017-01-26.9.core.tx-submissions.sql', 'f2526abddcb7f725dad8be8a7130c514299944530eb0d55f4c7b7fc8bfb3becd');
+insert into migrations (filename, hash) values ('2017-01-27.0.core.asset-max-issuance.sql', '877877a5695b8d38e3381858c92751880e9bdede4a0d9584bc349bc9446d7b59');
+insert into migrations (filename, hash) values ('2017-01-27.1.core.access-token-namespace.sql', '8a06def4417aa0e5bc7e93349ff87a3b248dd4e927c92475c324ccc3fa989192');
+insert into migrations (filename, hash) values ('2017-01-27.2.core.asset-rotations.sql', 'f1cba349047f3bc9a2e807b5310c2bcce86399cbc0d37bf5b06857bd420b4d87');
+insert into migrations (filename, hash) values ('2017-01-27.3.core.block-cost.sql', 'fd28f117bba33aedc9a69e4f8a0c842cb37fb5e349e61374c09805c340d4e37a');
+insert into migrations (filename, hash) values ('2017-01-27.4.core.generator-pending-txs.sql', '55e58bf24f205dfceaaa8572a31baaefeb4951437d904e7833756c99731944fb');
+insert into migrations (filename, hash) values ('2017-01-27.5.core.account-archive.sql', 'b182be0670a5b0ee31ad475c252073276c9fef564891cc580aeb311e187e116f');
+insert into migrations (filename, hash) values ('2017-01-27.6.core.account-epochs.sql', 'dfb8c7dc87cf1c36d02317ddd678bf901008cf18c161153301084f8ac6780987');
+insert into migrations (filename, hash) values ('2017-01-27.7.core.sub-accounts.sql', '7f6c45f801f7b93bc97d4bd0f36158be3107657c53ea2cf60fb62beeeeab5047');
+insert into migrations (filename, hash) values ('2017-01-27.8.core.account-spending-limits.sql', '0e61da5d314fb3eec9636b534b5c5645f0103de9810ab83740a3ada12fa68b48');
+insert into migrations (filename, hash) values ('2017-01-27.9.core.receivers.sql', '2cf9870207ee927990f276a82d40f7169e5af76408748bf1e30022edbfbb6bd9');
+insert into migrations (filename, hash) values ('2017-01-28.0.core.account-key-rotation.sql', 'e2dcadcbb0c72fe7523239a26d48bd255e23dd54ef7b3690792c09c14b386e8d');
+insert into migrations (filename, hash) values ('2017-01-28.1.core.balance-webhooks.sql', '9c3ff7650442a36aee3370f2a3a57c71549afca31a106ee973b325a6966d51d5');
+insert into migrations (filename, hash) values ('2017-01-28.2.core.balance-alerts.sql', '3815739e2df1ce999c28360bb46e7b55bdb6ac939bc0af1f924f8db61ec2537c');
+insert into migrations (filename, hash) values ('2017-01-28.3.core.account-quorum-tiers.sql', '45f557437a9fe9b16c038e8c7f110ec18eb1bb1bfd50b4a57472fdf2c495f370');
+insert into migrations (filename, hash) values ('2017-01-28.4.core.supply-caps.sql', 'c24a1ce795d6462de5a2f207c76484de8e5282d935e1c9182a4d630ccd6ba8b0');
+insert into migrations (filename, hash) values ('2017-01-28.5.core.generator-fee-ordering.sql', '383ae87bdeb447c4143a13be6e8ea6ed30eb4a5c84e058147ec14fef253c8202');
+insert into migrations (filename, hash) values ('2017-01-28.6.core.account-control-program-first-use.sql', '8a3c9e12e251c220a8ac372ea94c8102d5637cc7d75a50d4159f55364217c3f5');
+insert into migrations (filename, hash) values ('2017-01-28.7.core.submit-client-tokens.sql', '825f2ca0b7340bc1915a457c34d8f9ff6bac885605ce009adc53a366c4c0798e');
+insert into migrations (filename, hash) values ('2017-01-28.8.core.program-templates.sql', '3c485b03961ab8ce411b9cf81f9eb1004aea23a6295adc4329cae1c6b456a28d');
+insert into migrations (filename, hash) values ('2017-01-28.9.core.generator-rejections.sql', '8ea1343534a9833ec1abe5c04fa98985ab71ab52e508a830e3e60e90e46bd596');
+insert into migrations (filename, hash) values ('2017-01-29.0.core.swap-offers.sql', 'e504a2295f48f9b3c2de4f15b6bdc681ae6c73944ca89d363a52aa362f70c669');
+insert into migrations (filename, hash) values ('2017-01-29.1.core.submit-client-tokens-access-token.sql', 'b32a97298efd7718b08012b64aa284e04df393c90d26b0b505a027e98c379d5d');
+insert into migrations (filename, hash) values ('2017-01-29.2.core.txfeed-sinks.sql', '08e91fa1642b16d79956524637e575d6ceb3c23dd8f86be7b8351f6489fb823f');
+insert into migrations (filename, hash) values ('2017-01-29.3.core.tx-submissions-tx.sql', '7960ba787c882be7c6d0e6cc2e406aed82f22492732f7279fdd2a57f550bbec7');
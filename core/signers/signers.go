@@ -59,14 +59,31 @@ type Signer struct {
 	XPubs    []chainkd.XPub
 	Quorum   int
 	KeyIndex uint64
+
+	// ParentKeyIndexes holds the key indexes of the signers
+	// a child signer descends from, outermost first.
+	ParentKeyIndexes []uint64
 }
 
-// Path returns the complete path for derived keys
+// childPathFlag marks the elements of a derivation path that
+// lead from a signer to its child, so that a child's keys are
+// never derived along the same path as any of its parent's
+// item keys.
+const childPathFlag = 0x80
+
+// Path returns the complete path for derived keys. The path
+// of a child signer extends the path of its parent.
 func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
 	var path [][]byte
-	signerPath := [9]byte{byte(ks)}
-	binary.LittleEndian.PutUint64(signerPath[1:], s.KeyIndex)
-	path = append(path, signerPath[:])
+	indexes := append(append([]uint64(nil), s.ParentKeyIndexes...), s.KeyIndex)
+	for i, keyIndex := range indexes {
+		signerPath := [9]byte{byte(ks)}
+		if i > 0 {
+			signerPath[0] |= childPathFlag
+		}
+		binary.LittleEndian.PutUint64(signerPath[1:], keyIndex)
+		path = append(path, signerPath[:])
+	}
 	for _, idx := range itemIndexes {
 		var idxBytes [8]byte
 		binary.LittleEndian.PutUint64(idxBytes[:], idx)
@@ -75,21 +92,58 @@ func Path(s *Signer, ks keySpace, itemIndexes ...uint64) [][]byte {
 	return path
 }
 
-// Create creates and stores a Signer in the database
-func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quorum int, clientToken string) (*Signer, error) {
-	if len(xpubs) == 0 {
-		return nil, errors.Wrap(ErrNoXPubs)
+// CreateChild creates and stores a child of the parent Signer,
+// with the same keys and quorum. Keys for the child are derived
+// along a path that extends the parent's.
+func CreateChild(ctx context.Context, db pg.DB, parent *Signer, clientToken string) (*Signer, error) {
+	nullToken := sql.NullString{
+		String: clientToken,
+		Valid:  clientToken != "",
+	}
+	var xpubBytes [][]byte
+	for _, key := range parent.XPubs {
+		xpubBytes = append(xpubBytes, key[:])
+	}
+	parentIndexes := append(append([]uint64(nil), parent.ParentKeyIndexes...), parent.KeyIndex)
+	var parentIndexesArray pq.Int64Array
+	for _, idx := range parentIndexes {
+		parentIndexesArray = append(parentIndexesArray, int64(idx))
 	}
 
-	sort.Sort(sortKeys(xpubs)) // this transforms the input slice
-	for i := 1; i < len(xpubs); i++ {
-		if bytes.Equal(xpubs[i][:], xpubs[i-1][:]) {
-			return nil, errors.WithDetailf(ErrDupeXPub, "duplicated key=%x", xpubs[i])
-		}
+	const q = `
+		INSERT INTO signers (id, type, xpubs, quorum, client_token, parent_key_indexes)
+		VALUES (next_chain_id($1::text), $2, $3, $4, $5, $6)
+		ON CONFLICT (client_token) DO NOTHING
+		RETURNING id, key_index
+	`
+	var (
+		id       string
+		keyIndex uint64
+	)
+	err := db.QueryRow(ctx, q, typeIDMap[parent.Type], parent.Type, pq.ByteaArray(xpubBytes), parent.Quorum, nullToken, parentIndexesArray).
+		Scan(&id, &keyIndex)
+	if err == sql.ErrNoRows && clientToken != "" {
+		return findByClientToken(ctx, db, clientToken)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
 	}
 
-	if quorum == 0 || quorum > len(xpubs) {
-		return nil, errors.Wrap(ErrBadQuorum)
+	return &Signer{
+		ID:               id,
+		Type:             parent.Type,
+		XPubs:            parent.XPubs,
+		Quorum:           parent.Quorum,
+		KeyIndex:         keyIndex,
+		ParentKeyIndexes: parentIndexes,
+	}, nil
+}
+
+// Create creates and stores a Signer in the database
+func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quorum int, clientToken string) (*Signer, error) {
+	err := CheckKeys(xpubs, quorum)
+	if err != nil {
+		return nil, err
 	}
 
 	var xpubBytes [][]byte
@@ -112,7 +166,7 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quo
 		id       string
 		keyIndex uint64
 	)
-	err := db.QueryRow(ctx, q, typeIDMap[typ], typ, pq.ByteaArray(xpubBytes), quorum, nullToken).
+	err = db.QueryRow(ctx, q, typeIDMap[typ], typ, pq.ByteaArray(xpubBytes), quorum, nullToken).
 		Scan(&id, &keyIndex)
 	if err == sql.ErrNoRows && clientToken != "" {
 		return findByClientToken(ctx, db, clientToken)
@@ -130,6 +184,26 @@ func Create(ctx context.Context, db pg.DB, typ string, xpubs []chainkd.XPub, quo
 	}, nil
 }
 
+// CheckKeys checks that xpubs and quorum make a valid
+// signer, sorting xpubs in place as Create does.
+func CheckKeys(xpubs []chainkd.XPub, quorum int) error {
+	if len(xpubs) == 0 {
+		return errors.Wrap(ErrNoXPubs)
+	}
+
+	sort.Sort(sortKeys(xpubs)) // this transforms the input slice
+	for i := 1; i < len(xpubs); i++ {
+		if bytes.Equal(xpubs[i][:], xpubs[i-1][:]) {
+			return errors.WithDetailf(ErrDupeXPub, "duplicated key=%x", xpubs[i])
+		}
+	}
+
+	if quorum == 0 || quorum > len(xpubs) {
+		return errors.Wrap(ErrBadQuorum)
+	}
+	return nil
+}
+
 func New(id, typ string, xpubs [][]byte, quorum int, keyIndex uint64) (*Signer, error) {
 	keys, err := ConvertKeys(xpubs)
 	if err != nil {
@@ -146,19 +220,21 @@ func New(id, typ string, xpubs [][]byte, quorum int, keyIndex uint64) (*Signer,
 
 func findByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Signer, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, parent_key_indexes
 		FROM signers WHERE client_token=$1
 	`
 
 	var (
-		s         Signer
-		xpubBytes [][]byte
+		s             Signer
+		xpubBytes     [][]byte
+		parentIndexes pq.Int64Array
 	)
 	err := db.QueryRow(ctx, q, clientToken).
-		Scan(&s.ID, &s.Type, (*pq.ByteaArray)(&xpubBytes), &s.Quorum, &s.KeyIndex)
+		Scan(&s.ID, &s.Type, (*pq.ByteaArray)(&xpubBytes), &s.Quorum, &s.KeyIndex, &parentIndexes)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
+	s.ParentKeyIndexes = keyIndexes(parentIndexes)
 
 	keys, err := ConvertKeys(xpubBytes)
 	if err != nil {
@@ -174,13 +250,14 @@ func findByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Sign
 // using the type and id.
 func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, parent_key_indexes
 		FROM signers WHERE id=$1
 	`
 
 	var (
-		s         Signer
-		xpubBytes [][]byte
+		s             Signer
+		xpubBytes     [][]byte
+		parentIndexes pq.Int64Array
 	)
 	err := db.QueryRow(ctx, q, id).Scan(
 		&s.ID,
@@ -188,6 +265,7 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 		(*pq.ByteaArray)(&xpubBytes),
 		&s.Quorum,
 		&s.KeyIndex,
+		&parentIndexes,
 	)
 	if err == sql.ErrNoRows {
 		return nil, errors.Wrap(pg.ErrUserInputNotFound)
@@ -199,6 +277,7 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 	if s.Type != typ {
 		return nil, errors.Wrap(ErrBadType)
 	}
+	s.ParentKeyIndexes = keyIndexes(parentIndexes)
 
 	keys, err := ConvertKeys(xpubBytes)
 	if err != nil {
@@ -214,25 +293,26 @@ func Find(ctx context.Context, db pg.DB, typ, id string) (*Signer, error) {
 // the provided type.
 func List(ctx context.Context, db pg.DB, typ, prev string, limit int) ([]*Signer, string, error) {
 	const q = `
-		SELECT id, type, xpubs, quorum, key_index
+		SELECT id, type, xpubs, quorum, key_index, parent_key_indexes
 		FROM signers WHERE type=$1 AND ($2='' OR $2<id)
 		ORDER BY id ASC LIMIT $3
 	`
 
 	var signers []*Signer
 	err := pg.ForQueryRows(ctx, db, q, typ, prev, limit,
-		func(id, typ string, xpubs pq.ByteaArray, quorum int, keyIndex uint64) error {
+		func(id, typ string, xpubs pq.ByteaArray, quorum int, keyIndex uint64, parentIndexes pq.Int64Array) error {
 			keys, err := ConvertKeys(xpubs)
 			if err != nil {
 				return errors.WithDetail(errors.New("bad xpub in databse"), errors.Detail(err))
 			}
 
 			signers = append(signers, &Signer{
-				ID:       id,
-				Type:     typ,
-				XPubs:    keys,
-				Quorum:   quorum,
-				KeyIndex: keyIndex,
+				ID:               id,
+				Type:             typ,
+				XPubs:            keys,
+				Quorum:           quorum,
+				KeyIndex:         keyIndex,
+				ParentKeyIndexes: keyIndexes(parentIndexes),
 			})
 			return nil
 		},
@@ -250,6 +330,14 @@ func List(ctx context.Context, db pg.DB, typ, prev string, limit int) ([]*Signer
 	return signers, last, nil
 }
 
+func keyIndexes(a pq.Int64Array) []uint64 {
+	var indexes []uint64
+	for _, idx := range a {
+		indexes = append(indexes, uint64(idx))
+	}
+	return indexes
+}
+
 func ConvertKeys(xpubs [][]byte) ([]chainkd.XPub, error) {
 	var xkeys []chainkd.XPub
 	for i, xpub := range xpubs {
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/approval"
+	"chain/core/txbuilder"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+)
+
+// getSigningHashes returns the hashes each key must sign to
+// fill the unsigned slots of transaction templates, so they
+// can be signed by services that never see the transaction.
+//
+// POST /get-signing-hashes
+func (h *Handler) getSigningHashes(ctx context.Context, x struct {
+	Txs []*txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		if x.Txs[i] == nil || x.Txs[i].Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		hashes, err := txbuilder.SigningHashes(x.Txs[i])
+		if err != nil {
+			return nil, err
+		}
+		if hashes == nil {
+			hashes = []txbuilder.SigningHash{}
+		}
+		return struct {
+			Hashes []txbuilder.SigningHash `json:"signing_hashes"`
+		}{hashes}, nil
+	})
+}
+
+// addSignatures puts signatures of the hashes from
+// /get-signing-hashes into transaction templates.
+//
+// POST /add-signatures
+func (h *Handler) addSignatures(ctx context.Context, x struct {
+	Txs []struct {
+		Template   *txbuilder.Template           `json:"template"`
+		Signatures []txbuilder.DetachedSignature `json:"signatures"`
+	} `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := x.Txs[i].Template
+		if tpl == nil || tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		err := h.checkApproval(ctx, tpl, approval.EventSigned, func() error {
+			return txbuilder.AddSignatures(tpl, x.Txs[i].Signatures)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return tpl, nil
+	})
+}
+
+// encodeTemplates encodes transaction templates in the
+// binary form of txbuilder.Template.MarshalBinary, for
+// carrying to signers that are offline.
+//
+// POST /encode-transaction-templates
+func (h *Handler) encodeTemplates(ctx context.Context, x struct {
+	Txs []*txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		if x.Txs[i] == nil || x.Txs[i].Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		b, err := x.Txs[i].MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return struct {
+			Data chainjson.HexBytes `json:"data"`
+		}{b}, nil
+	})
+}
+
+// decodeTemplates decodes transaction templates
+// encoded by /encode-transaction-templates.
+//
+// POST /decode-transaction-templates
+func (h *Handler) decodeTemplates(ctx context.Context, x struct {
+	Txs []struct {
+		Data chainjson.HexBytes `json:"data"`
+	} `json:"transactions"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(x.Txs), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := new(txbuilder.Template)
+		err := tpl.UnmarshalBinary(x.Txs[i].Data)
+		if err != nil {
+			return nil, err
+		}
+		return tpl, nil
+	})
+}
+
+// mergeTemplates combines the signatures of templates of
+// one transaction, each signed by different parties.
+//
+// POST /merge-transaction-templates
+func (h *Handler) mergeTemplates(ctx context.Context, x struct {
+	Txs []*txbuilder.Template `json:"transactions"`
+}) (*txbuilder.Template, error) {
+	if len(x.Txs) == 0 || x.Txs[0] == nil || x.Txs[0].Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	var merged *txbuilder.Template
+	err := h.checkApproval(ctx, x.Txs[0], approval.EventSigned, func() (err error) {
+		merged, err = txbuilder.MergeTemplates(x.Txs...)
+		return err
+	})
+	return merged, err
+}
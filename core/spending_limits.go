@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/account"
+	chainjson "chain/encoding/json"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+type spendingLimitRequest struct {
+	AccountID    string      `json:"account_id"`
+	AccountAlias string      `json:"account_alias"`
+	AssetID      *bc.AssetID `json:"asset_id"`
+	AssetAlias   string      `json:"asset_alias"`
+
+	// Used only by /set-account-spending-limit.
+	MaxAmount uint64             `json:"max_amount"`
+	Window    chainjson.Duration `json:"window"`
+}
+
+// setAccountSpendingLimit limits the amount of an asset an
+// account can spend in any window of the given length.
+// It requires an admin access token.
+//
+// POST /set-account-spending-limit
+func (h *Handler) setAccountSpendingLimit(ctx context.Context, x spendingLimitRequest) (*account.SpendingLimit, error) {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accountID, assetID, err := h.spendingLimitIDs(ctx, x)
+	if err != nil {
+		return nil, err
+	}
+	return h.Accounts.SetSpendingLimit(ctx, accountID, assetID, x.MaxAmount, x.Window.Duration)
+}
+
+// deleteAccountSpendingLimit removes an account's limit
+// on spending an asset. It requires an admin access token.
+//
+// POST /delete-account-spending-limit
+func (h *Handler) deleteAccountSpendingLimit(ctx context.Context, x spendingLimitRequest) error {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	accountID, assetID, err := h.spendingLimitIDs(ctx, x)
+	if err != nil {
+		return err
+	}
+	return h.Accounts.DeleteSpendingLimit(ctx, accountID, assetID)
+}
+
+// listAccountSpendingLimits returns an account's spending
+// limits, with the amount spent against each in its
+// current window.
+//
+// POST /list-account-spending-limits
+func (h *Handler) listAccountSpendingLimits(ctx context.Context, x spendingLimitRequest) (interface{}, error) {
+	accountID, err := h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := h.Accounts.ListSpendingLimits(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(limits), nil
+}
+
+func (h *Handler) spendingLimitIDs(ctx context.Context, x spendingLimitRequest) (accountID string, assetID bc.AssetID, err error) {
+	accountID, err = h.requestAccountID(ctx, x.AccountID, x.AccountAlias)
+	if err != nil {
+		return "", assetID, err
+	}
+	if x.AssetID != nil {
+		return accountID, *x.AssetID, nil
+	}
+	a, err := h.findAssetByAlias(ctx, x.AssetAlias)
+	if err != nil {
+		return "", assetID, err
+	}
+	return accountID, a.AssetID, nil
+}
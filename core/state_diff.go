@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"chain/core/txdb"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+// maxStateDiffRange is the most blocks one /get-state-diff
+// request covers, since the outputs they create are held
+// in memory until the end of the stream.
+const maxStateDiffRange = 10000
+
+// stateDiffFlushLines is how many lines
+// /get-state-diff writes between flushes.
+const stateDiffFlushLines = 1000
+
+// stateDiffLine is one line of the /get-state-diff response.
+// A line holds either a change, or, last of all, Done. A
+// stream that fails part way through ends with an error
+// line instead.
+type stateDiffLine struct {
+	Change *stateChange   `json:"change,omitempty"`
+	Done   bool           `json:"done,omitempty"`
+	Error  *detailedError `json:"error,omitempty"`
+}
+
+type stateChange struct {
+	Type           string             `json:"type"`
+	BlockHeight    uint64             `json:"block_height"`
+	TransactionID  bc.Hash            `json:"transaction_id"`
+	Position       uint32             `json:"position"`
+	AssetID        bc.AssetID         `json:"asset_id"`
+	Amount         uint64             `json:"amount"`
+	ControlProgram chainjson.HexBytes `json:"control_program"`
+}
+
+// getStateDiff writes, as newline-delimited JSON, the outputs
+// consumed and then the outputs created between the blockchain
+// state after start_height and the state after end_height, as
+// in txdb.Store.DiffState. A client can keep a copy of the
+// unspent outputs up to date by applying each diff in turn,
+// without processing every transaction.
+//
+// POST /get-state-diff
+//
+// This handler doesn't use the httpjson.Handler format
+// so that it can write the response incrementally.
+func (h *Handler) getStateDiff(rw http.ResponseWriter, req *http.Request) {
+	if h.Config == nil {
+		alwaysError(errUnconfigured).ServeHTTP(rw, req)
+		return
+	}
+	ctx := req.Context()
+
+	var x struct {
+		StartHeight uint64 `json:"start_height"`
+		EndHeight   uint64 `json:"end_height"`
+	}
+	err := json.NewDecoder(req.Body).Decode(&x)
+	if err != nil {
+		WriteHTTPError(ctx, rw, httpjson.ErrBadRequest)
+		return
+	}
+	switch {
+	case x.EndHeight < x.StartHeight:
+		err = errors.WithDetail(httpjson.ErrBadRequest, "end_height must be at least start_height")
+	case x.EndHeight > h.Chain.Height():
+		err = errors.WithDetailf(httpjson.ErrBadRequest, "end_height is above the blockchain height %d", h.Chain.Height())
+	case x.EndHeight-x.StartHeight > maxStateDiffRange:
+		err = errors.WithDetailf(httpjson.ErrBadRequest, "at most %d blocks per diff", maxStateDiffRange)
+	}
+	if err != nil {
+		WriteHTTPError(ctx, rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := rw.(http.Flusher)
+	enc := json.NewEncoder(rw)
+	var n int
+	err = h.Store.DiffState(ctx, x.StartHeight, x.EndHeight, func(c *txdb.StateChange) error {
+		o := c.Output
+		err := enc.Encode(stateDiffLine{Change: &stateChange{
+			Type:           c.Kind,
+			BlockHeight:    c.Height,
+			TransactionID:  o.Outpoint.Hash,
+			Position:       o.Outpoint.Index,
+			AssetID:        o.AssetID,
+			Amount:         o.Amount,
+			ControlProgram: o.ControlProgram,
+		}})
+		if err != nil {
+			return errors.Wrap(err, "writing state change")
+		}
+		n++
+		if n%stateDiffFlushLines == 0 && flusher != nil {
+			flusher.Flush()
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		logHTTPError(ctx, err)
+		body, _ := errInfo(err)
+		enc.Encode(stateDiffLine{Error: &body})
+		return
+	}
+	err = enc.Encode(stateDiffLine{Done: true})
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "writing end of state diff"))
+	}
+}
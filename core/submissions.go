@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	stdsql "database/sql"
+	"encoding/json"
+	"time"
+
+	"chain/core/approval"
+	"chain/core/leader"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/log"
+	"chain/protocol/bc"
+)
+
+// Statuses of an asynchronous submission. A submission is
+// queued until the generator accepts its transaction, then
+// forwarded until the transaction lands in a block. It's
+// rejected if any step fails.
+const (
+	submissionQueued    = "queued"
+	submissionForwarded = "forwarded"
+	submissionConfirmed = "confirmed"
+	submissionRejected  = "rejected"
+)
+
+// errSubmissionInterrupted is recorded as the error of a
+// submission whose leader process stopped before it ended,
+// and that can't be resumed.
+var errSubmissionInterrupted = errors.New("submission interrupted")
+
+// submissionTimeout bounds the time spent waiting for the
+// transaction of a submission to land in a block.
+var submissionTimeout = 24 * time.Hour
+
+// submission is the status of a transaction submitted with
+// /submit-transaction-async. Submissions are kept for a day.
+type submission struct {
+	ID          string           `json:"id"`
+	TxID        bc.Hash          `json:"transaction_id"`
+	Status      string           `json:"status"`
+	BlockHeight uint64           `json:"block_height,omitempty"`
+	Error       *json.RawMessage `json:"error,omitempty"`
+	SubmittedAt time.Time        `json:"submitted_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// submitAsync submits transactions without waiting for them
+// to reach the generator or a block. It returns a submission
+// for each one, whose progress can be followed with
+// /get-submission-status. Transactions that aren't valid
+// are rejected at once, without a submission.
+//
+// POST /submit-transaction-async
+func (h *Handler) submitAsync(ctx context.Context, x struct {
+	Transactions []txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp json.RawMessage
+		err := h.forwardToLeader(ctx, "/submit-transaction-async", x, &resp)
+		return resp, err
+	}
+
+	return runBatch(ctx, len(x.Transactions), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := &x.Transactions[i]
+		if tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		tx := bc.NewTx(*tpl.Transaction)
+		err := txbuilder.ValidateTx(h.Chain, tx)
+		if err == nil {
+			err = h.Accounts.CheckFrozenSpends(ctx, tx)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "tx %s", tx.Hash)
+		}
+
+		var sub *submission
+		err = h.checkApproval(ctx, tpl, approval.EventSubmitted, func() (err error) {
+			sub, err = h.queueSubmission(ctx, tx)
+			return err
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "tx %s", tx.Hash)
+		}
+		return sub, nil
+	})
+}
+
+// queueSubmission records a new submission of tx and starts
+// submitting it in the background. The submission outlives
+// the request, so it doesn't use ctx for that.
+func (h *Handler) queueSubmission(ctx context.Context, tx *bc.Tx) (*submission, error) {
+	const q = `
+		INSERT INTO tx_submissions (tx_hash, status, tx) VALUES ($1, $2, $3)
+		RETURNING id, submitted_at, updated_at
+	`
+	sub := &submission{TxID: tx.Hash, Status: submissionQueued}
+	err := h.DB.QueryRow(ctx, q, tx.Hash[:], sub.Status, &tx.TxData).Scan(&sub.ID, &sub.SubmittedAt, &sub.UpdatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "saving submission")
+	}
+
+	go h.runSubmission(context.Background(), sub.ID, tx, false)
+	return sub, nil
+}
+
+// ResumeSubmissions takes up the submissions that were queued
+// or forwarded when the previous leader process stopped, since
+// they were driven by its goroutines. Submissions made before
+// their transactions were saved are rejected. It is meant to be
+// called when this process becomes leader; the resumed
+// submissions stop with ctx, to be taken up by the next leader.
+func (h *Handler) ResumeSubmissions(ctx context.Context) {
+	const q = `
+		SELECT id, status, tx FROM tx_submissions
+		WHERE status IN ($1, $2)
+		ORDER BY submitted_at
+	`
+	type resumable struct {
+		id, status string
+		tx         *bc.Tx
+	}
+	var subs []resumable
+	err := pg.ForQueryRows(ctx, h.DB, q, submissionQueued, submissionForwarded, func(id, status string, raw []byte) error {
+		sub := resumable{id: id, status: status}
+		if raw != nil {
+			var data bc.TxData
+			err := data.Scan(raw)
+			if err != nil {
+				return errors.Wrapf(err, "decoding tx of submission %s", id)
+			}
+			sub.tx = bc.NewTx(data)
+		}
+		subs = append(subs, sub)
+		return nil
+	})
+	if err != nil {
+		log.Error(ctx, errors.Wrap(err, "loading unfinished submissions"))
+		return
+	}
+
+	for _, sub := range subs {
+		if sub.tx == nil {
+			body, _ := errInfo(errSubmissionInterrupted)
+			err := h.setSubmissionStatus(ctx, sub.id, submissionRejected, 0, &body)
+			if err != nil {
+				log.Error(ctx, err, "submission ", sub.id)
+			}
+			continue
+		}
+		go h.runSubmission(ctx, sub.id, sub.tx, sub.status == submissionForwarded)
+	}
+}
+
+// runSubmission submits tx and records the progress of
+// the submission with the given ID until it lands in a
+// block or fails. If forwarded is set, the generator has
+// already accepted tx, and it only waits for the block.
+func (h *Handler) runSubmission(ctx context.Context, id string, tx *bc.Tx, forwarded bool) {
+	err := h.trackSubmission(ctx, id, tx, forwarded)
+	if ctx.Err() != nil {
+		// This process is no longer leader. The
+		// submission is left for the next one.
+		return
+	}
+	if err != nil {
+		body, _ := errInfo(err)
+		err = h.setSubmissionStatus(ctx, id, submissionRejected, 0, &body)
+	}
+	if err != nil {
+		log.Error(ctx, err, "submission ", id)
+	}
+}
+
+func (h *Handler) trackSubmission(ctx context.Context, id string, tx *bc.Tx, forwarded bool) error {
+	var (
+		height uint64
+		err    error
+	)
+	if forwarded {
+		// The height was recorded when tx was first submitted.
+		height, err = recordSubmittedTx(ctx, h.DB, tx.Hash, h.Chain.Height())
+		if err != nil {
+			return errors.Wrap(err, "loading tx submitted height")
+		}
+	} else {
+		height, err = h.finalizeTx(ctx, tx, chainjson.Duration{})
+		if err != nil {
+			return err
+		}
+		err = h.setSubmissionStatus(ctx, id, submissionForwarded, 0, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, submissionTimeout)
+	defer cancel()
+	height, err = h.waitForTxInBlock(waitCtx, tx, height)
+	if err != nil {
+		return err
+	}
+	return h.setSubmissionStatus(ctx, id, submissionConfirmed, height, nil)
+}
+
+func (h *Handler) setSubmissionStatus(ctx context.Context, id, status string, height uint64, body *detailedError) error {
+	var errJSON stdsql.NullString
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		errJSON = stdsql.NullString{String: string(b), Valid: true}
+	}
+	const q = `
+		UPDATE tx_submissions
+		SET status = $2, block_height = NULLIF($3::bigint, 0), error = $4::jsonb, updated_at = now()
+		WHERE id = $1
+	`
+	_, err := h.DB.Exec(ctx, q, id, status, int64(height), errJSON)
+	return errors.Wrap(err, "updating submission status")
+}
+
+// getSubmissionStatus returns the status of submissions
+// made in the last day with /submit-transaction-async.
+//
+// POST /get-submission-status
+func (h *Handler) getSubmissionStatus(ctx context.Context, ins []struct {
+	ID string `json:"id"`
+}) (interface{}, error) {
+	return runBatch(ctx, len(ins), func(ctx context.Context, i int) (interface{}, error) {
+		return loadSubmission(ctx, h.DB, ins[i].ID)
+	})
+}
+
+func loadSubmission(ctx context.Context, db pg.DB, id string) (*submission, error) {
+	const q = `
+		SELECT tx_hash, status, COALESCE(block_height, 0), error, submitted_at, updated_at
+		FROM tx_submissions WHERE id = $1
+	`
+	sub := &submission{ID: id}
+	var errJSON []byte
+	err := db.QueryRow(ctx, q, id).Scan(&sub.TxID, &sub.Status, &sub.BlockHeight, &errJSON, &sub.SubmittedAt, &sub.UpdatedAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "submission id %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading submission")
+	}
+	if len(errJSON) > 0 {
+		raw := json.RawMessage(errJSON)
+		sub.Error = &raw
+	}
+	return sub, nil
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"chain/core/account"
+	"chain/core/asset"
+	"chain/core/coretest"
+	"chain/core/generator"
+	"chain/core/leader"
+	"chain/core/pin"
+	"chain/core/query"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/prottest"
+	"chain/protocol/validation"
+	"chain/testutil"
+)
+
+func TestSubmissionStatus(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{DB: pgtest.NewTx(t)}
+
+	const q = `INSERT INTO tx_submissions (tx_hash, status) VALUES ($1, $2) RETURNING id`
+	var id string
+	txHash := bc.Hash{1}
+	err := h.DB.QueryRow(ctx, q, txHash[:], submissionQueued).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = h.setSubmissionStatus(ctx, id, submissionConfirmed, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := loadSubmission(ctx, h.DB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.TxID != (bc.Hash{1}) || sub.Status != submissionConfirmed || sub.BlockHeight != 5 || sub.Error != nil {
+		t.Errorf("submission = %+v, want tx %s confirmed at height 5", sub, bc.Hash{1})
+	}
+
+	body, _ := errInfo(validation.ErrFalseVMResult)
+	err = h.setSubmissionStatus(ctx, id, submissionRejected, 0, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err = loadSubmission(ctx, h.DB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Status != submissionRejected || sub.BlockHeight != 0 || sub.Error == nil {
+		t.Errorf("submission = %+v, want rejected with an error", sub)
+	}
+
+	_, err = loadSubmission(ctx, h.DB, "sub-nonexistent")
+	if errors.Root(err) != pg.ErrUserInputNotFound {
+		t.Errorf("err = %v want %v", err, pg.ErrUserInputNotFound)
+	}
+}
+
+func TestSubmitAsyncValidates(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	h := &Handler{
+		Chain:     c,
+		Submitter: g,
+		Assets:    asset.NewRegistry(db, c, pinStore),
+		Accounts:  account.NewManager(db, c, pinStore),
+		Indexer:   query.NewIndexer(db, c, pinStore),
+		DB:        db,
+	}
+
+	// TODO(jackson): Replace this with a mock leader.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go leader.Run(db, ":1999", func(ctx context.Context) {
+		wg.Done()
+	})
+	wg.Wait()
+
+	acc := coretest.CreateAccount(ctx, t, h.Accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, h.Assets, nil, "", nil)
+	build := func(amount uint64) *txbuilder.Template {
+		assetAmt := bc.AssetAmount{AssetID: assetID, Amount: amount}
+		tmpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			h.Assets.NewIssueAction(assetAmt, nil),
+			h.Accounts.NewControlAction(assetAmt, acc, nil),
+		}, time.Now().Add(time.Minute))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return tmpl
+	}
+	valid := build(100)
+	coretest.SignTxTemplate(t, ctx, valid, &testutil.TestXPrv)
+	invalid := build(200) // unsigned
+
+	var x struct {
+		Transactions []txbuilder.Template `json:"transactions"`
+	}
+	x.Transactions = []txbuilder.Template{*valid, *invalid}
+	resp, err := h.submitAsync(ctx, x)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	items := resp.([]interface{})
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2", len(items))
+	}
+	sub, ok := items[0].(*submission)
+	if !ok || sub.TxID != valid.Transaction.Hash() {
+		t.Errorf("items[0] = %+v, want submission of tx %s", items[0], valid.Transaction.Hash())
+	}
+	wantErr, _ := errInfo(txbuilder.ErrNoTxSighashCommitment)
+	if e, ok := items[1].(detailedError); !ok || e.ChainCode != wantErr.ChainCode {
+		t.Errorf("items[1] = %+v, want error %s", items[1], wantErr.ChainCode)
+	}
+
+	var hashes []bc.Hash
+	err = pg.ForQueryRows(ctx, db, `SELECT tx_hash FROM tx_submissions`, func(h bc.Hash) {
+		hashes = append(hashes, h)
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(hashes) != 1 || hashes[0] != valid.Transaction.Hash() {
+		t.Errorf("submitted txs = %v, want [%s]", hashes, valid.Transaction.Hash())
+	}
+}
+
+func TestResumeSubmissionsWithoutTx(t *testing.T) {
+	ctx := context.Background()
+	h := &Handler{DB: pgtest.NewTx(t)}
+
+	// A submission left queued by a leader that
+	// stopped before transactions were saved.
+	const q = `INSERT INTO tx_submissions (tx_hash, status) VALUES ($1, $2) RETURNING id`
+	var id string
+	txHash := bc.Hash{1}
+	err := h.DB.QueryRow(ctx, q, txHash[:], submissionQueued).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.ResumeSubmissions(ctx)
+	sub, err := loadSubmission(ctx, h.DB, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := errInfo(errSubmissionInterrupted)
+	if sub.Status != submissionRejected || sub.Error == nil || !strings.Contains(string(*sub.Error), want.ChainCode) {
+		t.Errorf("submission = %+v, want rejected with %s", sub, want.ChainCode)
+	}
+}
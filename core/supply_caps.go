@@ -0,0 +1,92 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"chain/core/blocksigner"
+	"chain/core/generator"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errNotBlockSigner = errors.New("core is not a block signer")
+
+// supplyCap is an asset's supply cap
+// and the amount issued under it.
+type supplyCap struct {
+	AssetID bc.AssetID `json:"asset_id"`
+	Amount  uint64     `json:"amount"`
+	Issued  uint64     `json:"issued"`
+}
+
+// listSupplyCaps returns the supply caps in
+// effect as of the latest block, by asset ID.
+//
+// POST /list-supply-caps
+func (h *Handler) listSupplyCaps(ctx context.Context) (interface{}, error) {
+	_, snapshot := h.Chain.State()
+	caps := []supplyCap{}
+	if snapshot != nil {
+		for assetID, sc := range snapshot.SupplyCaps {
+			caps = append(caps, supplyCap{AssetID: assetID, Amount: sc.Limit, Issued: sc.Issued})
+		}
+	}
+	sort.Sort(byAssetID(caps))
+	return httpjson.Array(caps), nil
+}
+
+// proposeSupplyCaps replaces the supply caps the generator
+// commits in the next block it makes. An empty list withdraws
+// the proposal. It requires an admin access token.
+//
+// POST /propose-supply-caps
+func (h *Handler) proposeSupplyCaps(ctx context.Context, x struct {
+	SupplyCaps []bc.SupplyCap `json:"supply_caps"`
+}) error {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !h.Config.IsGenerator {
+		return errors.Wrap(errNotGenerator)
+	}
+	return generator.ProposeSupplyCaps(ctx, h.DB, x.SupplyCaps)
+}
+
+// POST /list-pending-supply-caps
+func (h *Handler) listPendingSupplyCaps(ctx context.Context) (interface{}, error) {
+	if !h.Config.IsGenerator {
+		return nil, errors.Wrap(errNotGenerator)
+	}
+	caps, err := generator.PendingSupplyCaps(ctx, h.DB)
+	if err != nil {
+		return nil, err
+	}
+	return httpjson.Array(caps), nil
+}
+
+// approveSupplyCaps records supply caps that this signer
+// will sign blocks setting. It requires an admin access token.
+//
+// POST /approve-supply-caps
+func (h *Handler) approveSupplyCaps(ctx context.Context, x struct {
+	SupplyCaps []bc.SupplyCap `json:"supply_caps"`
+}) error {
+	err := h.checkAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if !h.Config.IsSigner {
+		return errors.Wrap(errNotBlockSigner)
+	}
+	return blocksigner.ApproveSupplyCaps(ctx, h.DB, x.SupplyCaps)
+}
+
+type byAssetID []supplyCap
+
+func (a byAssetID) Len() int           { return len(a) }
+func (a byAssetID) Less(i, j int) bool { return bytes.Compare(a[i].AssetID[:], a[j].AssetID[:]) < 0 }
+func (a byAssetID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
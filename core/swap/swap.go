@@ -0,0 +1,344 @@
+// Package swap keeps a book of offers to trade units of one
+// asset for units of another.
+//
+// An offer is a partial transaction, signed by its maker with
+// additional actions allowed, that spends the offered units
+// and pays the maker the requested ones. A taker completes it
+// with actions that pay the requested units and receive the
+// offered ones, so both sides settle in one transaction or
+// not at all. An offer closes once any output it spends is
+// spent on the blockchain, whether by a taker or its maker.
+package swap
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/pin"
+	"chain/core/txbuilder"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/math/checked"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+// PinName is used to identify the pin associated with
+// the swap offer block processor.
+const PinName = "swap"
+
+var (
+	// ErrBadOffer is returned by Create for a transaction
+	// that isn't a signed trade of one asset for another.
+	ErrBadOffer = errors.New("invalid swap offer")
+
+	// ErrNotOpen is returned for an offer that was
+	// closed or cancelled, or that has expired.
+	ErrNotOpen = errors.New("swap offer is not open")
+)
+
+// Offer statuses.
+const (
+	StatusOpen      = "open"
+	StatusClosed    = "closed"
+	StatusCancelled = "cancelled"
+)
+
+// An Offer trades the Offered units for the Requested ones.
+type Offer struct {
+	ID        string         `json:"id"`
+	Offered   bc.AssetAmount `json:"offered"`
+	Requested bc.AssetAmount `json:"requested"`
+
+	// Transaction is the maker's signed partial
+	// transaction, to build on to take the offer.
+	Transaction *bc.TxData `json:"base_transaction"`
+
+	Status string `json:"status"`
+
+	// ClosedBy is the transaction that spent
+	// an output the offer spends, if any.
+	ClosedBy *bc.Hash `json:"closed_by,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Book stores swap offers and closes them
+// as the outputs they spend are spent.
+type Book struct {
+	DB       pg.DB
+	Chain    *protocol.Chain
+	PinStore *pin.Store
+}
+
+// Terms returns the units tx offers, those its inputs
+// spend but its outputs don't pay back, and the units it
+// requests, those its outputs pay but its inputs don't
+// spend. Each must be of exactly one asset.
+func Terms(tx *bc.TxData) (offered, requested bc.AssetAmount, err error) {
+	net := make(map[bc.AssetID]int64)
+	var order []bc.AssetID
+	add := func(assetID bc.AssetID, amount uint64, spent bool) error {
+		if _, ok := net[assetID]; !ok {
+			order = append(order, assetID)
+		}
+		n, ok := net[assetID], amount <= math.MaxInt64
+		if ok && spent {
+			n, ok = checked.AddInt64(n, int64(amount))
+		} else if ok {
+			n, ok = checked.SubInt64(n, int64(amount))
+		}
+		if !ok {
+			return errors.WithDetailf(ErrBadOffer, "amount of asset %s out of range", assetID)
+		}
+		net[assetID] = n
+		return nil
+	}
+	for _, in := range tx.Inputs {
+		err = add(in.AssetID(), in.Amount(), true)
+		if err != nil {
+			return offered, requested, err
+		}
+	}
+	for _, out := range tx.Outputs {
+		err = add(out.AssetID, out.Amount, false)
+		if err != nil {
+			return offered, requested, err
+		}
+	}
+
+	var nOffered, nRequested int
+	for _, assetID := range order {
+		switch n := net[assetID]; {
+		case n > 0:
+			offered = bc.AssetAmount{AssetID: assetID, Amount: uint64(n)}
+			nOffered++
+		case n < 0:
+			requested = bc.AssetAmount{AssetID: assetID, Amount: uint64(-n)}
+			nRequested++
+		}
+	}
+	if nOffered != 1 || nRequested != 1 {
+		err = errors.WithDetailf(ErrBadOffer, "offers %d assets and requests %d; want one of each", nOffered, nRequested)
+		return bc.AssetAmount{}, bc.AssetAmount{}, err
+	}
+	return offered, requested, nil
+}
+
+// Create adds the offer made by tpl, which must allow
+// additional actions and have a signature on each input
+// that its program accepts, to the book.
+func (b *Book) Create(ctx context.Context, tpl *txbuilder.Template) (*Offer, error) {
+	if tpl == nil || tpl.Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	tx := tpl.Transaction
+	if !tpl.AllowAdditional {
+		return nil, errors.WithDetail(ErrBadOffer, "template must allow additional actions")
+	}
+	var spends pq.StringArray
+	for i, in := range tx.Inputs {
+		if len(in.Arguments()) == 0 {
+			return nil, errors.WithDetailf(ErrBadOffer, "input %d is not signed", i)
+		}
+		if !in.IsIssuance() {
+			spends = append(spends, in.Outpoint().String())
+		}
+	}
+	offered, requested, err := Terms(tx)
+	if err != nil {
+		return nil, err
+	}
+	if tx.MaxTime == 0 {
+		return nil, errors.WithDetail(ErrBadOffer, "transaction has no max time")
+	}
+	expiresAt := time.Unix(0, int64(tx.MaxTime)*int64(time.Millisecond))
+	if !expiresAt.After(time.Now()) {
+		return nil, errors.WithDetail(ErrBadOffer, "transaction has expired")
+	}
+	// An input whose program fails now, with only the maker's
+	// actions, would make every taker's transaction invalid.
+	btx := bc.NewTx(*tx)
+	for i := range tx.Inputs {
+		ok, err := vm.VerifyTxInput(btx, uint32(i))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadOffer, "input %d: %s", i, err)
+		}
+		if !ok {
+			return nil, errors.WithDetailf(ErrBadOffer, "input %d: program returned false", i)
+		}
+	}
+
+	o := &Offer{
+		Offered:     offered,
+		Requested:   requested,
+		Transaction: tx,
+		Status:      StatusOpen,
+		ExpiresAt:   expiresAt,
+	}
+	const q = `
+		INSERT INTO swap_offers (tx, spends, offered_asset_id, offered_amount,
+			requested_asset_id, requested_amount, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err = b.DB.QueryRow(ctx, q, tx, spends, offered.AssetID, offered.Amount,
+		requested.AssetID, requested.Amount, expiresAt).Scan(&o.ID, &o.CreatedAt)
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting swap offer")
+	}
+	return o, nil
+}
+
+const offerCols = `
+	id, tx, offered_asset_id, offered_amount, requested_asset_id,
+	requested_amount, status, closed_by, created_at, expires_at
+`
+
+func scanOffer(scan func(...interface{}) error) (*Offer, error) {
+	var (
+		o        = &Offer{Transaction: new(bc.TxData)}
+		closedBy []byte
+	)
+	err := scan(&o.ID, o.Transaction, &o.Offered.AssetID, &o.Offered.Amount, &o.Requested.AssetID,
+		&o.Requested.Amount, &o.Status, &closedBy, &o.CreatedAt, &o.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if closedBy != nil {
+		o.ClosedBy = new(bc.Hash)
+		copy(o.ClosedBy[:], closedBy)
+	}
+	return o, nil
+}
+
+// Find returns the offer with the given ID.
+func (b *Book) Find(ctx context.Context, id string) (*Offer, error) {
+	q := `SELECT ` + offerCols + ` FROM swap_offers WHERE id = $1`
+	o, err := scanOffer(b.DB.QueryRow(ctx, q, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "swap offer %s", id)
+	}
+	return o, errors.Wrap(err, "loading swap offer")
+}
+
+// List returns up to limit open, unexpired offers, ordered by
+// ID, starting after the offer with ID after. If offered or
+// requested is non-nil, it returns only offers of that asset
+// or for that asset. It also returns the value of after for
+// the next page.
+func (b *Book) List(ctx context.Context, offered, requested *bc.AssetID, after string, limit int) ([]*Offer, string, error) {
+	q := `
+		SELECT ` + offerCols + ` FROM swap_offers
+		WHERE status = 'open' AND expires_at > now()
+			AND ($1::bytea IS NULL OR offered_asset_id = $1)
+			AND ($2::bytea IS NULL OR requested_asset_id = $2)
+			AND id > $3
+		ORDER BY id LIMIT $4
+	`
+	var offeredID, requestedID []byte
+	if offered != nil {
+		offeredID = offered[:]
+	}
+	if requested != nil {
+		requestedID = requested[:]
+	}
+	rows, err := b.DB.Query(ctx, q, offeredID, requestedID, after, limit)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing swap offers")
+	}
+	defer rows.Close()
+	var offers []*Offer
+	for rows.Next() {
+		o, err := scanOffer(rows.Scan)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "scanning swap offer")
+		}
+		offers = append(offers, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, "", errors.Wrap(err, "listing swap offers")
+	}
+	if len(offers) > 0 {
+		after = offers[len(offers)-1].ID
+	}
+	return offers, after, nil
+}
+
+// FindOpen returns the offer with the given
+// ID, if it's open and hasn't expired.
+func (b *Book) FindOpen(ctx context.Context, id string) (*Offer, error) {
+	o, err := b.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if o.Status != StatusOpen {
+		return nil, errors.WithDetailf(ErrNotOpen, "swap offer %s is %s", id, o.Status)
+	}
+	if !o.ExpiresAt.After(time.Now()) {
+		return nil, errors.WithDetailf(ErrNotOpen, "swap offer %s has expired", id)
+	}
+	return o, nil
+}
+
+// Cancel removes an open offer from the book. Cancelling
+// doesn't invalidate the maker's signatures: a taker who
+// already has the offer's transaction can still complete it
+// until it expires, unless the maker spends its outputs.
+func (b *Book) Cancel(ctx context.Context, id string) error {
+	const q = `
+		UPDATE swap_offers SET status = 'cancelled'
+		WHERE id = $1 AND status = 'open'
+	`
+	res, err := b.DB.Exec(ctx, q, id)
+	if err != nil {
+		return errors.Wrap(err, "cancelling swap offer")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "cancelling swap offer")
+	}
+	if n == 0 {
+		_, err = b.FindOpen(ctx, id)
+		return err
+	}
+	return nil
+}
+
+// ProcessBlocks closes offers as transactions
+// spend the outputs they spend.
+func (b *Book) ProcessBlocks(ctx context.Context) {
+	if b.PinStore == nil {
+		return
+	}
+	b.PinStore.ProcessBlocks(ctx, b.Chain, PinName, b.indexBlock)
+}
+
+func (b *Book) indexBlock(ctx context.Context, block *bc.Block) error {
+	const q = `
+		UPDATE swap_offers SET status = 'closed', closed_by = $2
+		WHERE status <> 'closed' AND spends && $1::text[]
+	`
+	for _, tx := range block.Transactions {
+		var spends pq.StringArray
+		for _, in := range tx.Inputs {
+			if !in.IsIssuance() {
+				spends = append(spends, in.Outpoint().String())
+			}
+		}
+		if len(spends) == 0 {
+			continue
+		}
+		_, err := b.DB.Exec(ctx, q, spends, tx.Hash)
+		if err != nil {
+			return errors.Wrap(err, "closing swap offers")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package swap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/core/txbuilder"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+)
+
+func TestTerms(t *testing.T) {
+	gold, silver, bronze := bc.AssetID{1}, bc.AssetID{2}, bc.AssetID{3}
+	spend := func(assetID bc.AssetID, amount uint64) *bc.TxInput {
+		return bc.NewSpendInput(bc.Hash{}, 0, nil, assetID, amount, nil, nil)
+	}
+	pay := func(assetID bc.AssetID, amount uint64) *bc.TxOutput {
+		return bc.NewTxOutput(assetID, amount, []byte{1}, nil)
+	}
+
+	tx := &bc.TxData{
+		Inputs:  []*bc.TxInput{spend(gold, 150)},
+		Outputs: []*bc.TxOutput{pay(gold, 50), pay(silver, 20)},
+	}
+	offered, requested, err := Terms(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (bc.AssetAmount{AssetID: gold, Amount: 100}); offered != want {
+		t.Errorf("offered = %v want %v", offered, want)
+	}
+	if want := (bc.AssetAmount{AssetID: silver, Amount: 20}); requested != want {
+		t.Errorf("requested = %v want %v", requested, want)
+	}
+
+	bad := []*bc.TxData{
+		// nothing requested
+		{Inputs: []*bc.TxInput{spend(gold, 150)}, Outputs: []*bc.TxOutput{pay(gold, 50)}},
+		// two assets offered
+		{
+			Inputs:  []*bc.TxInput{spend(gold, 10), spend(bronze, 10)},
+			Outputs: []*bc.TxOutput{pay(silver, 20)},
+		},
+		// balanced
+		{Inputs: []*bc.TxInput{spend(gold, 10)}, Outputs: []*bc.TxOutput{pay(gold, 10)}},
+		// out of range
+		{
+			Inputs:  []*bc.TxInput{spend(gold, 1<<63), spend(gold, 1<<63)},
+			Outputs: []*bc.TxOutput{pay(silver, 20)},
+		},
+	}
+	for i, tx := range bad {
+		_, _, err := Terms(tx)
+		if errors.Root(err) != ErrBadOffer {
+			t.Errorf("case %d: err = %v want %v", i, err, ErrBadOffer)
+		}
+	}
+}
+
+func TestCreateVerifiesInputs(t *testing.T) {
+	gold, silver := bc.AssetID{1}, bc.AssetID{2}
+	maxTime := bc.Millis(time.Now().Add(time.Hour))
+	offer := func(prog []byte) *txbuilder.Template {
+		return &txbuilder.Template{
+			Transaction: &bc.TxData{
+				Version: 1,
+				MaxTime: maxTime,
+				Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, [][]byte{{1}}, gold, 10, prog, nil)},
+				Outputs: []*bc.TxOutput{bc.NewTxOutput(silver, 5, []byte{1}, nil)},
+			},
+			AllowAdditional: true,
+		}
+	}
+
+	// An input whose program rejects its arguments
+	// is refused before the offer is stored.
+	b := new(Book)
+	_, err := b.Create(context.Background(), offer([]byte{byte(vm.OP_FALSE)}))
+	if errors.Root(err) != ErrBadOffer {
+		t.Errorf("Create with failing input = %v want %v", err, ErrBadOffer)
+	}
+}
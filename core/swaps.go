@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/leader"
+	"chain/core/swap"
+	"chain/core/txbuilder"
+	chainjson "chain/encoding/json"
+	"chain/net/http/httpjson"
+)
+
+// POST /create-swap-offer
+func (h *Handler) createSwapOffer(ctx context.Context, x struct {
+	Template *txbuilder.Template `json:"template"`
+}) (*swap.Offer, error) {
+	return h.Swaps.Create(ctx, x.Template)
+}
+
+// POST /list-swap-offers
+func (h *Handler) listSwapOffers(ctx context.Context, x requestQuery) (*page, error) {
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	offers, next, err := h.Swaps.List(ctx, x.OfferedAssetID, x.RequestedAssetID, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(offers),
+		LastPage: len(offers) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// POST /get-swap-offer
+func (h *Handler) getSwapOffer(ctx context.Context, x struct {
+	ID string `json:"id"`
+}) (*swap.Offer, error) {
+	return h.Swaps.Find(ctx, x.ID)
+}
+
+// POST /cancel-swap-offer
+func (h *Handler) cancelSwapOffer(ctx context.Context, x struct {
+	ID string `json:"id"`
+}) error {
+	return h.Swaps.Cancel(ctx, x.ID)
+}
+
+type fillSwapOfferRequest struct {
+	ID             string                   `json:"id"`
+	Actions        []map[string]interface{} `json:"actions"`
+	TTL            chainjson.Duration       `json:"ttl"`
+	ReservationTTL chainjson.Duration       `json:"reservation_ttl"`
+}
+
+// fillSwapOffer builds a transaction template taking an open
+// offer: its transaction, with the maker's signatures, plus
+// the taker's actions. The taker signs the template and
+// submits it as usual; the offer closes once it's in a block.
+//
+// POST /fill-swap-offer
+func (h *Handler) fillSwapOffer(ctx context.Context, x fillSwapOfferRequest) (*txbuilder.Template, error) {
+	// Like /build-transaction, this needs the leader's reservations.
+	if !leader.IsLeading() {
+		var tpl txbuilder.Template
+		err := h.forwardToLeader(ctx, "/fill-swap-offer", x, &tpl)
+		return &tpl, err
+	}
+
+	o, err := h.Swaps.FindOpen(ctx, x.ID)
+	if err != nil {
+		return nil, err
+	}
+	return h.buildSingle(ctx, &buildRequest{
+		Tx:             o.Transaction,
+		Actions:        x.Actions,
+		TTL:            x.TTL,
+		ReservationTTL: x.ReservationTTL,
+	})
+}
@@ -1,11 +1,13 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	stdsql "database/sql"
 	"encoding/json"
-	"sync"
 	"time"
 
+	"chain/core/approval"
 	"chain/core/fetch"
 	"chain/core/leader"
 	"chain/core/txbuilder"
@@ -13,26 +15,74 @@ import (
 	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
-	"chain/net/http/reqid"
+	"chain/net/http/httpjson"
 	"chain/protocol/bc"
+	"chain/protocol/validation"
 )
 
 var defaultTxTTL = 5 * time.Minute
 
+// DefaultClientTokenWindow is how long submit results
+// are kept for retries with the same client token,
+// when Handler.ClientTokenWindow is zero.
+const DefaultClientTokenWindow = 24 * time.Hour
+
+var errClientTokenReused = errors.New("client token reused for a different transaction")
+
+// Submit wait modes, and the statuses reported for a submitted
+// transaction. A transaction is accepted once it's in the
+// generator's pool, processed once it's in a block, and indexed
+// once local queries can see it.
+const (
+	waitNone      = "none"
+	waitProcessed = "processed"
+	waitIndexed   = "indexed"
+
+	statusAccepted  = "accepted"
+	statusProcessed = "processed"
+	statusIndexed   = "indexed"
+)
+
+// Default timeouts for each stage of waiting for a submitted tx.
+var (
+	defaultAcceptTimeout    = 10 * time.Second
+	defaultProcessedTimeout = 30 * time.Second
+	defaultIndexedTimeout   = 30 * time.Second
+)
+
 func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilder.Template, error) {
 	err := h.filterAliases(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	actions := make([]txbuilder.Action, 0, len(req.Actions))
+	var quotaActions [][]byte
 	for i, act := range req.Actions {
 		typ, ok := act["type"].(string)
 		if !ok {
-			return nil, errors.WithDetailf(errBadActionType, "no action type provided on action %d", i)
+			err := errors.WithDetail(errBadActionType, "no action type provided")
+			return nil, errors.WithData(err, errors.KeyActionIndex, i)
 		}
 		decoder, ok := h.actionDecoders[typ]
 		if !ok {
-			return nil, errors.WithDetailf(errBadActionType, "unknown action type %q on action %d", typ, i)
+			err := errors.WithDetailf(errBadActionType, "unknown action type %q", typ)
+			return nil, errors.WithData(err, errors.KeyActionIndex, i)
+		}
+
+		// Clients that can't represent every uint64 as a number
+		// may send amounts as strings.
+		err := parseAmount(act)
+		if err != nil {
+			return nil, errors.WithData(err, errors.KeyActionIndex, i)
+		}
+		issues, _ := act["issues"].([]interface{})
+		for _, iss := range issues {
+			if iss, ok := iss.(map[string]interface{}); ok {
+				err := parseAmount(iss)
+				if err != nil {
+					return nil, errors.WithData(err, errors.KeyActionIndex, i)
+				}
+			}
 		}
 
 		// Remarshal to JSON, the action may have been modified when we
@@ -43,17 +93,43 @@ func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilde
 		}
 		a, err := decoder(b)
 		if err != nil {
-			return nil, errors.WithDetailf(errBadAction, "%s on action %d", err.Error(), i)
+			err = errors.WithDetail(errBadAction, err.Error())
+			return nil, errors.WithData(err, errors.KeyActionIndex, i)
 		}
 		actions = append(actions, a)
+		switch typ {
+		case "spend_account", "issue":
+			quotaActions = append(quotaActions, b)
+		case "issue_many":
+			for _, iss := range issues {
+				b, err := json.Marshal(iss)
+				if err != nil {
+					return nil, err
+				}
+				quotaActions = append(quotaActions, b)
+			}
+		}
+	}
+	charges, err := quotaCharges(ctx, quotaActions)
+	if err != nil {
+		return nil, err
 	}
 
 	ttl := req.TTL.Duration
 	if ttl == 0 {
 		ttl = defaultTxTTL
 	}
-	maxTime := time.Now().Add(ttl)
-	tpl, err := txbuilder.Build(ctx, req.Tx, actions, maxTime)
+	now := time.Now()
+	maxTime := now.Add(ttl)
+	reservationExpiry := maxTime
+	if req.ReservationTTL.Duration > 0 {
+		reservationExpiry = now.Add(req.ReservationTTL.Duration)
+	}
+	var tpl *txbuilder.Template
+	err = h.buildWithQuotas(ctx, charges, func() (err error) {
+		tpl, err = txbuilder.BuildReserving(ctx, req.Tx, actions, maxTime, reservationExpiry)
+		return err
+	})
 	if errors.Root(err) == txbuilder.ErrAction {
 		err = errors.WithData(err, "actions", errInfoBodyList(errors.Data(err)["actions"].([]error)))
 	}
@@ -68,6 +144,22 @@ func (h *Handler) buildSingle(ctx context.Context, req *buildRequest) (*txbuilde
 	return tpl, nil
 }
 
+// parseAmount replaces a string amount in
+// the action m with the number it holds.
+func parseAmount(m map[string]interface{}) error {
+	s, ok := m["amount"].(string)
+	if !ok {
+		return nil
+	}
+	var amt chainjson.Uint64
+	err := amt.UnmarshalJSON([]byte(s))
+	if err != nil {
+		return errors.WithDetailf(errBadAction, "invalid amount %q", s)
+	}
+	m["amount"] = amt
+	return nil
+}
+
 // POST /build-transaction
 func (h *Handler) build(ctx context.Context, buildReqs []*buildRequest) (interface{}, error) {
 	// If we're not the leader, we don't have access to the current
@@ -79,36 +171,64 @@ func (h *Handler) build(ctx context.Context, buildReqs []*buildRequest) (interfa
 		return resp, err
 	}
 
-	responses := make([]interface{}, len(buildReqs))
-	var wg sync.WaitGroup
-	wg.Add(len(responses))
+	return runBatch(ctx, len(buildReqs), func(ctx context.Context, i int) (interface{}, error) {
+		return h.buildSingle(ctx, buildReqs[i])
+	})
+}
 
-	for i := 0; i < len(responses); i++ {
-		go func(i int) {
-			subctx := reqid.NewSubContext(ctx, reqid.New())
-			defer wg.Done()
-			defer batchRecover(subctx, &responses[i])
+type extendReservationsRequest struct {
+	Template       *txbuilder.Template `json:"template"`
+	ReservationTTL chainjson.Duration  `json:"reservation_ttl"`
+}
 
-			tmpl, err := h.buildSingle(subctx, buildReqs[i])
-			if err != nil {
-				responses[i] = err
-			} else {
-				responses[i] = tmpl
-			}
-		}(i)
+// extendReservations keeps the outputs each template spends
+// reserved for another reservation_ttl, or until the template's
+// transaction expires, if that's sooner, so that long signing
+// flows don't lose them.
+//
+// POST /extend-transaction-reservations
+func (h *Handler) extendReservations(ctx context.Context, reqs []*extendReservationsRequest) (interface{}, error) {
+	// Reservations live in the leader process.
+	if !leader.IsLeading() {
+		var resp []interface{}
+		err := h.forwardToLeader(ctx, "/extend-transaction-reservations", reqs, &resp)
+		return resp, err
 	}
 
-	wg.Wait()
-	return responses, nil
+	return runBatch(ctx, len(reqs), func(ctx context.Context, i int) (interface{}, error) {
+		req := reqs[i]
+		if req.Template == nil || req.Template.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		ttl := req.ReservationTTL.Duration
+		if ttl <= 0 {
+			ttl = defaultTxTTL
+		}
+		n, exp, err := h.Accounts.ExtendReservations(ctx, req.Template.Transaction, time.Now().Add(ttl))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"reserved_outputs": n,
+			"expires_at":       exp,
+		}, nil
+	})
 }
 
-func (h *Handler) submitSingle(ctx context.Context, tpl *txbuilder.Template, waitUntil string) (interface{}, error) {
-	err := h.finalizeTxWait(ctx, tpl, waitUntil)
+func (h *Handler) submitSingle(ctx context.Context, tpl *txbuilder.Template, x *submitArg) (interface{}, error) {
+	var status string
+	err := h.checkApproval(ctx, tpl, approval.EventSubmitted, func() (err error) {
+		status, err = h.finalizeTxWait(ctx, tpl, x)
+		return err
+	})
 	if err != nil {
 		return nil, errors.Wrapf(err, "tx %s", tpl.Transaction.Hash())
 	}
 
-	return map[string]string{"id": tpl.Transaction.Hash().String()}, nil
+	return map[string]string{
+		"id":     tpl.Transaction.Hash().String(),
+		"status": status,
+	}, nil
 }
 
 // recordSubmittedTx records a lower bound height at which the tx
@@ -145,6 +265,34 @@ func recordSubmittedTx(ctx context.Context, db pg.DB, txHash bc.Hash, currentHei
 	return height, err
 }
 
+// releaseReservations cancels the reservations of the outputs
+// each template spends, so that an abandoned transaction's
+// funds can be spent again without waiting for it to expire.
+//
+// POST /release-transaction-reservations
+func (h *Handler) releaseReservations(ctx context.Context, reqs []struct {
+	Template *txbuilder.Template `json:"template"`
+}) (interface{}, error) {
+	// Reservations live in the leader process.
+	if !leader.IsLeading() {
+		var resp []interface{}
+		err := h.forwardToLeader(ctx, "/release-transaction-reservations", reqs, &resp)
+		return resp, err
+	}
+
+	return runBatch(ctx, len(reqs), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := reqs[i].Template
+		if tpl == nil || tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		n, err := h.Accounts.ReleaseReservations(ctx, tpl.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"released_outputs": n}, nil
+	})
+}
+
 // CleanupSubmittedTxs will periodically delete records of submitted txs
 // older than a day. This function blocks and only exits when its context
 // is cancelled.
@@ -165,6 +313,27 @@ func CleanupSubmittedTxs(ctx context.Context, db pg.DB) {
 			if err != nil {
 				log.Error(ctx, err)
 			}
+			// Pending txs with no max time are otherwise kept until they land.
+			const pendingQ = `DELETE FROM pending_txs WHERE submitted_at < now() - interval '1 day'`
+			_, err = db.Exec(ctx, pendingQ)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+			const failuresQ = `DELETE FROM tx_failures WHERE failed_at < now() - interval '1 day'`
+			_, err = db.Exec(ctx, failuresQ)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+			const submissionsQ = `DELETE FROM tx_submissions WHERE submitted_at < now() - interval '1 day'`
+			_, err = db.Exec(ctx, submissionsQ)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+			const rejectionsQ = `DELETE FROM generator_rejections WHERE rejected_at < now() - interval '1 day'`
+			_, err = db.Exec(ctx, rejectionsQ)
+			if err != nil {
+				log.Error(ctx, err)
+			}
 		case <-ctx.Done():
 			ticker.Stop()
 			return
@@ -172,16 +341,54 @@ func CleanupSubmittedTxs(ctx context.Context, db pg.DB) {
 	}
 }
 
-// finalizeTxWait calls FinalizeTx and then waits for confirmation of
-// the transaction.  A nil error return means the transaction is
-// confirmed on the blockchain.  ErrRejected means a conflicting tx is
-// on the blockchain.  context.DeadlineExceeded means ctx is an
-// expiring context that timed out.
-func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template, waitUntil string) error {
+// finalizeTxWait calls FinalizeTx and then waits for the
+// transaction to reach the stage given by x.WaitUntil, allowing
+// each stage its own timeout. It returns the status reached.
+// ErrRejected means a conflicting tx is on the blockchain.
+// context.DeadlineExceeded means a stage timed out; the error's
+// data includes the status reached before it did.
+func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Template, x *submitArg) (string, error) {
 	if txTemplate.Transaction == nil {
-		return errors.Wrap(txbuilder.ErrMissingRawTx)
+		return "", errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+
+	tx := bc.NewTx(*txTemplate.Transaction)
+	height, err := h.finalizeTx(ctx, tx, x.WaitTimeouts.None)
+	if err != nil {
+		return "", err
+	}
+	if x.WaitUntil == waitNone {
+		return statusAccepted, nil
+	}
+
+	stageCtx, cancel := withStageTimeout(ctx, x.WaitTimeouts.Processed, defaultProcessedTimeout)
+	height, err = h.waitForTxInBlock(stageCtx, tx, height)
+	cancel()
+	if errors.Root(err) == context.DeadlineExceeded {
+		return "", errors.WithData(err, "status", statusAccepted)
+	} else if err != nil {
+		return "", err
+	}
+	if x.WaitUntil == waitProcessed {
+		return statusProcessed, nil
+	}
+
+	stageCtx, cancel = withStageTimeout(ctx, x.WaitTimeouts.Indexed, defaultIndexedTimeout)
+	defer cancel()
+	select {
+	case <-stageCtx.Done():
+		return "", errors.WithData(stageCtx.Err(), "status", statusProcessed)
+	case <-h.PinStore.AllWaiter(height):
 	}
 
+	return statusIndexed, nil
+}
+
+// finalizeTx records tx as submitted and sends it to the
+// generator, allowing timeout, or defaultAcceptTimeout if it's
+// zero, for the generator to accept it. It returns a height
+// below that of any block the tx can appear in.
+func (h *Handler) finalizeTx(ctx context.Context, tx *bc.Tx, timeout chainjson.Duration) (uint64, error) {
 	// Use the current generator height as the lower bound of the block height
 	// that the transaction may appear in.
 	generatorHeight, _ := fetch.GeneratorHeight()
@@ -190,36 +397,60 @@ func (h *Handler) finalizeTxWait(ctx context.Context, txTemplate *txbuilder.Temp
 		generatorHeight = localHeight
 	}
 
-	// Remember this height in case we retry this submit call.
-	tx := bc.NewTx(*txTemplate.Transaction)
-	height, err := recordSubmittedTx(ctx, h.DB, tx.Hash, generatorHeight)
+	// A tx built before one of its accounts was frozen
+	// must not get through.
+	err := h.Accounts.CheckFrozenSpends(ctx, tx)
 	if err != nil {
-		return errors.Wrap(err, "saving tx submitted height")
+		return 0, err
 	}
 
-	err = txbuilder.FinalizeTx(ctx, h.Chain, h.Submitter, tx)
+	// Count the tx against its accounts' spending limits,
+	// failing before it's sent if it would exceed one.
+	err = h.Accounts.RecordSpends(ctx, tx)
 	if err != nil {
-		return err
-	}
-	if waitUntil == "none" {
-		return nil
+		return 0, err
 	}
 
-	height, err = h.waitForTxInBlock(ctx, tx, height)
+	// Remember this height in case we retry this submit call.
+	height, err := recordSubmittedTx(ctx, h.DB, tx.Hash, generatorHeight)
 	if err != nil {
-		return err
+		return 0, errors.Wrap(err, "saving tx submitted height")
 	}
-	if waitUntil == "confirmed" {
-		return nil
+
+	// Queries can include the tx as pending until it lands.
+	// Failing to record it is no reason to fail the submit.
+	err = h.Indexer.IndexPendingTx(ctx, tx)
+	if err != nil {
+		log.Error(ctx, err)
 	}
 
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	case <-h.PinStore.AllWaiter(height):
+	stageCtx, cancel := withStageTimeout(ctx, timeout, defaultAcceptTimeout)
+	err = txbuilder.FinalizeTx(stageCtx, h.Chain, h.Submitter, tx)
+	cancel()
+	if err != nil {
+		if err := h.Indexer.RemovePendingTx(ctx, tx.Hash); err != nil {
+			log.Error(ctx, err)
+		}
+		if errors.Root(err) == txbuilder.ErrRejected {
+			if err := recordTxFailure(ctx, h.DB, tx, err); err != nil {
+				log.Error(ctx, err)
+			}
+			if err := h.Accounts.ReleaseSpends(ctx, tx.Hash); err != nil {
+				log.Error(ctx, err)
+			}
+		}
+		return 0, err
 	}
+	return height, nil
+}
 
-	return nil
+// withStageTimeout returns a context for one stage of waiting
+// for a submitted tx, expiring after d or, if d is zero, def.
+func withStageTimeout(ctx context.Context, d chainjson.Duration, def time.Duration) (context.Context, context.CancelFunc) {
+	if d.Duration <= 0 {
+		d.Duration = def
+	}
+	return context.WithTimeout(ctx, d.Duration)
 }
 
 func (h *Handler) waitForTxInBlock(ctx context.Context, tx *bc.Tx, height uint64) (uint64, error) {
@@ -242,7 +473,12 @@ func (h *Handler) waitForTxInBlock(ctx context.Context, tx *bc.Tx, height uint64
 			}
 
 			if tx.MaxTime > 0 && tx.MaxTime < b.TimestampMS {
-				return 0, errors.Wrap(txbuilder.ErrRejected, "transaction max time exceeded")
+				err = errors.WithData(txbuilder.ErrRejected, validation.KeyReason, validation.ReasonExpired)
+				return 0, errors.Wrap(err, "transaction max time exceeded")
+			}
+			err = h.finalRejection(b, tx)
+			if err != nil {
+				return 0, err
 			}
 
 			// might still be in pool or might be rejected; we can't
@@ -253,17 +489,62 @@ func (h *Handler) waitForTxInBlock(ctx context.Context, tx *bc.Tx, height uint64
 			if err != nil {
 				return 0, err
 			}
-
-			// TODO(jackson): Do simple rejection checks like checking if
-			// the tx's blockchain prevouts still exist in the state tree.
 		}
 	}
 }
 
+// finalRejection returns why tx, left out of block b, can
+// never be in a block: it spends an output that is gone, or
+// reuses an issuance nonce. Otherwise, it returns nil. It
+// needs the state after b, so it gives up, returning nil,
+// once the chain has moved past b.
+func (h *Handler) finalRejection(b *bc.Block, tx *bc.Tx) error {
+	latest, snapshot := h.Chain.State()
+	if latest == nil || snapshot == nil || latest.Height != b.Height {
+		return nil
+	}
+	err := h.Chain.CheckTxInBlock(snapshot, b, tx)
+	switch validation.Reason(err) {
+	case validation.ReasonDoubleSpend, validation.ReasonDuplicateIssuance:
+		return errors.Sub(txbuilder.ErrRejected, err)
+	}
+	return nil
+}
+
 type submitArg struct {
 	Transactions []txbuilder.Template
-	wait         chainjson.Duration
-	WaitUntil    string `json:"wait_until"` // values none, confirmed, processed. default: processed
+	WaitUntil    string `json:"wait_until"` // values none, processed, indexed. default: indexed
+
+	// ClientToken, if set, makes the request safe to retry.
+	// The result of each transaction that was submitted
+	// successfully is kept, and a retry with the same token
+	// gets that result back instead of submitting it again.
+	ClientToken string `json:"client_token"`
+
+	// WaitTimeouts bounds the time spent on each stage
+	// of waiting, keyed by the mode that ends there.
+	WaitTimeouts waitTimeouts `json:"wait_timeouts"`
+}
+
+type waitTimeouts struct {
+	None      chainjson.Duration `json:"none"`
+	Processed chainjson.Duration `json:"processed"`
+	Indexed   chainjson.Duration `json:"indexed"`
+}
+
+// checkWaitUntil validates x.WaitUntil,
+// replacing an empty or deprecated value.
+func (x *submitArg) checkWaitUntil() error {
+	switch x.WaitUntil {
+	case "":
+		x.WaitUntil = waitIndexed
+	case "confirmed":
+		x.WaitUntil = waitProcessed // DEPRECATED: use processed instead
+	case waitNone, waitProcessed, waitIndexed:
+	default:
+		return errors.WithDetailf(httpjson.ErrBadRequest, "invalid wait_until %q", x.WaitUntil)
+	}
+	return nil
 }
 
 // POST /submit-transaction
@@ -274,32 +555,194 @@ func (h *Handler) submit(ctx context.Context, x submitArg) (interface{}, error)
 		return resp, err
 	}
 
-	// Setup a timeout for the provided wait duration.
-	timeout := x.wait.Duration
-	if timeout <= 0 {
-		timeout = 30 * time.Second
+	err := x.checkWaitUntil()
+	if err != nil {
+		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
 
-	responses := make([]interface{}, len(x.Transactions))
-	var wg sync.WaitGroup
-	wg.Add(len(responses))
-	for i := range responses {
-		go func(i int) {
-			subctx := reqid.NewSubContext(ctx, reqid.New())
-			defer wg.Done()
-			defer batchRecover(subctx, &responses[i])
+	return runBatch(ctx, len(x.Transactions), func(ctx context.Context, i int) (interface{}, error) {
+		if x.ClientToken != "" {
+			return h.submitWithToken(ctx, i, &x)
+		}
+		return h.submitSingle(ctx, &x.Transactions[i], &x)
+	})
+}
+
+// submitWithToken submits the ith transaction of x, unless a
+// request with the same client token submitted it within the
+// client token window, in which case it returns that result.
+// Client tokens are scoped to the access token used, so clients
+// can't see or collide with one another's submissions.
+func (h *Handler) submitWithToken(ctx context.Context, i int, x *submitArg) (interface{}, error) {
+	tpl := &x.Transactions[i]
+	if tpl.Transaction == nil {
+		return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+	}
+	txHash := tpl.Transaction.Hash()
+	tokenID, _, _ := httpjson.Request(ctx).BasicAuth()
+
+	window := h.ClientTokenWindow
+	if window == 0 {
+		window = DefaultClientTokenWindow
+	}
+	const selectQ = `
+		SELECT tx_hash, response FROM submit_client_tokens
+		WHERE access_token_id = $1 AND client_token = $2 AND position = $3
+		AND created_at > now() - $4 * interval '1 second'
+	`
+	var (
+		prevHash []byte
+		prevResp []byte
+	)
+	err := h.DB.QueryRow(ctx, selectQ, tokenID, x.ClientToken, i, int64(window/time.Second)).Scan(&prevHash, &prevResp)
+	if err == nil {
+		if !bytes.Equal(prevHash, txHash[:]) {
+			return nil, errors.WithDetailf(errClientTokenReused, "transaction %d was %x", i, prevHash)
+		}
+		return json.RawMessage(prevResp), nil
+	} else if err != stdsql.ErrNoRows {
+		return nil, errors.Wrap(err, "looking up client token")
+	}
+
+	resp, err := h.submitSingle(ctx, tpl, x)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	// An expired result for the same token is replaced.
+	const insertQ = `
+		INSERT INTO submit_client_tokens (access_token_id, client_token, position, tx_hash, response)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (access_token_id, client_token, position) DO UPDATE
+		SET tx_hash = excluded.tx_hash, response = excluded.response, created_at = now()
+		WHERE submit_client_tokens.created_at <= now() - $6 * interval '1 second'
+	`
+	_, err = h.DB.Exec(ctx, insertQ, tokenID, x.ClientToken, i, txHash[:], b, int64(window/time.Second))
+	if err != nil {
+		// The transaction is submitted either way;
+		// only a later retry would notice.
+		log.Error(ctx, errors.Wrap(err, "recording client token"))
+	}
+	return resp, nil
+}
 
-			tx, err := h.submitSingle(subctx, &x.Transactions[i], x.WaitUntil)
+// CleanupClientTokens periodically deletes submit results
+// kept for client tokens once they're older than window.
+// It blocks until ctx is cancelled.
+func CleanupClientTokens(ctx context.Context, db pg.DB, window time.Duration) {
+	if window == 0 {
+		window = DefaultClientTokenWindow
+	}
+	ticker := time.NewTicker(15 * time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			err := deleteExpiredClientTokens(ctx, db, window)
 			if err != nil {
-				responses[i] = err
-			} else {
-				responses[i] = tx
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+func deleteExpiredClientTokens(ctx context.Context, db pg.DB, window time.Duration) error {
+	const q = `DELETE FROM submit_client_tokens WHERE created_at < now() - $1 * interval '1 second'`
+	_, err := db.Exec(ctx, q, int64(window/time.Second))
+	return errors.Wrap(err, "deleting expired client tokens")
+}
+
+type inputRunResp struct {
+	Position  int    `json:"position"`
+	Valid     bool   `json:"valid"`
+	RunCost   int64  `json:"run_cost"`
+	SigChecks int64  `json:"sig_checks"`
+	Error     string `json:"error,omitempty"`
+}
+
+type validateResp struct {
+	Valid  bool            `json:"valid"`
+	Cost   validation.Cost `json:"cost"`
+	Inputs []inputRunResp  `json:"inputs"`
+	Error  *detailedError  `json:"error,omitempty"`
+}
+
+// validateTransactions validates signed transaction templates
+// against the current blockchain state, running every input
+// script, without submitting them. Each result says whether
+// the transaction could go in the next block, and reports the
+// outcome and cost of each input script.
+//
+// POST /validate-transaction
+func (h *Handler) validateTransactions(ctx context.Context, x struct {
+	Transactions []txbuilder.Template `json:"transactions"`
+}) (interface{}, error) {
+	<-h.Chain.BlockWaiter(1)
+	return runBatch(ctx, len(x.Transactions), func(ctx context.Context, i int) (interface{}, error) {
+		tpl := &x.Transactions[i]
+		if tpl.Transaction == nil {
+			return nil, errors.Wrap(txbuilder.ErrMissingRawTx)
+		}
+		d := h.Chain.DryRunTx(bc.NewTx(*tpl.Transaction))
+		resp := &validateResp{
+			Valid:  d.Err == nil,
+			Cost:   d.Cost,
+			Inputs: make([]inputRunResp, 0, len(d.Inputs)),
+		}
+		for i, run := range d.Inputs {
+			r := inputRunResp{
+				Position:  i,
+				Valid:     run.Err == nil,
+				RunCost:   run.Cost.RunCost,
+				SigChecks: run.Cost.SigChecks,
+			}
+			if run.Err != nil {
+				r.Error = run.Err.Error()
+			}
+			resp.Inputs = append(resp.Inputs, r)
+		}
+		if d.Err != nil {
+			err := d.Err
+			if errors.Root(err) == validation.ErrBadTx {
+				err = errors.Sub(txbuilder.ErrRejected, err)
 			}
-		}(i)
+			body, _ := errInfo(err)
+			resp.Error = &body
+		}
+		return resp, nil
+	})
+}
+
+type submitRawArg struct {
+	RawTransaction *bc.TxData   `json:"raw_transaction"`
+	WaitUntil      string       `json:"wait_until"`
+	WaitTimeouts   waitTimeouts `json:"wait_timeouts"`
+}
+
+// submitRaw submits a fully formed, signed transaction built
+// outside the core, with no template. It's validated locally
+// before it's sent to the generator, like any other.
+//
+// POST /submit-raw-transaction
+func (h *Handler) submitRaw(ctx context.Context, x submitRawArg) (interface{}, error) {
+	if !leader.IsLeading() {
+		var resp json.RawMessage
+		err := h.forwardToLeader(ctx, "/submit-raw-transaction", x, &resp)
+		return resp, err
 	}
 
-	wg.Wait()
-	return responses, nil
+	if x.RawTransaction == nil {
+		return nil, errors.WithDetail(httpjson.ErrBadRequest, "raw_transaction is required")
+	}
+	arg := &submitArg{WaitUntil: x.WaitUntil, WaitTimeouts: x.WaitTimeouts}
+	err := arg.checkWaitUntil()
+	if err != nil {
+		return nil, err
+	}
+	return h.submitSingle(ctx, &txbuilder.Template{Transaction: x.RawTransaction}, arg)
 }
@@ -1,7 +1,12 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,10 +14,14 @@ import (
 	"chain/core/asset"
 	"chain/core/coretest"
 	"chain/core/generator"
+	"chain/core/leader"
 	"chain/core/pin"
 	"chain/core/query"
 	"chain/core/txbuilder"
+	"chain/database/pg"
 	"chain/database/pg/pgtest"
+	"chain/errors"
+	"chain/net/http/httpjson"
 	"chain/protocol/bc"
 	"chain/protocol/prottest"
 	"chain/testutil"
@@ -73,6 +82,229 @@ func TestAccountTransferSpendChange(t *testing.T) {
 	}
 }
 
+func TestSubmitFrozenAccount(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	assets := asset.NewRegistry(db, c, pinStore)
+	accounts := account.NewManager(db, c, pinStore)
+	coretest.CreatePins(ctx, t, pinStore)
+	accounts.IndexAccounts(query.NewIndexer(db, c, pinStore))
+	go accounts.ProcessBlocks(ctx)
+	h := &Handler{DB: db, Chain: c, Submitter: g, Accounts: accounts}
+
+	acc := coretest.CreateAccount(ctx, t, accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, assets, nil, "", nil)
+	coretest.IssueAssets(ctx, t, c, g, assets, accounts, assetID, 100, acc)
+	prottest.MakeBlock(t, c, g.PendingTxs())
+	<-pinStore.PinWaiter(account.PinName, c.Height())
+
+	// Build a spend before the account is frozen.
+	assetAmt := bc.AssetAmount{AssetID: assetID, Amount: 100}
+	source := accounts.NewSpendAction(assetAmt, acc, nil, nil)
+	dest := accounts.NewControlAction(assetAmt, acc, nil)
+	tmpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{source, dest}, time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+
+	_, err = accounts.Freeze(ctx, acc, "legal_hold", "alice")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// The freeze canceled the spend's reservation...
+	_, _, err = accounts.ExtendReservations(ctx, tmpl.Transaction, time.Now().Add(time.Minute))
+	if errors.Root(err) != account.ErrNotReserved {
+		t.Errorf("extend reservations: got error %v want %v", err, account.ErrNotReserved)
+	}
+
+	// ...and the signed transaction is refused.
+	_, err = h.submitSingle(ctx, tmpl, &submitArg{WaitUntil: waitNone})
+	if errors.Root(err) != account.ErrFrozen {
+		t.Errorf("submit: got error %v want %v", err, account.ErrFrozen)
+	}
+	if n := len(g.PendingTxs()); n != 0 {
+		t.Errorf("got %d pending txs, want 0", n)
+	}
+}
+
+func TestSubmitRaw(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	h := &Handler{
+		Chain:     c,
+		Submitter: g,
+		Assets:    asset.NewRegistry(db, c, pinStore),
+		Accounts:  account.NewManager(db, c, pinStore),
+		Indexer:   query.NewIndexer(db, c, pinStore),
+		DB:        db,
+	}
+
+	// TODO(jackson): Replace this with a mock leader.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go leader.Run(db, ":1999", func(ctx context.Context) {
+		wg.Done()
+	})
+	wg.Wait()
+
+	acc := coretest.CreateAccount(ctx, t, h.Accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, h.Assets, nil, "", nil)
+	assetAmt := bc.AssetAmount{AssetID: assetID, Amount: 100}
+	tmpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+		h.Assets.NewIssueAction(assetAmt, nil),
+		h.Accounts.NewControlAction(assetAmt, acc, nil),
+	}, time.Now().Add(time.Minute))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = h.submitRaw(ctx, submitRawArg{WaitUntil: waitNone})
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("missing raw_transaction: got error %v want %v", err, httpjson.ErrBadRequest)
+	}
+	_, err = h.submitRaw(ctx, submitRawArg{RawTransaction: tmpl.Transaction, WaitUntil: "someday"})
+	if errors.Root(err) != httpjson.ErrBadRequest {
+		t.Errorf("invalid wait_until: got error %v want %v", err, httpjson.ErrBadRequest)
+	}
+
+	// Unsigned, the tx commits to nothing.
+	_, err = h.submitRaw(ctx, submitRawArg{RawTransaction: tmpl.Transaction, WaitUntil: waitNone})
+	if errors.Root(err) != txbuilder.ErrNoTxSighashCommitment {
+		t.Errorf("unsigned tx: got error %v want %v", err, txbuilder.ErrNoTxSighashCommitment)
+	}
+
+	coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+	resp, err := h.submitRaw(ctx, submitRawArg{RawTransaction: tmpl.Transaction, WaitUntil: waitNone})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := map[string]string{
+		"id":     tmpl.Transaction.Hash().String(),
+		"status": statusAccepted,
+	}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("submitRaw = %v want %v", resp, want)
+	}
+	if pending := g.PendingTxs(); len(pending) != 1 || pending[0].Hash != tmpl.Transaction.Hash() {
+		t.Errorf("pending txs = %v, want [%s]", pending, tmpl.Transaction.Hash())
+	}
+}
+
+func TestSubmitWithToken(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	g := generator.New(c, nil, db)
+	pinStore := pin.NewStore(db)
+	coretest.CreatePins(ctx, t, pinStore)
+	h := &Handler{
+		Chain:     c,
+		Submitter: g,
+		Assets:    asset.NewRegistry(db, c, pinStore),
+		Accounts:  account.NewManager(db, c, pinStore),
+		Indexer:   query.NewIndexer(db, c, pinStore),
+		DB:        db,
+	}
+
+	acc := coretest.CreateAccount(ctx, t, h.Accounts, "", nil)
+	assetID := coretest.CreateAsset(ctx, t, h.Assets, nil, "", nil)
+	build := func(amount uint64) txbuilder.Template {
+		assetAmt := bc.AssetAmount{AssetID: assetID, Amount: amount}
+		tmpl, err := txbuilder.Build(ctx, nil, []txbuilder.Action{
+			h.Assets.NewIssueAction(assetAmt, nil),
+			h.Accounts.NewControlAction(assetAmt, acc, nil),
+		}, time.Now().Add(time.Minute))
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		coretest.SignTxTemplate(t, ctx, tmpl, &testutil.TestXPrv)
+		return *tmpl
+	}
+	tx1, tx2 := build(100), build(200)
+	withToken := func(id string) context.Context {
+		req, _ := http.NewRequest("POST", "/submit-transaction", nil)
+		req.SetBasicAuth(id, "secret")
+		return httpjson.WithRequest(ctx, req)
+	}
+	alice, bob := withToken("alice"), withToken("bob")
+	submit := func(ctx context.Context, tpl txbuilder.Template) (interface{}, error) {
+		x := &submitArg{
+			Transactions: []txbuilder.Template{tpl},
+			WaitUntil:    waitNone,
+			ClientToken:  "token",
+		}
+		return h.submitWithToken(ctx, 0, x)
+	}
+
+	resp, err := submit(alice, tx1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry gets the stored response back.
+	resp, err = submit(alice, tx1)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if raw, ok := resp.(json.RawMessage); !ok || !bytes.Equal(raw, want) {
+		t.Errorf("retry = %s want stored response %s", resp, want)
+	}
+
+	// Reusing the token for another tx is an error...
+	_, err = submit(alice, tx2)
+	if errors.Root(err) != errClientTokenReused {
+		t.Errorf("reuse: got error %v want %v", err, errClientTokenReused)
+	}
+
+	// ...but not for another access token.
+	_, err = submit(bob, tx2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Once the window has passed, the token can be reused.
+	_, err = db.Exec(ctx, `UPDATE submit_client_tokens SET created_at = now() - interval '2 days' WHERE access_token_id = 'alice'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = submit(alice, tx2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	_, err = db.Exec(ctx, `UPDATE submit_client_tokens SET created_at = now() - interval '2 days' WHERE access_token_id = 'bob'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = deleteExpiredClientTokens(ctx, db, DefaultClientTokenWindow)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	var ids []string
+	err = pg.ForQueryRows(ctx, db, `SELECT access_token_id FROM submit_client_tokens`, func(id string) {
+		ids = append(ids, id)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"alice"}) {
+		t.Errorf("client tokens after cleanup = %v want [alice]", ids)
+	}
+}
+
 func TestRecordSubmittedTxs(t *testing.T) {
 	ctx := context.Background()
 	dbtx := pgtest.NewTx(t)
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	stdsql "database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/validation"
+	"chain/protocol/vm"
+)
+
+// txFailure describes why a submitted transaction failed
+// validation in the VM. Failures are kept for a day.
+type txFailure struct {
+	ID            bc.Hash              `json:"id"`
+	InputPosition uint32               `json:"input_position"`
+	Error         string               `json:"error"`
+	Program       chainjson.HexBytes   `json:"program"`
+	ProgramAsm    string               `json:"program_asm"`
+	PC            uint32               `json:"pc"`
+	Stack         []chainjson.HexBytes `json:"stack"`
+	FailedAt      time.Time            `json:"failed_at"`
+}
+
+// recordTxFailure saves the VM state at the point a rejected
+// transaction's program failed, if err blames one of its inputs.
+func recordTxFailure(ctx context.Context, db pg.DB, tx *bc.Tx, err error) error {
+	index, ok := errors.Data(err)[errors.KeyInputIndex].(int)
+	if !ok || index < 0 || index >= len(tx.Inputs) {
+		return nil
+	}
+	f := vm.InspectTxInput(tx, uint32(index))
+	if f == nil {
+		return nil
+	}
+	msg := validation.ErrFalseVMResult.Error()
+	if f.Err != nil {
+		msg = f.Err.Error()
+	}
+
+	const q = `
+		INSERT INTO tx_failures (tx_hash, input_index, error, program, pc, stack)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tx_hash) DO UPDATE
+		SET input_index = excluded.input_index, error = excluded.error, program = excluded.program,
+			pc = excluded.pc, stack = excluded.stack, failed_at = excluded.failed_at
+	`
+	// A program can fail before it runs,
+	// while its arguments are pushed.
+	prog, stack := f.Prog, pq.ByteaArray(f.Stack)
+	if prog == nil {
+		prog = []byte{}
+	}
+	if stack == nil {
+		stack = pq.ByteaArray{}
+	}
+	_, err = db.Exec(ctx, q, tx.Hash[:], index, msg, prog, f.PC, stack)
+	return errors.Wrap(err, "saving tx failure")
+}
+
+// getTransactionFailure returns the VM state at which
+// a transaction submitted in the last day failed.
+//
+// POST /get-transaction-failure
+func (h *Handler) getTransactionFailure(ctx context.Context, x struct {
+	ID bc.Hash `json:"id"`
+}) (*txFailure, error) {
+	const q = `
+		SELECT input_index, error, program, pc, stack, failed_at FROM tx_failures
+		WHERE tx_hash = $1 AND failed_at > now() - interval '1 day'
+	`
+	f := &txFailure{ID: x.ID}
+	var stack pq.ByteaArray
+	err := h.DB.QueryRow(ctx, q, x.ID[:]).Scan(&f.InputPosition, &f.Error, &f.Program, &f.PC, &stack, &f.FailedAt)
+	if err == stdsql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "no failure recorded for tx %s", x.ID)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading tx failure")
+	}
+	// A program that doesn't disassemble is
+	// still returned in hex.
+	f.ProgramAsm, _ = vm.Disassemble(f.Program)
+	f.Stack = make([]chainjson.HexBytes, 0, len(stack))
+	for _, item := range stack {
+		f.Stack = append(f.Stack, item)
+	}
+	return f, nil
+}
@@ -3,9 +3,12 @@ package txbuilder
 import (
 	"context"
 	stdjson "encoding/json"
+	"time"
 
 	"chain/encoding/json"
+	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/vmutil"
 )
 
 func DecodeControlProgramAction(data []byte) (Action, error) {
@@ -36,6 +39,82 @@ func (a *controlProgramAction) Build(ctx context.Context, b *TemplateBuilder) er
 	return b.AddOutput(out)
 }
 
+func DecodeControlTimeLockedAction(data []byte) (Action, error) {
+	a := new(controlTimeLockedAction)
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+// controlTimeLockedAction pays to a control program
+// that can't spend until a block height or time.
+type controlTimeLockedAction struct {
+	bc.AssetAmount
+	Program       json.HexBytes `json:"control_program"`
+	UnlockHeight  uint64        `json:"unlock_height"`
+	UnlockTime    *time.Time    `json:"unlock_time"`
+	ReferenceData json.Map      `json:"reference_data"`
+}
+
+func (a *controlTimeLockedAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if len(a.Program) == 0 {
+		missing = append(missing, "control_program")
+	}
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if a.UnlockHeight == 0 && a.UnlockTime == nil {
+		missing = append(missing, "unlock_height", "unlock_time")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	var unlockTime uint64
+	if a.UnlockTime != nil {
+		if a.UnlockTime.Before(time.Unix(0, 0)) {
+			return errors.WithDetail(ErrBadTimeLock, "unlock_time must be after 1970")
+		}
+		unlockTime = bc.Millis(*a.UnlockTime)
+	}
+	prog, err := vmutil.TimeLockProgram(a.Program, a.UnlockHeight, unlockTime)
+	if err != nil {
+		return errors.WithDetail(ErrBadTimeLock, errors.Detail(err))
+	}
+	out := bc.NewTxOutput(a.AssetID, a.Amount, prog, a.ReferenceData)
+	out.VMVersion = vmutil.TimeLockVMVersion
+	return b.AddOutput(out)
+}
+
+func DecodePayFeeAction(data []byte) (Action, error) {
+	a := new(payFeeAction)
+	err := stdjson.Unmarshal(data, a)
+	return a, err
+}
+
+// payFeeAction pays a transaction fee by sending
+// the amount to the fee program, retiring it.
+type payFeeAction struct {
+	bc.AssetAmount
+	ReferenceData json.Map `json:"reference_data"`
+}
+
+func (a *payFeeAction) Build(ctx context.Context, b *TemplateBuilder) error {
+	var missing []string
+	if a.AssetID == (bc.AssetID{}) {
+		missing = append(missing, "asset_id")
+	}
+	if a.Amount == 0 {
+		missing = append(missing, "amount")
+	}
+	if len(missing) > 0 {
+		return MissingFieldsError(missing...)
+	}
+
+	out := bc.NewTxOutput(a.AssetID, a.Amount, vmutil.FeeProgram(), a.ReferenceData)
+	return b.AddOutput(out)
+}
+
 func DecodeSetTxRefDataAction(data []byte) (Action, error) {
 	a := new(setTxRefDataAction)
 	err := stdjson.Unmarshal(data, a)
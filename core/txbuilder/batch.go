@@ -0,0 +1,109 @@
+package txbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// batchSubmitTimeout bounds the submit-batch RPC. The batch is
+// shared by many callers, so it can't use any one caller's context.
+const batchSubmitTimeout = 30 * time.Second
+
+// A submitBatch collects transactions to send to the generator in
+// one RPC. Once the RPC completes, errs holds the result for each
+// transaction and done is closed.
+type submitBatch struct {
+	txs  []*bc.Tx
+	done chan struct{}
+	errs []error
+}
+
+// batchResult is the generator's result for one transaction
+// of a submit-batch RPC. Code is empty if it was accepted.
+type batchResult struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+}
+
+func (rg *RemoteGenerator) submitBatched(ctx context.Context, tx *bc.Tx) error {
+	rg.mu.Lock()
+	b := rg.batch
+	if b == nil {
+		b = &submitBatch{done: make(chan struct{})}
+		rg.batch = b
+		time.AfterFunc(rg.BatchWindow, func() { rg.flush(b) })
+	}
+	i := len(b.txs)
+	b.txs = append(b.txs, tx)
+	full := rg.MaxBatch > 0 && len(b.txs) >= rg.MaxBatch
+	rg.mu.Unlock()
+
+	if full {
+		rg.flush(b)
+	}
+
+	select {
+	case <-b.done:
+		return b.errs[i]
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err())
+	}
+}
+
+// flush sends b to the generator, unless it has already been
+// sent. Either way, it stops b from accepting more transactions.
+func (rg *RemoteGenerator) flush(b *submitBatch) {
+	rg.mu.Lock()
+	if rg.batch != b {
+		rg.mu.Unlock()
+		return // already sent
+	}
+	rg.batch = nil
+	rg.mu.Unlock()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), batchSubmitTimeout)
+		defer cancel()
+		var resp json.RawMessage
+		err := rg.Peer.CallHedged(ctx, "/rpc/submit-batch", b.txs, &resp)
+		if err != nil {
+			err = errors.Wrap(err, "generator transaction batch notice")
+			b.errs = make([]error, len(b.txs))
+			for i := range b.errs {
+				b.errs[i] = err
+			}
+		} else {
+			b.errs = batchErrors(resp, len(b.txs))
+		}
+		close(b.done)
+	}()
+}
+
+// batchErrors returns the error for each of n transactions
+// from the generator's response to a submit-batch RPC.
+func batchErrors(resp json.RawMessage, n int) []error {
+	errs := make([]error, n)
+	var results []batchResult
+	if json.Unmarshal(resp, &results) != nil {
+		// Generators that predate per-transaction results
+		// fail the whole call if any transaction fails.
+		return errs
+	}
+	for i := range errs {
+		switch {
+		case i >= len(results):
+			errs[i] = errors.New("generator sent no result for transaction")
+		case results[i].Code != "":
+			r := results[i]
+			err := fmt.Errorf("generator rejected transaction: %s: %s", r.Code, r.Message)
+			errs[i] = errors.WithDetail(err, r.Detail)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,112 @@
+package txbuilder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chain/core/rpc"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+func TestRemoteGeneratorBatch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]*bc.Tx
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/rpc/submit-batch" {
+			t.Errorf("got=%s want=/rpc/submit-batch", req.URL.Path)
+		}
+		var txs []*bc.Tx
+		err := json.NewDecoder(req.Body).Decode(&txs)
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		batches = append(batches, txs)
+		mu.Unlock()
+		rw.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	rg := &RemoteGenerator{
+		Peer:        &rpc.Client{BaseURL: server.URL},
+		BatchWindow: time.Hour, // only flush when the batch is full
+		MaxBatch:    3,
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		tx := bc.NewTx(bc.TxData{Version: 1, MinTime: uint64(i)})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := rg.Submit(context.Background(), tx)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(batches) != 1 {
+		t.Fatalf("got %d batches want 1", len(batches))
+	}
+	if len(batches[0]) != 3 {
+		t.Errorf("got %d txs in batch want 3", len(batches[0]))
+	}
+}
+
+func TestRemoteGeneratorBatchResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var txs []*bc.Tx
+		err := json.NewDecoder(req.Body).Decode(&txs)
+		if err != nil {
+			t.Error(err)
+		}
+		// Reject the transaction with MinTime 1.
+		results := make([]interface{}, len(txs))
+		for i, tx := range txs {
+			results[i] = struct{}{}
+			if tx.MinTime == 1 {
+				results[i] = batchResult{Code: "CH735", Message: "Transaction rejected", Detail: "bad"}
+			}
+		}
+		json.NewEncoder(rw).Encode(results)
+	}))
+	defer server.Close()
+
+	rg := &RemoteGenerator{
+		Peer:        &rpc.Client{BaseURL: server.URL},
+		BatchWindow: time.Hour,
+		MaxBatch:    3,
+	}
+
+	errs := make([]error, 3)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		tx := bc.NewTx(bc.TxData{Version: 1, MinTime: uint64(i)})
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = rg.Submit(context.Background(), tx)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i == 1 {
+			if err == nil || errors.Detail(err) != "bad" {
+				t.Errorf("tx %d: err = %v want rejection with detail %q", i, err, "bad")
+			}
+		} else if err != nil {
+			t.Errorf("tx %d: err = %v want nil", i, err)
+		}
+	}
+}
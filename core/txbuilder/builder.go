@@ -16,6 +16,7 @@ type TemplateBuilder struct {
 	signingInstructions []*SigningInstruction
 	minTime             time.Time
 	maxTime             time.Time
+	reservationExpiry   time.Time
 	referenceData       []byte
 	rollbacks           []func()
 	callbacks           []func() error
@@ -48,6 +49,16 @@ func (b *TemplateBuilder) MaxTime() time.Time {
 	return b.maxTime
 }
 
+// ReservationExpiry returns the time until which actions
+// should reserve the outputs they spend. It is never later
+// than MaxTime.
+func (b *TemplateBuilder) ReservationExpiry() time.Time {
+	if b.reservationExpiry.IsZero() || b.reservationExpiry.After(b.maxTime) {
+		return b.maxTime
+	}
+	return b.reservationExpiry
+}
+
 // OnRollback registers a function that can be
 // used to attempt to undo any side effects of building
 // actions. For example, it might cancel any reservations
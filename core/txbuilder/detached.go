@@ -0,0 +1,148 @@
+package txbuilder
+
+import (
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// ErrBadSignature is returned by AddSignatures for a
+// signature that doesn't fit the template.
+var ErrBadSignature = errors.New("invalid signature")
+
+// A SigningHash is a hash that one key must sign to fill a
+// signature slot of a template. It lets a signing service
+// that never sees the transaction sign it.
+type SigningHash struct {
+	SigningInstruction int                  `json:"signing_instruction"`
+	WitnessComponent   int                  `json:"witness_component"`
+	KeyIndex           int                  `json:"key_index"`
+	XPub               chainkd.XPub         `json:"xpub"`
+	DerivationPath     []chainjson.HexBytes `json:"derivation_path"`
+	Hash               bc.Hash              `json:"hash"`
+}
+
+// A DetachedSignature is a signature of a SigningHash,
+// identified by the slot it fills.
+type DetachedSignature struct {
+	SigningInstruction int                `json:"signing_instruction"`
+	WitnessComponent   int                `json:"witness_component"`
+	KeyIndex           int                `json:"key_index"`
+	Signature          chainjson.HexBytes `json:"signature"`
+}
+
+// A PendingSignature is a signature witness component of a
+// template that doesn't yet have its quorum of signatures.
+type PendingSignature struct {
+	SigningInstruction int    `json:"signing_instruction"`
+	WitnessComponent   int    `json:"witness_component"`
+	Position           uint32 `json:"position"`
+	Quorum             int    `json:"quorum"`
+	Signed             int    `json:"signed"`
+
+	// Keys are the keys that could still sign.
+	Keys []KeyID `json:"keys"`
+}
+
+// PendingSignatures returns a PendingSignature for each
+// signature witness component of tpl short of its quorum.
+// Unlike SigningHashes, it doesn't change tpl.
+func PendingSignatures(tpl *Template) []PendingSignature {
+	var pending []PendingSignature
+	for i, sigInst := range tpl.SigningInstructions {
+		for j, c := range sigInst.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok {
+				continue
+			}
+			p := PendingSignature{
+				SigningInstruction: i,
+				WitnessComponent:   j,
+				Position:           sigInst.Position,
+				Quorum:             sw.Quorum,
+				Keys:               []KeyID{},
+			}
+			for k, keyID := range sw.Keys {
+				if k < len(sw.Sigs) && len(sw.Sigs[k]) > 0 {
+					p.Signed++
+				} else {
+					p.Keys = append(p.Keys, keyID)
+				}
+			}
+			if p.Signed < p.Quorum {
+				pending = append(pending, p)
+			}
+		}
+	}
+	return pending
+}
+
+// SigningHashes returns a SigningHash for every unfilled
+// signature slot of tpl. As Sign does, it fills in any empty
+// signature programs, so the hashes depend only on tpl.
+func SigningHashes(tpl *Template) ([]SigningHash, error) {
+	var hashes []SigningHash
+	for i, sigInst := range tpl.SigningInstructions {
+		for j, c := range sigInst.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok {
+				continue
+			}
+			h, err := sw.sigHash(tpl, uint32(i))
+			if err != nil {
+				err = errors.WithDetailf(err, "witness component %d", j)
+				return nil, errors.WithData(err, errors.KeyInputIndex, sigInst.Position)
+			}
+			for k, keyID := range sw.Keys {
+				if len(sw.Sigs[k]) > 0 {
+					continue
+				}
+				hashes = append(hashes, SigningHash{
+					SigningInstruction: i,
+					WitnessComponent:   j,
+					KeyIndex:           k,
+					XPub:               keyID.XPub,
+					DerivationPath:     keyID.DerivationPath,
+					Hash:               bc.Hash(h),
+				})
+			}
+		}
+	}
+	return hashes, nil
+}
+
+// AddSignatures puts signatures made outside the core, of
+// hashes returned by SigningHashes, into tpl. Each signature
+// is checked against the key of its slot. Like Sign, it
+// updates the witnesses of the transaction in tpl.
+func AddSignatures(tpl *Template, sigs []DetachedSignature) error {
+	for n, sig := range sigs {
+		if sig.SigningInstruction < 0 || sig.SigningInstruction >= len(tpl.SigningInstructions) {
+			return errors.WithDetailf(ErrBadSignature, "signature %d: no signing instruction %d", n, sig.SigningInstruction)
+		}
+		sigInst := tpl.SigningInstructions[sig.SigningInstruction]
+		if sig.WitnessComponent < 0 || sig.WitnessComponent >= len(sigInst.WitnessComponents) {
+			return errors.WithDetailf(ErrBadSignature, "signature %d: no witness component %d", n, sig.WitnessComponent)
+		}
+		sw, ok := sigInst.WitnessComponents[sig.WitnessComponent].(*SignatureWitness)
+		if !ok || sig.KeyIndex < 0 || sig.KeyIndex >= len(sw.Keys) {
+			return errors.WithDetailf(ErrBadSignature, "signature %d: no key %d", n, sig.KeyIndex)
+		}
+
+		h, err := sw.sigHash(tpl, uint32(sig.SigningInstruction))
+		if err != nil {
+			return errors.WithData(err, errors.KeyInputIndex, sigInst.Position)
+		}
+		keyID := sw.Keys[sig.KeyIndex]
+		var path [][]byte
+		for _, p := range keyID.DerivationPath {
+			path = append(path, p)
+		}
+		if !keyID.XPub.Derive(path).Verify(h[:], sig.Signature) {
+			return errors.WithDetailf(ErrBadSignature, "signature %d does not match its key and hash", n)
+		}
+		sw.Sigs[sig.KeyIndex] = sig.Signature
+	}
+	return materializeWitnesses(tpl)
+}
@@ -0,0 +1,72 @@
+package txbuilder
+
+import (
+	"testing"
+
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestDetachedSignatures(t *testing.T) {
+	path := []chainjson.HexBytes{{1}, {2}}
+	tpl := &Template{
+		Transaction: &bc.TxData{
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{}, 1, nil, bc.AssetID{}, 123, nil, nil),
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+			},
+		},
+		SigningInstructions: []*SigningInstruction{{
+			WitnessComponents: []WitnessComponent{
+				&SignatureWitness{
+					Quorum: 1,
+					Keys:   []KeyID{{XPub: testutil.TestXPub, DerivationPath: path}},
+				},
+			},
+		}},
+	}
+
+	if pending := PendingSignatures(tpl); len(pending) != 1 || pending[0].Signed != 0 || len(pending[0].Keys) != 1 {
+		t.Errorf("before signing, pending signatures = %+v, want one with one key", pending)
+	}
+
+	hashes, err := SigningHashes(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 1 {
+		t.Fatalf("got %d signing hashes, want 1", len(hashes))
+	}
+	h := hashes[0]
+
+	sig := DetachedSignature{SigningInstruction: h.SigningInstruction, WitnessComponent: h.WitnessComponent, KeyIndex: h.KeyIndex}
+	sig.Signature = testutil.TestXPrv.Derive([][]byte{{1}, {2}}).Sign([]byte("not the hash"))
+	err = AddSignatures(tpl, []DetachedSignature{sig})
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("adding signature of the wrong message: got error %v want %v", err, ErrBadSignature)
+	}
+
+	sig.Signature = testutil.TestXPrv.Derive([][]byte{{1}, {2}}).Sign(h.Hash[:])
+	err = AddSignatures(tpl, []DetachedSignature{sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args := tpl.Transaction.Inputs[0].Arguments(); len(args) != 3 {
+		t.Errorf("input has %d witness arguments, want 3", len(args))
+	}
+
+	hashes, err = SigningHashes(tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 0 {
+		t.Errorf("got %d signing hashes after signing, want 0", len(hashes))
+	}
+	if pending := PendingSignatures(tpl); len(pending) != 0 {
+		t.Errorf("after signing, pending signatures = %+v, want none", pending)
+	}
+}
@@ -0,0 +1,129 @@
+package txbuilder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/crypto/sha3pool"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// ErrBadEffects is returned by VerifyEffects when a template's
+// effects don't match its transaction or their signatures.
+var ErrBadEffects = errors.New("invalid transaction effects")
+
+// effectsHeader is the first line of every effects text.
+// It changes whenever the format of the text does.
+const effectsHeader = "chain transaction effects v1"
+
+// Effects is a human-readable statement of what a transaction
+// does: the asset amounts it issues, spends, pays and retires.
+// Hash commits to the text and to the transaction's hash, and
+// keys that sign a template sign Hash along with it, so people
+// reviewing what a key signs can read the text instead of
+// decoding the transaction.
+type Effects struct {
+	Text       string             `json:"text"`
+	Hash       bc.Hash            `json:"hash"`
+	Signatures []EffectsSignature `json:"signatures,omitempty"`
+}
+
+// An EffectsSignature is a signature of an Effects hash
+// by the root key of XPub.
+type EffectsSignature struct {
+	XPub      chainkd.XPub       `json:"xpub"`
+	Signature chainjson.HexBytes `json:"signature"`
+}
+
+// Names holds local names of the assets and accounts of a
+// transaction, for use in its effects text. Accounts holds the
+// alias or ID of the account of each local control program,
+// keyed by the control program's bytes.
+type Names struct {
+	Assets   map[bc.AssetID]string
+	Accounts map[string]string
+}
+
+// NewEffects renders the effects of tx as text, one line per
+// input and output, in order, naming assets and accounts from
+// names where it can. The text depends only on tx and names.
+func NewEffects(tx *bc.TxData, names *Names) *Effects {
+	if names == nil {
+		names = new(Names)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(effectsHeader + "\n")
+	fmt.Fprintf(&buf, "transaction %s\n", tx.Hash())
+	for _, in := range tx.Inputs {
+		amt := names.assetAmount(in.AssetAmount())
+		if in.IsIssuance() {
+			fmt.Fprintf(&buf, "issue %s\n", amt)
+		} else {
+			o := in.Outpoint()
+			fmt.Fprintf(&buf, "spend %s from %s, output %s:%d\n", amt, names.program(in.ControlProgram()), o.Hash, o.Index)
+		}
+	}
+	for _, out := range tx.Outputs {
+		amt := names.assetAmount(out.AssetAmount)
+		if vmutil.IsUnspendable(out.ControlProgram) {
+			fmt.Fprintf(&buf, "retire %s\n", amt)
+		} else {
+			fmt.Fprintf(&buf, "pay %s to %s\n", amt, names.program(out.ControlProgram))
+		}
+	}
+	text := buf.String()
+	return &Effects{Text: text, Hash: EffectsHash(tx.Hash(), text)}
+}
+
+// EffectsHash returns the hash of an effects text
+// of the transaction with the given hash.
+func EffectsHash(txHash bc.Hash, text string) (h bc.Hash) {
+	sha := sha3pool.Get256()
+	defer sha3pool.Put256(sha)
+	sha.Write([]byte("EffectsHash"))
+	sha.Write(txHash[:])
+	sha.Write([]byte(text))
+	sha.Read(h[:])
+	return h
+}
+
+// VerifyEffects checks that the effects of tpl are of its
+// transaction, and that their signatures are valid. It doesn't
+// check the text itself, which depends on the local names of
+// the core that rendered it.
+func VerifyEffects(tpl *Template) error {
+	e := tpl.Effects
+	if e == nil {
+		return errors.WithDetail(ErrBadEffects, "template has no effects")
+	}
+	if EffectsHash(tpl.Transaction.Hash(), e.Text) != e.Hash {
+		return errors.WithDetail(ErrBadEffects, "effects hash does not match the text and transaction")
+	}
+	for i, sig := range e.Signatures {
+		if !sig.XPub.Verify(e.Hash[:], sig.Signature) {
+			return errors.WithDetailf(ErrBadEffects, "signature %d does not match its key", i)
+		}
+	}
+	return nil
+}
+
+func (n *Names) assetAmount(aa bc.AssetAmount) string {
+	s := fmt.Sprintf("%d of asset %s", aa.Amount, aa.AssetID)
+	if name, ok := n.Assets[aa.AssetID]; ok {
+		s += " " + strconv.Quote(name)
+	}
+	return s
+}
+
+func (n *Names) program(prog []byte) string {
+	if name, ok := n.Accounts[string(prog)]; ok {
+		return "account " + strconv.Quote(name)
+	}
+	return "control program " + hex.EncodeToString(prog)
+}
@@ -0,0 +1,57 @@
+package txbuilder
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
+	"chain/testutil"
+)
+
+func TestEffects(t *testing.T) {
+	gold := bc.AssetID{1}
+	alice := []byte{byte(vm.OP_TRUE)}
+	tx := &bc.TxData{
+		Version: 1,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(bc.Hash{2}, 3, nil, gold, 10, alice, nil),
+		},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(gold, 7, []byte{byte(vm.OP_0)}, nil),
+			bc.NewTxOutput(gold, 3, []byte{byte(vm.OP_FAIL)}, nil),
+		},
+	}
+	names := &Names{
+		Assets:   map[bc.AssetID]string{gold: "gold"},
+		Accounts: map[string]string{string(alice): "alice"},
+	}
+	e := NewEffects(tx, names)
+	want := effectsHeader + "\n" +
+		"transaction " + tx.Hash().String() + "\n" +
+		`spend 10 of asset ` + gold.String() + ` "gold" from account "alice", output ` + bc.Hash{2}.String() + ":3\n" +
+		`pay 7 of asset ` + gold.String() + ` "gold" to control program 00` + "\n" +
+		`retire 3 of asset ` + gold.String() + ` "gold"` + "\n"
+	if e.Text != want {
+		t.Errorf("effects text = %q want %q", e.Text, want)
+	}
+
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	e.Signatures = []EffectsSignature{{XPub: xpub, Signature: xprv.Sign(e.Hash[:])}}
+	tpl := &Template{Transaction: tx, Effects: e}
+	err = VerifyEffects(tpl)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Effects don't carry over to a different transaction.
+	tx.Outputs[0].Amount = 8
+	err = VerifyEffects(tpl)
+	if errors.Root(err) != ErrBadEffects {
+		t.Errorf("verify after changing tx: got error %v want %v", err, ErrBadEffects)
+	}
+}
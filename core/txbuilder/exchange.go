@@ -0,0 +1,395 @@
+package txbuilder
+
+import (
+	"bytes"
+	"io"
+
+	"chain/encoding/blockchain"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+)
+
+// Errors for the binary template format and MergeTemplates.
+var (
+	ErrBadTemplate      = errors.New("invalid binary template")
+	ErrTemplateMismatch = errors.New("templates do not match")
+)
+
+// The binary template format starts with templateMagic,
+// followed by the format version.
+var templateMagic = []byte("chaintpl")
+
+const (
+	templateVersion = 1
+
+	// flags
+	templateAllowAdditional = 1 << 0
+
+	// witness component types
+	witnessTypeSignature = 1
+)
+
+// MarshalBinary encodes t in a canonical binary form, so it can
+// be carried to a signer that doesn't speak JSON, such as one
+// that is air-gapped, and back. The encoding holds the
+// transaction, with any witnesses it has, and the signing
+// instructions, with their partial signatures. It omits Local
+// and Effects, which a core must determine for itself.
+//
+// The encoding is:
+//
+//	"chaintpl" version flags tx instructions
+//
+// where version and flags are varint31s, tx is a varstr31
+// holding the serialized transaction, and instructions is a
+// varint31 count of signing instructions, each one:
+//
+//	position assetID amount components
+//
+// Components is a varint31 count of witness components, each
+// a varint31 type followed by its fields. The only type is 1,
+// a signature witness:
+//
+//	quorum keys program signatures
+//
+// where keys is a varint31 count of keys, each a 64-byte xpub
+// and a varstr list derivation path, and signatures is a
+// varstr list with an entry, possibly empty, for each key.
+func (t *Template) MarshalBinary() ([]byte, error) {
+	if t.Transaction == nil {
+		return nil, errors.Wrap(ErrMissingRawTx)
+	}
+	var buf bytes.Buffer
+	w := errors.NewWriter(&buf)
+
+	w.Write(templateMagic)
+	blockchain.WriteVarint31(w, templateVersion)
+	var flags uint64
+	if t.AllowAdditional {
+		flags |= templateAllowAdditional
+	}
+	blockchain.WriteVarint31(w, flags)
+
+	var tx bytes.Buffer
+	_, err := t.Transaction.WriteTo(&tx)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing transaction")
+	}
+	blockchain.WriteVarstr31(w, tx.Bytes())
+
+	blockchain.WriteVarint31(w, uint64(len(t.SigningInstructions)))
+	for i, si := range t.SigningInstructions {
+		blockchain.WriteVarint31(w, uint64(si.Position))
+		w.Write(si.AssetID[:])
+		blockchain.WriteVarint63(w, si.Amount)
+		blockchain.WriteVarint31(w, uint64(len(si.WitnessComponents)))
+		for j, c := range si.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok {
+				err := errors.WithDetailf(ErrBadWitnessComponent, "signing instruction %d: witness component %d has unknown type %T", i, j, c)
+				return nil, err
+			}
+			writeSignatureWitness(w, sw)
+		}
+	}
+	return buf.Bytes(), w.Err()
+}
+
+// assumes w has sticky errors
+func writeSignatureWitness(w io.Writer, sw *SignatureWitness) {
+	blockchain.WriteVarint31(w, witnessTypeSignature)
+	blockchain.WriteVarint31(w, uint64(sw.Quorum))
+	blockchain.WriteVarint31(w, uint64(len(sw.Keys)))
+	for _, k := range sw.Keys {
+		w.Write(k.XPub[:])
+		blockchain.WriteVarstrList(w, hexSlicesToBytes(k.DerivationPath))
+	}
+	blockchain.WriteVarstr31(w, sw.Program)
+	sigs := make([][]byte, len(sw.Keys))
+	for i := range sigs {
+		if i < len(sw.Sigs) {
+			sigs[i] = sw.Sigs[i]
+		}
+	}
+	blockchain.WriteVarstrList(w, sigs)
+}
+
+// UnmarshalBinary decodes a template encoded by MarshalBinary.
+// The decoded template came from elsewhere, so Local is false.
+func (t *Template) UnmarshalBinary(data []byte) error {
+	err := t.readFrom(bytes.NewReader(data))
+	if err != nil && errors.Root(err) != ErrBadTemplate {
+		err = errors.WithDetail(ErrBadTemplate, err.Error())
+	}
+	return err
+}
+
+func (t *Template) readFrom(r *bytes.Reader) error {
+	magic := make([]byte, len(templateMagic))
+	_, err := io.ReadFull(r, magic)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(magic, templateMagic) {
+		return errors.WithDetail(ErrBadTemplate, "not a binary template")
+	}
+	version, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return err
+	}
+	if version != templateVersion {
+		return errors.WithDetailf(ErrBadTemplate, "unsupported version %d", version)
+	}
+	flags, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return err
+	}
+
+	txBytes, _, err := blockchain.ReadVarstr31(r)
+	if err != nil {
+		return err
+	}
+	tx := new(bc.TxData)
+	err = tx.Scan(txBytes)
+	if err != nil {
+		return err
+	}
+
+	n, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return err
+	}
+	if uint64(n) > uint64(len(tx.Inputs)) {
+		return errors.Wrap(ErrBadInstructionCount)
+	}
+	sigInsts := make([]*SigningInstruction, 0, n)
+	for ; n > 0; n-- {
+		si := new(SigningInstruction)
+		si.Position, _, err = blockchain.ReadVarint31(r)
+		if err != nil {
+			return err
+		}
+		if si.Position >= uint32(len(tx.Inputs)) {
+			return errors.WithDetailf(ErrBadTemplate, "signing instruction for missing input %d", si.Position)
+		}
+		_, err = io.ReadFull(r, si.AssetID[:])
+		if err != nil {
+			return err
+		}
+		si.Amount, _, err = blockchain.ReadVarint63(r)
+		if err != nil {
+			return err
+		}
+		ncomp, _, err := blockchain.ReadVarint31(r)
+		if err != nil {
+			return err
+		}
+		for ; ncomp > 0; ncomp-- {
+			sw, err := readSignatureWitness(r)
+			if err != nil {
+				return err
+			}
+			si.WitnessComponents = append(si.WitnessComponents, sw)
+		}
+		sigInsts = append(sigInsts, si)
+	}
+	if r.Len() > 0 {
+		return errors.WithDetailf(ErrBadTemplate, "%d trailing bytes", r.Len())
+	}
+
+	*t = Template{
+		Transaction:         tx,
+		SigningInstructions: sigInsts,
+		AllowAdditional:     flags&templateAllowAdditional != 0,
+	}
+	return nil
+}
+
+func readSignatureWitness(r *bytes.Reader) (*SignatureWitness, error) {
+	typ, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return nil, err
+	}
+	if typ != witnessTypeSignature {
+		return nil, errors.WithDetailf(ErrBadWitnessComponent, "unknown witness component type %d", typ)
+	}
+	sw := new(SignatureWitness)
+	quorum, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return nil, err
+	}
+	sw.Quorum = int(quorum)
+	nkeys, _, err := blockchain.ReadVarint31(r)
+	if err != nil {
+		return nil, err
+	}
+	for ; nkeys > 0; nkeys-- {
+		var k KeyID
+		_, err = io.ReadFull(r, k.XPub[:])
+		if err != nil {
+			return nil, err
+		}
+		path, _, err := blockchain.ReadVarstrList(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range path {
+			k.DerivationPath = append(k.DerivationPath, p)
+		}
+		sw.Keys = append(sw.Keys, k)
+	}
+	sw.Program, _, err = blockchain.ReadVarstr31(r)
+	if err != nil {
+		return nil, err
+	}
+	sigs, _, err := blockchain.ReadVarstrList(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(sigs) != len(sw.Keys) {
+		return nil, errors.WithDetailf(ErrBadTemplate, "%d signatures for %d keys", len(sigs), len(sw.Keys))
+	}
+	for _, s := range sigs {
+		sw.Sigs = append(sw.Sigs, s)
+	}
+	return sw, nil
+}
+
+// MergeTemplates combines the signatures of templates of the
+// same transaction, each signed by different parties, into one
+// template, and updates the witnesses of its transaction. The
+// templates must have the same transaction, apart from its
+// witnesses, and the same signing instructions, apart from
+// their signatures. A slot signed in more than one template
+// must hold the same signature in each.
+func MergeTemplates(tpls ...*Template) (*Template, error) {
+	if len(tpls) == 0 {
+		return nil, errors.WithDetail(ErrTemplateMismatch, "no templates")
+	}
+	for i, tpl := range tpls {
+		if tpl == nil || tpl.Transaction == nil {
+			return nil, errors.WithDetailf(ErrMissingRawTx, "template %d", i)
+		}
+	}
+
+	// Copy the transaction, so filling in
+	// its witnesses leaves tpls unchanged.
+	first := tpls[0]
+	var txBytes bytes.Buffer
+	_, err := first.Transaction.WriteTo(&txBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing transaction")
+	}
+	merged := &Template{
+		Transaction:     new(bc.TxData),
+		AllowAdditional: first.AllowAdditional,
+		Effects:         first.Effects,
+	}
+	err = merged.Transaction.Scan(txBytes.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "copying transaction")
+	}
+	merged.Local = true
+	for _, tpl := range tpls {
+		merged.Local = merged.Local && tpl.Local
+	}
+	for _, si := range first.SigningInstructions {
+		cp := &SigningInstruction{Position: si.Position, AssetAmount: si.AssetAmount}
+		for _, c := range si.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok {
+				return nil, errors.WithDetailf(ErrBadWitnessComponent, "unknown witness component type %T", c)
+			}
+			swCopy := *sw
+			swCopy.Sigs = make([]chainjson.HexBytes, len(sw.Keys))
+			cp.WitnessComponents = append(cp.WitnessComponents, &swCopy)
+		}
+		merged.SigningInstructions = append(merged.SigningInstructions, cp)
+	}
+
+	txHash := first.Transaction.Hash()
+	for n, tpl := range tpls {
+		if tpl.Transaction.Hash() != txHash {
+			return nil, errors.WithDetailf(ErrTemplateMismatch, "template %d has a different transaction", n)
+		}
+		if tpl.AllowAdditional != merged.AllowAdditional {
+			return nil, errors.WithDetailf(ErrTemplateMismatch, "template %d differs in allow_additional_actions", n)
+		}
+		err := mergeSigningInstructions(merged.SigningInstructions, tpl.SigningInstructions)
+		if err != nil {
+			return nil, errors.WithDetailf(err, "template %d", n)
+		}
+	}
+
+	err = materializeWitnesses(merged)
+	if err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// mergeSigningInstructions adds the programs and
+// signatures of the instructions in src to dst.
+func mergeSigningInstructions(dst, src []*SigningInstruction) error {
+	if len(src) != len(dst) {
+		return errors.WithDetailf(ErrTemplateMismatch, "%d signing instructions, want %d", len(src), len(dst))
+	}
+	for i, si := range src {
+		d := dst[i]
+		if si.Position != d.Position || si.AssetAmount != d.AssetAmount || len(si.WitnessComponents) != len(d.WitnessComponents) {
+			return errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d differs", i)
+		}
+		for j, c := range si.WitnessComponents {
+			sw, ok := c.(*SignatureWitness)
+			if !ok {
+				return errors.WithDetailf(ErrBadWitnessComponent, "unknown witness component type %T", c)
+			}
+			dsw := d.WitnessComponents[j].(*SignatureWitness)
+			if sw.Quorum != dsw.Quorum || !sameKeys(sw.Keys, dsw.Keys) {
+				return errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d: witness component %d differs", i, j)
+			}
+			if len(sw.Program) > 0 {
+				if len(dsw.Program) > 0 && !bytes.Equal(sw.Program, dsw.Program) {
+					return errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d: witness component %d has a different program", i, j)
+				}
+				dsw.Program = sw.Program
+			}
+			for k, sig := range sw.Sigs {
+				if len(sig) == 0 || k >= len(dsw.Sigs) {
+					continue
+				}
+				if len(dsw.Sigs[k]) > 0 && !bytes.Equal(sig, dsw.Sigs[k]) {
+					return errors.WithDetailf(ErrTemplateMismatch, "signing instruction %d: witness component %d: conflicting signatures for key %d", i, j, k)
+				}
+				dsw.Sigs[k] = sig
+			}
+		}
+	}
+	return nil
+}
+
+func sameKeys(a, b []KeyID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].XPub != b[i].XPub || len(a[i].DerivationPath) != len(b[i].DerivationPath) {
+			return false
+		}
+		for j := range a[i].DerivationPath {
+			if !bytes.Equal(a[i].DerivationPath[j], b[i].DerivationPath[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hexSlicesToBytes(hs []chainjson.HexBytes) [][]byte {
+	res := make([][]byte, 0, len(hs))
+	for _, h := range hs {
+		res = append(res, h)
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package txbuilder
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	chainjson "chain/encoding/json"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/testutil"
+)
+
+func TestTemplateBinary(t *testing.T) {
+	tpl := &Template{
+		Transaction: &bc.TxData{
+			Version: 1,
+			Inputs: []*bc.TxInput{
+				bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{2}, 123, nil, nil),
+			},
+			Outputs: []*bc.TxOutput{
+				bc.NewTxOutput(bc.AssetID{2}, 123, []byte{10, 11, 12}, nil),
+			},
+		},
+		SigningInstructions: []*SigningInstruction{{
+			Position:    0,
+			AssetAmount: bc.AssetAmount{AssetID: bc.AssetID{2}, Amount: 123},
+			WitnessComponents: []WitnessComponent{
+				&SignatureWitness{
+					Quorum:  1,
+					Keys:    []KeyID{{XPub: testutil.TestXPub, DerivationPath: []chainjson.HexBytes{{1}, {2}}}},
+					Program: chainjson.HexBytes{1, 2, 3},
+					Sigs:    []chainjson.HexBytes{{4, 5, 6}},
+				},
+			},
+		}},
+		AllowAdditional: true,
+		Local:           true,
+	}
+
+	b, err := tpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(Template)
+	err = got.UnmarshalBinary(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Local {
+		t.Error("decoded template is local")
+	}
+	if got.Transaction.Hash() != tpl.Transaction.Hash() {
+		t.Errorf("decoded transaction hash = %x want %x", got.Transaction.Hash(), tpl.Transaction.Hash())
+	}
+	if !got.AllowAdditional {
+		t.Error("decoded template lost allow_additional_actions")
+	}
+	if !reflect.DeepEqual(got.SigningInstructions, tpl.SigningInstructions) {
+		t.Errorf("signing instructions = %+v want %+v", got.SigningInstructions, tpl.SigningInstructions)
+	}
+
+	err = new(Template).UnmarshalBinary(b[:len(b)-1])
+	if errors.Root(err) != ErrBadTemplate {
+		t.Errorf("decoding truncated template: err = %v want %v", err, ErrBadTemplate)
+	}
+}
+
+func TestMergeTemplates(t *testing.T) {
+	_, xpub2, err := chainkd.NewXKeys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTpl := func() *Template {
+		return &Template{
+			Transaction: &bc.TxData{
+				Version: 1,
+				Inputs: []*bc.TxInput{
+					bc.NewSpendInput(bc.Hash{1}, 0, nil, bc.AssetID{}, 123, nil, nil),
+				},
+				Outputs: []*bc.TxOutput{
+					bc.NewTxOutput(bc.AssetID{}, 123, []byte{10, 11, 12}, nil),
+				},
+			},
+			SigningInstructions: []*SigningInstruction{{
+				AssetAmount: bc.AssetAmount{Amount: 123},
+				WitnessComponents: []WitnessComponent{
+					&SignatureWitness{
+						Quorum:  2,
+						Keys:    []KeyID{{XPub: testutil.TestXPub}, {XPub: xpub2}},
+						Program: chainjson.HexBytes{1},
+					},
+				},
+			}},
+		}
+	}
+
+	a, b := newTpl(), newTpl()
+	a.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness).Sigs = []chainjson.HexBytes{{7}, nil}
+	b.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness).Sigs = []chainjson.HexBytes{nil, {8}}
+
+	merged, err := MergeTemplates(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sw := merged.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness)
+	want := []chainjson.HexBytes{{7}, {8}}
+	if !reflect.DeepEqual(sw.Sigs, want) {
+		t.Errorf("merged signatures = %v want %v", sw.Sigs, want)
+	}
+	if args := merged.Transaction.Inputs[0].Arguments(); len(args) != 4 {
+		t.Errorf("merged input has %d witness arguments, want 4", len(args))
+	}
+	if args := a.Transaction.Inputs[0].Arguments(); len(args) != 0 {
+		t.Errorf("merging changed the witness of its argument")
+	}
+
+	c := newTpl()
+	c.SigningInstructions[0].WitnessComponents[0].(*SignatureWitness).Sigs = []chainjson.HexBytes{{9}, nil}
+	_, err = MergeTemplates(a, c)
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Errorf("merging conflicting signatures: err = %v want %v", err, ErrTemplateMismatch)
+	}
+
+	d := newTpl()
+	d.Transaction.Outputs[0].Amount = 122
+	_, err = MergeTemplates(a, d)
+	if errors.Root(err) != ErrTemplateMismatch {
+		t.Errorf("merging different transactions: err = %v want %v", err, ErrTemplateMismatch)
+	}
+}
@@ -3,6 +3,8 @@ package txbuilder
 import (
 	"bytes"
 	"context"
+	"sync"
+	"time"
 
 	"chain/core/rpc"
 	"chain/errors"
@@ -30,6 +32,18 @@ type Submitter interface {
 // assembles a fully signed tx, and stores the effects of
 // its changes on the UTXO set.
 func FinalizeTx(ctx context.Context, c *protocol.Chain, s Submitter, tx *bc.Tx) error {
+	err := ValidateTx(c, tx)
+	if err != nil {
+		return err
+	}
+
+	err = s.Submit(ctx, tx)
+	return errors.Wrap(err)
+}
+
+// ValidateTx checks that tx is final and valid in the next
+// block, as FinalizeTx does before submitting it.
+func ValidateTx(c *protocol.Chain, tx *bc.Tx) error {
 	err := checkTxSighashCommitment(tx)
 	if err != nil {
 		return err
@@ -41,16 +55,15 @@ func FinalizeTx(ctx context.Context, c *protocol.Chain, s Submitter, tx *bc.Tx)
 
 	// If this transaction is valid, ValidateTxCached will store it in the cache.
 	err = c.ValidateTxCached(tx)
+	if err == nil {
+		err = c.ValidateTxInNextBlock(tx)
+	}
 	if errors.Root(err) == validation.ErrBadTx {
-		detail := errors.Detail(err)
-		err = errors.Wrap(ErrRejected, err)
-		return errors.WithDetail(err, detail)
-	} else if err != nil {
-		return errors.Wrap(err, "tx rejected")
+		// Keep the detail and data (such as the input index)
+		// describing why the tx is invalid.
+		return errors.Sub(ErrRejected, err)
 	}
-
-	err = s.Submit(ctx, tx)
-	return errors.Wrap(err)
+	return errors.Wrap(err, "tx rejected")
 }
 
 // To permit idempotence of transaction submission, we require at
@@ -108,10 +121,25 @@ func checkTxSighashCommitment(tx *bc.Tx) error {
 // TODO(jackson): This implementation maybe belongs elsewhere.
 type RemoteGenerator struct {
 	Peer *rpc.Client
+
+	// BatchWindow, if nonzero, enables batching. Submit holds
+	// each transaction for up to BatchWindow so that it can be
+	// sent to the generator in a single RPC along with any other
+	// transactions submitted in that time. If MaxBatch is
+	// nonzero, a batch is sent as soon as it has MaxBatch
+	// transactions.
+	BatchWindow time.Duration
+	MaxBatch    int
+
+	mu    sync.Mutex
+	batch *submitBatch // accepting transactions; nil if none
 }
 
 func (rg *RemoteGenerator) Submit(ctx context.Context, tx *bc.Tx) error {
-	err := rg.Peer.Call(ctx, "/rpc/submit", tx, nil)
+	if rg.BatchWindow > 0 {
+		return rg.submitBatched(ctx, tx)
+	}
+	err := rg.Peer.CallHedged(ctx, "/rpc/submit", tx, nil)
 	err = errors.Wrap(err, "generator transaction notice")
 	return err
 }
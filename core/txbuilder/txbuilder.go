@@ -21,6 +21,7 @@ var (
 	ErrBlankCheck          = errors.New("unsafe transaction: leaves assets free to control")
 	ErrAction              = errors.New("errors occurred in one or more actions")
 	ErrMissingFields       = errors.New("required field is missing")
+	ErrBadTimeLock         = errors.New("invalid time lock")
 )
 
 // Build builds or adds on to a transaction.
@@ -29,9 +30,18 @@ var (
 // The final party must ensure that the transaction is
 // balanced before calling finalize.
 func Build(ctx context.Context, tx *bc.TxData, actions []Action, maxTime time.Time) (*Template, error) {
+	return BuildReserving(ctx, tx, actions, maxTime, maxTime)
+}
+
+// BuildReserving is like Build, but the outputs actions reserve
+// stay reserved only until reservationExpiry, if that's before
+// maxTime. Reservations can be extended while the template is
+// signed; see account.Manager.ExtendReservations.
+func BuildReserving(ctx context.Context, tx *bc.TxData, actions []Action, maxTime, reservationExpiry time.Time) (*Template, error) {
 	builder := TemplateBuilder{
-		base:    tx,
-		maxTime: maxTime,
+		base:              tx,
+		maxTime:           maxTime,
+		reservationExpiry: reservationExpiry,
 	}
 
 	// Build all of the actions, updating the builder.
@@ -39,7 +49,7 @@ func Build(ctx context.Context, tx *bc.TxData, actions []Action, maxTime time.Ti
 	for i, action := range actions {
 		err := action.Build(ctx, &builder)
 		if err != nil {
-			err = errors.WithData(err, "index", i)
+			err = errors.WithData(err, errors.KeyActionIndex, i)
 			errs = append(errs, err)
 		}
 	}
@@ -85,7 +95,8 @@ func Sign(ctx context.Context, tpl *Template, xpubs []chainkd.XPub, signFn SignF
 		for j, c := range sigInst.WitnessComponents {
 			err := c.Sign(ctx, tpl, uint32(i), xpubs, signFn)
 			if err != nil {
-				return errors.WithDetailf(err, "adding signature(s) to witness component %d of input %d", j, i)
+				err = errors.WithDetailf(err, "adding signature(s) to witness component %d", j)
+				return errors.WithData(err, errors.KeyInputIndex, sigInst.Position)
 			}
 		}
 	}
@@ -26,6 +26,10 @@ type Template struct {
 	// as a whole, and any change to the tx invalidates the signature.
 	AllowAdditional bool `json:"allow_additional_actions"`
 
+	// Effects, if set, states what the transaction does,
+	// for review before signing. See Effects.
+	Effects *Effects `json:"effects,omitempty"`
+
 	sigHasher *bc.SigHasher
 }
 
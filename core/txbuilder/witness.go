@@ -48,14 +48,16 @@ func materializeWitnesses(txTemplate *Template) error {
 
 	for i, sigInst := range txTemplate.SigningInstructions {
 		if msg.Inputs[sigInst.Position] == nil {
-			return errors.WithDetailf(ErrBadTxInputIdx, "signing instruction %d references missing tx input %d", i, sigInst.Position)
+			err := errors.WithDetailf(ErrBadTxInputIdx, "signing instruction %d references a missing tx input", i)
+			return errors.WithData(err, errors.KeyInputIndex, sigInst.Position)
 		}
 
 		var witness [][]byte
 		for j, c := range sigInst.WitnessComponents {
 			err := c.Materialize(txTemplate, sigInst.Position, &witness)
 			if err != nil {
-				return errors.WithDetailf(err, "error in witness component %d of input %d", j, i)
+				err = errors.WithDetailf(err, "error in witness component %d", j)
+				return errors.WithData(err, errors.KeyInputIndex, sigInst.Position)
 			}
 		}
 
@@ -102,26 +104,10 @@ var ErrEmptyProgram = errors.New("empty signature program")
 //  - the outpoint and (if non-empty) reference data of the current input
 //  - the assetID, amount, control program, and (if non-empty) reference data of each output.
 func (sw *SignatureWitness) Sign(ctx context.Context, tpl *Template, index uint32, xpubs []chainkd.XPub, signFn SignFunc) error {
-	// Compute the predicate to sign. This is either a
-	// txsighash program if tpl.AllowAdditional is false (i.e., the tx is complete
-	// and no further changes are allowed) or a program enforcing
-	// constraints derived from the existing outputs and current input.
-	if len(sw.Program) == 0 {
-		sw.Program = buildSigProgram(tpl, tpl.SigningInstructions[index].Position)
-		if len(sw.Program) == 0 {
-			return ErrEmptyProgram
-		}
+	h, err := sw.sigHash(tpl, index)
+	if err != nil {
+		return err
 	}
-	if len(sw.Sigs) < len(sw.Keys) {
-		// Each key in sw.Keys may produce a signature in sw.Sigs. Make
-		// sure there are enough slots in sw.Sigs and that we preserve any
-		// sigs already present.
-		newSigs := make([]chainjson.HexBytes, len(sw.Keys))
-		copy(newSigs, sw.Sigs)
-		sw.Sigs = newSigs
-	}
-	var h [32]byte
-	sha3pool.Sum256(h[:], sw.Program)
 	for i, keyID := range sw.Keys {
 		if len(sw.Sigs[i]) > 0 {
 			// Already have a signature for this key
@@ -143,6 +129,32 @@ func (sw *SignatureWitness) Sign(ctx context.Context, tpl *Template, index uint3
 	return nil
 }
 
+// sigHash returns the hash of the predicate that each of
+// sw.Keys signs, computing the predicate if sw.Program is
+// empty. It also makes room in sw.Sigs for every key.
+func (sw *SignatureWitness) sigHash(tpl *Template, index uint32) (h [32]byte, err error) {
+	// Compute the predicate to sign. This is either a
+	// txsighash program if tpl.AllowAdditional is false (i.e., the tx is complete
+	// and no further changes are allowed) or a program enforcing
+	// constraints derived from the existing outputs and current input.
+	if len(sw.Program) == 0 {
+		sw.Program = buildSigProgram(tpl, tpl.SigningInstructions[index].Position)
+		if len(sw.Program) == 0 {
+			return h, ErrEmptyProgram
+		}
+	}
+	if len(sw.Sigs) < len(sw.Keys) {
+		// Each key in sw.Keys may produce a signature in sw.Sigs. Make
+		// sure there are enough slots in sw.Sigs and that we preserve any
+		// sigs already present.
+		newSigs := make([]chainjson.HexBytes, len(sw.Keys))
+		copy(newSigs, sw.Sigs)
+		sw.Sigs = newSigs
+	}
+	sha3pool.Sum256(h[:], sw.Program)
+	return h, nil
+}
+
 func contains(list []chainkd.XPub, key chainkd.XPub) bool {
 	for _, k := range list {
 		if bytes.Equal(k[:], key[:]) {
@@ -0,0 +1,115 @@
+package txdb
+
+import (
+	"context"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+	"chain/protocol/vmutil"
+)
+
+// Kinds of StateChange.
+const (
+	ChangeCreated  = "created"
+	ChangeConsumed = "consumed"
+)
+
+// A StateChange is an output added to or removed
+// from the set of unspent outputs.
+type StateChange struct {
+	Kind string
+
+	// Output is the output created or consumed.
+	// A consumed output lacks its reference
+	// data, which spending inputs don't hold.
+	Output *state.Output
+
+	// Height is the height of the block
+	// that created or consumed Output.
+	Height uint64
+}
+
+// stateDiff accumulates the net change to the set
+// of unspent outputs over a run of blocks.
+type stateDiff struct {
+	created map[bc.Outpoint]*StateChange
+	order   []bc.Outpoint
+}
+
+func newStateDiff() *stateDiff {
+	return &stateDiff{created: make(map[bc.Outpoint]*StateChange)}
+}
+
+// applyBlock adds the changes made by b. It calls consumed
+// with each output b spends that was unspent before the
+// first block, since no later block can undo that.
+func (d *stateDiff) applyBlock(b *bc.Block, consumed func(*StateChange) error) error {
+	for _, tx := range b.Transactions {
+		for _, in := range tx.Inputs {
+			if in.IsIssuance() {
+				continue
+			}
+			p := in.Outpoint()
+			if _, ok := d.created[p]; ok {
+				delete(d.created, p)
+				continue
+			}
+			err := consumed(&StateChange{Kind: ChangeConsumed, Output: state.Prevout(in), Height: b.Height})
+			if err != nil {
+				return err
+			}
+		}
+		for i, out := range tx.Outputs {
+			// Retirements never enter the state.
+			if vmutil.IsUnspendable(out.ControlProgram) {
+				continue
+			}
+			p := bc.Outpoint{Hash: tx.Hash, Index: uint32(i)}
+			d.created[p] = &StateChange{Kind: ChangeCreated, Output: state.NewOutput(*out, p), Height: b.Height}
+			d.order = append(d.order, p)
+		}
+	}
+	return nil
+}
+
+// forCreated calls fn with each output created
+// and still unspent, in the order they were created.
+func (d *stateDiff) forCreated(fn func(*StateChange) error) error {
+	for _, p := range d.order {
+		c, ok := d.created[p]
+		if !ok {
+			continue
+		}
+		err := fn(c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiffState calls fn with each change between the set of
+// unspent outputs after the block at heightA and the set
+// after the block at heightB: first each output consumed, in
+// the order they were spent, then each output created, in the
+// order they were created. Outputs both created and consumed
+// in between are left out. It holds the created outputs in
+// memory until the end, so callers should bound the range.
+// HeightA must be at most heightB.
+// If fn returns an error, DiffState stops and returns it.
+func (s *Store) DiffState(ctx context.Context, heightA, heightB uint64, fn func(*StateChange) error) error {
+	d := newStateDiff()
+	err := s.ForRawBlocks(ctx, heightA+1, heightB, func(height uint64, data []byte) error {
+		var b bc.Block
+		err := b.Scan(data)
+		if err != nil {
+			return errors.Wrapf(err, "decoding block %d", height)
+		}
+		return d.applyBlock(&b, fn)
+	})
+	if err != nil {
+		return err
+	}
+	return d.forCreated(fn)
+}
@@ -0,0 +1,64 @@
+package txdb
+
+import (
+	"reflect"
+	"testing"
+
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+func TestStateDiff(t *testing.T) {
+	spend := func(p bc.Outpoint) *bc.TxInput {
+		return bc.NewSpendInput(p.Hash, p.Index, nil, bc.AssetID{1}, 10, []byte{1}, nil)
+	}
+	out := bc.NewTxOutput(bc.AssetID{1}, 10, []byte{1}, nil)
+	retire := bc.NewTxOutput(bc.AssetID{1}, 10, vmutil.FeeProgram(), nil)
+
+	before := bc.Outpoint{Hash: bc.Hash{9}, Index: 0}
+	tx1 := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{spend(before)},
+		Outputs: []*bc.TxOutput{out, out, retire},
+	})
+	tx2 := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{spend(bc.Outpoint{Hash: tx1.Hash, Index: 0})},
+		Outputs: []*bc.TxOutput{out},
+	})
+	blocks := []*bc.Block{
+		{BlockHeader: bc.BlockHeader{Height: 2}, Transactions: []*bc.Tx{tx1}},
+		{BlockHeader: bc.BlockHeader{Height: 3}, Transactions: []*bc.Tx{tx2}},
+	}
+
+	type change struct {
+		kind   string
+		p      bc.Outpoint
+		height uint64
+	}
+	var got []change
+	record := func(c *StateChange) error {
+		got = append(got, change{c.Kind, c.Output.Outpoint, c.Height})
+		return nil
+	}
+	d := newStateDiff()
+	for _, b := range blocks {
+		err := d.applyBlock(b, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := d.forCreated(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []change{
+		{ChangeConsumed, before, 2},
+		{ChangeCreated, bc.Outpoint{Hash: tx1.Hash, Index: 1}, 2},
+		{ChangeCreated, bc.Outpoint{Hash: tx2.Hash, Index: 0}, 3},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v want %v", got, want)
+	}
+}
@@ -29,7 +29,7 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 // Snapshot represents a snapshot of the blockchain, including the state
-// tree and issuance memory.
+// tree, issuance memory, asset supply caps and amounts issued.
 type Snapshot struct {
 	// Nodes contains every node within the state tree, including interior nodes.
 	// The nodes are ordered according to a pre-order traversal.
@@ -37,6 +37,14 @@ type Snapshot struct {
 	// Issuances contains the record of recent issuances for ensuring uniqueness
 	// of issuances.
 	Issuances []*Snapshot_Issuance `protobuf:"bytes,2,rep,name=issuances" json:"issuances,omitempty"`
+	// SupplyCaps contains the supply cap of each capped asset and the amount
+	// issued under it.
+	SupplyCaps []*Snapshot_SupplyCap `protobuf:"bytes,3,rep,name=supply_caps,json=supplyCaps" json:"supply_caps,omitempty"`
+	// Issued contains the total amount issued of each asset.
+	Issued []*Snapshot_AssetIssued `protobuf:"bytes,4,rep,name=issued" json:"issued,omitempty"`
+	// IssuedCounted is set when Issued counts every issuance on the
+	// blockchain. Snapshots stored before Issued was added lack it.
+	IssuedCounted bool `protobuf:"varint,5,opt,name=issued_counted,json=issuedCounted" json:"issued_counted,omitempty"`
 }
 
 func (m *Snapshot) Reset()                    { *m = Snapshot{} }
@@ -58,6 +66,27 @@ func (m *Snapshot) GetIssuances() []*Snapshot_Issuance {
 	return nil
 }
 
+func (m *Snapshot) GetSupplyCaps() []*Snapshot_SupplyCap {
+	if m != nil {
+		return m.SupplyCaps
+	}
+	return nil
+}
+
+func (m *Snapshot) GetIssued() []*Snapshot_AssetIssued {
+	if m != nil {
+		return m.Issued
+	}
+	return nil
+}
+
+func (m *Snapshot) GetIssuedCounted() bool {
+	if m != nil {
+		return m.IssuedCounted
+	}
+	return false
+}
+
 type Snapshot_Issuance struct {
 	Hash     []byte `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
 	ExpiryMs uint64 `protobuf:"varint,2,opt,name=expiry_ms,json=expiryMs" json:"expiry_ms,omitempty"`
@@ -78,29 +107,59 @@ func (m *Snapshot_StateTreeNode) String() string            { return proto.Compa
 func (*Snapshot_StateTreeNode) ProtoMessage()               {}
 func (*Snapshot_StateTreeNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 1} }
 
+type Snapshot_SupplyCap struct {
+	AssetId []byte `protobuf:"bytes,1,opt,name=asset_id,json=assetId,proto3" json:"asset_id,omitempty"`
+	Limit   uint64 `protobuf:"varint,2,opt,name=limit" json:"limit,omitempty"`
+	Issued  uint64 `protobuf:"varint,3,opt,name=issued" json:"issued,omitempty"`
+}
+
+func (m *Snapshot_SupplyCap) Reset()                    { *m = Snapshot_SupplyCap{} }
+func (m *Snapshot_SupplyCap) String() string            { return proto.CompactTextString(m) }
+func (*Snapshot_SupplyCap) ProtoMessage()               {}
+func (*Snapshot_SupplyCap) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 2} }
+
+type Snapshot_AssetIssued struct {
+	AssetId []byte `protobuf:"bytes,1,opt,name=asset_id,json=assetId,proto3" json:"asset_id,omitempty"`
+	Amount  uint64 `protobuf:"varint,2,opt,name=amount" json:"amount,omitempty"`
+}
+
+func (m *Snapshot_AssetIssued) Reset()                    { *m = Snapshot_AssetIssued{} }
+func (m *Snapshot_AssetIssued) String() string            { return proto.CompactTextString(m) }
+func (*Snapshot_AssetIssued) ProtoMessage()               {}
+func (*Snapshot_AssetIssued) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0, 3} }
+
 func init() {
 	proto.RegisterType((*Snapshot)(nil), "chain.core.txdb.internal.storage.Snapshot")
 	proto.RegisterType((*Snapshot_Issuance)(nil), "chain.core.txdb.internal.storage.Snapshot.Issuance")
 	proto.RegisterType((*Snapshot_StateTreeNode)(nil), "chain.core.txdb.internal.storage.Snapshot.StateTreeNode")
+	proto.RegisterType((*Snapshot_SupplyCap)(nil), "chain.core.txdb.internal.storage.Snapshot.SupplyCap")
+	proto.RegisterType((*Snapshot_AssetIssued)(nil), "chain.core.txdb.internal.storage.Snapshot.AssetIssued")
 }
 
 func init() { proto.RegisterFile("snapshot.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 227 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xe2, 0xe2, 0x2b, 0xce, 0x4b, 0x2c,
-	0x28, 0xce, 0xc8, 0x2f, 0xd1, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x52, 0x48, 0xce, 0x48, 0xcc,
-	0xcc, 0xd3, 0x4b, 0xce, 0x2f, 0x4a, 0xd5, 0x2b, 0xa9, 0x48, 0x49, 0xd2, 0xcb, 0xcc, 0x2b, 0x49,
-	0x2d, 0xca, 0x4b, 0xcc, 0xd1, 0x2b, 0x2e, 0xc9, 0x2f, 0x4a, 0x4c, 0x4f, 0x55, 0x5a, 0xc8, 0xc4,
-	0xc5, 0x11, 0x0c, 0xd5, 0x24, 0xe4, 0xc7, 0xc5, 0x9a, 0x97, 0x9f, 0x92, 0x5a, 0x2c, 0xc1, 0xa8,
-	0xc0, 0xac, 0xc1, 0x6d, 0x64, 0xa1, 0x47, 0x48, 0xbb, 0x1e, 0x4c, 0xab, 0x5e, 0x70, 0x49, 0x62,
-	0x49, 0x6a, 0x48, 0x51, 0x6a, 0xaa, 0x1f, 0xd0, 0x80, 0x20, 0x88, 0x31, 0x42, 0x81, 0x5c, 0x9c,
-	0x99, 0xc5, 0xc5, 0xa5, 0x89, 0x79, 0xc9, 0x40, 0x33, 0x99, 0xc0, 0x66, 0x1a, 0x93, 0x60, 0xa6,
-	0x27, 0x54, 0x6f, 0x10, 0xc2, 0x14, 0x29, 0x6b, 0x2e, 0x0e, 0x98, 0xb0, 0x90, 0x10, 0x17, 0x4b,
-	0x46, 0x62, 0x71, 0x06, 0xd0, 0xb5, 0x8c, 0x1a, 0x3c, 0x41, 0x60, 0xb6, 0x90, 0x34, 0x17, 0x67,
-	0x6a, 0x45, 0x41, 0x66, 0x51, 0x65, 0x7c, 0x2e, 0xc8, 0x4a, 0x46, 0x0d, 0x96, 0x20, 0x0e, 0x88,
-	0x80, 0x6f, 0xb1, 0x94, 0x29, 0x17, 0x2f, 0x8a, 0x3b, 0x85, 0x04, 0xb8, 0x98, 0xb3, 0x53, 0x2b,
-	0xa1, 0x06, 0x80, 0x98, 0x70, 0x33, 0x99, 0x10, 0x66, 0x3a, 0x71, 0x46, 0xb1, 0x43, 0xdd, 0x96,
-	0xc4, 0x06, 0x0e, 0x57, 0x63, 0x40, 0x00, 0x00, 0x00, 0xff, 0xff, 0x2c, 0x20, 0xad, 0x97, 0x69,
-	0x01, 0x00, 0x00,
+	// 352 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x41, 0x4b, 0xc3, 0x30,
+	0x1c, 0xc5, 0xe9, 0xba, 0x6e, 0xed, 0x7f, 0x6e, 0x48, 0x90, 0x51, 0xeb, 0xa5, 0x08, 0x42, 0x4f,
+	0x39, 0x38, 0x15, 0xc1, 0x8b, 0xba, 0xd3, 0x0e, 0x0e, 0xec, 0xe6, 0xc5, 0x4b, 0xc9, 0xda, 0x60,
+	0x8b, 0x5b, 0x13, 0xfa, 0xcf, 0x60, 0xfb, 0x04, 0x7e, 0x6d, 0x69, 0x9a, 0x6d, 0x7a, 0x51, 0x76,
+	0x7b, 0xef, 0xc1, 0xfb, 0x25, 0x79, 0x04, 0x06, 0x58, 0x32, 0x89, 0xb9, 0x50, 0x54, 0x56, 0x42,
+	0x09, 0x12, 0xa6, 0x39, 0x2b, 0x4a, 0x9a, 0x8a, 0x8a, 0x53, 0xb5, 0xc9, 0x16, 0xb4, 0x28, 0x15,
+	0xaf, 0x4a, 0xb6, 0xa4, 0xa8, 0x44, 0xc5, 0x3e, 0xf8, 0xe5, 0x97, 0x03, 0xee, 0xcc, 0x94, 0xc8,
+	0x14, 0x9c, 0x52, 0x64, 0x1c, 0x7d, 0x2b, 0xb4, 0xa3, 0xde, 0xf5, 0x3d, 0xfd, 0xaf, 0x4e, 0x77,
+	0x55, 0x3a, 0x53, 0x4c, 0xf1, 0x79, 0xc5, 0xf9, 0x54, 0x64, 0x3c, 0x6e, 0x30, 0xe4, 0x15, 0xbc,
+	0x02, 0x71, 0xcd, 0xca, 0x94, 0xa3, 0xdf, 0xd2, 0xcc, 0xd1, 0x11, 0xcc, 0x89, 0xe9, 0xc6, 0x07,
+	0x0a, 0x79, 0x83, 0x1e, 0xae, 0xa5, 0x5c, 0x6e, 0x93, 0x94, 0x49, 0xf4, 0x6d, 0x0d, 0xbd, 0x39,
+	0xe6, 0xa2, 0xba, 0x3d, 0x66, 0x32, 0x06, 0xdc, 0x49, 0x24, 0x53, 0xe8, 0xd4, 0x67, 0xf0, 0xcc,
+	0x6f, 0x6b, 0xe2, 0xdd, 0x11, 0xc4, 0x27, 0x44, 0xae, 0x26, 0xba, 0x1d, 0x1b, 0x0a, 0xb9, 0x82,
+	0x41, 0xa3, 0x92, 0x54, 0xac, 0x4b, 0xc5, 0x33, 0xdf, 0x09, 0xad, 0xc8, 0x8d, 0xfb, 0x4d, 0x3a,
+	0x6e, 0xc2, 0xe0, 0x01, 0xdc, 0xdd, 0x23, 0x09, 0x81, 0x76, 0xce, 0x30, 0xf7, 0xad, 0xd0, 0x8a,
+	0x4e, 0x62, 0xad, 0xc9, 0x05, 0x78, 0x7c, 0x23, 0x8b, 0x6a, 0x9b, 0xac, 0xea, 0x01, 0xad, 0xa8,
+	0x1d, 0xbb, 0x4d, 0xf0, 0x82, 0xc1, 0x2d, 0xf4, 0x7f, 0xad, 0x4e, 0x4e, 0xc1, 0xfe, 0xe4, 0x5b,
+	0x03, 0xa8, 0xe5, 0x9e, 0xd9, 0x3a, 0x30, 0x83, 0x39, 0x78, 0xfb, 0x0d, 0xc8, 0x39, 0xb8, 0xac,
+	0xbe, 0x7e, 0x52, 0x64, 0xa6, 0xd7, 0xd5, 0x7e, 0x92, 0x91, 0x33, 0x70, 0x96, 0xc5, 0xaa, 0x50,
+	0xe6, 0xdc, 0xc6, 0x90, 0xe1, 0x7e, 0x28, 0x5b, 0xc7, 0xc6, 0x05, 0x8f, 0xd0, 0xfb, 0xb1, 0xc3,
+	0x5f, 0xdc, 0x21, 0x74, 0xd8, 0xaa, 0x7e, 0xbf, 0x01, 0x1b, 0xf7, 0xec, 0xbd, 0x77, 0xcd, 0xb4,
+	0x8b, 0x8e, 0xfe, 0xbd, 0xa3, 0xef, 0x01, 0x00, 0xac, 0x4c, 0x75, 0xa3, 0xcf, 0x02, 0x00, 0x00,
 }
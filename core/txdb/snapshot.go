@@ -14,13 +14,32 @@ import (
 	"chain/protocol/state"
 )
 
+// ErrUncountedSnapshot is returned by DecodeSnapshot for a snapshot
+// stored by a core that didn't count the amounts of assets issued.
+// Supply caps can't be checked against such a snapshot, so
+// using it could split consensus with cores that count.
+var ErrUncountedSnapshot = errors.New("snapshot doesn't count issued amounts")
+
 // DecodeSnapshot decodes a snapshot from the Chain Core's binary,
 // protobuf representation of the snapshot.
 func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
+	snapshot, counted, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, err
+	}
+	if !counted {
+		return nil, ErrUncountedSnapshot
+	}
+	return snapshot, nil
+}
+
+// decodeSnapshot decodes a snapshot, reporting
+// whether it counts the amounts of assets issued.
+func decodeSnapshot(data []byte) (*state.Snapshot, bool, error) {
 	var storedSnapshot storage.Snapshot
 	err := proto.Unmarshal(data, &storedSnapshot)
 	if err != nil {
-		return nil, errors.Wrap(err, "unmarshaling state snapshot proto")
+		return nil, false, errors.Wrap(err, "unmarshaling state snapshot proto")
 	}
 
 	leaves := make([]patricia.Leaf, len(storedSnapshot.Nodes))
@@ -30,7 +49,7 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 	}
 	tree, err := patricia.Reconstruct(leaves)
 	if err != nil {
-		return nil, errors.Wrap(err, "reconstructing state tree")
+		return nil, false, errors.Wrap(err, "reconstructing state tree")
 	}
 
 	issuances := make(state.PriorIssuances, len(storedSnapshot.Issuances))
@@ -40,10 +59,26 @@ func DecodeSnapshot(data []byte) (*state.Snapshot, error) {
 		issuances[hash] = issuance.ExpiryMs
 	}
 
+	supplyCaps := make(state.SupplyCaps, len(storedSnapshot.SupplyCaps))
+	for _, sc := range storedSnapshot.SupplyCaps {
+		var assetID bc.AssetID
+		copy(assetID[:], sc.AssetId)
+		supplyCaps[assetID] = state.SupplyCap{Limit: sc.Limit, Issued: sc.Issued}
+	}
+
+	issued := make(state.IssuedAmounts, len(storedSnapshot.Issued))
+	for _, ai := range storedSnapshot.Issued {
+		var assetID bc.AssetID
+		copy(assetID[:], ai.AssetId)
+		issued[assetID] = ai.Amount
+	}
+
 	return &state.Snapshot{
-		Tree:      tree,
-		Issuances: issuances,
-	}, nil
+		Tree:       tree,
+		Issuances:  issuances,
+		Issued:     issued,
+		SupplyCaps: supplyCaps,
+	}, storedSnapshot.IssuedCounted, nil
 }
 
 func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot, blockHeight uint64) error {
@@ -68,6 +103,27 @@ func storeStateSnapshot(ctx context.Context, db pg.DB, snapshot *state.Snapshot,
 		})
 	}
 
+	storedSnapshot.SupplyCaps = make([]*storage.Snapshot_SupplyCap, 0, len(snapshot.SupplyCaps))
+	for k, v := range snapshot.SupplyCaps {
+		assetID := k
+		storedSnapshot.SupplyCaps = append(storedSnapshot.SupplyCaps, &storage.Snapshot_SupplyCap{
+			AssetId: assetID[:],
+			Limit:   v.Limit,
+			Issued:  v.Issued,
+		})
+	}
+
+	storedSnapshot.Issued = make([]*storage.Snapshot_AssetIssued, 0, len(snapshot.Issued))
+	for k, v := range snapshot.Issued {
+		assetID := k
+		storedSnapshot.Issued = append(storedSnapshot.Issued, &storage.Snapshot_AssetIssued{
+			AssetId: assetID[:],
+			Amount:  v,
+		})
+	}
+
+	storedSnapshot.IssuedCounted = true
+
 	b, err := proto.Marshal(&storedSnapshot)
 	if err != nil {
 		return errors.Wrap(err, "marshaling state snapshot")
@@ -98,10 +154,24 @@ func getStateSnapshot(ctx context.Context, db pg.DB) (*state.Snapshot, uint64, e
 		return nil, height, errors.Wrap(err, "retrieving state snapshot blob")
 	}
 
-	snapshot, err := DecodeSnapshot(data)
+	snapshot, counted, err := decodeSnapshot(data)
 	if err != nil {
 		return nil, height, errors.Wrap(err, "decoding snapshot")
 	}
+	if !counted {
+		// Replaying the blockchain from the initial block
+		// counts the issuances the snapshot is missing.
+		const countQ = `SELECT COUNT(*) FROM blocks WHERE height <= $1`
+		var n uint64
+		err = db.QueryRow(ctx, countQ, height).Scan(&n)
+		if err != nil {
+			return nil, height, errors.Wrap(err, "counting blocks")
+		}
+		if n < height {
+			return nil, height, errors.WithDetail(ErrUncountedSnapshot, "the core has no blocks before its snapshot to count from; reset it and bootstrap it again")
+		}
+		return state.Empty(), 0, nil
+	}
 	return snapshot, height, nil
 }
 
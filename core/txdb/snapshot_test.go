@@ -6,7 +6,11 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/golang/protobuf/proto"
+
+	"chain/core/txdb/internal/storage"
 	"chain/database/pg/pgtest"
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/state"
 )
@@ -27,6 +31,8 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 		lookups          []pair
 		newIssuances     map[bc.Hash]uint64
 		deletedIssuances []bc.Hash
+		newSupplyCaps    state.SupplyCaps
+		newIssued        state.IssuedAmounts
 	}{
 		{ // add a single k/v pair
 			inserts: []pair{
@@ -38,6 +44,9 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 			newIssuances: map[bc.Hash]uint64{
 				bc.Hash{0x01}: 1000,
 			},
+			newSupplyCaps: state.SupplyCaps{
+				bc.AssetID{0x01}: {Limit: 1000},
+			},
 		},
 		{ // empty changeset
 			lookups: []pair{{key: "sup", hash: []byte{0x01}}},
@@ -60,6 +69,14 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 			newIssuances: map[bc.Hash]uint64{
 				bc.Hash{0x02}: 2000,
 			},
+			newSupplyCaps: state.SupplyCaps{
+				bc.AssetID{0x01}: {Limit: 1000, Issued: 10},
+				bc.AssetID{0x02}: {Limit: 5},
+			},
+			newIssued: state.IssuedAmounts{
+				bc.AssetID{0x01}: 10,
+				bc.AssetID{0x03}: 7,
+			},
 		},
 		{ // delete one pair
 			deletes:          []string{"sup"},
@@ -91,6 +108,12 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 				t.Fatal(err)
 			}
 		}
+		for assetID, sc := range changeset.newSupplyCaps {
+			snapshot.SupplyCaps[assetID] = sc
+		}
+		for assetID, amount := range changeset.newIssued {
+			snapshot.Issued[assetID] = amount
+		}
 
 		err := storeStateSnapshot(ctx, dbtx, snapshot, uint64(i))
 		if err != nil {
@@ -117,10 +140,54 @@ func TestReadWriteStateSnapshot(t *testing.T) {
 		if !reflect.DeepEqual(loadedSnapshot.Issuances, snapshot.Issuances) {
 			t.Fatalf("%d: Wrote %#v issuances to db, read %#v from db\n", i, snapshot.Issuances, loadedSnapshot.Issuances)
 		}
+		if !reflect.DeepEqual(loadedSnapshot.SupplyCaps, snapshot.SupplyCaps) {
+			t.Fatalf("%d: Wrote %#v supply caps to db, read %#v from db\n", i, snapshot.SupplyCaps, loadedSnapshot.SupplyCaps)
+		}
+		if !reflect.DeepEqual(loadedSnapshot.Issued, snapshot.Issued) {
+			t.Fatalf("%d: Wrote %#v issued amounts to db, read %#v from db\n", i, snapshot.Issued, loadedSnapshot.Issued)
+		}
 		snapshot = loadedSnapshot
 	}
 }
 
+func TestUncountedStateSnapshot(t *testing.T) {
+	dbtx := pgtest.NewTx(t)
+	ctx := context.Background()
+
+	// A snapshot stored before issued amounts were counted.
+	b, err := proto.Marshal(&storage.Snapshot{
+		Issuances: []*storage.Snapshot_Issuance{{Hash: make([]byte, 32), ExpiryMs: 1000}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pgtest.Exec(ctx, dbtx, t, `INSERT INTO snapshots (height, data) VALUES (2, $1)`, b)
+
+	_, err = DecodeSnapshot(b)
+	if err != ErrUncountedSnapshot {
+		t.Errorf("DecodeSnapshot error = %v want %v", err, ErrUncountedSnapshot)
+	}
+
+	// Without the blocks before it, the issuances can't be counted.
+	_, _, err = getStateSnapshot(ctx, dbtx)
+	if errors.Root(err) != ErrUncountedSnapshot {
+		t.Fatalf("getStateSnapshot error = %v want %v", err, ErrUncountedSnapshot)
+	}
+
+	// With them, the blockchain is replayed from the start.
+	pgtest.Exec(ctx, dbtx, t, `
+		INSERT INTO blocks (block_hash, height, data, header)
+		VALUES (decode('01', 'hex'), 1, '', ''), (decode('02', 'hex'), 2, '', '')
+	`)
+	snapshot, height, err := getStateSnapshot(ctx, dbtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 0 || len(snapshot.Issuances) != 0 {
+		t.Errorf("getStateSnapshot = %d issuances at height %d, want an empty snapshot at 0", len(snapshot.Issuances), height)
+	}
+}
+
 func BenchmarkStoreSnapshot100(b *testing.B) {
 	benchmarkStoreSnapshot(100, 100, b)
 }
@@ -51,3 +51,22 @@ func (s *Store) GetRawBlock(ctx context.Context, height uint64) ([]byte, error)
 	err := s.db.QueryRow(ctx, q, height).Scan(&block)
 	return block, errors.Wrap(err, "querying blocks from the db")
 }
+
+// GetRawBlockHeader queries the database for the header, including
+// the witness, of the block at the provided height.
+// The header is returned as raw bytes.
+func (s *Store) GetRawBlockHeader(ctx context.Context, height uint64) ([]byte, error) {
+	const q = `SELECT header FROM blocks WHERE height = $1`
+	var header []byte
+	err := s.db.QueryRow(ctx, q, height).Scan(&header)
+	return header, errors.Wrap(err, "querying block headers from the db")
+}
+
+// ForRawBlocks calls fn with the height and raw bytes of
+// each block from start to end, inclusive, in order.
+// If fn returns an error, ForRawBlocks stops and returns it.
+func (s *Store) ForRawBlocks(ctx context.Context, start, end uint64, fn func(height uint64, data []byte) error) error {
+	const q = `SELECT height, data FROM blocks WHERE height BETWEEN $1 AND $2 ORDER BY height`
+	err := pg.ForQueryRows(ctx, s.db, q, start, end, fn)
+	return errors.Wrap(err, "querying blocks from the db")
+}
@@ -0,0 +1,137 @@
+package txfeed
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+
+	"github.com/lib/pq"
+
+	"chain/core/query/filter"
+	"chain/database/pg"
+	"chain/errors"
+)
+
+// SinkSQS is the type of a sink that is an AWS SQS queue.
+const SinkSQS = "sqs"
+
+// Encodings of the transactions pushed to a sink.
+const (
+	EncodingJSON = "json"
+	EncodingCBOR = "cbor"
+)
+
+// ErrBadSink is returned by Create when a feed's sink is invalid.
+var ErrBadSink = errors.New("invalid transaction feed sink")
+
+// A Sink is a queue that the transactions matching a feed are
+// pushed to as blocks land, in addition to being listed by
+// clients consuming the feed.
+type Sink struct {
+	Type     string `json:"type"`
+	QueueURL string `json:"queue_url"`
+
+	// DeadLetterQueueURL, if set, receives the transactions
+	// that can't be delivered to QueueURL.
+	DeadLetterQueueURL string `json:"dead_letter_queue_url,omitempty"`
+
+	// Encoding is EncodingJSON or EncodingCBOR.
+	// It defaults to EncodingJSON.
+	Encoding string `json:"encoding"`
+
+	// OrderingKey, if set, is a field of the annotated
+	// transaction, such as reference_data.customer, whose
+	// value is sent with each transaction.
+	OrderingKey string `json:"ordering_key,omitempty"`
+}
+
+// checkSink validates s for a feed with the given filter,
+// filling in its defaults.
+func checkSink(s *Sink, fil string) error {
+	if s.Type != SinkSQS {
+		return errors.WithDetailf(ErrBadSink, "unsupported sink type %q", s.Type)
+	}
+	err := checkQueueURL(s.QueueURL)
+	if err != nil {
+		return err
+	}
+	if s.DeadLetterQueueURL != "" {
+		err = checkQueueURL(s.DeadLetterQueueURL)
+		if err != nil {
+			return err
+		}
+	}
+	switch s.Encoding {
+	case "":
+		s.Encoding = EncodingJSON
+	case EncodingJSON, EncodingCBOR:
+	default:
+		return errors.WithDetailf(ErrBadSink, "unknown encoding %q", s.Encoding)
+	}
+	if s.OrderingKey != "" {
+		_, err = filter.ParseField(s.OrderingKey)
+		if err != nil {
+			return errors.WithDetailf(ErrBadSink, "ordering key %q is not a field", s.OrderingKey)
+		}
+	}
+
+	// Pushed transactions are matched without
+	// filter params, so the filter can't take any.
+	p, err := filter.Parse(fil)
+	if err != nil {
+		return err
+	}
+	if p.Parameters > 0 {
+		return errors.WithDetail(ErrBadSink, "a feed with a sink can't have filter parameters")
+	}
+	return nil
+}
+
+func checkQueueURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.WithDetailf(ErrBadSink, "queue url: %q", rawURL)
+	}
+	return nil
+}
+
+// insertSink adds the sink of a new feed to the database.
+// It hears about blocks that land after it is created.
+func insertSink(ctx context.Context, db pg.DB, feedID string, s *Sink) error {
+	const q = `
+		INSERT INTO txfeed_sinks (feed_id, type, queue_url, dead_letter_queue_url, encoding, ordering_key, last_block_height)
+		SELECT $1, $2, $3, $4, $5, $6, COALESCE(MAX(height), 0) FROM blocks
+		ON CONFLICT (feed_id) DO NOTHING
+	`
+	dlq := sql.NullString{String: s.DeadLetterQueueURL, Valid: s.DeadLetterQueueURL != ""}
+	key := sql.NullString{String: s.OrderingKey, Valid: s.OrderingKey != ""}
+	_, err := db.Exec(ctx, q, feedID, s.Type, s.QueueURL, dlq, s.Encoding, key)
+	return errors.Wrap(err, "inserting txfeed sink")
+}
+
+// LoadSinks fills in the sinks of feeds that have them.
+func LoadSinks(ctx context.Context, db pg.DB, feeds []*TxFeed) error {
+	if len(feeds) == 0 {
+		return nil
+	}
+	byID := make(map[string]*TxFeed, len(feeds))
+	ids := make(pq.StringArray, 0, len(feeds))
+	for _, f := range feeds {
+		byID[f.ID] = f
+		ids = append(ids, f.ID)
+	}
+	const q = `
+		SELECT feed_id, type, queue_url, dead_letter_queue_url, encoding, ordering_key
+		FROM txfeed_sinks WHERE feed_id = ANY($1)
+	`
+	err := pg.ForQueryRows(ctx, db, q, ids, func(feedID, typ, queueURL string, dlq sql.NullString, encoding string, key sql.NullString) {
+		byID[feedID].Sink = &Sink{
+			Type:               typ,
+			QueueURL:           queueURL,
+			DeadLetterQueueURL: dlq.String,
+			Encoding:           encoding,
+			OrderingKey:        key.String,
+		}
+	})
+	return errors.Wrap(err, "loading txfeed sinks")
+}
@@ -22,14 +22,23 @@ type TxFeed struct {
 	Alias  *string `json:"alias"`
 	Filter string  `json:"filter,omitempty"`
 	After  string  `json:"after,omitempty"`
+	Sink   *Sink   `json:"sink,omitempty"`
 }
 
-func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientToken string) (*TxFeed, error) {
+// Create stores a new txfeed. If sink is non-nil, the txs
+// matching the feed are also pushed to it as blocks land.
+func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientToken string, sink *Sink) (*TxFeed, error) {
 	// Validate the filter.
 	_, err := filter.Parse(fil)
 	if err != nil {
 		return nil, err
 	}
+	if sink != nil {
+		err = checkSink(sink, fil)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	var ptrAlias *string
 	if alias != "" {
@@ -40,6 +49,7 @@ func (t *Tracker) Create(ctx context.Context, alias, fil, after string, clientTo
 		Alias:  ptrAlias,
 		Filter: fil,
 		After:  after,
+		Sink:   sink,
 	}
 	return insertTxFeed(ctx, t.DB, feed, clientToken)
 }
@@ -80,6 +90,11 @@ func insertTxFeed(ctx context.Context, db pg.DB, feed *TxFeed, clientToken strin
 		}
 	} else if err != nil {
 		return nil, err
+	} else if feed.Sink != nil {
+		err = insertSink(ctx, db, feed.ID, feed.Sink)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return feed, nil
@@ -105,6 +120,10 @@ func txfeedByClientToken(ctx context.Context, db pg.DB, clientToken string) (*Tx
 		feed.Alias = &alias.String
 	}
 
+	err = LoadSinks(ctx, db, []*TxFeed{&feed})
+	if err != nil {
+		return nil, err
+	}
 	return &feed, nil
 }
 
@@ -138,6 +157,10 @@ func (t *Tracker) Find(ctx context.Context, id, alias string) (*TxFeed, error) {
 		feed.Alias = &sqlAlias.String
 	}
 
+	err = LoadSinks(ctx, t.DB, []*TxFeed{&feed})
+	if err != nil {
+		return nil, err
+	}
 	return &feed, nil
 }
 
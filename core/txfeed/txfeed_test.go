@@ -92,8 +92,43 @@ func TestCreateTxFeedBadFilter(t *testing.T) {
 	token := "test_token_0"
 	alias := "test_txfeed"
 	fil := "lol i'm not a ~real~ filter"
-	_, err := tracker.Create(ctx, alias, fil, "", token)
+	_, err := tracker.Create(ctx, alias, fil, "", token, nil)
 	if errors.Root(err) != filter.ErrBadFilter {
 		t.Errorf("expected ErrBadFilter, got %s", errors.Root(err))
 	}
 }
+
+func TestCheckSink(t *testing.T) {
+	const queue = "https://sqs.us-east-1.amazonaws.com/1/feed"
+	cases := []struct {
+		sink   Sink
+		filter string
+		ok     bool
+	}{
+		{Sink{Type: SinkSQS, QueueURL: queue}, "", true},
+		{Sink{Type: SinkSQS, QueueURL: queue, DeadLetterQueueURL: queue + "-dead", Encoding: EncodingCBOR, OrderingKey: "reference_data.customer"}, "inputs(account_alias='alice')", true},
+		{Sink{Type: "pubsub", QueueURL: queue}, "", false},
+		{Sink{Type: SinkSQS, QueueURL: "http://example.com/feed"}, "", false},
+		{Sink{Type: SinkSQS, QueueURL: queue, DeadLetterQueueURL: "not a url"}, "", false},
+		{Sink{Type: SinkSQS, QueueURL: queue, Encoding: "xml"}, "", false},
+		{Sink{Type: SinkSQS, QueueURL: queue, OrderingKey: "a = 1"}, "", false},
+		{Sink{Type: SinkSQS, QueueURL: queue}, "inputs(account_alias=$1)", false},
+	}
+	for i, c := range cases {
+		err := checkSink(&c.sink, c.filter)
+		if c.ok && err != nil {
+			t.Errorf("case %d: unexpected error %v", i, err)
+		} else if !c.ok && errors.Root(err) != ErrBadSink {
+			t.Errorf("case %d: err = %v want %v", i, err, ErrBadSink)
+		}
+	}
+
+	s := Sink{Type: SinkSQS, QueueURL: queue}
+	err := checkSink(&s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Encoding != EncodingJSON {
+		t.Errorf("default encoding = %q want %q", s.Encoding, EncodingJSON)
+	}
+}
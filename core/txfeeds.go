@@ -11,6 +11,8 @@ import (
 	"chain/net/http/httpjson"
 )
 
+var errFeedSinksDisabled = errors.New("txfeed sinks require transaction indexing")
+
 // POST /create-txfeed
 func (h *Handler) createTxFeed(ctx context.Context, in struct {
 	Alias  string
@@ -21,9 +23,16 @@ func (h *Handler) createTxFeed(ctx context.Context, in struct {
 	// idempotency of create txfeed requests. Duplicate create txfeed requests
 	// with the same client_token will only create one txfeed.
 	ClientToken string `json:"client_token"`
+
+	// Sink, if set, is a queue that matching txs are
+	// pushed to as blocks land.
+	Sink *txfeed.Sink `json:"sink"`
 }) (*txfeed.TxFeed, error) {
+	if in.Sink != nil && h.FeedSinks == nil {
+		return nil, errFeedSinksDisabled
+	}
 	after := fmt.Sprintf("%d:%d-%d", h.Chain.Height(), math.MaxInt32, uint64(math.MaxInt64))
-	return h.TxFeeds.Create(ctx, in.Alias, in.Filter, after, in.ClientToken)
+	return h.TxFeeds.Create(ctx, in.Alias, in.Filter, after, in.ClientToken, in.Sink)
 }
 
 // POST /get-transaction-feed
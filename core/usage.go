@@ -0,0 +1,219 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/database/pg"
+	"chain/database/sql"
+	chainjson "chain/encoding/json"
+	"chain/log"
+)
+
+// TokenUsage reports the resources consumed by API requests
+// authenticated with one access token.
+//
+// CPUTime is the time spent handling the requests other than
+// in database calls. Go doesn't measure CPU time per goroutine,
+// so this is an upper bound; it includes time spent waiting,
+// for instance in long-polling requests.
+// DBTime is the time spent in database calls.
+// BytesReturned counts response bodies before compression.
+type TokenUsage struct {
+	ID            string             `json:"id"`
+	Requests      int64              `json:"requests"`
+	CPUTime       chainjson.Duration `json:"cpu_time"`
+	DBTime        chainjson.Duration `json:"db_time"`
+	BytesReturned int64              `json:"bytes_returned"`
+	Since         time.Time          `json:"since"`
+
+	// lastUsed is the start of the latest request.
+	// It's saved as the access token's last use.
+	lastUsed time.Time
+}
+
+func (u *TokenUsage) add(v *TokenUsage) {
+	u.Requests += v.Requests
+	u.CPUTime.Duration += v.CPUTime.Duration
+	u.DBTime.Duration += v.DBTime.Duration
+	u.BytesReturned += v.BytesReturned
+	if u.Since.IsZero() || !v.Since.IsZero() && v.Since.Before(u.Since) {
+		u.Since = v.Since
+	}
+	if v.lastUsed.After(u.lastUsed) {
+		u.lastUsed = v.lastUsed
+	}
+}
+
+// usage holds this process's token usage
+// not yet written to the database.
+var usage = struct {
+	mu      sync.Mutex
+	pending map[string]*TokenUsage
+}{pending: make(map[string]*TokenUsage)}
+
+func recordUsage(u *TokenUsage) {
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+	if p := usage.pending[u.ID]; p != nil {
+		p.add(u)
+		return
+	}
+	usage.pending[u.ID] = u
+}
+
+// usageHandler attributes the resources used by each request
+// to the access token that authenticated it.
+// Requests authenticated without a token aren't counted.
+func usageHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, _, ok := req.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		start := time.Now()
+		timer := new(sql.Timer)
+		cw := &countingWriter{ResponseWriter: w}
+		defer func() {
+			db := timer.Elapsed()
+			cpu := time.Since(start) - db
+			if cpu < 0 {
+				// Concurrent database calls can overlap.
+				cpu = 0
+			}
+			recordUsage(&TokenUsage{
+				ID:            id,
+				Requests:      1,
+				CPUTime:       chainjson.Duration{Duration: cpu},
+				DBTime:        chainjson.Duration{Duration: db},
+				BytesReturned: cw.n,
+				Since:         start,
+				lastUsed:      start,
+			})
+		}()
+		next.ServeHTTP(cw, req.WithContext(sql.NewContext(req.Context(), timer)))
+	})
+}
+
+// countingWriter counts the bytes written to a response body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+var (
+	_ http.Hijacker = (*countingWriter)(nil)
+	_ http.Flusher  = (*countingWriter)(nil)
+)
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *countingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("not a hijacker")
+	}
+	return h.Hijack()
+}
+
+// FlushTokenUsage periodically adds the token usage
+// recorded by this process to the totals in the database,
+// so they survive restarts and cover every process.
+// It also records when each access token was last used.
+func FlushTokenUsage(ctx context.Context, db pg.DB) {
+	ticker := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			err := flushUsage(ctx, db)
+			if err != nil {
+				log.Error(ctx, err)
+			}
+		case <-ctx.Done():
+			ticker.Stop()
+			return
+		}
+	}
+}
+
+func flushUsage(ctx context.Context, db pg.DB) error {
+	usage.mu.Lock()
+	pending := usage.pending
+	usage.pending = make(map[string]*TokenUsage)
+	usage.mu.Unlock()
+
+	const q = `
+		WITH used AS (
+			UPDATE access_tokens SET last_used_at = greatest(last_used_at, $7)
+			WHERE id = $1
+		)
+		INSERT INTO token_usage (token_id, requests, cpu_time, db_time, bytes_returned, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (token_id) DO UPDATE SET
+			requests = token_usage.requests + excluded.requests,
+			cpu_time = token_usage.cpu_time + excluded.cpu_time,
+			db_time = token_usage.db_time + excluded.db_time,
+			bytes_returned = token_usage.bytes_returned + excluded.bytes_returned
+	`
+	for id, u := range pending {
+		_, err := db.Exec(ctx, q, u.ID, u.Requests, int64(u.CPUTime.Duration), int64(u.DBTime.Duration), u.BytesReturned, u.Since, u.lastUsed)
+		if err != nil {
+			// Keep what wasn't written for the next flush.
+			for _, u := range pending {
+				recordUsage(u)
+			}
+			return err
+		}
+		delete(pending, id)
+	}
+	return nil
+}
+
+// listTokenUsage is an http handler reporting the resources
+// consumed by requests authenticated with each access token,
+// or with the given tokens, since it was first used.
+//
+// POST /list-token-usage
+func (h *Handler) listTokenUsage(ctx context.Context, x struct{ IDs []string }) ([]*TokenUsage, error) {
+	err := flushUsage(ctx, h.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	const q = `
+		SELECT token_id, requests, cpu_time, db_time, bytes_returned, created_at
+		FROM token_usage
+		WHERE coalesce(array_length($1::text[], 1), 0) = 0 OR token_id = ANY($1)
+		ORDER BY token_id
+	`
+	var report []*TokenUsage
+	err = pg.ForQueryRows(ctx, h.DB, q, pq.StringArray(x.IDs), func(id string, requests, cpu, db, bytes int64, since time.Time) {
+		report = append(report, &TokenUsage{
+			ID:            id,
+			Requests:      requests,
+			CPUTime:       chainjson.Duration{Duration: time.Duration(cpu)},
+			DBTime:        chainjson.Duration{Duration: time.Duration(db)},
+			BytesReturned: bytes,
+			Since:         since,
+		})
+	})
+	return report, err
+}
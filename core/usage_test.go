@@ -0,0 +1,30 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsageHandler(t *testing.T) {
+	usage.pending = make(map[string]*TokenUsage)
+	h := usageHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/info", nil)
+		req.SetBasicAuth("alice", "secret")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Requests without a token aren't counted.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/info", nil))
+
+	if len(usage.pending) != 1 {
+		t.Fatalf("got usage for %d tokens want 1", len(usage.pending))
+	}
+	u := usage.pending["alice"]
+	if u == nil || u.Requests != 2 || u.BytesReturned != 10 || u.Since.IsZero() {
+		t.Errorf("usage = %+v want 2 requests, 10 bytes", u)
+	}
+}
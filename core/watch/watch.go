@@ -0,0 +1,358 @@
+// Package watch tracks the balances of multisig control
+// programs whose keys are managed outside the core.
+//
+// A descriptor names a set of root xpubs, a quorum and a
+// derivation path template such as "0/1/*". Each index
+// substituted for the "*" derives one control program. The
+// core derives programs ahead of the highest index seen on
+// the blockchain, so payments to fresh addresses are found,
+// but it never creates a signer and can't spend the funds.
+package watch
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"chain/core/pin"
+	"chain/core/signers"
+	"chain/crypto/ed25519/chainkd"
+	"chain/database/pg"
+	"chain/errors"
+	"chain/protocol"
+	"chain/protocol/bc"
+	"chain/protocol/vmutil"
+)
+
+// PinName is used to identify the pin associated with
+// the watch descriptor block processor.
+const PinName = "watch"
+
+// gapLimit is how many unused programs are derived
+// past the highest index seen on the blockchain.
+const gapLimit = 20
+
+var (
+	// ErrBadPath is returned by Import when the derivation
+	// path template is malformed.
+	ErrBadPath = errors.New("invalid derivation path template")
+
+	// ErrDuplicateAlias is returned by Import when the alias
+	// is already used by another descriptor.
+	ErrDuplicateAlias = errors.New("duplicate watch descriptor alias")
+)
+
+// Descriptor describes externally managed multisig
+// control programs.
+type Descriptor struct {
+	ID        string         `json:"id"`
+	Alias     *string        `json:"alias"`
+	XPubs     []chainkd.XPub `json:"root_xpubs"`
+	Quorum    int            `json:"quorum"`
+	Path      string         `json:"derivation_path"`
+	CreatedAt time.Time      `json:"created_at"`
+
+	// NextIndex is the lowest index for
+	// which no program has been derived.
+	NextIndex uint32 `json:"next_index"`
+}
+
+// Registry stores watch descriptors and
+// the programs derived from them.
+type Registry struct {
+	DB       pg.DB
+	Chain    *protocol.Chain
+	PinStore *pin.Store
+}
+
+// pathTemplate is a parsed derivation path template.
+type pathTemplate struct {
+	components []uint32
+	wildcard   int // position of the "*"
+}
+
+// parsePath parses a derivation path template: components
+// separated by "/", each an unsigned 32-bit integer except
+// for exactly one "*".
+func parsePath(s string) (*pathTemplate, error) {
+	t := &pathTemplate{wildcard: -1}
+	for i, c := range strings.Split(s, "/") {
+		if c == "*" {
+			if t.wildcard >= 0 {
+				return nil, errors.WithDetail(ErrBadPath, "more than one *")
+			}
+			t.wildcard = i
+			t.components = append(t.components, 0)
+			continue
+		}
+		n, err := strconv.ParseUint(c, 10, 32)
+		if err != nil {
+			return nil, errors.WithDetailf(ErrBadPath, "bad component %q", c)
+		}
+		t.components = append(t.components, uint32(n))
+	}
+	if t.wildcard < 0 {
+		return nil, errors.WithDetail(ErrBadPath, "missing *")
+	}
+	return t, nil
+}
+
+// derive returns the derivation path for the given index.
+// Like signers.Path, each component is 8 bytes, little-endian.
+func (t *pathTemplate) derive(index uint32) [][]byte {
+	path := make([][]byte, 0, len(t.components))
+	for i, c := range t.components {
+		if i == t.wildcard {
+			c = index
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(c))
+		path = append(path, b[:])
+	}
+	return path
+}
+
+// program returns the control program derived
+// for the given index.
+func (t *pathTemplate) program(xpubs []chainkd.XPub, quorum int, index uint32) ([]byte, error) {
+	derived := chainkd.DeriveXPubs(xpubs, t.derive(index))
+	return vmutil.P2SPMultiSigProgram(chainkd.XPubKeys(derived), quorum)
+}
+
+// Import stores a descriptor and indexes the outputs
+// already on the blockchain that pay its programs.
+func (r *Registry) Import(ctx context.Context, alias string, xpubs []chainkd.XPub, quorum int, path string) (*Descriptor, error) {
+	if len(xpubs) == 0 {
+		return nil, errors.Wrap(signers.ErrNoXPubs)
+	}
+	seen := make(map[chainkd.XPub]bool)
+	for _, xpub := range xpubs {
+		if seen[xpub] {
+			return nil, errors.WithDetailf(signers.ErrDupeXPub, "duplicated key=%x", xpub)
+		}
+		seen[xpub] = true
+	}
+	if quorum <= 0 || quorum > len(xpubs) {
+		return nil, errors.Wrap(signers.ErrBadQuorum)
+	}
+	if _, err := parsePath(path); err != nil {
+		return nil, err
+	}
+
+	var xpubBytes [][]byte
+	for _, xpub := range xpubs {
+		xpubBytes = append(xpubBytes, xpub[:])
+	}
+	aliasSQL := sql.NullString{String: alias, Valid: alias != ""}
+	d := &Descriptor{XPubs: xpubs, Quorum: quorum, Path: path}
+	if alias != "" {
+		d.Alias = &alias
+	}
+	const q = `
+		INSERT INTO watch_descriptors (alias, xpubs, quorum, derivation_path)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err := r.DB.QueryRow(ctx, q, aliasSQL, pq.ByteaArray(xpubBytes), quorum, path).Scan(&d.ID, &d.CreatedAt)
+	if pg.IsUniqueViolation(err) {
+		return nil, errors.WithDetail(ErrDuplicateAlias, "a watch descriptor with the provided alias already exists")
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "inserting watch descriptor")
+	}
+
+	err = r.extend(ctx, d, true)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// extend derives programs for d until gapLimit of them follow
+// the highest used one. If backfill is set, it first looks for
+// outputs on the blockchain paying each new program.
+func (r *Registry) extend(ctx context.Context, d *Descriptor, backfill bool) error {
+	t, err := parsePath(d.Path)
+	if err != nil {
+		return err
+	}
+	for {
+		const stateQ = `
+			SELECT COALESCE(max(key_index) + 1, 0),
+				COALESCE(max(key_index) FILTER (WHERE used) + 1, 0)
+			FROM watch_programs WHERE descriptor_id = $1
+		`
+		var next, used uint32
+		err = r.DB.QueryRow(ctx, stateQ, d.ID).Scan(&next, &used)
+		if err != nil {
+			return errors.Wrap(err, "loading watch descriptor state")
+		}
+		d.NextIndex = next
+		if next >= used+gapLimit {
+			return nil
+		}
+
+		var (
+			indexes  pq.Int64Array
+			programs pq.ByteaArray
+		)
+		for i := next; i < used+gapLimit; i++ {
+			prog, err := t.program(d.XPubs, d.Quorum, i)
+			if err != nil {
+				return errors.Wrap(err, "deriving watch program")
+			}
+			indexes = append(indexes, int64(i))
+			programs = append(programs, prog)
+		}
+		const insertQ = `
+			INSERT INTO watch_programs (descriptor_id, key_index, control_program)
+			SELECT $1, unnest($2::bigint[]), unnest($3::bytea[])
+			ON CONFLICT (descriptor_id, key_index) DO NOTHING
+		`
+		_, err = r.DB.Exec(ctx, insertQ, d.ID, indexes, programs)
+		if err != nil {
+			return errors.Wrap(err, "inserting watch programs")
+		}
+		if !backfill {
+			continue
+		}
+		const usedQ = `
+			UPDATE watch_programs p SET used = true
+			WHERE p.control_program = ANY($1) AND EXISTS (
+				SELECT 1 FROM annotated_outputs o
+				WHERE o.data @> jsonb_build_object('control_program', encode(p.control_program, 'hex'))
+			)
+		`
+		_, err = r.DB.Exec(ctx, usedQ, programs)
+		if err != nil {
+			return errors.Wrap(err, "marking used watch programs")
+		}
+	}
+}
+
+// ProcessBlocks marks watch programs used as payments
+// to them arrive, deriving more as needed.
+func (r *Registry) ProcessBlocks(ctx context.Context) {
+	if r.PinStore == nil {
+		return
+	}
+	r.PinStore.ProcessBlocks(ctx, r.Chain, PinName, r.indexBlock)
+}
+
+func (r *Registry) indexBlock(ctx context.Context, b *bc.Block) error {
+	var programs pq.ByteaArray
+	for _, tx := range b.Transactions {
+		for _, out := range tx.Outputs {
+			programs = append(programs, out.ControlProgram)
+		}
+	}
+	if len(programs) == 0 {
+		return nil
+	}
+
+	const q = `
+		UPDATE watch_programs SET used = true
+		WHERE control_program = ANY($1) AND NOT used
+		RETURNING descriptor_id
+	`
+	touched := make(map[string]bool)
+	err := pg.ForQueryRows(ctx, r.DB, q, programs, func(id string) {
+		touched[id] = true
+	})
+	if err != nil {
+		return errors.Wrap(err, "marking used watch programs")
+	}
+	for id := range touched {
+		d, err := r.Find(ctx, id, "")
+		if err != nil {
+			return err
+		}
+		err = r.extend(ctx, d, false)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Find returns the descriptor with the given ID or alias.
+func (r *Registry) Find(ctx context.Context, id, alias string) (*Descriptor, error) {
+	const q = `
+		SELECT id, alias, xpubs, quorum, derivation_path, created_at,
+			(SELECT COALESCE(max(p.key_index) + 1, 0) FROM watch_programs p WHERE p.descriptor_id = d.id)
+		FROM watch_descriptors d WHERE id = $1 OR alias = $2
+	`
+	var (
+		d     Descriptor
+		a     sql.NullString
+		xpubs pq.ByteaArray
+	)
+	err := r.DB.QueryRow(ctx, q, id, alias).Scan(&d.ID, &a, &xpubs, &d.Quorum, &d.Path, &d.CreatedAt, &d.NextIndex)
+	if err == sql.ErrNoRows {
+		return nil, errors.WithDetailf(pg.ErrUserInputNotFound, "watch descriptor %s%s", id, alias)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading watch descriptor")
+	}
+	if a.Valid {
+		d.Alias = &a.String
+	}
+	d.XPubs, err = signers.ConvertKeys(xpubs)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding watch descriptor xpubs")
+	}
+	return &d, nil
+}
+
+// List returns a page of descriptors, ordered by ID.
+func (r *Registry) List(ctx context.Context, after string, limit int) ([]*Descriptor, string, error) {
+	const q = `SELECT id FROM watch_descriptors WHERE id > $1 ORDER BY id LIMIT $2`
+	var ids []string
+	err := pg.ForQueryRows(ctx, r.DB, q, after, limit, func(id string) {
+		ids = append(ids, id)
+	})
+	if err != nil {
+		return nil, "", errors.Wrap(err, "listing watch descriptors")
+	}
+	var descs []*Descriptor
+	for _, id := range ids {
+		d, err := r.Find(ctx, id, "")
+		if err != nil {
+			return nil, "", err
+		}
+		descs = append(descs, d)
+	}
+	if len(ids) > 0 {
+		after = ids[len(ids)-1]
+	}
+	return descs, after, nil
+}
+
+// Balances returns the unspent funds held by a
+// descriptor's programs, by asset.
+func (r *Registry) Balances(ctx context.Context, id string) ([]bc.AssetAmount, error) {
+	const q = `
+		SELECT o.data->>'asset_id', sum((o.data->>'amount')::numeric)
+		FROM watch_programs p
+		JOIN annotated_outputs o
+			ON o.data @> jsonb_build_object('control_program', encode(p.control_program, 'hex'))
+		WHERE p.descriptor_id = $1 AND p.used AND upper_inf(o.timespan)
+		GROUP BY 1 ORDER BY 1
+	`
+	var amounts []bc.AssetAmount
+	err := pg.ForQueryRows(ctx, r.DB, q, id, func(assetID string, amount uint64) error {
+		a := bc.AssetAmount{Amount: amount}
+		err := a.AssetID.UnmarshalText([]byte(assetID))
+		if err != nil {
+			return errors.Wrap(err, "decoding asset id")
+		}
+		amounts = append(amounts, a)
+		return nil
+	})
+	return amounts, errors.Wrap(err, "querying watch balances")
+}
@@ -0,0 +1,78 @@
+package watch
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+)
+
+func TestParsePath(t *testing.T) {
+	cases := []struct {
+		path string
+		want [][]byte
+	}{
+		{"*", [][]byte{{7, 0, 0, 0, 0, 0, 0, 0}}},
+		{"0/1/*", [][]byte{
+			{0, 0, 0, 0, 0, 0, 0, 0},
+			{1, 0, 0, 0, 0, 0, 0, 0},
+			{7, 0, 0, 0, 0, 0, 0, 0},
+		}},
+		{"*/4294967295", [][]byte{
+			{7, 0, 0, 0, 0, 0, 0, 0},
+			{255, 255, 255, 255, 0, 0, 0, 0},
+		}},
+	}
+	for _, c := range cases {
+		tmpl, err := parsePath(c.path)
+		if err != nil {
+			t.Errorf("parsePath(%q) error = %v", c.path, err)
+			continue
+		}
+		if got := tmpl.derive(7); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("parsePath(%q).derive(7) = %x want %x", c.path, got, c.want)
+		}
+	}
+
+	for _, path := range []string{"", "0/1", "*/*", "0//*", "-1/*", "4294967296/*", "x/*"} {
+		_, err := parsePath(path)
+		if errors.Root(err) != ErrBadPath {
+			t.Errorf("parsePath(%q) error = %v want %v", path, err, ErrBadPath)
+		}
+	}
+}
+
+func TestProgram(t *testing.T) {
+	var xpubs []chainkd.XPub
+	for i := 0; i < 3; i++ {
+		_, xpub, err := chainkd.NewXKeys(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		xpubs = append(xpubs, xpub)
+	}
+	tmpl, err := parsePath("0/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p0, err := tmpl.program(xpubs, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p0again, err := tmpl.program(xpubs, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, err := tmpl.program(xpubs, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p0, p0again) {
+		t.Errorf("program(0) is not deterministic: %x != %x", p0, p0again)
+	}
+	if bytes.Equal(p0, p1) {
+		t.Errorf("program(0) == program(1) = %x", p0)
+	}
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+
+	"chain/core/watch"
+	"chain/crypto/ed25519/chainkd"
+	"chain/errors"
+	"chain/net/http/httpjson"
+	"chain/protocol/bc"
+)
+
+var errWatchesDisabled = errors.New("watch descriptors require transaction indexing")
+
+// POST /import-watch-descriptor
+func (h *Handler) importWatchDescriptor(ctx context.Context, x struct {
+	Alias  string         `json:"alias"`
+	XPubs  []chainkd.XPub `json:"root_xpubs"`
+	Quorum int            `json:"quorum"`
+	Path   string         `json:"derivation_path"`
+}) (*watch.Descriptor, error) {
+	if h.Watches == nil {
+		return nil, errWatchesDisabled
+	}
+	return h.Watches.Import(ctx, x.Alias, x.XPubs, x.Quorum, x.Path)
+}
+
+// POST /list-watch-descriptors
+func (h *Handler) listWatchDescriptors(ctx context.Context, x requestQuery) (*page, error) {
+	if h.Watches == nil {
+		return nil, errWatchesDisabled
+	}
+	limit := x.PageSize
+	if limit == 0 {
+		limit = defGenericPageSize
+	}
+
+	descs, next, err := h.Watches.List(ctx, x.After, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	outQuery := x
+	outQuery.After = next
+
+	return &page{
+		Items:    httpjson.Array(descs),
+		LastPage: len(descs) < limit,
+		Next:     outQuery,
+	}, nil
+}
+
+// getWatchBalances returns the unspent funds, by asset,
+// held by the programs of a watch descriptor.
+//
+// POST /get-watch-balances
+func (h *Handler) getWatchBalances(ctx context.Context, x struct {
+	ID    string `json:"id"`
+	Alias string `json:"alias"`
+}) ([]bc.AssetAmount, error) {
+	if h.Watches == nil {
+		return nil, errWatchesDisabled
+	}
+	d, err := h.Watches.Find(ctx, x.ID, x.Alias)
+	if err != nil {
+		return nil, err
+	}
+	amounts, err := h.Watches.Balances(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if amounts == nil {
+		amounts = []bc.AssetAmount{}
+	}
+	return amounts, nil
+}
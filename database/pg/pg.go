@@ -49,6 +49,13 @@ func IsUniqueViolation(err error) bool {
 	return ok && pqErr.Code.Name() == "unique_violation"
 }
 
+// IsCheckViolation returns true if the given error is a Postgres check
+// constraint violation error.
+func IsCheckViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code.Name() == "check_violation"
+}
+
 func resolveURI(rawURI string) (string, error) {
 	u, err := url.Parse(rawURI)
 	if err != nil {
@@ -6,6 +6,10 @@
 // such as recording metrics and relaying distributed
 // tracing identifiers to drivers and remote hosts.
 //
+// A query or transaction stops when its context is canceled
+// or its deadline passes, so work done on behalf of an
+// abandoned request doesn't tie up a database connection.
+//
 // The sql package must be used in conjunction with a database driver.
 // See https://golang.org/s/sqldrivers for a list of drivers.
 //
@@ -23,6 +27,8 @@ import (
 	"database/sql"
 	"database/sql/driver"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"chain/errors"
 	"chain/log"
@@ -55,6 +61,35 @@ func logQuery(ctx context.Context, query string, args interface{}) {
 	}
 }
 
+// A Timer accumulates the time spent in database calls
+// made with a context carrying it. See NewContext.
+// It is safe for concurrent use by multiple goroutines.
+type Timer struct {
+	ns int64 // atomic
+}
+
+// Elapsed returns the total time recorded by t.
+func (t *Timer) Elapsed() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.ns))
+}
+
+type timerKey struct{}
+
+// NewContext returns a context carrying t. Database calls
+// made with the context, or any context derived from it,
+// add the time they take to t.
+func NewContext(ctx context.Context, t *Timer) context.Context {
+	return context.WithValue(ctx, timerKey{}, t)
+}
+
+// since adds the time elapsed since start
+// to the Timer carried by ctx, if any.
+func since(ctx context.Context, start time.Time) {
+	if t, ok := ctx.Value(timerKey{}).(*Timer); ok {
+		atomic.AddInt64(&t.ns, int64(time.Since(start)))
+	}
+}
+
 // ErrNoRows is returned by Scan when QueryRow doesn't return a
 // row. In such a case, QueryRow returns a placeholder *Row value that
 // defers this error until a Scan.
@@ -192,8 +227,12 @@ func (db *DB) SetMaxOpenConns(n int) {
 
 // Begin starts a transaction. The isolation level is dependent on
 // the driver.
+//
+// If ctx is canceled before the transaction is committed,
+// the transaction is rolled back.
 func (db *DB) Begin(ctx context.Context) (*Tx, error) {
-	tx, err := db.db.Begin()
+	defer since(ctx, time.Now())
+	tx, err := db.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -203,15 +242,17 @@ func (db *DB) Begin(ctx context.Context) (*Tx, error) {
 // Exec executes a query without returning any rows.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	return db.db.Exec(query, args...)
+	return db.db.ExecContext(ctx, query, args...)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	rows, err := db.db.Query(query, args...)
+	rows, err := db.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -222,33 +263,38 @@ func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (*Ro
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (db *DB) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	row := db.db.QueryRow(query, args...)
+	row := db.db.QueryRowContext(ctx, query, args...)
 	return &Row{row: row, ctx: ctx}
 }
 
 // Commit commits the transaction.
 func (tx *Tx) Commit(ctx context.Context) error {
+	defer since(ctx, time.Now())
 	return tx.tx.Commit()
 }
 
 // Rollback aborts the transaction.
 func (tx *Tx) Rollback(ctx context.Context) error {
+	defer since(ctx, time.Now())
 	return tx.tx.Rollback()
 }
 
 // Exec executes a query that doesn't return rows.
 // For example: an INSERT and UPDATE.
 func (tx *Tx) Exec(ctx context.Context, query string, args ...interface{}) (Result, error) {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	return tx.tx.Exec(query, args...)
+	return tx.tx.ExecContext(ctx, query, args...)
 }
 
 // Query executes a query that returns rows, typically a SELECT.
 // The args are for any placeholder parameters in the query.
 func (tx *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	rows, err := tx.tx.Query(query, args...)
+	rows, err := tx.tx.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err)
 	}
@@ -259,8 +305,9 @@ func (tx *Tx) Query(ctx context.Context, query string, args ...interface{}) (*Ro
 // QueryRow always return a non-nil value. Errors are deferred until
 // Row's Scan method is called.
 func (tx *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
+	defer since(ctx, time.Now())
 	logQuery(ctx, query, args)
-	row := tx.tx.QueryRow(query, args...)
+	row := tx.tx.QueryRowContext(ctx, query, args...)
 	return &Row{row: row, ctx: ctx}
 }
 
@@ -268,6 +315,7 @@ func (tx *Tx) QueryRow(ctx context.Context, query string, args ...interface{}) *
 // false, the Rows are closed automatically and it will suffice to check the
 // result of Err. Close is idempotent and does not affect the result of Err.
 func (rs *Rows) Close() error {
+	defer since(rs.ctx, time.Now())
 	return rs.rows.Close()
 }
 
@@ -278,6 +326,7 @@ func (rs *Rows) Close() error {
 //
 // Every call to Scan, even the first one, must be preceded by a call to Next.
 func (rs *Rows) Next() bool {
+	defer since(rs.ctx, time.Now())
 	return rs.rows.Next()
 }
 
@@ -308,5 +357,6 @@ func (rs *Rows) Scan(dest ...interface{}) error {
 // Scan uses the first row and discards the rest.  If no row matches
 // the query, Scan returns ErrNoRows.
 func (r *Row) Scan(dest ...interface{}) error {
+	defer since(r.ctx, time.Now())
 	return r.row.Scan(dest...)
 }
@@ -0,0 +1,73 @@
+package sql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chain/database/pg/pgtest"
+)
+
+func TestQueryCanceled(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := db.Exec(ctx, `SELECT pg_sleep(10)`)
+	if err == nil {
+		t.Error("Exec err = nil, want cancellation error")
+	}
+	rows, err := db.Query(ctx, `SELECT pg_sleep(10)`)
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+	}
+	if err == nil {
+		t.Error("Query err = nil, want cancellation error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("canceled queries took %s, want them aborted", d)
+	}
+}
+
+func TestTxRolledBackOnCancel(t *testing.T) {
+	_, db := pgtest.NewDB(t, pgtest.SchemaPath)
+	bg := context.Background()
+	_, err := db.Exec(bg, `CREATE TABLE cancel_test (x int)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(bg)
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO cancel_test (x) VALUES (1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	_, err = tx.Exec(ctx, `SELECT pg_sleep(10)`)
+	if err == nil {
+		t.Error("Exec err = nil, want cancellation error")
+	}
+	if err = tx.Commit(bg); err == nil {
+		t.Error("Commit err = nil, want error after cancel")
+	}
+
+	var n int
+	err = db.QueryRow(bg, `SELECT count(*) FROM cancel_test`).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("got %d rows after canceled tx, want 0", n)
+	}
+}
@@ -50,6 +50,9 @@ func ReadVarint63(r io.Reader) (uint64, int, error) {
 	return val, br.n, nil
 }
 
+// ReadVarstr31 reads a varint31 length prefix followed by
+// that many bytes. The length may not exceed
+// the limits of r (see Reader).
 func ReadVarstr31(r io.Reader) ([]byte, int, error) {
 	len, n, err := ReadVarint31(r)
 	if err != nil {
@@ -58,11 +61,26 @@ func ReadVarstr31(r io.Reader) ([]byte, int, error) {
 	if len == 0 {
 		return nil, n, nil
 	}
-	buf := make([]byte, len)
-	n2, err := io.ReadFull(r, buf)
+	if int(len) > limitsOf(r).MaxVarstrLen {
+		return nil, n, ErrTooLong
+	}
+	buf, n2, err := readBytes(r, int(len))
 	return buf, n + n2, err
 }
 
+// ReadListLen reads a varint31 element count for a list.
+// The count may not exceed the limits of r (see Reader).
+func ReadListLen(r io.Reader) (int, int, error) {
+	nelts, n, err := ReadVarint31(r)
+	if err != nil {
+		return 0, n, err
+	}
+	if int(nelts) > limitsOf(r).MaxListLen {
+		return 0, n, ErrTooLong
+	}
+	return int(nelts), n, nil
+}
+
 func WriteVarint31(w io.Writer, val uint64) (int, error) {
 	if val > math.MaxInt32 {
 		return 0, ErrRange
@@ -114,14 +132,20 @@ func WriteVarstrList(w io.Writer, l [][]byte) (int, error) {
 // ReadVarstrList reads a varint31 length prefix followed by that many
 // varstrs.
 func ReadVarstrList(r io.Reader) ([][]byte, int, error) {
-	nelts, n, err := ReadVarint31(r)
+	nelts, n, err := ReadListLen(r)
 	if err != nil {
 		return nil, n, err
 	}
 	if nelts == 0 {
 		return nil, n, nil
 	}
-	result := make([][]byte, 0, nelts)
+	// Each element takes at least one byte, so don't trust
+	// a large count until the elements arrive.
+	size := nelts
+	if size > readChunk {
+		size = readChunk
+	}
+	result := make([][]byte, 0, size)
 	for ; nelts > 0; nelts-- {
 		s, n2, err := ReadVarstr31(r)
 		n += n2
@@ -156,7 +180,14 @@ func ReadExtensibleString(r io.Reader, all bool, f func(io.Reader) error) (int,
 		return n, err
 	}
 	sr := bytes.NewReader(s)
-	err = f(sr)
+	if lr, ok := r.(*Reader); ok {
+		// Apply the same limits inside the string.
+		inner := NewReader(sr, lr.Limits)
+		defer PutReader(inner)
+		err = f(inner)
+	} else {
+		err = f(sr)
+	}
 	if err != nil {
 		return n, err
 	}
@@ -0,0 +1,111 @@
+package blockchain
+
+import (
+	"errors"
+	"io"
+	"math"
+	"sync"
+)
+
+// ErrTooLong is returned when a length prefix exceeds
+// the applicable limit.
+var ErrTooLong = errors.New("length exceeds limit")
+
+// Limits bounds the sizes of variable-length items
+// read from untrusted input, so a short input can't
+// claim a huge length and exhaust memory.
+// Data read from anything other than a Reader is
+// not limited.
+type Limits struct {
+	// MaxVarstrLen is the greatest length, in bytes,
+	// of a varstr (including an extensible string).
+	MaxVarstrLen int
+
+	// MaxListLen is the greatest number of elements
+	// in a list, such as a varstr list or the inputs
+	// of a transaction.
+	MaxListLen int
+}
+
+// APILimits are the limits for data from API requests.
+// They exceed the sizes of any data produced in practice.
+var APILimits = Limits{
+	MaxVarstrLen: 16 << 20,
+	MaxListLen:   1 << 20,
+}
+
+// noLimits applies when reading from anything other
+// than a Reader, as when reading blocks, whose sizes
+// are a matter of consensus.
+var noLimits = Limits{
+	MaxVarstrLen: math.MaxInt32,
+	MaxListLen:   math.MaxInt32,
+}
+
+// A Reader reads from an underlying io.Reader and carries
+// the limits applied by the Read functions in this package
+// when they are given the Reader (or an extensible string
+// read from it).
+type Reader struct {
+	Limits
+	r io.Reader
+}
+
+var limitReaderPool = sync.Pool{New: func() interface{} { return new(Reader) }}
+
+// NewReader returns a Reader for r with the given limits.
+// The caller may pass it to PutReader when done with it.
+func NewReader(r io.Reader, lim Limits) *Reader {
+	lr := limitReaderPool.Get().(*Reader)
+	lr.Limits = lim
+	lr.r = r
+	return lr
+}
+
+// PutReader returns r to a pool for reuse by NewReader.
+// The caller must not use r afterward.
+func PutReader(r *Reader) {
+	*r = Reader{}
+	limitReaderPool.Put(r)
+}
+
+// Read implements io.Reader.
+func (r *Reader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func limitsOf(r io.Reader) Limits {
+	if lr, ok := r.(*Reader); ok {
+		return lr.Limits
+	}
+	return noLimits
+}
+
+// readChunk is the most memory allocated for a varstr
+// ahead of reading the bytes that fill it.
+const readChunk = 1 << 16
+
+// readBytes reads n bytes from r. It allocates memory
+// as the bytes arrive, rather than all at once, so a
+// truncated input costs no more than its own length.
+func readBytes(r io.Reader, n int) ([]byte, int, error) {
+	if n <= readChunk {
+		buf := make([]byte, n)
+		m, err := io.ReadFull(r, buf)
+		return buf, m, err
+	}
+	buf := make([]byte, 0, readChunk)
+	for len(buf) < n {
+		off := len(buf)
+		chunk := n - off
+		if chunk > readChunk {
+			chunk = readChunk
+		}
+		buf = append(buf, make([]byte, chunk)...)
+		m, err := io.ReadFull(r, buf[off:])
+		if err != nil {
+			return buf[:off+m], off + m, err
+		}
+	}
+	return buf, n, nil
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReaderLimits(t *testing.T) {
+	lim := Limits{MaxVarstrLen: 3, MaxListLen: 2}
+
+	var buf bytes.Buffer
+	WriteVarstr31(&buf, []byte{1, 2, 3, 4})
+	r := NewReader(bytes.NewReader(buf.Bytes()), lim)
+	_, _, err := ReadVarstr31(r)
+	PutReader(r)
+	if err != ErrTooLong {
+		t.Errorf("ReadVarstr31 err = %v want %v", err, ErrTooLong)
+	}
+
+	buf.Reset()
+	WriteVarstrList(&buf, [][]byte{{1}, {2}, {3}})
+	r = NewReader(bytes.NewReader(buf.Bytes()), lim)
+	_, _, err = ReadVarstrList(r)
+	PutReader(r)
+	if err != ErrTooLong {
+		t.Errorf("ReadVarstrList err = %v want %v", err, ErrTooLong)
+	}
+
+	// The limits also apply inside an extensible string.
+	buf.Reset()
+	WriteExtensibleString(&buf, func(w io.Writer) error {
+		_, err := WriteVarstr31(w, []byte{1, 2, 3, 4})
+		return err
+	})
+	r = NewReader(bytes.NewReader(buf.Bytes()), lim)
+	_, err = ReadExtensibleString(r, true, func(r io.Reader) error {
+		_, _, err := ReadVarstr31(r)
+		return err
+	})
+	PutReader(r)
+	if err != ErrTooLong {
+		t.Errorf("ReadExtensibleString err = %v want %v", err, ErrTooLong)
+	}
+}
+
+func TestReadVarstrTruncated(t *testing.T) {
+	// A length prefix claiming 16MiB, followed by only 3 bytes.
+	var buf bytes.Buffer
+	WriteVarint31(&buf, 16<<20)
+	buf.Write([]byte{1, 2, 3})
+
+	s, _, err := ReadVarstr31(&buf)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("err = %v want %v", err, io.ErrUnexpectedEOF)
+	}
+	if cap(s) > readChunk {
+		t.Errorf("allocated %d bytes for a truncated varstr, want at most %d", cap(s), readChunk)
+	}
+}
+
+func TestNoLimits(t *testing.T) {
+	// Outside a Reader, as when reading blocks,
+	// counts past APILimits are allowed.
+	var buf bytes.Buffer
+	WriteVarint31(&buf, uint64(APILimits.MaxListLen+1))
+	n, _, err := ReadListLen(&buf)
+	if err != nil || n != APILimits.MaxListLen+1 {
+		t.Errorf("ReadListLen = %d, %v want %d", n, err, APILimits.MaxListLen+1)
+	}
+}
@@ -0,0 +1,395 @@
+// Package cbor translates between JSON texts and
+// CBOR (RFC 7049) data items, so that an API defined
+// in terms of JSON can also be spoken in CBOR.
+//
+// FromJSON produces canonical CBOR (RFC 7049 section 3.9):
+// integers and lengths use their shortest encoding,
+// all lengths are definite, and map keys are sorted,
+// so equal JSON values always encode to equal bytes.
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of CBOR data.
+const ContentType = "application/cbor"
+
+// maxDepth bounds the nesting of arrays and maps
+// accepted by ToJSON.
+const maxDepth = 256
+
+var (
+	// ErrSyntax is returned when CBOR input is malformed.
+	ErrSyntax = errors.New("cbor: malformed input")
+
+	// ErrUnsupported is returned when CBOR input holds
+	// a data item with no JSON equivalent.
+	ErrUnsupported = errors.New("cbor: unsupported data item")
+)
+
+// Major types.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// Tags for bignums (RFC 7049 section 2.4.2).
+const (
+	tagPosBignum = 2
+	tagNegBignum = 3
+)
+
+// FromJSON returns the canonical CBOR encoding of the JSON text b.
+// Strings become text strings, integers become integers
+// (or bignums when they don't fit in 64 bits), other numbers
+// become floating-point values, and true, false, and null
+// become the corresponding simple values.
+func FromJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = encode(&buf, v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(majorSimple<<5 | 22)
+	case bool:
+		if v {
+			buf.WriteByte(majorSimple<<5 | 21)
+		} else {
+			buf.WriteByte(majorSimple<<5 | 20)
+		}
+	case string:
+		writeHead(buf, majorText, uint64(len(v)))
+		buf.WriteString(v)
+	case json.Number:
+		return encodeNumber(buf, v)
+	case []interface{}:
+		writeHead(buf, majorArray, uint64(len(v)))
+		for _, elem := range v {
+			err := encode(buf, elem)
+			if err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Sort(canonicalKeys(keys))
+		writeHead(buf, majorMap, uint64(len(v)))
+		for _, k := range keys {
+			writeHead(buf, majorText, uint64(len(k)))
+			buf.WriteString(k)
+			err := encode(buf, v[k])
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.New("cbor: unexpected JSON value")
+	}
+	return nil
+}
+
+func encodeNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, ok := new(big.Int).SetString(n.String(), 10); ok {
+		encodeInt(buf, i)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return err
+	}
+	if f32 := float32(f); float64(f32) == f {
+		buf.WriteByte(majorSimple<<5 | 26)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+		return nil
+	}
+	buf.WriteByte(majorSimple<<5 | 27)
+	binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, i *big.Int) {
+	major, tag := byte(majorUint), uint64(tagPosBignum)
+	if i.Sign() < 0 {
+		// A negative integer n is encoded as -1 - n.
+		major, tag = majorNegInt, tagNegBignum
+		i.Neg(i).Sub(i, big.NewInt(1))
+	}
+	if i.IsUint64() {
+		writeHead(buf, major, i.Uint64())
+		return
+	}
+	writeHead(buf, majorTag, tag)
+	b := i.Bytes()
+	writeHead(buf, majorBytes, uint64(len(b)))
+	buf.Write(b)
+}
+
+// writeHead writes the initial byte of a data item
+// of the given major type, followed by its argument
+// in the shortest form that holds it.
+func writeHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+// canonicalKeys sorts text map keys in canonical order:
+// shorter keys first, then bytewise.
+type canonicalKeys []string
+
+func (a canonicalKeys) Len() int      { return len(a) }
+func (a canonicalKeys) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a canonicalKeys) Less(i, j int) bool {
+	if len(a[i]) != len(a[j]) {
+		return len(a[i]) < len(a[j])
+	}
+	return a[i] < a[j]
+}
+
+// ToJSON returns the JSON text for the single CBOR data item b.
+// It accepts any well-formed CBOR with definite lengths,
+// not only canonical CBOR. Byte strings become hex-encoded
+// JSON strings, as binary data is represented elsewhere in
+// the API. Map keys must be text strings. Tags other than
+// bignums are ignored.
+func ToJSON(b []byte) ([]byte, error) {
+	d := &decoder{b: b}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(d.b) {
+		return nil, ErrSyntax
+	}
+	return json.Marshal(v)
+}
+
+type decoder struct {
+	b   []byte
+	off int
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, ErrUnsupported
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		return json.Number(strconv.FormatUint(arg, 10)), nil
+	case majorNegInt:
+		n := new(big.Int).SetUint64(arg)
+		return json.Number(n.Neg(n).Sub(n, big.NewInt(1)).String()), nil
+	case majorBytes:
+		p, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(p), nil
+	case majorText:
+		p, err := d.bytes(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(p) {
+			return nil, ErrSyntax
+		}
+		return string(p), nil
+	case majorArray:
+		if arg > uint64(len(d.b)-d.off) { // each element takes at least one byte
+			return nil, ErrSyntax
+		}
+		a := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case majorMap:
+		if arg > uint64(len(d.b)-d.off)/2 {
+			return nil, ErrSyntax
+		}
+		m := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			if d.off < len(d.b) && d.b[d.off]>>5 != majorText {
+				return nil, ErrUnsupported
+			}
+			k, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			ks := k.(string)
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[ks] = v
+		}
+		return m, nil
+	case majorTag:
+		if arg != tagPosBignum && arg != tagNegBignum {
+			return d.decode(depth + 1)
+		}
+		major, _, n, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != majorBytes {
+			return nil, ErrSyntax
+		}
+		p, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		i := new(big.Int).SetBytes(p)
+		if arg == tagNegBignum {
+			i.Neg(i).Sub(i, big.NewInt(1))
+		}
+		return json.Number(i.String()), nil
+	default: // majorSimple
+		return d.simple(info, arg)
+	}
+}
+
+func (d *decoder) simple(info byte, arg uint64) (interface{}, error) {
+	var f float64
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null, undefined
+		return nil, nil
+	case 25:
+		f = halfToFloat64(uint16(arg))
+	case 26:
+		f = float64(math.Float32frombits(uint32(arg)))
+	case 27:
+		f = math.Float64frombits(arg)
+	default:
+		return nil, ErrUnsupported
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, ErrUnsupported
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// head reads the initial byte of a data item and its argument.
+func (d *decoder) head() (major, info byte, arg uint64, err error) {
+	if d.off >= len(d.b) {
+		return 0, 0, 0, ErrSyntax
+	}
+	c := d.b[d.off]
+	d.off++
+	major, info = c>>5, c&0x1f
+	var n int
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		n = 1
+	case info == 25:
+		n = 2
+	case info == 26:
+		n = 4
+	case info == 27:
+		n = 8
+	case info == 31:
+		// Indefinite lengths and the "break" stop code.
+		return 0, 0, 0, ErrUnsupported
+	default:
+		return 0, 0, 0, ErrSyntax
+	}
+	if len(d.b)-d.off < n {
+		return 0, 0, 0, ErrSyntax
+	}
+	for _, b := range d.b[d.off : d.off+n] {
+		arg = arg<<8 | uint64(b)
+	}
+	d.off += n
+	return major, info, arg, nil
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)-d.off) {
+		return nil, ErrSyntax
+	}
+	p := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return p, nil
+}
+
+// halfToFloat64 converts an IEEE 754 half-precision value.
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
@@ -0,0 +1,117 @@
+package cbor
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Examples from RFC 7049 appendix A.
+var examples = []struct {
+	json, cbor string
+}{
+	{`0`, "00"},
+	{`23`, "17"},
+	{`24`, "1818"},
+	{`1000`, "1903e8"},
+	{`1000000`, "1a000f4240"},
+	{`18446744073709551615`, "1bffffffffffffffff"},
+	{`18446744073709551616`, "c249010000000000000000"},
+	{`-1`, "20"},
+	{`-1000`, "3903e7"},
+	{`-18446744073709551616`, "3bffffffffffffffff"},
+	{`-18446744073709551617`, "c349010000000000000000"},
+	{`1.5`, "fa3fc00000"},
+	{`1.1`, "fb3ff199999999999a"},
+	{`false`, "f4"},
+	{`true`, "f5"},
+	{`null`, "f6"},
+	{`""`, "60"},
+	{`"IETF"`, "6449455446"},
+	{`"ü"`, "62c3bc"},
+	{`[]`, "80"},
+	{`[1,[2,3],[4,5]]`, "8301820203820405"},
+	{`{"a":1,"b":[2,3]}`, "a26161016162820203"},
+}
+
+func TestFromJSON(t *testing.T) {
+	for _, ex := range examples {
+		got, err := FromJSON([]byte(ex.json))
+		if err != nil {
+			t.Errorf("FromJSON(%s) error %v", ex.json, err)
+			continue
+		}
+		if hex.EncodeToString(got) != ex.cbor {
+			t.Errorf("FromJSON(%s) = %x want %s", ex.json, got, ex.cbor)
+		}
+	}
+
+	// Keys are sorted shortest first.
+	got, err := FromJSON([]byte(`{"bb":1,"c":2,"a":3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "a361610361630262626201"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("FromJSON(map) = %x want %s", got, want)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	for _, ex := range examples {
+		b, _ := hex.DecodeString(ex.cbor)
+		got, err := ToJSON(b)
+		if err != nil {
+			t.Errorf("ToJSON(%s) error %v", ex.cbor, err)
+			continue
+		}
+		if string(got) != ex.json {
+			t.Errorf("ToJSON(%s) = %s want %s", ex.cbor, got, ex.json)
+		}
+	}
+
+	// Non-canonical, but well-formed, input.
+	others := []struct {
+		cbor, json string
+	}{
+		{"1800", `0`},                  // argument not in shortest form
+		{"f93c00", `1`},                // half-precision float
+		{"f7", `null`},                 // undefined
+		{"c11a514b67b0", `1363896240`}, // tagged epoch time
+		{"4401020304", `"01020304"`},   // byte string
+	}
+	for _, ex := range others {
+		b, _ := hex.DecodeString(ex.cbor)
+		got, err := ToJSON(b)
+		if err != nil {
+			t.Errorf("ToJSON(%s) error %v", ex.cbor, err)
+			continue
+		}
+		if string(got) != ex.json {
+			t.Errorf("ToJSON(%s) = %s want %s", ex.cbor, got, ex.json)
+		}
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	cases := []struct {
+		cbor string
+		want error
+	}{
+		{"", ErrSyntax},
+		{"19ff", ErrSyntax},               // truncated argument
+		{"62c3", ErrSyntax},               // truncated text
+		{"9bffffffffffffffff", ErrSyntax}, // impossible array length
+		{"0000", ErrSyntax},               // trailing data
+		{"61ff", ErrSyntax},               // invalid UTF-8
+		{"9f01ff", ErrUnsupported},        // indefinite-length array
+		{"a10102", ErrUnsupported},        // integer map key
+		{"f97e00", ErrUnsupported},        // NaN
+	}
+	for _, c := range cases {
+		b, _ := hex.DecodeString(c.cbor)
+		_, err := ToJSON(b)
+		if err != c.want {
+			t.Errorf("ToJSON(%s) error = %v want %v", c.cbor, err, c.want)
+		}
+	}
+}
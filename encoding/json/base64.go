@@ -0,0 +1,292 @@
+package json
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// MarshalBase64 is like encoding/json.Marshal, but it
+// encodes the HexBytes values in v as base64 rather
+// than hex. This shortens byte fields by about a third.
+//
+// It finds the HexBytes values by walking the JSON text
+// of v alongside v itself. A struct with its own
+// MarshalJSON method is walked by its fields, matched by
+// name to the keys of the object it marshals to, as the
+// MarshalJSON methods in this repo mostly build objects
+// from their fields.
+func MarshalBase64(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || v == nil {
+		return b, err
+	}
+	return recode(b, reflect.TypeOf(v), reflect.ValueOf(v), hexToBase64)
+}
+
+// FromBase64 returns a copy of the JSON text b, which is
+// to be decoded into v, with the base64 strings for the
+// HexBytes values in v replaced by hex strings, so that
+// it can then be decoded as usual. Strings that aren't
+// valid base64 are an error.
+func FromBase64(b []byte, v interface{}) ([]byte, error) {
+	if v == nil {
+		return b, nil
+	}
+	return recode(b, reflect.TypeOf(v), reflect.ValueOf(v), base64ToHex)
+}
+
+func hexToBase64(s string) (string, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		// Not a HexBytes value after all; see MarshalBase64.
+		return s, nil
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func base64ToHex(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var (
+	hexBytesType        = reflect.TypeOf(HexBytes(nil))
+	marshalerType       = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// recode returns b, the JSON text of a value of type t,
+// with conv applied to the strings for its HexBytes
+// values. If v is valid, it holds the value, and is used
+// to find the dynamic types of interface values in it.
+// Text that doesn't have the shape t calls for is
+// returned unchanged.
+func recode(b []byte, t reflect.Type, v reflect.Value, conv func(string) (string, error)) ([]byte, error) {
+	if t == hexBytesType {
+		var s *string
+		if json.Unmarshal(b, &s) != nil || s == nil {
+			return b, nil
+		}
+		c, err := conv(*s)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(c)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsValid() {
+			v = v.Elem()
+		}
+		return recode(b, t.Elem(), v, conv)
+	case reflect.Interface:
+		if !v.IsValid() || v.IsNil() {
+			return b, nil
+		}
+		return recode(b, v.Elem().Type(), v.Elem(), conv)
+	case reflect.Struct:
+		return recodeObject(b, conv, func(key string) (reflect.Type, reflect.Value, bool) {
+			index, ok := fieldIndex(t, key)
+			if !ok {
+				return nil, reflect.Value{}, false
+			}
+			return t.FieldByIndex(index).Type, fieldValue(v, index), true
+		})
+	}
+
+	if hasJSONMethods(t) {
+		return b, nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return recodeObject(b, conv, func(key string) (reflect.Type, reflect.Value, bool) {
+			var elem reflect.Value
+			if v.IsValid() && t.Key().Kind() == reflect.String {
+				elem = v.MapIndex(reflect.ValueOf(key).Convert(t.Key()))
+			}
+			return t.Elem(), elem, true
+		})
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return b, nil // base64 already
+		}
+		return recodeArray(b, conv, func(i int) (reflect.Type, reflect.Value) {
+			var elem reflect.Value
+			if v.IsValid() && i < v.Len() {
+				elem = v.Index(i)
+			}
+			return t.Elem(), elem
+		})
+	}
+	return b, nil
+}
+
+// recodeObject recodes the members of the JSON object b
+// for which member returns a type.
+func recodeObject(b []byte, conv func(string) (string, error), member func(key string) (reflect.Type, reflect.Value, bool)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('{') {
+		return b, nil
+	}
+	out := []byte{'{'}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		var val json.RawMessage
+		err = dec.Decode(&val)
+		if err != nil {
+			return nil, err
+		}
+		if t, v, ok := member(key); ok {
+			val, err = recode(val, t, v, conv)
+			if err != nil {
+				return nil, err
+			}
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) > 1 {
+			out = append(out, ',')
+		}
+		out = append(out, k...)
+		out = append(out, ':')
+		out = append(out, val...)
+	}
+	return append(out, '}'), nil
+}
+
+// recodeArray recodes the elements of the JSON array b.
+func recodeArray(b []byte, conv func(string) (string, error), elem func(i int) (reflect.Type, reflect.Value)) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok != json.Delim('[') {
+		return b, nil
+	}
+	out := []byte{'['}
+	for i := 0; dec.More(); i++ {
+		var val json.RawMessage
+		err = dec.Decode(&val)
+		if err != nil {
+			return nil, err
+		}
+		t, v := elem(i)
+		val, err = recode(val, t, v, conv)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = append(out, val...)
+	}
+	return append(out, ']'), nil
+}
+
+// hasJSONMethods reports whether values of type t
+// choose their own JSON encoding.
+func hasJSONMethods(t reflect.Type) bool {
+	for _, m := range []reflect.Type{marshalerType, unmarshalerType, textMarshalerType, textUnmarshalerType} {
+		if t.Implements(m) || reflect.PtrTo(t).Implements(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldIndex returns the index of the field of struct
+// type t that the object key name is decoded into,
+// preferring an exact match to a case-insensitive one
+// and shallower fields to deeper ones, as encoding/json
+// does.
+func fieldIndex(t reflect.Type, name string) ([]int, bool) {
+	var exact, fold []int
+	for _, f := range jsonFields(t) {
+		if f.name == name && (exact == nil || len(f.index) < len(exact)) {
+			exact = f.index
+		}
+		if strings.EqualFold(f.name, name) && (fold == nil || len(f.index) < len(fold)) {
+			fold = f.index
+		}
+	}
+	if exact != nil {
+		return exact, true
+	}
+	return fold, fold != nil
+}
+
+type jsonField struct {
+	name  string
+	index []int
+}
+
+// jsonFields returns the fields of struct type t that
+// encoding/json encodes, including those promoted from
+// embedded structs, with their JSON names.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, ef := range jsonFields(ft) {
+				fields = append(fields, jsonField{ef.name, append([]int{i}, ef.index...)})
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name, []int{i}})
+	}
+	return fields
+}
+
+// fieldValue is like v.FieldByIndex, but it returns
+// the zero Value if v is, or if it would pass through
+// a nil pointer.
+func fieldValue(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if !v.IsValid() {
+			return v
+		}
+		v = v.Field(i)
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package json
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type bytesHolder struct {
+	B HexBytes
+}
+
+// MarshalJSON builds an object from the fields of h,
+// as many types in this repo do.
+func (h bytesHolder) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"b": h.B})
+}
+
+func TestMarshalBase64(t *testing.T) {
+	v := []interface{}{HexBytes{0xca, 0xfe, 0x00}, bytesHolder{HexBytes{0xde, 0xad}}}
+
+	got, err := MarshalBase64(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `["yv4A",{"b":"3q0="}]`
+	if string(got) != want {
+		t.Errorf("MarshalBase64 = %s want %s", got, want)
+	}
+
+	got, err = json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `["cafe00",{"b":"dead"}]`
+	if string(got) != want {
+		t.Errorf("Marshal = %s want %s", got, want)
+	}
+}
+
+type embedded struct {
+	Inner HexBytes `json:"inner"`
+}
+
+type bytesStruct struct {
+	embedded
+	Outer  HexBytes            `json:"outer"`
+	Raw    []byte              `json:"raw"`
+	Ptr    *HexBytes           `json:"ptr"`
+	Map    map[string]HexBytes `json:"map"`
+	Any    interface{}         `json:"any"`
+	Skip   HexBytes            `json:"-"`
+	String string              `json:"string"`
+}
+
+func TestMarshalBase64Struct(t *testing.T) {
+	v := &bytesStruct{
+		embedded: embedded{HexBytes{0x01}},
+		Outer:    HexBytes{0x02},
+		Raw:      []byte{0x03},
+		Map:      map[string]HexBytes{"k": {0x04}},
+		Any:      []HexBytes{{0x05}},
+		String:   "0a",
+	}
+	got, err := MarshalBase64(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"inner":"AQ==","outer":"Ag==","raw":"Aw==","ptr":null,"map":{"k":"BA=="},"any":["BQ=="],"string":"0a"}`
+	if string(got) != want {
+		t.Errorf("MarshalBase64 = %s want %s", got, want)
+	}
+}
+
+func TestFromBase64(t *testing.T) {
+	var got []HexBytes
+	b, err := FromBase64([]byte(`["yv4A","3q0="]`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HexBytes{{0xca, 0xfe, 0x00}, {0xde, 0xad}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FromBase64 = %x want %x", got, want)
+	}
+
+	var s bytesStruct
+	b, err = FromBase64([]byte(`{"Inner":"AQ==","outer":"Ag==","ptr":"Aw==","map":{"k":"BA=="},"string":"yv4="}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantText := `{"Inner":"01","outer":"02","ptr":"03","map":{"k":"04"},"string":"yv4="}`
+	if string(b) != wantText {
+		t.Errorf("FromBase64 = %s want %s", b, wantText)
+	}
+
+	_, err = FromBase64([]byte(`{"outer":"not base64"}`), &s)
+	if err == nil {
+		t.Error("FromBase64 with invalid base64: got no error")
+	}
+}
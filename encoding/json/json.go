@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 )
 
+// HexBytes is a byte slice encoded in JSON as a hex string.
+// MarshalBase64 and FromBase64 encode it as base64 instead.
 type HexBytes []byte
 
 func (h HexBytes) MarshalText() ([]byte, error) {
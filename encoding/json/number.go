@@ -0,0 +1,148 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// Uint64 is a uint64 that is encoded as a JSON number
+// and can be decoded from either a JSON number or a string
+// holding a decimal integer. Clients that can't represent
+// every uint64 exactly as a number (such as JavaScript,
+// whose numbers are IEEE 754 doubles) can send strings
+// instead without losing precision.
+type Uint64 uint64
+
+// MarshalJSON implements json.Marshaler.
+func (u Uint64) MarshalJSON() ([]byte, error) {
+	return strconv.AppendUint(nil, uint64(u), 10), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (u *Uint64) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	b = unquote(b)
+	n, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return errors.New("invalid json.Uint64")
+	}
+	*u = Uint64(n)
+	return nil
+}
+
+// Decimal is an arbitrary-precision decimal number.
+// It holds the number's literal text exactly as given,
+// so no precision is lost in a round trip.
+// It is encoded as a JSON number and can be decoded
+// from either a JSON number or a string holding one.
+type Decimal string
+
+// Rat returns the value of d as a big.Rat.
+// It returns nil if d is not a valid number.
+func (d Decimal) Rat() *big.Rat {
+	if !isNumber([]byte(d)) {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(string(d))
+	if !ok {
+		return nil
+	}
+	return r
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	if d == "" {
+		return []byte("0"), nil
+	}
+	if !isNumber([]byte(d)) {
+		return nil, errors.New("invalid json.Decimal")
+	}
+	return []byte(d), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	if bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	b = unquote(b)
+	if !isNumber(b) {
+		return errors.New("invalid json.Decimal")
+	}
+	*d = Decimal(b)
+	return nil
+}
+
+// unquote returns the contents of b if it is a JSON string
+// that needs no unescaping, as any string holding a number
+// doesn't. Otherwise it returns b unchanged.
+func unquote(b []byte) []byte {
+	if len(b) >= 2 && b[0] == '"' && b[len(b)-1] == '"' {
+		return b[1 : len(b)-1]
+	}
+	return b
+}
+
+// isNumber reports whether b is a valid JSON number literal.
+func isNumber(b []byte) bool {
+	if len(b) == 0 || b[0] != '-' && (b[0] < '0' || '9' < b[0]) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal(b, &n) == nil
+}
+
+// QuoteNumbers returns a copy of the JSON text b
+// with every number replaced by a string holding
+// the number's literal text, for clients that asked
+// for numbers to be string-encoded so they can be
+// decoded without loss of precision.
+// The result is undefined if b is not valid JSON.
+func QuoteNumbers(b []byte) []byte {
+	out := make([]byte, 0, len(b)+len(b)/8)
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				out = append(out, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '-' || '0' <= c && c <= '9':
+			j := i + 1
+			for j < len(b) && isNumberByte(b[j]) {
+				j++
+			}
+			out = append(out, '"')
+			out = append(out, b[i:j]...)
+			out = append(out, '"')
+			i = j - 1
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isNumberByte(c byte) bool {
+	switch {
+	case '0' <= c && c <= '9':
+		return true
+	case c == '-' || c == '+' || c == '.' || c == 'e' || c == 'E':
+		return true
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package json
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestUnmarshalUint64(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    Uint64
+		wantErr bool
+	}{
+		{`18446744073709551615`, 1<<64 - 1, false},
+		{`"18446744073709551615"`, 1<<64 - 1, false},
+		{`"9007199254740993"`, 1<<53 + 1, false},
+		{`0`, 0, false},
+		{`-1`, 0, true},
+		{`"18446744073709551616"`, 0, true},
+		{`1.5`, 0, true},
+		{`"x"`, 0, true},
+	}
+	for _, c := range cases {
+		var got Uint64
+		err := json.Unmarshal([]byte(c.in), &got)
+		if (err != nil) != c.wantErr {
+			t.Errorf("Unmarshal(%s) err = %v want error %v", c.in, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Unmarshal(%s) = %d want %d", c.in, got, c.want)
+		}
+	}
+
+	b, err := json.Marshal(Uint64(1<<64 - 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "18446744073709551615" {
+		t.Errorf("Marshal = %s want 18446744073709551615", b)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	const lit = "12345678901234567890.000000000000000000001"
+	for _, in := range []string{lit, `"` + lit + `"`} {
+		var d Decimal
+		err := json.Unmarshal([]byte(in), &d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != lit {
+			t.Errorf("round trip of %s = %s want %s", in, b, lit)
+		}
+		want, _ := new(big.Rat).SetString(lit)
+		if d.Rat().Cmp(want) != 0 {
+			t.Errorf("Rat() = %v want %v", d.Rat(), want)
+		}
+	}
+
+	for _, in := range []string{`"1.2.3"`, `"0x10"`, `"NaN"`, `true`} {
+		var d Decimal
+		if err := json.Unmarshal([]byte(in), &d); err == nil {
+			t.Errorf("Unmarshal(%s) = %q want error", in, d)
+		}
+	}
+}
+
+func TestQuoteNumbers(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{`1`, `"1"`},
+		{`[1,-2.5e+3,"3",true,null]`, `["1","-2.5e+3","3",true,null]`},
+		{`{"a":{"b":18446744073709551615},"c":"x\"1"}`, `{"a":{"b":"18446744073709551615"},"c":"x\"1"}`},
+		{`{"k\\":2}`, `{"k\\":"2"}`},
+	}
+	for _, c := range cases {
+		got := string(QuoteNumbers([]byte(c.in)))
+		if got != c.want {
+			t.Errorf("QuoteNumbers(%s) = %s want %s", c.in, got, c.want)
+		}
+	}
+}
@@ -154,3 +154,51 @@ func Data(err error) map[string]interface{} {
 	wrapper, _ := err.(wrapperError)
 	return wrapper.data
 }
+
+// Keys for structured data commonly attached to errors
+// with WithData. Use them, rather than formatting the
+// same information into a detail message, so that it
+// appears uniformly in the data of API error responses.
+const (
+	// KeyActionIndex is the position of the action that
+	// caused an error in a build request. It is "index"
+	// for compatibility with existing clients.
+	KeyActionIndex = "index"
+
+	// KeyInputIndex is the position, in a transaction,
+	// of the input that caused an error.
+	KeyInputIndex = "input_index"
+
+	// KeyAccountAlias is the alias of an account
+	// named in a request.
+	KeyAccountAlias = "account_alias"
+
+	// KeyAssetAlias is the alias of an asset
+	// named in a request.
+	KeyAssetAlias = "asset_alias"
+)
+
+// Sub returns an error containing root as its root and
+// taking all other metadata (stack trace, detail, message,
+// and data items) from err.
+//
+// Sub returns nil when either root or err is nil.
+//
+// Use this when you need to substitute a new root error in place
+// of an existing error that may already hold a stack trace
+// or other metadata.
+func Sub(root, err error) error {
+	if wrapper, ok := err.(wrapperError); ok && root != nil {
+		wrapper.root = Root(root)
+		return wrapper
+	}
+	if root == nil || err == nil {
+		return nil
+	}
+	wrapper := wrapperError{
+		msg:   err.Error(),
+		root:  Root(root),
+		stack: getStack(2, stackTraceSize),
+	}
+	return wrapper
+}
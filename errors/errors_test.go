@@ -117,3 +117,35 @@ func TestData(t *testing.T) {
 		}
 	}
 }
+
+func TestSub(t *testing.T) {
+	x := errors.New("x")
+	y := errors.New("y")
+	cases := []struct{ new, old, want error }{
+		{nil, nil, nil},
+		{x, nil, nil},
+		{nil, y, nil},
+		{Wrap(x, "w"), y, x},
+		{x, Wrap(y, "w"), x},
+		{x, WithData(WithDetail(y, "d"), "k", 1), x},
+	}
+
+	for _, test := range cases {
+		got := Sub(test.new, test.old)
+		if Root(got) != test.want {
+			t.Errorf("Root(Sub(%v, %v)) = %v want %v", test.new, test.old, Root(got), test.want)
+		}
+		if test.want == nil {
+			continue
+		}
+		if got.Error() != test.old.Error() {
+			t.Errorf("Sub(%v, %v).Error() = %q want %q", test.new, test.old, got.Error(), test.old.Error())
+		}
+		if !reflect.DeepEqual(Data(got), Data(test.old)) {
+			t.Errorf("Data(Sub(%v, %v)) = %v want %v", test.new, test.old, Data(got), Data(test.old))
+		}
+		if Detail(got) != Detail(test.old) {
+			t.Errorf("Detail(Sub(%v, %v)) = %q want %q", test.new, test.old, Detail(got), Detail(test.old))
+		}
+	}
+}
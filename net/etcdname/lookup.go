@@ -35,6 +35,17 @@ func init() {
 	etcd, initErr = client.New(cfg)
 }
 
+// Client returns the client for the etcd cluster
+// configured with ETCD_URLS, if any.
+func Client() (client.Client, error) {
+	if initErr != nil {
+		return nil, initErr
+	} else if etcd == nil {
+		return nil, errors.New("etcd is not configured")
+	}
+	return etcd, nil
+}
+
 // LookupHost looks up the given host using the configured etcd cluster, if any.
 // It retrieves the address for a host by checking etcd's services directory, and
 // returns an array of the provided host's addresses.
@@ -43,14 +43,24 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 type responseWriter struct {
-	w                   io.Writer // w wraps only method Write
-	http.ResponseWriter           // embedded for the other methods
+	gz                  *gzip.Writer // gz wraps methods Write and Flush
+	http.ResponseWriter              // embedded for the other methods
 }
 
 var _ http.ResponseWriter = (*responseWriter)(nil)
 var _ http.Hijacker = (*responseWriter)(nil)
+var _ http.Flusher = (*responseWriter)(nil)
 
-func (w *responseWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+func (w *responseWriter) Write(p []byte) (int, error) { return w.gz.Write(p) }
+
+// Flush writes any compressed data buffered so far
+// and flushes the underlying response, if it can.
+func (w *responseWriter) Flush() {
+	w.gz.Flush()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
 
 func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	h, ok := w.ResponseWriter.(http.Hijacker)
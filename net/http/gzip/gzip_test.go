@@ -91,3 +91,17 @@ func TestNoGzip(t *testing.T) {
 		t.Error("unexpected gzip")
 	}
 }
+
+func TestFlush(t *testing.T) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/foo", nil)
+	r.Header.Set("accept-encoding", "gzip")
+	h := Handler{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello, world")
+		w.(http.Flusher).Flush()
+		if w := w.(*responseWriter).ResponseWriter.(*httptest.ResponseRecorder); !w.Flushed || w.Body.Len() == 0 {
+			t.Errorf("after Flush, flushed = %v and body has %d bytes", w.Flushed, w.Body.Len())
+		}
+	})}
+	h.ServeHTTP(w, r)
+}
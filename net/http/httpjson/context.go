@@ -35,6 +35,18 @@ func ResponseWriter(ctx context.Context) http.ResponseWriter {
 	return ctx.Value(respKey).(http.ResponseWriter)
 }
 
+// QueryValue returns the value of the URL query parameter
+// named key in the HTTP request stored in ctx.
+// If there is no request, or no such parameter,
+// it returns the empty string.
+func QueryValue(ctx context.Context, key string) string {
+	req, ok := ctx.Value(reqKey).(*http.Request)
+	if !ok {
+		return ""
+	}
+	return req.URL.Query().Get(key)
+}
+
 // WithRequest returns a context with an HTTP request stored in it.
 // It is useful for testing.
 func WithRequest(ctx context.Context, req *http.Request) context.Context {
@@ -39,5 +39,11 @@ as JSON text to the response body.
 If the return type is omitted, the handler will send
 a default response value.
 
+Clients may send and receive CBOR instead of JSON
+by setting the Content-Type and Accept header fields
+to application/cbor. The CBOR data item is translated
+to and from the equivalent JSON text, so the function
+sees no difference.
+
 */
 package httpjson
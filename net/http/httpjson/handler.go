@@ -41,18 +41,19 @@ func Handler(f interface{}, errFunc ErrorWriter) (http.Handler, error) {
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	ctx = context.WithValue(ctx, reqKey, req)
+	ctx = context.WithValue(ctx, respKey, w)
+
 	var a []reflect.Value
 	if h.hasCtx {
-		ctx := req.Context()
-		ctx = context.WithValue(ctx, reqKey, req)
-		ctx = context.WithValue(ctx, respKey, w)
 		a = append(a, reflect.ValueOf(ctx))
 	}
 	if h.inType != nil {
 		inPtr := reflect.New(h.inType)
-		err := Read(req.Context(), req.Body, inPtr.Interface())
+		err := Read(ctx, req.Body, inPtr.Interface())
 		if err != nil {
-			h.errFunc(req.Context(), w, err)
+			h.errFunc(ctx, w, err)
 			return
 		}
 		a = append(a, inPtr.Elem())
@@ -77,11 +78,11 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		err, _ = rv[1].Interface().(error)
 	}
 	if err != nil {
-		h.errFunc(req.Context(), w, err)
+		h.errFunc(ctx, w, err)
 		return
 	}
 
-	Write(req.Context(), w, 200, res)
+	Write(ctx, w, 200, res)
 }
 
 var (
@@ -85,6 +85,31 @@ func TestReadErr(t *testing.T) {
 	}
 }
 
+func TestHandlerCBOR(t *testing.T) {
+	f := func(x struct{ A []int }) interface{} {
+		return map[string]interface{}{"sum": x.A[0] + x.A[1], "a": "b"}
+	}
+	h, err := Handler(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := "\xa1\x61a\x82\x01\x02" // {"a":[1,2]}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/cbor")
+	req.Header.Set("Accept", "application/json, application/cbor")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if got := resp.Header().Get("Content-Type"); got != "application/cbor" {
+		t.Errorf("Content-Type = %q want application/cbor", got)
+	}
+	want := "\xa2\x61a\x61b\x63sum\x03" // {"a":"b","sum":3}
+	if got := resp.Body.String(); got != want {
+		t.Errorf("response body = %x want %x", got, want)
+	}
+}
+
 func TestFuncInputTypeError(t *testing.T) {
 	cases := []interface{}{
 		0,
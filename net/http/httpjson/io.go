@@ -1,12 +1,18 @@
 package httpjson
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"reflect"
+	"strings"
 
+	"chain/encoding/cbor"
+	chainjson "chain/encoding/json"
 	"chain/errors"
 	"chain/log"
 )
@@ -15,10 +21,66 @@ import (
 // possibly including a datatype that doesn't match what we expected.
 var ErrBadRequest = errors.New("httpjson: bad request")
 
+// HeaderNumberEncoding is the request header a client
+// sets to "string" to receive every number in the response
+// as a string holding its decimal text. Clients whose
+// native numbers can't hold every uint64 exactly (such as
+// JavaScript's) use it to read amounts without losing
+// precision.
+const HeaderNumberEncoding = "Chain-Number-Encoding"
+
+// StringNumbers reports whether req asked for numbers
+// in the response to be string-encoded.
+func StringNumbers(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get(HeaderNumberEncoding), "string")
+}
+
+// HeaderBytesEncoding is the request header a client
+// sets to "base64" to send and receive byte fields
+// (those of type chain/encoding/json.HexBytes) as
+// base64 strings rather than hex.
+const HeaderBytesEncoding = "Chain-Bytes-Encoding"
+
+// Base64Bytes reports whether req asked for byte fields
+// to be base64-encoded.
+func Base64Bytes(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get(HeaderBytesEncoding), "base64")
+}
+
 // Read decodes a single JSON text from r into v.
+// If the request stored in ctx, if any, has a CBOR
+// content type, r is instead read as a single CBOR
+// data item and decoded as if it were the equivalent
+// JSON text. If the request asked for base64-encoded
+// bytes, byte fields are decoded from base64.
 // The only error it returns is ErrBadRequest
 // (wrapped with the original error message as context).
 func Read(ctx context.Context, r io.Reader, v interface{}) error {
+	req, _ := ctx.Value(reqKey).(*http.Request)
+	if req != nil && isCBOR(req.Header.Get("Content-Type")) {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return errors.WithDetail(ErrBadRequest, err.Error())
+		}
+		b, err = cbor.ToJSON(b)
+		if err != nil {
+			return errors.WithDetail(ErrBadRequest, err.Error())
+		}
+		r = bytes.NewReader(b)
+	}
+
+	if req != nil && Base64Bytes(req) {
+		var raw json.RawMessage
+		err := json.NewDecoder(r).Decode(&raw)
+		if err == nil {
+			raw, err = chainjson.FromBase64(raw, v)
+		}
+		if err != nil {
+			return errors.WithDetail(ErrBadRequest, err.Error())
+		}
+		r = bytes.NewReader(raw)
+	}
+
 	dec := json.NewDecoder(r)
 	dec.UseNumber()
 	err := dec.Decode(v)
@@ -35,15 +97,73 @@ func Read(ctx context.Context, r io.Reader, v interface{}) error {
 // Write sets the Content-Type header field to indicate
 // JSON data, writes the header using status,
 // then writes v to w.
+// If the request stored in ctx, if any, asked for
+// string-encoded numbers, every number in v is written
+// as a string; if it asked for base64-encoded bytes,
+// byte fields are written as base64. If it accepts CBOR, v is written as
+// the canonical CBOR encoding of its JSON text instead.
 // It logs any error encountered during the write.
 func Write(ctx context.Context, w http.ResponseWriter, status int, v interface{}) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
+	req, _ := ctx.Value(reqKey).(*http.Request)
+	if req == nil || !StringNumbers(req) && !acceptsCBOR(req) && !Base64Bytes(req) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(Array(v))
+		if err != nil {
+			log.Error(ctx, err)
+		}
+		return
+	}
 
-	err := json.NewEncoder(w).Encode(Array(v))
+	var (
+		contentType = "application/json; charset=utf-8"
+		b           []byte
+		err         error
+	)
+	if Base64Bytes(req) {
+		b, err = chainjson.MarshalBase64(Array(v))
+	} else {
+		b, err = json.Marshal(Array(v))
+	}
+	if err == nil && StringNumbers(req) {
+		b = chainjson.QuoteNumbers(b)
+	}
+	if err == nil && acceptsCBOR(req) {
+		contentType = cbor.ContentType
+		b, err = cbor.FromJSON(b)
+	} else {
+		b = append(b, '\n')
+	}
 	if err != nil {
 		log.Error(ctx, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
 	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+}
+
+// acceptsCBOR reports whether the Accept header of req
+// names the CBOR media type.
+func acceptsCBOR(req *http.Request) bool {
+	for _, accept := range req.Header["Accept"] {
+		for _, t := range strings.Split(accept, ",") {
+			if isCBOR(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isCBOR(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && t == cbor.ContentType
 }
 
 // Array returns an empty JSON array if v is a nil slice,
@@ -3,12 +3,15 @@ package httpjson
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
 
+	chainjson "chain/encoding/json"
 	"chain/log"
 )
 
@@ -32,6 +35,38 @@ func TestWriteArray(t *testing.T) {
 	}
 }
 
+func TestWriteStringNumbers(t *testing.T) {
+	v := map[string]interface{}{
+		"amount": uint64(1<<64 - 1),
+		"alias":  "10",
+		"items":  []float64{-1.5, 2e-7},
+	}
+	want := `{"alias":"10","amount":"18446744073709551615","items":["-1.5","2e-7"]}`
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderNumberEncoding, "string")
+	ctx := WithRequest(context.Background(), req)
+	rec := httptest.NewRecorder()
+	Write(ctx, rec, 200, v)
+	got := strings.TrimSpace(rec.Body.String())
+	if got != want {
+		t.Errorf("Write(%v) = %s want %s", v, got, want)
+	}
+}
+
+func TestWriteBase64Bytes(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(HeaderBytesEncoding, "base64")
+	ctx := WithRequest(context.Background(), req)
+	rec := httptest.NewRecorder()
+	Write(ctx, rec, 200, []chainjson.HexBytes{{0xca, 0xfe}})
+	got := strings.TrimSpace(rec.Body.String())
+	want := `["yv4="]`
+	if got != want {
+		t.Errorf("Write = %s want %s", got, want)
+	}
+}
+
 func TestWriteErr(t *testing.T) {
 	var buf bytes.Buffer
 	log.SetOutput(&buf)
@@ -56,3 +91,25 @@ type errResponse struct {
 func (r *errResponse) Write([]byte) (int, error) {
 	return 0, r.err
 }
+
+func TestReadBase64Bytes(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set(HeaderBytesEncoding, "base64")
+	ctx := WithRequest(context.Background(), req)
+	var got struct {
+		Data   chainjson.HexBytes `json:"data"`
+		Amount interface{}        `json:"amount"`
+	}
+	err := Read(ctx, strings.NewReader(`{"data":"yv4=","amount":1}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Data, []byte{0xca, 0xfe}) || got.Amount != json.Number("1") {
+		t.Errorf("Read = %x, %#v want cafe, 1", got.Data, got.Amount)
+	}
+
+	err = Read(ctx, strings.NewReader(`{"data":"cafe!"}`), &got)
+	if err == nil {
+		t.Error("Read invalid base64: got no error")
+	}
+}
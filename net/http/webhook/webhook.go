@@ -0,0 +1,72 @@
+// Package webhook delivers JSON events to an HTTP endpoint.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chain/errors"
+)
+
+// Attempts is how many times Send tries to deliver an event.
+const Attempts = 3
+
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// A Sender posts events to URL.
+type Sender struct {
+	URL string
+
+	// Client is used to send requests.
+	// If nil, a client with a 10-second timeout is used.
+	Client *http.Client
+}
+
+// Send posts event, encoded as JSON, retrying with
+// backoff if the receiver doesn't accept it.
+// It returns the last error if every attempt fails.
+func (s *Sender) Send(ctx context.Context, event interface{}) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+
+	backoff := time.Second
+	for i := 0; ; i++ {
+		err = post(ctx, client, s.URL, body)
+		if err == nil || i+1 == Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+			backoff *= 2
+		}
+	}
+}
+
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "posting webhook event")
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Wrap(fmt.Errorf("webhook returned status %d", resp.StatusCode))
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendRetry(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		b, _ := ioutil.ReadAll(req.Body)
+		if string(b) != `{"type":"test"}` {
+			t.Errorf("body = %s want {\"type\":\"test\"}", b)
+		}
+	}))
+	defer ts.Close()
+
+	s := &Sender{URL: ts.URL}
+	err := s.Send(context.Background(), map[string]string{"type": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d want 2", calls)
+	}
+}
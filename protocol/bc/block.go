@@ -67,12 +67,13 @@ func (b *Block) Scan(val interface{}) error {
 
 // Value fulfills the sql.driver.Valuer interface.
 func (b *Block) Value() (driver.Value, error) {
-	buf := new(bytes.Buffer)
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
 	_, err := b.WriteTo(buf)
 	if err != nil {
 		return nil, err
 	}
-	return buf.Bytes(), nil
+	return bufpool.CopyBytes(buf), nil
 }
 
 func (b *Block) readFrom(r io.Reader) error {
@@ -81,7 +82,7 @@ func (b *Block) readFrom(r io.Reader) error {
 		return err
 	}
 	if serflags&SerBlockTransactions == SerBlockTransactions {
-		n, _, err := blockchain.ReadVarint31(r)
+		n, _, err := blockchain.ReadListLen(r)
 		if err != nil {
 			return err
 		}
@@ -112,7 +113,7 @@ func (b *Block) writeTo(w io.Writer, serflags uint8) {
 	if serflags&SerBlockTransactions == SerBlockTransactions {
 		blockchain.WriteVarint31(w, uint64(len(b.Transactions))) // TODO(bobg): check and return error
 		for _, tx := range b.Transactions {
-			tx.WriteTo(w)
+			tx.writeTo(w, serRequired)
 		}
 	}
 }
@@ -137,7 +138,7 @@ type BlockHeader struct {
 	// to the time in the previous block.
 	TimestampMS uint64
 
-	// The next three fields constitute the block's "commitment."
+	// The next four fields constitute the block's "commitment."
 
 	// TransactionsMerkleRoot is the root hash of the Merkle binary hash
 	// tree formed by the transaction witness hashes of all transactions
@@ -152,6 +153,12 @@ type BlockHeader struct {
 	// ConsensusProgram is the predicate for validating the next block.
 	ConsensusProgram []byte
 
+	// SupplyCaps sets the cap on the cumulative issuance of each
+	// listed asset, from this block on. Being in the commitment,
+	// the caps are approved by the block's signers. The field is
+	// omitted from the serialized commitment when empty.
+	SupplyCaps []SupplyCap
+
 	// Witness is a vector of arguments to the previous block's
 	// ConsensusProgram for validating this block.
 	Witness [][]byte
@@ -247,7 +254,8 @@ func (bh *BlockHeader) readFrom(r io.Reader) (uint8, error) {
 		if err != nil {
 			return err
 		}
-		return nil
+		bh.SupplyCaps, err = readSupplyCaps(r)
+		return err
 	})
 	if err != nil {
 		return 0, err
@@ -281,7 +289,7 @@ func (bh *BlockHeader) WriteForSigTo(w io.Writer) (int64, error) {
 
 // writeTo writes bh to w.
 func (bh *BlockHeader) writeTo(w io.Writer, serflags uint8) error {
-	w.Write([]byte{serflags})
+	writeByte(w, serflags)
 
 	var err error
 
@@ -312,7 +320,10 @@ func (bh *BlockHeader) writeTo(w io.Writer, serflags uint8) error {
 			return err
 		}
 		_, err = blockchain.WriteVarstr31(w, bh.ConsensusProgram)
-		return err
+		if err != nil {
+			return err
+		}
+		return writeSupplyCaps(w, bh.SupplyCaps)
 	})
 	if err != nil {
 		return err
@@ -330,3 +341,57 @@ func (bh *BlockHeader) writeTo(w io.Writer, serflags uint8) error {
 
 	return nil
 }
+
+// A SupplyCap limits the total amount of an asset
+// that can ever be issued.
+type SupplyCap struct {
+	AssetID AssetID `json:"asset_id"`
+	Amount  uint64  `json:"amount"`
+}
+
+// readSupplyCaps reads the supply caps at the end of a block
+// commitment. A commitment without them ends before the count.
+func readSupplyCaps(r io.Reader) ([]SupplyCap, error) {
+	n, _, err := blockchain.ReadListLen(r)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var caps []SupplyCap
+	for ; n > 0; n-- {
+		var c SupplyCap
+		_, err = io.ReadFull(r, c.AssetID[:])
+		if err != nil {
+			return nil, err
+		}
+		c.Amount, _, err = blockchain.ReadVarint63(r)
+		if err != nil {
+			return nil, err
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
+
+func writeSupplyCaps(w io.Writer, caps []SupplyCap) error {
+	if len(caps) == 0 {
+		return nil
+	}
+	_, err := blockchain.WriteVarint31(w, uint64(len(caps)))
+	if err != nil {
+		return err
+	}
+	for _, c := range caps {
+		_, err = w.Write(c.AssetID[:])
+		if err != nil {
+			return err
+		}
+		_, err = blockchain.WriteVarint63(w, c.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"io/ioutil"
 	"reflect"
 	"testing"
 	"time"
@@ -171,3 +172,80 @@ func TestSmallBlock(t *testing.T) {
 		t.Errorf("small block bytes = %x want %x", got, want)
 	}
 }
+
+func TestBlockSupplyCaps(t *testing.T) {
+	header := BlockHeader{
+		Version:    NewBlockVersion,
+		Height:     1,
+		SupplyCaps: []SupplyCap{{AssetID: AssetID{1}, Amount: 1000}},
+	}
+
+	got := serialize(t, &header)
+	wantHex := ("01" + // serialization flags
+		"01" + // version
+		"01" + // block height
+		"0000000000000000000000000000000000000000000000000000000000000000" + // prev block hash
+		"00" + // timestamp
+		"64" + // commitment extensible field length
+		"0000000000000000000000000000000000000000000000000000000000000000" + // transactions merkle root
+		"0000000000000000000000000000000000000000000000000000000000000000" + // assets merkle root
+		"00" + // consensus program
+		"01" + // number of supply caps
+		"0100000000000000000000000000000000000000000000000000000000000000" + // supply cap 0, asset id
+		"e807" + // supply cap 0, amount
+		"01" + // witness extensible string length
+		"00") // witness number of witness args
+	want, _ := hex.DecodeString(wantHex)
+	if !bytes.Equal(got, want) {
+		t.Errorf("block header bytes = %x want %x", got, want)
+	}
+
+	var decoded BlockHeader
+	err := decoded.Scan(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, header) {
+		t.Errorf("decoded block header = %+v want %+v", decoded, header)
+	}
+}
+
+func BenchmarkBlockWriteTo(b *testing.B) {
+	block := benchBlock(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		block.WriteTo(ioutil.Discard)
+	}
+}
+
+func BenchmarkBlockValue(b *testing.B) {
+	block := benchBlock(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		block.Value()
+	}
+}
+
+func benchBlock(ntx int) *Block {
+	block := &Block{
+		BlockHeader: BlockHeader{
+			Version: NewBlockVersion,
+			Height:  1,
+		},
+	}
+	for i := 0; i < ntx; i++ {
+		tx := NewTx(TxData{
+			Version: CurrentTransactionVersion,
+			Inputs: []*TxInput{
+				NewSpendInput(Hash{}, uint32(i), [][]byte{{1}}, AssetID{}, 1, []byte{0x51}, nil),
+			},
+			Outputs: []*TxOutput{
+				NewTxOutput(AssetID{}, 1, []byte{0x51}, nil),
+			},
+		})
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block
+}
@@ -10,6 +10,7 @@ import (
 
 	"chain/crypto/sha3pool"
 	"chain/encoding/blockchain"
+	"chain/encoding/bufpool"
 	"chain/errors"
 )
 
@@ -17,6 +18,12 @@ import (
 // supported transaction version.
 const CurrentTransactionVersion = 1
 
+// MaxVMVersion is the latest VM version that programs
+// in transactions of the current version may use.
+// VM version 2 adds merkle proof verification to version 1.
+// VM version 3 adds introspection of the including block.
+const MaxVMVersion = 3
+
 // Tx holds a transaction along with its hash.
 type Tx struct {
 	TxData
@@ -84,13 +91,19 @@ func (tx *TxData) HasIssuance() bool {
 	return false
 }
 
+// UnmarshalText decodes a transaction from its hex
+// text, as found in API requests. Since that input is
+// untrusted, it applies blockchain.APILimits.
+// Transactions read as part of a block aren't limited.
 func (tx *TxData) UnmarshalText(p []byte) error {
 	b := make([]byte, hex.DecodedLen(len(p)))
 	_, err := hex.Decode(b, p)
 	if err != nil {
 		return err
 	}
-	return tx.readFrom(bytes.NewReader(b))
+	r := blockchain.NewReader(bytes.NewReader(b), blockchain.APILimits)
+	defer blockchain.PutReader(r)
+	return tx.readFrom(r)
 }
 
 func (tx *TxData) Scan(val interface{}) error {
@@ -102,12 +115,13 @@ func (tx *TxData) Scan(val interface{}) error {
 }
 
 func (tx *TxData) Value() (driver.Value, error) {
-	b := new(bytes.Buffer)
+	b := bufpool.Get()
+	defer bufpool.Put(b)
 	_, err := tx.WriteTo(b)
 	if err != nil {
 		return nil, err
 	}
-	return b.Bytes(), nil
+	return bufpool.CopyBytes(b), nil
 }
 
 func (tx *TxData) readFrom(r io.Reader) error {
@@ -145,7 +159,7 @@ func (tx *TxData) readFrom(r io.Reader) error {
 		return err
 	}
 
-	n, _, err := blockchain.ReadVarint31(r)
+	n, _, err := blockchain.ReadListLen(r)
 	if err != nil {
 		return err
 	}
@@ -158,7 +172,7 @@ func (tx *TxData) readFrom(r io.Reader) error {
 		tx.Inputs = append(tx.Inputs, ti)
 	}
 
-	n, _, err = blockchain.ReadVarint31(r)
+	n, _, err = blockchain.ReadListLen(r)
 	if err != nil {
 		return err
 	}
@@ -281,9 +295,10 @@ func (s *SigHasher) Hash(idx uint32) Hash {
 	si, ok := inp.TypedInput.(*SpendInput)
 	if ok {
 		// inp is a spend
-		var ocBuf bytes.Buffer
-		si.OutputCommitment.writeTo(&ocBuf, inp.AssetVersion)
-		sha3pool.Sum256(outHash[:], ocBuf.Bytes())
+		oc := sha3pool.Get256()
+		si.OutputCommitment.writeTo(oc, inp.AssetVersion)
+		oc.Read(outHash[:])
+		sha3pool.Put256(oc)
 	} else {
 		// inp is an issuance
 		outHash = EmptyStringHash
@@ -297,8 +312,9 @@ func (s *SigHasher) Hash(idx uint32) Hash {
 }
 
 func (tx *TxData) MarshalText() ([]byte, error) {
-	var buf bytes.Buffer
-	tx.WriteTo(&buf) // error is impossible
+	buf := bufpool.Get()
+	defer bufpool.Put(buf)
+	tx.WriteTo(buf) // error is impossible
 	b := make([]byte, hex.EncodedLen(buf.Len()))
 	hex.Encode(b, buf.Bytes())
 	return b, nil
@@ -313,7 +329,7 @@ func (tx *TxData) WriteTo(w io.Writer) (int64, error) {
 
 // assumes w has sticky errors
 func (tx *TxData) writeTo(w io.Writer, serflags byte) {
-	w.Write([]byte{serflags})
+	writeByte(w, serflags)
 	blockchain.WriteVarint63(w, tx.Version) // TODO(bobg): check and return error
 
 	// common fields
@@ -383,6 +399,20 @@ func (a *AssetAmount) writeTo(w io.Writer) error {
 	return err
 }
 
+// byteValues holds every byte value, so that writeByte can
+// pass a one-byte slice of it to w without allocating.
+var byteValues [256]byte
+
+func init() {
+	for i := range byteValues {
+		byteValues[i] = byte(i)
+	}
+}
+
+func writeByte(w io.Writer, b byte) (int, error) {
+	return w.Write(byteValues[b : int(b)+1])
+}
+
 // assumes w has sticky errors
 func writeRefData(w io.Writer, data []byte, serflags byte) {
 	if serflags&SerMetadata != 0 {
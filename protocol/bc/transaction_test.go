@@ -10,6 +10,7 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 
+	"chain/encoding/blockchain"
 	"chain/errors"
 )
 
@@ -430,3 +431,21 @@ func BenchmarkOutpointWriteTo(b *testing.B) {
 		o.WriteTo(ioutil.Discard)
 	}
 }
+
+func TestTxLimits(t *testing.T) {
+	// A transaction claiming 1<<20 + 1 inputs, with none present.
+	b, _ := hex.DecodeString("0701020000" + "00" + "818040")
+
+	var tx TxData
+	err := tx.UnmarshalText([]byte(hex.EncodeToString(b)))
+	if errors.Root(err) != blockchain.ErrTooLong {
+		t.Errorf("UnmarshalText error = %v want %v", err, blockchain.ErrTooLong)
+	}
+
+	// Transactions read from blocks aren't limited,
+	// so this one only runs out of input.
+	err = tx.Scan(b)
+	if errors.Root(err) == blockchain.ErrTooLong || err == nil {
+		t.Errorf("Scan error = %v want EOF", err)
+	}
+}
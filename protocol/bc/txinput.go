@@ -285,7 +285,7 @@ func (t *TxInput) WriteInputCommitment(w io.Writer) error {
 	if t.AssetVersion == 1 {
 		switch inp := t.TypedInput.(type) {
 		case *IssuanceInput:
-			_, err := w.Write([]byte{0}) // issuance type
+			_, err := writeByte(w, 0) // issuance type
 			if err != nil {
 				return err
 			}
@@ -302,7 +302,7 @@ func (t *TxInput) WriteInputCommitment(w io.Writer) error {
 			return err
 
 		case *SpendInput:
-			_, err := w.Write([]byte{1}) // spend type
+			_, err := writeByte(w, 1) // spend type
 			if err != nil {
 				return err
 			}
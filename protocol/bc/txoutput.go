@@ -80,7 +80,7 @@ func (oc *OutputCommitment) readFrom(r io.Reader, txVersion, assetVersion uint64
 			return errors.Wrap(err, "reading VM version")
 		}
 
-		if oc.VMVersion != 1 {
+		if oc.VMVersion < 1 || oc.VMVersion > MaxVMVersion {
 			return fmt.Errorf("unrecognized VM version %d for asset version 1", oc.VMVersion)
 		}
 
@@ -14,9 +14,9 @@ import (
 	"chain/protocol/vmutil"
 )
 
-// maxBlockTxs limits the number of transactions
+// MaxBlockTxs limits the number of transactions
 // included in each block.
-const maxBlockTxs = 10000
+const MaxBlockTxs = 10000
 
 // saveSnapshotFrequency stores how often to save a state
 // snapshot to the Store.
@@ -42,20 +42,41 @@ func (c *Chain) GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
 // After generating the block, the pending transaction pool will be
 // empty.
 func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *state.Snapshot, now time.Time, txs []*bc.Tx) (b *bc.Block, result *state.Snapshot, err error) {
+	return c.GenerateBlockFromTree(ctx, prev, snapshot, now, txs, nil, nil)
+}
+
+// GenerateBlockFromTree is like GenerateBlock, but it also takes
+// txTree, a merkle tree of exactly txs, in order, maintained as the
+// pending transactions arrived. If every tx in txs makes it into the
+// block in its original order, as is usual, the block's transactions
+// merkle root is taken from txTree instead of being recomputed.
+// It commits caps, supply caps for the block's signers to
+// approve, in the block; they apply to its transactions.
+// If txTree and caps are nil, GenerateBlockFromTree is
+// equivalent to GenerateBlock.
+func (c *Chain) GenerateBlockFromTree(ctx context.Context, prev *bc.Block, snapshot *state.Snapshot, now time.Time, txs []*bc.Tx, txTree *validation.MerkleTree, caps []bc.SupplyCap) (b *bc.Block, result *state.Snapshot, err error) {
 	timestampMS := bc.Millis(now)
 	if timestampMS < prev.TimestampMS {
 		return nil, nil, fmt.Errorf("timestamp %d is earlier than prevblock timestamp %d", timestampMS, prev.TimestampMS)
 	}
+	if txTree != nil && txTree.Len() != len(txs) {
+		txTree = nil // not a tree of txs; don't trust it
+	}
 
 	// Topologically sort the transactions, if needed.
 	if !isTopSorted(txs) {
 		log.Messagef(ctx, "set of %d txs not in topo order; sorting", len(txs))
 		txs = topSort(txs)
+		txTree = nil
 	}
 
 	// Make a copy of the state that we can apply our changes to.
 	result = state.Copy(snapshot)
 	result.PruneIssuances(timestampMS)
+	err = validation.ApplySupplyCaps(result, caps)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	b = &bc.Block{
 		BlockHeader: bc.BlockHeader{
@@ -64,11 +85,13 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 			PreviousBlockHash: prev.Hash(),
 			TimestampMS:       timestampMS,
 			ConsensusProgram:  prev.ConsensusProgram,
+			SupplyCaps:        caps,
 		},
 	}
 
+	var runCost int64
 	for _, tx := range txs {
-		if len(b.Transactions) >= maxBlockTxs {
+		if len(b.Transactions) >= MaxBlockTxs {
 			break
 		}
 
@@ -78,15 +101,33 @@ func (c *Chain) GenerateBlock(ctx context.Context, prev *bc.Block, snapshot *sta
 			continue
 		}
 
+		var cost validation.Cost
+		if c.MaxBlockCost > 0 {
+			cost, err = c.txCostInBlock(tx, &b.BlockHeader)
+			if err != nil || cost.RunCost > c.MaxBlockCost {
+				continue // could never be in a block
+			}
+			if runCost+cost.RunCost > c.MaxBlockCost {
+				// Stop here rather than skipping ahead, so the
+				// block holds a prefix of txs, as when it's full.
+				break
+			}
+		}
+
 		if validation.ConfirmTx(result, c.InitialBlockHash, b, tx) == nil {
 			err = validation.ApplyTx(result, tx)
 			if err != nil {
 				return nil, nil, err
 			}
 			b.Transactions = append(b.Transactions, tx)
+			runCost += cost.RunCost
 		}
 	}
-	b.TransactionsMerkleRoot = validation.CalcMerkleRoot(b.Transactions)
+	if txTree != nil && len(b.Transactions) == len(txs) {
+		b.TransactionsMerkleRoot = txTree.Root()
+	} else {
+		b.TransactionsMerkleRoot = validation.CalcMerkleRoot(b.Transactions)
+	}
 	b.AssetsMerkleRoot = result.Tree.RootHash()
 	return b, result, nil
 }
@@ -7,9 +7,11 @@ import (
 	"testing"
 	"time"
 
+	"chain/errors"
 	"chain/protocol/bc"
 	"chain/protocol/memstore"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 	"chain/testutil"
 )
 
@@ -186,6 +188,157 @@ func TestGenerateBlock(t *testing.T) {
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("generated block:\ngot:  %+v\nwant: %+v", got, want)
 	}
+
+	var tree validation.MerkleTree
+	for _, tx := range txs {
+		tree.Append(tx)
+	}
+	got, _, err = c.GenerateBlockFromTree(ctx, b1, state.Empty(), now, txs, &tree, nil)
+	if err != nil {
+		t.Fatalf("err got = %v want nil", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generated block from tree:\ngot:  %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestGenerateBlockMaxCost(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, b1 := newTestChain(t, now)
+
+	trueProg := []byte{0x51} // OP_TRUE, run cost 10
+	var txs []*bc.Tx
+	for i := 0; i < 3; i++ {
+		in := bc.NewIssuanceInput([]byte{byte(i)}, 1, nil, b1.Hash(), trueProg, nil, nil)
+		txs = append(txs, bc.NewTx(bc.TxData{
+			Version: 1,
+			MinTime: bc.Millis(now) - 1000,
+			MaxTime: bc.Millis(now) + 1000,
+			Inputs:  []*bc.TxInput{in},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(in.AssetID(), 1, trueProg, nil)},
+		}))
+	}
+
+	cases := []struct {
+		maxCost int64
+		want    int
+	}{
+		{0, 3},
+		{30, 3},
+		{25, 2},
+		{5, 0},
+	}
+	for _, tc := range cases {
+		c.MaxBlockCost = tc.maxCost
+		got, _, err := c.GenerateBlock(ctx, b1, state.Empty(), now, txs)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if len(got.Transactions) != tc.want {
+			t.Errorf("max cost %d: block has %d txs want %d", tc.maxCost, len(got.Transactions), tc.want)
+		}
+		cost, err := c.BlockCost(got)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if cost.RunCost != int64(10*tc.want) {
+			t.Errorf("max cost %d: block run cost = %d want %d", tc.maxCost, cost.RunCost, 10*tc.want)
+		}
+	}
+}
+
+func TestGenerateBlockSupplyCaps(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, b1 := newTestChain(t, now)
+
+	trueProg := []byte{0x51}
+	var txs []*bc.Tx
+	for i := 0; i < 3; i++ {
+		in := bc.NewIssuanceInput([]byte{byte(i)}, 1, nil, b1.Hash(), trueProg, nil, nil)
+		txs = append(txs, bc.NewTx(bc.TxData{
+			Version: 1,
+			MinTime: bc.Millis(now) - 1000,
+			MaxTime: bc.Millis(now) + 1000,
+			Inputs:  []*bc.TxInput{in},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(in.AssetID(), 1, trueProg, nil)},
+		}))
+	}
+	assetID := txs[0].Outputs[0].AssetID
+
+	caps := []bc.SupplyCap{{AssetID: assetID, Amount: 2}}
+	got, s, err := c.GenerateBlockFromTree(ctx, b1, state.Empty(), now, txs, nil, caps)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(got.Transactions) != 2 {
+		t.Errorf("block has %d txs want 2", len(got.Transactions))
+	}
+	if sc := s.SupplyCaps[assetID]; sc.Limit != 2 || sc.Issued != 2 {
+		t.Errorf("supply cap = %+v want limit 2 issued 2", sc)
+	}
+
+	_, err = c.ValidateBlock(ctx, state.Empty(), b1, got)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A block issuing more than its caps allow is invalid.
+	got.SupplyCaps = []bc.SupplyCap{{AssetID: assetID, Amount: 1}}
+	_, err = c.ValidateBlock(ctx, state.Empty(), b1, got)
+	if errors.Root(err) != ErrBadBlock {
+		t.Errorf("validating block over its supply cap: err = %v want %v", err, ErrBadBlock)
+	}
+}
+
+func TestSupplyCapCountsEarlierIssuance(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, b1 := newTestChain(t, now)
+
+	trueProg := []byte{0x51}
+	issue := func(nonce byte, amount uint64) *bc.Tx {
+		in := bc.NewIssuanceInput([]byte{nonce}, amount, nil, b1.Hash(), trueProg, nil, nil)
+		return bc.NewTx(bc.TxData{
+			Version: 1,
+			MinTime: bc.Millis(now) - 1000,
+			MaxTime: bc.Millis(now) + 10000,
+			Inputs:  []*bc.TxInput{in},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(in.AssetID(), amount, trueProg, nil)},
+		})
+	}
+	tx := issue(0, 3)
+	assetID := tx.Outputs[0].AssetID
+
+	// Issue before the asset is capped.
+	b2, s2, err := c.GenerateBlockFromTree(ctx, b1, state.Empty(), now, []*bc.Tx{tx}, nil, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if s2.Issued[assetID] != 3 {
+		t.Errorf("issued = %d want 3", s2.Issued[assetID])
+	}
+
+	// A cap below what's been issued is invalid.
+	_, _, err = c.GenerateBlockFromTree(ctx, b2, s2, now.Add(time.Second), nil, nil, []bc.SupplyCap{{AssetID: assetID, Amount: 2}})
+	if errors.Root(err) != validation.ErrBadSupplyCaps {
+		t.Errorf("cap below issued amount: err = %v want %v", err, validation.ErrBadSupplyCaps)
+	}
+
+	// A cap of 4 leaves room for 1 more.
+	caps := []bc.SupplyCap{{AssetID: assetID, Amount: 4}}
+	txs := []*bc.Tx{issue(1, 1), issue(2, 1)}
+	b3, s3, err := c.GenerateBlockFromTree(ctx, b2, s2, now.Add(time.Second), txs, nil, caps)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if len(b3.Transactions) != 1 {
+		t.Errorf("block has %d txs want 1", len(b3.Transactions))
+	}
+	if sc := s3.SupplyCaps[assetID]; sc.Limit != 4 || sc.Issued != 4 {
+		t.Errorf("supply cap = %+v want limit 4 issued 4", sc)
+	}
 }
 
 func TestValidateBlockForSig(t *testing.T) {
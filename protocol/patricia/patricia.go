@@ -12,8 +12,6 @@
 package patricia
 
 import (
-	"bytes"
-
 	"chain/crypto/sha3pool"
 	"chain/errors"
 	"chain/protocol/bc"
@@ -44,7 +42,7 @@ type Leaf struct {
 func Reconstruct(vals []Leaf) (*Tree, error) {
 	t := new(Tree)
 	for _, kv := range vals {
-		key := bitKey(kv.Key)
+		key := newPrefix(kv.Key)
 		hash := kv.Hash
 		if t.root == nil {
 			t.root = &node{key: key, hash: &hash, isLeaf: true}
@@ -102,7 +100,7 @@ func (t *Tree) ContainsKey(bkey []byte) bool {
 	if t.root == nil {
 		return false
 	}
-	return t.lookup(t.root, bitKey(bkey)) != nil
+	return t.lookup(t.root, newPrefix(bkey)) != nil
 }
 
 // Contains returns true if the tree contains the provided
@@ -112,8 +110,7 @@ func (t *Tree) Contains(bkey, val []byte) bool {
 		return false
 	}
 
-	key := bitKey(bkey)
-	n := t.lookup(t.root, key)
+	n := t.lookup(t.root, newPrefix(bkey))
 
 	var hash bc.Hash
 	h := sha3pool.Get256()
@@ -124,18 +121,18 @@ func (t *Tree) Contains(bkey, val []byte) bool {
 	return n != nil && n.Hash() == hash
 }
 
-func (t *Tree) lookup(n *node, key []uint8) *node {
-	if bytes.Equal(n.key, key) {
+func (t *Tree) lookup(n *node, key prefix) *node {
+	if key.equal(n.key) {
 		if !n.isLeaf {
 			return nil
 		}
 		return n
 	}
-	if !bytes.HasPrefix(key, n.key) {
+	if !key.hasPrefix(n.key) {
 		return nil
 	}
 
-	bit := key[len(n.key)]
+	bit := key.bit(n.key.n)
 	return t.lookup(n.children[bit], key)
 }
 
@@ -147,7 +144,7 @@ func (t *Tree) lookup(n *node, key []uint8) *node {
 // and its value is updated, leaving the structure of
 // the tree alone.
 func (t *Tree) Insert(bkey, val []byte) error {
-	key := bitKey(bkey)
+	key := newPrefix(bkey)
 
 	var hash bc.Hash
 	h := sha3pool.Get256()
@@ -166,8 +163,8 @@ func (t *Tree) Insert(bkey, val []byte) error {
 	return err
 }
 
-func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
-	if bytes.Equal(n.key, key) {
+func (t *Tree) insert(n *node, key prefix, hash *bc.Hash) (*node, error) {
+	if key.equal(n.key) {
 		if !n.isLeaf {
 			return n, errors.Wrap(ErrPrefix)
 		}
@@ -180,11 +177,11 @@ func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
 		return n, nil
 	}
 
-	if bytes.HasPrefix(key, n.key) {
+	if key.hasPrefix(n.key) {
 		if n.isLeaf {
 			return n, errors.Wrap(ErrPrefix)
 		}
-		bit := key[len(n.key)]
+		bit := key.bit(n.key.n)
 
 		child := n.children[bit]
 		child, err := t.insert(child, key, hash)
@@ -200,14 +197,15 @@ func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
 
 	common := commonPrefixLen(n.key, key)
 	newNode := &node{
-		key: key[:common],
+		key: key.truncate(common),
 	}
-	newNode.children[key[common]] = &node{
+	bit := key.bit(common)
+	newNode.children[bit] = &node{
 		key:    key,
 		hash:   hash,
 		isLeaf: true,
 	}
-	newNode.children[1-key[common]] = n
+	newNode.children[1-bit] = n
 	return newNode, nil
 }
 
@@ -215,30 +213,28 @@ func (t *Tree) insert(n *node, key []uint8, hash *bc.Hash) (*node, error) {
 // After removing the node, it will rearrange the tree
 // to the optimal structure.
 func (t *Tree) Delete(bkey []byte) error {
-	key := bitKey(bkey)
-
 	if t.root == nil {
 		return nil
 	}
 
 	var err error
-	t.root, err = t.delete(t.root, key)
+	t.root, err = t.delete(t.root, newPrefix(bkey))
 	return err
 }
 
-func (t *Tree) delete(n *node, key []uint8) (*node, error) {
-	if bytes.Equal(key, n.key) {
+func (t *Tree) delete(n *node, key prefix) (*node, error) {
+	if key.equal(n.key) {
 		if !n.isLeaf {
 			return n, errors.Wrap(ErrPrefix)
 		}
 		return nil, nil
 	}
 
-	if !bytes.HasPrefix(key, n.key) {
+	if !key.hasPrefix(n.key) {
 		return n, nil
 	}
 
-	bit := key[len(n.key)]
+	bit := key.bit(n.key.n)
 	newChild, err := t.delete(n.children[bit], key)
 	if err != nil {
 		return nil, err
@@ -250,7 +246,7 @@ func (t *Tree) delete(n *node, key []uint8) (*node, error) {
 
 	newNode := new(node)
 	*newNode = *n
-	newNode.key = newChild.key[:len(n.key)] // only use slices of leaf node keys
+	newNode.key = newChild.key.truncate(n.key.n) // only use slices of leaf node keys
 	newNode.children[bit] = newChild
 	newNode.hash = nil
 
@@ -266,38 +262,58 @@ func (t *Tree) RootHash() bc.Hash {
 	return root.Hash()
 }
 
-// bitKey takes a byte array and returns a key that can
-// be used inside insert and delete operations.
-func bitKey(byteKey []byte) []uint8 {
-	key := make([]uint8, 0, len(byteKey)*8)
-	for _, b := range byteKey {
-		for i := uint(0); i < 8; i++ {
-			key = append(key, (b>>(7-i))&1)
-		}
-	}
-	return key
+// prefix is a bit string packed into bytes, most significant
+// bit first. Leaf nodes own the backing array of their prefix;
+// interior nodes share a slice of the backing array of one of
+// their descendant leaves, so only the first n bits of an
+// interior node's bytes are significant.
+//
+// Packing the bits (rather than storing one byte per bit)
+// cuts the memory used by keys in the tree by a factor of 8,
+// which dominates the size of large state trees.
+type prefix struct {
+	bytes []byte
+	n     int
 }
 
-// byteKey is the inverse of bitKey.
-func byteKey(bitKey []uint8) (key []byte) {
-	key = make([]byte, len(bitKey)/8)
-	for i := uint(0); i < uint(len(key)); i++ {
-		var b byte
-		for j := uint(0); j < 8; j++ {
-			bit := bitKey[i*8+j]
-			b |= bit << (7 - j)
-		}
-		key[i] = b
-	}
-	return key
+// newPrefix returns a prefix containing all the bits of
+// b. It copies b so the tree never aliases caller memory.
+func newPrefix(b []byte) prefix {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return prefix{bytes: c, n: len(b) * 8}
 }
 
-func commonPrefixLen(a, b []uint8) int {
+// bit returns the i'th bit of p, either 0 or 1.
+func (p prefix) bit(i int) uint8 {
+	return (p.bytes[i/8] >> (7 - uint(i%8))) & 1
+}
+
+// truncate returns the first n bits of p. It shares
+// the backing array of p.
+func (p prefix) truncate(n int) prefix {
+	return prefix{bytes: p.bytes[:(n+7)/8], n: n}
+}
+
+func (p prefix) equal(q prefix) bool {
+	return p.n == q.n && commonPrefixLen(p, q) == p.n
+}
+
+// hasPrefix reports whether q is a prefix of p.
+func (p prefix) hasPrefix(q prefix) bool {
+	return q.n <= p.n && commonPrefixLen(p, q) == q.n
+}
+
+func commonPrefixLen(a, b prefix) int {
+	max := a.n
+	if b.n < max {
+		max = b.n
+	}
 	var common int
-	for i := 0; i < len(a) && i < len(b); i++ {
-		if a[i] != b[i] {
-			break
-		}
+	for common+8 <= max && a.bytes[common/8] == b.bytes[common/8] {
+		common += 8
+	}
+	for common < max && a.bit(common) == b.bit(common) {
 		common++
 	}
 	return common
@@ -305,7 +321,7 @@ func commonPrefixLen(a, b []uint8) int {
 
 // node is a leaf or branch node in a tree
 type node struct {
-	key      []uint8
+	key      prefix
 	hash     *bc.Hash
 	isLeaf   bool
 	children [2]*node
@@ -313,7 +329,11 @@ type node struct {
 
 // Key returns the key for the current node as bytes, as it
 // was provided to Insert.
-func (n *node) Key() []byte { return byteKey(n.key) }
+func (n *node) Key() []byte {
+	k := make([]byte, n.key.n/8)
+	copy(k, n.key.bytes)
+	return k
+}
 
 // Hash will return the hash for this node.
 func (n *node) Hash() bc.Hash {
@@ -137,7 +137,7 @@ func TestLookup(t *testing.T) {
 	tr := &Tree{
 		root: &node{key: bools("11111111"), hash: &hashes[0], isLeaf: true},
 	}
-	got := tr.lookup(tr.root, bitKey(bits("11111111")))
+	got := tr.lookup(tr.root, newPrefix(bits("11111111")))
 	if !reflect.DeepEqual(got, tr.root) {
 		t.Log("lookup on 1-node tree")
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root, 0))
@@ -146,7 +146,7 @@ func TestLookup(t *testing.T) {
 	tr = &Tree{
 		root: &node{key: bools("11111110"), hash: &hashes[1], isLeaf: true},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11111111")))
+	got = tr.lookup(tr.root, newPrefix(bits("11111111")))
 	if got != nil {
 		t.Log("lookup nonexistent key on 1-node tree")
 		t.Fatalf("got:\n%swant nil", prettyNode(got, 0))
@@ -162,7 +162,7 @@ func TestLookup(t *testing.T) {
 			},
 		},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11110000")))
+	got = tr.lookup(tr.root, newPrefix(bits("11110000")))
 	if !reflect.DeepEqual(got, tr.root.children[0]) {
 		t.Log("lookup root's first child")
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[0], 0))
@@ -185,7 +185,7 @@ func TestLookup(t *testing.T) {
 			},
 		},
 	}
-	got = tr.lookup(tr.root, bitKey(bits("11111100")))
+	got = tr.lookup(tr.root, newPrefix(bits("11111100")))
 	if !reflect.DeepEqual(got, tr.root.children[1].children[0]) {
 		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[1].children[0], 0))
 	}
@@ -230,7 +230,7 @@ func TestInsert(t *testing.T) {
 	want := &Tree{
 		root: &node{key: bools("11111111"), hash: &hashes[0], isLeaf: true},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		log.Printf("want hash? %s", hashes[0])
 		t.Log("insert into empty tree")
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
@@ -241,7 +241,7 @@ func TestInsert(t *testing.T) {
 	want = &Tree{
 		root: &node{key: bools("11111111"), hash: &hashes[1], isLeaf: true},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Log("inserting the same key updates the value, does not add a new node")
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
@@ -258,7 +258,7 @@ func TestInsert(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Log("different key creates a fork")
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
@@ -282,7 +282,7 @@ func TestInsert(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 
@@ -312,7 +312,7 @@ func TestInsert(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Log("a fork is created for each level of similar key")
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
@@ -350,7 +350,7 @@ func TestInsert(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Log("compressed branch node is split")
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
@@ -401,7 +401,7 @@ func TestDelete(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 
@@ -417,13 +417,13 @@ func TestDelete(t *testing.T) {
 			},
 		},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 
 	tr.Delete(bits("11110011"))
 	tr.RootHash()
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 
@@ -432,69 +432,72 @@ func TestDelete(t *testing.T) {
 	want = &Tree{
 		root: &node{key: bools("11111111"), hash: &hashes[3], isLeaf: true},
 	}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 
 	tr.Delete(bits("11111111"))
 	tr.RootHash()
 	want = &Tree{}
-	if !reflect.DeepEqual(tr.root, want.root) {
+	if !nodesEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
 	}
 }
 
-func TestBoolKey(t *testing.T) {
+func TestCommonPrefixLen(t *testing.T) {
 	cases := []struct {
-		b []byte
-		w []uint8
+		a, b prefix
+		w    int
 	}{{
-		b: nil,
-		w: []uint8{},
+		a: newPrefix(nil),
+		b: newPrefix([]byte{0x8f}),
+		w: 0,
 	}, {
-		b: []byte{0x8f},
-		w: []uint8{1, 0, 0, 0, 1, 1, 1, 1},
+		a: newPrefix([]byte{0x8f}),
+		b: newPrefix([]byte{0x8f}),
+		w: 8,
 	}, {
-		b: []byte{0x81},
-		w: []uint8{1, 0, 0, 0, 0, 0, 0, 1},
+		a: newPrefix([]byte{0x8f}),
+		b: newPrefix([]byte{0x81}),
+		w: 4,
+	}, {
+		a: newPrefix([]byte{0x81, 0x8f}),
+		b: newPrefix([]byte{0x81, 0x0f}),
+		w: 8,
+	}, {
+		a: newPrefix([]byte{0x81, 0x8f}).truncate(11),
+		b: newPrefix([]byte{0x81, 0x9f}),
+		w: 11,
 	}}
 
 	for _, c := range cases {
-		g := bitKey(c.b)
-
-		if !reflect.DeepEqual(g, c.w) {
-			t.Errorf("Key(0x%x) = %v want %v", c.b, g, c.w)
+		g := commonPrefixLen(c.a, c.b)
+		if g != c.w {
+			t.Errorf("commonPrefixLen(%x/%d, %x/%d) = %d want %d", c.a.bytes, c.a.n, c.b.bytes, c.b.n, g, c.w)
 		}
 	}
 }
 
-func TestByteKey(t *testing.T) {
-	cases := []struct {
-		b []uint8
-		w []byte
-	}{{
-		b: []uint8{},
-		w: []byte{},
-	}, {
-		b: []uint8{1, 0, 0, 0, 1, 1, 1, 1},
-		w: []byte{0x8f},
-	}, {
-		b: []uint8{1, 0, 0, 0, 0, 0, 0, 1},
-		w: []byte{0x81},
-	}, {
-		b: []uint8{1, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 1, 1, 1, 1},
-		w: []byte{0x81, 0x8f},
-	}}
-
-	for _, c := range cases {
-		g := byteKey(c.b)
-
-		if !reflect.DeepEqual(g, c.w) {
-			t.Errorf("byteKey(%#v) = %x want %x", c.b, g, c.w)
+func TestPrefixBit(t *testing.T) {
+	p := newPrefix([]byte{0x81, 0x8f})
+	want := []uint8{1, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 1, 1, 1, 1}
+	for i, w := range want {
+		if g := p.bit(i); g != w {
+			t.Errorf("bit(%d) = %d want %d", i, g, w)
 		}
 	}
 }
 
+func TestNodeKey(t *testing.T) {
+	b := []byte{0x81, 0x8f}
+	n := &node{key: newPrefix(b), isLeaf: true}
+	b[0] = 0 // the tree must not alias the caller's key
+	got := n.Key()
+	if want := []byte{0x81, 0x8f}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Key() = %x want %x", got, want)
+	}
+}
+
 func makeVals(num int) (vals [][]byte, hashes []bc.Hash) {
 	for i := 0; i < num; i++ {
 		v := sha3.Sum256([]byte{byte(i)})
@@ -517,11 +520,14 @@ func prettyNode(n *node, depth int) string {
 		prettyStr += "nil\n"
 		return prettyStr
 	}
-	var b int
-	if len(n.key) > 31*8 {
-		b = 31 * 8
+	var bits []uint8
+	for i := 0; i < n.key.n; i++ {
+		bits = append(bits, n.key.bit(i))
+	}
+	if len(bits) > 31*8 {
+		bits = bits[31*8:]
 	}
-	prettyStr += fmt.Sprintf("key=%+v", n.key[b:])
+	prettyStr += fmt.Sprintf("key=%+v", bits)
 	if n.hash != nil {
 		prettyStr += fmt.Sprintf(" hash=%+v", n.hash)
 	}
@@ -542,9 +548,25 @@ func bits(lit string) []byte {
 	return append(b[:], byte(n))
 }
 
-func bools(lit string) []uint8 {
-	b := bitKey(bits(lit))
-	return append(b[:31*8], b[32*8-len(lit):]...)
+func bools(lit string) prefix {
+	b := bits(lit)
+	b[31] <<= 8 - uint(len(lit)) // left-align the literal bits
+	return prefix{bytes: b, n: 31*8 + len(lit)}
+}
+
+// nodesEqual compares two trees for equality, looking only at
+// the significant bits of each node's key.
+func nodesEqual(a, b *node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.isLeaf != b.isLeaf || !a.key.equal(b.key) {
+		return false
+	}
+	if !reflect.DeepEqual(a.hash, b.hash) {
+		return false
+	}
+	return nodesEqual(a.children[0], b.children[0]) && nodesEqual(a.children[1], b.children[1])
 }
 
 func hashForNonLeaf(a, b bc.Hash) bc.Hash {
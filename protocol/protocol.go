@@ -86,6 +86,7 @@ type Store interface {
 type Chain struct {
 	InitialBlockHash  bc.Hash
 	MaxIssuanceWindow time.Duration // only used by generators
+	MaxBlockCost      int64         // total run cost per block, only used by generators; 0 means no limit
 
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
@@ -10,11 +10,28 @@ import (
 // at which it should expire from the issuance memory.
 type PriorIssuances map[bc.Hash]uint64
 
+// A SupplyCap is the cap on an asset's cumulative issuance,
+// and the amount of the asset issued to date.
+type SupplyCap struct {
+	Limit  uint64
+	Issued uint64
+}
+
+// SupplyCaps maps each capped asset to its cap.
+type SupplyCaps map[bc.AssetID]SupplyCap
+
+// IssuedAmounts maps each asset to the total
+// amount of it ever issued.
+type IssuedAmounts map[bc.AssetID]uint64
+
 // Snapshot encompasses a snapshot of entire blockchain state. It
-// consists of a patricia state tree and the issuances memory.
+// consists of a patricia state tree, the issuances memory, the
+// amounts issued of each asset and the asset supply caps.
 type Snapshot struct {
-	Tree      *patricia.Tree
-	Issuances PriorIssuances
+	Tree       *patricia.Tree
+	Issuances  PriorIssuances
+	Issued     IssuedAmounts
+	SupplyCaps SupplyCaps
 }
 
 // PruneIssuances modifies a Snapshot, removing all issuance hashes
@@ -29,25 +46,35 @@ func (s *Snapshot) PruneIssuances(timestampMS uint64) {
 
 // Copy makes a copy of provided snapshot. Copying a snapshot is an
 // O(n) operation where n is the number of issuance hashes in the
-// snapshot's issuance memory.
+// snapshot's issuance memory plus the number of issued assets.
 func Copy(original *Snapshot) *Snapshot {
 	// TODO(kr): consider making type Snapshot truly immutable.
 	// We already handle it that way in many places (with explicit
 	// calls to Copy to get the right behavior).
 	c := &Snapshot{
-		Tree:      patricia.Copy(original.Tree),
-		Issuances: make(PriorIssuances, len(original.Issuances)),
+		Tree:       patricia.Copy(original.Tree),
+		Issuances:  make(PriorIssuances, len(original.Issuances)),
+		Issued:     make(IssuedAmounts, len(original.Issued)),
+		SupplyCaps: make(SupplyCaps, len(original.SupplyCaps)),
 	}
 	for k, v := range original.Issuances {
 		c.Issuances[k] = v
 	}
+	for k, v := range original.Issued {
+		c.Issued[k] = v
+	}
+	for k, v := range original.SupplyCaps {
+		c.SupplyCaps[k] = v
+	}
 	return c
 }
 
 // Empty returns an empty state snapshot.
 func Empty() *Snapshot {
 	return &Snapshot{
-		Tree:      new(patricia.Tree),
-		Issuances: make(PriorIssuances),
+		Tree:       new(patricia.Tree),
+		Issuances:  make(PriorIssuances),
+		Issued:     make(IssuedAmounts),
+		SupplyCaps: make(SupplyCaps),
 	}
 }
@@ -0,0 +1,52 @@
+package state
+
+import (
+	"math/rand"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+func BenchmarkSnapshotInsert(b *testing.B) {
+	const outputs = 10000
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchSnapshot(b, outputs)
+	}
+}
+
+func BenchmarkSnapshotRootHash(b *testing.B) {
+	const outputs = 10000
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := benchSnapshot(b, outputs)
+		s.Tree.RootHash()
+	}
+}
+
+func BenchmarkSnapshotCopy(b *testing.B) {
+	s := benchSnapshot(b, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Copy(s)
+	}
+}
+
+func benchSnapshot(b *testing.B, outputs int) *Snapshot {
+	r := rand.New(rand.NewSource(12345))
+	s := Empty()
+	for j := 0; j < outputs; j++ {
+		var p bc.Outpoint
+		_, err := r.Read(p.Hash[:])
+		if err != nil {
+			b.Fatal(err)
+		}
+		o := NewOutput(*bc.NewTxOutput(bc.AssetID{}, 1, []byte{0x51}, nil), p)
+		err = s.Tree.Insert(OutputTreeItem(o))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return s
+}
@@ -2,49 +2,173 @@ package protocol
 
 import (
 	"sync"
+	"time"
 
 	"github.com/golang/groupcache/lru"
 
 	"chain/errors"
 	"chain/protocol/bc"
+	"chain/protocol/state"
 	"chain/protocol/validation"
 )
 
 // ValidateTxCached checks a cache of prevalidated transactions
 // before attempting to perform a context-free validation of the tx.
 func (c *Chain) ValidateTxCached(tx *bc.Tx) error {
+	_, err := c.TxCost(tx)
+	return err
+}
+
+// TxCost returns the cost of validating tx, as reported by
+// validation.MeterTx, along with the result of validating it.
+// Like ValidateTxCached, it consults the cache of
+// prevalidated transactions first.
+func (c *Chain) TxCost(tx *bc.Tx) (validation.Cost, error) {
 	// Consult a cache of prevalidated transactions.
-	err, ok := c.prevalidated.lookup(tx.Hash)
+	v, ok := c.prevalidated.lookup(tx.Hash)
 	if ok {
-		return err
+		return v.cost, v.err
 	}
 
-	err = validation.CheckTxWellFormed(tx)
-	c.prevalidated.cache(tx.Hash, err)
+	cost, err := validation.MeterTx(tx)
+	c.prevalidated.cache(tx.Hash, prevalidatedTx{cost: cost, err: err})
+	return cost, err
+}
+
+// txCostInBlock is like TxCost, but it includes the cost of
+// the scripts of tx that depend on the block including it,
+// run as part of the block with the given header.
+func (c *Chain) txCostInBlock(tx *bc.Tx, block *bc.BlockHeader) (validation.Cost, error) {
+	if !validation.DependsOnBlock(tx) {
+		return c.TxCost(tx)
+	}
+	return validation.MeterTxInBlock(tx, block)
+}
+
+// ValidateTxInNextBlock runs the scripts of tx that depend on
+// the block including it, as part of the next block, as if it
+// were made now. It lets the pool turn away transactions that
+// couldn't go in the next block. Each block still runs them
+// again, so a transaction accepted now can fail later, as when
+// its time runs out.
+func (c *Chain) ValidateTxInNextBlock(tx *bc.Tx) error {
+	if !validation.DependsOnBlock(tx) {
+		return nil
+	}
+	prev, _ := c.State()
+	_, err := validation.MeterTxInBlock(tx, nextBlockHeader(prev, time.Now()))
 	return err
 }
 
+// nextBlockHeader returns a provisional header for
+// a block made at t after prev, which may be nil.
+func nextBlockHeader(prev *bc.Block, t time.Time) *bc.BlockHeader {
+	next := &bc.BlockHeader{TimestampMS: bc.Millis(t)}
+	if prev != nil {
+		next.Version = prev.Version
+		next.Height = prev.Height + 1
+	}
+	return next
+}
+
+// A DryRun is the result of validating a
+// transaction without submitting it.
+type DryRun struct {
+	// Inputs holds the result of running
+	// each input script of the transaction.
+	Inputs []validation.InputRun
+
+	// Cost is the cost of validating the transaction,
+	// as reported by validation.MeterTx.
+	Cost validation.Cost
+
+	// Err is why the transaction couldn't go in the next
+	// block, or nil if it could.
+	Err error
+}
+
+// DryRunTx validates tx fully, including its input scripts
+// and the outputs it spends, against the current state, as
+// if it were in the next block, made now. It doesn't count
+// transactions waiting to go in a block, so tx can still
+// conflict with one of those.
+func (c *Chain) DryRunTx(tx *bc.Tx) *DryRun {
+	prev, snapshot := c.State()
+	next := nextBlockHeader(prev, time.Now())
+	d := &DryRun{Inputs: validation.RunTxInputs(tx, next)}
+	d.Cost, d.Err = validation.MeterTxInBlock(tx, next)
+	if d.Err == nil {
+		d.Err = c.checkIssuanceWindow(tx)
+	}
+	if d.Err == nil && snapshot != nil {
+		d.Err = validation.ConfirmTx(snapshot, c.InitialBlockHash, &bc.Block{BlockHeader: *next}, tx)
+	}
+	return d
+}
+
+// CheckTxInBlock returns why tx can't go in block b, given
+// snapshot, the state before tx would be applied, or nil if it
+// can. It makes the checks GenerateBlock makes of each tx,
+// except that it doesn't run the input scripts that don't
+// depend on the block, as they ran when tx was submitted.
+//
+// Checked against the state after b, a tx left out of b
+// fails with the reason it was left out.
+func (c *Chain) CheckTxInBlock(snapshot *state.Snapshot, b *bc.Block, tx *bc.Tx) error {
+	err := c.checkIssuanceWindow(tx)
+	if err != nil {
+		return err
+	}
+	if c.MaxBlockCost > 0 {
+		cost, err := c.txCostInBlock(tx, &b.BlockHeader)
+		if err != nil {
+			return err
+		}
+		if cost.RunCost > c.MaxBlockCost {
+			err = errors.WithData(validation.ErrBadTx, validation.KeyReason, validation.ReasonTooCostly)
+			return errors.WithDetailf(err, "run cost %d exceeds the maximum block cost %d", cost.RunCost, c.MaxBlockCost)
+		}
+	}
+	return validation.ConfirmTx(snapshot, c.InitialBlockHash, b, tx)
+}
+
+// BlockCost returns the total cost of validating
+// the transactions in b.
+func (c *Chain) BlockCost(b *bc.Block) (validation.Cost, error) {
+	var total validation.Cost
+	for _, tx := range b.Transactions {
+		cost, err := c.txCostInBlock(tx, &b.BlockHeader)
+		if err != nil {
+			return total, errors.Wrapf(err, "tx %s", tx.Hash)
+		}
+		total = total.Add(cost)
+	}
+	return total, nil
+}
+
+type prevalidatedTx struct {
+	cost validation.Cost
+	err  error
+}
+
 type prevalidatedTxsCache struct {
 	mu  sync.Mutex
 	lru *lru.Cache
 }
 
-func (c *prevalidatedTxsCache) lookup(txID bc.Hash) (err error, ok bool) {
+func (c *prevalidatedTxsCache) lookup(txID bc.Hash) (v prevalidatedTx, ok bool) {
 	c.mu.Lock()
-	v, ok := c.lru.Get(txID)
+	x, ok := c.lru.Get(txID)
 	c.mu.Unlock()
 	if !ok {
-		return err, ok
-	}
-	if v == nil {
-		return nil, ok
+		return v, ok
 	}
-	return v.(error), ok
+	return x.(prevalidatedTx), ok
 }
 
-func (c *prevalidatedTxsCache) cache(txID bc.Hash, err error) {
+func (c *prevalidatedTxsCache) cache(txID bc.Hash, v prevalidatedTx) {
 	c.mu.Lock()
-	c.lru.Add(txID, err)
+	c.lru.Add(txID, v)
 	c.mu.Unlock()
 }
 
@@ -53,7 +177,8 @@ func (c *Chain) checkIssuanceWindow(tx *bc.Tx) error {
 		if _, ok := txi.TypedInput.(*bc.IssuanceInput); ok {
 			// TODO(tessr): consider removing 0 check once we can configure this
 			if c.MaxIssuanceWindow != 0 && tx.MinTime+bc.DurationMillis(c.MaxIssuanceWindow) < tx.MaxTime {
-				return errors.WithDetailf(validation.ErrBadTx, "issuance input's time window is larger than the network maximum (%s)", c.MaxIssuanceWindow)
+				err := errors.WithData(validation.ErrBadTx, validation.KeyReason, validation.ReasonIssuanceWindow)
+				return errors.WithDetailf(err, "issuance input's time window is larger than the network maximum (%s)", c.MaxIssuanceWindow)
 			}
 		}
 	}
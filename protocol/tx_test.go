@@ -11,6 +11,7 @@ import (
 	"chain/crypto/ed25519"
 	"chain/protocol/bc"
 	"chain/protocol/state"
+	"chain/protocol/validation"
 	"chain/protocol/vm"
 	"chain/protocol/vmutil"
 	"chain/testutil"
@@ -30,6 +31,39 @@ func TestBadMaxIssuanceWindow(t *testing.T) {
 	if len(got.Transactions) != 0 {
 		t.Error("expected issuance past max issuance window to be rejected")
 	}
+	err = c.CheckTxInBlock(state.Empty(), got, issueTx)
+	if r := validation.Reason(err); r != validation.ReasonIssuanceWindow {
+		t.Errorf("CheckTxInBlock reason = %q want %q", r, validation.ReasonIssuanceWindow)
+	}
+}
+
+func TestDryRunTx(t *testing.T) {
+	c, _ := newTestChain(t, time.Now())
+
+	// The issuance is well-formed, but for another blockchain.
+	issueTx, _, _ := issue(t, nil, nil, 1)
+	d := c.DryRunTx(issueTx)
+	if len(d.Inputs) != 1 || d.Inputs[0].Err != nil || d.Inputs[0].Cost.RunCost == 0 {
+		t.Errorf("input runs = %+v want one successful run", d.Inputs)
+	}
+	if r := validation.Reason(d.Err); r != validation.ReasonWrongBlockchain {
+		t.Errorf("err = %v want reason %q", d.Err, validation.ReasonWrongBlockchain)
+	}
+
+	// Every failing input is reported.
+	tx := issueTx.TxData
+	tx.Inputs = []*bc.TxInput{
+		bc.NewSpendInput(bc.Hash{1}, 0, nil, issueTx.Outputs[0].AssetID, 1, []byte{byte(vm.OP_FALSE)}, nil),
+		bc.NewSpendInput(bc.Hash{2}, 0, nil, issueTx.Outputs[0].AssetID, 1, []byte{byte(vm.OP_FAIL)}, nil),
+	}
+	tx.Outputs = append(tx.Outputs, tx.Outputs[0])
+	d = c.DryRunTx(bc.NewTx(tx))
+	if len(d.Inputs) != 2 || d.Inputs[0].Err != validation.ErrFalseVMResult || d.Inputs[1].Err == nil {
+		t.Errorf("input runs = %+v want two failures", d.Inputs)
+	}
+	if r := validation.Reason(d.Err); r != validation.ReasonInvalidWitness {
+		t.Errorf("err = %v want reason %q", d.Err, validation.ReasonInvalidWitness)
+	}
 }
 
 type testDest struct {
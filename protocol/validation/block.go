@@ -72,6 +72,10 @@ func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHa
 			return err
 		}
 		snapshot.PruneIssuances(block.TimestampMS)
+		err = ApplySupplyCaps(snapshot, block.SupplyCaps)
+		if err != nil {
+			return err
+		}
 
 		// TODO: Check that other block headers are valid.
 		// TODO(erykwalder): consider writing to a copy of the state tree
@@ -112,11 +116,16 @@ func ValidateBlock(ctx context.Context, snapshot *state.Snapshot, initialBlockHa
 	return g.Wait()
 }
 
-// ApplyBlock applies the transactions in the block to the state tree.
+// ApplyBlock applies the block's supply caps and
+// transactions to the state.
 func ApplyBlock(snapshot *state.Snapshot, block *bc.Block) error {
 	snapshot.PruneIssuances(block.TimestampMS)
+	err := ApplySupplyCaps(snapshot, block.SupplyCaps)
+	if err != nil {
+		return err
+	}
 	for _, tx := range block.Transactions {
-		err := ApplyTx(snapshot, tx)
+		err = ApplyTx(snapshot, tx)
 		if err != nil {
 			return err
 		}
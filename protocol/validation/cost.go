@@ -0,0 +1,21 @@
+package validation
+
+// Cost is the work done validating a transaction or block:
+// the VM run cost of its input programs, the number of
+// signatures they verified, and its serialized size in bytes.
+// Signature checks are also part of the run cost; they are
+// counted separately because they dominate validation time.
+type Cost struct {
+	RunCost   int64 `json:"run_cost"`
+	SigChecks int64 `json:"sig_checks"`
+	Bytes     int64 `json:"bytes"`
+}
+
+// Add returns the sum of c and d.
+func (c Cost) Add(d Cost) Cost {
+	return Cost{
+		RunCost:   c.RunCost + d.RunCost,
+		SigChecks: c.SigChecks + d.SigChecks,
+		Bytes:     c.Bytes + d.Bytes,
+	}
+}
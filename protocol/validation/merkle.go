@@ -21,28 +21,85 @@ func CalcMerkleRoot(transactions []*bc.Tx) (root bc.Hash) {
 		return root
 
 	case len(transactions) == 1:
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-
-		witHash := transactions[0].WitnessHash()
-		h.Write(leafPrefix)
-		h.Write(witHash[:])
-		h.Read(root[:])
-		return root
+		return leafHash(transactions[0])
 
 	default:
 		k := prevPowerOfTwo(len(transactions))
 		left := CalcMerkleRoot(transactions[:k])
 		right := CalcMerkleRoot(transactions[k:])
+		return interiorHash(left, right)
+	}
+}
+
+// MerkleTree computes the same root as CalcMerkleRoot for a
+// sequence of transactions that grows over time, such as a pending
+// tx pool. Each transaction is hashed once, when it's appended;
+// Root then takes time logarithmic in the number of transactions.
+//
+// The zero value is an empty tree.
+type MerkleTree struct {
+	n int
+
+	// The roots of the complete subtrees of the tree, in order.
+	// Their sizes are the powers of two in the binary
+	// representation of n, largest first.
+	frontier []bc.Hash
+}
+
+// Append adds tx to the end of the tree.
+func (t *MerkleTree) Append(tx *bc.Tx) {
+	h := leafHash(tx)
+	// Merge complete subtrees of equal size, just as
+	// incrementing n carries through its trailing one bits.
+	for size := 1; t.n&size != 0; size <<= 1 {
+		h = interiorHash(t.frontier[len(t.frontier)-1], h)
+		t.frontier = t.frontier[:len(t.frontier)-1]
+	}
+	t.frontier = append(t.frontier, h)
+	t.n++
+}
 
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-		h.Write(interiorPrefix)
-		h.Write(left[:])
-		h.Write(right[:])
-		h.Read(root[:])
+// Len returns the number of transactions in the tree.
+func (t *MerkleTree) Len() int {
+	return t.n
+}
+
+// Root returns the root hash of the tree.
+// It is equal to CalcMerkleRoot of the appended transactions.
+func (t *MerkleTree) Root() (root bc.Hash) {
+	if len(t.frontier) == 0 {
+		sha3pool.Sum256(root[:], nil)
 		return root
 	}
+	// Each subtree is the left sibling of the
+	// tree formed by all the smaller ones.
+	root = t.frontier[len(t.frontier)-1]
+	for i := len(t.frontier) - 2; i >= 0; i-- {
+		root = interiorHash(t.frontier[i], root)
+	}
+	return root
+}
+
+func leafHash(tx *bc.Tx) (h bc.Hash) {
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+
+	witHash := tx.WitnessHash()
+	hasher.Write(leafPrefix)
+	hasher.Write(witHash[:])
+	hasher.Read(h[:])
+	return h
+}
+
+func interiorHash(left, right bc.Hash) (h bc.Hash) {
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+
+	hasher.Write(interiorPrefix)
+	hasher.Write(left[:])
+	hasher.Write(right[:])
+	hasher.Read(h[:])
+	return h
 }
 
 // prevPowerOfTwo returns the largest power of two that is smaller than a given number.
@@ -124,6 +124,34 @@ func TestAllDuplicateLeaves(t *testing.T) {
 	}
 }
 
+func TestMerkleTree(t *testing.T) {
+	var initialBlockHash bc.Hash
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	assetID := bc.ComputeAssetID(trueProg, initialBlockHash, 1, bc.EmptyStringHash)
+
+	var (
+		tree MerkleTree
+		txs  []*bc.Tx
+	)
+	for i := uint64(0); i <= 33; i++ {
+		got, want := tree.Root(), CalcMerkleRoot(txs)
+		if got != want {
+			t.Errorf("MerkleTree.Root() with %d txs = %s want %s", len(txs), got, want)
+		}
+		if tree.Len() != len(txs) {
+			t.Errorf("MerkleTree.Len() = %d want %d", tree.Len(), len(txs))
+		}
+
+		tx := bc.NewTx(bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewIssuanceInput(nil, i+1, nil, initialBlockHash, trueProg, nil, nil)},
+			Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, i+1, trueProg, nil)},
+		})
+		tree.Append(tx)
+		txs = append(txs, tx)
+	}
+}
+
 func mustParseHash(s string) bc.Hash {
 	h, err := bc.ParseHash(s)
 	if err != nil {
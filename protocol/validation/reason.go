@@ -0,0 +1,88 @@
+package validation
+
+import "chain/errors"
+
+// KeyReason is the data key of the reason code of
+// an ErrBadTx error, one of the Reason constants.
+const KeyReason = "reason"
+
+// KeyOutpoint is the data key of the outpoint of an
+// ErrBadTx error with reason ReasonDoubleSpend.
+const KeyOutpoint = "outpoint"
+
+// Reasons a transaction is invalid.
+const (
+	// ReasonMalformed is a transaction that is invalid on its
+	// own, as when its inputs and outputs don't balance.
+	ReasonMalformed = "malformed"
+
+	// ReasonInvalidWitness is an input whose
+	// program failed with the input's arguments.
+	ReasonInvalidWitness = "invalid_witness"
+
+	// ReasonDoubleSpend is an input spending an output that isn't
+	// in the blockchain state: it was spent, or never existed.
+	ReasonDoubleSpend = "double_spend"
+
+	// ReasonDuplicateIssuance is an issuance
+	// whose nonce was used recently.
+	ReasonDuplicateIssuance = "duplicate_issuance"
+
+	// ReasonNotYetValid is a transaction whose
+	// min time is after the block's time.
+	ReasonNotYetValid = "not_yet_valid"
+
+	// ReasonExpired is a transaction whose max
+	// time is before the block's time.
+	ReasonExpired = "expired"
+
+	// ReasonIssuanceWindow is an issuance whose time
+	// window doesn't contain the block's time, or is
+	// longer than the network allows.
+	ReasonIssuanceWindow = "issuance_window"
+
+	// ReasonWrongBlockchain is an issuance
+	// for a different blockchain.
+	ReasonWrongBlockchain = "wrong_blockchain"
+
+	// ReasonSupplyCap is an issuance that
+	// exceeds its asset's supply cap.
+	ReasonSupplyCap = "supply_cap"
+
+	// ReasonTooCostly is a transaction that costs
+	// more to validate than a block can hold.
+	ReasonTooCostly = "too_costly"
+)
+
+// Reason returns the reason code of err, if its root is
+// ErrBadTx, or the empty string otherwise. An ErrBadTx
+// error without a code has reason ReasonMalformed.
+func Reason(err error) string {
+	if errors.Root(err) != ErrBadTx {
+		return ""
+	}
+	if r, ok := errors.Data(err)[KeyReason].(string); ok {
+		return r
+	}
+	return ReasonMalformed
+}
+
+func reasonFor(suberr error) string {
+	switch suberr {
+	case errNotYet:
+		return ReasonNotYetValid
+	case errTooLate:
+		return ReasonExpired
+	case errWrongBlockchain:
+		return ReasonWrongBlockchain
+	case errIssuanceTime:
+		return ReasonIssuanceWindow
+	case errDuplicateIssuance:
+		return ReasonDuplicateIssuance
+	case errInvalidOutput:
+		return ReasonDoubleSpend
+	case errSupplyCap:
+		return ReasonSupplyCap
+	}
+	return ReasonMalformed
+}
@@ -0,0 +1,124 @@
+package validation
+
+import (
+	"math"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+// ErrBadSupplyCaps is returned when a list of supply caps,
+// such as a block's, is invalid.
+var ErrBadSupplyCaps = errors.New("invalid supply caps")
+
+// "suberror" for ErrBadTx
+var errSupplyCap = errors.New("issuance exceeds asset supply cap")
+
+// CheckSupplyCaps tests that a list of supply caps
+// could be committed in a block.
+func CheckSupplyCaps(caps []bc.SupplyCap) error {
+	seen := make(map[bc.AssetID]bool, len(caps))
+	for _, c := range caps {
+		if seen[c.AssetID] {
+			return errors.WithDetailf(ErrBadSupplyCaps, "more than one cap for asset %s", c.AssetID)
+		}
+		seen[c.AssetID] = true
+		if c.Amount > math.MaxInt64 {
+			return errors.WithDetailf(ErrBadSupplyCaps, "cap for asset %s exceeds maximum value of int64", c.AssetID)
+		}
+	}
+	return nil
+}
+
+// ApplySupplyCaps sets the supply caps committed in a block,
+// replacing any earlier caps on the same assets. A cap counts
+// everything issued of its asset to date, including issuances
+// before the asset was first capped, so a cap below that total
+// is invalid. Caps take effect before the block's transactions.
+func ApplySupplyCaps(snapshot *state.Snapshot, caps []bc.SupplyCap) error {
+	err := CheckSupplyCaps(caps)
+	if err != nil {
+		return err
+	}
+
+	capped := make([]state.SupplyCap, len(caps))
+	for i, c := range caps {
+		sc, ok := snapshot.SupplyCaps[c.AssetID]
+		if !ok {
+			sc.Issued = snapshot.Issued[c.AssetID]
+		}
+		if c.Amount < sc.Issued {
+			return errors.WithDetailf(ErrBadSupplyCaps, "cap of %d on asset %s is below the %d already issued", c.Amount, c.AssetID, sc.Issued)
+		}
+		sc.Limit = c.Amount
+		capped[i] = sc
+	}
+
+	if snapshot.SupplyCaps == nil && len(caps) > 0 {
+		snapshot.SupplyCaps = make(state.SupplyCaps, len(caps))
+	}
+	for i, c := range caps {
+		snapshot.SupplyCaps[c.AssetID] = capped[i]
+	}
+	return nil
+}
+
+// checkSupplyCaps tests that the issuances in tx keep
+// every capped asset within its cap.
+func checkSupplyCaps(snapshot *state.Snapshot, tx *bc.Tx) error {
+	if len(snapshot.SupplyCaps) == 0 {
+		return nil
+	}
+	for assetID, amount := range issuedAmounts(tx) {
+		sc, ok := snapshot.SupplyCaps[assetID]
+		if !ok {
+			continue
+		}
+		// Amounts in well-formed txs are less than 2^63,
+		// as are limits, so the sum can't overflow.
+		if sc.Issued+amount > sc.Limit {
+			return badTxErrf(errSupplyCap, "issuing %d of asset %s exceeds its supply cap of %d, with %d already issued", amount, assetID, sc.Limit, sc.Issued)
+		}
+	}
+	return nil
+}
+
+// applySupplyCaps adds the amounts of assets issued by tx
+// to the totals issued, including those of capped assets.
+func applySupplyCaps(snapshot *state.Snapshot, tx *bc.Tx) {
+	for assetID, amount := range issuedAmounts(tx) {
+		if snapshot.Issued == nil {
+			snapshot.Issued = make(state.IssuedAmounts)
+		}
+		total := snapshot.Issued[assetID] + amount
+		if total < amount {
+			// Only a cap needs an exact total,
+			// and a capped total can't overflow.
+			total = math.MaxUint64
+		}
+		snapshot.Issued[assetID] = total
+
+		if sc, ok := snapshot.SupplyCaps[assetID]; ok {
+			sc.Issued += amount
+			snapshot.SupplyCaps[assetID] = sc
+		}
+	}
+}
+
+// issuedAmounts sums, for each asset,
+// the amounts issued by tx.
+func issuedAmounts(tx *bc.Tx) map[bc.AssetID]uint64 {
+	var sums map[bc.AssetID]uint64
+	for _, txin := range tx.Inputs {
+		ii, ok := txin.TypedInput.(*bc.IssuanceInput)
+		if !ok || txin.AssetVersion != 1 {
+			continue
+		}
+		if sums == nil {
+			sums = make(map[bc.AssetID]uint64)
+		}
+		sums[ii.AssetID()] += ii.Amount
+	}
+	return sums
+}
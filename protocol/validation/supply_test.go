@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"math"
+	"testing"
+
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/state"
+)
+
+func TestApplySupplyCaps(t *testing.T) {
+	gold, silver, bronze := bc.AssetID{1}, bc.AssetID{2}, bc.AssetID{3}
+	snapshot := state.Empty()
+	snapshot.SupplyCaps[gold] = state.SupplyCap{Limit: 100, Issued: 40}
+	snapshot.Issued[gold] = 40
+	snapshot.Issued[bronze] = 30
+
+	err := ApplySupplyCaps(snapshot, []bc.SupplyCap{
+		{AssetID: gold, Amount: 50},
+		{AssetID: silver, Amount: 10},
+		{AssetID: bronze, Amount: 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := state.SupplyCaps{
+		gold:   {Limit: 50, Issued: 40},
+		silver: {Limit: 10},
+		bronze: {Limit: 30, Issued: 30},
+	}
+	for assetID, sc := range want {
+		if got := snapshot.SupplyCaps[assetID]; got != sc {
+			t.Errorf("supply cap of %s = %+v want %+v", assetID, got, sc)
+		}
+	}
+
+	cases := [][]bc.SupplyCap{
+		{{AssetID: gold, Amount: 1}, {AssetID: gold, Amount: 2}},
+		{{AssetID: gold, Amount: math.MaxInt64 + 1}},
+		{{AssetID: gold, Amount: 39}},   // below the amount issued under the cap
+		{{AssetID: bronze, Amount: 29}}, // below the amount issued before the cap
+	}
+	for i, caps := range cases {
+		snapshot := state.Empty()
+		snapshot.SupplyCaps[gold] = state.SupplyCap{Limit: 100, Issued: 40}
+		snapshot.Issued[bronze] = 30
+		err := ApplySupplyCaps(snapshot, caps)
+		if errors.Root(err) != ErrBadSupplyCaps {
+			t.Errorf("case %d: err = %v want %v", i, err, ErrBadSupplyCaps)
+		}
+	}
+}
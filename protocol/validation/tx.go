@@ -2,6 +2,7 @@ package validation
 
 import (
 	"bytes"
+	"io/ioutil"
 	"math"
 
 	"chain/errors"
@@ -47,17 +48,36 @@ var (
 )
 
 func badTxErr(suberr error) error {
-	err := errors.WithData(ErrBadTx, "badtx", suberr)
+	err := errors.WithData(ErrBadTx, "badtx", suberr, KeyReason, reasonFor(suberr))
 	err = errors.WithDetail(err, suberr.Error())
 	return err
 }
 
 func badTxErrf(suberr error, f string, args ...interface{}) error {
-	err := errors.WithData(ErrBadTx, "badtx", suberr)
+	err := errors.WithData(ErrBadTx, "badtx", suberr, KeyReason, reasonFor(suberr))
 	err = errors.WithDetailf(err, f, args...)
 	return err
 }
 
+// badTxInputErr is like badTxErr for an error
+// caused by the input at position index.
+func badTxInputErr(suberr error, index int) error {
+	return errors.WithData(badTxErr(suberr), errors.KeyInputIndex, index)
+}
+
+// badTxInputErrf is like badTxErrf for an error
+// caused by the input at position index.
+func badTxInputErrf(suberr error, index int, f string, args ...interface{}) error {
+	return errors.WithData(badTxErrf(suberr, f, args...), errors.KeyInputIndex, index)
+}
+
+// badWitnessErr is like badTxInputErr for an
+// input whose program failed with error vmErr.
+func badWitnessErr(vmErr error, index int) error {
+	err := badTxInputErrf(vmErr, index, "validation failed in script execution, input %d", index)
+	return errors.WithData(err, KeyReason, ReasonInvalidWitness)
+}
+
 // ConfirmTx validates the given transaction against the given state tree
 // before it's added to a block. If tx is invalid, it returns a non-nil
 // error describing why.
@@ -85,23 +105,23 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 				continue
 			}
 			if ii.InitialBlock != initialBlockHash {
-				return badTxErr(errWrongBlockchain)
+				return badTxInputErr(errWrongBlockchain, i)
 			}
 			if len(ii.Nonce) == 0 {
 				continue
 			}
 			if tx.MinTime == 0 || tx.MaxTime == 0 {
-				return badTxErr(errTimelessIssuance)
+				return badTxInputErr(errTimelessIssuance, i)
 			}
 			if block.TimestampMS < tx.MinTime || block.TimestampMS > tx.MaxTime {
-				return badTxErr(errIssuanceTime)
+				return badTxInputErr(errIssuanceTime, i)
 			}
 			iHash, err := tx.IssuanceHash(i)
 			if err != nil {
 				return err
 			}
 			if _, ok2 := snapshot.Issuances[iHash]; ok2 {
-				return badTxErr(errDuplicateIssuance)
+				return badTxInputErr(errDuplicateIssuance, i)
 			}
 			continue
 		}
@@ -111,10 +131,15 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 		// Lookup the prevout in the blockchain state tree.
 		k, val := state.OutputTreeItem(state.Prevout(txin))
 		if !snapshot.Tree.Contains(k, val) {
-			return badTxErrf(errInvalidOutput, "output %s for input %d is invalid", txin.Outpoint().String(), i)
+			err := badTxInputErrf(errInvalidOutput, i, "output %s for input %d is invalid", txin.Outpoint().String(), i)
+			return errors.WithData(err, KeyOutpoint, txin.Outpoint())
 		}
 	}
-	return nil
+	err := checkBlockPrograms(tx, &block.BlockHeader)
+	if err != nil {
+		return err
+	}
+	return checkSupplyCaps(snapshot, tx)
 }
 
 // CheckTxWellFormed checks whether tx is "well-formed" (the
@@ -126,6 +151,121 @@ func ConfirmTx(snapshot *state.Snapshot, initialBlockHash bc.Hash, block *bc.Blo
 // Result is nil for well-formed transactions, ErrBadTx with
 // supporting detail otherwise.
 func CheckTxWellFormed(tx *bc.Tx) error {
+	_, err := MeterTx(tx)
+	return err
+}
+
+// MeterTx is like CheckTxWellFormed, but it also returns the
+// cost of validating tx. The cost is zero if tx fails before
+// its input scripts run.
+//
+// Input scripts that may read the block including tx don't
+// run here, and add nothing to the cost; see DependsOnBlock.
+func MeterTx(tx *bc.Tx) (Cost, error) {
+	return meterTx(tx, nil)
+}
+
+// MeterTxInBlock is like MeterTx, but it runs every input
+// script, as part of a block with the given header.
+func MeterTxInBlock(tx *bc.Tx, block *bc.BlockHeader) (Cost, error) {
+	return meterTx(tx, block)
+}
+
+func meterTx(tx *bc.Tx, block *bc.BlockHeader) (Cost, error) {
+	err := checkTxFields(tx)
+	if err != nil {
+		return Cost{}, err
+	}
+
+	n, _ := tx.WriteTo(ioutil.Discard) // error is impossible
+	cost := Cost{Bytes: n}
+	for i, txin := range tx.Inputs {
+		if block == nil && inputVMVersion(txin) >= blockVMVersion {
+			continue
+		}
+		ok, c, err := vm.MeterTxInputInBlock(tx, uint32(i), block)
+		cost = cost.Add(Cost{RunCost: c.RunCost, SigChecks: c.SigChecks})
+		if err == nil && !ok {
+			err = ErrFalseVMResult
+		}
+		if err != nil {
+			return cost, badWitnessErr(err, i)
+		}
+	}
+	return cost, nil
+}
+
+// An InputRun is the result of running the script
+// of one input of a transaction.
+type InputRun struct {
+	Cost Cost
+	Err  error // nil if the script succeeded
+}
+
+// RunTxInputs runs every input script of tx, as part of a block
+// with the given header, and returns the result of each. Unlike
+// MeterTx, it doesn't stop at the first script that fails.
+func RunTxInputs(tx *bc.Tx, block *bc.BlockHeader) []InputRun {
+	runs := make([]InputRun, len(tx.Inputs))
+	for i := range tx.Inputs {
+		ok, c, err := vm.MeterTxInputInBlock(tx, uint32(i), block)
+		if err == nil && !ok {
+			err = ErrFalseVMResult
+		}
+		runs[i] = InputRun{Cost: Cost{RunCost: c.RunCost, SigChecks: c.SigChecks}, Err: err}
+	}
+	return runs
+}
+
+// blockVMVersion is the first VM version
+// whose programs can read the including block.
+const blockVMVersion = 3
+
+// DependsOnBlock reports whether tx has an input whose script
+// may read the height or timestamp of the block including tx.
+// Those scripts don't run in the context-free checks of
+// CheckTxWellFormed and MeterTx, but in ConfirmTx, once the
+// block is known.
+func DependsOnBlock(tx *bc.Tx) bool {
+	for _, txin := range tx.Inputs {
+		if inputVMVersion(txin) >= blockVMVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// checkBlockPrograms runs the input scripts of tx left
+// out by MeterTx, as part of the block with header block.
+func checkBlockPrograms(tx *bc.Tx, block *bc.BlockHeader) error {
+	for i, txin := range tx.Inputs {
+		if inputVMVersion(txin) < blockVMVersion {
+			continue
+		}
+		ok, _, err := vm.MeterTxInputInBlock(tx, uint32(i), block)
+		if err == nil && !ok {
+			err = ErrFalseVMResult
+		}
+		if err != nil {
+			return badWitnessErr(err, i)
+		}
+	}
+	return nil
+}
+
+func inputVMVersion(txin *bc.TxInput) uint64 {
+	switch x := txin.TypedInput.(type) {
+	case *bc.IssuanceInput:
+		return x.VMVersion
+	case *bc.SpendInput:
+		return x.VMVersion
+	}
+	return 0
+}
+
+// checkTxFields performs the checks of CheckTxWellFormed
+// that don't run input scripts.
+func checkTxFields(tx *bc.Tx) error {
 	if len(tx.Inputs) == 0 {
 		return badTxErr(errNoInputs)
 	}
@@ -169,25 +309,25 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 
 	for i, txin := range tx.Inputs {
 		if tx.Version == 1 && txin.AssetVersion != 1 {
-			return badTxErrf(errAssetVersion, "unknown asset version %d in input %d for transaction version %d", txin.AssetVersion, i, tx.Version)
+			return badTxInputErrf(errAssetVersion, i, "unknown asset version %d in input %d for transaction version %d", txin.AssetVersion, i, tx.Version)
 		}
 
 		assetID := txin.AssetID()
 
 		if txin.Amount() > math.MaxInt64 {
-			return badTxErr(errInputTooBig)
+			return badTxInputErr(errInputTooBig, i)
 		}
 
 		sum, ok := checked.AddInt64(parity[assetID], int64(txin.Amount()))
 		if !ok {
-			return badTxErrf(errInputSumTooBig, "adding input %d overflows the allowed asset amount", i)
+			return badTxInputErrf(errInputSumTooBig, i, "adding input %d overflows the allowed asset amount", i)
 		}
 		parity[assetID] = sum
 
 		switch x := txin.TypedInput.(type) {
 		case *bc.IssuanceInput:
-			if tx.Version == 1 && x.VMVersion != 1 {
-				return badTxErrf(errVMVersion, "unknown vm version %d in input %d for transaction version %d", x.VMVersion, i, tx.Version)
+			if tx.Version == 1 && (x.VMVersion < 1 || x.VMVersion > bc.MaxVMVersion) {
+				return badTxInputErrf(errVMVersion, i, "unknown vm version %d in input %d for transaction version %d", x.VMVersion, i, tx.Version)
 			}
 			if txin.AssetVersion != 1 {
 				continue
@@ -196,18 +336,18 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 				continue
 			}
 			if tx.MinTime == 0 || tx.MaxTime == 0 {
-				return badTxErr(errTimelessIssuance)
+				return badTxInputErr(errTimelessIssuance, i)
 			}
 		case *bc.SpendInput:
-			if tx.Version == 1 && x.VMVersion != 1 {
-				return badTxErrf(errVMVersion, "unknown vm version %d in input %d for transaction version %d", x.VMVersion, i, tx.Version)
+			if tx.Version == 1 && (x.VMVersion < 1 || x.VMVersion > bc.MaxVMVersion) {
+				return badTxInputErrf(errVMVersion, i, "unknown vm version %d in input %d for transaction version %d", x.VMVersion, i, tx.Version)
 			}
 		}
 
 		buf := new(bytes.Buffer)
 		txin.WriteInputCommitment(buf)
 		if inp, ok := commitments[string(buf.Bytes())]; ok {
-			return badTxErrf(errDuplicateInput, "input %d is a duplicate of %d", i, inp)
+			return badTxInputErrf(errDuplicateInput, i, "input %d is a duplicate of %d", i, inp)
 		}
 		commitments[string(buf.Bytes())] = i
 	}
@@ -222,7 +362,7 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 			if txout.AssetVersion != 1 {
 				return badTxErrf(errAssetVersion, "unknown asset version %d in output %d for transaction version %d", txout.AssetVersion, i, tx.Version)
 			}
-			if txout.VMVersion != 1 {
+			if txout.VMVersion < 1 || txout.VMVersion > bc.MaxVMVersion {
 				return badTxErrf(errVMVersion, "unknown vm version %d in output %d for transaction version %d", txout.VMVersion, i, tx.Version)
 			}
 		}
@@ -250,16 +390,6 @@ func CheckTxWellFormed(tx *bc.Tx) error {
 		}
 	}
 
-	for i := range tx.Inputs {
-		ok, err := vm.VerifyTxInput(tx, uint32(i))
-		if err == nil && !ok {
-			err = ErrFalseVMResult
-		}
-		if err != nil {
-			return badTxErrf(err, "validation failed in script execution, input %d", i)
-		}
-	}
-
 	return nil
 }
 
@@ -296,5 +426,7 @@ func ApplyTx(snapshot *state.Snapshot, tx *bc.Tx) error {
 			return err
 		}
 	}
+
+	applySupplyCaps(snapshot, tx)
 	return nil
 }
@@ -1,6 +1,7 @@
 package validation
 
 import (
+	"io/ioutil"
 	"math"
 	"testing"
 	"time"
@@ -165,6 +166,55 @@ func TestUniqueIssuance(t *testing.T) {
 	}
 }
 
+func TestBlockDependentTx(t *testing.T) {
+	var initialBlockHash bc.Hash
+	prog, err := vm.Assemble("BLOCKHEIGHT 5 GREATERTHANOREQUAL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetID := bc.ComputeAssetID(prog, initialBlockHash, 3, bc.EmptyStringHash)
+	in := bc.NewIssuanceInput([]byte{1}, 1, nil, initialBlockHash, prog, nil, nil)
+	in.TypedInput.(*bc.IssuanceInput).VMVersion = 3
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs:  []*bc.TxInput{in},
+		Outputs: []*bc.TxOutput{bc.NewTxOutput(assetID, 1, []byte{byte(vm.OP_TRUE)}, nil)},
+		MinTime: 1,
+		MaxTime: 1000,
+	})
+	if !DependsOnBlock(tx) {
+		t.Fatal("DependsOnBlock = false want true")
+	}
+
+	// The context-free checks leave the program for ConfirmTx.
+	cost, err := MeterTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost.RunCost != 0 {
+		t.Errorf("MeterTx run cost = %d want 0", cost.RunCost)
+	}
+
+	snapshot := state.Empty()
+	for _, c := range []struct {
+		height uint64
+		ok     bool
+	}{{4, false}, {5, true}} {
+		block := &bc.Block{BlockHeader: bc.BlockHeader{Version: 1, Height: c.height, TimestampMS: 500}}
+		err = ConfirmTx(snapshot, initialBlockHash, block, tx)
+		if (err == nil) != c.ok {
+			t.Errorf("ConfirmTx at height %d: err = %v, want ok = %v", c.height, err, c.ok)
+		}
+		cost, err = MeterTxInBlock(tx, &block.BlockHeader)
+		if (err == nil) != c.ok {
+			t.Errorf("MeterTxInBlock at height %d: err = %v, want ok = %v", c.height, err, c.ok)
+		}
+	}
+	if cost.RunCost == 0 {
+		t.Error("MeterTxInBlock run cost = 0, want more")
+	}
+}
+
 func TestTxWellFormed(t *testing.T) {
 	var initialBlockHash bc.Hash
 	trueProg := []byte{byte(vm.OP_TRUE)}
@@ -415,7 +465,7 @@ func TestTxWellFormed(t *testing.T) {
 								AssetAmount: bc.AssetAmount{
 									Amount: 1,
 								},
-								VMVersion:      2,
+								VMVersion:      4,
 								ControlProgram: trueProg,
 							},
 						},
@@ -447,7 +497,7 @@ func TestTxWellFormed(t *testing.T) {
 								AssetAmount: bc.AssetAmount{
 									Amount: 1,
 								},
-								VMVersion:      2,
+								VMVersion:      4,
 								ControlProgram: trueProg,
 							},
 						},
@@ -460,7 +510,7 @@ func TestTxWellFormed(t *testing.T) {
 							AssetAmount: bc.AssetAmount{
 								Amount: 1,
 							},
-							VMVersion:      2,
+							VMVersion:      4,
 							ControlProgram: trueProg,
 						},
 					},
@@ -578,7 +628,7 @@ func TestTxWellFormed(t *testing.T) {
 								AssetAmount: bc.AssetAmount{
 									Amount: 1,
 								},
-								VMVersion:      2,
+								VMVersion:      4,
 								ControlProgram: trueProg,
 							},
 						},
@@ -624,7 +674,7 @@ func TestTxWellFormed(t *testing.T) {
 							AssetAmount: bc.AssetAmount{
 								Amount: 1,
 							},
-							VMVersion:      2,
+							VMVersion:      4,
 							ControlProgram: trueProg,
 						},
 					},
@@ -1073,6 +1123,12 @@ func TestConfirmTx(t *testing.T) {
 				if suberr != errInvalidOutput {
 					t.Errorf("case %d: confirm and apply succeeded, second confirm failed but with the wrong error: %s", i, err)
 				}
+				if r := Reason(err); r != ReasonDoubleSpend {
+					t.Errorf("case %d: second confirm reason = %q want %q", i, r, ReasonDoubleSpend)
+				}
+				if o, _ := errors.Data(err)[KeyOutpoint].(bc.Outpoint); o != tx.Inputs[0].Outpoint() {
+					t.Errorf("case %d: second confirm outpoint = %v want %v", i, o, tx.Inputs[0].Outpoint())
+				}
 			}
 
 			continue
@@ -1087,3 +1143,33 @@ func TestConfirmTx(t *testing.T) {
 		}
 	}
 }
+
+func TestMeterTx(t *testing.T) {
+	trueProg := []byte{byte(vm.OP_TRUE)}
+	aid := bc.AssetID{1}
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(bc.Hash{10}, 0, nil, aid, 1, trueProg, nil),
+			bc.NewSpendInput(bc.Hash{11}, 0, nil, aid, 1, trueProg, nil),
+		},
+		Outputs: []*bc.TxOutput{
+			bc.NewTxOutput(aid, 2, trueProg, nil),
+		},
+	})
+	got, err := MeterTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := tx.WriteTo(ioutil.Discard)
+	want := Cost{RunCost: 20, Bytes: n}
+	if got != want {
+		t.Errorf("MeterTx = %+v want %+v", got, want)
+	}
+
+	tx.Inputs[1].TypedInput.(*bc.SpendInput).ControlProgram = []byte{byte(vm.OP_FALSE)}
+	got, err = MeterTx(tx)
+	if errors.Root(err) != ErrBadTx || got.RunCost != 19 {
+		t.Errorf("MeterTx(false program) = %+v, %v want run cost 19 and %v", got, err, ErrBadTx)
+	}
+}
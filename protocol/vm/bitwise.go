@@ -17,9 +17,9 @@ func opInvert(vm *virtualMachine) error {
 	}
 	// Could rewrite top in place but maybe it's a shared data
 	// structure?
-	newTop := make([]byte, 0, len(top))
-	for _, b := range top {
-		newTop = append(newTop, ^b)
+	newTop := vm.alloc(len(top))
+	for i, b := range top {
+		newTop[i] = ^b
 	}
 	vm.dataStack[len(vm.dataStack)-1] = newTop
 	return nil
@@ -46,9 +46,9 @@ func opAnd(vm *virtualMachine) error {
 	if err != nil {
 		return err
 	}
-	res := make([]byte, 0, min)
+	res := vm.alloc(min)
 	for i := 0; i < min; i++ {
-		res = append(res, a[i]&b[i])
+		res[i] = a[i] & b[i]
 	}
 	return vm.push(res, true)
 }
@@ -82,7 +82,7 @@ func doOr(vm *virtualMachine, xor bool) error {
 	if err != nil {
 		return err
 	}
-	res := make([]byte, 0, max)
+	res := vm.alloc(max)
 	for i := 0; i < max; i++ {
 		var aByte, bByte, resByte byte
 		if i >= len(a) {
@@ -101,7 +101,7 @@ func doOr(vm *virtualMachine, xor bool) error {
 			resByte = aByte | bByte
 		}
 
-		res = append(res, resByte)
+		res[i] = resByte
 	}
 	return vm.push(res, true)
 }
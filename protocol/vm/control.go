@@ -60,20 +60,23 @@ func opCheckPredicate(vm *virtualMachine) error {
 		return err
 	}
 
-	childVM := virtualMachine{
-		mainprog:   vm.mainprog,
-		program:    predicate,
-		runLimit:   limit,
-		depth:      vm.depth + 1,
-		dataStack:  append([][]byte{}, vm.dataStack[l-n:]...),
-		tx:         vm.tx,
-		inputIndex: vm.inputIndex,
-		sigHasher:  vm.sigHasher,
-	}
+	childVM := getVM()
+	defer putVM(childVM)
+	childVM.mainprog = vm.mainprog
+	childVM.program = predicate
+	childVM.runLimit = limit
+	childVM.depth = vm.depth + 1
+	childVM.vmVersion = vm.vmVersion
+	childVM.dataStack = append(childVM.dataStack, vm.dataStack[l-n:]...)
+	childVM.tx = vm.tx
+	childVM.inputIndex = vm.inputIndex
+	childVM.sigHasher = vm.sigHasher
+	childVM.txBlock = vm.txBlock
 	vm.dataStack = vm.dataStack[:l-n]
 
 	ok, childErr := childVM.run()
 
+	vm.sigChecks += childVM.sigChecks
 	vm.deferCost(-childVM.runLimit)
 	vm.deferCost(-stackCost(childVM.dataStack))
 	vm.deferCost(-stackCost(childVM.altStack))
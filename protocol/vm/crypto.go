@@ -1,12 +1,14 @@
 package vm
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"hash"
 
 	"golang.org/x/crypto/sha3"
 
 	"chain/crypto/ed25519"
+	"chain/crypto/sha3pool"
 	"chain/math/checked"
 )
 
@@ -39,6 +41,75 @@ func doHash(vm *virtualMachine, hashFactory func() hash.Hash) error {
 	return vm.push(h.Sum(nil), false)
 }
 
+// Merkle proofs are checked against trees hashed like the
+// transactions merkle tree in block headers.
+var (
+	merkleLeafPrefix     = []byte{0x00}
+	merkleInteriorPrefix = []byte{0x01}
+)
+
+// opCheckMerkleProof pops a merkle root, a path, the hashes of
+// a leaf's siblings from the bottom of the tree up, and the leaf
+// itself. Bit i of the path is set if the i'th sibling is on the
+// left. It pushes whether the proof leads from the leaf to the root.
+func opCheckMerkleProof(vm *virtualMachine) error {
+	root, err := vm.pop(true)
+	if err != nil {
+		return err
+	}
+	if len(root) != 32 {
+		return ErrBadValue
+	}
+	path, err := vm.popInt64(true)
+	if err != nil {
+		return err
+	}
+	proof, err := vm.pop(true)
+	if err != nil {
+		return err
+	}
+	if path < 0 || len(proof)%32 != 0 {
+		return ErrBadValue
+	}
+	depth := len(proof) / 32
+	if depth < 63 && path>>uint(depth) != 0 {
+		return ErrBadValue
+	}
+	leaf, err := vm.pop(true)
+	if err != nil {
+		return err
+	}
+	cost := int64(len(leaf))
+	if cost < 64 {
+		cost = 64
+	}
+	err = vm.applyCost(cost + 64*int64(depth))
+	if err != nil {
+		return err
+	}
+
+	h := make([]byte, 32)
+	hasher := sha3pool.Get256()
+	defer sha3pool.Put256(hasher)
+	hasher.Write(merkleLeafPrefix)
+	hasher.Write(leaf)
+	hasher.Read(h)
+	for i := 0; i < depth; i++ {
+		sibling := proof[32*i : 32*(i+1)]
+		hasher.Reset()
+		hasher.Write(merkleInteriorPrefix)
+		if i < 63 && path>>uint(i)&1 == 1 {
+			hasher.Write(sibling)
+			hasher.Write(h)
+		} else {
+			hasher.Write(h)
+			hasher.Write(sibling)
+		}
+		hasher.Read(h)
+	}
+	return vm.pushBool(bytes.Equal(h, root), true)
+}
+
 func opCheckSig(vm *virtualMachine) error {
 	err := vm.applyCost(1024)
 	if err != nil {
@@ -62,6 +133,7 @@ func opCheckSig(vm *virtualMachine) error {
 	if err != nil {
 		return err
 	}
+	vm.sigChecks++
 	return vm.pushBool(ed25519.Verify(ed25519.PublicKey(pubkeyBytes), msg, sig), true)
 }
 
@@ -118,6 +190,7 @@ func opCheckMultiSig(vm *virtualMachine) error {
 	}
 
 	for len(sigs) > 0 && len(pubkeys) > 0 {
+		vm.sigChecks++
 		if ed25519.Verify(pubkeys[0], msg, sigs[0]) {
 			sigs = sigs[1:]
 		}
@@ -1,10 +1,12 @@
 package vm
 
 import (
+	"bytes"
 	"encoding/hex"
 	"reflect"
 	"testing"
 
+	"chain/crypto/sha3pool"
 	"chain/protocol/bc"
 )
 
@@ -162,6 +164,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -143,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{1}},
 		},
 	}, {
@@ -178,6 +181,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -144,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{}},
 		},
 	}, {
@@ -194,6 +198,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -144,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{}},
 		},
 	}, {
@@ -210,6 +215,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -144,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{}},
 		},
 	}, {
@@ -271,6 +277,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -161,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{1}},
 		},
 	}, {
@@ -288,6 +295,7 @@ func TestCryptoOps(t *testing.T) {
 		wantVM: &virtualMachine{
 			deferredCost: -162,
 			runLimit:     48976,
+			sigChecks:    1,
 			dataStack:    [][]byte{{}},
 		},
 	}, {
@@ -493,6 +501,69 @@ func TestCryptoOps(t *testing.T) {
 	}
 }
 
+func TestCheckMerkleProof(t *testing.T) {
+	hash := func(parts ...[]byte) []byte {
+		h := make([]byte, 32)
+		sha3pool.Sum256(h, bytes.Join(parts, nil))
+		return h
+	}
+	leaf := func(b []byte) []byte { return hash([]byte{0}, b) }
+	interior := func(l, r []byte) []byte { return hash([]byte{1}, l, r) }
+
+	// A tree of three leaves, shaped like a transactions merkle tree.
+	a, b, c := []byte("alice"), []byte("bob"), []byte("carol")
+	ab := interior(leaf(a), leaf(b))
+	root := interior(ab, leaf(c))
+
+	cases := []struct {
+		leaf    []byte
+		proof   [][]byte
+		path    int64
+		root    []byte
+		want    bool
+		wantErr error
+	}{
+		{leaf: a, proof: [][]byte{leaf(b), leaf(c)}, path: 0, root: root, want: true},
+		{leaf: b, proof: [][]byte{leaf(a), leaf(c)}, path: 1, root: root, want: true},
+		{leaf: c, proof: [][]byte{ab}, path: 1, root: root, want: true},
+		{leaf: c, proof: [][]byte{ab}, path: 0, root: root, want: false},
+		{leaf: []byte("mallory"), proof: [][]byte{leaf(b), leaf(c)}, path: 0, root: root, want: false},
+		{leaf: a, proof: nil, path: 0, root: leaf(a), want: true},
+		{leaf: a, proof: [][]byte{leaf(b)}, path: 2, root: root, wantErr: ErrBadValue},
+		{leaf: a, proof: [][]byte{leaf(b)}, path: -1, root: root, wantErr: ErrBadValue},
+		{leaf: a, proof: [][]byte{leaf(b)[:31]}, path: 0, root: root, wantErr: ErrBadValue},
+		{leaf: a, proof: [][]byte{leaf(b)}, path: 0, root: root[:31], wantErr: ErrBadValue},
+	}
+	for i, c := range cases {
+		vm := &virtualMachine{
+			vmVersion: 2,
+			program:   []byte{byte(OP_CHECKMERKLEPROOF)},
+			runLimit:  50000,
+			dataStack: [][]byte{c.leaf, bytes.Join(c.proof, nil), Int64Bytes(c.path), c.root},
+		}
+		ok, err := vm.run()
+		if err != c.wantErr {
+			t.Errorf("case %d: got error %v want %v", i, err, c.wantErr)
+			continue
+		}
+		if err == nil && ok != c.want {
+			t.Errorf("case %d: got %t want %t", i, ok, c.want)
+		}
+	}
+
+	// Before VM version 2, CHECKMERKLEPROOF is an expansion opcode.
+	vm := &virtualMachine{
+		vmVersion:         1,
+		program:           []byte{byte(OP_CHECKMERKLEPROOF)},
+		runLimit:          50000,
+		expansionReserved: true,
+	}
+	_, err := vm.run()
+	if err != ErrDisallowedOpcode {
+		t.Errorf("CHECKMERKLEPROOF in VM version 1: got error %v want %v", err, ErrDisallowedOpcode)
+	}
+}
+
 func mustDecodeHex(h string) []byte {
 	bits, err := hex.DecodeString(h)
 	if err != nil {
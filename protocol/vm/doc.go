@@ -3,8 +3,9 @@ Package vm implements the VM described in Chain Protocol 1.
 
 The VM is for verifying transaction inputs and blocks. Accordingly
 there are two main entrypoints: VerifyTxInput and VerifyBlockHeader,
-both in vm.go. Each constructs a disposable VM object to perform its
-computation.
+both in vm.go. Each takes a VM object from a pool (see pool.go) to
+perform its computation and returns it when done, so that stacks and
+scratch memory are reused across runs.
 
 For VerifyTxInput, the program to execute comes from the input
 commitment: either the prevout's control program, if it's a spend
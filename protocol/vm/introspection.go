@@ -248,15 +248,47 @@ func opNextProgram(vm *virtualMachine) error {
 }
 
 func opBlockTime(vm *virtualMachine) error {
-	if vm.block == nil {
+	header := vm.blockHeader()
+	if header == nil {
 		return ErrContext
 	}
 	err := vm.applyCost(1)
 	if err != nil {
 		return err
 	}
-	if vm.block.TimestampMS > math.MaxInt64 {
+	if header.TimestampMS > math.MaxInt64 {
 		return fmt.Errorf("block timestamp out of range")
 	}
-	return vm.pushInt64(int64(vm.block.TimestampMS), true)
+	return vm.pushInt64(int64(header.TimestampMS), true)
+}
+
+func opBlockHeight(vm *virtualMachine) error {
+	header := vm.blockHeader()
+	if header == nil {
+		return ErrContext
+	}
+	err := vm.applyCost(1)
+	if err != nil {
+		return err
+	}
+	if header.Height > math.MaxInt64 {
+		return fmt.Errorf("block height out of range")
+	}
+	return vm.pushInt64(int64(header.Height), true)
+}
+
+// blockHeader returns the header read by BLOCKTIME and
+// BLOCKHEIGHT: that of the block whose consensus program is
+// running, or, from VM version 3, that of the block including
+// the transaction whose program is running. It returns nil if
+// there is no such block, as when a transaction is checked
+// outside of any block.
+func (vm *virtualMachine) blockHeader() *bc.BlockHeader {
+	if vm.block != nil {
+		return &vm.block.BlockHeader
+	}
+	if vm.vmVersion >= 3 {
+		return vm.txBlock
+	}
+	return nil
 }
@@ -85,6 +85,41 @@ func TestBlockTime(t *testing.T) {
 	}
 }
 
+func TestTxBlockIntrospection(t *testing.T) {
+	prog, err := Assemble("BLOCKHEIGHT 7 NUMEQUAL BLOCKTIME 3263827 NUMEQUAL BOOLAND")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTx := func(vmVersion uint64) *bc.Tx {
+		in := bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)
+		in.TypedInput.(*bc.SpendInput).VMVersion = vmVersion
+		return bc.NewTx(bc.TxData{Version: 1, Inputs: []*bc.TxInput{in}})
+	}
+	header := &bc.BlockHeader{Height: 7, TimestampMS: 3263827}
+
+	ok, _, err := MeterTxInputInBlock(newTx(3), 0, header)
+	if err != nil || !ok {
+		t.Errorf("VM version 3 in block: got %v, %v want true, nil", ok, err)
+	}
+
+	ok, _, err = MeterTxInputInBlock(newTx(3), 0, &bc.BlockHeader{Height: 8, TimestampMS: 3263827})
+	if err != nil || ok {
+		t.Errorf("VM version 3 in another block: got %v, %v want false, nil", ok, err)
+	}
+
+	_, _, err = MeterTxInputInBlock(newTx(3), 0, nil)
+	if err, ok := err.(Error); !ok || err.Err != ErrContext {
+		t.Errorf("VM version 3 outside a block: got error %v want %v", err, ErrContext)
+	}
+
+	// Before version 3, BLOCKHEIGHT is an expansion opcode,
+	// disallowed in version 1 transactions.
+	_, _, err = MeterTxInputInBlock(newTx(2), 0, header)
+	if err, ok := err.(Error); !ok || err.Err != ErrDisallowedOpcode {
+		t.Errorf("VM version 2: got error %v want %v", err, ErrDisallowedOpcode)
+	}
+}
+
 func TestOutpointAndNonceOp(t *testing.T) {
 	var zeroHash bc.Hash
 	nonce := []byte{36, 37, 38}
@@ -194,12 +194,13 @@ const (
 	OP_MAX                Op = 0xa4
 	OP_WITHIN             Op = 0xa5
 
-	OP_SHA256        Op = 0xa8
-	OP_SHA3          Op = 0xaa
-	OP_CHECKSIG      Op = 0xac
-	OP_CHECKMULTISIG Op = 0xad
-	OP_TXSIGHASH     Op = 0xae
-	OP_BLOCKSIGHASH  Op = 0xaf
+	OP_SHA256           Op = 0xa8
+	OP_SHA3             Op = 0xaa
+	OP_CHECKMERKLEPROOF Op = 0xab
+	OP_CHECKSIG         Op = 0xac
+	OP_CHECKMULTISIG    Op = 0xad
+	OP_TXSIGHASH        Op = 0xae
+	OP_BLOCKSIGHASH     Op = 0xaf
 
 	OP_CHECKOUTPUT   Op = 0xc1
 	OP_ASSET         Op = 0xc2
@@ -214,6 +215,7 @@ const (
 	OP_NONCE         Op = 0xcc
 	OP_NEXTPROGRAM   Op = 0xcd
 	OP_BLOCKTIME     Op = 0xce
+	OP_BLOCKHEIGHT   Op = 0xcf
 )
 
 type opInfo struct {
@@ -305,12 +307,13 @@ var (
 		OP_MAX:                {OP_MAX, "MAX", opMax},
 		OP_WITHIN:             {OP_WITHIN, "WITHIN", opWithin},
 
-		OP_SHA256:        {OP_SHA256, "SHA256", opSha256},
-		OP_SHA3:          {OP_SHA3, "SHA3", opSha3},
-		OP_CHECKSIG:      {OP_CHECKSIG, "CHECKSIG", opCheckSig},
-		OP_CHECKMULTISIG: {OP_CHECKMULTISIG, "CHECKMULTISIG", opCheckMultiSig},
-		OP_TXSIGHASH:     {OP_TXSIGHASH, "TXSIGHASH", opTxSigHash},
-		OP_BLOCKSIGHASH:  {OP_BLOCKSIGHASH, "BLOCKSIGHASH", opBlockSigHash},
+		OP_SHA256:           {OP_SHA256, "SHA256", opSha256},
+		OP_SHA3:             {OP_SHA3, "SHA3", opSha3},
+		OP_CHECKMERKLEPROOF: {OP_CHECKMERKLEPROOF, "CHECKMERKLEPROOF", opCheckMerkleProof},
+		OP_CHECKSIG:         {OP_CHECKSIG, "CHECKSIG", opCheckSig},
+		OP_CHECKMULTISIG:    {OP_CHECKMULTISIG, "CHECKMULTISIG", opCheckMultiSig},
+		OP_TXSIGHASH:        {OP_TXSIGHASH, "TXSIGHASH", opTxSigHash},
+		OP_BLOCKSIGHASH:     {OP_BLOCKSIGHASH, "BLOCKSIGHASH", opBlockSigHash},
 
 		OP_CHECKOUTPUT:   {OP_CHECKOUTPUT, "CHECKOUTPUT", opCheckOutput},
 		OP_ASSET:         {OP_ASSET, "ASSET", opAsset},
@@ -325,11 +328,22 @@ var (
 		OP_NONCE:         {OP_NONCE, "NONCE", opNonce},
 		OP_NEXTPROGRAM:   {OP_NEXTPROGRAM, "NEXTPROGRAM", opNextProgram},
 		OP_BLOCKTIME:     {OP_BLOCKTIME, "BLOCKTIME", opBlockTime},
+		OP_BLOCKHEIGHT:   {OP_BLOCKHEIGHT, "BLOCKHEIGHT", opBlockHeight},
 	}
 
 	opsByName map[string]opInfo
 )
 
+// smallInts holds the data for OP_1 through OP_16,
+// so ParseOp needn't allocate it.
+var smallInts [16][]byte
+
+func init() {
+	for i := range smallInts {
+		smallInts[i] = []byte{byte(i + 1)}[:1:1]
+	}
+}
+
 // ParseOp parses the op at position pc in prog, returning the parsed
 // instruction (opcode plus any associated data).
 func ParseOp(prog []byte, pc uint32) (inst Instruction, err error) {
@@ -345,7 +359,7 @@ func ParseOp(prog []byte, pc uint32) (inst Instruction, err error) {
 	inst.Op = opcode
 	inst.Len = 1
 	if opcode >= OP_1 && opcode <= OP_16 {
-		inst.Data = []byte{uint8(opcode-OP_1) + 1}
+		inst.Data = smallInts[opcode-OP_1]
 		return
 	}
 	if opcode >= OP_DATA_1 && opcode <= OP_DATA_75 {
@@ -454,6 +468,14 @@ func ParseProgram(prog []byte) ([]Instruction, error) {
 
 var isExpansion [256]bool
 
+// minVMVersion is the first VM version to define each opcode.
+// In programs for earlier versions, the opcode is treated as
+// an expansion opcode.
+var minVMVersion = [256]uint64{
+	OP_CHECKMERKLEPROOF: 2,
+	OP_BLOCKHEIGHT:      3,
+}
+
 func init() {
 	for i := 1; i <= 75; i++ {
 		ops[i] = opInfo{Op(i), fmt.Sprintf("DATA_%d", i), opPushdata}
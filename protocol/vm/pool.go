@@ -0,0 +1,88 @@
+package vm
+
+import "sync"
+
+const (
+	// arenaChunk is the size of each buffer an arena carves
+	// byte slices from. Larger requests are allocated directly.
+	arenaChunk = 4096
+
+	// maxPooledStack bounds the capacity of stacks retained
+	// by pooled VMs, so one unusually deep program doesn't
+	// pin a large allocation indefinitely.
+	maxPooledStack = 1024
+)
+
+var vmPool = sync.Pool{New: func() interface{} { return &virtualMachine{arena: new(arena)} }}
+
+// getVM returns a zeroed VM from the pool. Its stacks and scratch
+// memory may be backed by storage left over from earlier runs.
+// The caller must call putVM once nothing refers to the VM's
+// stacks or to any value on them.
+func getVM() *virtualMachine {
+	return vmPool.Get().(*virtualMachine)
+}
+
+func putVM(vm *virtualMachine) {
+	dataStack := resetStack(vm.dataStack)
+	altStack := resetStack(vm.altStack)
+	a := vm.arena
+	a.reset()
+	*vm = virtualMachine{
+		dataStack: dataStack,
+		altStack:  altStack,
+		arena:     a,
+	}
+	vmPool.Put(vm)
+}
+
+func resetStack(stack [][]byte) [][]byte {
+	if cap(stack) > maxPooledStack {
+		return nil
+	}
+	for i := range stack {
+		stack[i] = nil // don't retain the items
+	}
+	return stack[:0]
+}
+
+// An arena hands out byte slices carved from a shared buffer,
+// replacing many small allocations during a program run with
+// a few large ones. Slices it returns are valid until reset.
+type arena struct {
+	buf []byte
+}
+
+func (a *arena) alloc(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	if n > arenaChunk/4 {
+		return make([]byte, n)
+	}
+	if cap(a.buf)-len(a.buf) < n {
+		// Slices handed out from the old buffer may still be
+		// in use, so leave it to the garbage collector.
+		a.buf = make([]byte, 0, arenaChunk)
+	}
+	i := len(a.buf)
+	a.buf = a.buf[:i+n]
+	// Limit the capacity so appends to the result
+	// can't overwrite later allocations.
+	return a.buf[i : i+n : i+n]
+}
+
+func (a *arena) reset() {
+	a.buf = a.buf[:0]
+}
+
+// alloc returns a byte slice of length n for a value produced
+// during execution. Its contents are unspecified; the caller
+// must overwrite all of it. VMs not obtained from getVM
+// (for instance in tests) allocate normally.
+func (vm *virtualMachine) alloc(n int) []byte {
+	if vm.arena == nil {
+		return make([]byte, n)
+	}
+	return vm.arena.alloc(n)
+}
@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"chain/protocol/bc"
+)
+
+func TestArenaAlloc(t *testing.T) {
+	a := new(arena)
+	x := a.alloc(3)
+	copy(x, "abc")
+	y := a.alloc(3)
+	copy(y, "def")
+
+	// Appending to one allocation must not clobber the next.
+	x = append(x, 'z')
+	if !bytes.Equal(y, []byte("def")) {
+		t.Errorf("y = %q want %q", y, "def")
+	}
+	if !bytes.Equal(x, []byte("abcz")) {
+		t.Errorf("x = %q want %q", x, "abcz")
+	}
+
+	big := a.alloc(arenaChunk)
+	if len(big) != arenaChunk {
+		t.Errorf("len(big) = %d want %d", len(big), arenaChunk)
+	}
+	if z := a.alloc(0); z == nil || len(z) != 0 {
+		t.Errorf("alloc(0) = %#v want empty non-nil slice", z)
+	}
+}
+
+func TestPutVM(t *testing.T) {
+	vm := getVM()
+	vm.runLimit = initialRunLimit
+	vm.program = []byte{byte(OP_1)}
+	for i := 0; i < 3; i++ {
+		vm.pushInt64(int64(i+1), false)
+	}
+	vm.altStack = append(vm.altStack, []byte{1})
+	stack := vm.dataStack
+	putVM(vm)
+
+	if len(vm.dataStack) != 0 || len(vm.altStack) != 0 || vm.runLimit != 0 || vm.program != nil {
+		t.Errorf("putVM left state behind: %+v", vm)
+	}
+	for i, item := range stack[:cap(stack)] {
+		if item != nil {
+			t.Errorf("pooled stack retains item %d: %x", i, item)
+		}
+	}
+	if vm.arena == nil {
+		t.Error("putVM dropped the arena")
+	}
+}
+
+func BenchmarkVerifyTxInput(b *testing.B) {
+	// A predicate exercising pushes, arithmetic, stack
+	// manipulation and a nested CHECKPREDICATE.
+	inner, err := Assemble("2ROT 2SWAP 2 ROLL ADD DROP DROP ADD ADD 8 NUMEQUAL")
+	if err != nil {
+		b.Fatal(err)
+	}
+	prog, err := Assemble("0x0102 0x03 CAT DROP 1 2 3 4 5 6 6 0x" + hex.EncodeToString(inner) + " 0 CHECKPREDICATE")
+	if err != nil {
+		b.Fatal(err)
+	}
+	tx := bc.NewTx(bc.TxData{
+		Version: 1,
+		Inputs: []*bc.TxInput{
+			bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil),
+		},
+	})
+	ok, err := VerifyTxInput(tx, 0)
+	if err != nil || !ok {
+		b.Fatalf("VerifyTxInput = %v, %v", ok, err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		VerifyTxInput(tx, 0)
+	}
+}
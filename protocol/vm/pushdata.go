@@ -15,7 +15,7 @@ func opPushdata(vm *virtualMachine) error {
 	if err != nil {
 		return err
 	}
-	d := make([]byte, len(vm.data))
+	d := vm.alloc(len(vm.data))
 	copy(d, vm.data)
 	return vm.push(d, false)
 }
@@ -21,7 +21,10 @@ func opCat(vm *virtualMachine) error {
 		return err
 	}
 	vm.deferCost(-lens)
-	err = vm.push(append(a, b...), true)
+	res := vm.alloc(len(a) + len(b))
+	copy(res, a)
+	copy(res[len(a):], b)
+	err = vm.push(res, true)
 	if err != nil {
 		return err
 	}
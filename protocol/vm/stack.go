@@ -94,12 +94,11 @@ func op2Rot(vm *virtualMachine) error {
 	if len(vm.dataStack) < 6 {
 		return ErrDataStackUnderflow
 	}
-	newStack := make([][]byte, 0, len(vm.dataStack))
-	newStack = append(newStack, vm.dataStack[:len(vm.dataStack)-6]...)
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-4:]...)
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-6])
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-5])
-	vm.dataStack = newStack
+	// Rotate in place: x1 x2 x3 x4 x5 x6 -> x3 x4 x5 x6 x1 x2.
+	top := vm.dataStack[len(vm.dataStack)-6:]
+	x1, x2 := top[0], top[1]
+	copy(top, top[2:])
+	top[4], top[5] = x1, x2
 	return nil
 }
 
@@ -111,12 +110,8 @@ func op2Swap(vm *virtualMachine) error {
 	if len(vm.dataStack) < 4 {
 		return ErrDataStackUnderflow
 	}
-	newStack := make([][]byte, 0, len(vm.dataStack))
-	newStack = append(newStack, vm.dataStack[:len(vm.dataStack)-4]...)
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-2:]...)
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-4])
-	newStack = append(newStack, vm.dataStack[len(vm.dataStack)-3])
-	vm.dataStack = newStack
+	top := vm.dataStack[len(vm.dataStack)-4:]
+	top[0], top[1], top[2], top[3] = top[2], top[3], top[0], top[1]
 	return nil
 }
 
@@ -269,12 +264,10 @@ func rot(vm *virtualMachine, n int64) error {
 	if int64(len(vm.dataStack)) < n {
 		return ErrDataStackUnderflow
 	}
-	index := int64(len(vm.dataStack)) - n
-	newStack := make([][]byte, 0, len(vm.dataStack))
-	newStack = append(newStack, vm.dataStack[:index]...)
-	newStack = append(newStack, vm.dataStack[index+1:]...)
-	newStack = append(newStack, vm.dataStack[index])
-	vm.dataStack = newStack
+	top := vm.dataStack[int64(len(vm.dataStack))-n:]
+	x := top[0]
+	copy(top, top[1:])
+	top[len(top)-1] = x
 	return nil
 }
 
@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -17,8 +18,10 @@ type virtualMachine struct {
 	program      []byte // the program currently executing
 	mainprog     []byte // the outermost program, returned by OP_PROGRAM
 	pc, nextPC   uint32
+	vmVersion    uint64
 	runLimit     int64
 	deferredCost int64
+	sigChecks    int64 // signature verifications, including in child VMs
 
 	expansionReserved bool
 
@@ -38,6 +41,14 @@ type virtualMachine struct {
 	sigHasher  *bc.SigHasher
 
 	block *bc.Block
+
+	// txBlock is the header of the block including tx,
+	// if known. See blockHeader.
+	txBlock *bc.BlockHeader
+
+	// Scratch memory for values produced during execution,
+	// reused across runs. Nil if the VM isn't pooled.
+	arena *arena
 }
 
 // TraceOut - if non-nil - will receive trace output during
@@ -51,10 +62,84 @@ func VerifyTxInput(tx *bc.Tx, inputIndex uint32) (ok bool, err error) {
 			err = ErrUnexpected
 		}
 	}()
-	return verifyTxInput(tx, inputIndex)
+	return verifyTxInput(tx, inputIndex, nil, nil, nil)
+}
+
+// Cost is the work done running a program: the run limit it
+// used up and the number of signatures it verified.
+type Cost struct {
+	RunCost   int64
+	SigChecks int64
+}
+
+// MeterTxInput is like VerifyTxInput, but it also returns
+// the cost of running the input's program. The cost is
+// reported even if the program fails.
+func MeterTxInput(tx *bc.Tx, inputIndex uint32) (ok bool, cost Cost, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	ok, err = verifyTxInput(tx, inputIndex, nil, nil, &cost)
+	return ok, cost, err
+}
+
+// MeterTxInputInBlock is like MeterTxInput, but runs the input's
+// program as part of a block with the given header. From VM
+// version 3, programs can read the block's height and timestamp.
+func MeterTxInputInBlock(tx *bc.Tx, inputIndex uint32, block *bc.BlockHeader) (ok bool, cost Cost, err error) {
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			ok = false
+			err = ErrUnexpected
+		}
+	}()
+	ok, err = verifyTxInput(tx, inputIndex, block, nil, &cost)
+	return ok, cost, err
+}
+
+// A Failure describes how a transaction input's program failed.
+// Err is nil if the program ran to completion but left a false
+// result. PC is the position in Prog of the instruction that
+// failed, or the program's length if it completed, and Stack is
+// the data stack at that point, top element last.
+type Failure struct {
+	Err   error
+	Prog  []byte
+	PC    uint32
+	Stack [][]byte
+}
+
+// InspectTxInput runs the program of a transaction input like
+// VerifyTxInput. If the program fails, it returns a description
+// of the failure. It returns nil if the input is valid.
+func InspectTxInput(tx *bc.Tx, inputIndex uint32) (f *Failure) {
+	f = new(Failure)
+	defer func() {
+		if panErr := recover(); panErr != nil {
+			f.Err = ErrUnexpected
+		}
+	}()
+	ok, err := verifyTxInput(tx, inputIndex, nil, f, nil)
+	if ok && err == nil {
+		return nil
+	}
+	if verr, isVMErr := err.(Error); isVMErr {
+		err = verr.Err
+	}
+	f.Err = err
+	return f
 }
 
-func verifyTxInput(tx *bc.Tx, inputIndex uint32) (bool, error) {
+// verifyTxInput runs the program of a transaction input.
+// If block is not nil, the program runs as part of the block
+// with that header. If fail is not nil and the program fails,
+// verifyTxInput records the program's state in it. If cost is
+// not nil, verifyTxInput records the cost of running the
+// program in it.
+func verifyTxInput(tx *bc.Tx, inputIndex uint32, block *bc.BlockHeader, fail *Failure, cost *Cost) (bool, error) {
 	if inputIndex < 0 || inputIndex >= uint32(len(tx.Inputs)) {
 		return false, ErrBadValue
 	}
@@ -66,21 +151,26 @@ func verifyTxInput(tx *bc.Tx, inputIndex uint32) (bool, error) {
 	sigHasher := bc.NewSigHasher(&tx.TxData)
 
 	f := func(vmversion uint64, prog []byte, args [][]byte) (bool, error) {
-		if vmversion != 1 {
+		if vmversion < 1 || vmversion > bc.MaxVMVersion {
 			return false, ErrUnsupportedVM
 		}
 
-		vm := virtualMachine{
-			tx:         tx,
-			inputIndex: inputIndex,
-			sigHasher:  sigHasher,
-
-			expansionReserved: expansionReserved,
-
-			mainprog: prog,
-			program:  prog,
-			runLimit: initialRunLimit,
+		vm := getVM()
+		defer putVM(vm)
+		vm.vmVersion = vmversion
+		vm.tx = tx
+		vm.inputIndex = inputIndex
+		vm.sigHasher = sigHasher
+		vm.txBlock = block
+		vm.expansionReserved = expansionReserved
+		vm.mainprog = prog
+		vm.program = prog
+		vm.runLimit = initialRunLimit
+
+		if fail != nil {
+			fail.Prog = prog
 		}
+
 		for _, arg := range args {
 			err := vm.push(arg, false)
 			if err != nil {
@@ -88,7 +178,20 @@ func verifyTxInput(tx *bc.Tx, inputIndex uint32) (bool, error) {
 			}
 		}
 		ok, err := vm.run()
-		return ok, wrapErr(err, &vm, args)
+		if cost != nil {
+			cost.RunCost = initialRunLimit - vm.runLimit
+			cost.SigChecks = vm.sigChecks
+		}
+		if fail != nil && (err != nil || !ok) {
+			// The VM and its stack are reused once it's returned
+			// to the pool, so copy what we keep.
+			fail.PC = vm.pc
+			fail.Stack = make([][]byte, 0, len(vm.dataStack))
+			for _, item := range vm.dataStack {
+				fail.Stack = append(fail.Stack, append([]byte(nil), item...))
+			}
+		}
+		return ok, wrapErr(err, vm, args)
 	}
 
 	switch inp := txinput.TypedInput.(type) {
@@ -97,7 +200,8 @@ func verifyTxInput(tx *bc.Tx, inputIndex uint32) (bool, error) {
 	case *bc.SpendInput:
 		return f(inp.VMVersion, inp.ControlProgram, inp.Arguments)
 	}
-	return false, errors.WithDetailf(ErrUnsupportedTx, "transaction input %d has unknown type %T", inputIndex, txinput.TypedInput)
+	err := errors.WithDetailf(ErrUnsupportedTx, "transaction input has unknown type %T", txinput.TypedInput)
+	return false, errors.WithData(err, errors.KeyInputIndex, inputIndex)
 }
 
 func VerifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (ok bool, err error) {
@@ -111,15 +215,14 @@ func VerifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (ok bool, err erro
 }
 
 func verifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (bool, error) {
-	vm := virtualMachine{
-		block: block,
-
-		expansionReserved: true,
-
-		mainprog: prev.ConsensusProgram,
-		program:  prev.ConsensusProgram,
-		runLimit: initialRunLimit,
-	}
+	vm := getVM()
+	defer putVM(vm)
+	vm.vmVersion = 1
+	vm.block = block
+	vm.expansionReserved = true
+	vm.mainprog = prev.ConsensusProgram
+	vm.program = prev.ConsensusProgram
+	vm.runLimit = initialRunLimit
 
 	for _, arg := range block.Witness {
 		err := vm.push(arg, false)
@@ -129,7 +232,7 @@ func verifyBlockHeader(prev *bc.BlockHeader, block *bc.Block) (bool, error) {
 	}
 
 	ok, err := vm.run()
-	return ok, wrapErr(err, &vm, block.Witness)
+	return ok, wrapErr(err, vm, block.Witness)
 }
 
 func (vm *virtualMachine) run() (bool, error) {
@@ -161,7 +264,7 @@ func (vm *virtualMachine) step() error {
 		fmt.Fprint(TraceOut, "\n")
 	}
 
-	if isExpansion[inst.Op] {
+	if isExpansion[inst.Op] || vm.vmVersion < minVMVersion[inst.Op] {
 		if vm.expansionReserved {
 			return ErrDisallowedOpcode
 		}
@@ -209,7 +312,18 @@ func (vm *virtualMachine) pushBool(b bool, deferred bool) error {
 }
 
 func (vm *virtualMachine) pushInt64(n int64, deferred bool) error {
-	return vm.push(Int64Bytes(n), deferred)
+	if n == 0 || vm.arena == nil {
+		return vm.push(Int64Bytes(n), deferred)
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(n))
+	l := len(buf)
+	for buf[l-1] == 0 {
+		l--
+	}
+	res := vm.alloc(l)
+	copy(res, buf[:l])
+	return vm.push(res, deferred)
 }
 
 func (vm *virtualMachine) pop(deferred bool) ([]byte, error) {
@@ -204,7 +204,7 @@ func TestVerifyTxInput(t *testing.T) {
 	}, {
 		input: &bc.TxInput{
 			TypedInput: &bc.IssuanceInput{
-				VMVersion: 2,
+				VMVersion: 4,
 			},
 		},
 		wantErr: ErrUnsupportedVM,
@@ -212,7 +212,7 @@ func TestVerifyTxInput(t *testing.T) {
 		input: &bc.TxInput{
 			TypedInput: &bc.SpendInput{
 				OutputCommitment: bc.OutputCommitment{
-					VMVersion: 2,
+					VMVersion: 4,
 				},
 			},
 		},
@@ -250,6 +250,44 @@ func TestVerifyTxInput(t *testing.T) {
 	}
 }
 
+func TestInspectTxInput(t *testing.T) {
+	cases := []struct {
+		prog      []byte
+		args      [][]byte
+		want      *Failure
+		wantValid bool
+	}{{
+		prog:      []byte{byte(OP_ADD), byte(OP_5), byte(OP_NUMEQUAL)},
+		args:      [][]byte{{2}, {3}},
+		wantValid: true,
+	}, {
+		prog: []byte{byte(OP_ADD), byte(OP_5), byte(OP_NUMEQUAL)},
+		args: [][]byte{{2}, {4}},
+		want: &Failure{PC: 3, Stack: [][]byte{nil}},
+	}, {
+		prog: []byte{byte(OP_ADD), byte(OP_5), byte(OP_NUMEQUALVERIFY)},
+		args: [][]byte{{2}, {4}},
+		want: &Failure{Err: ErrVerifyFailed, PC: 2, Stack: [][]byte{}},
+	}}
+
+	for i, c := range cases {
+		tx := &bc.Tx{TxData: bc.TxData{
+			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, c.args, bc.AssetID{}, 1, c.prog, nil)},
+		}}
+		got := InspectTxInput(tx, 0)
+		if c.wantValid {
+			if got != nil {
+				t.Errorf("InspectTxInput(%d) = %+v want nil", i, got)
+			}
+			continue
+		}
+		c.want.Prog = c.prog
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("InspectTxInput(%d) = %+v want %+v", i, got, c.want)
+		}
+	}
+}
+
 func TestVerifyBlockHeader(t *testing.T) {
 	block := &bc.Block{
 		BlockHeader: bc.BlockHeader{Witness: [][]byte{{2}, {3}}},
@@ -474,7 +512,7 @@ func TestVerifyTxInputQuickCheck(t *testing.T) {
 		tx := bc.NewTx(bc.TxData{
 			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, witnesses, bc.AssetID{}, 10, program, nil)},
 		})
-		verifyTxInput(tx, 0)
+		verifyTxInput(tx, 0, nil, nil, nil)
 		return true
 	}
 	if err := quick.Check(f, nil); err != nil {
@@ -503,3 +541,37 @@ func TestVerifyBlockHeaderQuickCheck(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestMeterTxInput(t *testing.T) {
+	sig, msg, pubkey := make([]byte, 64), make([]byte, 32), make([]byte, 32)
+	cases := []struct {
+		prog []byte
+		args [][]byte
+		want Cost
+	}{{
+		prog: []byte{byte(OP_ADD), byte(OP_5), byte(OP_NUMEQUAL)},
+		args: [][]byte{{2}, {3}},
+		want: Cost{RunCost: 14},
+	}, {
+		prog: []byte{byte(OP_CHECKSIG), byte(OP_NOT)},
+		args: [][]byte{sig, msg, pubkey},
+		want: Cost{RunCost: 1035, SigChecks: 1},
+	}, {
+		prog: append(append([]byte{byte(OP_3)}, PushdataBytes([]byte{byte(OP_CHECKSIG), byte(OP_NOT)})...), byte(OP_0), byte(OP_CHECKPREDICATE)),
+		args: [][]byte{sig, msg, pubkey},
+		want: Cost{RunCost: 1102, SigChecks: 1},
+	}}
+
+	for i, c := range cases {
+		tx := &bc.Tx{TxData: bc.TxData{
+			Inputs: []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, c.args, bc.AssetID{}, 1, c.prog, nil)},
+		}}
+		ok, got, err := MeterTxInput(tx, 0)
+		if err != nil || !ok {
+			t.Errorf("MeterTxInput(%d) = %v, %v want true, nil", i, ok, err)
+		}
+		if got != c.want {
+			t.Errorf("MeterTxInput(%d) cost = %+v want %+v", i, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package vmutil
+
+import (
+	"bytes"
+
+	"chain/protocol/vm"
+)
+
+// Program types recognized by DisassembleProgram.
+const (
+	ProgramTypeP2SP       = "p2sp"
+	ProgramTypeTieredP2SP = "tiered_p2sp"
+	ProgramTypeRetire     = "retire"
+	ProgramTypeFee        = "fee"
+	ProgramTypeHTLC       = "htlc"
+	ProgramTypeTimeLock   = "time_lock"
+	ProgramTypeUnknown    = "unknown"
+)
+
+// Roles of data pushed by the programs of recognized types.
+const (
+	RolePubkey          = "pubkey"
+	RoleQuorum          = "quorum"
+	RolePubkeyCount     = "pubkey_count"
+	RoleHash            = "hash"
+	RoleRecipientPubkey = "recipient_pubkey"
+	RoleRefundPubkey    = "refund_pubkey"
+	RoleExpiry          = "expiry"
+	RoleTierAmount      = "tier_amount"
+	RoleTierQuorum      = "tier_quorum"
+	RoleUnlockHeight    = "unlock_height"
+	RoleUnlockTime      = "unlock_time"
+)
+
+// An Instruction is one instruction of a disassembled program.
+// Role says what the data it pushes means, if the program is of
+// a recognized type; it's empty otherwise.
+type Instruction struct {
+	Op   vm.Op
+	Data []byte
+	Role string
+}
+
+// A DisassembledProgram is a program broken into instructions,
+// with the standard template it follows, if any, identified.
+type DisassembledProgram struct {
+	Type         string
+	Instructions []Instruction
+}
+
+// DisassembleProgram parses prog into instructions and identifies
+// the standard template it follows: a P2SP multisig program, as
+// made for accounts and assets; a tiered P2SP multisig program, as
+// made by TieredMultiSigProgram; the fee program; another
+// retirement program; an HTLC program, as made by HTLCProgram; or
+// a time lock program, as made by TimeLockProgram. A program that
+// follows none of them has type ProgramTypeUnknown.
+func DisassembleProgram(prog []byte) (*DisassembledProgram, error) {
+	insts, err := vm.ParseProgram(prog)
+	if err != nil && IsUnspendable(prog) {
+		// Whatever follows FAIL is never executed
+		// and need not parse. Keep it as FAIL's data.
+		return &DisassembledProgram{
+			Type:         ProgramTypeRetire,
+			Instructions: []Instruction{{Op: vm.OP_FAIL, Data: prog[1:]}},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	d := &DisassembledProgram{Type: ProgramTypeUnknown}
+	for _, inst := range insts {
+		d.Instructions = append(d.Instructions, Instruction{Op: inst.Op, Data: inst.Data})
+	}
+
+	switch {
+	case IsFeeProgram(prog):
+		d.Type = ProgramTypeFee
+	case IsUnspendable(prog):
+		d.Type = ProgramTypeRetire
+	case isHTLC(prog):
+		d.Type = ProgramTypeHTLC
+		roles := map[int]string{1: RoleHash, 5: RoleExpiry, 9: RoleRefundPubkey, 12: RoleRecipientPubkey}
+		for i, role := range roles {
+			d.Instructions[i].Role = role
+		}
+	case isTimeLock(prog):
+		// The locked program's instructions get no role.
+		d.Type = ProgramTypeTimeLock
+		_, height, unlockTime, _ := ParseTimeLockProgram(prog)
+		i := 1
+		if height > 0 {
+			d.Instructions[i].Role = RoleUnlockHeight
+			i += 4
+		}
+		if unlockTime > 0 {
+			d.Instructions[i].Role = RoleUnlockTime
+		}
+	case isP2SP(prog):
+		// Any instructions before the template
+		// (such as <data> DROP) get no role.
+		d.Type = ProgramTypeP2SP
+		pubkeys, _, _ := ParseP2SPMultiSigProgram(prog)
+		first := len(insts) - 7 - len(pubkeys)
+		for i := range pubkeys {
+			d.Instructions[first+i].Role = RolePubkey
+		}
+		d.Instructions[len(insts)-7].Role = RoleQuorum
+		d.Instructions[len(insts)-6].Role = RolePubkeyCount
+	case isTieredP2SP(prog):
+		d.Type = ProgramTypeTieredP2SP
+		pubkeys, _, tiers, _ := ParseTieredMultiSigProgram(prog)
+		for i := range pubkeys {
+			d.Instructions[3+i].Role = RolePubkey
+		}
+		d.Instructions[3+len(pubkeys)].Role = RoleQuorum
+		for i := range tiers {
+			first := 4 + len(pubkeys) + 6*i
+			d.Instructions[first+1].Role = RoleTierAmount
+			d.Instructions[first+5].Role = RoleTierQuorum
+		}
+		d.Instructions[len(insts)-6].Role = RolePubkeyCount
+	}
+	return d, nil
+}
+
+// ProgramType returns the type of the standard template prog
+// follows, as identified by DisassembleProgram.
+func ProgramType(prog []byte) string {
+	switch {
+	case IsFeeProgram(prog):
+		return ProgramTypeFee
+	case IsUnspendable(prog):
+		return ProgramTypeRetire
+	case isHTLC(prog):
+		return ProgramTypeHTLC
+	case isTimeLock(prog):
+		return ProgramTypeTimeLock
+	case isP2SP(prog):
+		return ProgramTypeP2SP
+	case isTieredP2SP(prog):
+		return ProgramTypeTieredP2SP
+	}
+	return ProgramTypeUnknown
+}
+
+func isHTLC(prog []byte) bool {
+	_, _, _, _, err := ParseHTLCProgram(prog)
+	return err == nil
+}
+
+func isTimeLock(prog []byte) bool {
+	_, _, _, err := ParseTimeLockProgram(prog)
+	return err == nil
+}
+
+func isP2SP(prog []byte) bool {
+	pubkeys, quorum, err := ParseP2SPMultiSigProgram(prog)
+	if err != nil {
+		return false
+	}
+	// ParseP2SPMultiSigProgram checks only the keys and
+	// counts. Rebuilding the program checks the rest.
+	want, err := P2SPMultiSigProgram(pubkeys, quorum)
+	return err == nil && bytes.HasSuffix(prog, want)
+}
+
+func isTieredP2SP(prog []byte) bool {
+	_, _, _, err := ParseTieredMultiSigProgram(prog)
+	return err == nil
+}
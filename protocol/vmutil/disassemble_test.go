@@ -0,0 +1,76 @@
+package vmutil
+
+import (
+	"testing"
+
+	"chain/crypto/ed25519"
+	"chain/protocol/vm"
+)
+
+func TestDisassembleProgram(t *testing.T) {
+	pub1, _, _ := ed25519.GenerateKey(nil)
+	pub2, _, _ := ed25519.GenerateKey(nil)
+	p2sp, _ := P2SPMultiSigProgram([]ed25519.PublicKey{pub1, pub2}, 1)
+	htlc, _ := HTLCProgram(make([]byte, 32), pub1, pub2, 1000)
+	timeLock, _ := TimeLockProgram(p2sp, 100, 1000)
+	tiered, _ := TieredMultiSigProgram([]ed25519.PublicKey{pub1, pub2}, 1, []QuorumTier{{Amount: 100, Quorum: 2}})
+
+	cases := []struct {
+		prog      []byte
+		wantType  string
+		wantRoles map[int]string
+	}{{
+		prog:      p2sp,
+		wantType:  ProgramTypeP2SP,
+		wantRoles: map[int]string{3: RolePubkey, 4: RolePubkey, 5: RoleQuorum, 6: RolePubkeyCount},
+	}, {
+		prog:      append([]byte{byte(vm.OP_1), byte(vm.OP_DROP)}, p2sp...),
+		wantType:  ProgramTypeP2SP,
+		wantRoles: map[int]string{5: RolePubkey, 6: RolePubkey, 7: RoleQuorum, 8: RolePubkeyCount},
+	}, {
+		prog:      tiered,
+		wantType:  ProgramTypeTieredP2SP,
+		wantRoles: map[int]string{3: RolePubkey, 4: RolePubkey, 5: RoleQuorum, 7: RoleTierAmount, 11: RoleTierQuorum, 12: RolePubkeyCount},
+	}, {
+		prog:      htlc,
+		wantType:  ProgramTypeHTLC,
+		wantRoles: map[int]string{1: RoleHash, 5: RoleExpiry, 9: RoleRefundPubkey, 12: RoleRecipientPubkey},
+	}, {
+		prog:      timeLock,
+		wantType:  ProgramTypeTimeLock,
+		wantRoles: map[int]string{1: RoleUnlockHeight, 5: RoleUnlockTime},
+	}, {
+		prog:     []byte{byte(vm.OP_FAIL), byte(vm.OP_DATA_4), 1},
+		wantType: ProgramTypeRetire,
+	}, {
+		prog:     FeeProgram(),
+		wantType: ProgramTypeFee,
+	}, {
+		prog:     []byte{byte(vm.OP_ADD), byte(vm.OP_5), byte(vm.OP_NUMEQUAL)},
+		wantType: ProgramTypeUnknown,
+	}}
+
+	for i, c := range cases {
+		d, err := DisassembleProgram(c.prog)
+		if err != nil {
+			t.Errorf("case %d: %s", i, err)
+			continue
+		}
+		if d.Type != c.wantType {
+			t.Errorf("case %d: type = %s want %s", i, d.Type, c.wantType)
+		}
+		if got := ProgramType(c.prog); got != c.wantType {
+			t.Errorf("case %d: ProgramType = %s want %s", i, got, c.wantType)
+		}
+		for j, inst := range d.Instructions {
+			if inst.Role != c.wantRoles[j] {
+				t.Errorf("case %d: instruction %d (%s) role = %q want %q", i, j, inst.Op, inst.Role, c.wantRoles[j])
+			}
+		}
+	}
+
+	_, err := DisassembleProgram([]byte{byte(vm.OP_DATA_4), 1})
+	if err == nil {
+		t.Error("DisassembleProgram(truncated) err = nil, want error")
+	}
+}
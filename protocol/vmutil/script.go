@@ -1,6 +1,10 @@
 package vmutil
 
 import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
 	"chain/crypto/ed25519"
 	"chain/errors"
 	"chain/protocol/vm"
@@ -9,12 +13,30 @@ import (
 var (
 	ErrBadValue       = errors.New("bad value")
 	ErrMultisigFormat = errors.New("bad multisig program format")
+	ErrHTLCFormat     = errors.New("bad HTLC program format")
+	ErrTimeLockFormat = errors.New("bad time lock program format")
 )
 
 func IsUnspendable(prog []byte) bool {
 	return len(prog) > 0 && prog[0] == byte(vm.OP_FAIL)
 }
 
+var feeProgram = NewBuilder().AddOp(vm.OP_FAIL).AddData([]byte("fee")).Program
+
+// FeeProgram returns the retirement program of outputs
+// that pay a transaction fee: FAIL "fee". Units sent to
+// it are retired like any others, but count as the
+// fee of the transaction that sends them.
+func FeeProgram() []byte {
+	return append([]byte{}, feeProgram...)
+}
+
+// IsFeeProgram reports whether prog is the program
+// returned by FeeProgram.
+func IsFeeProgram(prog []byte) bool {
+	return bytes.Equal(prog, feeProgram)
+}
+
 // BlockMultiSigProgram returns a valid multisignature consensus
 // program where nrequired of the keys in pubkeys are required to have
 // signed the block for success.  An ErrBadValue will be returned if
@@ -135,6 +157,279 @@ func ParseP2SPMultiSigProgram(program []byte) ([]ed25519.PublicKey, int, error)
 	return pubkeys, int(nrequired), nil
 }
 
+// HTLCProgram returns a hash time-locked contract program. The
+// recipient can spend by revealing a preimage of hash, a SHA3-256
+// digest, and signing. Once the transaction's min time is after
+// expiry, in milliseconds, the refund key can spend by signing
+// instead. Arguments are [SIG PREIMAGE]; a refund may pass any
+// value as PREIMAGE. The result is:
+//
+//	SHA3 <hash> EQUAL JUMPIF:$claim
+//	MINTIME <expiry> GREATERTHAN VERIFY TXSIGHASH <refund> JUMP:$check
+//	$claim TXSIGHASH <recipient> $check CHECKSIG
+func HTLCProgram(hash []byte, recipient, refund ed25519.PublicKey, expiry uint64) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, errors.WithDetail(ErrBadValue, "hash must be 32 bytes")
+	}
+	if len(recipient) != ed25519.PublicKeySize || len(refund) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrBadValue, "bad public key size")
+	}
+	if expiry > math.MaxInt64 {
+		return nil, errors.WithDetail(ErrBadValue, "expiry too big")
+	}
+	builder := NewBuilder()
+	builder.AddOp(vm.OP_SHA3).AddData(hash).AddOp(vm.OP_EQUAL)
+	claimJump := len(builder.Program) + 1
+	builder.AddOp(vm.OP_JUMPIF).AddRawBytes(make([]byte, 4))
+	builder.AddOp(vm.OP_MINTIME).AddInt64(int64(expiry)).AddOp(vm.OP_GREATERTHAN).AddOp(vm.OP_VERIFY)
+	builder.AddOp(vm.OP_TXSIGHASH).AddData(refund)
+	checkJump := len(builder.Program) + 1
+	builder.AddOp(vm.OP_JUMP).AddRawBytes(make([]byte, 4))
+	binary.LittleEndian.PutUint32(builder.Program[claimJump:], uint32(len(builder.Program)))
+	builder.AddOp(vm.OP_TXSIGHASH).AddData(recipient)
+	binary.LittleEndian.PutUint32(builder.Program[checkJump:], uint32(len(builder.Program)))
+	builder.AddOp(vm.OP_CHECKSIG)
+	return builder.Program, nil
+}
+
+// ParseHTLCProgram returns the parameters of a
+// program built by HTLCProgram.
+func ParseHTLCProgram(program []byte) (hash []byte, recipient, refund ed25519.PublicKey, expiry uint64, err error) {
+	pops, err := vm.ParseProgram(program)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
+	if len(pops) != 14 {
+		return nil, nil, nil, 0, ErrHTLCFormat
+	}
+	n, err := vm.AsInt64(pops[5].Data)
+	if err != nil || n < 0 {
+		return nil, nil, nil, 0, errors.Wrap(ErrHTLCFormat, "parsing expiry")
+	}
+	hash, refund, recipient, expiry = pops[1].Data, pops[9].Data, pops[12].Data, uint64(n)
+
+	// The parameters determine the whole program,
+	// so rebuilding it checks everything else.
+	want, err := HTLCProgram(hash, recipient, refund, expiry)
+	if err != nil || !bytes.Equal(want, program) {
+		return nil, nil, nil, 0, ErrHTLCFormat
+	}
+	return hash, recipient, refund, expiry, nil
+}
+
+// TimeLockVMVersion is the VM version of outputs controlled
+// by time lock programs: the first with BLOCKHEIGHT and
+// BLOCKTIME reading the block including the transaction.
+const TimeLockVMVersion = 3
+
+// TimeLockProgram returns prog locked until the block height
+// reaches unlockHeight and the block time, in milliseconds,
+// reaches unlockTime. A zero value imposes no lock; at least
+// one must be nonzero. Prog must not jump, since the lock
+// moves its instructions. Outputs controlled by the result
+// must have VM version TimeLockVMVersion. The result is:
+//
+//	BLOCKHEIGHT <unlockHeight> GREATERTHANOREQUAL VERIFY
+//	BLOCKTIME <unlockTime> GREATERTHANOREQUAL VERIFY
+//	<prog>
+//
+// with either lock left out if its value is zero.
+func TimeLockProgram(prog []byte, unlockHeight, unlockTime uint64) ([]byte, error) {
+	if unlockHeight == 0 && unlockTime == 0 {
+		return nil, errors.WithDetail(ErrBadValue, "no unlock height or time")
+	}
+	if len(prog) == 0 {
+		return nil, errors.WithDetail(ErrBadValue, "empty program")
+	}
+	if unlockHeight > math.MaxInt64 || unlockTime > math.MaxInt64 {
+		return nil, errors.WithDetail(ErrBadValue, "unlock height or time too big")
+	}
+	insts, err := vm.ParseProgram(prog)
+	if err != nil {
+		return nil, err
+	}
+	for _, inst := range insts {
+		if inst.Op == vm.OP_JUMP || inst.Op == vm.OP_JUMPIF {
+			return nil, errors.WithDetail(ErrBadValue, "program jumps")
+		}
+	}
+	builder := NewBuilder()
+	if unlockHeight > 0 {
+		builder.AddOp(vm.OP_BLOCKHEIGHT).AddInt64(int64(unlockHeight))
+		builder.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	}
+	if unlockTime > 0 {
+		builder.AddOp(vm.OP_BLOCKTIME).AddInt64(int64(unlockTime))
+		builder.AddOp(vm.OP_GREATERTHANOREQUAL).AddOp(vm.OP_VERIFY)
+	}
+	builder.AddRawBytes(prog)
+	return builder.Program, nil
+}
+
+// ParseTimeLockProgram returns the parameters of a
+// program built by TimeLockProgram.
+func ParseTimeLockProgram(program []byte) (prog []byte, unlockHeight, unlockTime uint64, err error) {
+	rest := program
+	for _, op := range []vm.Op{vm.OP_BLOCKHEIGHT, vm.OP_BLOCKTIME} {
+		if len(rest) == 0 || vm.Op(rest[0]) != op {
+			continue
+		}
+		pops, err := vm.ParseProgram(rest)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if len(pops) < 4 || pops[2].Op != vm.OP_GREATERTHANOREQUAL || pops[3].Op != vm.OP_VERIFY {
+			return nil, 0, 0, ErrTimeLockFormat
+		}
+		n, err := vm.AsInt64(pops[1].Data)
+		if err != nil || n <= 0 {
+			return nil, 0, 0, errors.Wrap(ErrTimeLockFormat, "parsing unlock value")
+		}
+		if op == vm.OP_BLOCKHEIGHT {
+			unlockHeight = uint64(n)
+		} else {
+			unlockTime = uint64(n)
+		}
+		var skip uint32
+		for _, pop := range pops[:4] {
+			skip += pop.Len
+		}
+		rest = rest[skip:]
+	}
+
+	// The parameters and prog determine the
+	// whole program, so rebuilding it checks
+	// everything else, including that prog
+	// doesn't jump.
+	want, err := TimeLockProgram(rest, unlockHeight, unlockTime)
+	if err != nil || !bytes.Equal(want, program) {
+		return nil, 0, 0, ErrTimeLockFormat
+	}
+	return rest, unlockHeight, unlockTime, nil
+}
+
+// A QuorumTier raises the number of signatures a tiered multisig
+// program requires to Quorum, for outputs of at least Amount units.
+type QuorumTier struct {
+	Amount uint64
+	Quorum int
+}
+
+// TieredMultiSigProgram returns a P2SP multisig program whose quorum
+// depends on the amount of the output it controls: nrequired below
+// the first tier's amount, and otherwise the quorum of the last tier
+// whose amount the output reaches. Tiers must be in strictly
+// increasing order of amount. The result is P2SPMultiSigProgram's,
+// with the quorum computed by:
+//
+//	<nrequired> (AMOUNT <amount> LESSTHAN JUMPIF:$done DROP <quorum>)... $done
+func TieredMultiSigProgram(pubkeys []ed25519.PublicKey, nrequired int, tiers []QuorumTier) ([]byte, error) {
+	err := checkMultiSigParams(int64(nrequired), int64(len(pubkeys)))
+	if err != nil {
+		return nil, err
+	}
+	if len(tiers) == 0 {
+		return nil, errors.WithDetail(ErrBadValue, "no quorum tiers")
+	}
+	for i, t := range tiers {
+		if t.Amount == 0 || t.Amount > math.MaxInt64 {
+			return nil, errors.WithDetail(ErrBadValue, "tier amount out of range")
+		}
+		if i > 0 && t.Amount <= tiers[i-1].Amount {
+			return nil, errors.WithDetail(ErrBadValue, "tier amounts not increasing")
+		}
+		if t.Quorum <= 0 {
+			return nil, errors.WithDetail(ErrBadValue, "tier quorum not positive")
+		}
+		err = checkMultiSigParams(int64(t.Quorum), int64(len(pubkeys)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	builder := NewBuilder()
+	builder.AddOp(vm.OP_DUP).AddOp(vm.OP_TOALTSTACK).AddOp(vm.OP_SHA3)
+	for _, p := range pubkeys {
+		builder.AddData(p)
+	}
+	builder.AddInt64(int64(nrequired))
+	var jumps []int
+	for _, t := range tiers {
+		builder.AddOp(vm.OP_AMOUNT).AddInt64(int64(t.Amount)).AddOp(vm.OP_LESSTHAN)
+		jumps = append(jumps, len(builder.Program)+1)
+		builder.AddOp(vm.OP_JUMPIF).AddRawBytes(make([]byte, 4))
+		builder.AddOp(vm.OP_DROP).AddInt64(int64(t.Quorum))
+	}
+	for _, j := range jumps {
+		binary.LittleEndian.PutUint32(builder.Program[j:], uint32(len(builder.Program)))
+	}
+	builder.AddInt64(int64(len(pubkeys)))
+	builder.AddOp(vm.OP_CHECKMULTISIG).AddOp(vm.OP_VERIFY)
+	builder.AddOp(vm.OP_FROMALTSTACK)
+	builder.AddInt64(0).AddOp(vm.OP_CHECKPREDICATE)
+	return builder.Program, nil
+}
+
+// ParseTieredMultiSigProgram returns the parameters of a
+// program built by TieredMultiSigProgram.
+func ParseTieredMultiSigProgram(program []byte) (pubkeys []ed25519.PublicKey, nrequired int, tiers []QuorumTier, err error) {
+	pops, err := vm.ParseProgram(program)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if len(pops) < 16 {
+		return nil, 0, nil, ErrMultisigFormat
+	}
+	npubkeys, err := vm.AsInt64(pops[len(pops)-6].Data)
+	if err != nil || npubkeys < 0 || 4+int(npubkeys) > len(pops)-6 {
+		return nil, 0, nil, errors.Wrap(ErrMultisigFormat, "parsing npubkeys")
+	}
+	for _, pop := range pops[3 : 3+npubkeys] {
+		pubkeys = append(pubkeys, ed25519.PublicKey(pop.Data))
+	}
+	n, err := vm.AsInt64(pops[3+npubkeys].Data)
+	if err != nil {
+		return nil, 0, nil, errors.Wrap(ErrMultisigFormat, "parsing quorum")
+	}
+	nrequired = int(n)
+	tierOps := pops[4+npubkeys : len(pops)-6]
+	if len(tierOps)%6 != 0 {
+		return nil, 0, nil, ErrMultisigFormat
+	}
+	for i := 0; i < len(tierOps); i += 6 {
+		amount, err := vm.AsInt64(tierOps[i+1].Data)
+		if err != nil || amount <= 0 {
+			return nil, 0, nil, errors.Wrap(ErrMultisigFormat, "parsing tier amount")
+		}
+		quorum, err := vm.AsInt64(tierOps[i+5].Data)
+		if err != nil {
+			return nil, 0, nil, errors.Wrap(ErrMultisigFormat, "parsing tier quorum")
+		}
+		tiers = append(tiers, QuorumTier{Amount: uint64(amount), Quorum: int(quorum)})
+	}
+
+	// As with HTLCs, rebuilding the program
+	// checks everything else.
+	want, err := TieredMultiSigProgram(pubkeys, nrequired, tiers)
+	if err != nil || !bytes.Equal(want, program) {
+		return nil, 0, nil, ErrMultisigFormat
+	}
+	return pubkeys, nrequired, tiers, nil
+}
+
+// TierQuorum returns the number of signatures a program built by
+// TieredMultiSigProgram requires to spend an output of amount.
+func TierQuorum(nrequired int, tiers []QuorumTier, amount uint64) int {
+	quorum := nrequired
+	for _, t := range tiers {
+		if amount < t.Amount {
+			break
+		}
+		quorum = t.Quorum
+	}
+	return quorum
+}
+
 func checkMultiSigParams(nrequired, npubkeys int64) error {
 	if nrequired < 0 {
 		return errors.WithDetail(ErrBadValue, "negative quorum")
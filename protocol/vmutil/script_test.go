@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"testing"
 
+	"golang.org/x/crypto/sha3"
+
 	"chain/crypto/ed25519"
+	"chain/errors"
+	"chain/protocol/bc"
+	"chain/protocol/vm"
 )
 
 // TestIsUnspendable ensures the IsUnspendable function returns the expected
@@ -108,3 +113,175 @@ func TestBlockMultisig(t *testing.T) {
 		t.Errorf("expected second pubkey to be %x, got %x", pub2, pubs[1])
 	}
 }
+
+func TestHTLC(t *testing.T) {
+	recipientPub, recipientPrv, _ := ed25519.GenerateKey(nil)
+	refundPub, refundPrv, _ := ed25519.GenerateKey(nil)
+	preimage := []byte("secret")
+	hash := sha3.Sum256(preimage)
+	prog, err := HTLCProgram(hash[:], recipientPub, refundPub, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotHash, gotRecipient, gotRefund, gotExpiry, err := ParseHTLCProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotHash, hash[:]) || !bytes.Equal(gotRecipient, recipientPub) || !bytes.Equal(gotRefund, refundPub) || gotExpiry != 1000 {
+		t.Errorf("ParseHTLCProgram = %x %x %x %d", gotHash, gotRecipient, gotRefund, gotExpiry)
+	}
+
+	cases := []struct {
+		prv      ed25519.PrivateKey
+		preimage []byte
+		minTime  uint64
+		want     bool
+	}{
+		{recipientPrv, preimage, 0, true},
+		{recipientPrv, []byte("wrong"), 0, false},
+		{refundPrv, nil, 1001, true},
+		{refundPrv, nil, 1000, false},
+		{refundPrv, preimage, 0, false},
+	}
+	for i, c := range cases {
+		tx := &bc.Tx{TxData: bc.TxData{
+			Version: 1,
+			MinTime: c.minTime,
+			Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)},
+		}}
+		h := bc.NewSigHasher(&tx.TxData).Hash(0)
+		tx.Inputs[0].SetArguments([][]byte{ed25519.Sign(c.prv, h[:]), c.preimage})
+		ok, err := vm.VerifyTxInput(tx, 0)
+		if ok != c.want {
+			t.Errorf("case %d: VerifyTxInput = %v, %v want %v", i, ok, err, c.want)
+		}
+	}
+}
+
+func TestTieredMultiSig(t *testing.T) {
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < 3; i++ {
+		pub, prv, _ := ed25519.GenerateKey(nil)
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	tiers := []QuorumTier{{Amount: 1000, Quorum: 2}, {Amount: 5000, Quorum: 3}}
+	prog, err := TieredMultiSigProgram(pubs, 1, tiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotPubs, gotQuorum, gotTiers, err := ParseTieredMultiSigProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gotPubs) != 3 || gotQuorum != 1 || len(gotTiers) != 2 || gotTiers[1] != tiers[1] {
+		t.Errorf("ParseTieredMultiSigProgram = %x %d %v", gotPubs, gotQuorum, gotTiers)
+	}
+	if isP2SP(prog) {
+		t.Error("tiered program parsed as a plain P2SP program")
+	}
+
+	// The predicate just succeeds; what matters
+	// is how many keys must sign it.
+	pred := []byte{byte(vm.OP_TRUE)}
+	predHash := sha3.Sum256(pred)
+	cases := []struct {
+		amount uint64
+		nsigs  int
+		want   bool
+	}{
+		{999, 1, true},
+		{1000, 1, false},
+		{1000, 2, true},
+		{4999, 2, true},
+		{5000, 2, false},
+		{5000, 3, true},
+	}
+	for _, c := range cases {
+		if q := TierQuorum(1, tiers, c.amount); (q <= c.nsigs) != c.want {
+			t.Errorf("TierQuorum(%d) = %d, inconsistent with case", c.amount, q)
+		}
+		args := [][]byte{vm.Int64Bytes(0)}
+		for _, prv := range prvs[:c.nsigs] {
+			args = append(args, ed25519.Sign(prv, predHash[:]))
+		}
+		args = append(args, pred)
+		tx := &bc.Tx{TxData: bc.TxData{
+			Version: 1,
+			Inputs:  []*bc.TxInput{bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, c.amount, prog, nil)},
+		}}
+		tx.Inputs[0].SetArguments(args)
+		ok, err := vm.VerifyTxInput(tx, 0)
+		if ok != c.want {
+			t.Errorf("amount %d with %d sigs: VerifyTxInput = %v, %v want %v", c.amount, c.nsigs, ok, err, c.want)
+		}
+	}
+
+	_, err = TieredMultiSigProgram(pubs, 1, []QuorumTier{{Amount: 5000, Quorum: 3}, {Amount: 1000, Quorum: 2}})
+	if err == nil {
+		t.Error("expected error for decreasing tier amounts")
+	}
+}
+
+func TestTimeLock(t *testing.T) {
+	pub, prv, _ := ed25519.GenerateKey(nil)
+	sigProg := NewBuilder().AddOp(vm.OP_TXSIGHASH).AddData(pub).AddOp(vm.OP_CHECKSIG).Program
+	prog, err := TimeLockProgram(sigProg, 10, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotProg, gotHeight, gotTime, err := ParseTimeLockProgram(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotProg, sigProg) || gotHeight != 10 || gotTime != 5000 {
+		t.Errorf("ParseTimeLockProgram = %x %d %d", gotProg, gotHeight, gotTime)
+	}
+
+	heightOnly, _ := TimeLockProgram(sigProg, 10, 0)
+	_, gotHeight, gotTime, err = ParseTimeLockProgram(heightOnly)
+	if err != nil || gotHeight != 10 || gotTime != 0 {
+		t.Errorf("ParseTimeLockProgram(height only) = %d %d %v", gotHeight, gotTime, err)
+	}
+	_, _, _, err = ParseTimeLockProgram(sigProg)
+	if err == nil {
+		t.Error("ParseTimeLockProgram(unlocked) err = nil, want error")
+	}
+
+	tiered, _ := TieredMultiSigProgram([]ed25519.PublicKey{pub}, 1, []QuorumTier{{Amount: 100, Quorum: 1}})
+	_, err = TimeLockProgram(tiered, 10, 0)
+	if errors.Root(err) != ErrBadValue {
+		t.Errorf("TimeLockProgram(jumping program) err = %v want %v", err, ErrBadValue)
+	}
+	_, err = TimeLockProgram(sigProg, 0, 0)
+	if errors.Root(err) != ErrBadValue {
+		t.Errorf("TimeLockProgram(no lock) err = %v want %v", err, ErrBadValue)
+	}
+
+	cases := []struct {
+		height, timeMS uint64
+		want           bool
+	}{
+		{10, 5000, true},
+		{11, 6000, true},
+		{9, 5000, false},
+		{10, 4999, false},
+	}
+	for i, c := range cases {
+		in := bc.NewSpendInput(bc.Hash{}, 0, nil, bc.AssetID{}, 1, prog, nil)
+		in.TypedInput.(*bc.SpendInput).VMVersion = TimeLockVMVersion
+		tx := &bc.Tx{TxData: bc.TxData{Version: 1, Inputs: []*bc.TxInput{in}}}
+		h := bc.NewSigHasher(&tx.TxData).Hash(0)
+		in.SetArguments([][]byte{ed25519.Sign(prv, h[:])})
+		ok, _, err := vm.MeterTxInputInBlock(tx, 0, &bc.BlockHeader{Height: c.height, TimestampMS: c.timeMS})
+		if ok != c.want {
+			t.Errorf("case %d: MeterTxInputInBlock = %v, %v want %v", i, ok, err, c.want)
+		}
+	}
+}
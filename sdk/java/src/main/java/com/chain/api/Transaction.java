@@ -480,6 +480,11 @@ public class Transaction {
      * The transaction id.
      */
     public String id;
+
+    /**
+     * How far the transaction got before the response: accepted, processed, or indexed.
+     */
+    public String status;
   }
 
   /**
@@ -521,7 +526,7 @@ public class Transaction {
    * Submits a batch of signed transaction templates for inclusion into a block.
    * @param client client object which makes server requests
    * @param templates list of transaction templates
-   * @param waitUntil when the server should wait until responding - none, confirmed, processed
+   * @param waitUntil when the server should wait until responding - none, processed, indexed
    * @return a list of submit responses (individual objects can hold transaction ids or error info)
    * @throws APIException This exception is raised if the api returns errors while submitting transactions.
    * @throws BadURLException This exception wraps java.net.MalformedURLException.
@@ -560,7 +565,7 @@ public class Transaction {
    * Submits signed transaction template for inclusion into a block.
    * @param client client object which makes server requests
    * @param template transaction template
-   * @param waitUntil when the server should wait until responding - none, confirmed, processed
+   * @param waitUntil when the server should wait until responding - none, processed, indexed
    * @return submit responses
    * @throws APIException This exception is raised if the api returns errors while submitting a transaction.
    * @throws BadURLException This exception wraps java.net.MalformedURLException.